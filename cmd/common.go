@@ -1,31 +1,128 @@
 package cmd
 
 import (
+	"fmt"
+	"net/url"
 	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elb/elbiface"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/globalaccelerator"
+	"github.com/aws/aws-sdk-go/service/globalaccelerator/globalacceleratoriface"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 	"github.com/keikoproj/aws-sdk-go-cache/cache"
 	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/keikoproj/lifecycle-manager/pkg/service"
+	"github.com/keikoproj/lifecycle-manager/pkg/version"
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+const (
+	// GlobalAcceleratorRegion is the region serving the global accelerator API
+	GlobalAcceleratorRegion = "us-west-2"
+	// DefaultKubeAPIQPS and DefaultKubeAPIBurst are the client-side rate limits of Kubernetes clients
+	DefaultKubeAPIQPS   = 100
+	DefaultKubeAPIBurst = 100
+)
+
+var (
+	// kubeAPIQPS and kubeAPIBurst are the client-side rate limits of Kubernetes clients, requests above them are
+	// queued by client-go
+	kubeAPIQPS   float32 = DefaultKubeAPIQPS
+	kubeAPIBurst         = DefaultKubeAPIBurst
+)
+
+var (
+	// awsEndpointURLs overrides the endpoints of AWS APIs, in the form service=url or url for every service
+	awsEndpointURLs []string
+	// awsUseFIPSEndpoint and awsUseDualStackEndpoint resolve the FIPS and dual-stack endpoints of AWS APIs, when unset
+	// the AWS_USE_FIPS_ENDPOINT and AWS_USE_DUALSTACK_ENDPOINT environment variables are honored by the SDK
+	awsUseFIPSEndpoint      bool
+	awsUseDualStackEndpoint bool
+)
+
+var (
+	// targetHealthCacheTTLSeconds is the time to cache target and instance health for, loadBalancerCacheTTLSeconds is
+	// the time to cache target groups, classic-elbs and their attributes for
+	targetHealthCacheTTLSeconds = int64(DescribeTargetHealthTTL.Seconds())
+	loadBalancerCacheTTLSeconds = int64(DescribeLoadBalancersTTL.Seconds())
+	// cacheBackend stores cached AWS API responses in memory or in redis, where replicas share them
+	cacheBackend        = service.CacheBackendMemory
+	cacheRedisAddress   string
+	cacheRedisDB        int
+	cacheRedisKeyPrefix = "lifecycle-manager"
+)
+
+// apiRateLimiter is shared by all ELB, ELBv2 and autoscaling clients, nil disables rate limiting
+var apiRateLimiter *rate.Limiter
+
+func newAPIRateLimiter(requestsPerSecond float64, burst int) *rate.Limiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+}
+
+// addRateLimiting waits for a token from the shared limiter before sending a request, cache hits are not limited
+func addRateLimiting(sess *session.Session) {
+	if apiRateLimiter == nil {
+		return
+	}
+	sess.Handlers.Send.PushFront(func(r *request.Request) {
+		if cache.IsCacheHit(r.HTTPRequest.Context()) {
+			return
+		}
+		if err := apiRateLimiter.Wait(r.Context()); err != nil {
+			r.Error = err
+		}
+	})
+}
+
 func newKubernetesClient(localMode string) *kubernetes.Clientset {
+	return kubernetes.NewForConfigOrDie(newKubernetesConfig(localMode))
+}
+
+// newDynamicClient returns a client of the custom resources lifecycle-manager reads, such as instance groups
+func newDynamicClient(localMode string) dynamic.Interface {
+	return dynamic.NewForConfigOrDie(newKubernetesConfig(localMode))
+}
+
+// newKubernetesConfig returns the configuration of the local cluster, from the kubeconfig at localMode if it is set
+func newKubernetesConfig(localMode string) *rest.Config {
 	var config *rest.Config
 	var err error
 
@@ -41,15 +138,129 @@ func newKubernetesClient(localMode string) *kubernetes.Clientset {
 		if err != nil {
 			log.Fatalln("cannot load kubernetes config from InCluster")
 		}
-		config.QPS = 100
-		config.Burst = 100
 	}
-	return kubernetes.NewForConfigOrDie(config)
+	configureKubernetesClient(config)
+	return config
 }
 
-func newIAMClient(region string) iamiface.IAMAPI {
+// configureKubernetesClient sets the rate limits and user agent of a Kubernetes client
+func configureKubernetesClient(config *rest.Config) {
+	config.QPS = kubeAPIQPS
+	config.Burst = kubeAPIBurst
+	config.UserAgent = kubernetesUserAgent()
+}
+
+// kubernetesUserAgent identifies lifecycle-manager and its version in the audit logs of the API server
+func kubernetesUserAgent() string {
+	agent := fmt.Sprintf("lifecycle-manager/v%v (%v/%v)", version.Version, runtime.GOOS, runtime.GOARCH)
+	if version.GitCommit != "" {
+		agent = fmt.Sprintf("%v %v", agent, version.GitCommit)
+	}
+	return agent
+}
+
+// newClusterClients returns a client per kubeconfig context, routing the scaling groups matching the context's pattern
+func newClusterClients(kubeconfig string, contexts map[string]string) []service.ClusterClient {
+	clients := make([]service.ClusterClient, 0)
+
+	// sort contexts so that overlapping patterns are matched in a stable order
+	names := make([]string, 0)
+	for name := range contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+
+	for _, name := range names {
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: name}
+		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+		if err != nil {
+			log.Fatalf("cannot load kubernetes config of context '%v', Err=%s", name, err)
+		}
+		configureKubernetesClient(config)
+		clients = append(clients, service.ClusterClient{
+			Name:                name,
+			ScalingGroupPattern: contexts[name],
+			KubernetesClient:    kubernetes.NewForConfigOrDie(config),
+		})
+	}
+	return clients
+}
+
+// parseEndpointURLs parses endpoint overrides in the form service=url, where service is the endpoint id of the API
+// e.g. sqs or elasticloadbalancing, or url which overrides the endpoint of every service
+func parseEndpointURLs(values []string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, value := range values {
+		service, endpoint := "", strings.TrimSpace(value)
+		if parts := strings.SplitN(endpoint, "=", 2); len(parts) == 2 {
+			service, endpoint = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+			if service == "" {
+				return nil, fmt.Errorf("missing service name in '%v'", value)
+			}
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint url '%v'", endpoint)
+		}
+		if _, ok := parsed[service]; ok {
+			return nil, fmt.Errorf("endpoint of '%v' is overridden more than once", service)
+		}
+		parsed[service] = endpoint
+	}
+	return parsed, nil
+}
+
+func validateEndpointURLs() {
+	if _, err := parseEndpointURLs(awsEndpointURLs); err != nil {
+		log.Fatalf("--aws-endpoint-url must be in the form service=url or url: %v", err)
+	}
+}
+
+// newEndpointResolver resolves the overridden endpoints of services, and the endpoints of the partition of the region
+// otherwise. Overridden endpoints keep the signing region and name of the service so that requests to VPC interface
+// endpoints are still signed for the service
+func newEndpointResolver(overrides map[string]string) endpoints.Resolver {
+	return endpoints.ResolverFunc(func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		endpoint, ok := overrides[service]
+		if !ok {
+			endpoint, ok = overrides[""]
+		}
+		resolved, err := endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+		if !ok {
+			return resolved, err
+		}
+		if err != nil {
+			// regions unknown to the SDK, e.g. of LocalStack, are signed for the region itself
+			resolved = endpoints.ResolvedEndpoint{SigningRegion: region, SigningMethod: "v4"}
+		}
+		resolved.URL = endpoint
+		return resolved, nil
+	})
+}
+
+// newAWSConfig returns the base config of AWS sessions in a region
+func newAWSConfig(region string) *aws.Config {
 	config := aws.NewConfig().WithRegion(region)
 	config = config.WithCredentialsChainVerboseErrors(true)
+	if awsUseFIPSEndpoint {
+		config = config.WithUseFIPSEndpoint(true)
+	}
+	if awsUseDualStackEndpoint {
+		config.UseDualStackEndpoint = endpoints.DualStackEndpointStateEnabled
+	}
+	if overrides, _ := parseEndpointURLs(awsEndpointURLs); len(overrides) > 0 {
+		config = config.WithEndpointResolver(newEndpointResolver(overrides))
+	}
+	return config
+}
+
+func newIAMClient(region string) iamiface.IAMAPI {
+	config := newAWSConfig(region)
 	sess, err := session.NewSession(config)
 	if err != nil {
 		log.Fatalf("failed to create iam client, %v", err)
@@ -58,8 +269,7 @@ func newIAMClient(region string) iamiface.IAMAPI {
 }
 
 func newAWSSession(region string) (*session.Session, error) {
-	config := aws.NewConfig().WithRegion(region)
-	config = config.WithCredentialsChainVerboseErrors(true)
+	config := newAWSConfig(region)
 
 	if refreshExpiredCredentials {
 		filename := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
@@ -86,24 +296,41 @@ func newAWSSession(region string) (*session.Session, error) {
 	if err != nil {
 		return nil, err
 	}
+	service.AddAPIMetrics(sess)
 
 	return sess, nil
 }
 
-func newELBv2Client(region string, cacheCfg *cache.Config) elbv2iface.ELBV2API {
+// newResponseCache returns the cache of AWS API responses configured by --cache-backend, the redis password is read
+// from $CACHE_REDIS_PASSWORD
+func newResponseCache() service.ResponseCache {
+	if cacheBackend == service.CacheBackendRedis {
+		return service.NewRedisCache(service.RedisCacheOptions{
+			Address:   cacheRedisAddress,
+			Password:  os.Getenv("CACHE_REDIS_PASSWORD"),
+			DB:        cacheRedisDB,
+			KeyPrefix: cacheRedisKeyPrefix,
+		})
+	}
+	return service.NewMemoryCache(cache.NewConfig(CacheDefaultTTL, 1*time.Hour, CacheMaxItems, CacheItemsToPrune))
+}
+
+func newELBv2Client(region string, cacheCfg service.ResponseCache) elbv2iface.ELBV2API {
 	sess, err := newAWSSession(region)
 	if err != nil {
 		log.Fatalf("failed to create AWS session, %s", err)
 	}
 
-	cache.AddCaching(sess, cacheCfg)
-	cacheCfg.SetCacheTTL("elasticloadbalancing", "DescribeTargetHealth", DescribeTargetHealthTTL)
-	cacheCfg.SetCacheTTL("elasticloadbalancing", "DescribeTargetGroups", DescribeTargetGroupsTTL)
+	cacheCfg.AddCaching(sess)
+	addRateLimiting(sess)
+	cacheCfg.SetCacheTTL("elasticloadbalancing", "DescribeTargetHealth", time.Duration(targetHealthCacheTTLSeconds)*time.Second)
+	cacheCfg.SetCacheTTL("elasticloadbalancing", "DescribeTargetGroups", time.Duration(loadBalancerCacheTTLSeconds)*time.Second)
+	cacheCfg.SetCacheTTL("elasticloadbalancing", "DescribeTargetGroupAttributes", time.Duration(loadBalancerCacheTTLSeconds)*time.Second)
 	cacheCfg.SetCacheMutating("elasticloadbalancing", "DeregisterTargets", false)
 	sess.Handlers.Complete.PushFront(func(r *request.Request) {
 		ctx := r.HTTPRequest.Context()
 		log.Debugf("cache hit => %v, service => %s.%s",
-			cache.IsCacheHit(ctx),
+			service.IsCacheHit(ctx),
 			r.ClientInfo.ServiceName,
 			r.Operation.Name,
 		)
@@ -112,20 +339,22 @@ func newELBv2Client(region string, cacheCfg *cache.Config) elbv2iface.ELBV2API {
 	return elbv2.New(sess)
 }
 
-func newELBClient(region string, cacheCfg *cache.Config) elbiface.ELBAPI {
+func newELBClient(region string, cacheCfg service.ResponseCache) elbiface.ELBAPI {
 	sess, err := newAWSSession(region)
 	if err != nil {
 		log.Fatalf("failed to create AWS session, %s", err)
 	}
 
-	cache.AddCaching(sess, cacheCfg)
-	cacheCfg.SetCacheTTL("elasticloadbalancing", "DescribeInstanceHealth", DescribeInstanceHealthTTL)
-	cacheCfg.SetCacheTTL("elasticloadbalancing", "DescribeLoadBalancers", DescribeLoadBalancersTTL)
+	cacheCfg.AddCaching(sess)
+	addRateLimiting(sess)
+	cacheCfg.SetCacheTTL("elasticloadbalancing", "DescribeInstanceHealth", time.Duration(targetHealthCacheTTLSeconds)*time.Second)
+	cacheCfg.SetCacheTTL("elasticloadbalancing", "DescribeLoadBalancers", time.Duration(loadBalancerCacheTTLSeconds)*time.Second)
+	cacheCfg.SetCacheTTL("elasticloadbalancing", "DescribeLoadBalancerAttributes", time.Duration(loadBalancerCacheTTLSeconds)*time.Second)
 	cacheCfg.SetCacheMutating("elasticloadbalancing", "DeregisterInstancesFromLoadBalancer", false)
 	sess.Handlers.Complete.PushFront(func(r *request.Request) {
 		ctx := r.HTTPRequest.Context()
 		log.Debugf("cache hit => %v, service => %s.%s",
-			cache.IsCacheHit(ctx),
+			service.IsCacheHit(ctx),
 			r.ClientInfo.ServiceName,
 			r.Operation.Name,
 		)
@@ -134,6 +363,15 @@ func newELBClient(region string, cacheCfg *cache.Config) elbiface.ELBAPI {
 	return elb.New(sess)
 }
 
+func newEC2Client(region string) ec2iface.EC2API {
+	sess, err := newAWSSession(region)
+	if err != nil {
+		log.Fatalf("failed to create AWS session, %s", err)
+	}
+
+	return ec2.New(sess)
+}
+
 func newSQSClient(region string) sqsiface.SQSAPI {
 	sess, err := newAWSSession(region)
 	if err != nil {
@@ -143,11 +381,91 @@ func newSQSClient(region string) sqsiface.SQSAPI {
 	return sqs.New(sess)
 }
 
+// newQueueSQSClient returns the client of the consumed queue, in the region of the queue when it is given by url or arn,
+// and with the credentials of --queue-role-arn when set, which lets a central account grant access to its queue
+func newQueueSQSClient() sqsiface.SQSAPI {
+	queueRegion := region
+	if queue.Region != "" {
+		queueRegion = queue.Region
+	}
+
+	sess, err := newAWSSession(queueRegion)
+	if err != nil {
+		log.Fatalf("failed to create AWS session, %s", err)
+	}
+	if queueRoleARN == "" {
+		return sqs.New(sess)
+	}
+
+	creds := stscreds.NewCredentials(sess, queueRoleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = "lifecycle-manager"
+		if queueRoleExternalID != "" {
+			p.ExternalID = aws.String(queueRoleExternalID)
+		}
+	})
+	return sqs.New(sess, &aws.Config{Credentials: creds})
+}
+
 func newASGClient(region string) autoscalingiface.AutoScalingAPI {
 	sess, err := newAWSSession(region)
 	if err != nil {
 		log.Fatalf("failed to create AWS session, %s", err)
 	}
 
+	addRateLimiting(sess)
 	return autoscaling.New(sess)
 }
+
+func newRoute53Client(region string) route53iface.Route53API {
+	sess, err := newAWSSession(region)
+	if err != nil {
+		log.Fatalf("failed to create AWS session, %s", err)
+	}
+
+	return route53.New(sess)
+}
+
+func newSNSClient(region string) snsiface.SNSAPI {
+	sess, err := newAWSSession(region)
+	if err != nil {
+		log.Fatalf("failed to create AWS session, %s", err)
+	}
+
+	return sns.New(sess)
+}
+
+func newCloudWatchClient(region string) cloudwatchiface.CloudWatchAPI {
+	sess, err := newAWSSession(region)
+	if err != nil {
+		log.Fatalf("failed to create AWS session, %s", err)
+	}
+
+	return cloudwatch.New(sess)
+}
+
+func newDynamoDBClient(region string) dynamodbiface.DynamoDBAPI {
+	sess, err := newAWSSession(region)
+	if err != nil {
+		log.Fatalf("failed to create AWS session, %s", err)
+	}
+
+	return dynamodb.New(sess)
+}
+
+func newServiceDiscoveryClient(region string) servicediscoveryiface.ServiceDiscoveryAPI {
+	sess, err := newAWSSession(region)
+	if err != nil {
+		log.Fatalf("failed to create AWS session, %s", err)
+	}
+
+	return servicediscovery.New(sess)
+}
+
+func newGlobalAcceleratorClient() globalacceleratoriface.GlobalAcceleratorAPI {
+	sess, err := newAWSSession(GlobalAcceleratorRegion)
+	if err != nil {
+		log.Fatalf("failed to create AWS session, %s", err)
+	}
+
+	return globalaccelerator.New(sess)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/keikoproj/lifecycle-manager/pkg/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorRegion         string
+	doctorQueueName      string
+	doctorLocalMode      string
+	doctorScalingGroups  []string
+	doctorSkipKubernetes bool
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "checks the queue, lifecycle hooks, AWS permissions and Kubernetes RBAC lifecycle-manager needs",
+	Long: `doctor runs with the credentials lifecycle-manager would use and prints a pass/fail report of the queue,
+			the lifecycle hooks notifying it, the AWS permissions and the Kubernetes RBAC needed to process events`,
+	Run: func(cmd *cobra.Command, args []string) {
+		validateDoctor()
+		log.SetLevel(logLevel)
+
+		cacheCfg := newResponseCache()
+		auth := service.Authenticator{
+			ScalingGroupClient: newASGClient(doctorRegion),
+			SQSClient:          newSQSClient(doctorRegion),
+			ELBv2Client:        newELBv2Client(doctorRegion, cacheCfg),
+			ELBClient:          newELBClient(doctorRegion, cacheCfg),
+			EC2Client:          newEC2Client(doctorRegion),
+		}
+		if !doctorSkipKubernetes {
+			auth.KubernetesClient = newKubernetesClient(doctorLocalMode)
+		}
+
+		results := service.RunDiagnostics(auth, service.DiagnosticOptions{
+			QueueName:     doctorQueueName,
+			ScalingGroups: doctorScalingGroups,
+			Kubernetes:    !doctorSkipKubernetes,
+		})
+
+		if failed := printDiagnostics(results); failed > 0 {
+			fmt.Printf("\n%v of %v checks failed\n", failed, len(results))
+			os.Exit(1)
+		}
+		fmt.Printf("\nall %v checks passed\n", len(results))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().StringVar(&doctorRegion, "region", "", "AWS region to operate in")
+	doctorCmd.Flags().StringSliceVar(&awsEndpointURLs, "aws-endpoint-url", []string{}, "comma separated list of AWS API endpoints to use instead of the default endpoints of the region, in the form service=url or url for every service")
+	doctorCmd.Flags().BoolVar(&awsUseFIPSEndpoint, "use-fips-endpoint", false, "call the FIPS 140-2 validated endpoints of AWS APIs, also enabled by AWS_USE_FIPS_ENDPOINT=true")
+	doctorCmd.Flags().BoolVar(&awsUseDualStackEndpoint, "use-dualstack-endpoint", false, "call the dual-stack IPv4/IPv6 endpoints of AWS APIs, also enabled by AWS_USE_DUALSTACK_ENDPOINT=true")
+	doctorCmd.Flags().StringVar(&doctorQueueName, "queue-name", "", "the name of the SQS queue lifecycle-manager consumes")
+	doctorCmd.Flags().StringVar(&doctorLocalMode, "local-mode", "", "absolute path to kubeconfig, the in-cluster config is used by default")
+	doctorCmd.Flags().StringSliceVar(&doctorScalingGroups, "target-scaling-groups", []string{}, "comma separated list of auto scaling group names whose hooks are checked, any scaling group with a hook notifying the queue passes by default")
+	doctorCmd.Flags().BoolVar(&doctorSkipKubernetes, "skip-kubernetes", false, "skip checking the Kubernetes RBAC permissions")
+}
+
+func validateDoctor() {
+	if doctorRegion == "" {
+		log.Fatalf("--region was not provided")
+	}
+
+	if doctorQueueName == "" {
+		log.Fatalf("--queue-name was not provided")
+	}
+
+	if doctorLocalMode != "" {
+		if _, err := os.Stat(doctorLocalMode); os.IsNotExist(err) {
+			log.Fatalf("provided kubeconfig path does not exist")
+		}
+	}
+
+	validateEndpointURLs()
+}
+
+// printDiagnostics prints the results as a table and returns the number of failed checks
+func printDiagnostics(results []service.DiagnosticResult) int {
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT\tDETAIL")
+	for _, r := range results {
+		result := "PASS"
+		if !r.Passed {
+			result = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\n", r.Check, result, r.Detail)
+	}
+	w.Flush()
+	return failed
+}
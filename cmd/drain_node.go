@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/keikoproj/lifecycle-manager/pkg/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	drainNodeName          string
+	drainInstanceID        string
+	drainTerminate         bool
+	drainDecrementCapacity bool
+)
+
+// drainNodeCmd represents the drain-node command
+var drainNodeCmd = &cobra.Command{
+	Use:   "drain-node",
+	Short: "drains and deregisters a node without a lifecycle hook, optionally terminating its instance",
+	Long: `drain-node runs the drain and load balancer deregistration lifecycle-manager performs for a termination hook
+			against a node selected by name or instance id, and can then terminate the instance through its scaling group`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// argument validation
+		validateDrainNode()
+		log.SetLevel(logLevel)
+		cacheCfg := newResponseCache()
+		apiRateLimiter = newAPIRateLimiter(apiRateLimit, apiRateBurst)
+
+		// the drain does not join the shard ring, it would otherwise not own any instance
+		context := newManagerContext(cacheCfg)
+		context.WithSharding = false
+
+		s := service.New(newServeAuthenticator(cacheCfg), context)
+
+		// interrupting the drain cancels it, the node is left cordoned
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+		go func() {
+			sig := <-signals
+			log.Infof("received %v", sig)
+			s.Stop()
+		}()
+
+		err := s.DrainInstance(service.DrainOptions{
+			NodeName:          drainNodeName,
+			InstanceID:        drainInstanceID,
+			Terminate:         drainTerminate,
+			DecrementCapacity: drainDecrementCapacity,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(drainNodeCmd)
+	addManagerFlags(drainNodeCmd.Flags())
+	drainNodeCmd.Flags().StringVar(&drainNodeName, "node-name", "", "name of the node to drain")
+	drainNodeCmd.Flags().StringVar(&drainInstanceID, "instance-id", "", "id of the instance whose node to drain")
+	drainNodeCmd.Flags().BoolVar(&drainTerminate, "terminate", false, "terminate the instance through its scaling group once drained, which runs the scaling group's termination hooks")
+	drainNodeCmd.Flags().BoolVar(&drainDecrementCapacity, "decrement-desired-capacity", false, "decrement the desired capacity of the scaling group when terminating the instance rather than replacing it")
+}
+
+func validateDrainNode() {
+	if (drainNodeName == "") == (drainInstanceID == "") {
+		log.Fatalf("exactly one of --node-name or --instance-id must be provided")
+	}
+
+	if drainDecrementCapacity && !drainTerminate {
+		log.Fatalf("--decrement-desired-capacity requires --terminate")
+	}
+
+	validateManagerFlags()
+}
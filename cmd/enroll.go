@@ -8,6 +8,7 @@ import (
 
 var (
 	overwrite            bool
+	withLaunchHook       bool
 	enrollRegion         string
 	enrollQueueName      string
 	notificationRoleName string
@@ -41,6 +42,7 @@ var enrollCmd = &cobra.Command{
 			TargetScalingGroups:  targetScalingGroups,
 			HeartbeatTimeout:     heartbeatTimeout,
 			Overwrite:            overwrite,
+			WithLaunchHook:       withLaunchHook,
 		}
 
 		e := enroll.New(auth, context)
@@ -55,6 +57,7 @@ func init() {
 	enrollCmd.Flags().StringVar(&enrollQueueName, "queue-name", "", "the name of the SQS queue to create")
 	enrollCmd.Flags().StringVar(&notificationRoleName, "notification-role-name", "", "the name of the notification IAM role to create")
 	enrollCmd.Flags().StringSliceVar(&targetScalingGroups, "target-scaling-groups", []string{}, "comma separated list of auto scaling group names")
+	enrollCmd.Flags().BoolVar(&withLaunchHook, "with-launch-hook", false, "also create a launching lifecycle hook")
 	enrollCmd.Flags().UintVar(&heartbeatTimeout, "heartbeat-timeout", 300, "lifecycle hook heartbeat timeout")
 }
 
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/keikoproj/lifecycle-manager/pkg/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyAddress string
+	historyOutput  string
+	historyTimeout time.Duration
+	historyCACert  string
+	historyCert    string
+	historyKey     string
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "print the recently completed or failed events of a running lifecycle-manager",
+	Long: `history queries the event history endpoint served on the metrics port of a running
+			lifecycle-manager and prints the last completed or failed events, most recent first`,
+	Run: func(cmd *cobra.Command, args []string) {
+		validateHistory()
+		log.SetLevel(logLevel)
+
+		records, err := getHistory(historyAddress)
+		if err != nil {
+			log.Fatalf("failed to get event history: %v", err)
+		}
+
+		if historyOutput == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(records); err != nil {
+				log.Fatalf("failed to print event history: %v", err)
+			}
+			return
+		}
+		printHistory(records)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().StringVar(&historyAddress, "address", fmt.Sprintf("http://localhost%v", service.MetricsPort), "address of the metrics server of the lifecycle-manager to query")
+	historyCmd.Flags().StringVar(&historyOutput, "output", "table", "output format (table, json)")
+	historyCmd.Flags().DurationVar(&historyTimeout, "timeout", 10*time.Second, "timeout of the request to lifecycle-manager")
+	historyCmd.Flags().StringVar(&historyCACert, "ca-cert", "", "path to a CA bundle to verify the certificate of a metrics server served over TLS")
+	historyCmd.Flags().StringVar(&historyCert, "cert", "", "path to a client certificate file, when the metrics server requires client certificates")
+	historyCmd.Flags().StringVar(&historyKey, "key", "", "path to the key file of the client certificate")
+}
+
+func validateHistory() {
+	if historyAddress == "" {
+		log.Fatalf("--address was not provided")
+	}
+
+	if historyOutput != "table" && historyOutput != "json" {
+		log.Fatalf("--output must be one of 'table' or 'json'")
+	}
+
+	if (historyCert == "") != (historyKey == "") {
+		log.Fatalf("--cert and --key must be provided together")
+	}
+}
+
+// newAdminTLSConfig returns the TLS config of clients of the metrics server, which may be served over TLS and require
+// client certificates
+func newAdminTLSConfig(caCertFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caCertFile != "" {
+		pem, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %v", caCertFile)
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func getHistory(address string) ([]service.EventRecord, error) {
+	tlsConfig, err := newAdminTLSConfig(historyCACert, historyCert, historyKey)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout:   historyTimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	resp, err := client.Get(strings.TrimSuffix(address, "/") + service.HistoryEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lifecycle-manager responded with status %v", resp.StatusCode)
+	}
+
+	records := make([]service.EventRecord, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func printHistory(records []service.EventRecord) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "END TIME\tREQUEST ID\tINSTANCE\tSCALING GROUP\tNODE\tOUTCOME\tDURATION\tDRAIN\tDEREGISTER\tERROR")
+	for _, r := range records {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%.0fs\t%.0fs\t%.0fs\t%v\n",
+			r.EndTime.Format(time.RFC3339), r.RequestID, r.InstanceID, r.ScalingGroupName, r.NodeName, r.Outcome,
+			r.DurationSeconds, r.DrainDurationSeconds, r.DeregisterDurationSeconds, r.Error)
+	}
+	w.Flush()
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/enroll"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	initOverwrite            bool
+	initWithLaunchHook       bool
+	initRegion               string
+	initQueueName            string
+	initNotificationRoleName string
+	initNotificationRoleARN  string
+	initHeartbeatTimeout     uint
+	initScalingGroups        []string
+	initPolicyFile           string
+)
+
+// initCmd represents the init command
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "provisions and validates the SQS queue and lifecycle hooks, and prints the IAM policy lifecycle-manager needs",
+	Long: `init creates the SQS queue and notification role or validates existing ones, points the lifecycle hooks
+			of the scaling groups to the queue and prints the IAM policy to attach to the role of lifecycle-manager`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// argument validation
+		validateInit()
+		log.SetLevel(logLevel)
+
+		// prepare auth clients
+		auth := enroll.EnrollmentAuthenticator{
+			ScalingGroupClient: newASGClient(initRegion),
+			SQSClient:          newSQSClient(initRegion),
+			IAMClient:          newIAMClient(initRegion),
+		}
+
+		// prepare runtime context
+		context := &enroll.EnrollmentContext{
+			Region:               initRegion,
+			QueueName:            initQueueName,
+			NotificationRoleName: initNotificationRoleName,
+			RoleARN:              initNotificationRoleARN,
+			TargetScalingGroups:  initScalingGroups,
+			HeartbeatTimeout:     initHeartbeatTimeout,
+			Overwrite:            initOverwrite,
+			WithLaunchHook:       initWithLaunchHook,
+		}
+
+		e := enroll.New(auth, context)
+		if err := e.Init(); err != nil {
+			log.Fatal(err)
+		}
+
+		policy, err := enroll.GetLifecycleManagerPolicy(context.QueueARN)
+		if err != nil {
+			log.Fatalf("failed to render IAM policy: %v", err)
+		}
+		if initPolicyFile != "" {
+			if err := os.WriteFile(initPolicyFile, []byte(policy+"\n"), 0644); err != nil {
+				log.Fatalf("failed to write IAM policy: %v", err)
+			}
+			log.Infof("IAM policy for lifecycle-manager written to %v", initPolicyFile)
+			return
+		}
+		log.Info("attach the following IAM policy to the role of lifecycle-manager:")
+		fmt.Println(policy)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().BoolVar(&initOverwrite, "overwrite", false, "re-use an existing notification role and point existing lifecycle hooks to the queue")
+	initCmd.Flags().StringVar(&initRegion, "region", "", "AWS region to operate in")
+	initCmd.Flags().StringSliceVar(&awsEndpointURLs, "aws-endpoint-url", []string{}, "comma separated list of AWS API endpoints to use instead of the default endpoints of the region, in the form service=url or url for every service")
+	initCmd.Flags().BoolVar(&awsUseFIPSEndpoint, "use-fips-endpoint", false, "call the FIPS 140-2 validated endpoints of AWS APIs, also enabled by AWS_USE_FIPS_ENDPOINT=true")
+	initCmd.Flags().BoolVar(&awsUseDualStackEndpoint, "use-dualstack-endpoint", false, "call the dual-stack IPv4/IPv6 endpoints of AWS APIs, also enabled by AWS_USE_DUALSTACK_ENDPOINT=true")
+	initCmd.Flags().StringVar(&initQueueName, "queue-name", "", "the name of the SQS queue to create or validate")
+	initCmd.Flags().StringVar(&initNotificationRoleName, "notification-role-name", "", "the name of the notification IAM role to create")
+	initCmd.Flags().StringVar(&initNotificationRoleARN, "notification-role-arn", "", "the ARN of an existing notification IAM role to use instead of creating one")
+	initCmd.Flags().StringSliceVar(&initScalingGroups, "target-scaling-groups", []string{}, "comma separated list of auto scaling group names")
+	initCmd.Flags().BoolVar(&initWithLaunchHook, "with-launch-hook", false, "also create a launching lifecycle hook")
+	initCmd.Flags().UintVar(&initHeartbeatTimeout, "heartbeat-timeout", 300, "lifecycle hook heartbeat timeout")
+	initCmd.Flags().StringVar(&initPolicyFile, "policy-file", "", "write the IAM policy of lifecycle-manager to a file instead of printing it")
+}
+
+func validateInit() {
+	if initRegion == "" {
+		log.Fatalf("--region was not provided")
+	}
+
+	if initQueueName == "" {
+		log.Fatalf("--queue-name was not provided")
+	}
+
+	if (initNotificationRoleName == "") == (initNotificationRoleARN == "") {
+		log.Fatalf("exactly one of --notification-role-name or --notification-role-arn must be provided")
+	}
+
+	if len(initScalingGroups) == 0 {
+		log.Fatalf("--target-scaling-groups was not provided")
+	}
+
+	if initHeartbeatTimeout < 30 || initHeartbeatTimeout > 7200 {
+		log.Fatalf("--heartbeat-timeout must be between 30 and 7200 seconds")
+	}
+
+	validateEndpointURLs()
+}
@@ -15,7 +15,7 @@ var rootCmd = &cobra.Command{
 	Short: "lifecycle-manager makes AWS scaling event on Kubernetes graceful using lifecycle hooks",
 	Long: `lifecycle-manager is a service that can be deployed to a Kubernetes cluster in order to make AWS autoscaling events more graceful using draining
 
-lifecycle-manager serve --kubectl-path /usr/local/bin/kubectl --queue-name test-queue --region us-west-2`,
+lifecycle-manager serve --queue-name test-queue --region us-west-2`,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -3,43 +3,153 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws/client"
-	"github.com/keikoproj/aws-sdk-go-cache/cache"
 	"github.com/keikoproj/lifecycle-manager/pkg/log"
 	"github.com/keikoproj/lifecycle-manager/pkg/service"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"golang.org/x/sync/semaphore"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 const (
-	CacheDefaultTTL           time.Duration = time.Second * 0
-	DescribeTargetHealthTTL   time.Duration = 120 * time.Second
-	DescribeInstanceHealthTTL time.Duration = 120 * time.Second
-	DescribeTargetGroupsTTL   time.Duration = 300 * time.Second
-	DescribeLoadBalancersTTL  time.Duration = 300 * time.Second
-	CacheMaxItems             int64         = 5000
-	CacheItemsToPrune         uint32        = 500
+	CacheDefaultTTL   time.Duration = time.Second * 0
+	CacheMaxItems     int64         = 5000
+	CacheItemsToPrune uint32        = 500
+	// DescribeTargetHealthTTL and DescribeLoadBalancersTTL are the default TTLs of cached target health and load
+	// balancer lookups
+	DescribeTargetHealthTTL  time.Duration = 120 * time.Second
+	DescribeLoadBalancersTTL time.Duration = 300 * time.Second
 )
 
 var (
 	localMode                  string
 	region                     string
 	queueName                  string
+	queue                      service.QueueReference
+	queueRoleARN               string
+	queueRoleExternalID        string
+	queueDiscoveryTags         []string
+	queueDiscoveryInterval     int64
+	clusterName                string
+	clusterContexts            map[string]string
 	kubectlLocalPath           string
 	nodeName                   string
 	logLevel                   string
 	deregisterTargetGroups     bool
 	deregisterTargetTypes      []string
+	deregisterFullScan         bool
+	deregisterTagFilters       []string
+	withIPTargetWait           bool
+	deregisterBeforeDrain      bool
+	deregisterDuringDrain      bool
+	membershipCacheTTLSeconds  int64
+	nodeAgeCacheFlushMinutes   int
+	membershipConcurrency      int
+	deregisterBatchWindow      int64
+	route53ZoneIDs             []string
+	route53ZoneTagFilters      []string
+	cloudMapNamespaceTags      []string
+	cloudMapServiceTags        []string
+	acceleratorTagFilters      []string
+	acceleratorDialDownSeconds int64
+	apiRateLimit               float64
+	apiRateBurst               int
 	refreshExpiredCredentials  bool
 	drainRetryIntervalSeconds  int
 	maxDrainConcurrency        int64
+	refreshDrainConcurrency    int64
+	scalingGroupDrainLimits    map[string]int64
 	drainTimeoutSeconds        int
 	drainTimeoutUnknownSeconds int
 	drainRetryAttempts         int
+	drainFailurePolicy         string
+	drainGracePeriodSeconds    int64
+	drainEscalationTimeout     int64
+	drainEscalationGrace       int64
+	drainExcludeNamespaces     []string
+	jobCompletionTimeout       int64
+	evictionOrder              string
+	withVolumeDetachWait       bool
+	withPodDeletionWait        bool
+	podDeletionTimeout         int64
+	podDeletionNSTimeouts      map[string]int64
+	withNodeCondition          bool
+	deleteNodeAfterComplete    bool
+	waitForReplacement         bool
+	replacementWaitTimeout     int64
+	deregisterFailurePolicy    string
 	pollingIntervalSeconds     int
 	maxTimeToProcessSeconds    int64
+	backoffJitter              float64
+	waiterMinDelaySeconds      int64
+	waiterMaxDelaySeconds      int64
+	waiterMaxAttempts          uint32
+	waiterDelayIntervalSeconds int64
+	lbDiscoveryTimeout         int64
+	deregisterTimeout          int64
+	waiterTimeout              int64
+	withLaunchHooks            bool
+	launchTimeoutSeconds       int64
+	launchReadinessSelector    string
+	launchReadinessCommand     string
+	nodeNotFoundGraceSeconds   int64
+	transientRetryAttempts     int64
+	validationTimeoutSeconds   int64
+	reconcileOnStart           bool
+	reconcileIntervalSeconds   int64
+	maxInFlightEvents          int64
+	workerPoolSize             int
+	pollerCount                int
+	dedupStore                 string
+	verifySNSSignature         bool
+	allowedAccountIDs          []string
+	allowedSenderIDs           []string
+	skipNodeSelector           string
+	skipNodeAction             string
+	instanceTagFilters         []string
+	maintenanceDrainLead       int64
+	maintenanceTerminate       bool
+	scaleInProtection          bool
+	scaleInProtectionInterval  int64
+	scaleDownDisabledKey       string
+	scaleDownDisabledAction    string
+	withUpgradeManager         bool
+	upgradeManagerTagFilters   []string
+	withInstanceManager        bool
+	policyFile                 string
+	policy                     *service.Policy
+	selfNodeName               string
+	selfPodName                string
+	selfPodNamespace           string
+	withSharding               bool
+	eventSinks                 []string
+	eventWebhookURL            string
+	eventSNSTopicARN           string
+	eventNamespace             string
+	nodeScopedEvents           bool
+	historySize                int
+	failureRateWindow          int
+	failureRateThreshold       float64
+	failureRateWebhookURL      string
+	auditTableName             string
+	auditRetentionDays         int64
+	withCloudWatchMetrics      bool
+	cloudWatchNamespace        string
+	cloudWatchInterval         int64
+	statsDAddress              string
+	statsDTags                 []string
+	metricsPort                int
+	metricsEndpoint            string
+	metricsTLSCertFile         string
+	metricsTLSKeyFile          string
+	metricsTLSClientCAFile     string
 
 	// DefaultRetryer is the default retry configuration for some AWS API calls
 	DefaultRetryer = client.DefaultRetryer{
@@ -60,83 +170,681 @@ var serveCmd = &cobra.Command{
 		// argument validation
 		validateServe()
 		log.SetLevel(logLevel)
-		cacheCfg := cache.NewConfig(CacheDefaultTTL, 1*time.Hour, CacheMaxItems, CacheItemsToPrune)
+		cacheCfg := newResponseCache()
+		apiRateLimiter = newAPIRateLimiter(apiRateLimit, apiRateBurst)
 
 		// prepare auth clients
-		auth := service.Authenticator{
-			ScalingGroupClient: newASGClient(region),
-			SQSClient:          newSQSClient(region),
-			ELBv2Client:        newELBv2Client(region, cacheCfg),
-			ELBClient:          newELBClient(region, cacheCfg),
-			KubernetesClient:   newKubernetesClient(localMode),
-		}
+		auth := newServeAuthenticator(cacheCfg)
 
 		// prepare runtime context
-		context := service.ManagerContext{
-			CacheConfig:                cacheCfg,
-			KubectlLocalPath:           kubectlLocalPath,
-			QueueName:                  queueName,
-			DrainTimeoutSeconds:        int64(drainTimeoutSeconds),
-			DrainTimeoutUnknownSeconds: int64(drainTimeoutUnknownSeconds),
-			PollingIntervalSeconds:     int64(pollingIntervalSeconds),
-			DrainRetryIntervalSeconds:  int64(drainRetryIntervalSeconds),
-			MaxDrainConcurrency:        semaphore.NewWeighted(maxDrainConcurrency),
-			MaxTimeToProcessSeconds:    int64(maxTimeToProcessSeconds),
-			DrainRetryAttempts:         uint(drainRetryAttempts),
-			Region:                     region,
-			WithDeregister:             deregisterTargetGroups,
-			DeregisterTargetTypes:      deregisterTargetTypes,
-		}
+		context := newManagerContext(cacheCfg)
 
 		s := service.New(auth, context)
+
+		// stop the service on SIGTERM/SIGINT, in-flight events are resumed from their node annotation on restart
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+		go func() {
+			sig := <-signals
+			log.Infof("received %v", sig)
+			s.Stop()
+		}()
+
 		s.Start()
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(serveCmd)
-	serveCmd.Flags().StringVar(&localMode, "local-mode", "", "absolute path to kubeconfig")
-	serveCmd.Flags().StringVar(&region, "region", "", "AWS region to operate in")
-	serveCmd.Flags().StringVar(&queueName, "queue-name", "", "the name of the SQS queue to consume lifecycle hooks from")
-	serveCmd.Flags().StringVar(&kubectlLocalPath, "kubectl-path", "/usr/local/bin/kubectl", "the path to kubectl binary")
-	serveCmd.Flags().StringVar(&logLevel, "log-level", "info", "the logging level (info, warning, debug)")
-	serveCmd.Flags().Int64Var(&maxDrainConcurrency, "max-drain-concurrency", 32, "maximum number of node drains to process in parallel")
-	serveCmd.Flags().Int64Var(&maxTimeToProcessSeconds, "max-time-to-process", 3600, "max time to spend processing an event")
-	serveCmd.Flags().IntVar(&drainTimeoutSeconds, "drain-timeout", 300, "hard time limit for draining healthy nodes")
-	serveCmd.Flags().IntVar(&drainTimeoutUnknownSeconds, "drain-timeout-unknown", 30, "hard time limit for draining nodes that are in unknown state")
-	serveCmd.Flags().IntVar(&drainRetryIntervalSeconds, "drain-interval", 30, "interval in seconds for which to retry draining")
-	serveCmd.Flags().IntVar(&drainRetryAttempts, "drain-retries", 3, "number of times to retry the node drain operation")
-	serveCmd.Flags().IntVar(&pollingIntervalSeconds, "polling-interval", 10, "interval in seconds for which to poll SQS")
-	serveCmd.Flags().BoolVar(&deregisterTargetGroups, "with-deregister", true, "try to deregister deleting instance from target groups")
-	serveCmd.Flags().StringSliceVar(&deregisterTargetTypes, "deregister-target-types", []string{service.TargetTypeClassicELB.String(), service.TargetTypeTargetGroup.String()},
+	addManagerFlags(serveCmd.Flags())
+}
+
+// addManagerFlags adds the flags configuring the processing of events, which are shared by serve and simulate
+func addManagerFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&localMode, "local-mode", "", "absolute path to kubeconfig")
+	flags.StringVar(&region, "region", "", "AWS region to operate in")
+	flags.StringVar(&queueName, "queue-name", "", "the name, url or arn of the SQS queue to consume lifecycle hooks from, queues of another account are given by url or arn")
+	flags.StringVar(&queueRoleARN, "queue-role-arn", "", "the ARN of an IAM role to assume for consuming the SQS queue, e.g. a role of the account owning the queue")
+	flags.StringVar(&queueRoleExternalID, "queue-role-external-id", "", "the external id required to assume --queue-role-arn")
+	flags.StringSliceVar(&queueDiscoveryTags, "queue-discovery-tag", []string{}, "consume the SQS queues carrying these tags instead of --queue-name, in the form key=value or key e.g. lifecycle-manager.keikoproj.io/cluster=my-cluster")
+	flags.Int64Var(&queueDiscoveryInterval, "queue-discovery-interval", int64(service.DefaultQueueDiscoveryInterval.Seconds()), "interval in seconds to discover queues by --queue-discovery-tag at, pollers are started and stopped as queues appear and disappear")
+	flags.StringVar(&clusterName, "cluster-name", "", "name of the cluster, when set only events of scaling groups tagged kubernetes.io/cluster/<name> or eks:cluster-name=<name> are processed and others are returned to the queue")
+	flags.StringToStringVar(&clusterContexts, "cluster-contexts", map[string]string{}, "route events of scaling groups matching a pattern to the cluster of a kubeconfig context, in the form context=pattern, events matching no pattern are processed in the default cluster")
+	flags.Float32Var(&kubeAPIQPS, "kube-api-qps", DefaultKubeAPIQPS, "maximum Kubernetes API requests per second of each cluster client, node gets, event creates and evictions above it are queued")
+	flags.IntVar(&kubeAPIBurst, "kube-api-burst", DefaultKubeAPIBurst, "maximum burst of Kubernetes API requests above --kube-api-qps")
+	flags.StringVar(&kubectlLocalPath, "kubectl-path", "/usr/local/bin/kubectl", "the path to kubectl binary")
+	flags.MarkDeprecated("kubectl-path", "nodes are labeled and annotated through the Kubernetes API")
+	flags.StringVar(&logLevel, "log-level", "info", "the logging level (info, warning, debug)")
+	flags.Int64Var(&maxDrainConcurrency, "max-drain-concurrency", 32, "maximum number of node drains to process in parallel")
+	flags.StringToInt64Var(&scalingGroupDrainLimits, "scaling-group-max-drain-concurrency", map[string]int64{}, "maximum number of nodes of a scaling group to drain in parallel, in the form name=N")
+	flags.Int64Var(&refreshDrainConcurrency, "instance-refresh-max-drain-concurrency", 0, "maximum number of nodes of a scaling group to drain in parallel during an instance refresh, 0 uses the scaling group's limit")
+	flags.Int64Var(&maxInFlightEvents, "max-in-flight-events", 0, "maximum number of events to process at once, polling pauses while the limit is reached, 0 is unlimited")
+	flags.IntVar(&workerPoolSize, "worker-pool-size", 32, "number of workers processing events, polling pauses while all workers are busy")
+	flags.IntVar(&pollerCount, "pollers", 1, "number of concurrent pollers receiving messages from the queue, they share the capacity of the workers")
+	flags.StringVar(&dedupStore, "dedup-store", service.DedupStoreMemory, "where lifecycle action tokens of events being processed are recorded to reject redelivered messages, use annotation or lease when running multiple replicas (memory, annotation, lease)")
+	flags.BoolVar(&verifySNSSignature, "verify-sns-signature", false, "verify the signature of lifecycle notifications delivered through an SNS topic without raw message delivery")
+	flags.StringSliceVar(&allowedAccountIDs, "allowed-account-ids", []string{}, "comma separated list of account ids lifecycle notifications and sns topics may belong to, messages of other accounts are rejected")
+	flags.StringSliceVar(&allowedSenderIDs, "allowed-sender-ids", []string{}, "comma separated list of principal ids allowed to send messages to the queue, messages of other senders are rejected")
+	flags.StringVar(&skipNodeSelector, "skip-node-selector", "", "label selector of nodes managed by other tooling which are not drained, in addition to nodes annotated with lifecycle-manager.keikoproj.io/skip=true")
+	flags.StringVar(&selfNodeName, "self-node-name", os.Getenv("NODE_NAME"), "name of the node running lifecycle-manager, its termination is deferred until other in-flight events complete (defaults to $NODE_NAME)")
+	flags.StringVar(&selfPodName, "self-pod-name", os.Getenv("POD_NAME"), "name of the lifecycle-manager pod, which is not evicted while terminating its own node (defaults to $POD_NAME)")
+	flags.StringVar(&selfPodNamespace, "self-pod-namespace", os.Getenv("POD_NAMESPACE"), "namespace of the lifecycle-manager pod (defaults to $POD_NAMESPACE)")
+	flags.BoolVar(&withSharding, "with-sharding", false, "run replicas active-active, each replica processes the instances it owns by consistent hashing over the replicas holding a lease in the pod namespace")
+	flags.StringVar(&policyFile, "policy-file", "", "path to a YAML or JSON policy whose rules decide to process or skip termination events and override their drain settings by scaling group, scaling group tag, node labels, namespaces on the node or time of day")
+	flags.StringSliceVar(&instanceTagFilters, "instance-tag-filter", []string{}, "only drain instances carrying these tags, in the form key=value or key, other instances are skipped and left to other termination handlers")
+	flags.Int64Var(&maintenanceDrainLead, "maintenance-drain-lead", 3600, "seconds ahead of a scheduled EC2 maintenance window to drain the nodes of affected instances, notices of later windows are returned to the queue until then")
+	flags.BoolVar(&maintenanceTerminate, "maintenance-terminate", true, "terminate instances of scaling groups stopped or retired by scheduled EC2 maintenance through their scaling group once drained, so that they are replaced ahead of the window")
+	flags.BoolVar(&scaleInProtection, "scale-in-protection", false, "protect the instances of nodes annotated lifecycle-manager.keikoproj.io/scale-in-protection=true from scale-in, and release the protection once the annotation clears")
+	flags.Int64Var(&scaleInProtectionInterval, "scale-in-protection-interval", 60, "seconds between reconciliations of the scale-in protection of instances with the annotations of their nodes")
+	flags.StringVar(&scaleDownDisabledKey, "scale-down-disabled-annotation", service.DefaultScaleDownDisabledAnnotationKey, "annotation set to true on nodes whose scale-down is disabled, such as by cluster-autoscaler")
+	flags.StringVar(&scaleDownDisabledAction, "scale-down-disabled-action", service.ScaleDownDisabledActionIgnore, "action to take on terminating nodes with scale-down disabled, drain them, drain them with a warning event, wait for scale-down to be enabled or complete their lifecycle hook with ABANDON without draining them (ignore, warn, delay, abandon)")
+	flags.BoolVar(&withUpgradeManager, "with-upgrade-manager", false, "do not drain nodes again which were already cordoned and drained by an upgrade-manager RollingUpgrade replacing their instance")
+	flags.StringSliceVar(&upgradeManagerTagFilters, "upgrade-manager-tag-filter", []string{"upgrademgr.keikoproj.io/state=in-progress"}, "tags identifying the instances replaced by an upgrade-manager RollingUpgrade, in the form key=value or key")
+	flags.BoolVar(&withInstanceManager, "with-instance-manager", false, "override the settings of scaling groups managed by an instance-manager InstanceGroup with the lifecycle-manager annotations of the InstanceGroup")
+	flags.StringVar(&skipNodeAction, "skip-node-action", service.SkipNodeActionContinue, "action to take on the lifecycle hook of skipped nodes, complete it with CONTINUE or leave it alone (continue, ignore)")
+	flags.StringSliceVar(&eventSinks, "event-sinks", []string{service.EventSinkKubernetes}, "comma separated list of sinks to publish events to (kubernetes, log, webhook, sns)")
+	flags.StringVar(&eventWebhookURL, "event-webhook-url", "", "url to post events to as JSON when the webhook event sink is enabled")
+	flags.StringVar(&eventSNSTopicARN, "event-sns-topic-arn", "", "arn of the sns topic to publish events to as JSON when the sns event sink is enabled")
+	flags.StringVar(&eventNamespace, "event-namespace", service.EventNamespace, "namespace to publish kubernetes events in")
+	flags.BoolVar(&nodeScopedEvents, "node-events", false, "attach kubernetes events to the terminating node so that they are listed by kubectl describe node")
+	flags.IntVar(&failureRateWindow, "failure-rate-window", service.DefaultFailureRateWindow, "number of most recent events the failure rate alert is computed over, 0 disables the alert")
+	flags.Float64Var(&failureRateThreshold, "failure-rate-threshold", service.DefaultFailureRateThreshold, "failure rate of the most recent events above which a FailureRateExceeded event is published, between 0 and 1")
+	flags.StringVar(&failureRateWebhookURL, "failure-rate-webhook-url", "", "url to post failure rate alerts to as JSON, in addition to the event sinks")
+	flags.IntVar(&historySize, "history-size", service.DefaultHistorySize, "number of completed or failed events to keep in the event history served on the metrics port, 0 disables the history")
+	flags.StringVar(&auditTableName, "audit-table", "", "name of a DynamoDB table to write a record of every completed or failed event to, its partition key must be the string requestId")
+	flags.Int64Var(&auditRetentionDays, "audit-retention-days", 0, "days after which audit records expire through the expiresAt TTL attribute, 0 keeps them indefinitely")
+	flags.BoolVar(&withCloudWatchMetrics, "with-cloudwatch-metrics", false, "push event, drain and deregistration metrics to CloudWatch custom metrics with a ClusterName dimension")
+	flags.StringVar(&cloudWatchNamespace, "cloudwatch-namespace", service.CloudWatchNamespace, "namespace of the CloudWatch custom metrics")
+	flags.Int64Var(&cloudWatchInterval, "cloudwatch-interval", int64(service.CloudWatchInterval.Seconds()), "interval in seconds at which metrics are pushed to CloudWatch")
+	flags.StringVar(&statsDAddress, "statsd-address", "", "host:port of a DogStatsD agent to send metrics to over UDP, e.g. the Datadog agent")
+	flags.StringSliceVar(&statsDTags, "statsd-tags", []string{}, "comma separated list of tags added to every metric sent to statsd, in the form key:value")
+	flags.IntVar(&metricsPort, "metrics-port", 8080, "port to serve metrics and the event history on")
+	flags.StringVar(&metricsEndpoint, "metrics-endpoint", service.MetricsEndpoint, "endpoint to serve prometheus metrics on")
+	flags.StringVar(&metricsTLSCertFile, "metrics-tls-cert", "", "path to a certificate file to serve metrics and the event history over TLS")
+	flags.StringVar(&metricsTLSKeyFile, "metrics-tls-key", "", "path to the key file of the metrics TLS certificate")
+	flags.StringVar(&metricsTLSClientCAFile, "metrics-tls-client-ca", "", "path to a CA bundle, clients of the metrics server must present a certificate signed by one of its CAs")
+	flags.Int64Var(&maxTimeToProcessSeconds, "max-time-to-process", 3600, "max time in seconds to spend processing an event before it is abandoned")
+	flags.IntVar(&drainTimeoutSeconds, "drain-timeout", 300, "hard time limit for draining healthy nodes")
+	flags.IntVar(&drainTimeoutUnknownSeconds, "drain-timeout-unknown", 30, "hard time limit for draining nodes that are in unknown state")
+	flags.IntVar(&drainRetryIntervalSeconds, "drain-interval", 30, "interval in seconds for which to retry draining")
+	flags.IntVar(&drainRetryAttempts, "drain-retries", 3, "number of times to retry the node drain operation")
+	flags.StringVar(&drainFailurePolicy, "on-drain-failure", service.FailurePolicyAbandon.String(), "action to take when a node fails to drain, abandon or continue the termination (abandon, continue)")
+	flags.Int64Var(&drainGracePeriodSeconds, "drain-grace-period", -1, "termination grace period in seconds given to pods evicted by a drain, -1 uses each pod's own grace period")
+	flags.StringSliceVar(&drainExcludeNamespaces, "drain-exclude-namespaces", []string{}, "comma separated list of namespaces whose pods are never evicted, draining a node running them fails instead")
+	flags.Int64Var(&jobCompletionTimeout, "job-completion-timeout", 0, "seconds to wait for the pods of Jobs and CronJobs running on a cordoned node to complete before draining it, 0 evicts them right away")
+	flags.Int64Var(&drainEscalationTimeout, "drain-escalation-timeout", 0, "seconds after which a drain deletes the pods it could not evict, bypassing their pod disruption budgets, until the drain timeout, 0 only evicts pods")
+	flags.Int64Var(&drainEscalationGrace, "drain-escalation-grace-period", 30, "termination grace period in seconds given to pods deleted by an escalated drain, -1 uses each pod's own grace period")
+	flags.StringVar(&evictionOrder, "eviction-order", service.EvictionOrderNone, "order in which pods are evicted from a draining node, priority evicts stateless and lower priority pods first and waits for them to terminate (none, priority)")
+	flags.BoolVar(&withNodeCondition, "with-node-condition", false, "report the phase of a node's termination as its LifecycleTerminating condition")
+	flags.BoolVar(&withVolumeDetachWait, "with-volume-detach-wait", false, "wait for EBS CSI volumes to detach from a drained node before completing the lifecycle hook")
+	flags.BoolVar(&withPodDeletionWait, "with-pod-deletion-wait", false, "wait for the pods evicted from a drained node to be deleted before deregistering it, as some CSI and CNI teardown only happens at pod deletion")
+	flags.Int64Var(&podDeletionTimeout, "pod-deletion-timeout", 120, "seconds to wait for the evicted pods of a namespace to be deleted")
+	flags.StringToInt64Var(&podDeletionNSTimeouts, "pod-deletion-namespace-timeout", map[string]int64{}, "seconds to wait for the evicted pods of a namespace to be deleted, in the form namespace=N, overriding --pod-deletion-timeout")
+	flags.BoolVar(&waitForReplacement, "wait-for-replacement", false, "wait until the scaling group of a terminating instance has as many InService instances with a Ready node as its desired capacity before completing the lifecycle hook, so that rolling terminations such as instance refreshes do not dip below capacity")
+	flags.Int64Var(&replacementWaitTimeout, "replacement-wait-timeout", 600, "maximum time in seconds to wait for replacement capacity before continuing the termination")
+	flags.BoolVar(&deleteNodeAfterComplete, "delete-node-after-complete", false, "delete the node once the lifecycle hook is completed and its instance is terminating rather than before completing the hook, so that a kubelet still running cannot register it again")
+	flags.IntVar(&pollingIntervalSeconds, "polling-interval", 10, "interval in seconds for which to poll SQS")
+	flags.BoolVar(&deregisterTargetGroups, "with-deregister", true, "try to deregister deleting instance from target groups")
+	flags.StringSliceVar(&deregisterTargetTypes, "deregister-target-types", []string{service.TargetTypeClassicELB.String(), service.TargetTypeTargetGroup.String()},
 		fmt.Sprintf("comma separated list of target types to deregister instance from (%s, %s)", service.TargetTypeClassicELB.String(), service.TargetTypeTargetGroup.String()))
-	serveCmd.Flags().BoolVar(&refreshExpiredCredentials, "refresh-expired-credentials", false, "refreshes expired credentials (requires shared credentials file)")
+	flags.StringSliceVar(&deregisterTagFilters, "deregister-tag-filter", []string{}, "only consider target groups and classic-elbs carrying these tags, in the form key=value or key")
+	flags.BoolVar(&withIPTargetWait, "with-ip-target-wait", false, "wait for the pods evicted from a terminating node to be deregistered from ip target type target groups")
+	flags.BoolVar(&deregisterBeforeDrain, "deregister-before-drain", false, "deregister a terminating instance from load balancers and wait for its targets to drain before evicting the pods of its node")
+	flags.BoolVar(&deregisterDuringDrain, "deregister-during-drain", false, "deregister a terminating instance from load balancers while the pods of its node are evicted, and wait for both before completing the lifecycle hook")
+	flags.StringVar(&deregisterFailurePolicy, "on-deregister-failure", service.FailurePolicyAbandon.String(), "action to take when an instance fails to deregister from load balancers, abandon or continue the termination (abandon, continue)")
+	flags.Int64Var(&membershipCacheTTLSeconds, "membership-cache-ttl", 60, "time in seconds to share a target group/classic-elb membership snapshot between events")
+	flags.Int64Var(&targetHealthCacheTTLSeconds, "target-health-cache-ttl", int64(DescribeTargetHealthTTL.Seconds()), "time in seconds to cache target group target health and classic-elb instance health for, 0 disables caching")
+	flags.Int64Var(&loadBalancerCacheTTLSeconds, "load-balancer-cache-ttl", int64(DescribeLoadBalancersTTL.Seconds()), "time in seconds to cache target groups, classic-elbs and their attributes for, 0 disables caching")
+	flags.StringVar(&cacheBackend, "cache-backend", service.CacheBackendMemory, "where to cache AWS API responses, in memory or in redis where replicas share them (memory, redis)")
+	flags.StringVar(&cacheRedisAddress, "cache-redis-address", "", "host:port of the redis server caching AWS API responses when --cache-backend=redis, its password is read from $CACHE_REDIS_PASSWORD")
+	flags.IntVar(&cacheRedisDB, "cache-redis-db", 0, "redis database caching AWS API responses")
+	flags.StringVar(&cacheRedisKeyPrefix, "cache-redis-key-prefix", cacheRedisKeyPrefix, "prefix of the redis keys caching AWS API responses, replicas sharing cached responses must use the same prefix")
+	flags.IntVar(&nodeAgeCacheFlushMinutes, "node-age-cache-flush", service.NodeAgeCacheTTL, "age in minutes under which a terminating node flushes the target health caches, as its targets were likely cached before it registered")
+	flags.Int64Var(&deregisterBatchWindow, "deregister-batch-window", 10, "time in seconds to collect the targets of events terminating together before deregistering them from each load balancer in a single call, 0 deregisters right away")
+	flags.IntVar(&membershipConcurrency, "membership-check-concurrency", 10, "maximum number of target groups/classic-elbs to check for membership in parallel per event")
+	flags.StringSliceVar(&route53ZoneIDs, "route53-zone-ids", []string{}, "comma separated list of route53 hosted zone ids to remove A/SRV records of terminating nodes from")
+	flags.StringSliceVar(&route53ZoneTagFilters, "route53-zone-tag", []string{}, "remove A/SRV records of terminating nodes from route53 hosted zones carrying these tags, in the form key=value or key")
+	flags.StringSliceVar(&cloudMapNamespaceTags, "cloudmap-namespace-tag", []string{}, "deregister terminating instances from cloud map services in namespaces carrying these tags, in the form key=value or key")
+	flags.StringSliceVar(&cloudMapServiceTags, "cloudmap-service-tag", []string{}, "deregister terminating instances from cloud map services carrying these tags, in the form key=value or key")
+	flags.StringSliceVar(&acceleratorTagFilters, "global-accelerator-tag", []string{}, "remove terminating instances from endpoint groups of global accelerators carrying these tags, in the form key=value or key")
+	flags.Int64Var(&acceleratorDialDownSeconds, "global-accelerator-dial-down", 30, "time in seconds to wait after dialing an instance endpoint down to zero weight before removing it")
+	flags.Float64Var(&apiRateLimit, "aws-api-rate", 10, "maximum ELB/ELBv2/autoscaling API requests per second shared by all events, 0 disables rate limiting")
+	flags.IntVar(&apiRateBurst, "aws-api-burst", 20, "maximum burst of ELB/ELBv2/autoscaling API requests above the rate limit")
+	flags.BoolVar(&deregisterFullScan, "deregister-full-scan", false, "scan all target groups and classic-elbs in the account when none are attached to the scaling group")
+	flags.Float64Var(&backoffJitter, "backoff-jitter", 0, "fraction of the delays of poller backoff and deregistration waiters which is randomized, between 0 and 1")
+	flags.Int64Var(&waiterMinDelaySeconds, "waiter-min-delay", int64(service.WaiterMinDelay.Seconds()), "minimum delay in seconds between deregistration waiter attempts")
+	flags.Int64Var(&waiterMaxDelaySeconds, "waiter-max-delay", int64(service.WaiterMaxDelay.Seconds()), "maximum delay in seconds between deregistration waiter attempts")
+	flags.Uint32Var(&waiterMaxAttempts, "waiter-max-attempts", service.WaiterMaxAttempts, "maximum number of deregistration waiter attempts, waiters make only the attempts covering the connection draining timeout of their classic-elb or the deregistration delay of their target group plus a minute")
+	flags.Int64Var(&waiterDelayIntervalSeconds, "waiter-delay-interval", int64(service.WaiterDelayInterval.Seconds()), "interval in seconds at which pending deregistration waiters are reported")
+	flags.Int64Var(&lbDiscoveryTimeout, "lb-discovery-timeout", 0, "maximum time in seconds to discover the target groups and classic-elbs of a terminating instance, 0 disables the timeout")
+	flags.Int64Var(&deregisterTimeout, "deregister-timeout", 0, "maximum time in seconds to deregister a terminating instance from its target groups and classic-elbs, 0 disables the timeout")
+	flags.Int64Var(&waiterTimeout, "waiter-timeout", 0, "maximum time in seconds to wait for a terminating instance to drain from its target groups and classic-elbs, 0 disables the timeout")
+	flags.BoolVar(&withLaunchHooks, "with-launch-hooks", false, "process launching lifecycle hooks by waiting for the instance to become a ready node")
+	flags.Int64Var(&launchTimeoutSeconds, "launch-timeout", 600, "hard time limit in seconds for a launching instance to become a ready node")
+	flags.StringVar(&launchReadinessSelector, "launch-readiness-selector", "", "label selector a launching node must match to be considered ready")
+	flags.StringVar(&launchReadinessCommand, "launch-readiness-command", "", "path to a command which must succeed for a launching node to be considered ready, invoked with the node name")
+	flags.Int64Var(&transientRetryAttempts, "transient-retry-attempts", 3, "number of times a termination event failing with a transient error, such as throttling or a timeout, is returned to the queue instead of abandoning its instance, 0 abandons it immediately")
+	flags.Int64Var(&validationTimeoutSeconds, "validation-timeout", 60, "maximum time in seconds to validate an event before returning its message to the queue, the message is kept invisible while validated, 0 disables the timeout")
+	flags.Int64Var(&nodeNotFoundGraceSeconds, "node-not-found-grace", 0, "time in seconds to keep retrying termination events whose instance is not registered as a node yet, 0 rejects them immediately")
+	flags.BoolVar(&reconcileOnStart, "reconcile-on-start", true, "on start, process instances waiting on a termination hook of the queue whose message was lost")
+	flags.Int64Var(&reconcileIntervalSeconds, "reconcile-interval", 0, "interval in seconds at which orphaned events are re-adopted and stale in-progress annotations are cleared, 0 disables the reconciler")
+	flags.StringSliceVar(&awsEndpointURLs, "aws-endpoint-url", []string{}, "comma separated list of AWS API endpoints to use instead of the default endpoints of the region, in the form service=url e.g. sqs=https://vpce-123.sqs.us-east-1.vpce.amazonaws.com, or url for every service e.g. http://localhost:4566 for LocalStack")
+	flags.BoolVar(&awsUseFIPSEndpoint, "use-fips-endpoint", false, "call the FIPS 140-2 validated endpoints of AWS APIs, also enabled by AWS_USE_FIPS_ENDPOINT=true")
+	flags.BoolVar(&awsUseDualStackEndpoint, "use-dualstack-endpoint", false, "call the dual-stack IPv4/IPv6 endpoints of AWS APIs, also enabled by AWS_USE_DUALSTACK_ENDPOINT=true")
+	flags.BoolVar(&refreshExpiredCredentials, "refresh-expired-credentials", false, "refreshes expired credentials (requires shared credentials file)")
 }
 
 func validateServe() {
+	if queueName == "" && len(queueDiscoveryTags) == 0 {
+		log.Fatalf("must provide valid SQS queue name")
+	}
+	if queueName != "" && len(queueDiscoveryTags) > 0 {
+		log.Fatalf("--queue-name and --queue-discovery-tag are mutually exclusive")
+	}
+	if deregisterBeforeDrain && deregisterDuringDrain {
+		log.Fatalf("--deregister-before-drain and --deregister-during-drain are mutually exclusive")
+	}
+
+	validateManagerFlags()
+}
+
+// validateManagerFlags validates the flags added by addManagerFlags
+func validateManagerFlags() {
 	if localMode != "" {
 		if _, err := os.Stat(localMode); os.IsNotExist(err) {
 			log.Fatalf("provided kubeconfig path does not exist")
 		}
 	}
 
-	if kubectlLocalPath != "" {
-		if _, err := os.Stat(kubectlLocalPath); os.IsNotExist(err) {
-			log.Fatalf("provided kubectl path does not exist")
+	if region == "" {
+		log.Fatalf("must provide valid AWS region name")
+	}
+
+	if maxDrainConcurrency < 1 {
+		log.Fatalf("--max-drain-concurrency must be set to a value higher than 0")
+	}
+
+	for name, limit := range scalingGroupDrainLimits {
+		if limit < 1 {
+			log.Fatalf("--scaling-group-max-drain-concurrency of '%v' must be set to a value higher than 0", name)
 		}
-	} else {
-		log.Fatalf("must provide kubectl path")
 	}
 
-	if region == "" {
-		log.Fatalf("must provide valid AWS region name")
+	if podDeletionTimeout < 1 {
+		log.Fatalf("--pod-deletion-timeout must be set to a value higher than 0")
 	}
 
-	if queueName == "" {
-		log.Fatalf("must provide valid SQS queue name")
+	for namespace, timeout := range podDeletionNSTimeouts {
+		if timeout < 0 {
+			log.Fatalf("--pod-deletion-namespace-timeout of '%v' must be set to a value of 0 or higher", namespace)
+		}
 	}
 
-	if maxDrainConcurrency < 1 {
-		log.Fatalf("--max-drain-concurrency must be set to a value higher than 0")
+	if refreshDrainConcurrency < 0 {
+		log.Fatalf("--instance-refresh-max-drain-concurrency must be set to a value of 0 or higher")
+	}
+
+	if backoffJitter < 0 || backoffJitter > 1 {
+		log.Fatalf("--backoff-jitter must be set to a value between 0 and 1")
+	}
+	if waiterMinDelaySeconds < 1 || waiterMaxDelaySeconds < waiterMinDelaySeconds {
+		log.Fatalf("--waiter-max-delay must be greater or equal to --waiter-min-delay, which must be higher than 0")
+	}
+
+	if withLaunchHooks && launchTimeoutSeconds < 1 {
+		log.Fatalf("--launch-timeout must be set to a value higher than 0")
+	}
+
+	if launchReadinessSelector != "" {
+		if _, err := labels.Parse(launchReadinessSelector); err != nil {
+			log.Fatalf("--launch-readiness-selector is not a valid label selector: %v", err)
+		}
+	}
+
+	if launchReadinessCommand != "" {
+		if _, err := os.Stat(launchReadinessCommand); os.IsNotExist(err) {
+			log.Fatalf("provided launch readiness command path does not exist")
+		}
+	}
+
+	for _, filter := range deregisterTagFilters {
+		if strings.TrimSpace(strings.SplitN(filter, "=", 2)[0]) == "" {
+			log.Fatalf("--deregister-tag-filter '%v' must be in the form key=value or key", filter)
+		}
+	}
+
+	if maintenanceDrainLead < 1 {
+		log.Fatalf("--maintenance-drain-lead must be set to a value higher than 0")
+	}
+
+	if scaleInProtectionInterval < 1 {
+		log.Fatalf("--scale-in-protection-interval must be set to a value higher than 0")
+	}
+
+	for _, filter := range instanceTagFilters {
+		if strings.TrimSpace(strings.SplitN(filter, "=", 2)[0]) == "" {
+			log.Fatalf("--instance-tag-filter '%v' must be in the form key=value or key", filter)
+		}
+	}
+
+	for _, filter := range upgradeManagerTagFilters {
+		if strings.TrimSpace(strings.SplitN(filter, "=", 2)[0]) == "" {
+			log.Fatalf("--upgrade-manager-tag-filter '%v' must be in the form key=value or key", filter)
+		}
+	}
+
+	for _, filter := range route53ZoneTagFilters {
+		if strings.TrimSpace(strings.SplitN(filter, "=", 2)[0]) == "" {
+			log.Fatalf("--route53-zone-tag '%v' must be in the form key=value or key", filter)
+		}
+	}
+
+	for _, filter := range append(append([]string{}, cloudMapNamespaceTags...), cloudMapServiceTags...) {
+		if strings.TrimSpace(strings.SplitN(filter, "=", 2)[0]) == "" {
+			log.Fatalf("--cloudmap-namespace-tag and --cloudmap-service-tag '%v' must be in the form key=value or key", filter)
+		}
+	}
+
+	for _, filter := range acceleratorTagFilters {
+		if strings.TrimSpace(strings.SplitN(filter, "=", 2)[0]) == "" {
+			log.Fatalf("--global-accelerator-tag '%v' must be in the form key=value or key", filter)
+		}
+	}
+
+	if acceleratorDialDownSeconds < 0 {
+		log.Fatalf("--global-accelerator-dial-down must be set to a value of 0 or higher")
+	}
+
+	if membershipCacheTTLSeconds < 0 {
+		log.Fatalf("--membership-cache-ttl must be set to a value of 0 or higher")
+	}
+
+	if targetHealthCacheTTLSeconds < 0 || loadBalancerCacheTTLSeconds < 0 {
+		log.Fatalf("--target-health-cache-ttl and --load-balancer-cache-ttl must be set to a value of 0 or higher")
+	}
+
+	if cacheBackend != service.CacheBackendMemory && cacheBackend != service.CacheBackendRedis {
+		log.Fatalf("--cache-backend must be one of '%v' or '%v'", service.CacheBackendMemory, service.CacheBackendRedis)
+	}
+
+	if cacheBackend == service.CacheBackendRedis && cacheRedisAddress == "" {
+		log.Fatalf("--cache-redis-address must be set when --cache-backend=%v", service.CacheBackendRedis)
+	}
+
+	if cacheRedisDB < 0 {
+		log.Fatalf("--cache-redis-db must be set to a value of 0 or higher")
+	}
+
+	if replacementWaitTimeout < 1 {
+		log.Fatalf("--replacement-wait-timeout must be set to a value higher than 0")
+	}
+
+	if nodeAgeCacheFlushMinutes < 1 {
+		log.Fatalf("--node-age-cache-flush must be set to a value higher than 0")
+	}
+
+	if deregisterBatchWindow < 0 {
+		log.Fatalf("--deregister-batch-window must be set to a value of 0 or higher")
+	}
+
+	if membershipConcurrency < 1 {
+		log.Fatalf("--membership-check-concurrency must be set to a value higher than 0")
+	}
+
+	if apiRateLimit < 0 {
+		log.Fatalf("--aws-api-rate must be set to a value of 0 or higher")
+	}
+
+	if apiRateLimit > 0 && apiRateBurst < 1 {
+		log.Fatalf("--aws-api-burst must be set to a value higher than 0")
+	}
+
+	if kubeAPIQPS <= 0 {
+		log.Fatalf("--kube-api-qps must be set to a value higher than 0")
+	}
+
+	if kubeAPIBurst < 1 {
+		log.Fatalf("--kube-api-burst must be set to a value higher than 0")
+	}
+
+	validateEndpointURLs()
+
+	if !service.IsValidFailurePolicy(drainFailurePolicy) {
+		log.Fatalf("--on-drain-failure must be one of '%v' or '%v'", service.FailurePolicyAbandon, service.FailurePolicyContinue)
+	}
+
+	if !service.IsValidFailurePolicy(deregisterFailurePolicy) {
+		log.Fatalf("--on-deregister-failure must be one of '%v' or '%v'", service.FailurePolicyAbandon, service.FailurePolicyContinue)
+	}
+
+	if maxTimeToProcessSeconds < 1 {
+		log.Fatalf("--max-time-to-process must be set to a value higher than 0")
+	}
+
+	if nodeNotFoundGraceSeconds < 0 {
+		log.Fatalf("--node-not-found-grace must be set to a value of 0 or higher")
+	}
+
+	if transientRetryAttempts < 0 {
+		log.Fatalf("--transient-retry-attempts must be set to a value of 0 or higher")
+	}
+
+	if lbDiscoveryTimeout < 0 || deregisterTimeout < 0 || waiterTimeout < 0 {
+		log.Fatalf("--lb-discovery-timeout, --deregister-timeout and --waiter-timeout must be set to a value of 0 or higher")
+	}
+	if validationTimeoutSeconds < 0 {
+		log.Fatalf("--validation-timeout must be set to a value of 0 or higher")
+	}
+
+	if workerPoolSize < 1 {
+		log.Fatalf("--worker-pool-size must be set to a value higher than 0")
+	}
+
+	if pollerCount < 1 {
+		log.Fatalf("--pollers must be set to a value higher than 0")
+	}
+
+	if skipNodeSelector != "" {
+		if _, err := labels.Parse(skipNodeSelector); err != nil {
+			log.Fatalf("--skip-node-selector is not a valid label selector: %v", err)
+		}
+	}
+
+	if !service.IsValidSkipNodeAction(skipNodeAction) {
+		log.Fatalf("--skip-node-action must be one of '%v' or '%v'", service.SkipNodeActionContinue, service.SkipNodeActionIgnore)
+	}
+
+	if !service.IsValidScaleDownDisabledAction(scaleDownDisabledAction) {
+		log.Fatalf("--scale-down-disabled-action must be one of '%v', '%v', '%v' or '%v'", service.ScaleDownDisabledActionIgnore, service.ScaleDownDisabledActionWarn, service.ScaleDownDisabledActionDelay, service.ScaleDownDisabledActionAbandon)
+	}
+
+	if scaleDownDisabledKey == "" {
+		log.Fatalf("--scale-down-disabled-annotation must be set")
+	}
+
+	if queueName != "" {
+		var err error
+		if queue, err = service.ParseQueueReference(queueName); err != nil {
+			log.Fatalf("--queue-name must be a queue name, url or arn: %v", err)
+		}
+	}
+
+	if queueRoleExternalID != "" && queueRoleARN == "" {
+		log.Fatalf("--queue-role-external-id requires --queue-role-arn")
+	}
+
+	for _, filter := range queueDiscoveryTags {
+		if strings.TrimSpace(strings.SplitN(filter, "=", 2)[0]) == "" {
+			log.Fatalf("--queue-discovery-tag '%v' must be in the form key=value or key", filter)
+		}
+	}
+
+	if queueDiscoveryInterval < 1 {
+		log.Fatalf("--queue-discovery-interval must be set to a value higher than 0")
+	}
+
+	if policyFile != "" {
+		var err error
+		if policy, err = service.LoadPolicy(policyFile); err != nil {
+			log.Fatalf("--policy-file is not a valid policy: %v", err)
+		}
+	}
+
+	for name, pattern := range clusterContexts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.Fatalf("--cluster-contexts pattern of context %v is not valid: %v", name, err)
+		}
+	}
+
+	for _, sink := range eventSinks {
+		if !service.IsValidEventSink(sink) {
+			log.Fatalf("--event-sinks must be a list of '%v', '%v', '%v' or '%v'", service.EventSinkKubernetes, service.EventSinkLog, service.EventSinkWebhook, service.EventSinkSNS)
+		}
+		if sink == service.EventSinkWebhook && eventWebhookURL == "" {
+			log.Fatalf("--event-webhook-url must be provided with the webhook event sink")
+		}
+		if sink == service.EventSinkSNS && eventSNSTopicARN == "" {
+			log.Fatalf("--event-sns-topic-arn must be provided with the sns event sink")
+		}
+	}
+
+	if !service.IsValidEvictionOrder(evictionOrder) {
+		log.Fatalf("--eviction-order must be one of '%v' or '%v'", service.EvictionOrderNone, service.EvictionOrderPriority)
+	}
+
+	if drainGracePeriodSeconds < -1 {
+		log.Fatalf("--drain-grace-period must be -1 or greater")
+	}
+
+	if jobCompletionTimeout < 0 {
+		log.Fatalf("--job-completion-timeout must be set to a value of 0 or higher")
+	}
+
+	if drainEscalationTimeout < 0 {
+		log.Fatalf("--drain-escalation-timeout must be set to a value of 0 or higher")
+	}
+
+	if drainEscalationGrace < -1 {
+		log.Fatalf("--drain-escalation-grace-period must be -1 or greater")
+	}
+
+	if !service.IsValidDedupStore(dedupStore) {
+		log.Fatalf("--dedup-store must be one of '%v', '%v' or '%v'", service.DedupStoreMemory, service.DedupStoreAnnotation, service.DedupStoreLease)
+	}
+
+	if (withSharding || dedupStore == service.DedupStoreLease) && (selfPodName == "" || selfPodNamespace == "") {
+		log.Fatalf("--with-sharding and --dedup-store lease require --self-pod-name and --self-pod-namespace")
+	}
+
+	if withSharding && dedupStore == service.DedupStoreMemory {
+		log.Fatalf("--with-sharding requires --dedup-store '%v' or '%v'", service.DedupStoreAnnotation, service.DedupStoreLease)
+	}
+
+	if maxInFlightEvents < 0 {
+		log.Fatalf("--max-in-flight-events must be set to a value of 0 or higher")
+	}
+
+	if failureRateWindow < 0 {
+		log.Fatalf("--failure-rate-window must be set to a value of 0 or higher")
+	}
+
+	if failureRateThreshold < 0 || failureRateThreshold >= 1 {
+		log.Fatalf("--failure-rate-threshold must be set to a value of 0 or higher and lower than 1")
+	}
+
+	if historySize < 0 {
+		log.Fatalf("--history-size must be set to a value of 0 or higher")
+	}
+
+	if auditRetentionDays < 0 {
+		log.Fatalf("--audit-retention-days must be set to a value of 0 or higher")
+	}
+
+	if metricsPort < 1 || metricsPort > 65535 {
+		log.Fatalf("--metrics-port must be a valid port")
+	}
+
+	if !strings.HasPrefix(metricsEndpoint, "/") || metricsEndpoint == service.HistoryEndpoint || metricsEndpoint == service.VersionEndpoint || metricsEndpoint == service.EventsEndpoint || metricsEndpoint == service.CacheFlushEndpoint {
+		log.Fatalf("--metrics-endpoint must be a path other than %v, %v, %v and %v", service.HistoryEndpoint, service.VersionEndpoint, service.EventsEndpoint, service.CacheFlushEndpoint)
+	}
+
+	if (metricsTLSCertFile == "") != (metricsTLSKeyFile == "") {
+		log.Fatalf("--metrics-tls-cert and --metrics-tls-key must be provided together")
+	}
+
+	if metricsTLSClientCAFile != "" && metricsTLSCertFile == "" {
+		log.Fatalf("--metrics-tls-client-ca requires --metrics-tls-cert and --metrics-tls-key")
+	}
+
+	if withCloudWatchMetrics {
+		if clusterName == "" {
+			log.Fatalf("--cluster-name must be provided with --with-cloudwatch-metrics")
+		}
+		if cloudWatchNamespace == "" {
+			log.Fatalf("--cloudwatch-namespace was not provided")
+		}
+		if cloudWatchInterval < 1 {
+			log.Fatalf("--cloudwatch-interval must be set to a value of 1 or higher")
+		}
+	}
+
+	if reconcileIntervalSeconds < 0 {
+		log.Fatalf("--reconcile-interval must be set to a value of 0 or higher")
+	}
+
+	if waiterMaxAttempts < 1 {
+		log.Fatalf("--waiter-max-attempts must be set to a value higher than 0")
+	}
+}
+
+func parseTagFilters(filters []string) map[string]string {
+	parsed := make(map[string]string)
+	for _, filter := range filters {
+		parts := strings.SplitN(filter, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) == 2 {
+			parsed[key] = strings.TrimSpace(parts[1])
+			continue
+		}
+		parsed[key] = ""
+	}
+	return parsed
+}
+
+// newServeAuthenticator returns the clients of the APIs lifecycle-manager calls while processing events
+func newServeAuthenticator(cacheCfg service.ResponseCache) service.Authenticator {
+	return service.Authenticator{
+		ScalingGroupClient:      newASGClient(region),
+		SQSClient:               newQueueSQSClient(),
+		ELBv2Client:             newELBv2Client(region, cacheCfg),
+		ELBClient:               newELBClient(region, cacheCfg),
+		EC2Client:               newEC2Client(region),
+		Route53Client:           newRoute53Client(region),
+		ServiceDiscoveryClient:  newServiceDiscoveryClient(region),
+		GlobalAcceleratorClient: newGlobalAcceleratorClient(),
+		SNSClient:               newSNSClient(region),
+		DynamoDBClient:          newDynamoDBClient(region),
+		CloudWatchClient:        newCloudWatchClient(region),
+		KubernetesClient:        newKubernetesClient(localMode),
+		DynamicClient:           newDynamicClient(localMode),
+		ClusterClients:          newClusterClients(localMode, clusterContexts),
+	}
+}
+
+// newManagerContext returns the manager context configured by the flags added by addManagerFlags
+func newManagerContext(cacheCfg service.ResponseCache) service.ManagerContext {
+	return service.ManagerContext{
+		CacheConfig:                        cacheCfg,
+		QueueName:                          queue.Name,
+		QueueURL:                           queue.URL,
+		QueueOwnerAccountID:                queue.AccountID,
+		QueueDiscoveryTags:                 parseTagFilters(queueDiscoveryTags),
+		QueueDiscoveryIntervalSeconds:      queueDiscoveryInterval,
+		ClusterName:                        clusterName,
+		DrainTimeoutSeconds:                int64(drainTimeoutSeconds),
+		DrainTimeoutUnknownSeconds:         int64(drainTimeoutUnknownSeconds),
+		PollingIntervalSeconds:             int64(pollingIntervalSeconds),
+		DrainRetryIntervalSeconds:          int64(drainRetryIntervalSeconds),
+		MaxDrainConcurrency:                semaphore.NewWeighted(maxDrainConcurrency),
+		MaxTimeToProcessSeconds:            int64(maxTimeToProcessSeconds),
+		DrainRetryAttempts:                 uint(drainRetryAttempts),
+		DrainFailurePolicy:                 service.FailurePolicy(drainFailurePolicy),
+		DrainGracePeriodSeconds:            drainGracePeriodSeconds,
+		DrainEscalationTimeoutSeconds:      drainEscalationTimeout,
+		DrainEscalationGracePeriodSeconds:  drainEscalationGrace,
+		DrainExcludeNamespaces:             drainExcludeNamespaces,
+		JobCompletionTimeoutSeconds:        jobCompletionTimeout,
+		EvictionOrder:                      evictionOrder,
+		WithVolumeDetachWait:               withVolumeDetachWait,
+		WithPodDeletionWait:                withPodDeletionWait,
+		PodDeletionTimeoutSeconds:          podDeletionTimeout,
+		PodDeletionNamespaceTimeouts:       podDeletionNSTimeouts,
+		WithNodeCondition:                  withNodeCondition,
+		DeleteNodeAfterComplete:            deleteNodeAfterComplete,
+		WaitForReplacement:                 waitForReplacement,
+		ReplacementWaitTimeoutSeconds:      replacementWaitTimeout,
+		Region:                             region,
+		WithDeregister:                     deregisterTargetGroups,
+		DeregisterTargetTypes:              deregisterTargetTypes,
+		DeregisterFailurePolicy:            service.FailurePolicy(deregisterFailurePolicy),
+		DeregisterFullScanFallback:         deregisterFullScan,
+		DeregisterTagFilters:               parseTagFilters(deregisterTagFilters),
+		WithIPTargetWait:                   withIPTargetWait,
+		DeregisterBeforeDrain:              deregisterBeforeDrain,
+		DeregisterDuringDrain:              deregisterDuringDrain,
+		MembershipCacheTTLSeconds:          membershipCacheTTLSeconds,
+		MembershipCheckConcurrency:         membershipConcurrency,
+		NodeAgeCacheFlushMinutes:           nodeAgeCacheFlushMinutes,
+		DeregisterBatchWindowSeconds:       deregisterBatchWindow,
+		Route53ZoneIDs:                     route53ZoneIDs,
+		Route53ZoneTagFilters:              parseTagFilters(route53ZoneTagFilters),
+		CloudMapNamespaceTagFilters:        parseTagFilters(cloudMapNamespaceTags),
+		CloudMapServiceTagFilters:          parseTagFilters(cloudMapServiceTags),
+		AcceleratorTagFilters:              parseTagFilters(acceleratorTagFilters),
+		AcceleratorDialDownSeconds:         acceleratorDialDownSeconds,
+		ScalingGroupMaxDrainConcurrency:    scalingGroupDrainLimits,
+		InstanceRefreshMaxDrainConcurrency: refreshDrainConcurrency,
+		BackoffJitter:                      backoffJitter,
+		WaiterMinDelaySeconds:              waiterMinDelaySeconds,
+		WaiterMaxDelaySeconds:              waiterMaxDelaySeconds,
+		WaiterMaxAttempts:                  waiterMaxAttempts,
+		WaiterDelayIntervalSeconds:         waiterDelayIntervalSeconds,
+		LBDiscoveryTimeoutSeconds:          lbDiscoveryTimeout,
+		DeregisterTimeoutSeconds:           deregisterTimeout,
+		WaiterTimeoutSeconds:               waiterTimeout,
+		WithLaunchHooks:                    withLaunchHooks,
+		LaunchTimeoutSeconds:               launchTimeoutSeconds,
+		LaunchReadinessSelector:            launchReadinessSelector,
+		LaunchReadinessCommand:             launchReadinessCommand,
+		NodeNotFoundGraceSeconds:           nodeNotFoundGraceSeconds,
+		TransientRetryAttempts:             transientRetryAttempts,
+		ValidationTimeoutSeconds:           validationTimeoutSeconds,
+		ReconcileOnStart:                   reconcileOnStart,
+		ReconcileIntervalSeconds:           reconcileIntervalSeconds,
+		MaxInFlightEvents:                  maxInFlightEvents,
+		WorkerPoolSize:                     workerPoolSize,
+		PollerCount:                        pollerCount,
+		DedupStore:                         dedupStore,
+		VerifySNSSignature:                 verifySNSSignature,
+		AllowedAccountIDs:                  allowedAccountIDs,
+		AllowedSenderIDs:                   allowedSenderIDs,
+		SkipNodeSelector:                   skipNodeSelector,
+		SkipNodeAction:                     skipNodeAction,
+		InstanceTagFilters:                 parseTagFilters(instanceTagFilters),
+		MaintenanceDrainLeadSeconds:        maintenanceDrainLead,
+		MaintenanceTerminate:               maintenanceTerminate,
+		ScaleInProtection:                  scaleInProtection,
+		ScaleInProtectionIntervalSeconds:   scaleInProtectionInterval,
+		ScaleDownDisabledAnnotation:        scaleDownDisabledKey,
+		ScaleDownDisabledAction:            scaleDownDisabledAction,
+		WithUpgradeManager:                 withUpgradeManager,
+		UpgradeManagerTagFilters:           parseTagFilters(upgradeManagerTagFilters),
+		WithInstanceManager:                withInstanceManager,
+		Policy:                             policy,
+		SelfNodeName:                       selfNodeName,
+		SelfPodName:                        selfPodName,
+		SelfPodNamespace:                   selfPodNamespace,
+		WithSharding:                       withSharding,
+		EventSinks:                         eventSinks,
+		EventWebhookURL:                    eventWebhookURL,
+		EventSNSTopicARN:                   eventSNSTopicARN,
+		EventNamespace:                     eventNamespace,
+		NodeScopedEvents:                   nodeScopedEvents,
+		HistorySize:                        historySize,
+		FailureRateWindow:                  failureRateWindow,
+		FailureRateThreshold:               failureRateThreshold,
+		FailureRateWebhookURL:              failureRateWebhookURL,
+		AuditTableName:                     auditTableName,
+		AuditRetentionDays:                 auditRetentionDays,
+		WithCloudWatchMetrics:              withCloudWatchMetrics,
+		CloudWatchNamespace:                cloudWatchNamespace,
+		CloudWatchIntervalSeconds:          cloudWatchInterval,
+		StatsDAddress:                      statsDAddress,
+		StatsDTags:                         statsDTags,
+		MetricsPort:                        metricsPort,
+		MetricsEndpoint:                    metricsEndpoint,
+		MetricsTLSCertFile:                 metricsTLSCertFile,
+		MetricsTLSKeyFile:                  metricsTLSKeyFile,
+		MetricsTLSClientCAFile:             metricsTLSClientCAFile,
 	}
 }
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/keikoproj/lifecycle-manager/pkg/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	simulateFile   string
+	simulateDryRun bool
+)
+
+// simulateCmd represents the simulate command
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "runs a lifecycle event through validation and processing without receiving it from the queue",
+	Long: `simulate reads a lifecycle event, or a serialized SQS message such as the in-progress annotation of a node,
+			from a file or stdin and validates it against the live cluster. In dry-run the drain and deregistration are
+			planned without changes, otherwise the event is processed as if it was received from the queue`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// argument validation
+		validateSimulate()
+		log.SetLevel(logLevel)
+		cacheCfg := newResponseCache()
+		apiRateLimiter = newAPIRateLimiter(apiRateLimit, apiRateBurst)
+
+		body, err := readSimulateInput(simulateFile)
+		if err != nil {
+			log.Fatalf("failed to read event: %v", err)
+		}
+		message, err := service.NewSimulatedMessage(body)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// the simulation does not join the shard ring, it would otherwise not own any instance
+		context := newManagerContext(cacheCfg)
+		context.WithSharding = false
+
+		s := service.New(newServeAuthenticator(cacheCfg), context)
+		report := s.Simulate(message, simulateDryRun)
+		printSimulation(report)
+		if report.Error != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(simulateCmd)
+	addManagerFlags(simulateCmd.Flags())
+	simulateCmd.Flags().StringVar(&simulateFile, "file", "-", "path to a file holding the lifecycle event or SQS message as JSON, - reads from stdin")
+	simulateCmd.Flags().BoolVar(&simulateDryRun, "dry-run", true, "validate the event and plan its processing without draining the node or completing the lifecycle hook")
+}
+
+func validateSimulate() {
+	if simulateFile != "-" {
+		if _, err := os.Stat(simulateFile); os.IsNotExist(err) {
+			log.Fatalf("provided event file does not exist")
+		}
+	}
+
+	validateManagerFlags()
+}
+
+// readSimulateInput reads the simulated event from a file, or stdin if the path is -
+func readSimulateInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// printSimulation prints the report of a simulated event
+func printSimulation(report *service.SimulationReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Dry run:\t%v\n", report.DryRun)
+	fmt.Fprintf(w, "Request:\t%v\n", report.RequestID)
+	fmt.Fprintf(w, "Instance:\t%v\n", report.InstanceID)
+	fmt.Fprintf(w, "Scaling group:\t%v\n", report.ScalingGroupName)
+	fmt.Fprintf(w, "Transition:\t%v\n", report.Transition)
+	if report.InstanceRefreshID != "" {
+		fmt.Fprintf(w, "Instance refresh:\t%v\n", report.InstanceRefreshID)
+	}
+	if report.NodeName != "" {
+		fmt.Fprintf(w, "Node:\t%v\n", report.NodeName)
+	}
+	if report.HeartbeatInterval > 0 {
+		fmt.Fprintf(w, "Heartbeat interval:\t%vs\n", report.HeartbeatInterval)
+	}
+	fmt.Fprintf(w, "Action:\t%v\n", report.Action)
+	if report.Action == service.SimulationActionDrain {
+		settings := report.Settings
+		fmt.Fprintf(w, "Drain timeout:\t%vs\n", settings.DrainTimeoutSeconds)
+		fmt.Fprintf(w, "Drain retries:\t%v every %vs\n", settings.DrainRetryAttempts, settings.DrainRetryIntervalSeconds)
+		fmt.Fprintf(w, "On drain failure:\t%v\n", settings.DrainFailurePolicy)
+		fmt.Fprintf(w, "On deregister failure:\t%v\n", settings.DeregisterFailurePolicy)
+		fmt.Fprintf(w, "Cordon only:\t%v\n", settings.CordonOnly)
+		fmt.Fprintf(w, "Skip deregister:\t%v\n", settings.SkipDeregister)
+		fmt.Fprintf(w, "Evicted pods:\t%v\n", formatSimulationList(report.EvictedPods))
+		fmt.Fprintf(w, "Target groups:\t%v\n", formatSimulationList(report.TargetGroups))
+		fmt.Fprintf(w, "Classic ELBs:\t%v\n", formatSimulationList(report.LoadBalancers))
+	}
+	fmt.Fprintf(w, "Phase:\t%v\n", report.Phase)
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(w, "Warning:\t%v\n", warning)
+	}
+	if report.Error != nil {
+		fmt.Fprintf(w, "Error:\t%v\n", report.Error)
+	}
+	w.Flush()
+}
+
+func formatSimulationList(items []string) string {
+	if len(items) == 0 {
+		return "<none>"
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/keikoproj/lifecycle-manager/pkg/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusAddress string
+	statusOutput  string
+	statusTimeout time.Duration
+	statusCACert  string
+	statusCert    string
+	statusKey     string
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "print the events a running lifecycle-manager is processing",
+	Long: `status queries the events endpoint served on the metrics port of a running lifecycle-manager and
+			prints the events being processed with their phase, age and the pods blocking their drain, oldest first`,
+	Run: func(cmd *cobra.Command, args []string) {
+		validateStatus()
+		log.SetLevel(logLevel)
+
+		events, err := getInFlightEvents(statusAddress)
+		if err != nil {
+			log.Fatalf("failed to get in-flight events: %v", err)
+		}
+
+		if statusOutput == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(events); err != nil {
+				log.Fatalf("failed to print in-flight events: %v", err)
+			}
+			return
+		}
+		printInFlightEvents(events, statusOutput == "wide")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().StringVar(&statusAddress, "address", fmt.Sprintf("http://localhost%v", service.MetricsPort), "address of the metrics server of the lifecycle-manager to query")
+	statusCmd.Flags().StringVar(&statusOutput, "output", "table", "output format, wide lists the blocking pods rather than counting them (table, wide, json)")
+	statusCmd.Flags().DurationVar(&statusTimeout, "timeout", 30*time.Second, "timeout of the request to lifecycle-manager, which lists the pods of draining nodes")
+	statusCmd.Flags().StringVar(&statusCACert, "ca-cert", "", "path to a CA bundle to verify the certificate of a metrics server served over TLS")
+	statusCmd.Flags().StringVar(&statusCert, "cert", "", "path to a client certificate file, when the metrics server requires client certificates")
+	statusCmd.Flags().StringVar(&statusKey, "key", "", "path to the key file of the client certificate")
+}
+
+func validateStatus() {
+	if statusAddress == "" {
+		log.Fatalf("--address was not provided")
+	}
+
+	if statusOutput != "table" && statusOutput != "wide" && statusOutput != "json" {
+		log.Fatalf("--output must be one of 'table', 'wide' or 'json'")
+	}
+
+	if (statusCert == "") != (statusKey == "") {
+		log.Fatalf("--cert and --key must be provided together")
+	}
+}
+
+func getInFlightEvents(address string) ([]service.InFlightEvent, error) {
+	tlsConfig, err := newAdminTLSConfig(statusCACert, statusCert, statusKey)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout:   statusTimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	resp, err := client.Get(strings.TrimSuffix(address, "/") + service.EventsEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lifecycle-manager responded with status %v", resp.StatusCode)
+	}
+
+	events := make([]service.InFlightEvent, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func printInFlightEvents(events []service.InFlightEvent, wide bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START TIME\tREQUEST ID\tINSTANCE\tSCALING GROUP\tNODE\tTRANSITION\tPHASE\tAGE\tBLOCKING PODS")
+	for _, e := range events {
+		transition := strings.ToLower(strings.TrimPrefix(e.Transition, "autoscaling:EC2_INSTANCE_"))
+		blocking := fmt.Sprint(len(e.BlockingPods))
+		if wide {
+			blocking = strings.Join(e.BlockingPods, ",")
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%.0fs\t%v\n",
+			e.StartTime.Format(time.RFC3339), e.RequestID, e.InstanceID, e.ScalingGroupName, e.NodeName, transition,
+			e.Phase, e.AgeSeconds, blocking)
+	}
+	w.Flush()
+}
@@ -15,7 +15,9 @@ import (
 
 const (
 	terminationTransitionName   = "autoscaling:EC2_INSTANCE_TERMINATING"
+	launchTransitionName        = "autoscaling:EC2_INSTANCE_LAUNCHING"
 	defaultHookName             = "lifecycle-manager"
+	defaultLaunchHookName       = "lifecycle-manager-launch"
 	notificationRoleDescription = `Role used by lifecycle-manager for sending hooks from autoscaling to SQS`
 	notificationPolicyARN       = "arn:aws:iam::aws:policy/service-role/AutoScalingNotificationAccessRole"
 )
@@ -38,6 +40,7 @@ type EnrollmentContext struct {
 	QueueARN             string
 	RoleARN              string
 	Overwrite            bool
+	WithLaunchHook       bool
 }
 
 type Worker struct {
@@ -68,7 +71,13 @@ func (w *Worker) Start() {
 	}
 
 	for _, scalingGroup := range ctx.TargetScalingGroups {
-		if err := w.CreateLifecycleHook(scalingGroup); err != nil {
+		if err := w.CreateLifecycleHook(scalingGroup, defaultHookName, terminationTransitionName); err != nil {
+			log.Fatal(err)
+		}
+		if !ctx.WithLaunchHook {
+			continue
+		}
+		if err := w.CreateLifecycleHook(scalingGroup, defaultLaunchHookName, launchTransitionName); err != nil {
 			log.Fatal(err)
 		}
 	}
@@ -78,7 +87,7 @@ func (w *Worker) Start() {
 	log.Infof("Queue URL: %v", ctx.QueueURL)
 }
 
-func (w *Worker) CreateLifecycleHook(scalingGroup string) error {
+func (w *Worker) CreateLifecycleHook(scalingGroup, hookName, transition string) error {
 	var (
 		ASGClient = w.authenticator.ScalingGroupClient
 		ctx       = w.context
@@ -87,13 +96,13 @@ func (w *Worker) CreateLifecycleHook(scalingGroup string) error {
 	crInput := &autoscaling.PutLifecycleHookInput{
 		AutoScalingGroupName:  aws.String(scalingGroup),
 		HeartbeatTimeout:      aws.Int64(int64(ctx.HeartbeatTimeout)),
-		LifecycleHookName:     aws.String(defaultHookName),
-		LifecycleTransition:   aws.String(terminationTransitionName),
+		LifecycleHookName:     aws.String(hookName),
+		LifecycleTransition:   aws.String(transition),
 		NotificationTargetARN: aws.String(ctx.QueueARN),
 		RoleARN:               aws.String(ctx.RoleARN),
 	}
 
-	log.Infof("creating lifecycle hook for '%v'", scalingGroup)
+	log.Infof("creating lifecycle hook '%v' for '%v'", hookName, scalingGroup)
 	if _, err := ASGClient.PutLifecycleHook(crInput); err != nil {
 		return errors.Errorf("failed to put lifecycle hook: %v", err)
 	}
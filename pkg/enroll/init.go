@@ -0,0 +1,235 @@
+package enroll
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// queueActions are the SQS actions lifecycle-manager needs on its queue
+var queueActions = []string{
+	"sqs:ReceiveMessage",
+	"sqs:DeleteMessage",
+	"sqs:GetQueueUrl",
+	"sqs:GetQueueAttributes",
+	"sqs:ChangeMessageVisibility",
+	"sqs:ListQueues",
+	"sqs:ListQueueTags",
+}
+
+// serviceActions are the actions lifecycle-manager needs on resources which are discovered at runtime
+var serviceActions = []string{
+	"autoscaling:DescribeLifecycleHooks",
+	"autoscaling:DescribeAutoScalingGroups",
+	"autoscaling:DescribeLoadBalancerTargetGroups",
+	"autoscaling:DescribeLoadBalancers",
+	"autoscaling:DescribeInstanceRefreshes",
+	"autoscaling:CompleteLifecycleAction",
+	"autoscaling:RecordLifecycleActionHeartbeat",
+	"ec2:DescribeSecurityGroups",
+	"ec2:DescribeClassicLinkInstances",
+	"ec2:DescribeInstances",
+	"ec2:DescribeVolumes",
+	"elasticloadbalancing:DeregisterInstancesFromLoadBalancer",
+	"elasticloadbalancing:DescribeInstanceHealth",
+	"elasticloadbalancing:DescribeLoadBalancers",
+	"elasticloadbalancing:DescribeLoadBalancerAttributes",
+	"elasticloadbalancing:DeregisterTargets",
+	"elasticloadbalancing:DescribeTargetHealth",
+	"elasticloadbalancing:DescribeTargetGroups",
+	"elasticloadbalancing:DescribeTargetGroupAttributes",
+	"elasticloadbalancing:DescribeTags",
+	"route53:ListHostedZones",
+	"route53:ListTagsForResources",
+	"route53:ListResourceRecordSets",
+	"route53:ChangeResourceRecordSets",
+	"servicediscovery:ListNamespaces",
+	"servicediscovery:ListServices",
+	"servicediscovery:ListTagsForResource",
+	"servicediscovery:ListInstances",
+	"servicediscovery:DeregisterInstance",
+	"servicediscovery:GetOperation",
+	"globalaccelerator:ListAccelerators",
+	"globalaccelerator:ListTagsForResource",
+	"globalaccelerator:ListListeners",
+	"globalaccelerator:ListEndpointGroups",
+	"globalaccelerator:DescribeAccelerator",
+	"globalaccelerator:UpdateEndpointGroup",
+	"globalaccelerator:RemoveEndpoints",
+	"sns:Publish",
+	"dynamodb:PutItem",
+	"cloudwatch:PutMetricData",
+}
+
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource string   `json:"Resource"`
+}
+
+// GetLifecycleManagerPolicy returns the IAM policy document lifecycle-manager needs to consume the queue and process events
+func GetLifecycleManagerPolicy(queueARN string) (string, error) {
+	doc := policyDocument{
+		Version: "2012-10-17",
+		Statement: []policyStatement{
+			{Effect: "Allow", Action: queueActions, Resource: queueARN},
+			{Effect: "Allow", Action: serviceActions, Resource: "*"},
+		},
+	}
+	b, err := json.MarshalIndent(doc, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Init validates the scaling groups, creates or validates the queue and notification role and points the lifecycle
+// hooks of the scaling groups to the queue. Unlike Start, existing resources are validated rather than assumed to be
+// created by lifecycle-manager
+func (w *Worker) Init() error {
+	var (
+		ctx = w.context
+	)
+
+	log.Infof("initializing lifecycle-manager for scaling groups %+v", ctx.TargetScalingGroups)
+
+	if err := w.ValidateScalingGroups(); err != nil {
+		return err
+	}
+
+	if ctx.RoleARN != "" {
+		log.Infof("using notification role '%v'", ctx.RoleARN)
+	} else if err := w.CreateNotificationRole(); err != nil {
+		return err
+	}
+
+	if err := w.EnsureSQSQueue(); err != nil {
+		return err
+	}
+
+	for _, scalingGroup := range ctx.TargetScalingGroups {
+		if err := w.EnsureLifecycleHook(scalingGroup, defaultHookName, terminationTransitionName); err != nil {
+			return err
+		}
+		if !ctx.WithLaunchHook {
+			continue
+		}
+		if err := w.EnsureLifecycleHook(scalingGroup, defaultLaunchHookName, launchTransitionName); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("successfully initialized %v scaling groups", len(ctx.TargetScalingGroups))
+	log.Infof("Queue Name: %v", ctx.QueueName)
+	log.Infof("Queue URL: %v", ctx.QueueURL)
+	return nil
+}
+
+// ValidateScalingGroups returns an error if any of the target scaling groups does not exist
+func (w *Worker) ValidateScalingGroups() error {
+	var (
+		ASGClient = w.authenticator.ScalingGroupClient
+		ctx       = w.context
+		found     = make(map[string]bool)
+	)
+
+	input := &autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: aws.StringSlice(ctx.TargetScalingGroups),
+	}
+	err := ASGClient.DescribeAutoScalingGroupsPages(input, func(page *autoscaling.DescribeAutoScalingGroupsOutput, lastPage bool) bool {
+		for _, group := range page.AutoScalingGroups {
+			found[aws.StringValue(group.AutoScalingGroupName)] = true
+		}
+		return true
+	})
+	if err != nil {
+		return errors.Errorf("failed to describe scaling groups: %v", err)
+	}
+
+	missing := make([]string, 0)
+	for _, name := range ctx.TargetScalingGroups {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("scaling groups not found: %v", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// EnsureSQSQueue validates the queue if it already exists, or creates it
+func (w *Worker) EnsureSQSQueue() error {
+	var (
+		SQSClient = w.authenticator.SQSClient
+		ctx       = w.context
+	)
+
+	out, err := SQSClient.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String(ctx.QueueName)})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == sqs.ErrCodeQueueDoesNotExist {
+			return w.CreateSQSQueue()
+		}
+		return errors.Errorf("failed to get SQS queue url: %v", err)
+	}
+	ctx.QueueURL = aws.StringValue(out.QueueUrl)
+
+	attr, err := SQSClient.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       out.QueueUrl,
+		AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameAll}),
+	})
+	if err != nil {
+		return errors.Errorf("failed get queue attribute: %v", err)
+	}
+	ctx.QueueARN = aws.StringValue(attr.Attributes[sqs.QueueAttributeNameQueueArn])
+
+	if aws.StringValue(attr.Attributes[sqs.QueueAttributeNameFifoQueue]) == "true" {
+		return errors.Errorf("queue '%v' is a FIFO queue, lifecycle hooks can only notify standard queues", ctx.QueueName)
+	}
+
+	// the managed notification policy does not allow encrypting messages with a customer managed key
+	if key := aws.StringValue(attr.Attributes[sqs.QueueAttributeNameKmsMasterKeyId]); key != "" && key != "alias/aws/sqs" {
+		log.Warnf("queue '%v' is encrypted with '%v', the notification role needs kms:GenerateDataKey and kms:Decrypt on the key", ctx.QueueName, key)
+	}
+
+	log.Infof("using existing queue '%v'", ctx.QueueARN)
+	return nil
+}
+
+// EnsureLifecycleHook creates the lifecycle hook, a hook of the same name notifying another target is only replaced
+// with --overwrite
+func (w *Worker) EnsureLifecycleHook(scalingGroup, hookName, transition string) error {
+	var (
+		ASGClient = w.authenticator.ScalingGroupClient
+		ctx       = w.context
+	)
+
+	out, err := ASGClient.DescribeLifecycleHooks(&autoscaling.DescribeLifecycleHooksInput{
+		AutoScalingGroupName: aws.String(scalingGroup),
+		LifecycleHookNames:   aws.StringSlice([]string{hookName}),
+	})
+	if err != nil {
+		return errors.Errorf("failed to describe lifecycle hooks of '%v': %v", scalingGroup, err)
+	}
+
+	for _, hook := range out.LifecycleHooks {
+		target := aws.StringValue(hook.NotificationTargetARN)
+		if target == ctx.QueueARN || ctx.Overwrite {
+			continue
+		}
+		log.Warn("set flag --overwrite for pointing existing hooks to the queue")
+		return errors.Errorf("lifecycle hook '%v' of '%v' already notifies '%v'", hookName, scalingGroup, target)
+	}
+	return w.CreateLifecycleHook(scalingGroup, hookName, transition)
+}
@@ -0,0 +1,72 @@
+package enroll
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// readmePolicyHeading is the README heading followed by the documented policy of lifecycle-manager
+const readmePolicyHeading = "### Required AWS Auth"
+
+func Test_GetLifecycleManagerPolicy(t *testing.T) {
+	t.Log("Test_GetLifecycleManagerPolicy: should print the actions of the policy documented in the README")
+	queueARN := "arn:aws:sqs:us-west-2:000000000000:lifecycle-manager-queue"
+
+	printed, err := GetLifecycleManagerPolicy(queueARN)
+	if err != nil {
+		t.Fatalf("GetLifecycleManagerPolicy: expected error not to have occured, %v", err)
+	}
+
+	doc := policyDocument{}
+	if err := json.Unmarshal([]byte(printed), &doc); err != nil {
+		t.Fatalf("failed to decode printed policy: %v", err)
+	}
+	if len(doc.Statement) != 2 || doc.Statement[0].Resource != queueARN || doc.Statement[1].Resource != "*" {
+		t.Fatalf("expected statements on the queue and on all resources, got: %+v", doc.Statement)
+	}
+	actions := make([]string, 0)
+	for _, statement := range doc.Statement {
+		actions = append(actions, statement.Action...)
+	}
+
+	documented := readDocumentedActions(t)
+	sort.Strings(actions)
+	sort.Strings(documented)
+	if strings.Join(actions, ",") != strings.Join(documented, ",") {
+		t.Fatalf("expected printed actions to match the README, printed: %v, documented: %v", actions, documented)
+	}
+}
+
+// readDocumentedActions returns the actions of the policy documented in the README
+func readDocumentedActions(t *testing.T) []string {
+	readme, err := os.ReadFile("../../README.md")
+	if err != nil {
+		t.Fatalf("failed to read README: %v", err)
+	}
+
+	section := string(readme)
+	start := strings.Index(section, readmePolicyHeading)
+	if start < 0 {
+		t.Fatalf("expected README to have a %q section", readmePolicyHeading)
+	}
+	section = section[start:]
+	start = strings.Index(section, "```json")
+	if start < 0 {
+		t.Fatalf("expected README to document the policy as json")
+	}
+	section = section[start+len("```json"):]
+	end := strings.Index(section, "```")
+	if end < 0 {
+		t.Fatalf("expected README to document the policy as json")
+	}
+	block := section[:end]
+
+	statement := policyStatement{}
+	if err := json.Unmarshal([]byte(block), &statement); err != nil {
+		t.Fatalf("failed to decode documented policy: %v", err)
+	}
+	return statement.Action
+}
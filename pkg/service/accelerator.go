@@ -0,0 +1,226 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/globalaccelerator"
+	"github.com/aws/aws-sdk-go/service/globalaccelerator/globalacceleratoriface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+// AcceleratorEndpoint is an instance endpoint in a global accelerator endpoint group
+type AcceleratorEndpoint struct {
+	AcceleratorArn   string
+	EndpointGroupArn string
+	Endpoints        []*globalaccelerator.EndpointDescription
+}
+
+// removeAcceleratorEndpoints dials the event's instance down to zero weight in all matching endpoint groups,
+// waits for the change to deploy and the dial down period to pass, then removes the endpoint
+func (mgr *Manager) removeAcceleratorEndpoints(event *LifecycleEvent) error {
+	var (
+		ctx          = &mgr.context
+		client       = mgr.authenticator.GlobalAcceleratorClient
+		instanceID   = event.EC2InstanceID
+		waiterConfig = mgr.waiterConfig()
+		dialDown     = time.Duration(ctx.AcceleratorDialDownSeconds) * time.Second
+		errs         error
+		mutex        sync.Mutex
+	)
+
+	if client == nil || len(ctx.AcceleratorTagFilters) == 0 {
+		return nil
+	}
+
+	groups, err := findInstanceInAccelerators(client, instanceID, ctx.AcceleratorTagFilters)
+	if err != nil {
+		return err
+	}
+
+	forEachConcurrently(ctx.MembershipCheckConcurrency, len(groups), func(i int) {
+		group := groups[i]
+		log.Infof("%v> removing endpoint from accelerator endpoint group %v", instanceID, group.EndpointGroupArn)
+		err := removeAcceleratorEndpoint(event, client, group, dialDown, waiterConfig)
+		if err != nil {
+			log.Errorf("%v> failed to remove endpoint from accelerator endpoint group %v: %v", instanceID, group.EndpointGroupArn, err)
+			msg := fmt.Sprintf(EventMessageAcceleratorEndpointRemoveFailed, instanceID, group.EndpointGroupArn, err)
+			mgr.publishEvent(event, EventReasonAcceleratorEndpointRemoveFailed, getMessageFields(event, msg))
+			mutex.Lock()
+			errs = fmt.Errorf("failed to remove endpoint from accelerator endpoint group %v: %v", group.EndpointGroupArn, err)
+			mutex.Unlock()
+			return
+		}
+		log.Infof("%v> successfully removed endpoint from accelerator endpoint group %v", instanceID, group.EndpointGroupArn)
+		msg := fmt.Sprintf(EventMessageAcceleratorEndpointRemoved, instanceID, group.EndpointGroupArn)
+		mgr.publishEvent(event, EventReasonAcceleratorEndpointRemoved, getMessageFields(event, msg))
+	})
+
+	return errs
+}
+
+// findInstanceInAccelerators returns the endpoint groups of accelerators carrying all filter tags which contain the instance
+func findInstanceInAccelerators(client globalacceleratoriface.GlobalAcceleratorAPI, instanceID string, filters map[string]string) ([]AcceleratorEndpoint, error) {
+	var (
+		accelerators = []string{}
+		found        = []AcceleratorEndpoint{}
+	)
+
+	err := client.ListAcceleratorsPages(&globalaccelerator.ListAcceleratorsInput{}, func(page *globalaccelerator.ListAcceleratorsOutput, lastPage bool) bool {
+		for _, accelerator := range page.Accelerators {
+			accelerators = append(accelerators, aws.StringValue(accelerator.AcceleratorArn))
+		}
+		return true
+	})
+	if err != nil {
+		return found, err
+	}
+
+	for _, acceleratorArn := range accelerators {
+		out, err := client.ListTagsForResource(&globalaccelerator.ListTagsForResourceInput{
+			ResourceArn: aws.String(acceleratorArn),
+		})
+		if err != nil {
+			return found, err
+		}
+		tags := make(map[string]string)
+		for _, tag := range out.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		if !matchesTagFilters(tags, filters) {
+			continue
+		}
+
+		listeners := []string{}
+		input := &globalaccelerator.ListListenersInput{AcceleratorArn: aws.String(acceleratorArn)}
+		err = client.ListListenersPages(input, func(page *globalaccelerator.ListListenersOutput, lastPage bool) bool {
+			for _, listener := range page.Listeners {
+				listeners = append(listeners, aws.StringValue(listener.ListenerArn))
+			}
+			return true
+		})
+		if err != nil {
+			return found, err
+		}
+
+		for _, listenerArn := range listeners {
+			input := &globalaccelerator.ListEndpointGroupsInput{ListenerArn: aws.String(listenerArn)}
+			err = client.ListEndpointGroupsPages(input, func(page *globalaccelerator.ListEndpointGroupsOutput, lastPage bool) bool {
+				for _, group := range page.EndpointGroups {
+					for _, endpoint := range group.EndpointDescriptions {
+						if aws.StringValue(endpoint.EndpointId) == instanceID {
+							found = append(found, AcceleratorEndpoint{
+								AcceleratorArn:   acceleratorArn,
+								EndpointGroupArn: aws.StringValue(group.EndpointGroupArn),
+								Endpoints:        group.EndpointDescriptions,
+							})
+							break
+						}
+					}
+				}
+				return true
+			})
+			if err != nil {
+				return found, err
+			}
+		}
+	}
+	return found, nil
+}
+
+func removeAcceleratorEndpoint(event *LifecycleEvent, client globalacceleratoriface.GlobalAcceleratorAPI, group AcceleratorEndpoint, dialDown time.Duration, config WaiterConfig) error {
+	var (
+		instanceID     = event.EC2InstanceID
+		configurations = []*globalaccelerator.EndpointConfiguration{}
+		dialed         bool
+	)
+
+	for _, endpoint := range group.Endpoints {
+		weight := endpoint.Weight
+		if aws.StringValue(endpoint.EndpointId) == instanceID && aws.Int64Value(weight) != 0 {
+			weight = aws.Int64(0)
+			dialed = true
+		}
+		configurations = append(configurations, &globalaccelerator.EndpointConfiguration{
+			EndpointId:                  endpoint.EndpointId,
+			Weight:                      weight,
+			ClientIPPreservationEnabled: endpoint.ClientIPPreservationEnabled,
+		})
+	}
+
+	if dialed {
+		_, err := client.UpdateEndpointGroupWithContext(event.Context(), &globalaccelerator.UpdateEndpointGroupInput{
+			EndpointGroupArn:       aws.String(group.EndpointGroupArn),
+			EndpointConfigurations: configurations,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := waitForAcceleratorDeployed(event, client, group.AcceleratorArn, config); err != nil {
+			return err
+		}
+
+		log.Debugf("%v> waiting %v for accelerator traffic to dial down", instanceID, dialDown)
+		select {
+		case <-event.Context().Done():
+			return event.contextError("accelerator dial down")
+		case <-time.After(dialDown):
+		}
+	}
+
+	_, err := client.RemoveEndpointsWithContext(event.Context(), &globalaccelerator.RemoveEndpointsInput{
+		EndpointGroupArn: aws.String(group.EndpointGroupArn),
+		EndpointIdentifiers: []*globalaccelerator.EndpointIdentifier{
+			{EndpointId: aws.String(instanceID)},
+		},
+	})
+	if err != nil {
+		if _, ok := err.(*globalaccelerator.EndpointNotFoundException); ok {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func waitForAcceleratorDeployed(event *LifecycleEvent, client globalacceleratoriface.GlobalAcceleratorAPI, arn string, config WaiterConfig) error {
+	var (
+		instanceID = event.EC2InstanceID
+	)
+
+	input := &globalaccelerator.DescribeAcceleratorInput{
+		AcceleratorArn: aws.String(arn),
+	}
+
+	for ieb, err := config.newBackoff(event.Context()); err == nil; err = ieb.Next() {
+
+		if event.isCompleted() {
+			return errors.New("event finished execution during accelerator deployment wait")
+		}
+
+		if err := event.contextError("accelerator deployment wait"); err != nil {
+			return err
+		}
+
+		// stop before the lifecycle hook expires rather than waiting past it
+		if remaining, ok := event.remainingTime(); ok && remaining <= config.maxDelay() {
+			return fmt.Errorf("lifecycle hook deadline in %v reached during accelerator deployment wait", remaining.Round(time.Second))
+		}
+
+		out, err := client.DescribeAcceleratorWithContext(event.Context(), input)
+		if err != nil {
+			return err
+		}
+
+		if aws.StringValue(out.Accelerator.Status) == globalaccelerator.AcceleratorStatusDeployed {
+			return nil
+		}
+		log.Debugf("%v> accelerator %v deployment pending", instanceID, arn)
+	}
+
+	return errors.New("wait for accelerator deployment timed out")
+}
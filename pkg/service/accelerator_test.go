@@ -0,0 +1,151 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/globalaccelerator"
+	"github.com/aws/aws-sdk-go/service/globalaccelerator/globalacceleratoriface"
+)
+
+type stubGlobalAccelerator struct {
+	globalacceleratoriface.GlobalAcceleratorAPI
+	accelerators                   map[string]map[string]string
+	endpointGroups                 []*globalaccelerator.EndpointGroup
+	acceleratorStatuses            []string
+	updateInputs                   []*globalaccelerator.UpdateEndpointGroupInput
+	timesCalledDescribeAccelerator int
+	timesCalledRemoveEndpoints     int
+}
+
+func (g *stubGlobalAccelerator) ListAcceleratorsPages(input *globalaccelerator.ListAcceleratorsInput, callback func(*globalaccelerator.ListAcceleratorsOutput, bool) bool) error {
+	accelerators := []*globalaccelerator.Accelerator{}
+	for arn := range g.accelerators {
+		accelerators = append(accelerators, &globalaccelerator.Accelerator{AcceleratorArn: aws.String(arn)})
+	}
+	callback(&globalaccelerator.ListAcceleratorsOutput{Accelerators: accelerators}, true)
+	return nil
+}
+
+func (g *stubGlobalAccelerator) ListTagsForResource(input *globalaccelerator.ListTagsForResourceInput) (*globalaccelerator.ListTagsForResourceOutput, error) {
+	tags := []*globalaccelerator.Tag{}
+	for k, v := range g.accelerators[aws.StringValue(input.ResourceArn)] {
+		tags = append(tags, &globalaccelerator.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return &globalaccelerator.ListTagsForResourceOutput{Tags: tags}, nil
+}
+
+func (g *stubGlobalAccelerator) ListListenersPages(input *globalaccelerator.ListListenersInput, callback func(*globalaccelerator.ListListenersOutput, bool) bool) error {
+	listener := &globalaccelerator.Listener{ListenerArn: aws.String(aws.StringValue(input.AcceleratorArn) + "/listener")}
+	callback(&globalaccelerator.ListListenersOutput{Listeners: []*globalaccelerator.Listener{listener}}, true)
+	return nil
+}
+
+func (g *stubGlobalAccelerator) ListEndpointGroupsPages(input *globalaccelerator.ListEndpointGroupsInput, callback func(*globalaccelerator.ListEndpointGroupsOutput, bool) bool) error {
+	callback(&globalaccelerator.ListEndpointGroupsOutput{EndpointGroups: g.endpointGroups}, true)
+	return nil
+}
+
+func (g *stubGlobalAccelerator) UpdateEndpointGroup(input *globalaccelerator.UpdateEndpointGroupInput) (*globalaccelerator.UpdateEndpointGroupOutput, error) {
+	g.updateInputs = append(g.updateInputs, input)
+	return &globalaccelerator.UpdateEndpointGroupOutput{}, nil
+}
+
+func (g *stubGlobalAccelerator) UpdateEndpointGroupWithContext(ctx aws.Context, input *globalaccelerator.UpdateEndpointGroupInput, opts ...request.Option) (*globalaccelerator.UpdateEndpointGroupOutput, error) {
+	return g.UpdateEndpointGroup(input)
+}
+
+func (g *stubGlobalAccelerator) DescribeAccelerator(input *globalaccelerator.DescribeAcceleratorInput) (*globalaccelerator.DescribeAcceleratorOutput, error) {
+	status := globalaccelerator.AcceleratorStatusDeployed
+	if g.timesCalledDescribeAccelerator < len(g.acceleratorStatuses) {
+		status = g.acceleratorStatuses[g.timesCalledDescribeAccelerator]
+	}
+	g.timesCalledDescribeAccelerator++
+	return &globalaccelerator.DescribeAcceleratorOutput{
+		Accelerator: &globalaccelerator.Accelerator{AcceleratorArn: input.AcceleratorArn, Status: aws.String(status)},
+	}, nil
+}
+
+func (g *stubGlobalAccelerator) DescribeAcceleratorWithContext(ctx aws.Context, input *globalaccelerator.DescribeAcceleratorInput, opts ...request.Option) (*globalaccelerator.DescribeAcceleratorOutput, error) {
+	return g.DescribeAccelerator(input)
+}
+
+func (g *stubGlobalAccelerator) RemoveEndpoints(input *globalaccelerator.RemoveEndpointsInput) (*globalaccelerator.RemoveEndpointsOutput, error) {
+	g.timesCalledRemoveEndpoints++
+	return &globalaccelerator.RemoveEndpointsOutput{}, nil
+}
+
+func (g *stubGlobalAccelerator) RemoveEndpointsWithContext(ctx aws.Context, input *globalaccelerator.RemoveEndpointsInput, opts ...request.Option) (*globalaccelerator.RemoveEndpointsOutput, error) {
+	return g.RemoveEndpoints(input)
+}
+
+func Test_RemoveAcceleratorEndpoint(t *testing.T) {
+	t.Log("Test_RemoveAcceleratorEndpoint: should dial down and remove the instance from endpoint groups of matching accelerators")
+	var (
+		instanceID = "i-123456789012"
+	)
+
+	stubber := &stubGlobalAccelerator{
+		accelerators: map[string]map[string]string{
+			"arn:accelerator/1": {"cluster": "test"},
+			"arn:accelerator/2": {"cluster": "other"},
+		},
+		endpointGroups: []*globalaccelerator.EndpointGroup{
+			{
+				EndpointGroupArn: aws.String("arn:endpoint-group/1"),
+				EndpointDescriptions: []*globalaccelerator.EndpointDescription{
+					{EndpointId: aws.String(instanceID), Weight: aws.Int64(128)},
+					{EndpointId: aws.String("i-000000000000"), Weight: aws.Int64(128)},
+				},
+			},
+			{
+				EndpointGroupArn: aws.String("arn:endpoint-group/2"),
+				EndpointDescriptions: []*globalaccelerator.EndpointDescription{
+					{EndpointId: aws.String("i-000000000000"), Weight: aws.Int64(128)},
+				},
+			},
+		},
+		acceleratorStatuses: []string{globalaccelerator.AcceleratorStatusInProgress},
+	}
+
+	event := &LifecycleEvent{EC2InstanceID: instanceID, startTime: time.Now()}
+	config := WaiterConfig{MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	groups, err := findInstanceInAccelerators(stubber, instanceID, map[string]string{"cluster": "test"})
+	if err != nil {
+		t.Fatalf("findInstanceInAccelerators: expected error not to have occured, %v", err)
+	}
+
+	if len(groups) != 1 || groups[0].EndpointGroupArn != "arn:endpoint-group/1" {
+		t.Fatalf("expected endpoint groups: %v, got: %v", []string{"arn:endpoint-group/1"}, groups)
+	}
+
+	err = removeAcceleratorEndpoint(event, stubber, groups[0], 0, config)
+	if err != nil {
+		t.Fatalf("removeAcceleratorEndpoint: expected error not to have occured, %v", err)
+	}
+
+	if len(stubber.updateInputs) != 1 {
+		t.Fatalf("expected UpdateEndpointGroup calls: %v, got: %v", 1, len(stubber.updateInputs))
+	}
+
+	for _, config := range stubber.updateInputs[0].EndpointConfigurations {
+		expected := int64(128)
+		if aws.StringValue(config.EndpointId) == instanceID {
+			expected = 0
+		}
+		if aws.Int64Value(config.Weight) != expected {
+			t.Fatalf("expected weight of %v: %v, got: %v", aws.StringValue(config.EndpointId), expected, aws.Int64Value(config.Weight))
+		}
+	}
+
+	if stubber.timesCalledDescribeAccelerator != 2 {
+		t.Fatalf("expected timesCalledDescribeAccelerator: %v, got: %v", 2, stubber.timesCalledDescribeAccelerator)
+	}
+
+	if stubber.timesCalledRemoveEndpoints != 1 {
+		t.Fatalf("expected timesCalledRemoveEndpoints: %v, got: %v", 1, stubber.timesCalledRemoveEndpoints)
+	}
+}
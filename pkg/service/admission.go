@@ -0,0 +1,70 @@
+package service
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// getInstanceTags returns the tags of an EC2 instance
+func getInstanceTags(client ec2iface.EC2API, instanceID string) (map[string]string, error) {
+	tags := make(map[string]string)
+	out, err := client.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: aws.StringSlice([]string{instanceID}),
+	})
+	if err != nil {
+		return tags, err
+	}
+
+	for _, reservation := range out.Reservations {
+		for _, instance := range reservation.Instances {
+			for _, tag := range instance.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+		}
+	}
+	return tags, nil
+}
+
+// loadInstanceTags enriches an event with the tags of its instance, when --instance-tag-filter needs them to admit
+// the event
+func (mgr *Manager) loadInstanceTags(e *LifecycleEvent) error {
+	tags, err := mgr.admissionTags(e)
+	if err != nil {
+		return err
+	}
+	if tags != nil {
+		e.instanceTags = tags
+	}
+	return nil
+}
+
+// admissionTags returns the tags of an event's instance when --instance-tag-filter needs them to admit the event,
+// nil is returned when they are not needed or already known
+func (mgr *Manager) admissionTags(e *LifecycleEvent) (map[string]string, error) {
+	if len(mgr.context.InstanceTagFilters) == 0 || e.instanceTags != nil {
+		return nil, nil
+	}
+
+	tags, err := getInstanceTags(mgr.authenticator.EC2Client, e.EC2InstanceID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get tags of instance %v", e.EC2InstanceID)
+	}
+	return tags, nil
+}
+
+// isInstanceAdmitted returns true if the event's instance carries the tags of --instance-tag-filter, instances
+// without them are left to other termination handlers and skipped
+func (mgr *Manager) isInstanceAdmitted(e *LifecycleEvent) bool {
+	filters := mgr.context.InstanceTagFilters
+	if len(filters) == 0 {
+		return true
+	}
+	if !matchesTagFilters(e.instanceTags, filters) {
+		log.Infof("%v> instance is not tagged %v", e.EC2InstanceID, filters)
+		return false
+	}
+	return true
+}
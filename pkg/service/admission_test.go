@@ -0,0 +1,91 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func Test_IsInstanceAdmitted(t *testing.T) {
+	t.Log("Test_IsInstanceAdmitted: should only admit instances carrying the instance tag filters")
+	stubber := &stubEC2{
+		instances: []*ec2.Instance{
+			{
+				InstanceId: aws.String("i-111111111111"),
+				Tags:       []*ec2.Tag{{Key: aws.String("managed-by"), Value: aws.String("lifecycle-manager")}},
+			},
+			{
+				InstanceId: aws.String("i-222222222222"),
+				Tags:       []*ec2.Tag{{Key: aws.String("managed-by"), Value: aws.String("other-handler")}},
+			},
+			{
+				InstanceId: aws.String("i-333333333333"),
+			},
+		},
+	}
+
+	tests := []struct {
+		instanceID string
+		filters    map[string]string
+		expected   bool
+	}{
+		{"i-111111111111", map[string]string{"managed-by": "lifecycle-manager"}, true},
+		{"i-222222222222", map[string]string{"managed-by": "lifecycle-manager"}, false},
+		{"i-333333333333", map[string]string{"managed-by": "lifecycle-manager"}, false},
+		{"i-222222222222", map[string]string{"managed-by": ""}, true},
+		{"i-333333333333", map[string]string{}, true},
+	}
+
+	for _, tc := range tests {
+		ctx := _newBasicContext()
+		ctx.InstanceTagFilters = tc.filters
+		mgr := New(Authenticator{EC2Client: stubber}, ctx)
+		event := &LifecycleEvent{EC2InstanceID: tc.instanceID}
+
+		if err := mgr.loadInstanceTags(event); err != nil {
+			t.Fatalf("loadInstanceTags: expected error not to have occured, %v", err)
+		}
+		if got := mgr.isInstanceAdmitted(event); got != tc.expected {
+			t.Fatalf("expected instance %v admitted with filters %v: %v, got: %v", tc.instanceID, tc.filters, tc.expected, got)
+		}
+	}
+}
+
+func Test_EvaluatePolicyInstanceTags(t *testing.T) {
+	t.Log("Test_EvaluatePolicyInstanceTags: should match policy rules against the tags of the event's instance")
+	stubber := &stubEC2{
+		instances: []*ec2.Instance{
+			{
+				InstanceId: aws.String("i-111111111111"),
+				Tags:       []*ec2.Tag{{Key: aws.String("managed-by"), Value: aws.String("other-handler")}},
+			},
+			{
+				InstanceId: aws.String("i-222222222222"),
+			},
+		},
+	}
+	ctx := _newBasicContext()
+	ctx.Policy = &Policy{
+		Rules: []PolicyRule{
+			{
+				Name:     "other-handler",
+				Match:    PolicyMatch{InstanceTags: map[string]string{"managed-by": "other-handler"}},
+				Decision: PolicyDecision{Action: PolicyActionSkip},
+			},
+		},
+	}
+	mgr := New(Authenticator{EC2Client: stubber}, ctx)
+
+	event := &LifecycleEvent{EC2InstanceID: "i-111111111111"}
+	if decision := mgr.evaluatePolicy(event); !decision.skip() {
+		t.Fatalf("expected decision of rule: %v, got: %+v", "other-handler", decision)
+	}
+	if event.instanceTags["managed-by"] != "other-handler" {
+		t.Fatalf("expected event to be enriched with its instance tags, got: %v", event.instanceTags)
+	}
+
+	if decision := mgr.evaluatePolicy(&LifecycleEvent{EC2InstanceID: "i-222222222222"}); decision != nil {
+		t.Fatalf("expected decision: %v, got: %+v", nil, decision)
+	}
+}
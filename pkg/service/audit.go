@@ -0,0 +1,64 @@
+package service
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/pkg/errors"
+)
+
+var (
+	// AuditExpiryAttribute is the attribute of audit records holding their expiry time in epoch seconds, for use as the table's TTL attribute
+	AuditExpiryAttribute = "expiresAt"
+)
+
+// AuditLog persists a record of every completed or failed event beyond the lifetime of lifecycle-manager
+type AuditLog interface {
+	// Record persists the record of a completed or failed event
+	Record(record EventRecord) error
+}
+
+// newAuditLog returns the configured audit log, or nil when auditing is disabled
+func newAuditLog(ctx ManagerContext, auth Authenticator) AuditLog {
+	if ctx.AuditTableName == "" {
+		return nil
+	}
+	return &DynamoDBAuditLog{
+		client:    auth.DynamoDBClient,
+		tableName: ctx.AuditTableName,
+		retention: time.Duration(ctx.AuditRetentionDays) * 24 * time.Hour,
+	}
+}
+
+// DynamoDBAuditLog writes an item per event record to a DynamoDB table whose partition key is requestId
+type DynamoDBAuditLog struct {
+	client    dynamodbiface.DynamoDBAPI
+	tableName string
+	// retention sets the expiresAt attribute of items when greater than 0
+	retention time.Duration
+}
+
+func (a *DynamoDBAuditLog) Record(record EventRecord) error {
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit record")
+	}
+
+	if a.retention > 0 {
+		expiresAt := record.EndTime.Add(a.retention).Unix()
+		item[AuditExpiryAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(expiresAt, 10))}
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(a.tableName),
+		Item:      item,
+	}
+	if _, err := a.client.PutItem(input); err != nil {
+		return errors.Wrapf(err, "failed to write audit record to table %v", a.tableName)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+type stubDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	items []*dynamodb.PutItemInput
+	err   error
+}
+
+func (d *stubDynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	d.items = append(d.items, input)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func Test_DynamoDBAuditLog(t *testing.T) {
+	t.Log("Test_DynamoDBAuditLog: should write an item per record with its expiry")
+	stubber := &stubDynamoDB{}
+	auditLog := &DynamoDBAuditLog{client: stubber, tableName: "lifecycle-audit", retention: 24 * time.Hour}
+
+	end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := EventRecord{
+		RequestID:            "123456789",
+		ClusterName:          "my-cluster",
+		InstanceID:           "i-123486890234",
+		Outcome:              HistoryOutcomeFailed,
+		Error:                "drain failed",
+		EndTime:              end,
+		DrainDurationSeconds: 30,
+	}
+	if err := auditLog.Record(record); err != nil {
+		t.Fatalf("Record: expected error not to have occured, %v", err)
+	}
+
+	if len(stubber.items) != 1 {
+		t.Fatalf("expected items: 1, got: %v", len(stubber.items))
+	}
+	input := stubber.items[0]
+	if aws.StringValue(input.TableName) != "lifecycle-audit" {
+		t.Fatalf("expected table: lifecycle-audit, got: %v", aws.StringValue(input.TableName))
+	}
+
+	expected := map[string]string{
+		"requestId":   "123456789",
+		"clusterName": "my-cluster",
+		"outcome":     HistoryOutcomeFailed,
+		"error":       "drain failed",
+	}
+	for k, v := range expected {
+		if got := aws.StringValue(input.Item[k].S); got != v {
+			t.Fatalf("expected %v: %v, got: %v", k, v, got)
+		}
+	}
+	if got := aws.StringValue(input.Item["drainDurationSeconds"].N); got != "30" {
+		t.Fatalf("expected drainDurationSeconds: 30, got: %v", got)
+	}
+	if got := aws.StringValue(input.Item[AuditExpiryAttribute].N); got != "1704153600" {
+		t.Fatalf("expected %v: 1704153600, got: %v", AuditExpiryAttribute, got)
+	}
+}
+
+func Test_DynamoDBAuditLogError(t *testing.T) {
+	t.Log("Test_DynamoDBAuditLogError: should return put item errors and not set an expiry without retention")
+	stubber := &stubDynamoDB{err: errors.New("throttled")}
+	auditLog := &DynamoDBAuditLog{client: stubber, tableName: "lifecycle-audit"}
+
+	if err := auditLog.Record(EventRecord{RequestID: "123456789"}); err == nil {
+		t.Fatalf("Record: expected error to have occured")
+	}
+
+	stubber.err = nil
+	if err := auditLog.Record(EventRecord{RequestID: "123456789"}); err != nil {
+		t.Fatalf("Record: expected error not to have occured, %v", err)
+	}
+	if _, ok := stubber.items[0].Item[AuditExpiryAttribute]; ok {
+		t.Fatalf("expected no %v attribute without retention", AuditExpiryAttribute)
+	}
+}
+
+func Test_RecordEventAudit(t *testing.T) {
+	t.Log("Test_RecordEventAudit: should add completed events to the history and audit log")
+	stubber := &stubDynamoDB{}
+	ctx := _newBasicContext()
+	ctx.ClusterName = "my-cluster"
+	ctx.HistorySize = 10
+	ctx.AuditTableName = "lifecycle-audit"
+	mgr := New(Authenticator{DynamoDBClient: stubber}, ctx)
+
+	event := &LifecycleEvent{RequestID: "123456789", EC2InstanceID: "i-123486890234", startTime: time.Now()}
+	mgr.recordEvent(event, HistoryOutcomeCompleted, nil)
+
+	records := mgr.history.List()
+	if len(records) != 1 || records[0].ClusterName != "my-cluster" {
+		t.Fatalf("expected one record of cluster my-cluster, got: %+v", records)
+	}
+	if len(stubber.items) != 1 {
+		t.Fatalf("expected audit items: 1, got: %v", len(stubber.items))
+	}
+}
@@ -1,49 +1,121 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
 	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
 )
 
-func sendHeartbeat(client autoscalingiface.AutoScalingAPI, event *LifecycleEvent, maxTimeToProcessSeconds int64) {
+var (
+	// HeartbeatRetryAttempts is the number of times a failed heartbeat is retried before heartbeats stop
+	HeartbeatRetryAttempts = 3
+	// HeartbeatRetryDelay is the initial delay between heartbeat retries, it is doubled on every attempt
+	HeartbeatRetryDelay = 2 * time.Second
+)
+
+// sendHeartbeat extends the lifecycle action until the event is completed or its context is cancelled,
+// an error is returned if heartbeats stop before the event is completed
+func sendHeartbeat(client autoscalingiface.AutoScalingAPI, event *LifecycleEvent, maxTimeToProcessSeconds int64, metrics *MetricsServer) error {
 	var (
 		iterationCount      = 0
+		ctx                 = event.Context()
 		interval            = event.heartbeatInterval
 		instanceID          = event.EC2InstanceID
 		scalingGroupName    = event.AutoScalingGroupName
 		recommendedInterval = interval / 2
 	)
 
+	if recommendedInterval < 1 {
+		recommendedInterval = 1
+	}
+
 	log.Debugf("scaling-group = %v, maxInterval = %v, heartbeat = %v", scalingGroupName, interval, recommendedInterval)
 
-	// max time to process an event is capped at 1hr
+	// heartbeats stop once the max time to process the event has passed
 	maxIterations := int(maxTimeToProcessSeconds / recommendedInterval)
 
+	ticker := time.NewTicker(time.Duration(recommendedInterval) * time.Second)
+	defer ticker.Stop()
+
 	for {
 		iterationCount++
 		if iterationCount >= maxIterations {
 			// hard limit in case event is not marked completed
 			log.Warnf("%v> heartbeat extended over threshold, instance will be abandoned", instanceID)
-			event.SetEventCompleted(true)
+			if event.cancel != nil {
+				event.cancel()
+			}
+			return nil
 		}
 
-		if event.eventCompleted {
-			return
+		if event.isCompleted() {
+			return nil
 		}
 
 		log.Infof("%v> sending heartbeat (%v/%v)", instanceID, iterationCount, maxIterations)
-		err := extendLifecycleAction(client, *event)
+		err := extendLifecycleActionWithRetry(ctx, client, event, metrics)
 		if err != nil {
-			log.Errorf("%v> failed to send heartbeat for event: %v", instanceID, err)
-			return
+			if event.isCompleted() {
+				return nil
+			}
+			return errors.Wrap(err, "heartbeats stopped")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// extendLifecycleActionWithRetry sends a heartbeat and retries transient errors with an exponential backoff, every
+// attempt and failed attempt is counted
+func extendLifecycleActionWithRetry(ctx context.Context, client autoscalingiface.AutoScalingAPI, event *LifecycleEvent, metrics *MetricsServer) error {
+	var (
+		err   error
+		delay = HeartbeatRetryDelay
+	)
+
+	for attempt := 0; attempt <= HeartbeatRetryAttempts; attempt++ {
+		if attempt > 0 {
+			log.Warnf("%v> failed to send heartbeat, retrying in %v (%v/%v): %v", event.EC2InstanceID, delay, attempt, HeartbeatRetryAttempts, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		metrics.AddCounter(HeartbeatAttemptsTotalMetric, eventLabels(event), 1)
+		err = extendLifecycleAction(ctx, client, event.AutoScalingGroupName, event.EC2InstanceID, event.LifecycleActionToken, event.LifecycleHookName)
+		if err == nil {
+			event.setLastHeartbeat(time.Now())
+			return nil
+		}
+		metrics.AddCounter(HeartbeatFailuresTotalMetric, eventLabels(event), 1)
+
+		if !isRetryableHeartbeatError(err) {
+			return err
 		}
-		time.Sleep(time.Duration(recommendedInterval) * time.Second)
 	}
+	return err
+}
+
+// isRetryableHeartbeatError returns false for validation errors, which indicate the lifecycle action no longer exists
+func isRetryableHeartbeatError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() != "ValidationError"
+	}
+	return true
 }
 
 func getHookHeartbeatInterval(client autoscalingiface.AutoScalingAPI, lifecycleHookName, scalingGroupName string) (int64, error) {
@@ -64,7 +136,132 @@ func getHookHeartbeatInterval(client autoscalingiface.AutoScalingAPI, lifecycleH
 	return aws.Int64Value(out.LifecycleHooks[0].HeartbeatTimeout), nil
 }
 
-func completeLifecycleAction(client autoscalingiface.AutoScalingAPI, event LifecycleEvent, result string) error {
+func getScalingGroupTags(client autoscalingiface.AutoScalingAPI, scalingGroupName string) (map[string]string, error) {
+	tags := make(map[string]string)
+	input := &autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: aws.StringSlice([]string{scalingGroupName}),
+	}
+	out, err := client.DescribeAutoScalingGroups(input)
+	if err != nil {
+		return tags, err
+	}
+
+	if len(out.AutoScalingGroups) == 0 {
+		err = fmt.Errorf("could not find scaling group %v", scalingGroupName)
+		return tags, err
+	}
+
+	for _, tag := range out.AutoScalingGroups[0].Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
+
+func getScalingGroupTargetGroups(ctx context.Context, client autoscalingiface.AutoScalingAPI, scalingGroupName string) ([]string, error) {
+	arns := []string{}
+	input := &autoscaling.DescribeLoadBalancerTargetGroupsInput{
+		AutoScalingGroupName: aws.String(scalingGroupName),
+	}
+	err := client.DescribeLoadBalancerTargetGroupsPagesWithContext(ctx, input, func(page *autoscaling.DescribeLoadBalancerTargetGroupsOutput, lastPage bool) bool {
+		for _, state := range page.LoadBalancerTargetGroups {
+			arns = append(arns, aws.StringValue(state.LoadBalancerTargetGroupARN))
+		}
+		return page.NextToken != nil
+	})
+	return arns, err
+}
+
+func getScalingGroupClassicBalancers(ctx context.Context, client autoscalingiface.AutoScalingAPI, scalingGroupName string) ([]string, error) {
+	names := []string{}
+	input := &autoscaling.DescribeLoadBalancersInput{
+		AutoScalingGroupName: aws.String(scalingGroupName),
+	}
+	err := client.DescribeLoadBalancersPagesWithContext(ctx, input, func(page *autoscaling.DescribeLoadBalancersOutput, lastPage bool) bool {
+		for _, state := range page.LoadBalancers {
+			names = append(names, aws.StringValue(state.LoadBalancerName))
+		}
+		return page.NextToken != nil
+	})
+	return names, err
+}
+
+// getWaitingInstances returns the instances in a Terminating:Wait state mapped to their scaling group
+func getWaitingInstances(client autoscalingiface.AutoScalingAPI) (map[string][]string, error) {
+	waiting := make(map[string][]string)
+	err := client.DescribeAutoScalingGroupsPages(&autoscaling.DescribeAutoScalingGroupsInput{}, func(page *autoscaling.DescribeAutoScalingGroupsOutput, lastPage bool) bool {
+		for _, group := range page.AutoScalingGroups {
+			for _, instance := range group.Instances {
+				if aws.StringValue(instance.LifecycleState) == autoscaling.LifecycleStateTerminatingWait {
+					name := aws.StringValue(group.AutoScalingGroupName)
+					waiting[name] = append(waiting[name], aws.StringValue(instance.InstanceId))
+				}
+			}
+		}
+		return page.NextToken != nil
+	})
+	return waiting, err
+}
+
+// getInstanceScalingGroup returns the scaling group of an instance, false is returned if the instance is not part of one
+func getInstanceScalingGroup(client autoscalingiface.AutoScalingAPI, instanceID string) (string, bool, error) {
+	out, err := client.DescribeAutoScalingInstances(&autoscaling.DescribeAutoScalingInstancesInput{
+		InstanceIds: aws.StringSlice([]string{instanceID}),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	if len(out.AutoScalingInstances) == 0 {
+		return "", false, nil
+	}
+	return aws.StringValue(out.AutoScalingInstances[0].AutoScalingGroupName), true, nil
+}
+
+// getInstanceLifecycleState returns the lifecycle state of an instance in its scaling group, false is returned if the
+// instance is not part of one, as is the case once it terminated
+func getInstanceLifecycleState(client autoscalingiface.AutoScalingAPI, instanceID string) (string, bool, error) {
+	out, err := client.DescribeAutoScalingInstances(&autoscaling.DescribeAutoScalingInstancesInput{
+		InstanceIds: aws.StringSlice([]string{instanceID}),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	if len(out.AutoScalingInstances) == 0 {
+		return "", false, nil
+	}
+	return aws.StringValue(out.AutoScalingInstances[0].LifecycleState), true, nil
+}
+
+// terminateInstance terminates an instance through its scaling group, which runs the termination hooks of the group
+func terminateInstance(client autoscalingiface.AutoScalingAPI, instanceID string, decrementCapacity bool) error {
+	log.Infof("%v> terminating instance in scaling group, decrementing desired capacity: %v", instanceID, decrementCapacity)
+	_, err := client.TerminateInstanceInAutoScalingGroup(&autoscaling.TerminateInstanceInAutoScalingGroupInput{
+		InstanceId:                     aws.String(instanceID),
+		ShouldDecrementDesiredCapacity: aws.Bool(decrementCapacity),
+	})
+	return err
+}
+
+// getQueueTerminationHook returns the scaling group's termination hook which notifies the queue
+func getQueueTerminationHook(client autoscalingiface.AutoScalingAPI, scalingGroupName, queueARN string) (*autoscaling.LifecycleHook, bool, error) {
+	input := &autoscaling.DescribeLifecycleHooksInput{
+		AutoScalingGroupName: aws.String(scalingGroupName),
+	}
+	out, err := client.DescribeLifecycleHooks(input)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, hook := range out.LifecycleHooks {
+		if aws.StringValue(hook.LifecycleTransition) == TerminationEventName && aws.StringValue(hook.NotificationTargetARN) == queueARN {
+			return hook, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// completeLifecycleAction is not bound to the event's context, which is already done once an event fails or
+// exceeds its max time to process, so that the lifecycle action is still completed or abandoned
+func completeLifecycleAction(client autoscalingiface.AutoScalingAPI, event *LifecycleEvent, result string) error {
 	log.Infof("%v> setting lifecycle event as completed with result: %v", event.EC2InstanceID, result)
 	input := &autoscaling.CompleteLifecycleActionInput{
 		AutoScalingGroupName:  aws.String(event.AutoScalingGroupName),
@@ -79,15 +276,17 @@ func completeLifecycleAction(client autoscalingiface.AutoScalingAPI, event Lifec
 	return nil
 }
 
-func extendLifecycleAction(client autoscalingiface.AutoScalingAPI, event LifecycleEvent) error {
-	log.Debugf("%v> extending lifecycle event", event.EC2InstanceID)
+// extendLifecycleAction records a heartbeat of a lifecycle action, it is called from the heartbeat goroutine of an event
+// and only takes the identifiers of the action, which do not change while the event is processed
+func extendLifecycleAction(ctx context.Context, client autoscalingiface.AutoScalingAPI, scalingGroupName, instanceID, token, hookName string) error {
+	log.Debugf("%v> extending lifecycle event", instanceID)
 	input := &autoscaling.RecordLifecycleActionHeartbeatInput{
-		AutoScalingGroupName: aws.String(event.AutoScalingGroupName),
-		InstanceId:           aws.String(event.EC2InstanceID),
-		LifecycleActionToken: aws.String(event.LifecycleActionToken),
-		LifecycleHookName:    aws.String(event.LifecycleHookName),
+		AutoScalingGroupName: aws.String(scalingGroupName),
+		InstanceId:           aws.String(instanceID),
+		LifecycleActionToken: aws.String(token),
+		LifecycleHookName:    aws.String(hookName),
 	}
-	_, err := client.RecordLifecycleActionHeartbeat(input)
+	_, err := client.RecordLifecycleActionHeartbeatWithContext(ctx, input)
 	if err != nil {
 		return err
 	}
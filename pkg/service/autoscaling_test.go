@@ -1,20 +1,41 @@
 package service
 
 import (
+	"context"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 type stubAutoscaling struct {
 	autoscalingiface.AutoScalingAPI
 	lifecycleHooks                            []*autoscaling.LifecycleHook
+	scalingGroups                             []*autoscaling.Group
+	attachedTargetGroups                      []*autoscaling.LoadBalancerTargetGroupState
+	attachedLoadBalancers                     []*autoscaling.LoadBalancerState
 	timesCalledDescribeLifecycleHooks         int
+	timesCalledDescribeAutoScalingGroups      int
 	timesCalledRecordLifecycleActionHeartbeat int
+	heartbeatErrors                           []error
 	timesCalledCompleteLifecycleAction        int
+	instanceRefreshes                         []*autoscaling.InstanceRefresh
+	autoScalingInstances                      []*autoscaling.InstanceDetails
+	terminatedInstances                       []*autoscaling.TerminateInstanceInAutoScalingGroupInput
+	protectedInstances                        map[string]bool
+	// heartbeatLock guards the heartbeats, which are sent concurrently by the events sharing the stub
+	heartbeatLock sync.Mutex
+}
+
+func (a *stubAutoscaling) DescribeInstanceRefreshes(input *autoscaling.DescribeInstanceRefreshesInput) (*autoscaling.DescribeInstanceRefreshesOutput, error) {
+	return &autoscaling.DescribeInstanceRefreshesOutput{InstanceRefreshes: a.instanceRefreshes}, nil
 }
 
 func (a *stubAutoscaling) DescribeLifecycleHooks(input *autoscaling.DescribeLifecycleHooksInput) (*autoscaling.DescribeLifecycleHooksOutput, error) {
@@ -22,19 +43,92 @@ func (a *stubAutoscaling) DescribeLifecycleHooks(input *autoscaling.DescribeLife
 	return &autoscaling.DescribeLifecycleHooksOutput{LifecycleHooks: a.lifecycleHooks}, nil
 }
 
+func (a *stubAutoscaling) DescribeAutoScalingGroups(input *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	a.timesCalledDescribeAutoScalingGroups++
+	return &autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: a.scalingGroups}, nil
+}
+
+func (a *stubAutoscaling) DescribeAutoScalingGroupsPages(input *autoscaling.DescribeAutoScalingGroupsInput, callback func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	page, err := a.DescribeAutoScalingGroups(input)
+	if err != nil {
+		return err
+	}
+	callback(page, true)
+	return nil
+}
+
+func (a *stubAutoscaling) DescribeLoadBalancerTargetGroupsPages(input *autoscaling.DescribeLoadBalancerTargetGroupsInput, callback func(*autoscaling.DescribeLoadBalancerTargetGroupsOutput, bool) bool) error {
+	callback(&autoscaling.DescribeLoadBalancerTargetGroupsOutput{LoadBalancerTargetGroups: a.attachedTargetGroups}, true)
+	return nil
+}
+
+func (a *stubAutoscaling) DescribeLoadBalancersPages(input *autoscaling.DescribeLoadBalancersInput, callback func(*autoscaling.DescribeLoadBalancersOutput, bool) bool) error {
+	callback(&autoscaling.DescribeLoadBalancersOutput{LoadBalancers: a.attachedLoadBalancers}, true)
+	return nil
+}
+
+func (a *stubAutoscaling) DescribeLoadBalancerTargetGroupsPagesWithContext(ctx aws.Context, input *autoscaling.DescribeLoadBalancerTargetGroupsInput, callback func(*autoscaling.DescribeLoadBalancerTargetGroupsOutput, bool) bool, opts ...request.Option) error {
+	return a.DescribeLoadBalancerTargetGroupsPages(input, callback)
+}
+
+func (a *stubAutoscaling) DescribeLoadBalancersPagesWithContext(ctx aws.Context, input *autoscaling.DescribeLoadBalancersInput, callback func(*autoscaling.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	return a.DescribeLoadBalancersPages(input, callback)
+}
+
 func (a *stubAutoscaling) RecordLifecycleActionHeartbeat(input *autoscaling.RecordLifecycleActionHeartbeatInput) (*autoscaling.RecordLifecycleActionHeartbeatOutput, error) {
+	a.heartbeatLock.Lock()
+	defer a.heartbeatLock.Unlock()
 	a.timesCalledRecordLifecycleActionHeartbeat++
+	if len(a.heartbeatErrors) > 0 {
+		err := a.heartbeatErrors[0]
+		a.heartbeatErrors = a.heartbeatErrors[1:]
+		return &autoscaling.RecordLifecycleActionHeartbeatOutput{}, err
+	}
 	return &autoscaling.RecordLifecycleActionHeartbeatOutput{}, nil
 }
 
+func (a *stubAutoscaling) RecordLifecycleActionHeartbeatWithContext(ctx aws.Context, input *autoscaling.RecordLifecycleActionHeartbeatInput, opts ...request.Option) (*autoscaling.RecordLifecycleActionHeartbeatOutput, error) {
+	return a.RecordLifecycleActionHeartbeat(input)
+}
+
 func (a *stubAutoscaling) CompleteLifecycleAction(input *autoscaling.CompleteLifecycleActionInput) (*autoscaling.CompleteLifecycleActionOutput, error) {
 	a.timesCalledCompleteLifecycleAction++
 	return &autoscaling.CompleteLifecycleActionOutput{}, nil
 }
 
-func (e *LifecycleEvent) _setEventCompletedAfter(value bool, seconds int64) {
+func (a *stubAutoscaling) DescribeAutoScalingInstances(input *autoscaling.DescribeAutoScalingInstancesInput) (*autoscaling.DescribeAutoScalingInstancesOutput, error) {
+	if len(input.InstanceIds) == 0 {
+		return &autoscaling.DescribeAutoScalingInstancesOutput{AutoScalingInstances: a.autoScalingInstances}, nil
+	}
+	instances := []*autoscaling.InstanceDetails{}
+	for _, instance := range a.autoScalingInstances {
+		for _, instanceID := range input.InstanceIds {
+			if aws.StringValue(instance.InstanceId) == aws.StringValue(instanceID) {
+				instances = append(instances, instance)
+			}
+		}
+	}
+	return &autoscaling.DescribeAutoScalingInstancesOutput{AutoScalingInstances: instances}, nil
+}
+
+func (a *stubAutoscaling) SetInstanceProtection(input *autoscaling.SetInstanceProtectionInput) (*autoscaling.SetInstanceProtectionOutput, error) {
+	if a.protectedInstances == nil {
+		a.protectedInstances = make(map[string]bool)
+	}
+	for _, instanceID := range input.InstanceIds {
+		a.protectedInstances[aws.StringValue(instanceID)] = aws.BoolValue(input.ProtectedFromScaleIn)
+	}
+	return &autoscaling.SetInstanceProtectionOutput{}, nil
+}
+
+func (a *stubAutoscaling) TerminateInstanceInAutoScalingGroup(input *autoscaling.TerminateInstanceInAutoScalingGroupInput) (*autoscaling.TerminateInstanceInAutoScalingGroupOutput, error) {
+	a.terminatedInstances = append(a.terminatedInstances, input)
+	return &autoscaling.TerminateInstanceInAutoScalingGroupOutput{}, nil
+}
+
+func (e *LifecycleEvent) _setPhaseAfter(phase EventPhase, seconds int64) {
 	time.Sleep(time.Duration(seconds)*time.Second + time.Duration(500)*time.Millisecond)
-	e.eventCompleted = value
+	e.setPhase(phase)
 }
 
 func Test_SendHeartbeatPositive(t *testing.T) {
@@ -45,13 +139,12 @@ func Test_SendHeartbeatPositive(t *testing.T) {
 		EC2InstanceID:        "i-1234567890",
 		LifecycleActionToken: "some-token-1234",
 		LifecycleHookName:    "my-hook",
-		drainCompleted:       false,
 		heartbeatInterval:    3,
 	}
 	maxTimeToProcessSeconds := int64(3600)
 
-	go event._setEventCompletedAfter(true, 2)
-	sendHeartbeat(stubber, event, maxTimeToProcessSeconds)
+	go event._setPhaseAfter(PhaseDone, 2)
+	sendHeartbeat(stubber, event, maxTimeToProcessSeconds, &MetricsServer{})
 	expectedHeartbeatCalls := 3
 
 	if stubber.timesCalledRecordLifecycleActionHeartbeat != expectedHeartbeatCalls {
@@ -67,12 +160,12 @@ func Test_SendHeartbeatNegative(t *testing.T) {
 		EC2InstanceID:        "i-1234567890",
 		LifecycleActionToken: "some-token-1234",
 		LifecycleHookName:    "my-hook",
-		eventCompleted:       true,
+		phase:                PhaseDone,
 		heartbeatInterval:    3,
 	}
 	maxTimeToProcessSeconds := int64(3600)
 
-	sendHeartbeat(stubber, event, maxTimeToProcessSeconds)
+	sendHeartbeat(stubber, event, maxTimeToProcessSeconds, &MetricsServer{})
 	expectedHeartbeatCalls := 0
 
 	if stubber.timesCalledRecordLifecycleActionHeartbeat != expectedHeartbeatCalls {
@@ -88,11 +181,11 @@ func Test_CompleteLifecycleAction(t *testing.T) {
 		EC2InstanceID:        "i-1234567890",
 		LifecycleActionToken: "some-token-1234",
 		LifecycleHookName:    "my-hook",
-		drainCompleted:       true,
+		phase:                PhaseCompleting,
 	}
 
-	completeLifecycleAction(stubber, *event, ContinueAction)
-	completeLifecycleAction(stubber, *event, AbandonAction)
+	completeLifecycleAction(stubber, event, ContinueAction)
+	completeLifecycleAction(stubber, event, AbandonAction)
 	expectedCalls := 2
 
 	if stubber.timesCalledCompleteLifecycleAction != expectedCalls {
@@ -100,6 +193,83 @@ func Test_CompleteLifecycleAction(t *testing.T) {
 	}
 }
 
+func Test_SendHeartbeatContextCancelled(t *testing.T) {
+	t.Log("Test_SendHeartbeatContextCancelled: should stop sending heartbeats once the event context is cancelled")
+	stubber := &stubAutoscaling{}
+	event := &LifecycleEvent{
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-1234567890",
+		LifecycleActionToken: "some-token-1234",
+		LifecycleHookName:    "my-hook",
+		heartbeatInterval:    60,
+	}
+	event.SetContext(context.WithCancel(context.Background()))
+
+	go _completeEventAfter(event, time.Millisecond*100)
+	err := sendHeartbeat(stubber, event, 3600, &MetricsServer{})
+	if err != nil {
+		t.Fatalf("sendHeartbeat: expected error not to have occured, %v", err)
+	}
+
+	expectedHeartbeatCalls := 1
+	if stubber.timesCalledRecordLifecycleActionHeartbeat != expectedHeartbeatCalls {
+		t.Fatalf("expected timesCalledRecordLifecycleActionHeartbeat: %v, got: %v", expectedHeartbeatCalls, stubber.timesCalledRecordLifecycleActionHeartbeat)
+	}
+}
+
+func Test_SendHeartbeatRetry(t *testing.T) {
+	t.Log("Test_SendHeartbeatRetry: should retry transient heartbeat errors and stop on validation errors")
+	stubber := &stubAutoscaling{
+		heartbeatErrors: []error{
+			awserr.New("Throttling", "Rate exceeded", nil),
+			awserr.New("Throttling", "Rate exceeded", nil),
+		},
+	}
+	event := &LifecycleEvent{
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-1234567890",
+		LifecycleActionToken: "some-token-1234",
+		LifecycleHookName:    "my-hook",
+	}
+
+	metrics := &MetricsServer{
+		Counters: map[string]*prometheus.CounterVec{
+			HeartbeatAttemptsTotalMetric: prometheus.NewCounterVec(prometheus.CounterOpts{Name: HeartbeatAttemptsTotalMetric}, ScalingGroupLabels),
+			HeartbeatFailuresTotalMetric: prometheus.NewCounterVec(prometheus.CounterOpts{Name: HeartbeatFailuresTotalMetric}, ScalingGroupLabels),
+		},
+	}
+
+	err := extendLifecycleActionWithRetry(context.Background(), stubber, event, metrics)
+	if err != nil {
+		t.Fatalf("extendLifecycleActionWithRetry: expected error not to have occured, %v", err)
+	}
+
+	expectedHeartbeatCalls := 3
+	if stubber.timesCalledRecordLifecycleActionHeartbeat != expectedHeartbeatCalls {
+		t.Fatalf("expected timesCalledRecordLifecycleActionHeartbeat: %v, got: %v", expectedHeartbeatCalls, stubber.timesCalledRecordLifecycleActionHeartbeat)
+	}
+	attempts := testutil.ToFloat64(metrics.Counters[HeartbeatAttemptsTotalMetric].With(eventLabels(event)))
+	failures := testutil.ToFloat64(metrics.Counters[HeartbeatFailuresTotalMetric].With(eventLabels(event)))
+	if attempts != 3 || failures != 2 {
+		t.Fatalf("expected heartbeat attempts/failures: 3/2, got: %v/%v", attempts, failures)
+	}
+	if age, ok := event.heartbeatAge(); !ok || age > time.Second {
+		t.Fatalf("expected last heartbeat to be recorded, got age: %v", age)
+	}
+
+	stubber.heartbeatErrors = []error{awserr.New("ValidationError", "No active Lifecycle Action found with token", nil)}
+	event.heartbeatInterval = 2
+	err = sendHeartbeat(stubber, event, 3600, &MetricsServer{})
+	if err == nil {
+		t.Fatal("sendHeartbeat: expected error to have occured")
+	}
+
+	expectedHeartbeatCalls = 4
+	if stubber.timesCalledRecordLifecycleActionHeartbeat != expectedHeartbeatCalls {
+		t.Fatalf("expected timesCalledRecordLifecycleActionHeartbeat: %v, got: %v", expectedHeartbeatCalls, stubber.timesCalledRecordLifecycleActionHeartbeat)
+	}
+}
+
 func Test_GetHookHeartbeatIntervalPositive(t *testing.T) {
 	t.Log("Test_GetHookHeartbeatIntervalPositive: should be able get a lifecycle hook's heartbeat timeout interval if it exists")
 	stubber := &stubAutoscaling{
@@ -115,7 +285,7 @@ func Test_GetHookHeartbeatIntervalPositive(t *testing.T) {
 		EC2InstanceID:        "i-1234567890",
 		LifecycleActionToken: "some-token-1234",
 		LifecycleHookName:    "my-hook",
-		drainCompleted:       true,
+		phase:                PhaseCompleting,
 	}
 
 	interval, err := getHookHeartbeatInterval(stubber, event.AutoScalingGroupName, event.LifecycleHookName)
@@ -145,7 +315,7 @@ func Test_GetHookHeartbeatIntervalNegative(t *testing.T) {
 		EC2InstanceID:        "i-1234567890",
 		LifecycleActionToken: "some-token-1234",
 		LifecycleHookName:    "my-hook",
-		drainCompleted:       true,
+		phase:                PhaseCompleting,
 	}
 
 	interval, err := getHookHeartbeatInterval(stubber, event.AutoScalingGroupName, event.LifecycleHookName)
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/keikoproj/aws-sdk-go-cache/cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	AWSAPICallsTotalMetric          = "aws_api_calls_total"
+	AWSAPIErrorsTotalMetric         = "aws_api_errors_total"
+	AWSAPIThrottlesTotalMetric      = "aws_api_throttles_total"
+	AWSAPICallDurationSecondsMetric = "aws_api_call_duration_seconds"
+)
+
+var (
+	awsAPICalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      AWSAPICallsTotalMetric,
+		Help:      "indicates the sum of all AWS API call attempts which were not served from cache.",
+	}, []string{"service", "operation"})
+
+	awsAPIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      AWSAPIErrorsTotalMetric,
+		Help:      "indicates the sum of all AWS API call attempts which returned an error.",
+	}, []string{"service", "operation", "code"})
+
+	awsAPIThrottles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      AWSAPIThrottlesTotalMetric,
+		Help:      "indicates the sum of all AWS API call attempts which were throttled.",
+	}, []string{"service", "operation"})
+
+	awsAPIDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      AWSAPICallDurationSecondsMetric,
+		Help:      "indicates the latency of AWS API call attempts in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "operation"})
+)
+
+// AddAPIMetrics instruments an AWS session to record call counts, latencies, errors and throttles
+func AddAPIMetrics(sess *session.Session) {
+	sess.Handlers.CompleteAttempt.PushBack(recordAPICall)
+}
+
+func recordAPICall(r *request.Request) {
+	if r.HTTPRequest != nil && cache.IsCacheHit(r.HTTPRequest.Context()) {
+		return
+	}
+
+	var (
+		service   = r.ClientInfo.ServiceName
+		operation = r.Operation.Name
+	)
+
+	awsAPICalls.WithLabelValues(service, operation).Inc()
+	if !r.AttemptTime.IsZero() {
+		awsAPIDuration.WithLabelValues(service, operation).Observe(time.Since(r.AttemptTime).Seconds())
+	}
+
+	if r.Error == nil {
+		return
+	}
+
+	code := "Unknown"
+	if awsErr, ok := r.Error.(awserr.Error); ok {
+		code = awsErr.Code()
+	}
+	awsAPIErrors.WithLabelValues(service, operation, code).Inc()
+
+	if r.IsErrorThrottle() {
+		awsAPIThrottles.WithLabelValues(service, operation).Inc()
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func Test_RecordAPICall(t *testing.T) {
+	t.Log("Test_RecordAPICall: should record calls, errors and throttles per service and operation")
+	httpReq, _ := http.NewRequest("POST", "https://elasticloadbalancing.us-west-2.amazonaws.com", nil)
+	newRequest := func(err error) *request.Request {
+		return &request.Request{
+			ClientInfo:  metadata.ClientInfo{ServiceName: "elasticloadbalancing"},
+			Operation:   &request.Operation{Name: "DescribeTargetHealth"},
+			HTTPRequest: httpReq,
+			AttemptTime: time.Now(),
+			Error:       err,
+		}
+	}
+
+	recordAPICall(newRequest(nil))
+	recordAPICall(newRequest(awserr.New("Throttling", "Rate exceeded", nil)))
+
+	calls := testutil.ToFloat64(awsAPICalls.WithLabelValues("elasticloadbalancing", "DescribeTargetHealth"))
+	if calls != 2 {
+		t.Fatalf("expected calls: %v, got: %v", 2, calls)
+	}
+
+	errs := testutil.ToFloat64(awsAPIErrors.WithLabelValues("elasticloadbalancing", "DescribeTargetHealth", "Throttling"))
+	if errs != 1 {
+		t.Fatalf("expected errors: %v, got: %v", 1, errs)
+	}
+
+	throttles := testutil.ToFloat64(awsAPIThrottles.WithLabelValues("elasticloadbalancing", "DescribeTargetHealth"))
+	if throttles != 1 {
+		t.Fatalf("expected throttles: %v, got: %v", 1, throttles)
+	}
+}
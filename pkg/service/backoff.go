@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	// jitterRand is the random source of backoff jitter, it is seeded once and shared between goroutines
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	jitterLock sync.Mutex
+
+	// jitterFloat64 returns a random number in [0.0, 1.0) to randomize backoff delays, tests replace it to make delays
+	// deterministic
+	jitterFloat64 = func() float64 {
+		jitterLock.Lock()
+		defer jitterLock.Unlock()
+		return jitterRand.Float64()
+	}
+)
+
+// Backoff computes the delays between the attempts of an operation, the delay grows exponentially from the initial
+// delay by a factor up to a max delay, and is shortened by a random jitter so that goroutines started together do not
+// retry in lockstep
+type Backoff struct {
+	// Initial is the delay before the first retry
+	Initial time.Duration
+	// Max caps the delay, it is unbounded when 0
+	Max time.Duration
+	// Factor multiplies the delay on every attempt, the delay is constant for a factor of 1 or lower
+	Factor float64
+	// Jitter is the fraction of the delay which is randomized, 0 disables jitter and 1 waits anywhere between 0 and
+	// the full delay
+	Jitter float64
+}
+
+// Delay returns the delay before an attempt, counted from 0
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Initial)
+	if b.Factor > 1 && attempt > 0 {
+		delay *= math.Pow(b.Factor, float64(attempt))
+	}
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	return withJitter(time.Duration(delay), b.Jitter)
+}
+
+// Wait waits for the delay before an attempt, it returns false when the context is done meanwhile
+func (b Backoff) Wait(ctx context.Context, attempt int) bool {
+	delay := b.Delay(attempt)
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	return sleep(ctx, delay)
+}
+
+// withJitter shortens a delay by a random fraction of up to jitter, jitter is bound to [0, 1]
+func withJitter(delay time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 || delay <= 0 {
+		return delay
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+	return delay - time.Duration(jitter*jitterFloat64()*float64(delay))
+}
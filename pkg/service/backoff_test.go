@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_BackoffDelay(t *testing.T) {
+	t.Log("Test_BackoffDelay: should grow delays exponentially up to the max delay and shorten them by the jitter")
+	random := jitterFloat64
+	jitterFloat64 = func() float64 { return 0.5 }
+	defer func() { jitterFloat64 = random }()
+
+	tests := []struct {
+		backoff  Backoff
+		attempt  int
+		expected time.Duration
+	}{
+		{Backoff{Initial: time.Second, Factor: 2}, 0, time.Second},
+		{Backoff{Initial: time.Second, Factor: 2}, 3, 8 * time.Second},
+		{Backoff{Initial: time.Second, Max: 5 * time.Second, Factor: 2}, 3, 5 * time.Second},
+		{Backoff{Initial: time.Second}, 3, time.Second},
+		{Backoff{Initial: 4 * time.Second, Factor: 2, Jitter: 0.5}, 1, 6 * time.Second},
+		{Backoff{Initial: 4 * time.Second, Jitter: 2}, 0, 2 * time.Second},
+		{Backoff{}, 5, 0},
+	}
+
+	for _, tc := range tests {
+		if delay := tc.backoff.Delay(tc.attempt); delay != tc.expected {
+			t.Fatalf("expected delay of %+v attempt %v: %v, got: %v", tc.backoff, tc.attempt, tc.expected, delay)
+		}
+	}
+}
+
+func Test_BackoffWait(t *testing.T) {
+	t.Log("Test_BackoffWait: should stop waiting once the context is done")
+	backoff := Backoff{Initial: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if backoff.Wait(ctx, 0) {
+		t.Fatal("expected wait to be cut short by the cancelled context")
+	}
+	if (Backoff{}).Wait(ctx, 0) {
+		t.Fatal("expected wait without delay to report the cancelled context")
+	}
+	if !(Backoff{}).Wait(context.Background(), 0) {
+		t.Fatal("expected wait without delay to return right away")
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/keikoproj/lifecycle-manager/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	BuildInfoMetric = "build_info"
+)
+
+var (
+	// VersionEndpoint is the endpoint of the metrics server serving the version of lifecycle-manager
+	VersionEndpoint = "/version"
+
+	buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      BuildInfoMetric,
+		Help:      "indicates the version of lifecycle-manager, the value is always 1.",
+	}, []string{"version", "commit", "build_date", "go_version"})
+)
+
+// VersionInfo is the payload served on the version endpoint
+type VersionInfo struct {
+	Version     string `json:"version"`
+	GitCommit   string `json:"gitCommit"`
+	BuildDate   string `json:"buildDate"`
+	GoVersion   string `json:"goVersion"`
+	OsArch      string `json:"osArch"`
+	ClusterName string `json:"clusterName,omitempty"`
+}
+
+func newVersionInfo(clusterName string) VersionInfo {
+	return VersionInfo{
+		Version:     version.Version,
+		GitCommit:   version.GitCommit,
+		BuildDate:   version.BuildDate,
+		GoVersion:   version.GoVersion,
+		OsArch:      version.OsArch,
+		ClusterName: clusterName,
+	}
+}
+
+// setBuildInfo sets the build info gauge of the running version
+func setBuildInfo() {
+	buildInfo.With(prometheus.Labels{
+		"version":    version.Version,
+		"commit":     version.GitCommit,
+		"build_date": version.BuildDate,
+		"go_version": version.GoVersion,
+	}).Set(1)
+}
+
+// versionHandler serves the version of lifecycle-manager as JSON
+func versionHandler(clusterName string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newVersionInfo(clusterName)); err != nil {
+			log.Errorf("failed to serve version: %v", err)
+		}
+	})
+}
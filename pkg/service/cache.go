@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/keikoproj/aws-sdk-go-cache/cache"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// CacheBackendMemory caches AWS API responses in the memory of each replica
+	CacheBackendMemory = "memory"
+	// CacheBackendRedis caches AWS API responses in redis, shared by all replicas
+	CacheBackendRedis = "redis"
+)
+
+var (
+	// CacheFlushEndpoint is the endpoint of the metrics server flushing the AWS API caches on demand
+	CacheFlushEndpoint = "/cache/flush"
+)
+
+// ResponseCache caches the responses of the describe calls made through the AWS sessions it is added to, caches are
+// named service.operation
+type ResponseCache interface {
+	AddCaching(sess *session.Session)
+	SetCacheTTL(serviceName, operationName string, ttl time.Duration)
+	SetCacheMutating(serviceName, operationName string, isMutating bool)
+	// FlushCache flushes the caches whose name starts with prefix, an empty prefix flushes all caches
+	FlushCache(prefix string)
+	// NewCacheCollector returns the prometheus collector of the cache hits, misses and flushes
+	NewCacheCollector(namespace string) prometheus.Collector
+}
+
+// MemoryCache is a ResponseCache local to the replica
+type MemoryCache struct {
+	*cache.Config
+}
+
+// NewMemoryCache returns an in-memory ResponseCache
+func NewMemoryCache(cfg *cache.Config) *MemoryCache {
+	return &MemoryCache{Config: cfg}
+}
+
+func (c *MemoryCache) AddCaching(sess *session.Session) {
+	cache.AddCaching(sess, c.Config)
+}
+
+// IsCacheHit returns true if the response of a request was served by a ResponseCache
+func IsCacheHit(ctx context.Context) bool {
+	return cache.IsCacheHit(ctx) || isRedisCacheHit(ctx)
+}
+
+// CacheFlushResult is the response of the cache flush endpoint
+type CacheFlushResult struct {
+	Flushed string `json:"flushed"`
+}
+
+// cacheFlushPrefix returns the prefix of the caches to flush for a service and operation, caches are named
+// service.operation and an empty service flushes all caches
+func cacheFlushPrefix(serviceName, operationName string) string {
+	if operationName == "" {
+		return serviceName
+	}
+	return serviceName + "." + operationName
+}
+
+// cacheFlushHandler flushes the AWS API caches matching the service and operation query parameters on POST
+func (mgr *Manager) cacheFlushHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if mgr.context.CacheConfig == nil {
+			http.Error(w, "caching is not enabled", http.StatusNotFound)
+			return
+		}
+
+		serviceName := strings.TrimSpace(r.URL.Query().Get("service"))
+		operationName := strings.TrimSpace(r.URL.Query().Get("operation"))
+		if serviceName == "" && operationName != "" {
+			http.Error(w, "operation requires a service", http.StatusBadRequest)
+			return
+		}
+
+		prefix := cacheFlushPrefix(serviceName, operationName)
+		mgr.context.CacheConfig.FlushCache(prefix)
+		if prefix == "" {
+			prefix = "*"
+		}
+		log.Infof("flushed AWS API caches matching %v on demand", prefix)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(CacheFlushResult{Flushed: prefix}); err != nil {
+			log.Errorf("failed to serve cache flush result: %v", err)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/keikoproj/aws-sdk-go-cache/cache"
+)
+
+func Test_CacheFlushHandler(t *testing.T) {
+	t.Log("Test_CacheFlushHandler: should flush the cached target health of an operation on demand")
+	var calls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<DescribeTargetHealthResponse><DescribeTargetHealthResult><TargetHealthDescriptions/></DescribeTargetHealthResult></DescribeTargetHealthResponse>`))
+	}))
+	defer api.Close()
+
+	cacheCfg := cache.NewConfig(0, time.Hour, 100, 10)
+	cacheCfg.SetCacheTTL("elasticloadbalancing", "DescribeTargetHealth", time.Minute)
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-west-2"),
+		Endpoint:    aws.String(api.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	cache.AddCaching(sess, cacheCfg)
+	elbClient := elbv2.New(sess)
+
+	describe := func() {
+		if _, err := elbClient.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{TargetGroupArn: aws.String("arn")}); err != nil {
+			t.Fatalf("failed to describe target health: %v", err)
+		}
+	}
+
+	ctx := _newBasicContext()
+	ctx.CacheConfig = NewMemoryCache(cacheCfg)
+	mgr := New(Authenticator{}, ctx)
+	server := httptest.NewServer(mgr.cacheFlushHandler())
+	defer server.Close()
+
+	describe()
+	describe()
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected calls before flush: %v, got: %v", 1, got)
+	}
+
+	resp, err := http.Get(server.URL + CacheFlushEndpoint)
+	if err != nil {
+		t.Fatalf("failed to get cache flush endpoint: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status: %v, got: %v", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+CacheFlushEndpoint+"?operation=DescribeTargetHealth", "", nil)
+	if err != nil {
+		t.Fatalf("failed to post cache flush endpoint: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status: %v, got: %v", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+CacheFlushEndpoint+"?service=elasticloadbalancing&operation=DescribeTargetHealth", "", nil)
+	if err != nil {
+		t.Fatalf("failed to post cache flush endpoint: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	describe()
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected calls after flush: %v, got: %v", 2, got)
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+// CloudMapIPv4Attribute is the registration attribute holding the IPv4 address of a cloud map instance
+const CloudMapIPv4Attribute = "AWS_INSTANCE_IPV4"
+
+// CloudMapRegistration is an instance registered in a cloud map service
+type CloudMapRegistration struct {
+	ServiceID  string
+	InstanceID string
+}
+
+// deregisterCloudMapTarget deregisters the event's instance from all matching cloud map services and waits for the operations to complete
+func (mgr *Manager) deregisterCloudMapTarget(event *LifecycleEvent) error {
+	var (
+		ctx          = &mgr.context
+		client       = mgr.authenticator.ServiceDiscoveryClient
+		metrics      = mgr.metrics
+		instanceID   = event.EC2InstanceID
+		waiterConfig = mgr.waiterConfig()
+		errs         error
+		mutex        sync.Mutex
+	)
+
+	if client == nil || (len(ctx.CloudMapNamespaceTagFilters) == 0 && len(ctx.CloudMapServiceTagFilters) == 0) {
+		return nil
+	}
+
+	serviceIDs, err := getCloudMapServicesByTags(client, ctx.CloudMapNamespaceTagFilters, ctx.CloudMapServiceTagFilters)
+	if err != nil {
+		return err
+	}
+	log.Infof("%v> checking membership in %v cloud map services", instanceID, len(serviceIDs))
+
+	addresses := getNodeAddresses(&event.referencedNode)
+	registrations := make([][]CloudMapRegistration, len(serviceIDs))
+	lookupErrs := make([]error, len(serviceIDs))
+	forEachConcurrently(ctx.MembershipCheckConcurrency, len(serviceIDs), func(i int) {
+		registrations[i], lookupErrs[i] = findInstanceInCloudMapService(event.Context(), client, serviceIDs[i], instanceID, addresses)
+	})
+
+	for _, err := range lookupErrs {
+		if err != nil {
+			return err
+		}
+	}
+
+	active := []CloudMapRegistration{}
+	for _, found := range registrations {
+		active = append(active, found...)
+	}
+
+	forEachConcurrently(ctx.MembershipCheckConcurrency, len(active), func(i int) {
+		registration := active[i]
+		log.Infof("%v> deregistering %v from cloud map service %v", instanceID, registration.InstanceID, registration.ServiceID)
+		err := deregisterCloudMapInstance(event, client, registration, waiterConfig)
+		if err != nil {
+			log.Errorf("%v> failed to deregister from cloud map service %v: %v", instanceID, registration.ServiceID, err)
+			metrics.AddCounter(FailedCloudMapDeregisterTotalMetric, eventLabels(event), 1)
+			msg := fmt.Sprintf(EventMessageCloudMapDeregisterFailed, registration.InstanceID, registration.ServiceID, err)
+			mgr.publishEvent(event, EventReasonCloudMapDeregisterFailed, getMessageFields(event, msg))
+			mutex.Lock()
+			errs = fmt.Errorf("failed to deregister from cloud map service %v: %v", registration.ServiceID, err)
+			mutex.Unlock()
+			return
+		}
+		log.Infof("%v> successfully deregistered %v from cloud map service %v", instanceID, registration.InstanceID, registration.ServiceID)
+		metrics.AddCounter(SuccessfulCloudMapDeregisterTotalMetric, eventLabels(event), 1)
+		msg := fmt.Sprintf(EventMessageCloudMapDeregisterSucceeded, registration.InstanceID, registration.ServiceID)
+		mgr.publishEvent(event, EventReasonCloudMapDeregisterSucceeded, getMessageFields(event, msg))
+	})
+
+	return errs
+}
+
+// getCloudMapServicesByTags returns the ids of services in namespaces matching the namespace filters which match the service filters,
+// empty filters match everything
+func getCloudMapServicesByTags(client servicediscoveryiface.ServiceDiscoveryAPI, namespaceFilters, serviceFilters map[string]string) ([]string, error) {
+	var (
+		services = []*servicediscovery.ServiceSummary{}
+		matched  = []string{}
+	)
+
+	collect := func(page *servicediscovery.ListServicesOutput, lastPage bool) bool {
+		services = append(services, page.Services...)
+		return true
+	}
+
+	if len(namespaceFilters) == 0 {
+		if err := client.ListServicesPages(&servicediscovery.ListServicesInput{}, collect); err != nil {
+			return matched, err
+		}
+	} else {
+		namespaces := []*servicediscovery.NamespaceSummary{}
+		err := client.ListNamespacesPages(&servicediscovery.ListNamespacesInput{}, func(page *servicediscovery.ListNamespacesOutput, lastPage bool) bool {
+			namespaces = append(namespaces, page.Namespaces...)
+			return true
+		})
+		if err != nil {
+			return matched, err
+		}
+
+		for _, namespace := range namespaces {
+			ok, err := cloudMapResourceMatches(client, aws.StringValue(namespace.Arn), namespaceFilters)
+			if err != nil {
+				return matched, err
+			}
+			if !ok {
+				continue
+			}
+			input := &servicediscovery.ListServicesInput{
+				Filters: []*servicediscovery.ServiceFilter{
+					{
+						Name:      aws.String(servicediscovery.ServiceFilterNameNamespaceId),
+						Condition: aws.String(servicediscovery.FilterConditionEq),
+						Values:    aws.StringSlice([]string{aws.StringValue(namespace.Id)}),
+					},
+				},
+			}
+			if err := client.ListServicesPages(input, collect); err != nil {
+				return matched, err
+			}
+		}
+	}
+
+	for _, service := range services {
+		if len(serviceFilters) > 0 {
+			ok, err := cloudMapResourceMatches(client, aws.StringValue(service.Arn), serviceFilters)
+			if err != nil {
+				return matched, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		matched = append(matched, aws.StringValue(service.Id))
+	}
+	return matched, nil
+}
+
+func cloudMapResourceMatches(client servicediscoveryiface.ServiceDiscoveryAPI, arn string, filters map[string]string) (bool, error) {
+	out, err := client.ListTagsForResource(&servicediscovery.ListTagsForResourceInput{
+		ResourceARN: aws.String(arn),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range out.Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return matchesTagFilters(tags, filters), nil
+}
+
+// findInstanceInCloudMapService returns the registrations of a service which reference the instance id or one of the node's addresses
+func findInstanceInCloudMapService(ctx context.Context, client servicediscoveryiface.ServiceDiscoveryAPI, serviceID, instanceID string, addresses map[string]bool) ([]CloudMapRegistration, error) {
+	found := []CloudMapRegistration{}
+	input := &servicediscovery.ListInstancesInput{
+		ServiceId: aws.String(serviceID),
+	}
+	err := client.ListInstancesPagesWithContext(ctx, input, func(page *servicediscovery.ListInstancesOutput, lastPage bool) bool {
+		for _, instance := range page.Instances {
+			id := aws.StringValue(instance.Id)
+			ipv4 := aws.StringValue(instance.Attributes[CloudMapIPv4Attribute])
+			if id == instanceID || (ipv4 != "" && addresses[ipv4]) {
+				found = append(found, CloudMapRegistration{ServiceID: serviceID, InstanceID: id})
+			}
+		}
+		return true
+	})
+	if err != nil {
+		log.Errorf("%v> failed finding instance in cloud map service %v: %v", instanceID, serviceID, err)
+		return found, err
+	}
+	return found, nil
+}
+
+func deregisterCloudMapInstance(event *LifecycleEvent, client servicediscoveryiface.ServiceDiscoveryAPI, registration CloudMapRegistration, config WaiterConfig) error {
+	out, err := client.DeregisterInstanceWithContext(event.Context(), &servicediscovery.DeregisterInstanceInput{
+		ServiceId:  aws.String(registration.ServiceID),
+		InstanceId: aws.String(registration.InstanceID),
+	})
+	if err != nil {
+		if _, ok := err.(*servicediscovery.InstanceNotFound); ok {
+			return nil
+		}
+		return err
+	}
+	return waitForCloudMapOperation(event, client, aws.StringValue(out.OperationId), config)
+}
+
+func waitForCloudMapOperation(event *LifecycleEvent, client servicediscoveryiface.ServiceDiscoveryAPI, operationID string, config WaiterConfig) error {
+	var (
+		instanceID = event.EC2InstanceID
+	)
+
+	input := &servicediscovery.GetOperationInput{
+		OperationId: aws.String(operationID),
+	}
+
+	for ieb, err := config.newBackoff(event.Context()); err == nil; err = ieb.Next() {
+
+		if event.isCompleted() {
+			return errors.New("event finished execution during cloud map deregistration wait")
+		}
+
+		if err := event.contextError("cloud map deregistration wait"); err != nil {
+			return err
+		}
+
+		// stop before the lifecycle hook expires rather than waiting past it
+		if remaining, ok := event.remainingTime(); ok && remaining <= config.maxDelay() {
+			return fmt.Errorf("lifecycle hook deadline in %v reached during cloud map deregistration wait", remaining.Round(time.Second))
+		}
+
+		out, err := client.GetOperationWithContext(event.Context(), input)
+		if err != nil {
+			return err
+		}
+
+		switch aws.StringValue(out.Operation.Status) {
+		case servicediscovery.OperationStatusSuccess:
+			return nil
+		case servicediscovery.OperationStatusFail:
+			return fmt.Errorf("cloud map operation %v failed: %v", operationID, aws.StringValue(out.Operation.ErrorMessage))
+		}
+		log.Debugf("%v> cloud map operation %v pending", instanceID, operationID)
+	}
+
+	return errors.New("wait for cloud map deregister timed out")
+}
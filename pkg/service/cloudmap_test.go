@@ -0,0 +1,213 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type stubServiceDiscovery struct {
+	servicediscoveryiface.ServiceDiscoveryAPI
+	namespaces                    []*servicediscovery.NamespaceSummary
+	services                      map[string][]*servicediscovery.ServiceSummary
+	tags                          map[string]map[string]string
+	instances                     map[string][]*servicediscovery.InstanceSummary
+	operationStatuses             []string
+	timesCalledDeregisterInstance int
+	timesCalledGetOperation       int
+}
+
+func (s *stubServiceDiscovery) ListNamespacesPages(input *servicediscovery.ListNamespacesInput, callback func(*servicediscovery.ListNamespacesOutput, bool) bool) error {
+	callback(&servicediscovery.ListNamespacesOutput{Namespaces: s.namespaces}, true)
+	return nil
+}
+
+func (s *stubServiceDiscovery) ListServicesPages(input *servicediscovery.ListServicesInput, callback func(*servicediscovery.ListServicesOutput, bool) bool) error {
+	services := []*servicediscovery.ServiceSummary{}
+	if len(input.Filters) == 0 {
+		for _, namespaced := range s.services {
+			services = append(services, namespaced...)
+		}
+	} else {
+		for _, namespaceID := range input.Filters[0].Values {
+			services = append(services, s.services[aws.StringValue(namespaceID)]...)
+		}
+	}
+	callback(&servicediscovery.ListServicesOutput{Services: services}, true)
+	return nil
+}
+
+func (s *stubServiceDiscovery) ListTagsForResource(input *servicediscovery.ListTagsForResourceInput) (*servicediscovery.ListTagsForResourceOutput, error) {
+	tags := []*servicediscovery.Tag{}
+	for k, v := range s.tags[aws.StringValue(input.ResourceARN)] {
+		tags = append(tags, &servicediscovery.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return &servicediscovery.ListTagsForResourceOutput{Tags: tags}, nil
+}
+
+func (s *stubServiceDiscovery) ListInstancesPages(input *servicediscovery.ListInstancesInput, callback func(*servicediscovery.ListInstancesOutput, bool) bool) error {
+	callback(&servicediscovery.ListInstancesOutput{Instances: s.instances[aws.StringValue(input.ServiceId)]}, true)
+	return nil
+}
+
+func (s *stubServiceDiscovery) ListInstancesPagesWithContext(ctx aws.Context, input *servicediscovery.ListInstancesInput, callback func(*servicediscovery.ListInstancesOutput, bool) bool, opts ...request.Option) error {
+	return s.ListInstancesPages(input, callback)
+}
+
+func (s *stubServiceDiscovery) DeregisterInstance(input *servicediscovery.DeregisterInstanceInput) (*servicediscovery.DeregisterInstanceOutput, error) {
+	s.timesCalledDeregisterInstance++
+	return &servicediscovery.DeregisterInstanceOutput{OperationId: aws.String("op-" + aws.StringValue(input.InstanceId))}, nil
+}
+
+func (s *stubServiceDiscovery) DeregisterInstanceWithContext(ctx aws.Context, input *servicediscovery.DeregisterInstanceInput, opts ...request.Option) (*servicediscovery.DeregisterInstanceOutput, error) {
+	return s.DeregisterInstance(input)
+}
+
+func (s *stubServiceDiscovery) GetOperation(input *servicediscovery.GetOperationInput) (*servicediscovery.GetOperationOutput, error) {
+	status := servicediscovery.OperationStatusSuccess
+	if s.timesCalledGetOperation < len(s.operationStatuses) {
+		status = s.operationStatuses[s.timesCalledGetOperation]
+	}
+	s.timesCalledGetOperation++
+	return &servicediscovery.GetOperationOutput{
+		Operation: &servicediscovery.Operation{
+			Id:           input.OperationId,
+			Status:       aws.String(status),
+			ErrorMessage: aws.String("operation failed"),
+		},
+	}, nil
+}
+
+func (s *stubServiceDiscovery) GetOperationWithContext(ctx aws.Context, input *servicediscovery.GetOperationInput, opts ...request.Option) (*servicediscovery.GetOperationOutput, error) {
+	return s.GetOperation(input)
+}
+
+func _newCloudMapStubber() *stubServiceDiscovery {
+	return &stubServiceDiscovery{
+		namespaces: []*servicediscovery.NamespaceSummary{
+			{Id: aws.String("ns-1"), Arn: aws.String("arn:ns-1")},
+			{Id: aws.String("ns-2"), Arn: aws.String("arn:ns-2")},
+		},
+		services: map[string][]*servicediscovery.ServiceSummary{
+			"ns-1": {
+				{Id: aws.String("srv-1"), Arn: aws.String("arn:srv-1")},
+				{Id: aws.String("srv-2"), Arn: aws.String("arn:srv-2")},
+			},
+			"ns-2": {
+				{Id: aws.String("srv-3"), Arn: aws.String("arn:srv-3")},
+			},
+		},
+		tags: map[string]map[string]string{
+			"arn:ns-1":  {"cluster": "test"},
+			"arn:srv-2": {"team": "a"},
+			"arn:srv-3": {"team": "a"},
+		},
+		instances: map[string][]*servicediscovery.InstanceSummary{
+			"srv-1": {
+				{Id: aws.String("i-123456789012")},
+				{Id: aws.String("i-000000000000")},
+			},
+			"srv-2": {
+				{Id: aws.String("task-1"), Attributes: aws.StringMap(map[string]string{CloudMapIPv4Attribute: "10.0.0.1"})},
+				{Id: aws.String("task-2"), Attributes: aws.StringMap(map[string]string{CloudMapIPv4Attribute: "10.0.0.2"})},
+			},
+			"srv-3": {
+				{Id: aws.String("i-123456789012")},
+			},
+		},
+	}
+}
+
+func Test_GetCloudMapServicesByTags(t *testing.T) {
+	t.Log("Test_GetCloudMapServicesByTags: should return services matching namespace and service tag filters")
+	stubber := _newCloudMapStubber()
+
+	services, err := getCloudMapServicesByTags(stubber, map[string]string{"cluster": "test"}, map[string]string{})
+	if err != nil {
+		t.Fatalf("getCloudMapServicesByTags: expected error not to have occured, %v", err)
+	}
+	if len(services) != 2 {
+		t.Fatalf("expected services: %v, got: %v", 2, services)
+	}
+
+	services, err = getCloudMapServicesByTags(stubber, map[string]string{"cluster": "test"}, map[string]string{"team": "a"})
+	if err != nil {
+		t.Fatalf("getCloudMapServicesByTags: expected error not to have occured, %v", err)
+	}
+	if len(services) != 1 || services[0] != "srv-2" {
+		t.Fatalf("expected services: %v, got: %v", []string{"srv-2"}, services)
+	}
+
+	services, err = getCloudMapServicesByTags(stubber, map[string]string{}, map[string]string{"team": "a"})
+	if err != nil {
+		t.Fatalf("getCloudMapServicesByTags: expected error not to have occured, %v", err)
+	}
+	if len(services) != 2 {
+		t.Fatalf("expected services: %v, got: %v", 2, services)
+	}
+}
+
+func Test_DeregisterCloudMapTarget(t *testing.T) {
+	t.Log("Test_DeregisterCloudMapTarget: should deregister registrations matching the instance id or node address")
+	stubber := _newCloudMapStubber()
+	auth := Authenticator{
+		ServiceDiscoveryClient: stubber,
+		KubernetesClient:       fake.NewSimpleClientset(),
+	}
+	ctx := _newBasicContext()
+	ctx.CloudMapNamespaceTagFilters = map[string]string{"cluster": "test"}
+	ctx.MembershipCheckConcurrency = 2
+	mgr := New(auth, ctx)
+
+	event := &LifecycleEvent{
+		EC2InstanceID: "i-123456789012",
+		startTime:     time.Now(),
+		referencedNode: v1.Node{
+			Status: v1.NodeStatus{
+				Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}},
+			},
+		},
+	}
+
+	err := mgr.deregisterCloudMapTarget(event)
+	if err != nil {
+		t.Fatalf("deregisterCloudMapTarget: expected error not to have occured, %v", err)
+	}
+
+	if stubber.timesCalledDeregisterInstance != 2 {
+		t.Fatalf("expected timesCalledDeregisterInstance: %v, got: %v", 2, stubber.timesCalledDeregisterInstance)
+	}
+}
+
+func Test_WaitForCloudMapOperation(t *testing.T) {
+	t.Log("Test_WaitForCloudMapOperation: should wait for pending operations and fail on failed operations")
+	var (
+		event  = &LifecycleEvent{EC2InstanceID: "i-123456789012", startTime: time.Now()}
+		config = WaiterConfig{MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	)
+
+	stubber := &stubServiceDiscovery{
+		operationStatuses: []string{servicediscovery.OperationStatusSubmitted, servicediscovery.OperationStatusPending},
+	}
+	err := waitForCloudMapOperation(event, stubber, "op-1", config)
+	if err != nil {
+		t.Fatalf("waitForCloudMapOperation: expected error not to have occured, %v", err)
+	}
+	if stubber.timesCalledGetOperation != 3 {
+		t.Fatalf("expected timesCalledGetOperation: %v, got: %v", 3, stubber.timesCalledGetOperation)
+	}
+
+	stubber = &stubServiceDiscovery{
+		operationStatuses: []string{servicediscovery.OperationStatusFail},
+	}
+	err = waitForCloudMapOperation(event, stubber, "op-1", config)
+	if err == nil {
+		t.Fatal("waitForCloudMapOperation: expected error to have occured")
+	}
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// CloudWatchNamespace is the default namespace of CloudWatch custom metrics
+	CloudWatchNamespace = "LifecycleManager"
+	// CloudWatchInterval is the default interval at which metrics are pushed to CloudWatch
+	CloudWatchInterval = 60 * time.Second
+	// CloudWatchMaxDatums is the maximum number of datums sent per PutMetricData call
+	CloudWatchMaxDatums = 20
+
+	// CloudWatchMetrics are the metrics pushed to CloudWatch and their unit
+	CloudWatchMetrics = map[string]string{
+		SuccessfulEventsTotalMetric:      cloudwatch.StandardUnitCount,
+		FailedEventsTotalMetric:          cloudwatch.StandardUnitCount,
+		FailedNodeDrainTotalMetric:       cloudwatch.StandardUnitCount,
+		FailedLBDeregisterTotalMetric:    cloudwatch.StandardUnitCount,
+		TerminatingInstancesCountMetric:  cloudwatch.StandardUnitCount,
+		DrainingInstancesCountMetric:     cloudwatch.StandardUnitCount,
+		EventDurationSecondsMetric:       cloudwatch.StandardUnitSeconds,
+		FailedEventDurationSecondsMetric: cloudwatch.StandardUnitSeconds,
+		DrainDurationSecondsMetric:       cloudwatch.StandardUnitSeconds,
+	}
+)
+
+// cloudWatchSeries identifies a metric series by name and scaling group
+type cloudWatchSeries struct {
+	name         string
+	scalingGroup string
+}
+
+// CloudWatchPublisher aggregates the CloudWatchMetrics and periodically pushes them as custom metrics with a
+// ClusterName dimension, and an AutoScalingGroupName dimension for per scaling group metrics
+type CloudWatchPublisher struct {
+	sync.Mutex
+	client      cloudwatchiface.CloudWatchAPI
+	namespace   string
+	clusterName string
+	interval    time.Duration
+	counts      map[cloudWatchSeries]float64
+	gauges      map[cloudWatchSeries]float64
+	stats       map[cloudWatchSeries]*cloudwatch.StatisticSet
+}
+
+// newCloudWatchPublisher returns a publisher for the configured namespace, or nil when CloudWatch metrics are disabled
+func newCloudWatchPublisher(ctx ManagerContext, auth Authenticator) *CloudWatchPublisher {
+	if !ctx.WithCloudWatchMetrics {
+		return nil
+	}
+	namespace := ctx.CloudWatchNamespace
+	if namespace == "" {
+		namespace = CloudWatchNamespace
+	}
+	interval := time.Duration(ctx.CloudWatchIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = CloudWatchInterval
+	}
+	return &CloudWatchPublisher{
+		client:      auth.CloudWatchClient,
+		namespace:   namespace,
+		clusterName: ctx.ClusterName,
+		interval:    interval,
+		counts:      make(map[cloudWatchSeries]float64),
+		gauges:      make(map[cloudWatchSeries]float64),
+		stats:       make(map[cloudWatchSeries]*cloudwatch.StatisticSet),
+	}
+}
+
+func newCloudWatchSeries(idx string, labels prometheus.Labels) (cloudWatchSeries, bool) {
+	if _, ok := CloudWatchMetrics[idx]; !ok {
+		return cloudWatchSeries{}, false
+	}
+	return cloudWatchSeries{name: idx, scalingGroup: labels["asg_name"]}, true
+}
+
+func (p *CloudWatchPublisher) AddCounter(idx string, labels prometheus.Labels, value float64) {
+	if series, ok := newCloudWatchSeries(idx, labels); ok {
+		p.Lock()
+		p.counts[series] += value
+		p.Unlock()
+	}
+}
+
+func (p *CloudWatchPublisher) SetGauge(idx string, labels prometheus.Labels, value float64) {
+	if series, ok := newCloudWatchSeries(idx, labels); ok {
+		p.Lock()
+		p.gauges[series] = value
+		p.Unlock()
+	}
+}
+
+func (p *CloudWatchPublisher) AddGauge(idx string, labels prometheus.Labels, value float64) {
+	if series, ok := newCloudWatchSeries(idx, labels); ok {
+		p.Lock()
+		p.gauges[series] += value
+		p.Unlock()
+	}
+}
+
+// DeleteGauge is a no-op since gauges are aggregated per scaling group, the series of other events are kept
+func (p *CloudWatchPublisher) DeleteGauge(idx string, labels prometheus.Labels) {}
+
+func (p *CloudWatchPublisher) ObserveHistogram(idx string, labels prometheus.Labels, value float64) {
+	series, ok := newCloudWatchSeries(idx, labels)
+	if !ok {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	stats, ok := p.stats[series]
+	if !ok {
+		p.stats[series] = &cloudwatch.StatisticSet{
+			Minimum:     aws.Float64(value),
+			Maximum:     aws.Float64(value),
+			Sum:         aws.Float64(value),
+			SampleCount: aws.Float64(1),
+		}
+		return
+	}
+	if value < *stats.Minimum {
+		stats.Minimum = aws.Float64(value)
+	}
+	if value > *stats.Maximum {
+		stats.Maximum = aws.Float64(value)
+	}
+	stats.Sum = aws.Float64(*stats.Sum + value)
+	stats.SampleCount = aws.Float64(*stats.SampleCount + 1)
+}
+
+func (p *CloudWatchPublisher) datum(series cloudWatchSeries, timestamp time.Time) *cloudwatch.MetricDatum {
+	dimensions := []*cloudwatch.Dimension{
+		{Name: aws.String("ClusterName"), Value: aws.String(p.clusterName)},
+	}
+	if series.scalingGroup != "" {
+		dimensions = append(dimensions, &cloudwatch.Dimension{Name: aws.String("AutoScalingGroupName"), Value: aws.String(series.scalingGroup)})
+	}
+	return &cloudwatch.MetricDatum{
+		MetricName: aws.String(series.name),
+		Dimensions: dimensions,
+		Timestamp:  aws.Time(timestamp),
+		Unit:       aws.String(CloudWatchMetrics[series.name]),
+	}
+}
+
+// collect returns the datums aggregated since the last collection, gauges are reported with their current value
+func (p *CloudWatchPublisher) collect() []*cloudwatch.MetricDatum {
+	p.Lock()
+	defer p.Unlock()
+
+	var (
+		now    = time.Now()
+		datums = make([]*cloudwatch.MetricDatum, 0)
+	)
+	for series, value := range p.counts {
+		d := p.datum(series, now)
+		d.Value = aws.Float64(value)
+		datums = append(datums, d)
+	}
+	for series, value := range p.gauges {
+		d := p.datum(series, now)
+		d.Value = aws.Float64(value)
+		datums = append(datums, d)
+	}
+	for series, stats := range p.stats {
+		d := p.datum(series, now)
+		d.StatisticValues = stats
+		datums = append(datums, d)
+	}
+	p.counts = make(map[cloudWatchSeries]float64)
+	p.stats = make(map[cloudWatchSeries]*cloudwatch.StatisticSet)
+
+	sort.Slice(datums, func(i, j int) bool {
+		return aws.StringValue(datums[i].MetricName) < aws.StringValue(datums[j].MetricName)
+	})
+	return datums
+}
+
+// Flush pushes the datums aggregated since the last flush to CloudWatch
+func (p *CloudWatchPublisher) Flush() error {
+	datums := p.collect()
+	for start := 0; start < len(datums); start += CloudWatchMaxDatums {
+		end := start + CloudWatchMaxDatums
+		if end > len(datums) {
+			end = len(datums)
+		}
+		input := &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(p.namespace),
+			MetricData: datums[start:end],
+		}
+		if _, err := p.client.PutMetricData(input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start flushes metrics to CloudWatch at the publisher's interval
+func (p *CloudWatchPublisher) Start() {
+	for range time.Tick(p.interval) {
+		if err := p.Flush(); err != nil {
+			log.Errorf("failed to push metrics to cloudwatch: %v", err)
+		}
+	}
+}
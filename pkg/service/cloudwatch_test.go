@@ -0,0 +1,117 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+type stubCloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	inputs []*cloudwatch.PutMetricDataInput
+}
+
+func (c *stubCloudWatch) PutMetricData(input *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error) {
+	c.inputs = append(c.inputs, input)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func _newCloudWatchPublisher(stubber *stubCloudWatch) *CloudWatchPublisher {
+	ctx := _newBasicContext()
+	ctx.ClusterName = "my-cluster"
+	ctx.WithCloudWatchMetrics = true
+	return newCloudWatchPublisher(ctx, Authenticator{CloudWatchClient: stubber})
+}
+
+func Test_CloudWatchPublisherDisabled(t *testing.T) {
+	t.Log("Test_CloudWatchPublisherDisabled: should not create a publisher unless cloudwatch metrics are enabled")
+	if p := newCloudWatchPublisher(_newBasicContext(), Authenticator{}); p != nil {
+		t.Fatalf("expected nil publisher, got: %+v", p)
+	}
+
+	m := &MetricsServer{}
+	m.AddCounter(SuccessfulEventsTotalMetric, eventLabels(&LifecycleEvent{}), 1)
+}
+
+func Test_CloudWatchPublisherFlush(t *testing.T) {
+	t.Log("Test_CloudWatchPublisherFlush: should push aggregated counters, gauges and statistics with cluster and scaling group dimensions")
+	var (
+		stubber   = &stubCloudWatch{}
+		publisher = _newCloudWatchPublisher(stubber)
+		m         = &MetricsServer{backends: []MetricsBackend{publisher}}
+		myASG     = eventLabels(&LifecycleEvent{AutoScalingGroupName: "my-asg", LifecycleTransition: TerminationEventName})
+	)
+
+	m.AddCounter(SuccessfulEventsTotalMetric, myASG, 1)
+	m.AddCounter(SuccessfulEventsTotalMetric, myASG, 1)
+	m.AddCounter(EmptyPollsTotalMetric, nil, 1)
+	m.IncGauge(TerminatingInstancesCountMetric, myASG)
+	m.IncGauge(TerminatingInstancesCountMetric, myASG)
+	m.DecGauge(TerminatingInstancesCountMetric, myASG)
+	m.ObserveHistogram(DrainDurationSecondsMetric, myASG, 10)
+	m.ObserveHistogram(DrainDurationSecondsMetric, myASG, 30)
+
+	if err := publisher.Flush(); err != nil {
+		t.Fatalf("Flush: expected error not to have occured, %v", err)
+	}
+	if len(stubber.inputs) != 1 {
+		t.Fatalf("expected PutMetricData calls: 1, got: %v", len(stubber.inputs))
+	}
+	input := stubber.inputs[0]
+	if aws.StringValue(input.Namespace) != CloudWatchNamespace {
+		t.Fatalf("expected namespace: %v, got: %v", CloudWatchNamespace, aws.StringValue(input.Namespace))
+	}
+
+	datums := make(map[string]*cloudwatch.MetricDatum)
+	for _, d := range input.MetricData {
+		datums[aws.StringValue(d.MetricName)] = d
+		if len(d.Dimensions) != 2 || aws.StringValue(d.Dimensions[0].Value) != "my-cluster" || aws.StringValue(d.Dimensions[1].Value) != "my-asg" {
+			t.Fatalf("expected cluster and scaling group dimensions, got: %v", d.Dimensions)
+		}
+	}
+	if len(datums) != 3 {
+		t.Fatalf("expected datums: 3, got: %v", len(datums))
+	}
+	if got := aws.Float64Value(datums[SuccessfulEventsTotalMetric].Value); got != 2 {
+		t.Fatalf("expected successful events: 2, got: %v", got)
+	}
+	if got := aws.Float64Value(datums[TerminatingInstancesCountMetric].Value); got != 1 {
+		t.Fatalf("expected terminating instances: 1, got: %v", got)
+	}
+	stats := datums[DrainDurationSecondsMetric].StatisticValues
+	if aws.Float64Value(stats.Minimum) != 10 || aws.Float64Value(stats.Maximum) != 30 || aws.Float64Value(stats.Sum) != 40 || aws.Float64Value(stats.SampleCount) != 2 {
+		t.Fatalf("expected drain duration statistics 10/30/40/2, got: %v", stats)
+	}
+
+	// counters and statistics are reset after a flush, gauges keep their value
+	if err := publisher.Flush(); err != nil {
+		t.Fatalf("Flush: expected error not to have occured, %v", err)
+	}
+	if len(stubber.inputs) != 2 || len(stubber.inputs[1].MetricData) != 1 {
+		t.Fatalf("expected only the gauge to be pushed again, got: %v", stubber.inputs[len(stubber.inputs)-1])
+	}
+}
+
+func Test_CloudWatchPublisherBatches(t *testing.T) {
+	t.Log("Test_CloudWatchPublisherBatches: should split datums into batches of CloudWatchMaxDatums")
+	var (
+		stubber = &stubCloudWatch{}
+		p       = _newCloudWatchPublisher(stubber)
+	)
+	for i := 0; i < CloudWatchMaxDatums+5; i++ {
+		labels := eventLabels(&LifecycleEvent{AutoScalingGroupName: string(rune('a' + i))})
+		p.AddCounter(FailedEventsTotalMetric, labels, 1)
+	}
+
+	if err := p.Flush(); err != nil {
+		t.Fatalf("Flush: expected error not to have occured, %v", err)
+	}
+	if len(stubber.inputs) != 2 {
+		t.Fatalf("expected PutMetricData calls: 2, got: %v", len(stubber.inputs))
+	}
+	if len(stubber.inputs[0].MetricData) != CloudWatchMaxDatums || len(stubber.inputs[1].MetricData) != 5 {
+		t.Fatalf("expected batches of %v and 5, got: %v and %v", CloudWatchMaxDatums, len(stubber.inputs[0].MetricData), len(stubber.inputs[1].MetricData))
+	}
+}
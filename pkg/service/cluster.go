@@ -0,0 +1,44 @@
+package service
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ClusterTagKeyPrefix is the prefix of the tag key marking scaling groups which belong to a cluster
+	ClusterTagKeyPrefix = "kubernetes.io/cluster/"
+	// EKSClusterNameTagKey is the tag key set by EKS managed node groups to the name of their cluster
+	EKSClusterNameTagKey = "eks:cluster-name"
+	// ErrNotOwned is returned when the scaling group of an event does not belong to this cluster
+	ErrNotOwned = errors.New("scaling group is not owned by this cluster")
+)
+
+// isOwnedScalingGroup returns true if the scaling group is tagged as belonging to the cluster
+func isOwnedScalingGroup(tags map[string]string, clusterName string) bool {
+	if _, ok := tags[ClusterTagKeyPrefix+clusterName]; ok {
+		return true
+	}
+	return tags[EKSClusterNameTagKey] == clusterName
+}
+
+// validateOwnership returns ErrNotOwned if the event's scaling group belongs to another cluster sharing the queue.
+// Scaling groups whose tags cannot be read are treated as not owned, so that their messages are returned to the queue.
+func (mgr *Manager) validateOwnership(e *LifecycleEvent) error {
+	var (
+		clusterName = mgr.context.ClusterName
+	)
+
+	if clusterName == "" {
+		return nil
+	}
+
+	tags, err := getScalingGroupTags(mgr.authenticator.ScalingGroupClient, e.AutoScalingGroupName)
+	if err != nil {
+		return errors.Wrapf(ErrNotOwned, "failed to get tags of scaling group %v: %v", e.AutoScalingGroupName, err)
+	}
+
+	if !isOwnedScalingGroup(tags, clusterName) {
+		return errors.Wrapf(ErrNotOwned, "scaling group %v is not tagged for cluster %v", e.AutoScalingGroupName, clusterName)
+	}
+	return nil
+}
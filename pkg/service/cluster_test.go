@@ -0,0 +1,100 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/pkg/errors"
+)
+
+func Test_ValidateOwnership(t *testing.T) {
+	t.Log("Test_ValidateOwnership: should only accept events of scaling groups tagged for the cluster")
+	tests := []struct {
+		name        string
+		clusterName string
+		groups      []*autoscaling.Group
+		owned       bool
+	}{
+		{
+			name:  "no cluster name",
+			owned: true,
+		},
+		{
+			name:        "kubernetes cluster tag",
+			clusterName: "my-cluster",
+			groups: []*autoscaling.Group{{Tags: []*autoscaling.TagDescription{
+				{Key: aws.String("kubernetes.io/cluster/my-cluster"), Value: aws.String("owned")},
+			}}},
+			owned: true,
+		},
+		{
+			name:        "eks cluster tag",
+			clusterName: "my-cluster",
+			groups: []*autoscaling.Group{{Tags: []*autoscaling.TagDescription{
+				{Key: aws.String(EKSClusterNameTagKey), Value: aws.String("my-cluster")},
+			}}},
+			owned: true,
+		},
+		{
+			name:        "other cluster",
+			clusterName: "my-cluster",
+			groups: []*autoscaling.Group{{Tags: []*autoscaling.TagDescription{
+				{Key: aws.String("kubernetes.io/cluster/other-cluster"), Value: aws.String("owned")},
+				{Key: aws.String(EKSClusterNameTagKey), Value: aws.String("other-cluster")},
+			}}},
+			owned: false,
+		},
+		{
+			name:        "scaling group not found",
+			clusterName: "my-cluster",
+			owned:       false,
+		},
+	}
+
+	for _, tc := range tests {
+		auth := Authenticator{
+			ScalingGroupClient: &stubAutoscaling{scalingGroups: tc.groups},
+		}
+		ctx := _newBasicContext()
+		ctx.ClusterName = tc.clusterName
+		mgr := New(auth, ctx)
+
+		err := mgr.validateOwnership(&LifecycleEvent{AutoScalingGroupName: "my-asg"})
+		if tc.owned && err != nil {
+			t.Fatalf("%v: expected error not to have occured, %v", tc.name, err)
+		}
+		if !tc.owned && errors.Cause(err) != ErrNotOwned {
+			t.Fatalf("%v: expected error: %v, got: %v", tc.name, ErrNotOwned, err)
+		}
+	}
+}
+
+func Test_SkipMessage(t *testing.T) {
+	t.Log("Test_SkipMessage: should return messages of other clusters to the queue without deleting them")
+	sqsStubber := &stubSQS{}
+	auth := Authenticator{
+		SQSClient: sqsStubber,
+	}
+	mgr := New(auth, _newBasicContext())
+	event := &LifecycleEvent{
+		EC2InstanceID: "i-123486890234",
+		receiptHandle: "MbZj6wDWli+JvwwJaBV+3dcjk2YW2vA3+STFFljTM8tJJg6HRG6PYSasuWXPJB+Cw=",
+	}
+
+	if mgr.SkipMessage(ErrNodeNotFound, event) {
+		t.Fatalf("expected skipped: %v, got: %v", false, true)
+	}
+
+	if !mgr.SkipMessage(errors.Wrap(ErrNotOwned, "scaling group my-asg is not tagged"), event) {
+		t.Fatalf("expected skipped: %v, got: %v", true, false)
+	}
+
+	if sqsStubber.timesCalledChangeMessageVisibility != 1 {
+		t.Fatalf("expected timesCalledChangeMessageVisibility: %v, got: %v", 1, sqsStubber.timesCalledChangeMessageVisibility)
+	}
+
+	if sqsStubber.timesCalledDeleteMessage != 0 {
+		t.Fatalf("expected timesCalledDeleteMessage: %v, got: %v", 0, sqsStubber.timesCalledDeleteMessage)
+	}
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// DedupStoreMemory keeps claimed lifecycle action tokens in memory of the running replica
+	DedupStoreMemory = "memory"
+	// DedupStoreAnnotation keeps claimed lifecycle action tokens as an annotation on the terminating node
+	DedupStoreAnnotation = "annotation"
+	// DedupStoreLease keeps claimed lifecycle action tokens as a lease per instance, in the namespace of lifecycle-manager
+	DedupStoreLease = "lease"
+)
+
+var (
+	// ActionTokenAnnotationKey is the annotation key for the lifecycle action token of the event processing a node
+	ActionTokenAnnotationKey = "lifecycle-manager.keikoproj.io/action-token"
+	// ClaimLeasePrefix is the name prefix of the leases claiming the lifecycle action of an instance
+	ClaimLeasePrefix = "lifecycle-manager-claim-"
+	// ErrEventClaimed is returned when the lifecycle action of an event is already being processed
+	ErrEventClaimed = errors.New("lifecycle action is already being processed")
+)
+
+// DedupStore records the lifecycle action tokens of events being processed so that redelivered messages are not processed twice
+type DedupStore interface {
+	// Claim records the event's token, false is returned if the token was already claimed
+	Claim(event *LifecycleEvent) (bool, error)
+	// Release forgets the event's token once processing has ended
+	Release(event *LifecycleEvent) error
+}
+
+// IsValidDedupStore returns true if store is a known dedup store
+func IsValidDedupStore(store string) bool {
+	switch store {
+	case DedupStoreMemory, DedupStoreAnnotation, DedupStoreLease:
+		return true
+	}
+	return false
+}
+
+// newDedupStore returns the configured dedup store, the memory store is used by default
+func newDedupStore(ctx ManagerContext, kubeClient kubernetes.Interface, shards *ShardRing) DedupStore {
+	switch ctx.DedupStore {
+	case DedupStoreAnnotation:
+		return &AnnotationDedupStore{kubeClient: kubeClient}
+	case DedupStoreLease:
+		return &LeaseDedupStore{
+			kubeClient: kubeClient,
+			namespace:  ctx.SelfPodNamespace,
+			identity:   ctx.SelfPodName,
+			shards:     shards,
+		}
+	}
+	return &MemoryDedupStore{tokens: make(map[string]bool)}
+}
+
+// MemoryDedupStore claims tokens within a single replica
+type MemoryDedupStore struct {
+	sync.Mutex
+	tokens map[string]bool
+}
+
+func (s *MemoryDedupStore) Claim(event *LifecycleEvent) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.tokens[event.LifecycleActionToken] {
+		return false, nil
+	}
+	s.tokens[event.LifecycleActionToken] = true
+	return true, nil
+}
+
+func (s *MemoryDedupStore) Release(event *LifecycleEvent) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.tokens, event.LifecycleActionToken)
+	return nil
+}
+
+// AnnotationDedupStore claims tokens by annotating the terminating node, which is shared by all replicas
+type AnnotationDedupStore struct {
+	kubeClient kubernetes.Interface
+}
+
+func (s *AnnotationDedupStore) Claim(event *LifecycleEvent) (bool, error) {
+	var (
+		nodes    = eventKubeClient(event, s.kubeClient).CoreV1().Nodes()
+		nodeName = event.referencedNode.Name
+		token    = event.LifecycleActionToken
+		claimed  bool
+	)
+
+	// launching instances are not registered as nodes yet
+	if nodeName == "" {
+		return true, nil
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := nodes.Get(context.Background(), nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if node.Annotations[ActionTokenAnnotationKey] == token {
+			claimed = false
+			return nil
+		}
+		if node.Annotations == nil {
+			node.Annotations = make(map[string]string)
+		}
+		node.Annotations[ActionTokenAnnotationKey] = token
+		// the update fails with a conflict if another replica modified the node since it was read
+		_, err = nodes.Update(context.Background(), node, metav1.UpdateOptions{})
+		claimed = err == nil
+		return err
+	})
+	return claimed, err
+}
+
+func (s *AnnotationDedupStore) Release(event *LifecycleEvent) error {
+	var (
+		nodes    = eventKubeClient(event, s.kubeClient).CoreV1().Nodes()
+		nodeName = event.referencedNode.Name
+	)
+
+	if nodeName == "" {
+		return nil
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := nodes.Get(context.Background(), nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if node.Annotations[ActionTokenAnnotationKey] != event.LifecycleActionToken {
+			return nil
+		}
+		delete(node.Annotations, ActionTokenAnnotationKey)
+		_, err = nodes.Update(context.Background(), node, metav1.UpdateOptions{})
+		return err
+	})
+	// the node is deleted once the termination completes
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// LeaseDedupStore claims tokens as a lease named after the instance and held by the replica, unlike the annotation
+// store it also claims launching instances. Claims held by replicas which left the shard ring are taken over
+type LeaseDedupStore struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	identity   string
+	shards     *ShardRing
+}
+
+func claimLeaseName(event *LifecycleEvent) string {
+	return ClaimLeasePrefix + strings.ToLower(event.EC2InstanceID)
+}
+
+// isLive returns true if the claim holder is still processing, holders are assumed live when sharding is disabled
+func (s *LeaseDedupStore) isLive(holder string) bool {
+	return s.shards == nil || s.shards.isMember(holder)
+}
+
+func (s *LeaseDedupStore) Claim(event *LifecycleEvent) (bool, error) {
+	var (
+		leases  = s.kubeClient.CoordinationV1().Leases(s.namespace)
+		token   = event.LifecycleActionToken
+		now     = metav1.NewMicroTime(time.Now())
+		claimed bool
+	)
+
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        claimLeaseName(event),
+			Namespace:   s.namespace,
+			Annotations: map[string]string{ActionTokenAnnotationKey: token},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity: &s.identity,
+			AcquireTime:    &now,
+		},
+	}
+	_, err := leases.Create(context.Background(), lease, metav1.CreateOptions{})
+	if err == nil || !apierrors.IsAlreadyExists(err) {
+		return err == nil, err
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := leases.Get(context.Background(), lease.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		holder := ""
+		if existing.Spec.HolderIdentity != nil {
+			holder = *existing.Spec.HolderIdentity
+		}
+		if existing.Annotations[ActionTokenAnnotationKey] == token && (holder == s.identity || s.isLive(holder)) {
+			claimed = false
+			return nil
+		}
+		// the lease is left over from a previous lifecycle action, or its holder left the ring before releasing it
+		if existing.Annotations == nil {
+			existing.Annotations = make(map[string]string)
+		}
+		existing.Annotations[ActionTokenAnnotationKey] = token
+		existing.Spec.HolderIdentity = &s.identity
+		existing.Spec.AcquireTime = &now
+		_, err = leases.Update(context.Background(), existing, metav1.UpdateOptions{})
+		claimed = err == nil
+		return err
+	})
+	return claimed, err
+}
+
+func (s *LeaseDedupStore) Release(event *LifecycleEvent) error {
+	var (
+		leases = s.kubeClient.CoordinationV1().Leases(s.namespace)
+	)
+
+	lease, err := leases.Get(context.Background(), claimLeaseName(event), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if lease.Annotations[ActionTokenAnnotationKey] != event.LifecycleActionToken {
+		return nil
+	}
+
+	// the delete fails if the lease was taken over since it was read
+	err = leases.Delete(context.Background(), lease.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+	if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// claimEvent claims the event's lifecycle action token, ErrEventClaimed is returned if it is already being processed.
+// Events without a token, such as reconciled events, are not claimed
+func (mgr *Manager) claimEvent(event *LifecycleEvent) error {
+	if mgr.dedupStore == nil || event.LifecycleActionToken == "" {
+		return nil
+	}
+
+	claimed, err := mgr.dedupStore.Claim(event)
+	if err != nil {
+		// prefer processing a duplicate over leaving the instance waiting
+		log.Warnf("%v> failed to claim lifecycle action token, processing without deduplication: %v", event.EC2InstanceID, err)
+		return nil
+	}
+	if !claimed {
+		return errors.Wrapf(ErrEventClaimed, "token %v", event.LifecycleActionToken)
+	}
+	event.tokenClaimed = true
+	return nil
+}
+
+// releaseEvent releases the event's lifecycle action token once processing has ended
+func (mgr *Manager) releaseEvent(event *LifecycleEvent) {
+	if !event.tokenClaimed {
+		return
+	}
+	if err := mgr.dedupStore.Release(event); err != nil {
+		log.Warnf("%v> failed to release lifecycle action token: %v", event.EC2InstanceID, err)
+	}
+	event.tokenClaimed = false
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_ClaimEventMemory(t *testing.T) {
+	t.Log("Test_ClaimEventMemory: should reject an event whose token is already claimed until it is released")
+	mgr := New(Authenticator{}, _newBasicContext())
+
+	event := &LifecycleEvent{EC2InstanceID: "i-123456789012", LifecycleActionToken: "token-1"}
+	duplicate := &LifecycleEvent{EC2InstanceID: "i-123456789012", LifecycleActionToken: "token-1"}
+
+	if err := mgr.claimEvent(event); err != nil {
+		t.Fatalf("claimEvent: expected error not to have occured, %v", err)
+	}
+
+	if err := mgr.claimEvent(duplicate); err == nil {
+		t.Fatalf("claimEvent: expected error to have occured for a duplicate token")
+	}
+
+	mgr.releaseEvent(event)
+	if err := mgr.claimEvent(duplicate); err != nil {
+		t.Fatalf("claimEvent: expected error not to have occured after release, %v", err)
+	}
+
+	if err := mgr.claimEvent(&LifecycleEvent{EC2InstanceID: "i-123456789012"}); err != nil {
+		t.Fatalf("claimEvent: expected events without a token not to be claimed, %v", err)
+	}
+}
+
+func Test_ClaimEventAnnotation(t *testing.T) {
+	t.Log("Test_ClaimEventAnnotation: should claim tokens on the node shared by all replicas")
+	kubeClient := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+
+	ctx := _newBasicContext()
+	ctx.DedupStore = DedupStoreAnnotation
+	auth := Authenticator{KubernetesClient: kubeClient}
+
+	// each manager stands for a replica
+	replica1, replica2 := New(auth, ctx), New(auth, ctx)
+
+	newEvent := func() *LifecycleEvent {
+		event := &LifecycleEvent{EC2InstanceID: "i-123456789012", LifecycleActionToken: "token-1"}
+		event.SetReferencedNode(v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+		return event
+	}
+
+	event := newEvent()
+	if err := replica1.claimEvent(event); err != nil {
+		t.Fatalf("claimEvent: expected error not to have occured, %v", err)
+	}
+
+	node, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if node.Annotations[ActionTokenAnnotationKey] != "token-1" {
+		t.Fatalf("expected annotation %v: %v, got: %v", ActionTokenAnnotationKey, "token-1", node.Annotations[ActionTokenAnnotationKey])
+	}
+
+	if err := replica2.claimEvent(newEvent()); err == nil {
+		t.Fatalf("claimEvent: expected error to have occured for a token claimed by another replica")
+	}
+
+	replica1.releaseEvent(event)
+	node, _ = kubeClient.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if _, ok := node.Annotations[ActionTokenAnnotationKey]; ok {
+		t.Fatalf("expected annotation %v to be removed after release", ActionTokenAnnotationKey)
+	}
+}
+
+func Test_ClaimEventLease(t *testing.T) {
+	t.Log("Test_ClaimEventLease: should claim tokens as a lease and take over claims of replicas which left the ring")
+	kubeClient := fake.NewSimpleClientset()
+	auth := Authenticator{KubernetesClient: kubeClient}
+
+	newReplica := func(name string) *Manager {
+		ctx := _newBasicContext()
+		ctx.DedupStore = DedupStoreLease
+		ctx.WithSharding = true
+		ctx.SelfPodName = name
+		ctx.SelfPodNamespace = "lifecycle-manager"
+		return New(auth, ctx)
+	}
+	replica1, replica2 := newReplica("replica-1"), newReplica("replica-2")
+	for _, replica := range []*Manager{replica1, replica2} {
+		if err := replica.shards.renew(context.Background()); err != nil {
+			t.Fatalf("renew: expected error not to have occured, %v", err)
+		}
+	}
+
+	newEvent := func() *LifecycleEvent {
+		return &LifecycleEvent{EC2InstanceID: "i-123456789012", LifecycleActionToken: "token-1"}
+	}
+
+	if err := replica1.claimEvent(newEvent()); err != nil {
+		t.Fatalf("claimEvent: expected error not to have occured, %v", err)
+	}
+
+	lease, err := kubeClient.CoordinationV1().Leases("lifecycle-manager").Get(context.Background(), "lifecycle-manager-claim-i-123456789012", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected claim lease to exist: %v", err)
+	}
+	if *lease.Spec.HolderIdentity != "replica-1" {
+		t.Fatalf("expected holder: replica-1, got: %v", *lease.Spec.HolderIdentity)
+	}
+
+	if err := replica2.claimEvent(newEvent()); err == nil {
+		t.Fatalf("claimEvent: expected error to have occured for a token claimed by a live replica")
+	}
+
+	// replica-1 leaves the ring without releasing its claim
+	replica1.shards.leave()
+	if err := replica2.shards.renew(context.Background()); err != nil {
+		t.Fatalf("renew: expected error not to have occured, %v", err)
+	}
+
+	event := newEvent()
+	if err := replica2.claimEvent(event); err != nil {
+		t.Fatalf("claimEvent: expected claim of a departed replica to be taken over, %v", err)
+	}
+
+	replica2.releaseEvent(event)
+	if _, err := kubeClient.CoordinationV1().Leases("lifecycle-manager").Get(context.Background(), "lifecycle-manager-claim-i-123456789012", metav1.GetOptions{}); err == nil {
+		t.Fatalf("expected claim lease to be deleted after release")
+	}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrLoadBalancerDiscoveryTimeout is returned when the discovery of the load balancers of an instance exceeds the
+	// load balancer discovery timeout
+	ErrLoadBalancerDiscoveryTimeout = errors.New("load balancer discovery timed out")
+	// ErrDeregisterTimeout is returned when the deregistration of an instance from its load balancers exceeds the
+	// deregister timeout
+	ErrDeregisterTimeout = errors.New("load balancer deregistration timed out")
+	// ErrWaiterTimeout is returned when the deregistration waiters of an instance exceed the waiter timeout
+	ErrWaiterTimeout = errors.New("deregistration waiters timed out")
+)
+
+// phaseTimeout returns a channel receiving once a phase timeout in seconds passed, a nil channel which never
+// receives is returned when the timeout is disabled
+func phaseTimeout(seconds int64) <-chan time.Time {
+	if seconds <= 0 {
+		return nil
+	}
+	return time.After(time.Duration(seconds) * time.Second)
+}
+
+// scanMembershipWithTimeout scans the load balancers of an event's instance within the load balancer discovery
+// timeout, scans which time out are cancelled and fail with ErrLoadBalancerDiscoveryTimeout
+func (mgr *Manager) scanMembershipWithTimeout(event *LifecycleEvent) (*ScanResult, error) {
+	timeout := mgr.context.LBDiscoveryTimeoutSeconds
+	if timeout <= 0 {
+		return mgr.scanMembership(event.Context(), event)
+	}
+
+	ctx, cancel := context.WithTimeout(event.Context(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	result, err := mgr.scanMembership(ctx, event)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && event.Context().Err() == nil {
+		return &ScanResult{}, ErrLoadBalancerDiscoveryTimeout
+	}
+	return result, err
+}
+
+// discardWaiterErrors reads the errors of deregistration waiters which are no longer waited for until they finish,
+// so that they do not block
+func discardWaiterErrors(waiter *Waiter) {
+	for {
+		select {
+		case <-waiter.finished:
+			return
+		case <-waiter.errors:
+		}
+	}
+}
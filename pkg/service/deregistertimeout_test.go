@@ -0,0 +1,144 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+type stubHangingELBv2 struct {
+	*stubELBv2
+	release chan struct{}
+}
+
+func (e *stubHangingELBv2) DescribeTargetGroupsPagesWithContext(ctx aws.Context, input *elbv2.DescribeTargetGroupsInput, callback func(*elbv2.DescribeTargetGroupsOutput, bool) bool, opts ...request.Option) error {
+	select {
+	case <-e.release:
+		return e.stubELBv2.DescribeTargetGroupsPages(input, callback)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type stubHangingTagsELBv2 struct {
+	*stubELBv2
+	release chan struct{}
+}
+
+func (e *stubHangingTagsELBv2) DescribeTagsWithContext(ctx aws.Context, input *elbv2.DescribeTagsInput, opts ...request.Option) (*elbv2.DescribeTagsOutput, error) {
+	select {
+	case <-e.release:
+		return e.stubELBv2.DescribeTags(input)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func Test_ScanMembershipWithTimeout(t *testing.T) {
+	t.Log("Test_ScanMembershipWithTimeout: should fail load balancer discovery which exceeds its timeout")
+	arn := "arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/targetgroup-name/some-id"
+	elbv2Stubber := &stubHangingELBv2{
+		stubELBv2: &stubELBv2{
+			targetGroups: []*elbv2.TargetGroup{
+				{
+					TargetGroupArn: aws.String(arn),
+				},
+			},
+			targetHealthDescriptions: []*elbv2.TargetHealthDescription{
+				{Target: &elbv2.TargetDescription{Id: aws.String("i-123486890234"), Port: aws.Int64(32334)}},
+			},
+		},
+		release: make(chan struct{}),
+	}
+
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		ELBv2Client:        elbv2Stubber,
+		ELBClient:          &stubELB{},
+	}
+
+	ctx := _newBasicContext()
+	ctx.DeregisterTargetTypes = []string{TargetTypeTargetGroup.String()}
+	ctx.DeregisterFullScanFallback = true
+	ctx.LBDiscoveryTimeoutSeconds = 1
+	mgr := New(auth, ctx)
+	event := &LifecycleEvent{EC2InstanceID: "i-123486890234", AutoScalingGroupName: "my-asg"}
+
+	_, err := mgr.scanMembershipWithTimeout(event)
+	if err != ErrLoadBalancerDiscoveryTimeout {
+		t.Fatalf("expected error: %v, got: %v", ErrLoadBalancerDiscoveryTimeout, err)
+	}
+
+	// the timed out scan is cancelled rather than left to add the targets it finds in the background
+	close(elbv2Stubber.release)
+	time.Sleep(100 * time.Millisecond)
+	if targets := mgr.GetTargetMapping(arn); len(targets) != 0 {
+		t.Fatalf("expected no targets of timed out scan, got: %v", targets)
+	}
+
+	if _, err := mgr.scanMembershipWithTimeout(event); err != nil {
+		t.Fatalf("expected error not to have occured, %v", err)
+	}
+	if port := mgr.GetTargetMapping(arn)["i-123486890234"]; port != 32334 {
+		t.Fatalf("expected target port: %v, got: %v", 32334, port)
+	}
+}
+
+func Test_ScanMembershipWithTimeoutTagFilters(t *testing.T) {
+	t.Log("Test_ScanMembershipWithTimeoutTagFilters: should fail load balancer discovery whose tag filtering exceeds its timeout")
+	arn := "arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/targetgroup-name/some-id"
+	elbv2Stubber := &stubHangingTagsELBv2{
+		stubELBv2: &stubELBv2{
+			targetGroups: []*elbv2.TargetGroup{
+				{
+					TargetGroupArn: aws.String(arn),
+				},
+			},
+			tagDescriptions: []*elbv2.TagDescription{
+				{
+					ResourceArn: aws.String(arn),
+					Tags:        []*elbv2.Tag{{Key: aws.String("cluster"), Value: aws.String("my-cluster")}},
+				},
+			},
+			targetHealthDescriptions: []*elbv2.TargetHealthDescription{
+				{Target: &elbv2.TargetDescription{Id: aws.String("i-123486890234"), Port: aws.Int64(32334)}},
+			},
+		},
+		release: make(chan struct{}),
+	}
+
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		ELBv2Client:        elbv2Stubber,
+		ELBClient:          &stubELB{},
+	}
+
+	ctx := _newBasicContext()
+	ctx.DeregisterTargetTypes = []string{TargetTypeTargetGroup.String()}
+	ctx.DeregisterFullScanFallback = true
+	ctx.DeregisterTagFilters = map[string]string{"cluster": "my-cluster"}
+	ctx.LBDiscoveryTimeoutSeconds = 1
+	mgr := New(auth, ctx)
+	event := &LifecycleEvent{EC2InstanceID: "i-123486890234", AutoScalingGroupName: "my-asg"}
+
+	_, err := mgr.scanMembershipWithTimeout(event)
+	if err != ErrLoadBalancerDiscoveryTimeout {
+		t.Fatalf("expected error: %v, got: %v", ErrLoadBalancerDiscoveryTimeout, err)
+	}
+
+	close(elbv2Stubber.release)
+	time.Sleep(100 * time.Millisecond)
+	if targets := mgr.GetTargetMapping(arn); len(targets) != 0 {
+		t.Fatalf("expected no targets of timed out scan, got: %v", targets)
+	}
+
+	if _, err := mgr.scanMembershipWithTimeout(event); err != nil {
+		t.Fatalf("expected error not to have occured, %v", err)
+	}
+	if port := mgr.GetTargetMapping(arn)["i-123486890234"]; port != 32334 {
+		t.Fatalf("expected target port: %v, got: %v", 32334, port)
+	}
+}
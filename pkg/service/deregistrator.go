@@ -1,6 +1,8 @@
 package service
 
 import (
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elbv2"
@@ -10,11 +12,12 @@ import (
 type Deregistrator struct {
 	targetDeregisteredCount  int
 	classicDeregisteredCount int
-	errors                   chan DeregistrationError
+	errors                   []DeregistrationError
 }
 
 func (d *Deregistrator) AddClassicDeregistration(val int)     { d.classicDeregisteredCount += val }
 func (d *Deregistrator) AddTargetGroupDeregistration(val int) { d.targetDeregisteredCount += val }
+func (d *Deregistrator) AddError(err DeregistrationError)     { d.errors = append(d.errors, err) }
 
 type DeregistrationError struct {
 	Error     error
@@ -23,6 +26,70 @@ type DeregistrationError struct {
 	Type      TargetType
 }
 
+// deregistrationBatch is a deregistrator run shared by the events which queued their targets within the batch window,
+// so that instances terminating together are deregistered from each load balancer in a single call
+type deregistrationBatch struct {
+	done   chan struct{}
+	errors []DeregistrationError
+}
+
+// instanceErrors returns the errors of the deregistrations of the batch which included an instance
+func (b *deregistrationBatch) instanceErrors(instanceID string) []DeregistrationError {
+	errs := make([]DeregistrationError, 0)
+	for _, err := range b.errors {
+		for _, instance := range err.Instances {
+			if instance == instanceID {
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// joinDeregistrationBatch returns the pending deregistration batch, or starts one which deregisters the queued targets
+// once the batch window passed. Targets must be queued before joining so that the batch includes them
+func (mgr *Manager) joinDeregistrationBatch() *deregistrationBatch {
+	mgr.batchMu.Lock()
+	defer mgr.batchMu.Unlock()
+	if mgr.pendingBatch != nil {
+		return mgr.pendingBatch
+	}
+
+	batch := &deregistrationBatch{done: make(chan struct{})}
+	mgr.pendingBatch = batch
+	go func() {
+		window := time.NewTimer(time.Duration(mgr.context.DeregisterBatchWindowSeconds) * time.Second)
+		defer window.Stop()
+		select {
+		case <-window.C:
+		case <-mgr.ctx.Done():
+		}
+
+		// events joining from now on start the next batch
+		mgr.batchMu.Lock()
+		mgr.pendingBatch = nil
+		mgr.batchMu.Unlock()
+
+		d := &Deregistrator{}
+		mgr.startDeregistrator(d)
+		batch.errors = d.errors
+		close(batch.done)
+	}()
+	return batch
+}
+
+// iterationBackoff returns the random delay between the deregistrations of the targets of a batch, so that the
+// deregistrations of concurrent batches are spread out. The delay is drawn between IterationJitterMinSeconds and
+// IterationJitterRangeSeconds
+func (mgr *Manager) iterationBackoff() Backoff {
+	backoff := Backoff{Initial: time.Duration(IterationJitterRangeSeconds * float64(time.Second))}
+	if IterationJitterRangeSeconds > IterationJitterMinSeconds {
+		backoff.Jitter = 1 - IterationJitterMinSeconds/IterationJitterRangeSeconds
+	}
+	return backoff
+}
+
 func (mgr *Manager) startDeregistrator(d *Deregistrator) {
 	mgr.deregistrationMu.Lock()
 	defer mgr.deregistrationMu.Unlock()
@@ -34,7 +101,7 @@ func (mgr *Manager) startDeregistrator(d *Deregistrator) {
 		if len(targets) == 0 {
 			return true
 		}
-		waitJitter(IterationJitterRangeSeconds)
+		mgr.iterationBackoff().Wait(mgr.ctx, 0)
 		mgr.DeregisterTargets(targets, d)
 		return true
 	})
@@ -61,7 +128,7 @@ func (m *Manager) DeregisterTargets(targets []*Target, d *Deregistrator) {
 		switch target.Type {
 		case TargetTypeClassicELB:
 			log.Infof("deregistrator> deregistering %+v from %v", instances, target.TargetId)
-			err := deregisterInstances(elbClient, target.TargetId, instances)
+			err := deregisterInstances(m.ctx, elbClient, target.TargetId, instances)
 			if err != nil {
 				if awsErr, ok := err.(awserr.Error); ok {
 					if awsErr.Code() == elb.ErrCodeAccessPointNotFoundException {
@@ -79,7 +146,7 @@ func (m *Manager) DeregisterTargets(targets []*Target, d *Deregistrator) {
 					Instances: instances,
 					Type:      TargetTypeClassicELB,
 				}
-				d.errors <- deregistrationErr
+				d.AddError(deregistrationErr)
 			}
 			d.AddClassicDeregistration(len(instances))
 			for _, instance := range instances {
@@ -87,7 +154,7 @@ func (m *Manager) DeregisterTargets(targets []*Target, d *Deregistrator) {
 			}
 		case TargetTypeTargetGroup:
 			log.Infof("deregistrator> deregistering %+v from %v", instances, target.TargetId)
-			err := deregisterTargets(elbv2Client, target.TargetId, mapping)
+			err := deregisterTargets(m.ctx, elbv2Client, target.TargetId, mapping)
 			if err != nil {
 				if awsErr, ok := err.(awserr.Error); ok {
 					if awsErr.Code() == elbv2.ErrCodeTargetGroupNotFoundException {
@@ -105,7 +172,7 @@ func (m *Manager) DeregisterTargets(targets []*Target, d *Deregistrator) {
 					Instances: instances,
 					Type:      TargetTypeTargetGroup,
 				}
-				d.errors <- deregistrationErr
+				d.AddError(deregistrationErr)
 			}
 			d.AddTargetGroupDeregistration(len(instances))
 			for _, instance := range instances {
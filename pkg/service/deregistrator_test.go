@@ -0,0 +1,91 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_DeregistrationBatch(t *testing.T) {
+	t.Log("Test_DeregistrationBatch: should deregister the targets of events joining the same batch in a single call")
+	var (
+		arn     = "arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/targetgroup-name/some-id"
+		stubber = &stubELBv2{}
+		ctx     = _newBasicContext()
+	)
+	ctx.DeregisterBatchWindowSeconds = 1
+	auth := Authenticator{
+		ELBv2Client:      stubber,
+		KubernetesClient: fake.NewSimpleClientset(),
+	}
+	mgr := New(auth, ctx)
+
+	mgr.AddTargetByInstance(arn, mgr.NewTarget(arn, "i-111111111111", 80, TargetTypeTargetGroup))
+	first := mgr.joinDeregistrationBatch()
+	mgr.AddTargetByInstance(arn, mgr.NewTarget(arn, "i-222222222222", 80, TargetTypeTargetGroup))
+	second := mgr.joinDeregistrationBatch()
+	if first != second {
+		t.Fatalf("expected events within the window to join the same batch")
+	}
+	<-first.done
+
+	if stubber.timesCalledDeregisterTargets != 1 {
+		t.Fatalf("expected timesCalledDeregisterTargets: %v, got: %v", 1, stubber.timesCalledDeregisterTargets)
+	}
+
+	if next := mgr.joinDeregistrationBatch(); next == first {
+		t.Fatalf("expected a new batch once the previous one started")
+	}
+}
+
+func Test_DeregistrationBatchErrors(t *testing.T) {
+	t.Log("Test_DeregistrationBatchErrors: should report failed deregistrations to the events whose instance they included")
+	var (
+		arn     = "arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/targetgroup-name/some-id"
+		stubber = &stubErrorELBv2{}
+	)
+	auth := Authenticator{
+		ELBv2Client:      stubber,
+		KubernetesClient: fake.NewSimpleClientset(),
+	}
+	mgr := New(auth, _newBasicContext())
+
+	mgr.AddTargetByInstance(arn, mgr.NewTarget(arn, "i-111111111111", 80, TargetTypeTargetGroup))
+	batch := mgr.joinDeregistrationBatch()
+	<-batch.done
+
+	if errs := batch.instanceErrors("i-111111111111"); len(errs) != 1 || errs[0].Target != arn {
+		t.Fatalf("expected one deregistration error for target group %v, got: %+v", arn, errs)
+	}
+
+	if errs := batch.instanceErrors("i-222222222222"); len(errs) != 0 {
+		t.Fatalf("expected no deregistration errors, got: %+v", errs)
+	}
+}
+
+func Test_IterationBackoff(t *testing.T) {
+	t.Log("Test_IterationBackoff: should delay the deregistrations of a batch by 0.5 to 1.5 seconds")
+	random, jitterRange := jitterFloat64, IterationJitterRangeSeconds
+	defer func() { jitterFloat64, IterationJitterRangeSeconds = random, jitterRange }()
+	IterationJitterRangeSeconds = 1.5
+
+	mgr := New(Authenticator{}, _newBasicContext())
+	for _, tc := range []struct {
+		random   float64
+		expected time.Duration
+	}{
+		{0, 1500 * time.Millisecond},
+		{1, 500 * time.Millisecond},
+	} {
+		jitterFloat64 = func() float64 { return tc.random }
+		if delay := mgr.iterationBackoff().Delay(0); delay != tc.expected {
+			t.Fatalf("expected delay with random %v: %v, got: %v", tc.random, tc.expected, delay)
+		}
+	}
+
+	IterationJitterRangeSeconds = 0
+	if delay := mgr.iterationBackoff().Delay(0); delay != 0 {
+		t.Fatalf("expected no delay with jitter disabled, got: %v", delay)
+	}
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+var (
+	// DefaultQueueDiscoveryInterval is the default interval to discover queues by tag at
+	DefaultQueueDiscoveryInterval = 5 * time.Minute
+)
+
+// consumedQueue is a queue lifecycle-manager polls, given by --queue-name or discovered by tag
+type consumedQueue struct {
+	// visibility is the default visibility timeout of the queue, messages are kept invisible while validated
+	visibility time.Duration
+	// stop stops the pollers of the queue
+	stop context.CancelFunc
+}
+
+// receivedMessage is a message received by a poller from the queue at queueURL
+type receivedMessage struct {
+	message  *sqs.Message
+	queueURL string
+}
+
+// getQueueURLName returns the name of a queue from its url
+func getQueueURLName(url string) string {
+	return path.Base(url)
+}
+
+// eventQueueName returns the name of the queue an event belongs to, the queue given by --queue-name or the discovered
+// queue the event was received from
+func (mgr *Manager) eventQueueName(event *LifecycleEvent) string {
+	if len(mgr.context.QueueDiscoveryTags) == 0 || event.queueURL == "" {
+		return mgr.context.QueueName
+	}
+	return getQueueURLName(event.queueURL)
+}
+
+// isQueueAnnotation returns true if the queue name annotated on a node is the queue at url, nodes annotated before
+// the queue name was recorded belong to the queue given by --queue-name
+func (mgr *Manager) isQueueAnnotation(queueName, url string) bool {
+	if len(mgr.context.QueueDiscoveryTags) == 0 {
+		return queueName == mgr.context.QueueName || queueName == ""
+	}
+	return queueName == getQueueURLName(url)
+}
+
+// discoverQueues returns the urls of the queues of the account whose tags match the tag filters
+func discoverQueues(s sqsiface.SQSAPI, filters map[string]string) ([]string, error) {
+	var (
+		urls       = []string{}
+		discovered = []string{}
+	)
+
+	err := s.ListQueuesPages(&sqs.ListQueuesInput{}, func(page *sqs.ListQueuesOutput, lastPage bool) bool {
+		urls = append(urls, aws.StringValueSlice(page.QueueUrls)...)
+		return true
+	})
+	if err != nil {
+		return discovered, err
+	}
+
+	for _, url := range urls {
+		out, err := s.ListQueueTags(&sqs.ListQueueTagsInput{QueueUrl: aws.String(url)})
+		if err != nil {
+			// queues deleted since they were listed are no longer discovered
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == sqs.ErrCodeQueueDoesNotExist {
+				continue
+			}
+			return discovered, err
+		}
+		if matchesTagFilters(aws.StringValueMap(out.Tags), filters) {
+			discovered = append(discovered, url)
+		}
+	}
+	sort.Strings(discovered)
+	return discovered, nil
+}
+
+// initialQueueURLs returns the urls of the queues to consume at start, the queue given by --queue-name or the queues
+// discovered by tag
+func (mgr *Manager) initialQueueURLs() []string {
+	var (
+		ctx   = &mgr.context
+		queue = mgr.authenticator.SQSClient
+	)
+
+	if len(ctx.QueueDiscoveryTags) == 0 {
+		return []string{getQueueURL(queue, ctx)}
+	}
+
+	urls, err := discoverQueues(queue, ctx.QueueDiscoveryTags)
+	if err != nil {
+		log.Fatalf("unable to discover queues tagged %v: %v", ctx.QueueDiscoveryTags, err)
+	}
+	return urls
+}
+
+// queueURLs returns the urls of the consumed queues
+func (mgr *Manager) queueURLs() []string {
+	mgr.queuesMu.Lock()
+	defer mgr.queuesMu.Unlock()
+	urls := make([]string, 0, len(mgr.queues))
+	for url := range mgr.queues {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// queueVisibility returns the default visibility timeout of a consumed queue, it is zero for queues which are not
+// consumed or whose visibility timeout is not known
+func (mgr *Manager) queueVisibility(url string) time.Duration {
+	mgr.queuesMu.Lock()
+	defer mgr.queuesMu.Unlock()
+	if queue, ok := mgr.queues[url]; ok {
+		return queue.visibility
+	}
+	return 0
+}
+
+// startQueue starts the pollers of a queue, they share the capacity of the workers with the pollers of other queues
+func (mgr *Manager) startQueue(url string) {
+	var (
+		ctx     = &mgr.context
+		metrics = mgr.metrics
+	)
+
+	mgr.queuesMu.Lock()
+	defer mgr.queuesMu.Unlock()
+	if _, ok := mgr.queues[url]; ok {
+		return
+	}
+
+	// messages are kept invisible while validated, validation of throttled events may exceed the visibility timeout
+	visibility, err := getQueueVisibilityTimeout(mgr.authenticator.SQSClient, url)
+	if err != nil {
+		log.Warnf("failed to get visibility timeout of queue %v: %v", url, err)
+	}
+
+	pollerCtx, stop := context.WithCancel(mgr.ctx)
+	mgr.queues[url] = &consumedQueue{visibility: visibility, stop: stop}
+	metrics.SetGauge(ConsumedQueuesCountMetric, nil, float64(len(mgr.queues)))
+
+	log.Infof("starting %v pollers of queue %v", ctx.PollerCount, url)
+	for i := 0; i < ctx.PollerCount; i++ {
+		go mgr.newPoller(pollerCtx, url)
+	}
+}
+
+// stopQueue stops the pollers of a queue, events already received from it are still processed
+func (mgr *Manager) stopQueue(url string) {
+	var (
+		metrics = mgr.metrics
+	)
+
+	mgr.queuesMu.Lock()
+	defer mgr.queuesMu.Unlock()
+	queue, ok := mgr.queues[url]
+	if !ok {
+		return
+	}
+
+	log.Infof("stopping pollers of queue %v", url)
+	queue.stop()
+	delete(mgr.queues, url)
+	metrics.SetGauge(ConsumedQueuesCountMetric, nil, float64(len(mgr.queues)))
+}
+
+// syncQueues starts the pollers of discovered queues which are not consumed yet and stops those of consumed queues
+// which are no longer discovered
+func (mgr *Manager) syncQueues(discovered []string) {
+	isDiscovered := make(map[string]bool)
+	for _, url := range discovered {
+		isDiscovered[url] = true
+		mgr.startQueue(url)
+	}
+	for _, url := range mgr.queueURLs() {
+		if !isDiscovered[url] {
+			mgr.stopQueue(url)
+		}
+	}
+}
+
+// startQueueDiscovery periodically discovers queues by tag, consumed queues are kept when discovery fails
+func (mgr *Manager) startQueueDiscovery() {
+	var (
+		ctx      = &mgr.context
+		queue    = mgr.authenticator.SQSClient
+		interval = time.Duration(ctx.QueueDiscoveryIntervalSeconds) * time.Second
+	)
+
+	if interval <= 0 {
+		interval = DefaultQueueDiscoveryInterval
+	}
+
+	for sleep(mgr.ctx, interval) {
+		discovered, err := discoverQueues(queue, ctx.QueueDiscoveryTags)
+		if err != nil {
+			log.Errorf("failed to discover queues tagged %v: %v", ctx.QueueDiscoveryTags, err)
+			continue
+		}
+		mgr.syncQueues(discovered)
+	}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func Test_DiscoverQueues(t *testing.T) {
+	t.Log("Test_DiscoverQueues: should start and stop pollers of queues as their tags match")
+	var (
+		clusterQueue = "https://sqs.us-west-2.amazonaws.com/000000000000/cluster-queue"
+		otherQueue   = "https://sqs.us-west-2.amazonaws.com/000000000000/other-queue"
+	)
+	sqsStubber := &stubSQS{
+		FakeQueueTags: map[string]map[string]*string{
+			clusterQueue: {"lifecycle-manager.keikoproj.io/cluster": aws.String("my-cluster")},
+			otherQueue:   {"lifecycle-manager.keikoproj.io/cluster": aws.String("other-cluster")},
+		},
+	}
+
+	ctx := _newBasicContext()
+	ctx.QueueName = ""
+	ctx.QueueDiscoveryTags = map[string]string{"lifecycle-manager.keikoproj.io/cluster": "my-cluster"}
+	mgr := New(Authenticator{SQSClient: sqsStubber}, ctx)
+	defer mgr.Stop()
+
+	discovered, err := discoverQueues(sqsStubber, ctx.QueueDiscoveryTags)
+	if err != nil {
+		t.Fatalf("discoverQueues: expected error not to have occured, %v", err)
+	}
+	if !reflect.DeepEqual(discovered, []string{clusterQueue}) {
+		t.Fatalf("expected discovered queues: %v, got: %v", []string{clusterQueue}, discovered)
+	}
+
+	mgr.syncQueues(discovered)
+	if urls := mgr.queueURLs(); !reflect.DeepEqual(urls, []string{clusterQueue}) {
+		t.Fatalf("expected consumed queues: %v, got: %v", []string{clusterQueue}, urls)
+	}
+
+	// the queue of the other cluster is re-tagged for this cluster, the queue of this cluster is deleted
+	sqsStubber.FakeQueueTags[otherQueue] = sqsStubber.FakeQueueTags[clusterQueue]
+	delete(sqsStubber.FakeQueueTags, clusterQueue)
+	discovered, _ = discoverQueues(sqsStubber, ctx.QueueDiscoveryTags)
+	mgr.syncQueues(discovered)
+	if urls := mgr.queueURLs(); !reflect.DeepEqual(urls, []string{otherQueue}) {
+		t.Fatalf("expected consumed queues: %v, got: %v", []string{otherQueue}, urls)
+	}
+
+	event := &LifecycleEvent{queueURL: otherQueue}
+	if name := mgr.eventQueueName(event); name != "other-queue" {
+		t.Fatalf("expected event queue name: %v, got: %v", "other-queue", name)
+	}
+	if mgr.isQueueAnnotation("cluster-queue", otherQueue) || !mgr.isQueueAnnotation("other-queue", otherQueue) {
+		t.Fatal("expected annotations to match the queue by name")
+	}
+}
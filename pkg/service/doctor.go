@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// doctorInstanceID and doctorActionToken are placeholders for probing lifecycle actions, they are rejected as
+	// invalid once the caller is authorized
+	doctorInstanceID  = "i-00000000000000000"
+	doctorActionToken = "00000000-0000-0000-0000-000000000000"
+)
+
+// DiagnosticResult is the outcome of a single check of the doctor command
+type DiagnosticResult struct {
+	Check  string
+	Passed bool
+	Detail string
+}
+
+// DiagnosticOptions selects what is checked by RunDiagnostics
+type DiagnosticOptions struct {
+	QueueName     string
+	ScalingGroups []string
+	// Kubernetes checks the RBAC permissions of the kubernetes client
+	Kubernetes bool
+}
+
+// kubernetesPermission is a permission lifecycle-manager needs in the cluster
+type kubernetesPermission struct {
+	group       string
+	resource    string
+	subresource string
+	verb        string
+}
+
+// kubernetesPermissions are the permissions of the example cluster role
+var kubernetesPermissions = []kubernetesPermission{
+	{"", "nodes", "", "get"},
+	{"", "nodes", "", "list"},
+	{"", "nodes", "", "patch"},
+	{"", "nodes", "", "update"},
+	{"", "pods", "", "get"},
+	{"", "pods", "", "list"},
+	{"", "pods", "", "delete"},
+	{"", "pods", "eviction", "create"},
+	{"apps", "daemonsets", "", "get"},
+	{"", "events", "", "create"},
+}
+
+// isAccessDenied returns true if the error is an authorization failure of an AWS API
+func isAccessDenied(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	code := awsErr.Code()
+	return strings.Contains(code, "AccessDenied") || code == "UnauthorizedOperation" || code == "AuthorizationError" ||
+		code == "InvalidClientTokenId" || code == "UnrecognizedClientException"
+}
+
+// probeResult interprets the error of a call made only to test a permission. Calls which reached the API and were not
+// denied pass, probes use invalid input so that authorized calls fail validation rather than take effect
+func probeResult(check string, err error) DiagnosticResult {
+	if err == nil {
+		return DiagnosticResult{Check: check, Passed: true, Detail: "allowed"}
+	}
+	if isAccessDenied(err) {
+		return DiagnosticResult{Check: check, Detail: fmt.Sprintf("denied: %v", err.(awserr.Error).Message())}
+	}
+	if _, ok := err.(awserr.RequestFailure); ok {
+		return DiagnosticResult{Check: check, Passed: true, Detail: fmt.Sprintf("allowed (%v)", err.(awserr.Error).Code())}
+	}
+	return DiagnosticResult{Check: check, Detail: err.Error()}
+}
+
+// RunDiagnostics checks the queue, lifecycle hooks, AWS permissions and Kubernetes RBAC lifecycle-manager needs. Checks
+// depending on a failed check are not run
+func RunDiagnostics(auth Authenticator, opts DiagnosticOptions) []DiagnosticResult {
+	results := make([]DiagnosticResult, 0)
+
+	queueURL, queueARN, queueResults := diagnoseQueue(auth, opts.QueueName)
+	results = append(results, queueResults...)
+
+	if queueURL != "" {
+		results = append(results, diagnoseQueuePermissions(auth, queueURL)...)
+	}
+	if queueARN != "" {
+		results = append(results, diagnoseHooks(auth, queueARN, opts.ScalingGroups)...)
+	}
+	results = append(results, diagnoseServicePermissions(auth, opts.ScalingGroups)...)
+
+	if opts.Kubernetes {
+		results = append(results, diagnoseKubernetes(auth)...)
+	}
+	return results
+}
+
+func diagnoseQueue(auth Authenticator, queueName string) (string, string, []DiagnosticResult) {
+	var (
+		check = fmt.Sprintf("queue %v exists", queueName)
+	)
+
+	out, err := auth.SQSClient.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String(queueName)})
+	if err != nil {
+		return "", "", []DiagnosticResult{{Check: check, Detail: err.Error()}}
+	}
+	queueURL := aws.StringValue(out.QueueUrl)
+
+	queueARN, err := getQueueARN(auth.SQSClient, queueURL)
+	if err != nil {
+		return queueURL, "", []DiagnosticResult{
+			{Check: check, Passed: true, Detail: queueURL},
+			{Check: "sqs:GetQueueAttributes", Detail: err.Error()},
+		}
+	}
+	return queueURL, queueARN, []DiagnosticResult{{Check: check, Passed: true, Detail: queueARN}}
+}
+
+func diagnoseQueuePermissions(auth Authenticator, queueURL string) []DiagnosticResult {
+	var (
+		queue = auth.SQSClient
+	)
+
+	// the wait time is out of range, messages would be returned to the queue right away if it were accepted
+	_, receiveErr := queue.ReceiveMessage(&sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(queueURL),
+		MaxNumberOfMessages: aws.Int64(1),
+		VisibilityTimeout:   aws.Int64(0),
+		WaitTimeSeconds:     aws.Int64(21),
+	})
+	_, visibilityErr := queue.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(queueURL),
+		ReceiptHandle:     aws.String(doctorActionToken),
+		VisibilityTimeout: aws.Int64(0),
+	})
+	_, deleteErr := queue.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(queueURL),
+		ReceiptHandle: aws.String(doctorActionToken),
+	})
+
+	return []DiagnosticResult{
+		probeResult("sqs:ReceiveMessage", receiveErr),
+		probeResult("sqs:ChangeMessageVisibility", visibilityErr),
+		probeResult("sqs:DeleteMessage", deleteErr),
+	}
+}
+
+// diagnoseHooks checks that each scaling group has a termination hook notifying the queue, without scaling groups
+// at least one scaling group of the region must have one
+func diagnoseHooks(auth Authenticator, queueARN string, scalingGroups []string) []DiagnosticResult {
+	var (
+		asgClient = auth.ScalingGroupClient
+		results   = make([]DiagnosticResult, 0)
+	)
+
+	if len(scalingGroups) == 0 {
+		names := make([]string, 0)
+		err := asgClient.DescribeAutoScalingGroupsPages(&autoscaling.DescribeAutoScalingGroupsInput{}, func(page *autoscaling.DescribeAutoScalingGroupsOutput, lastPage bool) bool {
+			for _, group := range page.AutoScalingGroups {
+				names = append(names, aws.StringValue(group.AutoScalingGroupName))
+			}
+			return true
+		})
+		if err != nil {
+			return []DiagnosticResult{probeResult("autoscaling:DescribeAutoScalingGroups", err)}
+		}
+
+		hooked := make([]string, 0)
+		for _, name := range names {
+			if _, ok, err := getQueueTerminationHook(asgClient, name, queueARN); err == nil && ok {
+				hooked = append(hooked, name)
+			}
+		}
+		if len(hooked) == 0 {
+			return []DiagnosticResult{{Check: "termination hooks", Detail: fmt.Sprintf("no scaling group has a termination hook notifying %v", queueARN)}}
+		}
+		return []DiagnosticResult{{Check: "termination hooks", Passed: true, Detail: strings.Join(hooked, ", ")}}
+	}
+
+	for _, name := range scalingGroups {
+		check := fmt.Sprintf("termination hook of %v", name)
+		hook, ok, err := getQueueTerminationHook(asgClient, name, queueARN)
+		switch {
+		case err != nil:
+			results = append(results, DiagnosticResult{Check: check, Detail: err.Error()})
+		case !ok:
+			results = append(results, DiagnosticResult{Check: check, Detail: fmt.Sprintf("no termination hook notifies %v", queueARN)})
+		case aws.StringValue(hook.RoleARN) == "":
+			results = append(results, DiagnosticResult{Check: check, Detail: fmt.Sprintf("hook %v has no notification role", aws.StringValue(hook.LifecycleHookName))})
+		default:
+			detail := fmt.Sprintf("%v, heartbeat timeout %vs, default result %v", aws.StringValue(hook.LifecycleHookName), aws.Int64Value(hook.HeartbeatTimeout), aws.StringValue(hook.DefaultResult))
+			results = append(results, DiagnosticResult{Check: check, Passed: true, Detail: detail})
+		}
+	}
+	return results
+}
+
+func diagnoseServicePermissions(auth Authenticator, scalingGroups []string) []DiagnosticResult {
+	var (
+		results      = make([]DiagnosticResult, 0)
+		scalingGroup = "lifecycle-manager-doctor"
+	)
+
+	if len(scalingGroups) > 0 {
+		scalingGroup = scalingGroups[0]
+	}
+
+	// the placeholder instance is not waiting on the hook, authorized calls fail validation
+	_, err := auth.ScalingGroupClient.CompleteLifecycleAction(&autoscaling.CompleteLifecycleActionInput{
+		AutoScalingGroupName:  aws.String(scalingGroup),
+		LifecycleHookName:     aws.String("lifecycle-manager-doctor"),
+		InstanceId:            aws.String(doctorInstanceID),
+		LifecycleActionToken:  aws.String(doctorActionToken),
+		LifecycleActionResult: aws.String(ContinueAction),
+	})
+	results = append(results, probeResult("autoscaling:CompleteLifecycleAction", err))
+
+	_, err = auth.ScalingGroupClient.RecordLifecycleActionHeartbeat(&autoscaling.RecordLifecycleActionHeartbeatInput{
+		AutoScalingGroupName: aws.String(scalingGroup),
+		LifecycleHookName:    aws.String("lifecycle-manager-doctor"),
+		InstanceId:           aws.String(doctorInstanceID),
+		LifecycleActionToken: aws.String(doctorActionToken),
+	})
+	results = append(results, probeResult("autoscaling:RecordLifecycleActionHeartbeat", err))
+
+	_, err = auth.ScalingGroupClient.DescribeLifecycleHooks(&autoscaling.DescribeLifecycleHooksInput{AutoScalingGroupName: aws.String(scalingGroup)})
+	results = append(results, probeResult("autoscaling:DescribeLifecycleHooks", err))
+
+	// dry runs of EC2 calls report whether the call would have been authorized
+	_, err = auth.EC2Client.DescribeInstances(&ec2.DescribeInstancesInput{DryRun: aws.Bool(true)})
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "DryRunOperation" {
+		err = nil
+	}
+	results = append(results, probeResult("ec2:DescribeInstances", err))
+
+	_, err = auth.ELBClient.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{PageSize: aws.Int64(1)})
+	results = append(results, probeResult("elasticloadbalancing:DescribeLoadBalancers", err))
+
+	_, err = auth.ELBv2Client.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{PageSize: aws.Int64(1)})
+	results = append(results, probeResult("elasticloadbalancing:DescribeTargetGroups", err))
+
+	return results
+}
+
+func diagnoseKubernetes(auth Authenticator) []DiagnosticResult {
+	var (
+		reviews = auth.KubernetesClient.AuthorizationV1().SelfSubjectAccessReviews()
+		results = make([]DiagnosticResult, 0)
+	)
+
+	for _, p := range kubernetesPermissions {
+		check := fmt.Sprintf("kubernetes %v %v", p.verb, p.resource)
+		if p.subresource != "" {
+			check += "/" + p.subresource
+		}
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:       p.group,
+					Resource:    p.resource,
+					Subresource: p.subresource,
+					Verb:        p.verb,
+				},
+			},
+		}
+		out, err := reviews.Create(context.Background(), review, metav1.CreateOptions{})
+		switch {
+		case err != nil:
+			results = append(results, DiagnosticResult{Check: check, Detail: err.Error()})
+		case !out.Status.Allowed:
+			results = append(results, DiagnosticResult{Check: check, Detail: fmt.Sprintf("denied %v", out.Status.Reason)})
+		default:
+			results = append(results, DiagnosticResult{Check: check, Passed: true, Detail: "allowed"})
+		}
+	}
+	return results
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_ProbeResult(t *testing.T) {
+	t.Log("Test_ProbeResult: should only pass probes which reached the API and were not denied")
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"allowed", nil, true},
+		{"validation error", awserr.NewRequestFailure(awserr.New("ValidationError", "no active lifecycle action", nil), 400, ""), true},
+		{"access denied", awserr.NewRequestFailure(awserr.New("AccessDenied", "not authorized", nil), 403, ""), false},
+		{"unauthorized operation", awserr.NewRequestFailure(awserr.New("UnauthorizedOperation", "not authorized", nil), 403, ""), false},
+		{"network error", errors.New("dial tcp: connection refused"), false},
+	}
+
+	for _, tc := range tests {
+		if got := probeResult(tc.name, tc.err); got.Passed != tc.expected {
+			t.Fatalf("%v: expected passed: %v, got: %+v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func Test_DiagnoseHooks(t *testing.T) {
+	t.Log("Test_DiagnoseHooks: should fail scaling groups without a termination hook notifying the queue")
+	queueARN := "arn:aws:sqs:us-west-2:000000000000:my-queue"
+	stubber := &stubAutoscaling{
+		scalingGroups: []*autoscaling.Group{{AutoScalingGroupName: aws.String("my-asg")}},
+		lifecycleHooks: []*autoscaling.LifecycleHook{
+			{
+				LifecycleHookName:     aws.String("my-hook"),
+				LifecycleTransition:   aws.String(TerminationEventName),
+				NotificationTargetARN: aws.String(queueARN),
+				RoleARN:               aws.String("arn:aws:iam::000000000000:role/my-notification-role"),
+			},
+		},
+	}
+	auth := Authenticator{ScalingGroupClient: stubber}
+
+	results := diagnoseHooks(auth, queueARN, []string{"my-asg"})
+	if len(results) != 1 || !results[0].Passed {
+		t.Fatalf("expected hook check to pass, got: %+v", results)
+	}
+
+	results = diagnoseHooks(auth, queueARN, nil)
+	if len(results) != 1 || !results[0].Passed || results[0].Detail != "my-asg" {
+		t.Fatalf("expected hooked scaling groups to be discovered, got: %+v", results)
+	}
+
+	results = diagnoseHooks(auth, "arn:aws:sqs:us-west-2:000000000000:other-queue", []string{"my-asg"})
+	if len(results) != 1 || results[0].Passed {
+		t.Fatalf("expected hook check of another queue to fail, got: %+v", results)
+	}
+}
+
+func Test_DiagnoseKubernetes(t *testing.T) {
+	t.Log("Test_DiagnoseKubernetes: should report the RBAC permissions denied to lifecycle-manager")
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Subresource != "eviction"
+		return true, review, nil
+	})
+
+	results := diagnoseKubernetes(Authenticator{KubernetesClient: kubeClient})
+	if len(results) != len(kubernetesPermissions) {
+		t.Fatalf("expected results: %v, got: %v", len(kubernetesPermissions), len(results))
+	}
+	for _, r := range results {
+		expected := r.Check != "kubernetes create pods/eviction"
+		if r.Passed != expected {
+			t.Fatalf("%v: expected passed: %v, got: %+v", r.Check, expected, r)
+		}
+	}
+}
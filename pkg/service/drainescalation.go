@@ -0,0 +1,38 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"k8s.io/client-go/kubernetes"
+)
+
+// runStagedDrain drains the event's node by evicting its pods, honoring their pod disruption budgets, until the drain
+// escalation timeout, then deletes the pods remaining with the escalation grace period until the drain timeout, so
+// that a single stuck pod does not fail the drain. Nodes are drained by eviction only when escalation is not enabled
+// or its timeout is not shorter than the drain timeout
+func (mgr *Manager) runStagedDrain(event *LifecycleEvent, kubeClient kubernetes.Interface, timeout, retryInterval int64, retryAttempts uint, observer *drainObserver) error {
+	var (
+		settings = event.settings
+		policy   = mgr.drainPolicy(event)
+		soft     = settings.DrainEscalationTimeoutSeconds
+	)
+
+	if soft <= 0 || soft >= timeout {
+		return drainNode(event.Context(), kubeClient, &event.referencedNode, timeout, retryInterval, retryAttempts, policy, observer)
+	}
+
+	err := drainNode(event.Context(), kubeClient, &event.referencedNode, soft, retryInterval, 1, policy, observer)
+	if err == nil || event.Context().Err() != nil {
+		return err
+	}
+
+	log.Warnf("%v> eviction from node/%v did not complete within %vs, deleting remaining pods: %v", event.EC2InstanceID, event.referencedNode.Name, soft, err)
+	mgr.metrics.AddCounter(EscalatedNodeDrainTotalMetric, eventLabels(event), 1)
+	msg := fmt.Sprintf(EventMessageNodeDrainEscalated, event.referencedNode.Name, soft, settings.DrainEscalationGracePeriodSeconds)
+	mgr.publishEvent(event, EventReasonNodeDrainEscalated, getMessageFields(event, msg))
+
+	policy.disableEviction = true
+	policy.gracePeriodSeconds = int(settings.DrainEscalationGracePeriodSeconds)
+	return drainNode(event.Context(), kubeClient, &event.referencedNode, timeout-soft, retryInterval, retryAttempts, policy, observer)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_RunStagedDrain(t *testing.T) {
+	t.Log("Test_RunStagedDrain: should delete the pods which could not be evicted once the escalation timeout passes")
+	tests := []struct {
+		escalationTimeout int64
+		expectDrained     bool
+	}{
+		{0, false},
+		{10, false},
+		{1, true},
+	}
+
+	for _, tc := range tests {
+		kubeClient := fake.NewSimpleClientset()
+		kubeClient.Fake.Resources = []*metav1.APIResourceList{
+			{GroupVersion: "policy/v1"},
+			{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{{Name: "pods/eviction", Kind: "Eviction", Group: "policy", Version: "v1"}},
+			},
+		}
+		// evictions are refused, as they are while a pod disruption budget would be violated
+		kubeClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "eviction" {
+				return false, nil, nil
+			}
+			return true, nil, apierrors.NewInternalError(errors.New("eviction refused"))
+		})
+
+		node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+		kubeClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"},
+			Spec:       v1.PodSpec{NodeName: "node-1"},
+		}
+		kubeClient.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{})
+
+		mgr := New(Authenticator{KubernetesClient: kubeClient}, _newBasicContext())
+		event := &LifecycleEvent{EC2InstanceID: "i-123486890234"}
+		event.SetContext(context.WithCancel(context.Background()))
+		event.SetReferencedNode(*node)
+		event.SetSettings(EventSettings{DrainEscalationTimeoutSeconds: tc.escalationTimeout})
+
+		err := mgr.runStagedDrain(event, kubeClient, 10, 0, 1, nil)
+		if drained := err == nil; drained != tc.expectDrained {
+			t.Fatalf("expected drain with escalation timeout %v to succeed: %v, got: %v", tc.escalationTimeout, tc.expectDrained, err)
+		}
+
+		_, err = kubeClient.CoreV1().Pods("default").Get(context.Background(), "pod-1", metav1.GetOptions{})
+		if deleted := apierrors.IsNotFound(err); deleted != tc.expectDrained {
+			t.Fatalf("expected pod deleted with escalation timeout %v: %v, got: %v", tc.escalationTimeout, tc.expectDrained, deleted)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// drainQueueItem is an event waiting for a drain slot
+type drainQueueItem struct {
+	event    *LifecycleEvent
+	deadline time.Time
+	sequence uint64
+	index    int
+}
+
+// drainQueueHeap orders waiting events by deadline, events without a deadline are ordered last by arrival
+type drainQueueHeap []*drainQueueItem
+
+func (h drainQueueHeap) Len() int { return len(h) }
+
+func (h drainQueueHeap) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	switch {
+	case a.deadline.IsZero() != b.deadline.IsZero():
+		return !a.deadline.IsZero()
+	case !a.deadline.Equal(b.deadline):
+		return a.deadline.Before(b.deadline)
+	}
+	return a.sequence < b.sequence
+}
+
+func (h drainQueueHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *drainQueueHeap) Push(x interface{}) {
+	item := x.(*drainQueueItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *drainQueueHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	item.index = -1
+	return item
+}
+
+// DrainQueue hands out drain slots to waiting events in order of their remaining lifecycle deadline,
+// so that urgent terminations are drained first when drain concurrency is limited
+type DrainQueue struct {
+	sync.Mutex
+	items    drainQueueHeap
+	sequence uint64
+	wake     chan struct{}
+}
+
+// NewDrainQueue returns an empty drain queue
+func NewDrainQueue() *DrainQueue {
+	return &DrainQueue{
+		items: drainQueueHeap{},
+		wake:  make(chan struct{}),
+	}
+}
+
+// Len returns the number of waiting events
+func (q *DrainQueue) Len() int {
+	q.Lock()
+	defer q.Unlock()
+	return q.items.Len()
+}
+
+// Acquire blocks until the event is the most urgent waiting event and a slot of sem is free, or the event is done
+func (q *DrainQueue) Acquire(event *LifecycleEvent, sem *semaphore.Weighted) error {
+	q.Lock()
+	deadline, _ := event.deadline()
+	item := &drainQueueItem{event: event, deadline: deadline, sequence: q.sequence}
+	q.sequence++
+	heap.Push(&q.items, item)
+	q.Unlock()
+
+	for {
+		q.Lock()
+		wake := q.wake
+		head := q.items[0] == item
+		q.Unlock()
+
+		if head && sem.TryAcquire(1) {
+			q.remove(item)
+			return nil
+		}
+
+		select {
+		case <-wake:
+		case <-event.Context().Done():
+			q.remove(item)
+			return event.Context().Err()
+		}
+	}
+}
+
+// Notify wakes up waiting events, it is called whenever a slot is released
+func (q *DrainQueue) Notify() {
+	q.Lock()
+	defer q.Unlock()
+	close(q.wake)
+	q.wake = make(chan struct{})
+}
+
+// remove drops an item from the queue and wakes up the next waiting event
+func (q *DrainQueue) remove(item *drainQueueItem) {
+	q.Lock()
+	if item.index >= 0 {
+		heap.Remove(&q.items, item.index)
+	}
+	q.Unlock()
+	q.Notify()
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+func Test_DrainQueueDeadlineOrder(t *testing.T) {
+	t.Log("Test_DrainQueueDeadlineOrder: should hand out drain slots to the events with the earliest deadline first")
+	var (
+		sem     = semaphore.NewWeighted(1)
+		queue   = NewDrainQueue()
+		results = make(chan string, 3)
+	)
+
+	newEvent := func(instanceID string, timeout time.Duration) *LifecycleEvent {
+		event := &LifecycleEvent{EC2InstanceID: instanceID}
+		if timeout > 0 {
+			event.SetContext(context.WithTimeout(context.Background(), timeout))
+		} else {
+			event.SetContext(context.WithCancel(context.Background()))
+		}
+		return event
+	}
+
+	events := []*LifecycleEvent{
+		newEvent("i-leisurely", 0),
+		newEvent("i-hour", time.Hour),
+		newEvent("i-urgent", 2*time.Minute),
+	}
+
+	sem.TryAcquire(1)
+	for i, event := range events {
+		go func(event *LifecycleEvent) {
+			if err := queue.Acquire(event, sem); err != nil {
+				t.Errorf("Acquire: expected error not to have occured, %v", err)
+				return
+			}
+			results <- event.EC2InstanceID
+		}(event)
+		for queue.Len() != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for _, expected := range []string{"i-urgent", "i-hour", "i-leisurely"} {
+		sem.Release(1)
+		queue.Notify()
+		select {
+		case got := <-results:
+			if got != expected {
+				t.Fatalf("expected drain slot for: %v, got: %v", expected, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected drain slot for: %v, got: none", expected)
+		}
+	}
+
+	for _, event := range events {
+		event.cancel()
+	}
+}
+
+func Test_DrainQueueCancel(t *testing.T) {
+	t.Log("Test_DrainQueueCancel: should stop waiting once the event is done")
+	var (
+		sem   = semaphore.NewWeighted(1)
+		queue = NewDrainQueue()
+	)
+
+	event := &LifecycleEvent{EC2InstanceID: "i-123456789012"}
+	event.SetContext(context.WithTimeout(context.Background(), 10*time.Millisecond))
+	defer event.cancel()
+
+	sem.TryAcquire(1)
+	if err := queue.Acquire(event, sem); err == nil {
+		t.Fatalf("Acquire: expected error to have occured")
+	}
+
+	if queue.Len() != 0 {
+		t.Fatalf("expected waiting events: %v, got: %v", 0, queue.Len())
+	}
+}
@@ -1,17 +1,19 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elb/elbiface"
-	iebackoff "github.com/keikoproj/inverse-exp-backoff"
 
 	"github.com/keikoproj/lifecycle-manager/pkg/log"
 )
 
-func waitForDeregisterInstance(event *LifecycleEvent, elbClient elbiface.ELBAPI, elbName, instanceID string) error {
+func waitForDeregisterInstance(event *LifecycleEvent, elbClient elbiface.ELBAPI, elbName, instanceID string, config WaiterConfig) error {
 	var (
 		found bool
 	)
@@ -20,14 +22,23 @@ func waitForDeregisterInstance(event *LifecycleEvent, elbClient elbiface.ELBAPI,
 		LoadBalancerName: aws.String(elbName),
 	}
 
-	for ieb, err := iebackoff.NewIEBackoff(WaiterMaxDelay, WaiterMinDelay, 0.5, WaiterMaxAttempts); err == nil; err = ieb.Next() {
+	for ieb, err := config.newBackoff(event.Context()); err == nil; err = ieb.Next() {
 
-		if event.eventCompleted {
+		if event.isCompleted() {
 			return errors.New("event finished execution during deregistration wait")
 		}
 
+		if err := event.contextError("deregistration wait"); err != nil {
+			return err
+		}
+
+		// stop before the lifecycle hook expires rather than waiting past it
+		if remaining, ok := event.remainingTime(); ok && remaining <= config.maxDelay() {
+			return fmt.Errorf("lifecycle hook deadline in %v reached during deregistration wait", remaining.Round(time.Second))
+		}
+
 		found = false
-		instances, err := elbClient.DescribeInstanceHealth(input)
+		instances, err := elbClient.DescribeInstanceHealthWithContext(event.Context(), input)
 		if err != nil {
 			return err
 		}
@@ -51,25 +62,53 @@ func waitForDeregisterInstance(event *LifecycleEvent, elbClient elbiface.ELBAPI,
 	return err
 }
 
-func findInstanceInClassicBalancer(elbClient elbiface.ELBAPI, elbName, instanceID string) (bool, error) {
-	input := &elb.DescribeInstanceHealthInput{
+// getClassicBalancerDrainingTimeout returns the connection draining timeout of a classic-elb, which is 0 when connection
+// draining is disabled and instances are out of service as soon as they are deregistered
+func getClassicBalancerDrainingTimeout(ctx context.Context, elbClient elbiface.ELBAPI, elbName string) (time.Duration, error) {
+	out, err := elbClient.DescribeLoadBalancerAttributesWithContext(ctx, &elb.DescribeLoadBalancerAttributesInput{
 		LoadBalancerName: aws.String(elbName),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if out.LoadBalancerAttributes == nil || out.LoadBalancerAttributes.ConnectionDraining == nil {
+		return 0, nil
 	}
+	draining := out.LoadBalancerAttributes.ConnectionDraining
+	if !aws.BoolValue(draining.Enabled) {
+		return 0, nil
+	}
+	return time.Duration(aws.Int64Value(draining.Timeout)) * time.Second, nil
+}
 
-	instance, err := elbClient.DescribeInstanceHealth(input)
+func findInstanceInClassicBalancer(ctx context.Context, elbClient elbiface.ELBAPI, elbName, instanceID string) (bool, error) {
+	members, err := getClassicBalancerMembers(ctx, elbClient, elbName)
 	if err != nil {
 		log.Errorf("%v> failed finding instance in elb %v: %v", instanceID, elbName, err.Error())
 		return false, err
 	}
+	_, ok := members[instanceID]
+	return ok, nil
+}
+
+// getClassicBalancerMembers returns the registered instances of a classic elb
+func getClassicBalancerMembers(ctx context.Context, elbClient elbiface.ELBAPI, elbName string) (map[string]int64, error) {
+	input := &elb.DescribeInstanceHealthInput{
+		LoadBalancerName: aws.String(elbName),
+	}
+
+	members := make(map[string]int64)
+	instance, err := elbClient.DescribeInstanceHealthWithContext(ctx, input)
+	if err != nil {
+		return members, err
+	}
 	for _, state := range instance.InstanceStates {
-		if aws.StringValue(state.InstanceId) == instanceID {
-			return true, nil
-		}
+		members[aws.StringValue(state.InstanceId)] = 0
 	}
-	return false, nil
+	return members, nil
 }
 
-func deregisterInstances(elbClient elbiface.ELBAPI, elbName string, instances []string) error {
+func deregisterInstances(ctx context.Context, elbClient elbiface.ELBAPI, elbName string, instances []string) error {
 	targets := []*elb.Instance{}
 	for _, instance := range instances {
 		target := &elb.Instance{
@@ -83,9 +122,38 @@ func deregisterInstances(elbClient elbiface.ELBAPI, elbName string, instances []
 		Instances:        targets,
 	}
 
-	_, err := elbClient.DeregisterInstancesFromLoadBalancer(input)
+	_, err := elbClient.DeregisterInstancesFromLoadBalancerWithContext(ctx, input)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// getClassicBalancersByTags returns the set of classic elb names carrying all filter tags
+func getClassicBalancersByTags(ctx context.Context, elbClient elbiface.ELBAPI, names []string, filters map[string]string) (map[string]bool, error) {
+	matched := make(map[string]bool)
+	for start := 0; start < len(names); start += DescribeTagsBatchSize {
+		end := start + DescribeTagsBatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+
+		out, err := elbClient.DescribeTagsWithContext(ctx, &elb.DescribeTagsInput{
+			LoadBalancerNames: aws.StringSlice(names[start:end]),
+		})
+		if err != nil {
+			return matched, err
+		}
+
+		for _, desc := range out.TagDescriptions {
+			tags := make(map[string]string)
+			for _, tag := range desc.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+			if matchesTagFilters(tags, filters) {
+				matched[aws.StringValue(desc.LoadBalancerName)] = true
+			}
+		}
+	}
+	return matched, nil
+}
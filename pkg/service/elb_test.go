@@ -1,12 +1,14 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elb/elbiface"
 )
@@ -18,6 +20,23 @@ type stubELB struct {
 	timesCalledDescribeInstanceHealth int
 	timesCalledDeregisterInstances    int
 	timesCalledDescribeLoadBalancers  int
+	tagDescriptions                   []*elb.TagDescription
+	timesCalledDescribeTags           int
+	connectionDraining                *elb.ConnectionDraining
+}
+
+func (e *stubELB) DescribeLoadBalancerAttributesWithContext(ctx aws.Context, input *elb.DescribeLoadBalancerAttributesInput, opts ...request.Option) (*elb.DescribeLoadBalancerAttributesOutput, error) {
+	attributes := &elb.LoadBalancerAttributes{ConnectionDraining: e.connectionDraining}
+	return &elb.DescribeLoadBalancerAttributesOutput{LoadBalancerAttributes: attributes}, nil
+}
+
+func (e *stubELB) DescribeTags(input *elb.DescribeTagsInput) (*elb.DescribeTagsOutput, error) {
+	e.timesCalledDescribeTags++
+	return &elb.DescribeTagsOutput{TagDescriptions: e.tagDescriptions}, nil
+}
+
+func (e *stubELB) DescribeTagsWithContext(ctx aws.Context, input *elb.DescribeTagsInput, opts ...request.Option) (*elb.DescribeTagsOutput, error) {
+	return e.DescribeTags(input)
 }
 
 func (e *stubELB) DescribeInstanceHealth(input *elb.DescribeInstanceHealthInput) (*elb.DescribeInstanceHealthOutput, error) {
@@ -25,11 +44,19 @@ func (e *stubELB) DescribeInstanceHealth(input *elb.DescribeInstanceHealthInput)
 	return &elb.DescribeInstanceHealthOutput{InstanceStates: e.instanceStates}, nil
 }
 
+func (e *stubELB) DescribeInstanceHealthWithContext(ctx aws.Context, input *elb.DescribeInstanceHealthInput, opts ...request.Option) (*elb.DescribeInstanceHealthOutput, error) {
+	return e.DescribeInstanceHealth(input)
+}
+
 func (e *stubELB) DeregisterInstancesFromLoadBalancer(input *elb.DeregisterInstancesFromLoadBalancerInput) (*elb.DeregisterInstancesFromLoadBalancerOutput, error) {
 	e.timesCalledDeregisterInstances++
 	return &elb.DeregisterInstancesFromLoadBalancerOutput{}, nil
 }
 
+func (e *stubELB) DeregisterInstancesFromLoadBalancerWithContext(ctx aws.Context, input *elb.DeregisterInstancesFromLoadBalancerInput, opts ...request.Option) (*elb.DeregisterInstancesFromLoadBalancerOutput, error) {
+	return e.DeregisterInstancesFromLoadBalancer(input)
+}
+
 func (e *stubELB) DescribeLoadBalancers(input *elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error) {
 	e.timesCalledDescribeLoadBalancers++
 	return &elb.DescribeLoadBalancersOutput{LoadBalancerDescriptions: e.loadBalancerDescriptions}, nil
@@ -46,6 +73,10 @@ func (e *stubELB) DescribeLoadBalancersPages(input *elb.DescribeLoadBalancersInp
 	return nil
 }
 
+func (e *stubELB) DescribeLoadBalancersPagesWithContext(ctx aws.Context, input *elb.DescribeLoadBalancersInput, callback func(*elb.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	return e.DescribeLoadBalancersPages(input, callback)
+}
+
 type stubErrorELB struct {
 	elbiface.ELBAPI
 	instanceStates                    []*elb.InstanceState
@@ -67,6 +98,14 @@ func (e *stubErrorELB) DescribeInstanceHealth(input *elb.DescribeInstanceHealthI
 	return &elb.DescribeInstanceHealthOutput{}, err
 }
 
+func (e *stubErrorELB) DescribeLoadBalancerAttributesWithContext(ctx aws.Context, input *elb.DescribeLoadBalancerAttributesInput, opts ...request.Option) (*elb.DescribeLoadBalancerAttributesOutput, error) {
+	return &elb.DescribeLoadBalancerAttributesOutput{}, nil
+}
+
+func (e *stubErrorELB) DescribeInstanceHealthWithContext(ctx aws.Context, input *elb.DescribeInstanceHealthInput, opts ...request.Option) (*elb.DescribeInstanceHealthOutput, error) {
+	return e.DescribeInstanceHealth(input)
+}
+
 func (e *stubErrorELB) DeregisterInstancesFromLoadBalancer(input *elb.DeregisterInstancesFromLoadBalancerInput) (*elb.DeregisterInstancesFromLoadBalancerOutput, error) {
 	e.timesCalledDeregisterInstances++
 	var err error
@@ -80,6 +119,10 @@ func (e *stubErrorELB) DeregisterInstancesFromLoadBalancer(input *elb.Deregister
 	return &elb.DeregisterInstancesFromLoadBalancerOutput{}, err
 }
 
+func (e *stubErrorELB) DeregisterInstancesFromLoadBalancerWithContext(ctx aws.Context, input *elb.DeregisterInstancesFromLoadBalancerInput, opts ...request.Option) (*elb.DeregisterInstancesFromLoadBalancerOutput, error) {
+	return e.DeregisterInstancesFromLoadBalancer(input)
+}
+
 func (e *stubErrorELB) DescribeLoadBalancers(input *elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error) {
 	e.timesCalledDescribeLoadBalancers++
 	return &elb.DescribeLoadBalancersOutput{LoadBalancerDescriptions: e.loadBalancerDescriptions}, nil
@@ -96,6 +139,10 @@ func (e *stubErrorELB) DescribeLoadBalancersPages(input *elb.DescribeLoadBalance
 	return nil
 }
 
+func (e *stubErrorELB) DescribeLoadBalancersPagesWithContext(ctx aws.Context, input *elb.DescribeLoadBalancersInput, callback func(*elb.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	return e.DescribeLoadBalancersPages(input, callback)
+}
+
 func Test_DeregisterInstance(t *testing.T) {
 	t.Log("Test_DeregisterInstance: should be able to deregister an instance from classic elb")
 	var (
@@ -105,7 +152,7 @@ func Test_DeregisterInstance(t *testing.T) {
 		expectedCalls = 1
 	)
 
-	err := deregisterInstances(stubber, elbName, instances)
+	err := deregisterInstances(context.Background(), stubber, elbName, instances)
 	if err != nil {
 		t.Fatalf("Test_DeregisterInstance: expected error not to have occured, %v", err)
 	}
@@ -125,7 +172,7 @@ func Test_DeregisterNotFoundException(t *testing.T) {
 	)
 
 	stubber.failHint = elb.ErrCodeAccessPointNotFoundException
-	err := deregisterInstances(stubber, elbName, instances)
+	err := deregisterInstances(context.Background(), stubber, elbName, instances)
 	if err == nil {
 		t.Fatalf("Test_DeregisterInstance: expected error to have occured, got: %v", err)
 	}
@@ -145,7 +192,7 @@ func Test_DeregisterInvalidException(t *testing.T) {
 	)
 
 	stubber.failHint = elb.ErrCodeInvalidEndPointException
-	err := deregisterInstances(stubber, elbName, instances)
+	err := deregisterInstances(context.Background(), stubber, elbName, instances)
 	if err == nil {
 		t.Fatalf("Test_DeregisterInstance: expected error to have occured, got: %v", err)
 	}
@@ -174,7 +221,7 @@ func Test_DeregisterWaiterAbort(t *testing.T) {
 	}
 
 	go _completeEventAfter(event, time.Millisecond*1500)
-	err := waitForDeregisterInstance(event, stubber, elbName, instanceID)
+	err := waitForDeregisterInstance(event, stubber, elbName, instanceID, WaiterConfig{})
 	if err == nil {
 		t.Fatalf("Test_DeregisterWaiterAbort: expected error to have occured, got: %v", err)
 	}
@@ -197,7 +244,7 @@ func Test_DeregisterWaiterFail(t *testing.T) {
 		failHint: elb.ErrCodeAccessPointNotFoundException,
 	}
 
-	err := waitForDeregisterInstance(event, stubber, elbName, instanceID)
+	err := waitForDeregisterInstance(event, stubber, elbName, instanceID, WaiterConfig{})
 	if err == nil {
 		t.Fatalf("Test_DeregisterWaiterFail: expected error to have occured, got: %v", err)
 	}
@@ -225,7 +272,7 @@ func Test_DeregisterWaiterNotFound(t *testing.T) {
 		},
 	}
 
-	err := waitForDeregisterInstance(event, stubber, elbName, instanceID)
+	err := waitForDeregisterInstance(event, stubber, elbName, instanceID, WaiterConfig{})
 	if err != nil {
 		t.Fatalf("Test_DeregisterWaiterNotFound: expected error not to have occured, got: %v", err)
 	}
@@ -253,7 +300,7 @@ func Test_DeregisterWaiterTimeout(t *testing.T) {
 		},
 	}
 
-	err := waitForDeregisterInstance(event, stubber, elbName, instanceID)
+	err := waitForDeregisterInstance(event, stubber, elbName, instanceID, WaiterConfig{})
 	if err == nil {
 		t.Fatalf("Test_DeregisterWaiterTimeout: expected error to have occured, got: %v", err)
 	}
@@ -278,7 +325,7 @@ func Test_FindInstanceInClassicBalancerPositive(t *testing.T) {
 		},
 	}
 
-	found, err := findInstanceInClassicBalancer(stubber, elbName, instanceID)
+	found, err := findInstanceInClassicBalancer(context.Background(), stubber, elbName, instanceID)
 	if err != nil {
 		t.Fatalf("Test_FindInstanceInClassicBalancerPositive: expected error not to have occured, %v", err)
 	}
@@ -299,7 +346,7 @@ func Test_FindInstanceInClassicBalancerNegative(t *testing.T) {
 		stubber       = &stubELB{}
 	)
 
-	found, err := findInstanceInClassicBalancer(stubber, elbName, instanceID)
+	found, err := findInstanceInClassicBalancer(context.Background(), stubber, elbName, instanceID)
 	if err != nil {
 		t.Fatalf("Test_FindInstanceInClassicBalancerNegative: expected error not to have occured, %v", err)
 	}
@@ -321,7 +368,7 @@ func Test_FindInstanceInClassicBalancerError(t *testing.T) {
 	)
 
 	stubber.failHint = elb.ErrCodeAccessPointNotFoundException
-	found, err := findInstanceInClassicBalancer(stubber, elbName, instanceID)
+	found, err := findInstanceInClassicBalancer(context.Background(), stubber, elbName, instanceID)
 	if err == nil {
 		t.Fatalf("Test_FindInstanceInClassicBalancerError: expected error to have occured, got: %v", err)
 	}
@@ -332,3 +379,65 @@ func Test_FindInstanceInClassicBalancerError(t *testing.T) {
 		t.Fatalf("Test_FindInstanceInClassicBalancerError: expected instance not to be found")
 	}
 }
+
+func Test_GetClassicBalancersByTags(t *testing.T) {
+	t.Log("Test_GetClassicBalancersByTags: should only match classic elbs carrying the filter tags")
+	stubber := &stubELB{
+		tagDescriptions: []*elb.TagDescription{
+			{
+				LoadBalancerName: aws.String("owned-elb"),
+				Tags:             []*elb.Tag{{Key: aws.String("kubernetes.io/cluster/my-cluster"), Value: aws.String("owned")}},
+			},
+			{
+				LoadBalancerName: aws.String("other-elb"),
+				Tags:             []*elb.Tag{{Key: aws.String("kubernetes.io/cluster/other-cluster"), Value: aws.String("owned")}},
+			},
+		},
+	}
+
+	matched, err := getClassicBalancersByTags(context.Background(), stubber, []string{"owned-elb", "other-elb"}, map[string]string{"kubernetes.io/cluster/my-cluster": "owned"})
+	if err != nil {
+		t.Fatalf("getClassicBalancersByTags: expected error not to have occured, %v", err)
+	}
+
+	if !matched["owned-elb"] || matched["other-elb"] {
+		t.Fatalf("expected matched classic-elbs: %v, got: %v", map[string]bool{"owned-elb": true}, matched)
+	}
+}
+
+func Test_GetClassicBalancerDrainingTimeout(t *testing.T) {
+	t.Log("Test_GetClassicBalancerDrainingTimeout: should return the connection draining timeout, 0 when draining is disabled")
+	ctx := context.Background()
+
+	stubber := &stubELB{
+		connectionDraining: &elb.ConnectionDraining{Enabled: aws.Bool(true), Timeout: aws.Int64(300)},
+	}
+	timeout, err := getClassicBalancerDrainingTimeout(ctx, stubber, "some-load-balancer")
+	if err != nil || timeout != 300*time.Second {
+		t.Fatalf("expected timeout: %v, got: %v (err: %v)", 300*time.Second, timeout, err)
+	}
+
+	stubber.connectionDraining = &elb.ConnectionDraining{Enabled: aws.Bool(false), Timeout: aws.Int64(300)}
+	timeout, err = getClassicBalancerDrainingTimeout(ctx, stubber, "some-load-balancer")
+	if err != nil || timeout != 0 {
+		t.Fatalf("expected timeout: %v, got: %v (err: %v)", 0, timeout, err)
+	}
+}
+
+func Test_WaiterConfigForTimeout(t *testing.T) {
+	t.Log("Test_WaiterConfigForTimeout: should size the waiter attempts to cover a timeout within the configured attempts")
+	config := WaiterConfig{MinDelay: 10 * time.Second, MaxDelay: 80 * time.Second, MaxAttempts: 20}
+
+	// 80s + 40s + 20s + 10s + 10s
+	if attempts := config.forTimeout(160 * time.Second).MaxAttempts; attempts != 5 {
+		t.Fatalf("expected attempts: %v, got: %v", 5, attempts)
+	}
+
+	if attempts := config.forTimeout(0).MaxAttempts; attempts != 1 {
+		t.Fatalf("expected attempts: %v, got: %v", 1, attempts)
+	}
+
+	if attempts := config.forTimeout(time.Hour).MaxAttempts; attempts != 20 {
+		t.Fatalf("expected attempts: %v, got: %v", 20, attempts)
+	}
+}
@@ -1,17 +1,44 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
-	iebackoff "github.com/keikoproj/inverse-exp-backoff"
 
 	"github.com/keikoproj/lifecycle-manager/pkg/log"
 )
 
-func waitForDeregisterTarget(event *LifecycleEvent, elbClient elbv2iface.ELBV2API, arn, instanceID string, port int64) error {
+// TargetGroupDeregistrationDelayAttribute is the target group attribute holding its deregistration delay in seconds
+const TargetGroupDeregistrationDelayAttribute = "deregistration_delay.timeout_seconds"
+
+// getTargetGroupDeregistrationDelay returns how long a target group keeps draining deregistered targets
+func getTargetGroupDeregistrationDelay(ctx context.Context, elbClient elbv2iface.ELBV2API, arn string) (time.Duration, error) {
+	out, err := elbClient.DescribeTargetGroupAttributesWithContext(ctx, &elbv2.DescribeTargetGroupAttributesInput{
+		TargetGroupArn: aws.String(arn),
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, attribute := range out.Attributes {
+		if aws.StringValue(attribute.Key) != TargetGroupDeregistrationDelayAttribute {
+			continue
+		}
+		seconds, err := strconv.ParseInt(aws.StringValue(attribute.Value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %v attribute '%v'", TargetGroupDeregistrationDelayAttribute, aws.StringValue(attribute.Value))
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, fmt.Errorf("target group has no %v attribute", TargetGroupDeregistrationDelayAttribute)
+}
+
+func waitForDeregisterTarget(event *LifecycleEvent, elbClient elbv2iface.ELBV2API, arn, instanceID string, port int64, config WaiterConfig) error {
 	var (
 		found bool
 	)
@@ -20,14 +47,23 @@ func waitForDeregisterTarget(event *LifecycleEvent, elbClient elbv2iface.ELBV2AP
 		TargetGroupArn: aws.String(arn),
 	}
 
-	for ieb, err := iebackoff.NewIEBackoff(WaiterMaxDelay, WaiterMinDelay, 0.5, WaiterMaxAttempts); err == nil; err = ieb.Next() {
+	for ieb, err := config.newBackoff(event.Context()); err == nil; err = ieb.Next() {
 
-		if event.eventCompleted {
+		if event.isCompleted() {
 			return errors.New("event finished execution during deregistration wait")
 		}
 
+		if err := event.contextError("deregistration wait"); err != nil {
+			return err
+		}
+
+		// stop before the lifecycle hook expires rather than waiting past it
+		if remaining, ok := event.remainingTime(); ok && remaining <= config.maxDelay() {
+			return fmt.Errorf("lifecycle hook deadline in %v reached during deregistration wait", remaining.Round(time.Second))
+		}
+
 		found = false
-		targets, err := elbClient.DescribeTargetHealth(input)
+		targets, err := elbClient.DescribeTargetHealthWithContext(event.Context(), input)
 		if err != nil {
 			return err
 		}
@@ -51,26 +87,38 @@ func waitForDeregisterTarget(event *LifecycleEvent, elbClient elbv2iface.ELBV2AP
 	return err
 }
 
-func findInstanceInTargetGroup(elbClient elbv2iface.ELBV2API, arn, instanceID string) (bool, int64, error) {
+func findInstanceInTargetGroup(ctx context.Context, elbClient elbv2iface.ELBV2API, arn, instanceID string) (bool, int64, error) {
+	members, err := getTargetGroupMembers(ctx, elbClient, arn)
+	if err != nil {
+		log.Errorf("%v> failed finding instance in target group %v: %v", instanceID, arn, err.Error())
+		return false, 0, err
+	}
+	port, ok := members[instanceID]
+	return ok, port, nil
+}
+
+// getTargetGroupMembers returns the registered instances of a target group mapped to their port
+func getTargetGroupMembers(ctx context.Context, elbClient elbv2iface.ELBV2API, arn string) (map[string]int64, error) {
 	input := &elbv2.DescribeTargetHealthInput{
 		TargetGroupArn: aws.String(arn),
 	}
 
-	target, err := elbClient.DescribeTargetHealth(input)
+	members := make(map[string]int64)
+	target, err := elbClient.DescribeTargetHealthWithContext(ctx, input)
 	if err != nil {
-		log.Errorf("%v> failed finding instance in target group %v: %v", instanceID, arn, err.Error())
-		return false, 0, err
+		return members, err
 	}
 	for _, desc := range target.TargetHealthDescriptions {
-		if aws.StringValue(desc.Target.Id) == instanceID {
-			port := aws.Int64Value(desc.Target.Port)
-			return true, port, nil
+		id := aws.StringValue(desc.Target.Id)
+		if _, ok := members[id]; ok {
+			continue
 		}
+		members[id] = aws.Int64Value(desc.Target.Port)
 	}
-	return false, 0, nil
+	return members, nil
 }
 
-func deregisterTargets(elbClient elbv2iface.ELBV2API, arn string, mapping map[string]int64) error {
+func deregisterTargets(ctx context.Context, elbClient elbv2iface.ELBV2API, arn string, mapping map[string]int64) error {
 
 	targets := []*elbv2.TargetDescription{}
 	for instance, port := range mapping {
@@ -86,9 +134,38 @@ func deregisterTargets(elbClient elbv2iface.ELBV2API, arn string, mapping map[st
 		TargetGroupArn: aws.String(arn),
 	}
 
-	_, err := elbClient.DeregisterTargets(input)
+	_, err := elbClient.DeregisterTargetsWithContext(ctx, input)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// getTargetGroupsByTags returns the set of target group arns carrying all filter tags
+func getTargetGroupsByTags(ctx context.Context, elbClient elbv2iface.ELBV2API, arns []string, filters map[string]string) (map[string]bool, error) {
+	matched := make(map[string]bool)
+	for start := 0; start < len(arns); start += DescribeTagsBatchSize {
+		end := start + DescribeTagsBatchSize
+		if end > len(arns) {
+			end = len(arns)
+		}
+
+		out, err := elbClient.DescribeTagsWithContext(ctx, &elbv2.DescribeTagsInput{
+			ResourceArns: aws.StringSlice(arns[start:end]),
+		})
+		if err != nil {
+			return matched, err
+		}
+
+		for _, desc := range out.TagDescriptions {
+			tags := make(map[string]string)
+			for _, tag := range desc.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+			if matchesTagFilters(tags, filters) {
+				matched[aws.StringValue(desc.ResourceArn)] = true
+			}
+		}
+	}
+	return matched, nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,15 +12,41 @@ import (
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 type stubELBv2 struct {
 	elbv2iface.ELBV2API
 	targetHealthDescriptions        []*elbv2.TargetHealthDescription
+	targetHealthResponses           [][]*elbv2.TargetHealthDescription
 	targetGroups                    []*elbv2.TargetGroup
+	tagDescriptions                 []*elbv2.TagDescription
 	timesCalledDescribeTargetHealth int
 	timesCalledDeregisterTargets    int
 	timesCalledDescribeTargetGroups int
+	timesCalledDescribeTags         int
+	deregistrationDelay             string
+}
+
+func (e *stubELBv2) DescribeTargetGroupAttributesWithContext(ctx aws.Context, input *elbv2.DescribeTargetGroupAttributesInput, opts ...request.Option) (*elbv2.DescribeTargetGroupAttributesOutput, error) {
+	attributes := []*elbv2.TargetGroupAttribute{}
+	if e.deregistrationDelay != "" {
+		attributes = append(attributes, &elbv2.TargetGroupAttribute{
+			Key:   aws.String(TargetGroupDeregistrationDelayAttribute),
+			Value: aws.String(e.deregistrationDelay),
+		})
+	}
+	return &elbv2.DescribeTargetGroupAttributesOutput{Attributes: attributes}, nil
+}
+
+func (e *stubELBv2) DescribeTags(input *elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error) {
+	e.timesCalledDescribeTags++
+	return &elbv2.DescribeTagsOutput{TagDescriptions: e.tagDescriptions}, nil
+}
+
+func (e *stubELBv2) DescribeTagsWithContext(ctx aws.Context, input *elbv2.DescribeTagsInput, opts ...request.Option) (*elbv2.DescribeTagsOutput, error) {
+	return e.DescribeTags(input)
 }
 
 func (e *stubELBv2) WaitUntilTargetDeregisteredWithContext(ctx context.Context, input *elbv2.DescribeTargetHealthInput, req ...request.WaiterOption) error {
@@ -28,14 +55,27 @@ func (e *stubELBv2) WaitUntilTargetDeregisteredWithContext(ctx context.Context,
 
 func (e *stubELBv2) DescribeTargetHealth(input *elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error) {
 	e.timesCalledDescribeTargetHealth++
+	if len(e.targetHealthResponses) > 0 {
+		response := e.targetHealthResponses[0]
+		e.targetHealthResponses = e.targetHealthResponses[1:]
+		return &elbv2.DescribeTargetHealthOutput{TargetHealthDescriptions: response}, nil
+	}
 	return &elbv2.DescribeTargetHealthOutput{TargetHealthDescriptions: e.targetHealthDescriptions}, nil
 }
 
+func (e *stubELBv2) DescribeTargetHealthWithContext(ctx aws.Context, input *elbv2.DescribeTargetHealthInput, opts ...request.Option) (*elbv2.DescribeTargetHealthOutput, error) {
+	return e.DescribeTargetHealth(input)
+}
+
 func (e *stubELBv2) DeregisterTargets(input *elbv2.DeregisterTargetsInput) (*elbv2.DeregisterTargetsOutput, error) {
 	e.timesCalledDeregisterTargets++
 	return &elbv2.DeregisterTargetsOutput{}, nil
 }
 
+func (e *stubELBv2) DeregisterTargetsWithContext(ctx aws.Context, input *elbv2.DeregisterTargetsInput, opts ...request.Option) (*elbv2.DeregisterTargetsOutput, error) {
+	return e.DeregisterTargets(input)
+}
+
 func (e *stubELBv2) DescribeTargetGroups(input *elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error) {
 	e.timesCalledDescribeTargetGroups++
 	return &elbv2.DescribeTargetGroupsOutput{TargetGroups: e.targetGroups}, nil
@@ -52,6 +92,10 @@ func (e *stubELBv2) DescribeTargetGroupsPages(input *elbv2.DescribeTargetGroupsI
 	return nil
 }
 
+func (e *stubELBv2) DescribeTargetGroupsPagesWithContext(ctx aws.Context, input *elbv2.DescribeTargetGroupsInput, callback func(*elbv2.DescribeTargetGroupsOutput, bool) bool, opts ...request.Option) error {
+	return e.DescribeTargetGroupsPages(input, callback)
+}
+
 type stubErrorELBv2 struct {
 	elbv2iface.ELBV2API
 	targetHealthDescriptions        []*elbv2.TargetHealthDescription
@@ -73,6 +117,14 @@ func (e *stubErrorELBv2) DescribeTargetHealth(input *elbv2.DescribeTargetHealthI
 	return &elbv2.DescribeTargetHealthOutput{}, err
 }
 
+func (e *stubErrorELBv2) DescribeTargetGroupAttributesWithContext(ctx aws.Context, input *elbv2.DescribeTargetGroupAttributesInput, opts ...request.Option) (*elbv2.DescribeTargetGroupAttributesOutput, error) {
+	return nil, fmt.Errorf("some other error occured")
+}
+
+func (e *stubErrorELBv2) DescribeTargetHealthWithContext(ctx aws.Context, input *elbv2.DescribeTargetHealthInput, opts ...request.Option) (*elbv2.DescribeTargetHealthOutput, error) {
+	return e.DescribeTargetHealth(input)
+}
+
 func (e *stubErrorELBv2) DeregisterTargets(input *elbv2.DeregisterTargetsInput) (*elbv2.DeregisterTargetsOutput, error) {
 	e.timesCalledDeregisterTargets++
 	var err error
@@ -86,6 +138,10 @@ func (e *stubErrorELBv2) DeregisterTargets(input *elbv2.DeregisterTargetsInput)
 	return &elbv2.DeregisterTargetsOutput{}, err
 }
 
+func (e *stubErrorELBv2) DeregisterTargetsWithContext(ctx aws.Context, input *elbv2.DeregisterTargetsInput, opts ...request.Option) (*elbv2.DeregisterTargetsOutput, error) {
+	return e.DeregisterTargets(input)
+}
+
 func (e *stubErrorELBv2) DescribeTargetGroups(input *elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error) {
 	e.timesCalledDescribeTargetGroups++
 	return &elbv2.DescribeTargetGroupsOutput{TargetGroups: e.targetGroups}, nil
@@ -102,6 +158,10 @@ func (e *stubErrorELBv2) DescribeTargetGroupsPages(input *elbv2.DescribeTargetGr
 	return nil
 }
 
+func (e *stubErrorELBv2) DescribeTargetGroupsPagesWithContext(ctx aws.Context, input *elbv2.DescribeTargetGroupsInput, callback func(*elbv2.DescribeTargetGroupsOutput, bool) bool, opts ...request.Option) error {
+	return e.DescribeTargetGroupsPages(input, callback)
+}
+
 func Test_DeregisterTargetWaiterAbort(t *testing.T) {
 	t.Log("Test_DeregisterTargetWaiterAbort: should return when event is completed")
 	var (
@@ -128,7 +188,7 @@ func Test_DeregisterTargetWaiterAbort(t *testing.T) {
 
 	go _completeEventAfter(event, time.Millisecond*1500)
 
-	err := waitForDeregisterTarget(event, stubber, arn, instanceID, port)
+	err := waitForDeregisterTarget(event, stubber, arn, instanceID, port, WaiterConfig{})
 	if err == nil {
 		t.Fatalf("Test_DeregisterTargetWaiterAbort: expected error not have occured, %v", err)
 	}
@@ -138,6 +198,52 @@ func Test_DeregisterTargetWaiterAbort(t *testing.T) {
 	}
 }
 
+func Test_DeregisterTargetWaiterCancel(t *testing.T) {
+	t.Log("Test_DeregisterTargetWaiterCancel: should return without waiting for the next attempt when the event is cancelled")
+	var (
+		event               = &LifecycleEvent{}
+		arn                 = "arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/targetgroup-name/some-id"
+		instanceID          = "i-1234567890"
+		port          int64 = 32334
+		expectedCalls       = 1
+		config              = WaiterConfig{MinDelay: time.Minute, MaxDelay: time.Minute}
+	)
+	event.SetContext(context.WithCancel(context.Background()))
+
+	stubber := &stubELBv2{
+		targetHealthDescriptions: []*elbv2.TargetHealthDescription{
+			{
+				Target: &elbv2.TargetDescription{
+					Id:   aws.String(instanceID),
+					Port: aws.Int64(port),
+				},
+				TargetHealth: &elbv2.TargetHealth{
+					State: aws.String(elbv2.TargetHealthStateEnumDraining),
+				},
+			},
+		},
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		event.cancel()
+	}()
+
+	start := time.Now()
+	err := waitForDeregisterTarget(event, stubber, arn, instanceID, port, config)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("expected cancellation error, got: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("expected waiter to return once cancelled, took: %v", elapsed)
+	}
+
+	if stubber.timesCalledDescribeTargetHealth != expectedCalls {
+		t.Fatalf("expected timesCalledDescribeTargetHealth: %v, got: %v", expectedCalls, stubber.timesCalledDescribeTargetHealth)
+	}
+}
+
 func Test_DeregisterTargetWaiterNotFound(t *testing.T) {
 	t.Log("Test_DeregisterTargetWaiterNotFound: should return when instance is not found")
 	var (
@@ -162,7 +268,7 @@ func Test_DeregisterTargetWaiterNotFound(t *testing.T) {
 		},
 	}
 
-	err := waitForDeregisterTarget(event, stubber, arn, instanceID, port)
+	err := waitForDeregisterTarget(event, stubber, arn, instanceID, port, WaiterConfig{})
 	if err != nil {
 		t.Fatalf("Test_DeregisterTargetWaiterNotFound: expected error not to have occured, %v", err)
 	}
@@ -196,7 +302,7 @@ func Test_DeregisterTargetWaiterTimeout(t *testing.T) {
 		},
 	}
 
-	err := waitForDeregisterTarget(event, stubber, arn, instanceID, port)
+	err := waitForDeregisterTarget(event, stubber, arn, instanceID, port, WaiterConfig{})
 	if err == nil {
 		t.Fatalf("Test_DeregisterTargetWaiterNotFound: expected error to have occured, %v", err)
 	}
@@ -206,6 +312,45 @@ func Test_DeregisterTargetWaiterTimeout(t *testing.T) {
 	}
 }
 
+func Test_DeregisterTargetWaiterConfig(t *testing.T) {
+	t.Log("Test_DeregisterTargetWaiterConfig: should respect configured waiter attempts")
+	var (
+		event               = &LifecycleEvent{}
+		arn                 = "arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/targetgroup-name/some-id"
+		instanceID          = "i-1234567890"
+		port          int64 = 32334
+		expectedCalls       = 2
+		config              = WaiterConfig{
+			MinDelay:    time.Millisecond * 10,
+			MaxDelay:    time.Millisecond * 20,
+			MaxAttempts: 1,
+		}
+	)
+
+	stubber := &stubELBv2{
+		targetHealthDescriptions: []*elbv2.TargetHealthDescription{
+			{
+				Target: &elbv2.TargetDescription{
+					Id:   aws.String(instanceID),
+					Port: aws.Int64(port),
+				},
+				TargetHealth: &elbv2.TargetHealth{
+					State: aws.String(elbv2.TargetHealthStateEnumHealthy),
+				},
+			},
+		},
+	}
+
+	err := waitForDeregisterTarget(event, stubber, arn, instanceID, port, config)
+	if err == nil {
+		t.Fatalf("Test_DeregisterTargetWaiterConfig: expected error to have occured, %v", err)
+	}
+
+	if stubber.timesCalledDescribeTargetHealth != expectedCalls {
+		t.Fatalf("Test_DeregisterTargetWaiterConfig: expected timesCalledDescribeTargetHealth: %v, got: %v", expectedCalls, stubber.timesCalledDescribeTargetHealth)
+	}
+}
+
 func Test_DeregisterTargetWaiterFail(t *testing.T) {
 	t.Log("Test_DeregisterTargetWaiterFail: should return an error when call fails")
 	var (
@@ -220,7 +365,7 @@ func Test_DeregisterTargetWaiterFail(t *testing.T) {
 		failHint: elbv2.ErrCodeTargetGroupNotFoundException,
 	}
 
-	err := waitForDeregisterTarget(event, stubber, arn, instanceID, port)
+	err := waitForDeregisterTarget(event, stubber, arn, instanceID, port, WaiterConfig{})
 	if err == nil {
 		t.Fatalf("Test_DeregisterTargetWaiterFail: expected error not to have occured, %v", err)
 	}
@@ -239,7 +384,7 @@ func Test_DeregisterTarget(t *testing.T) {
 		expectedCalls = 1
 	)
 
-	err := deregisterTargets(stubber, arn, instances)
+	err := deregisterTargets(context.Background(), stubber, arn, instances)
 	if err != nil {
 		t.Fatalf("Test_DeregisterInstance: expected error not to have occured, %v", err)
 	}
@@ -259,7 +404,7 @@ func Test_DeregisterTargetNotFoundException(t *testing.T) {
 	)
 
 	stubber.failHint = elbv2.ErrCodeTargetGroupNotFoundException
-	err := deregisterTargets(stubber, arn, instances)
+	err := deregisterTargets(context.Background(), stubber, arn, instances)
 	if err == nil {
 		t.Fatalf("Test_DeregisterTargetNotFoundException: expected error not have occured, %v", err)
 	}
@@ -279,7 +424,7 @@ func Test_DeregisterTargetInvalidException(t *testing.T) {
 	)
 
 	stubber.failHint = elbv2.ErrCodeInvalidTargetException
-	err := deregisterTargets(stubber, arn, instances)
+	err := deregisterTargets(context.Background(), stubber, arn, instances)
 	if err == nil {
 		t.Fatalf("Test_DeregisterTargetInvalidException: expected error not have occured, %v", err)
 	}
@@ -307,7 +452,7 @@ func Test_FindInstanceInTargetGroupPositive(t *testing.T) {
 			},
 		},
 	}
-	found, foundPort, err := findInstanceInTargetGroup(stubber, arn, instanceID)
+	found, foundPort, err := findInstanceInTargetGroup(context.Background(), stubber, arn, instanceID)
 	if err != nil {
 		t.Fatalf("Test_FindInstanceInTargetGroupPositive: expected error not to have occured, %v", err)
 	}
@@ -340,7 +485,7 @@ func Test_FindInstanceInTargetGroupNegative(t *testing.T) {
 			},
 		},
 	}
-	found, _, err := findInstanceInTargetGroup(stubber, arn, instanceID)
+	found, _, err := findInstanceInTargetGroup(context.Background(), stubber, arn, instanceID)
 	if err != nil {
 		t.Fatalf("Test_FindInstanceInTargetGroupPositive: expected error not to have occured, %v", err)
 	}
@@ -363,7 +508,7 @@ func Test_FindInstanceInTargetGroupError(t *testing.T) {
 
 	stubber.failHint = elbv2.ErrCodeTargetGroupNotFoundException
 
-	found, _, err := findInstanceInTargetGroup(stubber, arn, instanceID)
+	found, _, err := findInstanceInTargetGroup(context.Background(), stubber, arn, instanceID)
 	if err == nil {
 		t.Fatalf("Test_FindInstanceInTargetGroupError: expected error to have occured, got: %v", err)
 	}
@@ -374,3 +519,95 @@ func Test_FindInstanceInTargetGroupError(t *testing.T) {
 		t.Fatalf("Test_FindInstanceInTargetGroupError: expected instance not to be found")
 	}
 }
+
+func Test_GetTargetGroupsByTags(t *testing.T) {
+	t.Log("Test_GetTargetGroupsByTags: should only match target groups carrying all filter tags in batches")
+	var (
+		arns     = []string{}
+		tagDescs = []*elbv2.TagDescription{}
+		filters  = map[string]string{"kubernetes.io/cluster/my-cluster": "owned", "team": ""}
+	)
+
+	for i := 0; i < 25; i++ {
+		arn := fmt.Sprintf("arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/tg-%v/some-id", i)
+		arns = append(arns, arn)
+		tags := []*elbv2.Tag{
+			{Key: aws.String("kubernetes.io/cluster/my-cluster"), Value: aws.String("owned")},
+		}
+		if i%2 == 0 {
+			tags = append(tags, &elbv2.Tag{Key: aws.String("team"), Value: aws.String("platform")})
+		}
+		tagDescs = append(tagDescs, &elbv2.TagDescription{ResourceArn: aws.String(arn), Tags: tags})
+	}
+
+	stubber := &stubELBv2{
+		tagDescriptions: tagDescs,
+	}
+
+	matched, err := getTargetGroupsByTags(context.Background(), stubber, arns, filters)
+	if err != nil {
+		t.Fatalf("getTargetGroupsByTags: expected error not to have occured, %v", err)
+	}
+
+	expectedCalls := 2
+	if stubber.timesCalledDescribeTags != expectedCalls {
+		t.Fatalf("expected timesCalledDescribeTags: %v, got: %v", expectedCalls, stubber.timesCalledDescribeTags)
+	}
+
+	expectedMatches := 13
+	if len(matched) != expectedMatches {
+		t.Fatalf("expected matched target groups: %v, got: %v", expectedMatches, len(matched))
+	}
+}
+
+func Test_DeregisterTargetHookDeadline(t *testing.T) {
+	t.Log("Test_DeregisterTargetHookDeadline: should stop waiting when the lifecycle hook is about to expire")
+	var (
+		stubber = &stubELBv2{}
+		event   = &LifecycleEvent{
+			heartbeatInterval: 1,
+			startTime:         time.Now().Add(-2 * time.Minute),
+		}
+	)
+
+	err := waitForDeregisterTarget(event, stubber, "arn", "i-123456789012", 32334, WaiterConfig{})
+	if err == nil {
+		t.Fatal("waitForDeregisterTarget: expected error to have occured")
+	}
+
+	if stubber.timesCalledDescribeTargetHealth != 0 {
+		t.Fatalf("expected timesCalledDescribeTargetHealth: %v, got: %v", 0, stubber.timesCalledDescribeTargetHealth)
+	}
+}
+
+func Test_GetTargetGroupDeregistrationDelay(t *testing.T) {
+	t.Log("Test_GetTargetGroupDeregistrationDelay: should return the deregistration delay attribute of a target group")
+	ctx := context.Background()
+
+	stubber := &stubELBv2{deregistrationDelay: "300"}
+	delay, err := getTargetGroupDeregistrationDelay(ctx, stubber, "some-target-group")
+	if err != nil || delay != 300*time.Second {
+		t.Fatalf("expected delay: %v, got: %v (err: %v)", 300*time.Second, delay, err)
+	}
+
+	stubber.deregistrationDelay = ""
+	if _, err := getTargetGroupDeregistrationDelay(ctx, stubber, "some-target-group"); err == nil {
+		t.Fatalf("expected error for a missing attribute, got: %v", err)
+	}
+}
+
+func Test_CheckDeregistrationDelay(t *testing.T) {
+	t.Log("Test_CheckDeregistrationDelay: should publish a warning when the deregistration delay exceeds the heartbeat timeout")
+	kubeClient := fake.NewSimpleClientset()
+	auth := Authenticator{KubernetesClient: kubeClient}
+	mgr := New(auth, _newBasicContext())
+
+	event := &LifecycleEvent{EC2InstanceID: "i-123456789012", heartbeatInterval: 300}
+	mgr.checkDeregistrationDelay(event, "some-target-group", 300*time.Second)
+	mgr.checkDeregistrationDelay(event, "some-target-group", 600*time.Second)
+
+	events, _ := kubeClient.CoreV1().Events(EventNamespace).List(context.Background(), metav1.ListOptions{})
+	if len(events.Items) != 1 || events.Items[0].Reason != string(EventReasonTargetDeregisterDelayExceeded) {
+		t.Fatalf("expected one %v event, got: %v", EventReasonTargetDeregisterDelayExceeded, events.Items)
+	}
+}
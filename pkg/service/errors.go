@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrorClass classifies the failure of an event to decide whether it is worth retrying
+type ErrorClass string
+
+func (c ErrorClass) String() string {
+	return string(c)
+}
+
+const (
+	// ErrorClassTransient is the class of failures which may succeed when retried, such as throttling or timeouts
+	ErrorClassTransient ErrorClass = "transient"
+	// ErrorClassTerminal is the class of failures which will not succeed when retried, such as a lifecycle action
+	// or node which no longer exists, failures which are not known to be transient are terminal
+	ErrorClassTerminal ErrorClass = "terminal"
+)
+
+// EventError is an error of a known class
+type EventError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func (e *EventError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the classified error
+func (e *EventError) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the classified error
+func (e *EventError) Cause() error {
+	return e.Err
+}
+
+// NewTransientError classifies err as a transient failure
+func NewTransientError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &EventError{Class: ErrorClassTransient, Err: err}
+}
+
+// NewTerminalError classifies err as a terminal failure
+func NewTerminalError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &EventError{Class: ErrorClassTerminal, Err: err}
+}
+
+// ClassifyError returns the class of err, the outermost EventError in the chain of wrapped errors decides the class,
+// otherwise AWS and Kubernetes throttling, server and timeout errors are transient and other errors are terminal
+func ClassifyError(err error) ErrorClass {
+	var eventErr *EventError
+	if errors.As(err, &eventErr) {
+		return eventErr.Class
+	}
+
+	if isLifecycleActionGone(err) {
+		return ErrorClassTerminal
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if e == ErrNodeNotFound {
+			return ErrorClassTerminal
+		}
+		if awsErr, ok := e.(awserr.Error); ok {
+			if request.IsErrorThrottle(awsErr) || request.IsErrorRetryable(awsErr) {
+				return ErrorClassTransient
+			}
+			if reqErr, ok := awsErr.(awserr.RequestFailure); ok && reqErr.StatusCode() >= 500 {
+				return ErrorClassTransient
+			}
+		}
+		if e == context.DeadlineExceeded {
+			return ErrorClassTransient
+		}
+		if netErr, ok := e.(net.Error); ok && netErr.Timeout() {
+			return ErrorClassTransient
+		}
+		if apierrors.IsTooManyRequests(e) || apierrors.IsServerTimeout(e) || apierrors.IsTimeout(e) || apierrors.IsServiceUnavailable(e) || apierrors.IsInternalError(e) {
+			return ErrorClassTransient
+		}
+	}
+	return ErrorClassTerminal
+}
+
+// isLifecycleActionGone returns true if err is caused by a lifecycle action which was completed or timed out, as
+// reported by the validation error of autoscaling
+func isLifecycleActionGone(err error) bool {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if awsErr, ok := e.(awserr.Error); ok && awsErr.Code() == "ValidationError" && strings.Contains(awsErr.Message(), "No active Lifecycle Action found") {
+			return true
+		}
+	}
+	return false
+}
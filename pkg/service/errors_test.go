@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_ClassifyError(t *testing.T) {
+	t.Log("Test_ClassifyError: should classify throttling and timeouts as transient and other failures as terminal")
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorClass
+	}{
+		{"throttling", errors.Wrap(awserr.New("Throttling", "Rate exceeded", nil), "deregister failed"), ErrorClassTransient},
+		{"server error", awserr.NewRequestFailure(awserr.New("InternalFailure", "", nil), 500, "id"), ErrorClassTransient},
+		{"timeout", errors.Wrap(context.DeadlineExceeded, "failed to drain node"), ErrorClassTransient},
+		{"too many requests", apierrors.NewTooManyRequests("slow down", 1), ErrorClassTransient},
+		{"hook gone", awserr.New("ValidationError", "No active Lifecycle Action found with instance ID i-123", nil), ErrorClassTerminal},
+		{"node gone", errors.Wrap(ErrNodeNotFound, "instance i-123 is not seen in cluster nodes"), ErrorClassTerminal},
+		{"not found", apierrors.NewNotFound(schema.GroupResource{Resource: "nodes"}, "node-1"), ErrorClassTerminal},
+		{"unknown", errors.New("some failure"), ErrorClassTerminal},
+		{"typed transient", NewTransientError(errors.New("some failure")), ErrorClassTransient},
+		{"typed terminal", NewTerminalError(errors.Wrap(context.DeadlineExceeded, "event exceeded max time")), ErrorClassTerminal},
+	}
+
+	for _, tc := range tests {
+		if got := ClassifyError(tc.err); got != tc.expected {
+			t.Fatalf("%v: expected class: %v, got: %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func Test_RedriveEvent(t *testing.T) {
+	t.Log("Test_RedriveEvent: should return events failing with a transient error to the queue until retries are exhausted")
+	var (
+		sqsStubber = &stubSQS{}
+		asgStubber = &stubAutoscaling{}
+	)
+
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		SQSClient:          sqsStubber,
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	ctx := _newBasicContext()
+	ctx.TransientRetryAttempts = 2
+	mgr := New(auth, ctx)
+
+	newEvent := func(received string) *LifecycleEvent {
+		event := &LifecycleEvent{
+			RequestID:     "63f5b5c2-58b3-0574-b7d5-b3162d0268f0",
+			EC2InstanceID: "i-123486890234",
+			receiptHandle: "MbZj6wDWli+JvwwJaBV+3dcjk2YW2vA3+STFFljTM8tJJg6HRG6PYSasuWXPJB+Cw=",
+			message: &sqs.Message{
+				Attributes: map[string]*string{
+					sqs.MessageSystemAttributeNameApproximateReceiveCount: aws.String(received),
+				},
+			},
+		}
+		event.ctx, event.cancel = context.WithCancel(context.Background())
+		return event
+	}
+	throttled := errors.Wrap(awserr.New("Throttling", "Rate exceeded", nil), "deregister failed")
+
+	if !mgr.RedriveEvent(throttled, newEvent("2")) {
+		t.Fatal("expected event failing with a transient error to be redriven")
+	}
+	if sqsStubber.timesCalledChangeMessageVisibility != 1 {
+		t.Fatalf("expected timesCalledChangeMessageVisibility: %v, got: %v", 1, sqsStubber.timesCalledChangeMessageVisibility)
+	}
+
+	if mgr.RedriveEvent(throttled, newEvent("3")) {
+		t.Fatal("expected event which exhausted its retries not to be redriven")
+	}
+
+	if mgr.RedriveEvent(errors.New("some failure"), newEvent("1")) {
+		t.Fatal("expected event failing with a terminal error not to be redriven")
+	}
+
+	hookGone := awserr.New("ValidationError", "No active Lifecycle Action found with instance ID i-123486890234", nil)
+	mgr.FailEvent(hookGone, newEvent("1"), true)
+	if asgStubber.timesCalledCompleteLifecycleAction != 0 {
+		t.Fatalf("expected timesCalledCompleteLifecycleAction: %v, got: %v", 0, asgStubber.timesCalledCompleteLifecycleAction)
+	}
+}
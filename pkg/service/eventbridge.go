@@ -0,0 +1,227 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// EventBridgeTerminateActionType is the detail type of termination lifecycle actions routed by EventBridge
+	EventBridgeTerminateActionType = "EC2 Instance-terminate Lifecycle Action"
+	// EventBridgeLaunchActionType is the detail type of launch lifecycle actions routed by EventBridge
+	EventBridgeLaunchActionType = "EC2 Instance-launch Lifecycle Action"
+	// EventBridgeSpotInterruptionType is the detail type of spot instance interruption warnings
+	EventBridgeSpotInterruptionType = "EC2 Spot Instance Interruption Warning"
+	// EventBridgeRebalanceRecommendationType is the detail type of spot instance rebalance recommendations
+	EventBridgeRebalanceRecommendationType = "EC2 Instance Rebalance Recommendation"
+	// EventBridgeStateChangeType is the detail type of instance state changes
+	EventBridgeStateChangeType = "EC2 Instance State-change Notification"
+	// EventBridgeHealthType is the detail type of AWS Health events, which carry EC2 scheduled changes
+	EventBridgeHealthType = "AWS Health Event"
+
+	// InterruptionKindSpot is the kind of spot interruption notices
+	InterruptionKindSpot = "spot-interruption"
+	// InterruptionKindRebalance is the kind of rebalance recommendation notices
+	InterruptionKindRebalance = "rebalance-recommendation"
+	// InterruptionKindScheduledChange is the kind of scheduled change notices
+	InterruptionKindScheduledChange = "scheduled-change"
+	// InterruptionKindStateChange is the kind of instance state change notices
+	InterruptionKindStateChange = "state-change"
+
+	// InterruptionRequestPrefix is the request id prefix of events draining the node of an interrupted instance
+	InterruptionRequestPrefix = "interruption"
+)
+
+// InterruptionLabels are the labels of interruption notice metrics
+var InterruptionLabels = []string{"kind"}
+
+// EventBridgeEvent is an event routed to the queue by an EventBridge rule, as consumed by the queue processor mode of
+// aws-node-termination-handler
+type EventBridgeEvent struct {
+	Version    string          `json:"version"`
+	ID         string          `json:"id"`
+	DetailType string          `json:"detail-type"`
+	Source     string          `json:"source"`
+	Account    string          `json:"account"`
+	Time       string          `json:"time"`
+	Region     string          `json:"region"`
+	Resources  []string        `json:"resources"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+// InterruptionNotice is an EC2 event announcing that instances are about to be interrupted, it carries no lifecycle
+// hook to complete
+type InterruptionNotice struct {
+	ID          string
+	Kind        string
+	Account     string
+	InstanceIDs []string
+	// Drain is false for notices which do not interrupt the instance, such as rebalance recommendations
+	Drain bool
+	// EventTypeCode and StartTime are the type and window of scheduled changes, e.g. AWS_EC2_INSTANCE_STOP_SCHEDULED
+	EventTypeCode string
+	StartTime     time.Time
+}
+
+// unwrapEventBridgeEvent returns the event routed by EventBridge, nil is returned if body is not an EventBridge event
+func unwrapEventBridgeEvent(body string) (*EventBridgeEvent, error) {
+	event := &EventBridgeEvent{}
+	if err := json.Unmarshal([]byte(body), event); err != nil {
+		return nil, err
+	}
+	if event.DetailType == "" || event.Source == "" || len(event.Detail) == 0 {
+		return nil, nil
+	}
+	return event, nil
+}
+
+// isLifecycleAction returns true if the event carries a lifecycle action notification in its detail
+func (e *EventBridgeEvent) isLifecycleAction() bool {
+	return e.DetailType == EventBridgeTerminateActionType || e.DetailType == EventBridgeLaunchActionType
+}
+
+// interruptionNotice returns the interruption notice of an EC2 event, nil is returned for other events
+func (e *EventBridgeEvent) interruptionNotice() (*InterruptionNotice, error) {
+	var detail struct {
+		InstanceID        string `json:"instance-id"`
+		State             string `json:"state"`
+		Service           string `json:"service"`
+		EventTypeCategory string `json:"eventTypeCategory"`
+		EventTypeCode     string `json:"eventTypeCode"`
+		StartTime         string `json:"startTime"`
+		AffectedEntities  []struct {
+			EntityValue string `json:"entityValue"`
+		} `json:"affectedEntities"`
+	}
+	if err := json.Unmarshal(e.Detail, &detail); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse detail of %v event %v", e.DetailType, e.ID)
+	}
+
+	notice := &InterruptionNotice{ID: e.ID, Account: e.Account, Drain: true}
+	switch e.DetailType {
+	case EventBridgeSpotInterruptionType:
+		notice.Kind = InterruptionKindSpot
+	case EventBridgeRebalanceRecommendationType:
+		notice.Kind = InterruptionKindRebalance
+		notice.Drain = false
+	case EventBridgeStateChangeType:
+		notice.Kind = InterruptionKindStateChange
+		notice.Drain = detail.State == "stopping" || detail.State == "shutting-down"
+	case EventBridgeHealthType:
+		if detail.Service != "EC2" || detail.EventTypeCategory != "scheduledChange" {
+			return nil, nil
+		}
+		notice.Kind = InterruptionKindScheduledChange
+		notice.EventTypeCode = detail.EventTypeCode
+		notice.StartTime = parseMaintenanceTime(detail.StartTime)
+		for _, entity := range detail.AffectedEntities {
+			notice.InstanceIDs = append(notice.InstanceIDs, entity.EntityValue)
+		}
+		return notice, nil
+	default:
+		return nil, nil
+	}
+	notice.InstanceIDs = []string{detail.InstanceID}
+	return notice, nil
+}
+
+// readInterruptionNotice returns the interruption notice carried by a message, nil is returned for messages which
+// are not interruption notices, such as lifecycle notifications, they are read and rejected as events otherwise
+func readInterruptionNotice(message *sqs.Message) *InterruptionNotice {
+	body := aws.StringValue(message.Body)
+	if envelope, _ := unwrapSNSEnvelope(body); envelope != nil {
+		body = envelope.Message
+	}
+
+	event, _ := unwrapEventBridgeEvent(body)
+	if event == nil || event.isLifecycleAction() {
+		return nil
+	}
+	notice, err := event.interruptionNotice()
+	if err != nil {
+		log.Warnf("failed to read interruption notice: %v", err)
+		return nil
+	}
+	return notice
+}
+
+// handleInterruptionNotice drains the nodes of the instances of an interruption notice without a lifecycle hook, the
+// message is deleted once the drains are started as the instances are interrupted regardless. Notices of maintenance
+// scheduled later than the drain lead are returned to the queue until then
+func (mgr *Manager) handleInterruptionNotice(notice *InterruptionNotice, message *sqs.Message, queueURL string) {
+	var (
+		metrics = mgr.metrics
+		queue   = mgr.authenticator.SQSClient
+	)
+
+	// the notice is checked against the allowed accounts and senders like lifecycle notifications
+	source := &LifecycleEvent{RequestID: notice.ID, AccountID: notice.Account}
+	source.SetMessage(message)
+	source.SetReceiptHandle(aws.StringValue(message.ReceiptHandle))
+	source.SetQueueURL(queueURL)
+	if err := mgr.validateMessageSource(source); err != nil {
+		mgr.RejectEvent(err, source)
+		return
+	}
+	if mgr.deferMaintenanceNotice(notice, message, queueURL) {
+		return
+	}
+
+	log.Infof("received %v notice %v for instances %v, drain = %v", notice.Kind, notice.ID, notice.InstanceIDs, notice.Drain)
+	metrics.AddCounter(InterruptionNoticesTotalMetric, prometheus.Labels{"kind": notice.Kind}, 1)
+	if notice.Drain {
+		for _, instanceID := range notice.InstanceIDs {
+			go mgr.drainInterruptedInstance(notice, instanceID)
+		}
+	}
+
+	if err := deleteMessage(queue, queueURL, source.receiptHandle); err != nil {
+		log.Errorf("failed to delete message: %v", err)
+	}
+}
+
+// drainInterruptedInstance drains and deregisters the node of an interrupted instance, and terminates instances of
+// scaling groups stopped or retired by scheduled maintenance. Instances which are not nodes of the cluster, skipped
+// nodes and instances not admitted by --instance-tag-filter are left alone
+func (mgr *Manager) drainInterruptedInstance(notice *InterruptionNotice, instanceID string) {
+	opts := DrainOptions{InstanceID: instanceID}
+	event, err := mgr.newManualEvent(opts)
+	if err != nil {
+		if errors.Cause(err) == ErrNodeNotFound {
+			log.Debugf("%v> ignoring %v notice: %v", instanceID, notice.Kind, err)
+			return
+		}
+		log.Errorf("%v> failed to handle %v notice: %v", instanceID, notice.Kind, err)
+		return
+	}
+	defer event.cancel()
+	event.RequestID = fmt.Sprintf("%v-%v-%v", InterruptionRequestPrefix, instanceID, notice.ID)
+	opts.Terminate = mgr.terminatesInstance(notice, event)
+
+	if err := mgr.loadInstanceTags(event); err != nil {
+		log.Errorf("%v> failed to handle %v notice: %v", instanceID, notice.Kind, err)
+		return
+	}
+	if mgr.isNodeSkipped(event) || !mgr.isInstanceAdmitted(event) {
+		log.Infof("%v> node/%v is skipped, not draining it on %v notice", instanceID, event.referencedNode.Name, notice.Kind)
+		return
+	}
+
+	if err := mgr.runManualDrain(event, opts); err != nil {
+		log.Errorf("%v> failed to drain node/%v on %v notice: %v", instanceID, event.referencedNode.Name, notice.Kind, err)
+		msg := fmt.Sprintf(EventMessageInterruptionDrainFailed, event.referencedNode.Name, notice.Kind, err)
+		mgr.publishEvent(event, EventReasonInterruptionDrainFailed, getMessageFields(event, msg))
+		return
+	}
+
+	msg := fmt.Sprintf(EventMessageInterruptionDrainSucceeded, event.referencedNode.Name, notice.Kind, opts.Terminate)
+	mgr.publishEvent(event, EventReasonInterruptionDrainSucceeded, getMessageFields(event, msg))
+	log.Infof("%v> node/%v drained and deregistered on %v notice", instanceID, event.referencedNode.Name, notice.Kind)
+}
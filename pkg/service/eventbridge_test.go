@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const _fakeEventBridgeLifecycleMessage = `{"version":"0","id":"5f6e3c1a-8a5b-4c2e-9f1d-0e2b7c9d1a3f","detail-type":"EC2 Instance-terminate Lifecycle Action","source":"aws.autoscaling","account":"12345689012","time":"2019-09-27T02:39:14Z","region":"us-west-2","resources":["arn:aws:autoscaling:us-west-2:12345689012:autoScalingGroup:1f2e3d4c:autoScalingGroupName/my-asg"],"detail":{"LifecycleActionToken":"cc34960c-1e41-4703-a665-bdb3e5b81ad3","AutoScalingGroupName":"my-asg","LifecycleHookName":"my-hook","EC2InstanceId":"i-123486890234","LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING"}}`
+
+func Test_ReadMessageEventBridge(t *testing.T) {
+	t.Log("Test_ReadMessageEventBridge: should read lifecycle actions routed by EventBridge")
+	event, err := readMessage(&sqs.Message{Body: aws.String(_fakeEventBridgeLifecycleMessage)}, "some-queue")
+	if err != nil {
+		t.Fatalf("readMessage: expected error not to have occured, %v", err)
+	}
+
+	expected := &LifecycleEvent{
+		LifecycleHookName:    "my-hook",
+		AccountID:            "12345689012",
+		RequestID:            "5f6e3c1a-8a5b-4c2e-9f1d-0e2b7c9d1a3f",
+		LifecycleTransition:  TerminationEventName,
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-123486890234",
+		LifecycleActionToken: "cc34960c-1e41-4703-a665-bdb3e5b81ad3",
+	}
+	got := &LifecycleEvent{
+		LifecycleHookName:    event.LifecycleHookName,
+		AccountID:            event.AccountID,
+		RequestID:            event.RequestID,
+		LifecycleTransition:  event.LifecycleTransition,
+		AutoScalingGroupName: event.AutoScalingGroupName,
+		EC2InstanceID:        event.EC2InstanceID,
+		LifecycleActionToken: event.LifecycleActionToken,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected event: %+v, got: %+v", expected, got)
+	}
+
+	if notice := readInterruptionNotice(&sqs.Message{Body: aws.String(_fakeEventBridgeLifecycleMessage)}); notice != nil {
+		t.Fatalf("expected lifecycle action not to be an interruption notice, got: %+v", notice)
+	}
+}
+
+func Test_ReadInterruptionNotice(t *testing.T) {
+	t.Log("Test_ReadInterruptionNotice: should read the interruption notices of aws-node-termination-handler's queue")
+	tests := []struct {
+		body     string
+		expected *InterruptionNotice
+	}{
+		{
+			body:     `{"id":"1","detail-type":"EC2 Spot Instance Interruption Warning","source":"aws.ec2","account":"12345689012","detail":{"instance-id":"i-111111111111","instance-action":"terminate"}}`,
+			expected: &InterruptionNotice{ID: "1", Kind: InterruptionKindSpot, Account: "12345689012", InstanceIDs: []string{"i-111111111111"}, Drain: true},
+		},
+		{
+			body:     `{"id":"2","detail-type":"EC2 Instance Rebalance Recommendation","source":"aws.ec2","account":"12345689012","detail":{"instance-id":"i-111111111111"}}`,
+			expected: &InterruptionNotice{ID: "2", Kind: InterruptionKindRebalance, Account: "12345689012", InstanceIDs: []string{"i-111111111111"}, Drain: false},
+		},
+		{
+			body:     `{"id":"3","detail-type":"EC2 Instance State-change Notification","source":"aws.ec2","account":"12345689012","detail":{"instance-id":"i-111111111111","state":"stopping"}}`,
+			expected: &InterruptionNotice{ID: "3", Kind: InterruptionKindStateChange, Account: "12345689012", InstanceIDs: []string{"i-111111111111"}, Drain: true},
+		},
+		{
+			body:     `{"id":"4","detail-type":"EC2 Instance State-change Notification","source":"aws.ec2","account":"12345689012","detail":{"instance-id":"i-111111111111","state":"running"}}`,
+			expected: &InterruptionNotice{ID: "4", Kind: InterruptionKindStateChange, Account: "12345689012", InstanceIDs: []string{"i-111111111111"}, Drain: false},
+		},
+		{
+			body:     `{"id":"5","detail-type":"AWS Health Event","source":"aws.health","account":"12345689012","detail":{"service":"EC2","eventTypeCategory":"scheduledChange","eventTypeCode":"AWS_EC2_INSTANCE_RETIREMENT_SCHEDULED","affectedEntities":[{"entityValue":"i-111111111111"},{"entityValue":"i-222222222222"}]}}`,
+			expected: &InterruptionNotice{ID: "5", Kind: InterruptionKindScheduledChange, Account: "12345689012", InstanceIDs: []string{"i-111111111111", "i-222222222222"}, Drain: true, EventTypeCode: "AWS_EC2_INSTANCE_RETIREMENT_SCHEDULED"},
+		},
+		{
+			body:     `{"id":"6","detail-type":"AWS Health Event","source":"aws.health","account":"12345689012","detail":{"service":"RDS","eventTypeCategory":"scheduledChange"}}`,
+			expected: nil,
+		},
+		{
+			body:     _fakeLifecycleMessage,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		notice := readInterruptionNotice(&sqs.Message{Body: aws.String(tc.body)})
+		if !reflect.DeepEqual(notice, tc.expected) {
+			t.Fatalf("expected notice: %+v, got: %+v", tc.expected, notice)
+		}
+	}
+}
+
+func Test_HandleInterruptionNotice(t *testing.T) {
+	t.Log("Test_HandleInterruptionNotice: should drain the node of an interrupted instance and delete the notice")
+	kubeClient := fake.NewSimpleClientset()
+	sqsStubber := &stubSQS{}
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		KubernetesClient:   kubeClient,
+		SQSClient:          sqsStubber,
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-123486890234"},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+	mgr := New(auth, _newBasicContext())
+
+	message := &sqs.Message{
+		Body:          aws.String(`{"id":"1","detail-type":"EC2 Spot Instance Interruption Warning","source":"aws.ec2","account":"12345689012","detail":{"instance-id":"i-123486890234","instance-action":"terminate"}}`),
+		ReceiptHandle: aws.String("receipt-1"),
+	}
+	mgr.intakeMessage(message, "some-queue")
+
+	if sqsStubber.timesCalledDeleteMessage != 1 {
+		t.Fatalf("expected timesCalledDeleteMessage: %v, got: %v", 1, sqsStubber.timesCalledDeleteMessage)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		drained, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+		if drained.Spec.Unschedulable {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected node to be cordoned")
+}
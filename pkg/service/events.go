@@ -38,14 +38,58 @@ const (
 	EventReasonLifecycleHookFailed EventReason = "LifecycleHookFailed"
 	// EventMessageLifecycleHookFailed is the message for a lifecycle failed event
 	EventMessageLifecycleHookFailed = "lifecycle hook for event %v has failed processing after %vs: %v"
+	// EventReasonLifecycleHookDeadlineExceeded is the reason for a lifecycle event exceeding the max time to process
+	EventReasonLifecycleHookDeadlineExceeded EventReason = "LifecycleHookDeadlineExceeded"
+	// EventMessageLifecycleHookDeadlineExceeded is the message for a lifecycle event exceeding the max time to process
+	EventMessageLifecycleHookDeadlineExceeded = "lifecycle hook for event %v has exceeded the max time to process of %vs, instance %v will be abandoned"
 	// EventReasonNodeDrainSucceeded is the reason for a successful drain event
 	EventReasonNodeDrainSucceeded EventReason = "NodeDrainSucceeded"
 	// EventMessageNodeDrainSucceeded is the message for a successful drain event
 	EventMessageNodeDrainSucceeded = "node %v has been drained successfully as a response to a termination event"
+	// EventReasonNodeDrainSkipped is the reason for a node which was already drained by upgrade-manager
+	EventReasonNodeDrainSkipped EventReason = "NodeDrainSkipped"
+	// EventMessageNodeDrainSkipped is the message for a node which was already drained by upgrade-manager
+	EventMessageNodeDrainSkipped = "node %v was already drained by upgrade-manager and is not drained again"
+	// EventReasonNodeDrainEscalated is the reason for a drain deleting the pods it could not evict
+	EventReasonNodeDrainEscalated EventReason = "NodeDrainEscalated"
+	// EventMessageNodeDrainEscalated is the message for a drain deleting the pods it could not evict
+	EventMessageNodeDrainEscalated = "pods of node %v were not evicted within %vs and are deleted with a grace period of %vs"
 	// EventReasonNodeDrainFailed is the reason for a failed drain event
 	EventReasonNodeDrainFailed EventReason = "NodeDrainFailed"
 	// EventMessageNodeDrainFailed is the message for a failed drain event
 	EventMessageNodeDrainFailed = "node %v draining has failed: %v"
+	// EventReasonNodeDrainFailureIgnored is the reason for a failed drain which does not stop the termination
+	EventReasonNodeDrainFailureIgnored EventReason = "NodeDrainFailureIgnored"
+	// EventMessageNodeDrainFailureIgnored is the message for a failed drain which does not stop the termination
+	EventMessageNodeDrainFailureIgnored = "node %v has failed to drain, termination will continue due to the %v drain failure policy: %v"
+	// EventReasonPodEvicted is the reason for a pod evicted while draining a node
+	EventReasonPodEvicted EventReason = "PodEvicted"
+	// EventMessagePodEvicted is the message for a pod evicted while draining a node
+	EventMessagePodEvicted = "pod %v/%v has been evicted from node %v, %v pods remaining"
+	// EventReasonNodeSkipped is the reason for a node which opted out of processing
+	EventReasonNodeSkipped EventReason = "NodeSkipped"
+	// EventMessageNodeSkipped is the message for a node which opted out of processing
+	EventMessageNodeSkipped = "node %v opted out of processing and will not be drained, lifecycle hook action: %v"
+	// EventReasonWarmPoolInstanceCompleted is the reason for a warm pool instance whose lifecycle hook was completed without draining
+	EventReasonWarmPoolInstanceCompleted EventReason = "WarmPoolInstanceCompleted"
+	// EventMessageWarmPoolInstanceCompleted is the message for a warm pool instance whose lifecycle hook was completed without draining
+	EventMessageWarmPoolInstanceCompleted = "instance %v transitioning from %v to %v is not a node, lifecycle hook completed without draining"
+	// EventReasonSelfTerminationDeferred is the reason for the termination of lifecycle-manager's own node waiting for other events
+	EventReasonSelfTerminationDeferred EventReason = "SelfTerminationDeferred"
+	// EventMessageSelfTerminationDeferred is the message for the termination of lifecycle-manager's own node waiting for other events
+	EventMessageSelfTerminationDeferred = "node %v runs lifecycle-manager, its termination is deferred until %v other in-flight events complete"
+	// EventReasonNodeScaleDownDisabled is the reason for a terminating node which has scale-down disabled
+	EventReasonNodeScaleDownDisabled EventReason = "NodeScaleDownDisabled"
+	// EventMessageNodeScaleDownDisabled is the message for a terminating node which has scale-down disabled
+	EventMessageNodeScaleDownDisabled = "node %v has scale-down disabled by annotation %v, action: %v"
+	// EventReasonJobCompletionWait is the reason for a drain waiting for the jobs running on the node to complete
+	EventReasonJobCompletionWait EventReason = "JobCompletionWait"
+	// EventMessageJobCompletionWait is the message for a drain waiting for the jobs running on the node to complete
+	EventMessageJobCompletionWait = "waiting for %v job pods of node %v to complete for up to %v before draining it"
+	// EventReasonJobCompletionWaitFailed is the reason for job pods which did not complete before the node was drained
+	EventReasonJobCompletionWaitFailed EventReason = "JobCompletionWaitFailed"
+	// EventMessageJobCompletionWaitFailed is the message for job pods which did not complete before the node was drained
+	EventMessageJobCompletionWaitFailed = "job pods of node %v did not complete before it was drained: %v"
 	// EventReasonNodeDeleteSucceeded is the reason for a successful node delete event
 	EventReasonNodeDeleteSucceeded EventReason = "NodeDeleteSucceeded"
 	// EventMessageNodeDeleteSucceeded is the message for a successful node delete event
@@ -54,6 +98,14 @@ const (
 	EventReasonNodeDeleteFailed EventReason = "NodeDeleteFailed"
 	// EventMessageNodeDeleteFailed is the message for a failed node delete event
 	EventMessageNodeDeleteFailed = "node %v deletion has failed: %v"
+	// EventReasonNodeLaunchSucceeded is the reason for a successful node launch event
+	EventReasonNodeLaunchSucceeded EventReason = "NodeLaunchSucceeded"
+	// EventMessageNodeLaunchSucceeded is the message for a successful node launch event
+	EventMessageNodeLaunchSucceeded = "node %v has become ready as a response to a launch event for instance %v"
+	// EventReasonNodeLaunchFailed is the reason for a failed node launch event
+	EventReasonNodeLaunchFailed EventReason = "NodeLaunchFailed"
+	// EventMessageNodeLaunchFailed is the message for a failed node launch event
+	EventMessageNodeLaunchFailed = "instance %v has failed to become a ready node: %v"
 	// EventReasonTargetDeregisterSucceeded is the reason for a successful target group deregister event
 	EventReasonTargetDeregisterSucceeded EventReason = "TargetDeregisterSucceeded"
 	// EventMessageTargetDeregisterSucceeded is the message for a successful target group deregister event
@@ -62,6 +114,10 @@ const (
 	EventReasonTargetDeregisterFailed EventReason = "TargetDeregisterFailed"
 	// EventMessageTargetDeregisterFailed is the message for a successful drain event
 	EventMessageTargetDeregisterFailed = "target %v has failed to deregistered from target group %v: %v"
+	// EventReasonTargetDeregisterDelayExceeded is the reason for a target group whose deregistration delay exceeds the heartbeat timeout
+	EventReasonTargetDeregisterDelayExceeded EventReason = "TargetDeregisterDelayExceeded"
+	// EventMessageTargetDeregisterDelayExceeded is the message for a target group whose deregistration delay exceeds the heartbeat timeout
+	EventMessageTargetDeregisterDelayExceeded = "target group %v has a deregistration delay of %vs, which exceeds the lifecycle hook heartbeat timeout of %vs of instance %v"
 	// EventReasonInstanceDeregisterSucceeded is the reason for a successful target group deregister event
 	EventReasonInstanceDeregisterSucceeded EventReason = "InstanceDeregisterSucceeded"
 	// EventMessageInstanceDeregisterSucceeded is the message for a successful target group deregister event
@@ -70,31 +126,129 @@ const (
 	EventReasonInstanceDeregisterFailed EventReason = "InstanceDeregisterFailed"
 	// EventMessageInstanceDeregisterFailed is the message for a successful classic elb deregister event
 	EventMessageInstanceDeregisterFailed = "instance %v has failed to deregister from classic-elb %v: %v"
+	// EventReasonCloudMapDeregisterSucceeded is the reason for a successful cloud map deregister event
+	EventReasonCloudMapDeregisterSucceeded EventReason = "CloudMapDeregisterSucceeded"
+	// EventMessageCloudMapDeregisterSucceeded is the message for a successful cloud map deregister event
+	EventMessageCloudMapDeregisterSucceeded = "instance %v has successfully deregistered from cloud map service %v"
+	// EventReasonCloudMapDeregisterFailed is the reason for a failed cloud map deregister event
+	EventReasonCloudMapDeregisterFailed EventReason = "CloudMapDeregisterFailed"
+	// EventMessageCloudMapDeregisterFailed is the message for a failed cloud map deregister event
+	EventMessageCloudMapDeregisterFailed = "instance %v has failed to deregister from cloud map service %v: %v"
+	// EventReasonAcceleratorEndpointRemoved is the reason for a successful global accelerator endpoint removal event
+	EventReasonAcceleratorEndpointRemoved EventReason = "AcceleratorEndpointRemoved"
+	// EventMessageAcceleratorEndpointRemoved is the message for a successful global accelerator endpoint removal event
+	EventMessageAcceleratorEndpointRemoved = "instance %v has been dialed down and removed from accelerator endpoint group %v"
+	// EventReasonAcceleratorEndpointRemoveFailed is the reason for a failed global accelerator endpoint removal event
+	EventReasonAcceleratorEndpointRemoveFailed EventReason = "AcceleratorEndpointRemoveFailed"
+	// EventMessageAcceleratorEndpointRemoveFailed is the message for a failed global accelerator endpoint removal event
+	EventMessageAcceleratorEndpointRemoveFailed = "instance %v has failed to be removed from accelerator endpoint group %v: %v"
+	// EventReasonDeregisterFailureIgnored is the reason for a failed load balancer deregistration which does not stop the termination
+	EventReasonDeregisterFailureIgnored EventReason = "DeregisterFailureIgnored"
+	// EventMessageDeregisterFailureIgnored is the message for a failed load balancer deregistration which does not stop the termination
+	EventMessageDeregisterFailureIgnored = "instance %v has failed to deregister from %v, termination will continue due to the %v deregister failure policy: %v"
+	// EventReasonHeartbeatStopped is the reason for heartbeats stopping before an event completed
+	EventReasonHeartbeatStopped EventReason = "HeartbeatStopped"
+	// EventMessageHeartbeatStopped is the message for heartbeats stopping before an event completed
+	EventMessageHeartbeatStopped = "heartbeats for instance %v have stopped before processing completed, the lifecycle hook may time out: %v"
+	// EventReasonVolumeDetachWaitFailed is the reason for volumes which were not detached before the termination continued
+	EventReasonVolumeDetachWaitFailed EventReason = "VolumeDetachWaitFailed"
+	// EventMessageVolumeDetachWaitFailed is the message for volumes which were not detached before the termination continued
+	EventMessageVolumeDetachWaitFailed = "csi volumes of node %v were not detached before termination, rescheduled pods may see multi-attach errors: %v"
+	// EventReasonPodDeletionWaitFailed is the reason for evicted pods which were not deleted before the termination continued
+	EventReasonPodDeletionWaitFailed EventReason = "PodDeletionWaitFailed"
+	// EventMessagePodDeletionWaitFailed is the message for evicted pods which were not deleted before the termination continued
+	EventMessagePodDeletionWaitFailed = "evicted pods of node %v were not deleted before termination continued: %v"
+	// EventReasonReplacementWaitFailed is the reason for a termination which continued before its scaling group regained its desired capacity
+	EventReasonReplacementWaitFailed EventReason = "ReplacementWaitFailed"
+	// EventMessageReplacementWaitFailed is the message for a termination which continued before its scaling group regained its desired capacity
+	EventMessageReplacementWaitFailed = "instance %v is terminating before a replacement became ready, the scaling group may be below capacity: %v"
+	// EventReasonDNSRecordsRemoved is the reason for a successful route53 record cleanup event
+	EventReasonDNSRecordsRemoved EventReason = "DNSRecordsRemoved"
+	// EventMessageDNSRecordsRemoved is the message for a successful route53 record cleanup event
+	EventMessageDNSRecordsRemoved = "%v record sets pointing at node %v were removed from hosted zone %v"
+	// EventReasonDNSRecordsCleanupFailed is the reason for a failed route53 record cleanup event
+	EventReasonDNSRecordsCleanupFailed EventReason = "DNSRecordsCleanupFailed"
+	// EventMessageDNSRecordsCleanupFailed is the message for a failed route53 record cleanup event
+	EventMessageDNSRecordsCleanupFailed = "records pointing at node %v could not be removed, termination will continue: %v"
+	// EventReasonUntrustedMessageRejected is the reason for a message rejected since it was not sent by an allowed account or sender
+	EventReasonUntrustedMessageRejected EventReason = "UntrustedMessageRejected"
+	// EventMessageUntrustedMessageRejected is the message for a message rejected since it was not sent by an allowed account or sender
+	EventMessageUntrustedMessageRejected = "message of request %v for instance %v was rejected, check the queue policy and subscriptions: %v"
+	// EventReasonManualDrainSucceeded is the reason for a node drained and deregistered on request of an operator
+	EventReasonManualDrainSucceeded EventReason = "ManualDrainSucceeded"
+	// EventMessageManualDrainSucceeded is the message for a node drained and deregistered on request of an operator
+	EventMessageManualDrainSucceeded = "node %v has been drained and deregistered on request, instance %v terminated: %v"
+	// EventReasonManualDrainFailed is the reason for a failed drain or deregistration requested by an operator
+	EventReasonManualDrainFailed EventReason = "ManualDrainFailed"
+	// EventMessageManualDrainFailed is the message for a failed drain or deregistration requested by an operator
+	EventMessageManualDrainFailed = "node %v has failed to drain and deregister on request: %v"
+	// EventReasonInterruptionDrainSucceeded is the reason for a node drained and deregistered on an interruption notice
+	EventReasonInterruptionDrainSucceeded EventReason = "InterruptionDrainSucceeded"
+	// EventMessageInterruptionDrainSucceeded is the message for a node drained and deregistered on an interruption notice
+	EventMessageInterruptionDrainSucceeded = "node %v has been drained and deregistered on %v notice, instance terminated: %v"
+	// EventReasonInterruptionDrainFailed is the reason for a failed drain or deregistration on an interruption notice
+	EventReasonInterruptionDrainFailed EventReason = "InterruptionDrainFailed"
+	// EventMessageInterruptionDrainFailed is the message for a failed drain or deregistration on an interruption notice
+	EventMessageInterruptionDrainFailed = "node %v has failed to drain and deregister on %v notice: %v"
+	// EventReasonFailureRateExceeded is the reason for a failure rate of recent events above the alerting threshold
+	EventReasonFailureRateExceeded EventReason = "FailureRateExceeded"
+	// EventMessageFailureRateExceeded is the message for a failure rate of recent events above the alerting threshold
+	EventMessageFailureRateExceeded = "%v of the last %v lifecycle events have failed, above the threshold of %v%%, check permissions and connectivity of lifecycle-manager: %v"
 )
 
 var (
 	// EventName is the default name for service events
 	EventName = "lifecycle-manager.%v"
-	// EventNamespace is the default namespace in which events will be published in
+	// EventNamespace is the default namespace in which events will be published in, unless overridden by --event-namespace
 	EventNamespace = "default"
 
 	// EventLevels is a map of event reasons and their event level
 	EventLevels = map[EventReason]string{
-		EventReasonLifecycleHookReceived:       EventLevelNormal,
-		EventReasonLifecycleHookProcessed:      EventLevelNormal,
-		EventReasonLifecycleHookFailed:         EventLevelWarning,
-		EventReasonNodeDrainSucceeded:          EventLevelNormal,
-		EventReasonNodeDrainFailed:             EventLevelWarning,
-		EventReasonTargetDeregisterSucceeded:   EventLevelNormal,
-		EventReasonTargetDeregisterFailed:      EventLevelWarning,
-		EventReasonInstanceDeregisterSucceeded: EventLevelNormal,
-		EventReasonInstanceDeregisterFailed:    EventLevelWarning,
+		EventReasonLifecycleHookReceived:           EventLevelNormal,
+		EventReasonLifecycleHookProcessed:          EventLevelNormal,
+		EventReasonLifecycleHookFailed:             EventLevelWarning,
+		EventReasonLifecycleHookDeadlineExceeded:   EventLevelWarning,
+		EventReasonNodeDrainSucceeded:              EventLevelNormal,
+		EventReasonNodeDrainSkipped:                EventLevelNormal,
+		EventReasonNodeDrainEscalated:              EventLevelWarning,
+		EventReasonNodeDrainFailed:                 EventLevelWarning,
+		EventReasonPodEvicted:                      EventLevelNormal,
+		EventReasonNodeSkipped:                     EventLevelNormal,
+		EventReasonWarmPoolInstanceCompleted:       EventLevelNormal,
+		EventReasonSelfTerminationDeferred:         EventLevelNormal,
+		EventReasonNodeScaleDownDisabled:           EventLevelWarning,
+		EventReasonJobCompletionWait:               EventLevelNormal,
+		EventReasonJobCompletionWaitFailed:         EventLevelWarning,
+		EventReasonNodeLaunchSucceeded:             EventLevelNormal,
+		EventReasonNodeLaunchFailed:                EventLevelWarning,
+		EventReasonTargetDeregisterSucceeded:       EventLevelNormal,
+		EventReasonTargetDeregisterFailed:          EventLevelWarning,
+		EventReasonTargetDeregisterDelayExceeded:   EventLevelWarning,
+		EventReasonInstanceDeregisterSucceeded:     EventLevelNormal,
+		EventReasonInstanceDeregisterFailed:        EventLevelWarning,
+		EventReasonCloudMapDeregisterSucceeded:     EventLevelNormal,
+		EventReasonCloudMapDeregisterFailed:        EventLevelWarning,
+		EventReasonAcceleratorEndpointRemoved:      EventLevelNormal,
+		EventReasonAcceleratorEndpointRemoveFailed: EventLevelWarning,
+		EventReasonDeregisterFailureIgnored:        EventLevelWarning,
+		EventReasonHeartbeatStopped:                EventLevelWarning,
+		EventReasonPodDeletionWaitFailed:           EventLevelWarning,
+		EventReasonVolumeDetachWaitFailed:          EventLevelWarning,
+		EventReasonReplacementWaitFailed:           EventLevelWarning,
+		EventReasonDNSRecordsRemoved:               EventLevelNormal,
+		EventReasonDNSRecordsCleanupFailed:         EventLevelWarning,
+		EventReasonUntrustedMessageRejected:        EventLevelWarning,
+		EventReasonManualDrainSucceeded:            EventLevelNormal,
+		EventReasonManualDrainFailed:               EventLevelWarning,
+		EventReasonInterruptionDrainSucceeded:      EventLevelNormal,
+		EventReasonInterruptionDrainFailed:         EventLevelWarning,
+		EventReasonFailureRateExceeded:             EventLevelWarning,
 	}
 )
 
 func publishKubernetesEvent(kubeClient kubernetes.Interface, event *v1.Event) {
 	log.Debugf("publishing event: %v", event.Reason)
-	_, err := kubeClient.CoreV1().Events(EventNamespace).Create(context.Background(), event, apimachinery_v1.CreateOptions{})
+	_, err := kubeClient.CoreV1().Events(event.Namespace).Create(context.Background(), event, apimachinery_v1.CreateOptions{})
 	if err != nil {
 		log.Errorf("failed to publish event: %v", err)
 	}
@@ -108,12 +262,38 @@ func getReasonEventLevel(reason EventReason) string {
 }
 
 func getMessageFields(event *LifecycleEvent, details string) map[string]string {
-	return map[string]string{
+	fields := map[string]string{
 		"eventID":       event.RequestID,
 		"ec2InstanceId": event.EC2InstanceID,
 		"asgName":       event.AutoScalingGroupName,
 		"details":       details,
 	}
+	if event.isInstanceRefresh() {
+		fields["instanceRefreshId"] = event.instanceRefreshID
+	}
+	return fields
+}
+
+// getTimelineFields returns the message fields of an event which completed or failed, with the time it was validated
+// and its drain and deregistration started and ended, steps the event did not reach are omitted
+func getTimelineFields(event *LifecycleEvent, details string) map[string]string {
+	fields := getMessageFields(event, details)
+	setTime := func(key string, t time.Time) {
+		if !t.IsZero() {
+			fields[key] = t.UTC().Format(time.RFC3339)
+		}
+	}
+	timing := event.timing()
+	setTime("validatedAt", event.validatedTime)
+	setTime("drainStartedAt", timing.drainStartTime)
+	if timing.drainDuration > 0 {
+		setTime("drainEndedAt", timing.drainStartTime.Add(timing.drainDuration))
+	}
+	setTime("deregisterStartedAt", timing.deregisterStartTime)
+	if timing.deregisterDuration > 0 {
+		setTime("deregisterEndedAt", timing.deregisterStartTime.Add(timing.deregisterDuration))
+	}
+	return fields
 }
 
 func newKubernetesEvent(reason EventReason, msgFields map[string]string) *v1.Event {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
@@ -76,3 +77,34 @@ func Test_PublishEvent(t *testing.T) {
 	}
 
 }
+
+func Test_GetTimelineFields(t *testing.T) {
+	t.Log("Test_GetTimelineFields: should include the time of each step the event reached")
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	event := &LifecycleEvent{
+		EC2InstanceID:  "i-123456789012",
+		validatedTime:  start,
+		drainStartTime: start.Add(time.Minute),
+		drainDuration:  2 * time.Minute,
+	}
+
+	fields := getTimelineFields(event, "completed")
+	expected := map[string]string{
+		"validatedAt":    "2024-01-01T10:00:00Z",
+		"drainStartedAt": "2024-01-01T10:01:00Z",
+		"drainEndedAt":   "2024-01-01T10:03:00Z",
+	}
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Fatalf("expected %v: %v, got: %v", key, value, fields[key])
+		}
+	}
+	for _, key := range []string{"deregisterStartedAt", "deregisterEndedAt"} {
+		if _, ok := fields[key]; ok {
+			t.Fatalf("expected %v to be omitted, got: %v", key, fields[key])
+		}
+	}
+	if fields["details"] != "completed" {
+		t.Fatalf("expected details: completed, got: %v", fields["details"])
+	}
+}
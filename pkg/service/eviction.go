@@ -0,0 +1,146 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	v1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	drain "k8s.io/kubectl/pkg/drain"
+)
+
+const (
+	// EvictionOrderNone evicts all pods of a node at once
+	EvictionOrderNone = "none"
+	// EvictionOrderPriority evicts stateless pods before stateful pods, lowest priority first
+	EvictionOrderPriority = "priority"
+)
+
+// drainPolicy controls how pods are evicted from a draining node
+type drainPolicy struct {
+	// order is the eviction order of pods, EvictionOrderNone or EvictionOrderPriority
+	order string
+	// gracePeriodSeconds overrides the termination grace period of evicted pods, -1 keeps the pod's own
+	gracePeriodSeconds int
+	// excludedPods are the namespace/name of pods left running on the node
+	excludedPods map[string]bool
+	// disableEviction deletes pods rather than evicting them, bypassing their pod disruption budgets
+	disableEviction bool
+	// excludedNamespaces are the namespaces whose pods are never evicted, draining a node running them fails instead
+	excludedNamespaces map[string]bool
+}
+
+// EvictionExcludeAnnotationKey is set to "true" on pods which are never evicted, draining a node running them fails
+// instead
+var EvictionExcludeAnnotationKey = "lifecycle-manager.keikoproj.io/exclude-from-eviction"
+
+// defaultDrainPolicy evicts all pods at once with their own termination grace period
+var defaultDrainPolicy = drainPolicy{
+	order:              EvictionOrderNone,
+	gracePeriodSeconds: -1,
+}
+
+// isEvictionExcluded returns true if a running pod must not be evicted, by its namespace or annotation
+func (p drainPolicy) isEvictionExcluded(pod v1.Pod) bool {
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return false
+	}
+	return p.excludedNamespaces[pod.Namespace] || pod.GetAnnotations()[EvictionExcludeAnnotationKey] == "true"
+}
+
+// filterPod leaves excluded pods running on the node and fails the drain of nodes running pods excluded from eviction
+func (p drainPolicy) filterPod(pod v1.Pod) drain.PodDeleteStatus {
+	if p.excludedPods[pod.Namespace+"/"+pod.Name] {
+		return drain.MakePodDeleteStatusSkip()
+	}
+	if p.isEvictionExcluded(pod) {
+		return drain.MakePodDeleteStatusWithError("pods excluded from eviction by namespace or annotation")
+	}
+	return drain.MakePodDeleteStatusOkay()
+}
+
+// IsValidEvictionOrder returns true if the eviction order is supported
+func IsValidEvictionOrder(order string) bool {
+	switch order {
+	case EvictionOrderNone, EvictionOrderPriority:
+		return true
+	}
+	return false
+}
+
+// isStatefulPod returns true if the pod is owned by a stateful set or mounts a persistent volume claim
+func isStatefulPod(pod v1.Pod) bool {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "StatefulSet" {
+			return true
+		}
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func podPriority(pod v1.Pod) int32 {
+	if pod.Spec.Priority == nil {
+		return 0
+	}
+	return *pod.Spec.Priority
+}
+
+// evictionTiers groups pods into tiers to evict one after the other, stateless pods come before
+// stateful pods and lower priority pods before higher priority pods
+func evictionTiers(pods []v1.Pod) [][]v1.Pod {
+	sorted := make([]v1.Pod, len(pods))
+	copy(sorted, pods)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		iStateful, jStateful := isStatefulPod(sorted[i]), isStatefulPod(sorted[j])
+		if iStateful != jStateful {
+			return !iStateful
+		}
+		return podPriority(sorted[i]) < podPriority(sorted[j])
+	})
+
+	tiers := make([][]v1.Pod, 0)
+	for i, pod := range sorted {
+		if i > 0 && isStatefulPod(sorted[i-1]) == isStatefulPod(pod) && podPriority(sorted[i-1]) == podPriority(pod) {
+			tiers[len(tiers)-1] = append(tiers[len(tiers)-1], pod)
+			continue
+		}
+		tiers = append(tiers, []v1.Pod{pod})
+	}
+	return tiers
+}
+
+// runOrderedDrain evicts the pods of a cordoned node tier by tier, waiting for each tier to be deleted
+// before evicting the next one. DaemonSet and mirror pods are skipped as by drain.RunNodeDrain.
+func runOrderedDrain(helper *drain.Helper, nodeName string) error {
+	list, errs := helper.GetPodsForDeletion(nodeName)
+	if errs != nil {
+		return utilerrors.NewAggregate(errs)
+	}
+	if warnings := list.Warnings(); warnings != "" {
+		log.Warnf("drain warnings for node %v: %v", nodeName, warnings)
+	}
+
+	timeout := helper.Timeout
+	deadline := time.Now().Add(timeout)
+	for _, tier := range evictionTiers(list.Pods()) {
+		if timeout > 0 {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return fmt.Errorf("drain did not complete within %v", timeout)
+			}
+			helper.Timeout = remaining
+		}
+		log.Debugf("evicting %v pods of priority %v from node %v", len(tier), podPriority(tier[0]), nodeName)
+		if err := helper.DeleteOrEvictPods(tier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
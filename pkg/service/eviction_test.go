@@ -0,0 +1,114 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	drain "k8s.io/kubectl/pkg/drain"
+)
+
+func _newPriorityPod(name string, priority int32, stateful bool) v1.Pod {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.PodSpec{
+			Priority: &priority,
+		},
+	}
+	if stateful {
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: "StatefulSet", Name: "some-set"}}
+	}
+	return pod
+}
+
+func Test_EvictionTiers(t *testing.T) {
+	t.Log("Test_EvictionTiers: should evict stateless pods first, lowest priority first")
+	pods := []v1.Pod{
+		_newPriorityPod("stateful-low", 0, true),
+		_newPriorityPod("stateless-high", 1000, false),
+		_newPriorityPod("stateless-low", 0, false),
+		_newPriorityPod("stateless-low-2", 0, false),
+		_newPriorityPod("stateful-high", 1000, true),
+	}
+	pvcPod := _newPriorityPod("pvc-low", 0, false)
+	pvcPod.Spec.Volumes = []v1.Volume{
+		{
+			Name: "data",
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "data"},
+			},
+		},
+	}
+	pods = append(pods, pvcPod)
+
+	expected := [][]string{
+		{"stateless-low", "stateless-low-2"},
+		{"stateless-high"},
+		{"stateful-low", "pvc-low"},
+		{"stateful-high"},
+	}
+
+	tiers := evictionTiers(pods)
+	got := make([][]string, 0)
+	for _, tier := range tiers {
+		names := make([]string, 0)
+		for _, pod := range tier {
+			names = append(names, pod.Name)
+		}
+		got = append(got, names)
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected tiers: %v, got: %v", expected, got)
+	}
+}
+
+func Test_DrainPolicyFilterPod(t *testing.T) {
+	t.Log("Test_DrainPolicyFilterPod: should fail the drain of pods excluded from eviction by namespace or annotation")
+	policy := drainPolicy{
+		excludedPods:       map[string]bool{"lifecycle-manager/lifecycle-manager-0": true},
+		excludedNamespaces: map[string]bool{"payments": true},
+	}
+
+	tests := []struct {
+		pod      v1.Pod
+		expected drain.PodDeleteStatus
+	}{
+		{
+			pod:      v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}},
+			expected: drain.MakePodDeleteStatusOkay(),
+		},
+		{
+			pod:      v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "lifecycle-manager-0", Namespace: "lifecycle-manager"}},
+			expected: drain.MakePodDeleteStatusSkip(),
+		},
+		{
+			pod:      v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ledger", Namespace: "payments"}},
+			expected: drain.MakePodDeleteStatusWithError("pods excluded from eviction by namespace or annotation"),
+		},
+		{
+			pod: v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "ledger", Namespace: "payments"},
+				Status:     v1.PodStatus{Phase: v1.PodSucceeded},
+			},
+			expected: drain.MakePodDeleteStatusOkay(),
+		},
+		{
+			pod: v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name:        "audit",
+				Namespace:   "default",
+				Annotations: map[string]string{EvictionExcludeAnnotationKey: "true"},
+			}},
+			expected: drain.MakePodDeleteStatusWithError("pods excluded from eviction by namespace or annotation"),
+		},
+	}
+
+	for _, tc := range tests {
+		if got := policy.filterPod(tc.pod); !reflect.DeepEqual(got, tc.expected) {
+			t.Fatalf("expected status of pod %v/%v: %+v, got: %+v", tc.pod.Namespace, tc.pod.Name, tc.expected, got)
+		}
+	}
+}
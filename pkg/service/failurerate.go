@@ -0,0 +1,118 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+var (
+	// DefaultFailureRateWindow is the number of most recent events the failure rate is computed over by default
+	DefaultFailureRateWindow = 10
+	// DefaultFailureRateThreshold is the failure rate above which an alert is raised by default
+	DefaultFailureRateThreshold = 0.5
+	// FailureRateSeverity is the severity of failure rate alerts, set as the severity field of the published event
+	FailureRateSeverity = "critical"
+)
+
+// failureRateTracker computes the failure rate of the most recent events, an alert is raised once when the rate
+// crosses the threshold and again only after the rate went back to or below it
+type failureRateTracker struct {
+	sync.Mutex
+	outcomes  []bool
+	next      int
+	count     int
+	threshold float64
+	alerting  bool
+}
+
+func newFailureRateTracker(window int, threshold float64) *failureRateTracker {
+	if window <= 0 {
+		return nil
+	}
+	return &failureRateTracker{
+		outcomes:  make([]bool, window),
+		threshold: threshold,
+	}
+}
+
+// record adds the outcome of an event and returns the failure rate of the window and the number of failed events,
+// crossed is true when the rate went above the threshold with this outcome
+func (t *failureRateTracker) record(failed bool) (rate float64, failures int, crossed bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.outcomes[t.next] = failed
+	t.next = (t.next + 1) % len(t.outcomes)
+	if t.count < len(t.outcomes) {
+		t.count++
+	}
+
+	for i := 0; i < t.count; i++ {
+		if t.outcomes[i] {
+			failures++
+		}
+	}
+	rate = float64(failures) / float64(t.count)
+
+	// a partial window is not representative of the failure rate
+	if t.count < len(t.outcomes) {
+		return rate, failures, false
+	}
+
+	if rate <= t.threshold {
+		t.alerting = false
+		return rate, failures, false
+	}
+	if t.alerting {
+		return rate, failures, false
+	}
+	t.alerting = true
+	return rate, failures, true
+}
+
+// observeOutcome records the outcome of a completed or failed event and publishes a failure rate alert when the
+// failure rate of the most recent events crossed the threshold
+func (mgr *Manager) observeOutcome(event *LifecycleEvent, failed bool, err error) {
+	var (
+		metrics = mgr.metrics
+		tracker = mgr.failureRate
+	)
+	if tracker == nil {
+		return
+	}
+
+	rate, failures, crossed := tracker.record(failed)
+	metrics.SetGauge(EventFailureRateMetric, nil, rate)
+	if !crossed {
+		return
+	}
+
+	msg := fmt.Sprintf(EventMessageFailureRateExceeded, failures, len(tracker.outcomes), tracker.threshold*100, err)
+	log.Errorf("%v> %v", event.EC2InstanceID, msg)
+	fields := map[string]string{
+		"severity":      FailureRateSeverity,
+		"failureRate":   strconv.FormatFloat(rate, 'f', 2, 64),
+		"failedEvents":  strconv.Itoa(failures),
+		"windowSize":    strconv.Itoa(len(tracker.outcomes)),
+		"lastEventID":   event.RequestID,
+		"ec2InstanceId": event.EC2InstanceID,
+		"asgName":       event.AutoScalingGroupName,
+		"details":       msg,
+	}
+	metrics.AddCounter(FailureRateAlertsTotalMetric, nil, 1)
+
+	// the alert is about the service rather than a node, it is not attached to the event's node
+	mgr.publishEvent(&LifecycleEvent{}, EventReasonFailureRateExceeded, fields)
+
+	if mgr.context.FailureRateWebhookURL == "" {
+		return
+	}
+	webhook := &WebhookEventSink{url: mgr.context.FailureRateWebhookURL, client: &http.Client{Timeout: WebhookTimeout}}
+	if err := webhook.Publish(event, EventReasonFailureRateExceeded, fields); err != nil {
+		log.Errorf("failed to post failure rate alert: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_FailureRateTracker(t *testing.T) {
+	t.Log("Test_FailureRateTracker: should report crossing the threshold once per full window above it")
+	tracker := newFailureRateTracker(4, 0.5)
+
+	outcomes := []struct {
+		failed  bool
+		crossed bool
+	}{
+		{true, false},  // partial window
+		{true, false},  // partial window
+		{true, false},  // partial window
+		{false, true},  // 3/4 failed
+		{true, false},  // 3/4 failed, already alerting
+		{false, false}, // 2/4 failed, back at the threshold
+		{true, false},  // 2/4 failed
+		{true, true},   // 3/4 failed
+	}
+
+	for i, o := range outcomes {
+		if _, _, crossed := tracker.record(o.failed); crossed != o.crossed {
+			t.Fatalf("outcome %v: expected crossed: %v, got: %v", i, o.crossed, crossed)
+		}
+	}
+
+	if newFailureRateTracker(0, 0.5) != nil {
+		t.Fatal("expected failure rate tracker to be disabled with an empty window")
+	}
+}
+
+func Test_ObserveOutcome(t *testing.T) {
+	t.Log("Test_ObserveOutcome: should publish a failure rate alert to the event sinks and the webhook")
+	var alerts []SinkEvent
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert SinkEvent
+		json.NewDecoder(r.Body).Decode(&alert)
+		alerts = append(alerts, alert)
+	}))
+	defer webhook.Close()
+
+	kubeClient := fake.NewSimpleClientset()
+	auth := Authenticator{
+		KubernetesClient: kubeClient,
+	}
+	ctx := _newBasicContext()
+	ctx.FailureRateWindow = 2
+	ctx.FailureRateThreshold = 0.5
+	ctx.FailureRateWebhookURL = webhook.URL
+	mgr := New(auth, ctx)
+
+	event := &LifecycleEvent{RequestID: "63f5b5c2-58b3-0574-b7d5-b3162d0268f0", EC2InstanceID: "i-123486890234"}
+	failure := errors.New("AccessDenied")
+	mgr.observeOutcome(event, true, failure)
+	mgr.observeOutcome(event, true, failure)
+	mgr.observeOutcome(event, true, failure)
+
+	if len(alerts) != 1 {
+		t.Fatalf("expected webhook alerts: %v, got: %v", 1, len(alerts))
+	}
+	if alerts[0].Reason != string(EventReasonFailureRateExceeded) || alerts[0].Fields["severity"] != FailureRateSeverity {
+		t.Fatalf("expected alert: %v with severity %v, got: %+v", EventReasonFailureRateExceeded, FailureRateSeverity, alerts[0])
+	}
+
+	events, _ := kubeClient.CoreV1().Events(EventNamespace).List(context.Background(), metav1.ListOptions{})
+	published := 0
+	for _, e := range events.Items {
+		if e.Reason == string(EventReasonFailureRateExceeded) {
+			published++
+		}
+	}
+	if published != 1 {
+		t.Fatalf("expected %v events: %v, got: %v", EventReasonFailureRateExceeded, 1, published)
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+const (
+	// HistoryOutcomeCompleted is the outcome of events whose lifecycle hook completed with CONTINUE
+	HistoryOutcomeCompleted = "completed"
+	// HistoryOutcomeFailed is the outcome of events that failed processing
+	HistoryOutcomeFailed = "failed"
+	// HistoryOutcomeAbandoned is the outcome of events that failed processing and were abandoned
+	HistoryOutcomeAbandoned = "abandoned"
+	// HistoryOutcomeRetried is the outcome of events that failed with a transient error and were returned to the queue
+	HistoryOutcomeRetried = "retried"
+)
+
+var (
+	// HistoryEndpoint is the endpoint of the metrics server serving the event history
+	HistoryEndpoint = "/history"
+	// DefaultHistorySize is the number of events kept in the event history by default
+	DefaultHistorySize = 100
+)
+
+// EventRecord is the record of a completed or failed event kept in the event history
+type EventRecord struct {
+	RequestID                 string    `json:"requestId"`
+	ClusterName               string    `json:"clusterName,omitempty"`
+	InstanceID                string    `json:"instanceId"`
+	ScalingGroupName          string    `json:"scalingGroupName"`
+	Transition                string    `json:"transition"`
+	InstanceRefreshID         string    `json:"instanceRefreshId,omitempty"`
+	NodeName                  string    `json:"nodeName,omitempty"`
+	Outcome                   string    `json:"outcome"`
+	Error                     string    `json:"error,omitempty"`
+	StartTime                 time.Time `json:"startTime"`
+	EndTime                   time.Time `json:"endTime"`
+	DurationSeconds           float64   `json:"durationSeconds"`
+	DrainDurationSeconds      float64   `json:"drainDurationSeconds"`
+	DeregisterDurationSeconds float64   `json:"deregisterDurationSeconds"`
+}
+
+func newEventRecord(event *LifecycleEvent, outcome string, err error) EventRecord {
+	now := time.Now().UTC()
+	timing := event.timing()
+	record := EventRecord{
+		RequestID:                 event.RequestID,
+		InstanceID:                event.EC2InstanceID,
+		ScalingGroupName:          event.AutoScalingGroupName,
+		Transition:                event.LifecycleTransition,
+		InstanceRefreshID:         event.instanceRefreshID,
+		NodeName:                  event.referencedNode.Name,
+		Outcome:                   outcome,
+		StartTime:                 event.startTime.UTC(),
+		EndTime:                   now,
+		DrainDurationSeconds:      timing.drainDuration.Seconds(),
+		DeregisterDurationSeconds: timing.deregisterDuration.Seconds(),
+	}
+	if !event.startTime.IsZero() {
+		record.DurationSeconds = now.Sub(event.startTime).Seconds()
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	return record
+}
+
+// EventHistory is a bounded ring buffer of the last completed or failed events
+type EventHistory struct {
+	sync.Mutex
+	records []EventRecord
+	next    int
+	full    bool
+}
+
+// NewEventHistory returns an event history keeping the last size events, a size of 0 disables the history
+func NewEventHistory(size int) *EventHistory {
+	if size < 0 {
+		size = 0
+	}
+	return &EventHistory{
+		records: make([]EventRecord, size),
+	}
+}
+
+// Add records an event, overwriting the oldest record once the history is full
+func (h *EventHistory) Add(record EventRecord) {
+	if h == nil || len(h.records) == 0 {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	h.records[h.next] = record
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// List returns the recorded events, most recent first
+func (h *EventHistory) List() []EventRecord {
+	records := make([]EventRecord, 0)
+	if h == nil || len(h.records) == 0 {
+		return records
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	count := h.next
+	if h.full {
+		count = len(h.records)
+	}
+	for i := 1; i <= count; i++ {
+		idx := (h.next - i + len(h.records)) % len(h.records)
+		records = append(records, h.records[idx])
+	}
+	return records
+}
+
+// ServeHTTP serves the recorded events as JSON, most recent first
+func (h *EventHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.List()); err != nil {
+		log.Errorf("failed to serve event history: %v", err)
+	}
+}
+
+// recordEvent adds a completed or failed event to the manager's event history and audit log
+func (mgr *Manager) recordEvent(event *LifecycleEvent, outcome string, err error) {
+	record := newEventRecord(event, outcome, err)
+	record.ClusterName = mgr.context.ClusterName
+	mgr.history.Add(record)
+
+	if mgr.auditLog == nil {
+		return
+	}
+	if err := mgr.auditLog.Record(record); err != nil {
+		log.Errorf("%v> failed to write audit record: %v", event.EC2InstanceID, err)
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_EventHistory(t *testing.T) {
+	t.Log("Test_EventHistory: should keep the last events, most recent first")
+	history := NewEventHistory(3)
+
+	if records := history.List(); len(records) != 0 {
+		t.Fatalf("expected empty history, got: %v", records)
+	}
+
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		history.Add(EventRecord{RequestID: id})
+	}
+
+	records := history.List()
+	expected := []string{"e", "d", "c"}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %v records, got: %v", len(expected), len(records))
+	}
+	for i, id := range expected {
+		if records[i].RequestID != id {
+			t.Fatalf("expected record %v: %v, got: %v", i, id, records[i].RequestID)
+		}
+	}
+}
+
+func Test_EventHistoryDisabled(t *testing.T) {
+	t.Log("Test_EventHistoryDisabled: should not record events when the history size is 0 or the history is nil")
+	history := NewEventHistory(0)
+	history.Add(EventRecord{RequestID: "a"})
+	if records := history.List(); len(records) != 0 {
+		t.Fatalf("expected empty history, got: %v", records)
+	}
+
+	var nilHistory *EventHistory
+	nilHistory.Add(EventRecord{RequestID: "a"})
+	if records := nilHistory.List(); len(records) != 0 {
+		t.Fatalf("expected empty history, got: %v", records)
+	}
+}
+
+func Test_NewEventRecord(t *testing.T) {
+	t.Log("Test_NewEventRecord: should record the event's durations, outcome and error")
+	event := &LifecycleEvent{
+		RequestID:            "123456789",
+		EC2InstanceID:        "i-123486890234",
+		AutoScalingGroupName: "my-asg",
+		LifecycleTransition:  TerminationEventName,
+		startTime:            time.Now().Add(-time.Minute),
+		drainDuration:        30 * time.Second,
+		deregisterDuration:   10 * time.Second,
+	}
+	event.referencedNode.Name = "ip-10-10-10-10.us-west-2.compute.internal"
+
+	record := newEventRecord(event, HistoryOutcomeAbandoned, errors.New("drain failed"))
+	if record.Outcome != HistoryOutcomeAbandoned {
+		t.Fatalf("expected outcome: %v, got: %v", HistoryOutcomeAbandoned, record.Outcome)
+	}
+	if record.Error != "drain failed" {
+		t.Fatalf("expected error: %v, got: %v", "drain failed", record.Error)
+	}
+	if record.DurationSeconds < 60 {
+		t.Fatalf("expected duration of at least 60s, got: %v", record.DurationSeconds)
+	}
+	if record.DrainDurationSeconds != 30 || record.DeregisterDurationSeconds != 10 {
+		t.Fatalf("expected drain/deregister durations: 30/10, got: %v/%v", record.DrainDurationSeconds, record.DeregisterDurationSeconds)
+	}
+	if record.NodeName != event.referencedNode.Name {
+		t.Fatalf("expected node name: %v, got: %v", event.referencedNode.Name, record.NodeName)
+	}
+}
+
+func Test_EventHistoryServeHTTP(t *testing.T) {
+	t.Log("Test_EventHistoryServeHTTP: should serve the history as JSON")
+	history := NewEventHistory(10)
+	history.Add(EventRecord{RequestID: "a", Outcome: HistoryOutcomeCompleted})
+	history.Add(EventRecord{RequestID: "b", Outcome: HistoryOutcomeFailed})
+
+	server := httptest.NewServer(history)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + HistoryEndpoint)
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	defer resp.Body.Close()
+
+	records := make([]EventRecord, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		t.Fatalf("failed to decode history: %v", err)
+	}
+	if len(records) != 2 || records[0].RequestID != "b" || records[1].Outcome != HistoryOutcomeCompleted {
+		t.Fatalf("expected records b, a, got: %+v", records)
+	}
+
+	resp, err = http.Post(server.URL+HistoryEndpoint, "application/json", nil)
+	if err != nil {
+		t.Fatalf("failed to post history: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status: %v, got: %v", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
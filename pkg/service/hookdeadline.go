@@ -0,0 +1,58 @@
+package service
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// HookRemainingInterval is the interval to update the seconds remaining until the lifecycle hook of an event expires
+	// and the age of its last heartbeat at
+	HookRemainingInterval = 15 * time.Second
+)
+
+// InstanceLabels are the labels of per event metrics
+var InstanceLabels = append(append([]string{}, ScalingGroupLabels...), "instance_id")
+
+// instanceLabels returns the per event metric labels of an event
+func instanceLabels(event *LifecycleEvent) prometheus.Labels {
+	labels := eventLabels(event)
+	labels["instance_id"] = event.EC2InstanceID
+	return labels
+}
+
+// trackHookDeadline updates the seconds remaining until the lifecycle hook of an event expires, or the event exceeds
+// --max-time-to-process, and the seconds since its last successful heartbeat until the event is processed, its
+// series are then removed
+func (mgr *Manager) trackHookDeadline(event *LifecycleEvent) {
+	var (
+		metrics = mgr.metrics
+		ctx     = event.Context()
+		labels  = instanceLabels(event)
+	)
+
+	defer metrics.DeleteGauge(HookRemainingSecondsMetric, labels)
+	defer metrics.DeleteGauge(HeartbeatAgeSecondsMetric, labels)
+
+	ticker := time.NewTicker(HookRemainingInterval)
+	defer ticker.Stop()
+
+	for {
+		if remaining, ok := event.remainingTime(); ok {
+			if remaining < 0 {
+				remaining = 0
+			}
+			metrics.SetGauge(HookRemainingSecondsMetric, labels, remaining.Seconds())
+		}
+		if age, ok := event.heartbeatAge(); ok {
+			metrics.SetGauge(HeartbeatAgeSecondsMetric, labels, age.Seconds())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func Test_TrackHookDeadline(t *testing.T) {
+	t.Log("Test_TrackHookDeadline: should export the seconds remaining until the lifecycle hook expires while the event is processed")
+	mgr := New(Authenticator{}, _newBasicContext())
+	mgr.metrics.Gauges = map[string]*prometheus.GaugeVec{
+		HookRemainingSecondsMetric: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: HookRemainingSecondsMetric}, InstanceLabels),
+	}
+	event := &LifecycleEvent{AutoScalingGroupName: "my-asg", LifecycleTransition: TerminationEventName, EC2InstanceID: "i-123486890234"}
+	event.SetHeartbeatInterval(60)
+	event.SetEventTimeStarted(time.Now().Add(-time.Hour))
+	event.SetContext(context.WithCancel(context.Background()))
+
+	done := make(chan struct{})
+	go func() {
+		mgr.trackHookDeadline(event)
+		close(done)
+	}()
+
+	gauge := mgr.metrics.Gauges[HookRemainingSecondsMetric]
+	expected := (time.Duration(60*HookMaxHeartbeats)*time.Second - time.Hour).Seconds()
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.CollectAndCount(gauge) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	remaining := testutil.ToFloat64(gauge.With(instanceLabels(event)))
+	if remaining > expected || remaining < expected-5 {
+		t.Fatalf("expected remaining seconds: %v, got: %v", expected, remaining)
+	}
+
+	event.cancel()
+	<-done
+	if count := testutil.CollectAndCount(gauge); count != 0 {
+		t.Fatalf("expected series to be removed once the event is processed, got: %v", count)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	// InstanceGroupResource is the resource of instance-manager's InstanceGroup custom resources
+	InstanceGroupResource = schema.GroupVersionResource{
+		Group:    "instancemgr.keikoproj.io",
+		Version:  "v1alpha1",
+		Resource: "instancegroups",
+	}
+)
+
+// getInstanceGroup returns the InstanceGroup whose active scaling group is scalingGroupName, false is returned if
+// there is none, or if the InstanceGroup custom resource is not installed in the cluster
+func getInstanceGroup(client dynamic.Interface, scalingGroupName string) (*unstructured.Unstructured, bool, error) {
+	list, err := client.Resource(InstanceGroupResource).Namespace(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	for i := range list.Items {
+		group := &list.Items[i]
+		name, _, _ := unstructured.NestedString(group.Object, "status", "activeScalingGroupName")
+		if name == scalingGroupName {
+			return group, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// applyInstanceGroup overrides settings with the annotations of the event's InstanceGroup, which take the keys of the
+// scaling group tags, so that the lifecycle settings of a group are declared alongside it rather than in flags
+func (mgr *Manager) applyInstanceGroup(event *LifecycleEvent, settings *EventSettings) {
+	var (
+		client = mgr.authenticator.DynamicClient
+	)
+
+	if !mgr.context.WithInstanceManager || client == nil {
+		return
+	}
+
+	group, found, err := getInstanceGroup(client, event.AutoScalingGroupName)
+	if err != nil {
+		log.Warnf("%v> failed to get instance group of scaling group %v: %v", event.EC2InstanceID, event.AutoScalingGroupName, err)
+		return
+	}
+	if !found {
+		log.Debugf("%v> scaling group %v is not managed by an instance group", event.EC2InstanceID, event.AutoScalingGroupName)
+		return
+	}
+
+	log.Debugf("%v> applying settings of instancegroup %v/%v", event.EC2InstanceID, group.GetNamespace(), group.GetName())
+	settings.applyTags(event.EC2InstanceID, group.GetAnnotations())
+}
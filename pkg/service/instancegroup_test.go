@@ -0,0 +1,80 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func _newInstanceGroup(name, scalingGroupName string, annotations map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "instancemgr.keikoproj.io/v1alpha1",
+			"kind":       "InstanceGroup",
+			"metadata": map[string]interface{}{
+				"name":        name,
+				"namespace":   "instance-manager",
+				"annotations": annotations,
+			},
+			"status": map[string]interface{}{
+				"activeScalingGroupName": scalingGroupName,
+			},
+		},
+	}
+}
+
+func Test_ResolveEventSettingsInstanceGroup(t *testing.T) {
+	t.Log("Test_ResolveEventSettingsInstanceGroup: should override scaling group tags with the annotations of its instance group")
+	stubber := &stubAutoscaling{
+		scalingGroups: []*autoscaling.Group{
+			{
+				AutoScalingGroupName: aws.String("my-asg"),
+				Tags: []*autoscaling.TagDescription{
+					{Key: aws.String(DrainTimeoutTagKey), Value: aws.String("900")},
+					{Key: aws.String(DrainRetryIntervalTagKey), Value: aws.String("60")},
+				},
+			},
+		},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{InstanceGroupResource: "InstanceGroupList"},
+		_newInstanceGroup("batch", "my-asg", map[string]interface{}{
+			DrainTimeoutTagKey:       "1200",
+			DrainFailurePolicyTagKey: "continue",
+		}),
+		_newInstanceGroup("other", "other-asg", map[string]interface{}{
+			DrainTimeoutTagKey: "60",
+		}),
+	)
+
+	ctx := _newBasicContext()
+	ctx.WithInstanceManager = true
+	mgr := New(Authenticator{ScalingGroupClient: stubber, DynamicClient: dynamicClient}, ctx)
+	event := &LifecycleEvent{
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-1234567890",
+	}
+
+	settings := mgr.resolveEventSettings(event)
+	if settings.DrainTimeoutSeconds != 1200 {
+		t.Fatalf("expected DrainTimeoutSeconds: %v, got: %v", 1200, settings.DrainTimeoutSeconds)
+	}
+	if settings.DrainRetryIntervalSeconds != 60 {
+		t.Fatalf("expected DrainRetryIntervalSeconds: %v, got: %v", 60, settings.DrainRetryIntervalSeconds)
+	}
+	if settings.DrainFailurePolicy != FailurePolicyContinue {
+		t.Fatalf("expected DrainFailurePolicy: %v, got: %v", FailurePolicyContinue, settings.DrainFailurePolicy)
+	}
+
+	// instance groups are ignored unless enabled
+	mgr.context.WithInstanceManager = false
+	settings = mgr.resolveEventSettings(event)
+	if settings.DrainTimeoutSeconds != 900 {
+		t.Fatalf("expected DrainTimeoutSeconds: %v, got: %v", 900, settings.DrainTimeoutSeconds)
+	}
+}
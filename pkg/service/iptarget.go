@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// getNodePodIPs returns the IPs of running pods scheduled on a node, host network pods are excluded since they share the node's IP
+func getNodePodIPs(ctx context.Context, kubeClient kubernetes.Interface, nodeName string) ([]string, error) {
+	ips := []string{}
+	selector := fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+	pods, err := kubeClient.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return ips, err
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Spec.HostNetwork || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if pod.Status.PodIP != "" {
+			ips = append(ips, pod.Status.PodIP)
+		}
+	}
+	return ips, nil
+}
+
+// waitForIPTargetsDrained waits for the IP targets of the pods evicted from the event's node to be deregistered
+// from ip target type target groups
+func (mgr *Manager) waitForIPTargetsDrained(event *LifecycleEvent) error {
+	var (
+		ctx          = &mgr.context
+		elbv2Client  = mgr.authenticator.ELBv2Client
+		instanceID   = event.EC2InstanceID
+		podIPs       = event.referencedPodIPs
+		waiterConfig = mgr.waiterConfig()
+		targetGroups = []*elbv2.TargetGroup{}
+		errs         error
+		mutex        sync.Mutex
+	)
+
+	if !ctx.WithIPTargetWait || event.settings.SkipDeregister || len(podIPs) == 0 {
+		return nil
+	}
+
+	err := elbv2Client.DescribeTargetGroupsPagesWithContext(event.Context(), &elbv2.DescribeTargetGroupsInput{}, func(page *elbv2.DescribeTargetGroupsOutput, lastPage bool) bool {
+		for _, tg := range page.TargetGroups {
+			if aws.StringValue(tg.TargetType) == elbv2.TargetTypeEnumIp {
+				targetGroups = append(targetGroups, tg)
+			}
+		}
+		return page.NextMarker != nil
+	})
+	if err != nil {
+		return err
+	}
+
+	targetGroups, _, err = mgr.filterLoadBalancersByTags(event.Context(), targetGroups, nil)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("%v> waiting for %v pod targets to drain from %v ip target groups", instanceID, len(podIPs), len(targetGroups))
+	forEachConcurrently(ctx.MembershipCheckConcurrency, len(targetGroups), func(i int) {
+		arn := aws.StringValue(targetGroups[i].TargetGroupArn)
+		err := waitForIPTargetsDeregistered(event, elbv2Client, arn, podIPs, waiterConfig)
+		if err != nil {
+			log.Errorf("%v> pod targets failed to drain from target group %v: %v", instanceID, arn, err)
+			mutex.Lock()
+			errs = fmt.Errorf("pod targets failed to drain from target group %v: %v", arn, err)
+			mutex.Unlock()
+		}
+	})
+
+	if errs != nil {
+		return errs
+	}
+	log.Infof("%v> pod targets drained from ip target groups", instanceID)
+	return nil
+}
+
+func waitForIPTargetsDeregistered(event *LifecycleEvent, elbClient elbv2iface.ELBV2API, arn string, podIPs []string, config WaiterConfig) error {
+	var (
+		instanceID = event.EC2InstanceID
+		ips        = make(map[string]bool)
+	)
+
+	for _, ip := range podIPs {
+		ips[ip] = true
+	}
+
+	input := &elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: aws.String(arn),
+	}
+
+	for ieb, err := config.newBackoff(event.Context()); err == nil; err = ieb.Next() {
+
+		if event.isCompleted() {
+			return errors.New("event finished execution during pod target drain wait")
+		}
+
+		if err := event.contextError("pod target drain wait"); err != nil {
+			return err
+		}
+
+		// stop before the lifecycle hook expires rather than waiting past it
+		if remaining, ok := event.remainingTime(); ok && remaining <= config.maxDelay() {
+			return fmt.Errorf("lifecycle hook deadline in %v reached during pod target drain wait", remaining.Round(time.Second))
+		}
+
+		targets, err := elbClient.DescribeTargetHealthWithContext(event.Context(), input)
+		if err != nil {
+			return err
+		}
+
+		pending := 0
+		for _, desc := range targets.TargetHealthDescriptions {
+			if !ips[aws.StringValue(desc.Target.Id)] {
+				continue
+			}
+			if aws.StringValue(desc.TargetHealth.State) != elbv2.TargetHealthStateEnumUnused {
+				pending++
+			}
+		}
+		if pending == 0 {
+			return nil
+		}
+		log.Debugf("%v> %v pod targets pending deregistration from %v", instanceID, pending, arn)
+	}
+
+	return errors.New("wait for pod target deregister timed out")
+}
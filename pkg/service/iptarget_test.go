@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newIPTargetHealth(ip, state string) *elbv2.TargetHealthDescription {
+	return &elbv2.TargetHealthDescription{
+		Target:       &elbv2.TargetDescription{Id: aws.String(ip), Port: aws.Int64(8080)},
+		TargetHealth: &elbv2.TargetHealth{State: aws.String(state)},
+	}
+}
+
+func Test_GetNodePodIPs(t *testing.T) {
+	t.Log("Test_GetNodePodIPs: should return IPs of running pods on the node excluding host network pods")
+	kubeClient := fake.NewSimpleClientset(
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"},
+			Spec:       v1.PodSpec{NodeName: "node-1"},
+			Status:     v1.PodStatus{Phase: v1.PodRunning, PodIP: "10.0.1.1"},
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-2", Namespace: "default"},
+			Spec:       v1.PodSpec{NodeName: "node-1", HostNetwork: true},
+			Status:     v1.PodStatus{Phase: v1.PodRunning, PodIP: "10.0.0.1"},
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-3", Namespace: "default"},
+			Spec:       v1.PodSpec{NodeName: "node-1"},
+			Status:     v1.PodStatus{Phase: v1.PodSucceeded, PodIP: "10.0.1.3"},
+		},
+	)
+
+	ips, err := getNodePodIPs(context.Background(), kubeClient, "node-1")
+	if err != nil {
+		t.Fatalf("getNodePodIPs: expected error not to have occured, %v", err)
+	}
+
+	if len(ips) != 1 || ips[0] != "10.0.1.1" {
+		t.Fatalf("expected pod ips: %v, got: %v", []string{"10.0.1.1"}, ips)
+	}
+}
+
+func Test_WaitForIPTargetsDrained(t *testing.T) {
+	t.Log("Test_WaitForIPTargetsDrained: should check pod targets in ip target groups only")
+	elbv2Stubber := &stubELBv2{
+		targetGroups: []*elbv2.TargetGroup{
+			{TargetGroupArn: aws.String("arn:ip"), TargetType: aws.String(elbv2.TargetTypeEnumIp)},
+			{TargetGroupArn: aws.String("arn:instance"), TargetType: aws.String(elbv2.TargetTypeEnumInstance)},
+		},
+		targetHealthDescriptions: []*elbv2.TargetHealthDescription{
+			newIPTargetHealth("10.0.1.1", elbv2.TargetHealthStateEnumUnused),
+			newIPTargetHealth("10.0.2.1", elbv2.TargetHealthStateEnumHealthy),
+		},
+	}
+
+	auth := Authenticator{
+		ELBv2Client:      elbv2Stubber,
+		KubernetesClient: fake.NewSimpleClientset(),
+	}
+	ctx := _newBasicContext()
+	ctx.WithIPTargetWait = true
+	mgr := New(auth, ctx)
+
+	event := &LifecycleEvent{EC2InstanceID: "i-123456789012", startTime: time.Now()}
+	event.SetReferencedPodIPs([]string{"10.0.1.1"})
+
+	err := mgr.waitForIPTargetsDrained(event)
+	if err != nil {
+		t.Fatalf("waitForIPTargetsDrained: expected error not to have occured, %v", err)
+	}
+
+	if elbv2Stubber.timesCalledDescribeTargetHealth != 1 {
+		t.Fatalf("expected timesCalledDescribeTargetHealth: %v, got: %v", 1, elbv2Stubber.timesCalledDescribeTargetHealth)
+	}
+}
+
+func Test_WaitForIPTargetsDeregistered(t *testing.T) {
+	t.Log("Test_WaitForIPTargetsDeregistered: should wait while pod targets are draining")
+	elbv2Stubber := &stubELBv2{
+		targetHealthResponses: [][]*elbv2.TargetHealthDescription{
+			{
+				newIPTargetHealth("10.0.1.1", elbv2.TargetHealthStateEnumDraining),
+				newIPTargetHealth("10.0.2.1", elbv2.TargetHealthStateEnumHealthy),
+			},
+		},
+		targetHealthDescriptions: []*elbv2.TargetHealthDescription{
+			newIPTargetHealth("10.0.2.1", elbv2.TargetHealthStateEnumHealthy),
+		},
+	}
+
+	event := &LifecycleEvent{EC2InstanceID: "i-123456789012", startTime: time.Now()}
+	config := WaiterConfig{MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	err := waitForIPTargetsDeregistered(event, elbv2Stubber, "arn:ip", []string{"10.0.1.1"}, config)
+	if err != nil {
+		t.Fatalf("waitForIPTargetsDeregistered: expected error not to have occured, %v", err)
+	}
+
+	if elbv2Stubber.timesCalledDescribeTargetHealth != 2 {
+		t.Fatalf("expected timesCalledDescribeTargetHealth: %v, got: %v", 2, elbv2Stubber.timesCalledDescribeTargetHealth)
+	}
+}
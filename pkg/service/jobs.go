@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	// JobCompletionCheckInterval defines the interval at which the job pods of a terminating node are checked for
+	// completion
+	JobCompletionCheckInterval = 10 * time.Second
+)
+
+// isJobPod returns true if the pod is owned by a Job, which is the case of the pods of CronJobs as well
+func isJobPod(pod v1.Pod) bool {
+	for _, owner := range pod.GetOwnerReferences() {
+		if owner.Kind == "Job" {
+			return true
+		}
+	}
+	return false
+}
+
+// getRunningJobPods returns the namespace/name of the pods owned by Jobs which are still running on a node
+func getRunningJobPods(ctx context.Context, kubeClient kubernetes.Interface, nodeName string) ([]string, error) {
+	running := []string{}
+	selector := fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+	pods, err := kubeClient.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return running, err
+	}
+
+	for _, pod := range pods.Items {
+		if !isJobPod(pod) || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		running = append(running, pod.Namespace+"/"+pod.Name)
+	}
+	return running, nil
+}
+
+// awaitJobCompletion cordons the event's node and waits for the pods of Jobs running on it to complete before they are
+// evicted, up to --job-completion-timeout, so that batch workloads are not killed mid-run by scale-in. The lifecycle
+// hook is kept alive by heartbeats meanwhile, an error is returned if the jobs did not complete in time
+func (mgr *Manager) awaitJobCompletion(event *LifecycleEvent) error {
+	var (
+		kubeClient = mgr.kubeClient(event)
+		nodeName   = event.referencedNode.Name
+		timeout    = time.Duration(mgr.context.JobCompletionTimeoutSeconds) * time.Second
+	)
+
+	if timeout <= 0 || event.settings.CordonOnly {
+		return nil
+	}
+
+	pods, err := getRunningJobPods(event.Context(), kubeClient, nodeName)
+	if err != nil {
+		return err
+	}
+	if len(pods) == 0 {
+		return nil
+	}
+
+	// no new pods are scheduled on the node while its jobs complete
+	if err := cordonNode(event.Context(), kubeClient, &event.referencedNode); err != nil {
+		return err
+	}
+
+	log.Infof("%v> waiting up to %v for job pods %v of node/%v to complete", event.EC2InstanceID, timeout, pods, nodeName)
+	msg := fmt.Sprintf(EventMessageJobCompletionWait, len(pods), nodeName, timeout)
+	mgr.publishEvent(event, EventReasonJobCompletionWait, getMessageFields(event, msg))
+
+	deadline := time.Now().Add(timeout)
+	for len(pods) > 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("job pods %v did not complete within %v", pods, timeout)
+		}
+		select {
+		case <-event.Context().Done():
+			return event.contextError("job completion wait")
+		case <-time.After(JobCompletionCheckInterval):
+		}
+
+		pods, err = getRunningJobPods(event.Context(), kubeClient, nodeName)
+		if err != nil {
+			return err
+		}
+	}
+	log.Infof("%v> job pods of node/%v completed", event.EC2InstanceID, nodeName)
+	return nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_AwaitJobCompletion(t *testing.T) {
+	t.Log("Test_AwaitJobCompletion: should cordon the node and wait for its job pods to complete")
+	interval := JobCompletionCheckInterval
+	JobCompletionCheckInterval = 10 * time.Millisecond
+	defer func() { JobCompletionCheckInterval = interval }()
+
+	kubeClient := fake.NewSimpleClientset()
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+	pods := []*v1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "report-28000000-abcde",
+				Namespace:       "batch",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Job", Name: "report-28000000"}},
+			},
+			Spec:   v1.PodSpec{NodeName: "node-1"},
+			Status: v1.PodStatus{Phase: v1.PodRunning},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "web-abcde",
+				Namespace:       "default",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web"}},
+			},
+			Spec:   v1.PodSpec{NodeName: "node-1"},
+			Status: v1.PodStatus{Phase: v1.PodRunning},
+		},
+	}
+	for _, pod := range pods {
+		kubeClient.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	}
+
+	ctx := _newBasicContext()
+	ctx.JobCompletionTimeoutSeconds = 60
+	mgr := New(Authenticator{KubernetesClient: kubeClient}, ctx)
+	event := &LifecycleEvent{EC2InstanceID: "i-123486890234"}
+	event.SetContext(context.WithCancel(context.Background()))
+	event.SetReferencedNode(*node)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		completed := pods[0].DeepCopy()
+		completed.Status.Phase = v1.PodSucceeded
+		kubeClient.CoreV1().Pods("batch").UpdateStatus(context.Background(), completed, metav1.UpdateOptions{})
+	}()
+
+	if err := mgr.awaitJobCompletion(event); err != nil {
+		t.Fatalf("expected error not to have occured, %v", err)
+	}
+
+	cordoned, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if !cordoned.Spec.Unschedulable {
+		t.Fatal("expected node to be cordoned")
+	}
+
+	// job pods which do not complete in time are left to the drain
+	kubeClient.CoreV1().Pods("batch").Update(context.Background(), pods[0], metav1.UpdateOptions{})
+	mgr.context.JobCompletionTimeoutSeconds = 1
+	if err := mgr.awaitJobCompletion(event); err == nil {
+		t.Fatal("expected error for job pods which did not complete in time")
+	}
+}
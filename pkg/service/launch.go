@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var (
+	// LaunchEventName is the event name of a launching lifecycle hook
+	LaunchEventName = "autoscaling:EC2_INSTANCE_LAUNCHING"
+	// LaunchPollInterval defines the interval at which a launching node's readiness is checked
+	LaunchPollInterval = 10 * time.Second
+	// LaunchCommandWaitDelay is the time the output of a readiness command is waited for once it is killed
+	LaunchCommandWaitDelay = 5 * time.Second
+)
+
+func (mgr *Manager) handleLaunchEvent(event *LifecycleEvent) error {
+	var (
+		ctx        = &mgr.context
+		metrics    = mgr.metrics
+		instanceID = event.EC2InstanceID
+		timeout    = time.Duration(ctx.LaunchTimeoutSeconds) * time.Second
+	)
+
+	// send heartbeat at intervals
+	go mgr.startHeartbeat(event)
+
+	metrics.IncGauge(LaunchingInstancesCountMetric, eventLabels(event))
+	defer metrics.DecGauge(LaunchingInstancesCountMetric, eventLabels(event))
+
+	log.Infof("%v> waiting for node to become ready", instanceID)
+	node, err := mgr.waitForNodeReady(event, timeout)
+	if err != nil {
+		metrics.AddCounter(FailedNodeLaunchTotalMetric, eventLabels(event), 1)
+		failMsg := fmt.Sprintf(EventMessageNodeLaunchFailed, instanceID, err)
+		mgr.publishEvent(event, EventReasonNodeLaunchFailed, getMessageFields(event, failMsg))
+		return err
+	}
+	event.SetReferencedNode(node)
+	log.Infof("%v> node/%v is ready", instanceID, node.Name)
+	metrics.AddCounter(SuccessfulNodeLaunchTotalMetric, eventLabels(event), 1)
+
+	successMsg := fmt.Sprintf(EventMessageNodeLaunchSucceeded, node.Name, instanceID)
+	mgr.publishEvent(event, EventReasonNodeLaunchSucceeded, getMessageFields(event, successMsg))
+	return nil
+}
+
+// waitForNodeReady waits until the event's instance is registered as a node which passes all readiness gates
+func (mgr *Manager) waitForNodeReady(event *LifecycleEvent, timeout time.Duration) (v1.Node, error) {
+	var (
+		kubeClient = mgr.kubeClient(event)
+		instanceID = event.EC2InstanceID
+		deadline   = time.Now().Add(timeout)
+	)
+
+	// readiness commands are bound to the launch deadline so that a hung command cannot hold the worker past it
+	ctx, cancel := context.WithDeadline(event.Context(), deadline)
+	defer cancel()
+
+	for {
+		if event.isCompleted() {
+			return v1.Node{}, errors.New("event finished execution while waiting for node readiness")
+		}
+
+		node, exists := findNodeByInstance(kubeClient, mgr.authenticator.EC2Client, instanceID)
+		if !exists {
+			log.Debugf("%v> node is not registered yet", instanceID)
+		} else {
+			ready, err := mgr.isNodeLaunchReady(ctx, node)
+			if err != nil {
+				log.Debugf("%v> node/%v is not ready: %v", instanceID, node.Name, err)
+			} else if ready {
+				return node, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return v1.Node{}, errors.Errorf("node did not become ready within %v", timeout)
+		}
+		select {
+		case <-event.Context().Done():
+			return v1.Node{}, event.contextError("node readiness wait")
+		case <-time.After(LaunchPollInterval):
+		}
+	}
+}
+
+// isNodeLaunchReady checks the node's Ready condition followed by the configured readiness gates
+func (mgr *Manager) isNodeLaunchReady(ctx context.Context, node v1.Node) (bool, error) {
+	if !isNodeStatusInCondition(node, v1.ConditionTrue) {
+		return false, nil
+	}
+
+	if mgr.context.LaunchReadinessSelector != "" {
+		selector, err := labels.Parse(mgr.context.LaunchReadinessSelector)
+		if err != nil {
+			return false, errors.Wrap(err, "invalid readiness selector")
+		}
+		if !selector.Matches(labels.Set(node.GetLabels())) {
+			return false, nil
+		}
+	}
+
+	if mgr.context.LaunchReadinessCommand != "" {
+		// a failing command means the node is not ready yet, its output is logged by the caller at debug level
+		cmd := exec.CommandContext(ctx, mgr.context.LaunchReadinessCommand, node.Name)
+		// children of a killed command may keep its output open, they are not waited for
+		cmd.WaitDelay = LaunchCommandWaitDelay
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return false, errors.Wrapf(err, "readiness command failed with output: %s", out)
+		}
+	}
+
+	return true, nil
+}
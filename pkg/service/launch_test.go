@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	v1 "k8s.io/api/core/v1"
+	apimachinery_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func _newLaunchingNode(name, providerID string, status v1.ConditionStatus, nodeLabels map[string]string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: apimachinery_v1.ObjectMeta{
+			Name:   name,
+			Labels: nodeLabels,
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: providerID,
+		},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{
+				{
+					Type:   v1.NodeReady,
+					Status: status,
+				},
+			},
+		},
+	}
+}
+
+func Test_ProcessLaunchEvent(t *testing.T) {
+	t.Log("Test_ProcessLaunchEvent: should complete a launch event once the node is ready")
+	asgStubber := &stubAutoscaling{
+		lifecycleHooks: []*autoscaling.LifecycleHook{
+			{
+				AutoScalingGroupName: aws.String("my-asg"),
+				HeartbeatTimeout:     aws.Int64(60),
+			},
+		},
+	}
+	sqsStubber := &stubSQS{}
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		SQSClient:          sqsStubber,
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	ctx := _newBasicContext()
+	ctx.WithLaunchHooks = true
+	ctx.LaunchTimeoutSeconds = 5
+	ctx.LaunchReadinessSelector = "node-role=worker"
+
+	node := _newLaunchingNode("node-1", "aws:///us-west-2a/i-123486890234", v1.ConditionTrue, map[string]string{"node-role": "worker"})
+	auth.KubernetesClient.CoreV1().Nodes().Create(context.Background(), node, apimachinery_v1.CreateOptions{})
+
+	event := &LifecycleEvent{
+		LifecycleHookName:    "my-hook",
+		RequestID:            "63f5b5c2-58b3-0574-b7d5-b3162d0268f0",
+		LifecycleTransition:  LaunchEventName,
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-123486890234",
+		LifecycleActionToken: "cc34960c-1e41-4703-a665-bdb3e5b81ad3",
+	}
+
+	mgr := New(auth, ctx)
+	if err := mgr.validateEvent(event); err != nil {
+		t.Fatalf("validateEvent: expected error not to have occured, %v", err)
+	}
+
+	mgr.Process(event)
+
+	if mgr.completedEvents != 1 {
+		t.Fatalf("expected completed events: %v, got: %v", 1, mgr.completedEvents)
+	}
+
+	if event.referencedNode.Name != "node-1" {
+		t.Fatalf("expected referenced node: %v, got: %v", "node-1", event.referencedNode.Name)
+	}
+}
+
+func Test_ProcessLaunchEventNotReady(t *testing.T) {
+	t.Log("Test_ProcessLaunchEventNotReady: should fail a launch event if the node does not pass readiness gates")
+	asgStubber := &stubAutoscaling{}
+	sqsStubber := &stubSQS{}
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		SQSClient:          sqsStubber,
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	ctx := _newBasicContext()
+	ctx.WithLaunchHooks = true
+	ctx.LaunchTimeoutSeconds = 1
+	ctx.LaunchReadinessSelector = "node-role=worker"
+
+	node := _newLaunchingNode("node-1", "aws:///us-west-2a/i-123486890234", v1.ConditionTrue, map[string]string{"node-role": "ingress"})
+	auth.KubernetesClient.CoreV1().Nodes().Create(context.Background(), node, apimachinery_v1.CreateOptions{})
+
+	event := &LifecycleEvent{
+		LifecycleHookName:    "my-hook",
+		RequestID:            "63f5b5c2-58b3-0574-b7d5-b3162d0268f0",
+		LifecycleTransition:  LaunchEventName,
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-123486890234",
+		LifecycleActionToken: "cc34960c-1e41-4703-a665-bdb3e5b81ad3",
+		heartbeatInterval:    60,
+	}
+
+	mgr := New(auth, ctx)
+	mgr.Process(event)
+
+	if mgr.failedEvents != 1 {
+		t.Fatalf("expected failed events: %v, got: %v", 1, mgr.failedEvents)
+	}
+
+	if asgStubber.timesCalledCompleteLifecycleAction != 1 {
+		t.Fatalf("expected timesCalledCompleteLifecycleAction: %v, got: %v", 1, asgStubber.timesCalledCompleteLifecycleAction)
+	}
+}
+
+func Test_ValidateLaunchEventDisabled(t *testing.T) {
+	t.Log("Test_ValidateLaunchEventDisabled: should reject launch events unless enabled")
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	mgr := New(auth, _newBasicContext())
+	event := &LifecycleEvent{
+		LifecycleHookName:   "my-hook",
+		LifecycleTransition: LaunchEventName,
+		EC2InstanceID:       "i-123486890234",
+	}
+
+	if err := mgr.validateEvent(event); err == nil {
+		t.Fatal("validateEvent: expected error to have occured")
+	}
+}
+
+func Test_WaitForNodeReadyCommandTimeout(t *testing.T) {
+	t.Log("Test_WaitForNodeReadyCommandTimeout: should stop a hung readiness command once the launch timeout passes")
+	command := filepath.Join(t.TempDir(), "ready.sh")
+	if err := os.WriteFile(command, []byte("#!/bin/sh\nexec sleep 30\n"), 0755); err != nil {
+		t.Fatalf("failed to write readiness command: %v", err)
+	}
+
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	ctx := _newBasicContext()
+	ctx.LaunchReadinessCommand = command
+
+	node := _newLaunchingNode("node-1", "aws:///us-west-2a/i-123486890234", v1.ConditionTrue, nil)
+	auth.KubernetesClient.CoreV1().Nodes().Create(context.Background(), node, apimachinery_v1.CreateOptions{})
+
+	event := &LifecycleEvent{
+		LifecycleTransition: LaunchEventName,
+		EC2InstanceID:       "i-123486890234",
+	}
+
+	mgr := New(auth, ctx)
+	start := time.Now()
+	if _, err := mgr.waitForNodeReady(event, time.Second); err == nil {
+		t.Fatal("waitForNodeReady: expected error to have occured")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("waitForNodeReady: expected to return after the launch timeout, returned after %v", elapsed)
+	}
+}
+
+func Test_WaitForNodeReadyCancelled(t *testing.T) {
+	t.Log("Test_WaitForNodeReadyCancelled: should stop waiting for node readiness once the event is cancelled")
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	mgr := New(auth, _newBasicContext())
+
+	event := &LifecycleEvent{
+		LifecycleTransition: LaunchEventName,
+		EC2InstanceID:       "i-123486890234",
+	}
+	event.SetContext(context.WithCancel(mgr.ctx))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := mgr.waitForNodeReady(event, time.Minute)
+		done <- err
+	}()
+	mgr.Stop()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("waitForNodeReady: expected error to have occured")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForNodeReady: expected to return once the service stopped")
+	}
+}
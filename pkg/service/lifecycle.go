@@ -1,10 +1,22 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/sqs"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	// HookMaxHeartbeats is the number of heartbeat timeouts after which a lifecycle hook expires
+	HookMaxHeartbeats int64 = 100
+	// HookMaxTimeout is the maximum time an instance can remain in a lifecycle hook wait state
+	HookMaxTimeout = 48 * time.Hour
 )
 
 type LifecycleEvent struct {
@@ -15,16 +27,66 @@ type LifecycleEvent struct {
 	AutoScalingGroupName string `json:"AutoScalingGroupName"`
 	EC2InstanceID        string `json:"EC2InstanceId"`
 	LifecycleActionToken string `json:"LifecycleActionToken"`
+	NotificationMetadata string `json:"NotificationMetadata,omitempty"`
+	Origin               string `json:"Origin,omitempty"`
+	Destination          string `json:"Destination,omitempty"`
 	receiptHandle        string
 	queueURL             string
 	heartbeatInterval    int64
 	referencedNode       v1.Node
-	drainCompleted       bool
+	referencedPodIPs     []string
+	instanceTags         map[string]string
 	nodeDeleted          bool
-	deregisterCompleted  bool
-	eventCompleted       bool
+	phase                EventPhase
+	phases               []EventPhase
+	failedPhases         []EventPhase
 	startTime            time.Time
+	validatedTime        time.Time
+	drainStartTime       time.Time
+	deregisterStartTime  time.Time
+	lastHeartbeat        int64
+	drainDuration        time.Duration
+	deregisterDuration   time.Duration
 	message              *sqs.Message
+	settings             EventSettings
+	policyDecision       *PolicyDecision
+	drainLimiter         *drainLimiter
+	tokenClaimed         bool
+	instanceRefreshID    string
+	snsEnvelope          *SNSEnvelope
+	kubeClient           kubernetes.Interface
+	ctx                  context.Context
+	cancel               context.CancelFunc
+	// mu guards the phases and the drain and deregistration timing of the event, which are read by its
+	// heartbeats and written by its drain and deregistration running concurrently with --deregister-during-drain
+	mu sync.Mutex
+}
+
+// Context returns the context of the event, it is cancelled once the event completes or fails
+func (e *LifecycleEvent) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// contextError returns why the context of the event is done while waiting on an operation, nil is returned while
+// the context is not done
+func (e *LifecycleEvent) contextError(operation string) error {
+	switch e.Context().Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return fmt.Errorf("event exceeded max time to process during %v", operation)
+	default:
+		return fmt.Errorf("event was cancelled during %v", operation)
+	}
+}
+
+// SetContext is a setter method for the context of the event and its cancel function
+func (e *LifecycleEvent) SetContext(ctx context.Context, cancel context.CancelFunc) {
+	e.ctx = ctx
+	e.cancel = cancel
 }
 
 // SetMessage is a setter method for the sqs message body
@@ -42,17 +104,93 @@ func (e *LifecycleEvent) SetHeartbeatInterval(interval int64) { e.heartbeatInter
 // SetReferencedNode is a setter method for the event referenced node
 func (e *LifecycleEvent) SetReferencedNode(node v1.Node) { e.referencedNode = node }
 
-// SetDrainCompleted is a setter method for status of the drain operation
-func (e *LifecycleEvent) SetDrainCompleted(val bool) { e.drainCompleted = val }
+// SetReferencedPodIPs is a setter method for the IPs of the pods running on the event referenced node
+func (e *LifecycleEvent) SetReferencedPodIPs(ips []string) { e.referencedPodIPs = ips }
 
 // SetNodeDeleted is a setter method for status of the node deletion operation
 func (e *LifecycleEvent) SetNodeDeleted(val bool) { e.nodeDeleted = val }
 
-// SetDeregisterCompleted is a setter method for status of the drain operation
-func (e *LifecycleEvent) SetDeregisterCompleted(val bool) { e.deregisterCompleted = val }
-
-// SetEventCompleted is a setter method for status of the drain operation
-func (e *LifecycleEvent) SetEventCompleted(val bool) { e.eventCompleted = val }
-
 // SetEventTimeStarted is a setter method for the time an event started
 func (e *LifecycleEvent) SetEventTimeStarted(t time.Time) { e.startTime = t }
+
+// SetSettings is a setter method for the resolved processing settings of the event
+func (e *LifecycleEvent) SetSettings(settings EventSettings) { e.settings = settings }
+
+// eventTiming is when the drain and deregistration of an event started and how long they took
+type eventTiming struct {
+	drainStartTime      time.Time
+	drainDuration       time.Duration
+	deregisterStartTime time.Time
+	deregisterDuration  time.Duration
+}
+
+// timing returns when the drain and deregistration of the event started and how long they took
+func (e *LifecycleEvent) timing() eventTiming {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return eventTiming{
+		drainStartTime:      e.drainStartTime,
+		drainDuration:       e.drainDuration,
+		deregisterStartTime: e.deregisterStartTime,
+		deregisterDuration:  e.deregisterDuration,
+	}
+}
+
+// setDrainTiming records when the drain of the event's node started and how long it took, zero while it runs
+func (e *LifecycleEvent) setDrainTiming(start time.Time, duration time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.drainStartTime, e.drainDuration = start, duration
+}
+
+// setDeregisterTiming records when the deregistration of the event's instance started and how long it took, zero
+// while it runs
+func (e *LifecycleEvent) setDeregisterTiming(start time.Time, duration time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.deregisterStartTime, e.deregisterDuration = start, duration
+}
+
+// setLastHeartbeat records the time of the last successful heartbeat, it is read concurrently to export its age
+func (e *LifecycleEvent) setLastHeartbeat(t time.Time) {
+	atomic.StoreInt64(&e.lastHeartbeat, t.UnixNano())
+}
+
+// heartbeatAge returns the time since the last successful heartbeat, or since the event started when no heartbeat
+// succeeded yet, false is returned if neither is known
+func (e *LifecycleEvent) heartbeatAge() (time.Duration, bool) {
+	if last := atomic.LoadInt64(&e.lastHeartbeat); last != 0 {
+		return time.Since(time.Unix(0, last)), true
+	}
+	if e.startTime.IsZero() {
+		return 0, false
+	}
+	return time.Since(e.startTime), true
+}
+
+// deadline returns the earliest of the lifecycle hook expiry and the event context deadline,
+// false is returned if neither can be determined
+func (e *LifecycleEvent) deadline() (time.Time, bool) {
+	deadline, ok := e.Context().Deadline()
+
+	if !e.startTime.IsZero() && e.heartbeatInterval > 0 {
+		hookTimeout := time.Duration(e.heartbeatInterval*HookMaxHeartbeats) * time.Second
+		if hookTimeout > HookMaxTimeout {
+			hookTimeout = HookMaxTimeout
+		}
+		hookDeadline := e.startTime.Add(hookTimeout)
+		if !ok || hookDeadline.Before(deadline) {
+			deadline, ok = hookDeadline, true
+		}
+	}
+	return deadline, ok
+}
+
+// remainingTime returns the time left until the event deadline, false is returned if there is no deadline
+func (e *LifecycleEvent) remainingTime() (time.Duration, bool) {
+	deadline, ok := e.deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_EventDeadline(t *testing.T) {
+	t.Log("Test_EventDeadline: should return the earliest of the hook expiry and the context deadline")
+	start := time.Now()
+	event := &LifecycleEvent{
+		heartbeatInterval: 60,
+		startTime:         start,
+	}
+
+	deadline, ok := event.deadline()
+	expected := start.Add(100 * time.Minute)
+	if !ok || !deadline.Equal(expected) {
+		t.Fatalf("expected deadline: %v, got: %v", expected, deadline)
+	}
+
+	event.SetContext(context.WithDeadline(context.Background(), start.Add(time.Hour)))
+	deadline, ok = event.deadline()
+	expected = start.Add(time.Hour)
+	if !ok || !deadline.Equal(expected) {
+		t.Fatalf("expected deadline: %v, got: %v", expected, deadline)
+	}
+
+	event = &LifecycleEvent{
+		heartbeatInterval: 7200,
+		startTime:         start,
+	}
+	deadline, _ = event.deadline()
+	expected = start.Add(HookMaxTimeout)
+	if !deadline.Equal(expected) {
+		t.Fatalf("expected deadline: %v, got: %v", expected, deadline)
+	}
+
+	if _, ok := (&LifecycleEvent{}).deadline(); ok {
+		t.Fatal("expected no deadline for an event without a start time or context")
+	}
+}
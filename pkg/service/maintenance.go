@@ -0,0 +1,76 @@
+package service
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+var (
+	// DefaultMaintenanceDrainLead is the default time ahead of a scheduled maintenance window to drain affected nodes at
+	DefaultMaintenanceDrainLead = time.Hour
+	// MaxMessageVisibility is the maximum visibility timeout of an SQS message, notices of maintenance scheduled later
+	// are deferred again once it passes
+	MaxMessageVisibility = 12 * time.Hour
+
+	// maintenanceTerminationCodes are the scheduled changes stopping or retiring an instance, rather than rebooting it
+	maintenanceTerminationCodes = map[string]bool{
+		"AWS_EC2_INSTANCE_STOP_SCHEDULED":        true,
+		"AWS_EC2_INSTANCE_RETIREMENT_SCHEDULED":  true,
+		"AWS_EC2_INSTANCE_TERMINATION_SCHEDULED": true,
+	}
+)
+
+// parseMaintenanceTime parses the start time of a scheduled change, AWS Health events use RFC 1123 times
+func parseMaintenanceTime(value string) time.Time {
+	for _, layout := range []string{time.RFC1123, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// maintenanceDrainLead returns the time ahead of a scheduled maintenance window to drain affected nodes at
+func (mgr *Manager) maintenanceDrainLead() time.Duration {
+	if lead := mgr.context.MaintenanceDrainLeadSeconds; lead > 0 {
+		return time.Duration(lead) * time.Second
+	}
+	return DefaultMaintenanceDrainLead
+}
+
+// deferMaintenanceNotice returns the notice of a scheduled change to the queue until its window is within the drain
+// lead, false is returned if its nodes should be drained now
+func (mgr *Manager) deferMaintenanceNotice(notice *InterruptionNotice, message *sqs.Message, queueURL string) bool {
+	if notice.Kind != InterruptionKindScheduledChange || notice.StartTime.IsZero() {
+		return false
+	}
+
+	delay := time.Until(notice.StartTime) - mgr.maintenanceDrainLead()
+	if delay <= 0 {
+		return false
+	}
+	if delay > MaxMessageVisibility {
+		delay = MaxMessageVisibility
+	}
+
+	receipt := aws.StringValue(message.ReceiptHandle)
+	timeout := int64(delay.Round(time.Second).Seconds())
+	if err := changeMessageVisibility(mgr.authenticator.SQSClient, queueURL, receipt, timeout); err != nil {
+		log.Errorf("failed to defer %v notice %v, draining now: %v", notice.EventTypeCode, notice.ID, err)
+		return false
+	}
+	log.Infof("deferring %v notice %v for instances %v by %v, maintenance starts at %v", notice.EventTypeCode, notice.ID, notice.InstanceIDs, delay, notice.StartTime)
+	return true
+}
+
+// terminatesInstance returns true if the instance of a scaling group affected by a notice is terminated through its
+// scaling group once drained, so that it is replaced ahead of a maintenance window stopping or retiring it
+func (mgr *Manager) terminatesInstance(notice *InterruptionNotice, event *LifecycleEvent) bool {
+	return mgr.context.MaintenanceTerminate &&
+		notice.Kind == InterruptionKindScheduledChange &&
+		maintenanceTerminationCodes[notice.EventTypeCode] &&
+		event.AutoScalingGroupName != ""
+}
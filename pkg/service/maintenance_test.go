@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func _newMaintenanceMessage(code string, start time.Time) *sqs.Message {
+	body := fmt.Sprintf(`{"id":"1","detail-type":"AWS Health Event","source":"aws.health","account":"12345689012","detail":{"service":"EC2","eventTypeCategory":"scheduledChange","eventTypeCode":"%v","startTime":"%v","affectedEntities":[{"entityValue":"i-123486890234"}]}}`, code, start.UTC().Format(time.RFC1123))
+	return &sqs.Message{Body: aws.String(body), ReceiptHandle: aws.String("receipt-1")}
+}
+
+func Test_DeferMaintenanceNotice(t *testing.T) {
+	t.Log("Test_DeferMaintenanceNotice: should return notices to the queue until their window is within the drain lead")
+	tests := []struct {
+		start    time.Duration
+		deferred bool
+		timeout  int64
+	}{
+		{start: 3 * time.Hour, deferred: true, timeout: 7200},
+		{start: 72 * time.Hour, deferred: true, timeout: 43200},
+		{start: 30 * time.Minute, deferred: false},
+		{start: -time.Hour, deferred: false},
+	}
+
+	for _, tc := range tests {
+		sqsStubber := &stubSQS{}
+		mgr := New(Authenticator{SQSClient: sqsStubber}, _newBasicContext())
+		message := _newMaintenanceMessage("AWS_EC2_INSTANCE_STOP_SCHEDULED", time.Now().Add(tc.start))
+
+		notice := readInterruptionNotice(message)
+		if notice == nil || notice.StartTime.IsZero() {
+			t.Fatalf("expected notice with a start time, got: %+v", notice)
+		}
+		if got := mgr.deferMaintenanceNotice(notice, message, "some-queue"); got != tc.deferred {
+			t.Fatalf("expected notice starting in %v deferred: %v, got: %v", tc.start, tc.deferred, got)
+		}
+		// the start time is rounded to the second
+		if tc.deferred && (sqsStubber.lastVisibilityTimeout > tc.timeout || sqsStubber.lastVisibilityTimeout < tc.timeout-2) {
+			t.Fatalf("expected visibility timeout: %v, got: %v", tc.timeout, sqsStubber.lastVisibilityTimeout)
+		}
+	}
+}
+
+func Test_HandleMaintenanceNotice(t *testing.T) {
+	t.Log("Test_HandleMaintenanceNotice: should drain and replace instances of scaling groups retired by scheduled maintenance")
+	asgStubber := &stubAutoscaling{
+		autoScalingInstances: []*autoscaling.InstanceDetails{
+			{AutoScalingGroupName: aws.String("my-asg"), InstanceId: aws.String("i-123486890234")},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset()
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		KubernetesClient:   kubeClient,
+		SQSClient:          &stubSQS{},
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-123486890234"},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+	ctx := _newBasicContext()
+	ctx.MaintenanceTerminate = true
+	mgr := New(auth, ctx)
+
+	notice := readInterruptionNotice(_newMaintenanceMessage("AWS_EC2_INSTANCE_RETIREMENT_SCHEDULED", time.Now().Add(time.Minute)))
+	mgr.drainInterruptedInstance(notice, "i-123486890234")
+
+	drained, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if !drained.Spec.Unschedulable {
+		t.Fatal("expected node to be cordoned")
+	}
+	if len(asgStubber.terminatedInstances) != 1 || aws.BoolValue(asgStubber.terminatedInstances[0].ShouldDecrementDesiredCapacity) {
+		t.Fatalf("expected termination of i-123486890234 keeping capacity, got: %v", asgStubber.terminatedInstances)
+	}
+
+	notice = readInterruptionNotice(_newMaintenanceMessage("AWS_EC2_INSTANCE_REBOOT_MAINTENANCE_SCHEDULED", time.Now().Add(time.Minute)))
+	mgr.drainInterruptedInstance(notice, "i-123486890234")
+	if len(asgStubber.terminatedInstances) != 1 {
+		t.Fatalf("expected rebooted instances not to be terminated, got: %v", asgStubber.terminatedInstances)
+	}
+}
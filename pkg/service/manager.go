@@ -1,36 +1,66 @@
 package service
 
 import (
+	"context"
 	"fmt"
-	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/semaphore"
 
 	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/elb/elbiface"
 	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/globalaccelerator/globalacceleratoriface"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/aws/aws-sdk-go/service/servicediscovery/servicediscoveryiface"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
 
-	"github.com/keikoproj/aws-sdk-go-cache/cache"
-
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
 // Manager is the main object for lifecycle-manager and holds the state
 type Manager struct {
-	eventStream      chan *sqs.Message
-	authenticator    Authenticator
-	context          ManagerContext
+	eventStream   chan *receivedMessage
+	dispatchQueue chan *LifecycleEvent
+	authenticator Authenticator
+	context       ManagerContext
+	// ctx is the root context of the service, events derive their context from it and it is cancelled on shutdown
+	ctx              context.Context
+	stop             context.CancelFunc
 	deregistrationMu sync.Mutex
+	intakeMu         sync.Mutex
+	batchMu          sync.Mutex
+	pendingBatch     *deregistrationBatch
+	drainLimitersMu  sync.Mutex
+	drainLimiters    map[string]*drainLimiter
+	drainQueue       *DrainQueue
+	dedupStore       DedupStore
+	shards           *ShardRing
+	eventSink        EventSink
+	history          *EventHistory
+	failureRate      *failureRateTracker
+	queuesMu         sync.Mutex
+	queues           map[string]*consumedQueue
+	auditLog         AuditLog
 	sync.Mutex
-	workQueue       []*LifecycleEvent
+	workQueue       map[string]*LifecycleEvent
+	instanceIndex   map[string]string
+	inFlightEvents  int64
+	intakeEvents    int64
+	selfTerminating int32
 	targets         *sync.Map
+	membership      *MembershipCache
 	metrics         *MetricsServer
-	avarageLatency  float64
 	completedEvents int
 	rejectedEvents  int
 	failedEvents    int
@@ -38,28 +68,136 @@ type Manager struct {
 
 // ManagerContext contain the user input parameters on the current context
 type ManagerContext struct {
-	CacheConfig                *cache.Config
-	KubectlLocalPath           string
-	QueueName                  string
-	Region                     string
-	DrainTimeoutUnknownSeconds int64
-	DrainTimeoutSeconds        int64
-	DrainRetryIntervalSeconds  int64
-	DrainRetryAttempts         uint
-	PollingIntervalSeconds     int64
-	WithDeregister             bool
-	DeregisterTargetTypes      []string
-	MaxDrainConcurrency        *semaphore.Weighted
-	MaxTimeToProcessSeconds    int64
+	CacheConfig                        ResponseCache
+	QueueName                          string
+	QueueURL                           string
+	QueueOwnerAccountID                string
+	QueueDiscoveryTags                 map[string]string
+	QueueDiscoveryIntervalSeconds      int64
+	ClusterName                        string
+	Region                             string
+	DrainTimeoutUnknownSeconds         int64
+	DrainTimeoutSeconds                int64
+	DrainRetryIntervalSeconds          int64
+	DrainRetryAttempts                 uint
+	DrainFailurePolicy                 FailurePolicy
+	DrainGracePeriodSeconds            int64
+	DrainEscalationTimeoutSeconds      int64
+	DrainEscalationGracePeriodSeconds  int64
+	DrainExcludeNamespaces             []string
+	JobCompletionTimeoutSeconds        int64
+	EvictionOrder                      string
+	WithVolumeDetachWait               bool
+	WithPodDeletionWait                bool
+	PodDeletionTimeoutSeconds          int64
+	PodDeletionNamespaceTimeouts       map[string]int64
+	WithNodeCondition                  bool
+	DeleteNodeAfterComplete            bool
+	WaitForReplacement                 bool
+	ReplacementWaitTimeoutSeconds      int64
+	PollingIntervalSeconds             int64
+	WithDeregister                     bool
+	DeregisterTargetTypes              []string
+	DeregisterFailurePolicy            FailurePolicy
+	DeregisterFullScanFallback         bool
+	DeregisterTagFilters               map[string]string
+	DeregisterBatchWindowSeconds       int64
+	WithIPTargetWait                   bool
+	DeregisterBeforeDrain              bool
+	DeregisterDuringDrain              bool
+	MembershipCacheTTLSeconds          int64
+	MembershipCheckConcurrency         int
+	NodeAgeCacheFlushMinutes           int
+	Route53ZoneIDs                     []string
+	Route53ZoneTagFilters              map[string]string
+	CloudMapNamespaceTagFilters        map[string]string
+	CloudMapServiceTagFilters          map[string]string
+	AcceleratorTagFilters              map[string]string
+	AcceleratorDialDownSeconds         int64
+	ScalingGroupMaxDrainConcurrency    map[string]int64
+	InstanceRefreshMaxDrainConcurrency int64
+	MaxDrainConcurrency                *semaphore.Weighted
+	MaxTimeToProcessSeconds            int64
+	BackoffJitter                      float64
+	WaiterMinDelaySeconds              int64
+	WaiterMaxDelaySeconds              int64
+	WaiterMaxAttempts                  uint32
+	WaiterDelayIntervalSeconds         int64
+	LBDiscoveryTimeoutSeconds          int64
+	DeregisterTimeoutSeconds           int64
+	WaiterTimeoutSeconds               int64
+	WithLaunchHooks                    bool
+	LaunchTimeoutSeconds               int64
+	LaunchReadinessSelector            string
+	LaunchReadinessCommand             string
+	NodeNotFoundGraceSeconds           int64
+	TransientRetryAttempts             int64
+	ValidationTimeoutSeconds           int64
+	FailureRateWindow                  int
+	FailureRateThreshold               float64
+	FailureRateWebhookURL              string
+	ReconcileOnStart                   bool
+	ReconcileIntervalSeconds           int64
+	MaxInFlightEvents                  int64
+	WorkerPoolSize                     int
+	PollerCount                        int
+	DedupStore                         string
+	VerifySNSSignature                 bool
+	AllowedAccountIDs                  []string
+	AllowedSenderIDs                   []string
+	SkipNodeSelector                   string
+	SkipNodeAction                     string
+	InstanceTagFilters                 map[string]string
+	MaintenanceDrainLeadSeconds        int64
+	MaintenanceTerminate               bool
+	ScaleInProtection                  bool
+	ScaleInProtectionIntervalSeconds   int64
+	ScaleDownDisabledAnnotation        string
+	ScaleDownDisabledAction            string
+	WithUpgradeManager                 bool
+	UpgradeManagerTagFilters           map[string]string
+	WithInstanceManager                bool
+	Policy                             *Policy
+	SelfNodeName                       string
+	SelfPodName                        string
+	SelfPodNamespace                   string
+	WithSharding                       bool
+	EventSinks                         []string
+	EventWebhookURL                    string
+	EventSNSTopicARN                   string
+	EventNamespace                     string
+	NodeScopedEvents                   bool
+	HistorySize                        int
+	AuditTableName                     string
+	AuditRetentionDays                 int64
+	WithCloudWatchMetrics              bool
+	CloudWatchNamespace                string
+	CloudWatchIntervalSeconds          int64
+	StatsDAddress                      string
+	StatsDTags                         []string
+	MetricsPort                        int
+	MetricsEndpoint                    string
+	MetricsTLSCertFile                 string
+	MetricsTLSKeyFile                  string
+	MetricsTLSClientCAFile             string
 }
 
 // Authenticator holds clients for all required APIs
 type Authenticator struct {
-	ScalingGroupClient autoscalingiface.AutoScalingAPI
-	SQSClient          sqsiface.SQSAPI
-	ELBv2Client        elbv2iface.ELBV2API
-	ELBClient          elbiface.ELBAPI
-	KubernetesClient   kubernetes.Interface
+	ScalingGroupClient      autoscalingiface.AutoScalingAPI
+	SQSClient               sqsiface.SQSAPI
+	ELBv2Client             elbv2iface.ELBV2API
+	ELBClient               elbiface.ELBAPI
+	EC2Client               ec2iface.EC2API
+	Route53Client           route53iface.Route53API
+	ServiceDiscoveryClient  servicediscoveryiface.ServiceDiscoveryAPI
+	GlobalAcceleratorClient globalacceleratoriface.GlobalAcceleratorAPI
+	SNSClient               snsiface.SNSAPI
+	DynamoDBClient          dynamodbiface.DynamoDBAPI
+	CloudWatchClient        cloudwatchiface.CloudWatchAPI
+	KubernetesClient        kubernetes.Interface
+	DynamicClient           dynamic.Interface
+	ClusterClients          []ClusterClient
 }
 
 // ScanResult contains a list of found load balancers and target groups
@@ -72,141 +210,383 @@ type Waiter struct {
 	sync.WaitGroup
 	finished               chan bool
 	errors                 chan WaiterError
-	classicWaiterCount     int
-	targetGroupWaiterCount int
+	classicWaiterCount     int64
+	targetGroupWaiterCount int64
+}
+
+func (w *Waiter) IncClassicWaiter()     { atomic.AddInt64(&w.classicWaiterCount, 1) }
+func (w *Waiter) DecClassicWaiter()     { atomic.AddInt64(&w.classicWaiterCount, -1) }
+func (w *Waiter) IncTargetGroupWaiter() { atomic.AddInt64(&w.targetGroupWaiterCount, 1) }
+func (w *Waiter) DecTargetGroupWaiter() { atomic.AddInt64(&w.targetGroupWaiterCount, -1) }
+
+// WaiterConfig holds the backoff parameters used by deregistration waiters
+type WaiterConfig struct {
+	MinDelay    time.Duration
+	MaxDelay    time.Duration
+	MaxAttempts uint32
+	Jitter      float64
 }
 
-func (w *Waiter) IncClassicWaiter()     { w.classicWaiterCount++ }
-func (w *Waiter) DecClassicWaiter()     { w.classicWaiterCount-- }
-func (w *Waiter) IncTargetGroupWaiter() { w.targetGroupWaiterCount++ }
-func (w *Waiter) DecTargetGroupWaiter() { w.targetGroupWaiterCount-- }
+// newBackoff returns an inverse exponential backoff bound to a context, unset parameters fall back to the package defaults
+func (c WaiterConfig) newBackoff(ctx context.Context) (*waiterBackoff, error) {
+	var (
+		minDelay    = WaiterMinDelay
+		maxAttempts = WaiterMaxAttempts
+	)
+	if c.MinDelay > 0 {
+		minDelay = c.MinDelay
+	}
+	if c.MaxAttempts > 0 {
+		maxAttempts = c.MaxAttempts
+	}
+	if minDelay > c.maxDelay() {
+		return nil, errors.Errorf("waiter min delay %v is greater than max delay %v", minDelay, c.maxDelay())
+	}
+	return &waiterBackoff{
+		ctx:      ctx,
+		delay:    c.maxDelay(),
+		minDelay: minDelay,
+		retries:  maxAttempts,
+		jitter:   c.Jitter,
+	}, nil
+}
+
+// waiterBackoff is an inverse exponential backoff, its delay starts at the max delay and is halved on every attempt
+// down to the min delay, and shortened by the jitter. Unlike a sleep, the delay is cut short once the context is done
+// so that waiters observe the cancellation of their event right away rather than after their next attempt.
+type waiterBackoff struct {
+	ctx      context.Context
+	delay    time.Duration
+	minDelay time.Duration
+	retries  uint32
+	jitter   float64
+}
+
+// Next waits for the next attempt, an error is returned once no attempts are left
+func (b *waiterBackoff) Next() error {
+	if b.retries == 0 {
+		return errors.New("no more retries left")
+	}
+
+	timer := time.NewTimer(withJitter(b.delay, b.jitter))
+	defer timer.Stop()
+	select {
+	case <-b.ctx.Done():
+	case <-timer.C:
+	}
+
+	b.retries--
+	b.delay /= 2
+	if b.delay < b.minDelay {
+		b.delay = b.minDelay
+	}
+	return nil
+}
+
+// maxDelay returns the longest delay between two waiter attempts
+func (c WaiterConfig) maxDelay() time.Duration {
+	if c.MaxDelay > 0 {
+		return c.MaxDelay
+	}
+	return WaiterMaxDelay
+}
+
+// forTimeout returns the config with the fewest attempts whose delays add up to a timeout, the attempts are never
+// raised above the configured max attempts
+func (c WaiterConfig) forTimeout(timeout time.Duration) WaiterConfig {
+	var (
+		minDelay    = WaiterMinDelay
+		maxAttempts = WaiterMaxAttempts
+		delay       = c.maxDelay()
+		waited      time.Duration
+		attempts    uint32
+	)
+	if c.MinDelay > 0 {
+		minDelay = c.MinDelay
+	}
+	if c.MaxAttempts > 0 {
+		maxAttempts = c.MaxAttempts
+	}
+
+	for attempts < maxAttempts && waited < timeout {
+		waited += delay
+		attempts++
+		delay /= 2
+		if delay < minDelay {
+			delay = minDelay
+		}
+	}
+	// unset attempts would fall back to the defaults
+	if attempts == 0 {
+		attempts = 1
+	}
+	c.MaxAttempts = attempts
+	return c
+}
 
 type WaiterError struct {
 	Error error
 	Type  TargetType
 }
 
+// waiterConfig returns the waiter parameters configured for the manager
+func (mgr *Manager) waiterConfig() WaiterConfig {
+	var (
+		ctx = &mgr.context
+	)
+	return WaiterConfig{
+		MinDelay:    time.Duration(ctx.WaiterMinDelaySeconds) * time.Second,
+		MaxDelay:    time.Duration(ctx.WaiterMaxDelaySeconds) * time.Second,
+		MaxAttempts: ctx.WaiterMaxAttempts,
+		Jitter:      ctx.BackoffJitter,
+	}
+}
+
+// nodeAgeCacheFlush returns the node age in minutes under which a terminating node flushes the target health caches
+func (mgr *Manager) nodeAgeCacheFlush() int {
+	if mgr.context.NodeAgeCacheFlushMinutes > 0 {
+		return mgr.context.NodeAgeCacheFlushMinutes
+	}
+	return NodeAgeCacheTTL
+}
+
 func New(auth Authenticator, ctx ManagerContext) *Manager {
+	rootCtx, stop := context.WithCancel(context.Background())
+	shards := newShardRing(ctx, auth.KubernetesClient)
 	return &Manager{
-		eventStream:   make(chan *sqs.Message, 0),
-		workQueue:     make([]*LifecycleEvent, 0),
-		metrics:       &MetricsServer{},
+		eventStream:   make(chan *receivedMessage, 0),
+		dispatchQueue: make(chan *LifecycleEvent, 0),
+		workQueue:     make(map[string]*LifecycleEvent),
+		instanceIndex: make(map[string]string),
+		queues:        make(map[string]*consumedQueue),
+		metrics:       newMetricsServer(ctx, auth),
 		targets:       &sync.Map{},
+		drainLimiters: make(map[string]*drainLimiter),
+		drainQueue:    NewDrainQueue(),
+		dedupStore:    newDedupStore(ctx, auth.KubernetesClient, shards),
+		shards:        shards,
+		eventSink:     newEventSink(ctx, auth),
+		history:       NewEventHistory(ctx.HistorySize),
+		failureRate:   newFailureRateTracker(ctx.FailureRateWindow, ctx.FailureRateThreshold),
+		auditLog:      newAuditLog(ctx, auth),
+		membership:    NewMembershipCache(time.Second * time.Duration(ctx.MembershipCacheTTLSeconds)),
 		authenticator: auth,
 		context:       ctx,
+		ctx:           rootCtx,
+		stop:          stop,
 	}
 }
 
+// Stop shuts the service down, polling stops and the context of in-flight events is cancelled so that their
+// waiters return, the events are left to be resumed from their in-progress annotation once the service restarts
+func (mgr *Manager) Stop() {
+	log.Info("stopping lifecycle-manager service")
+	mgr.stop()
+}
+
+// stopping returns true once the service is shutting down
+func (mgr *Manager) stopping() bool { return mgr.ctx.Err() != nil }
+
 func (mgr *Manager) AddEvent(event *LifecycleEvent) {
 	var (
 		metrics = mgr.metrics
-		kube    = mgr.authenticator.KubernetesClient
 	)
-	mgr.Lock()
 	event.SetEventTimeStarted(time.Now())
-	metrics.IncGauge(TerminatingInstancesCountMetric)
+	metrics.IncGauge(TerminatingInstancesCountMetric, eventLabels(event))
+	mgr.enqueue(event)
 
-	if !mgr.EventInQueue(event) {
-		mgr.workQueue = append(mgr.workQueue, event)
-	}
+	msg := fmt.Sprintf(EventMessageLifecycleHookReceived, event.RequestID, event.EC2InstanceID)
+	mgr.publishEvent(event, EventReasonLifecycleHookReceived, getMessageFields(event, msg))
+}
 
-	mgr.Unlock()
+// instanceKey identifies the events of an instance's lifecycle transition in the work queue
+func instanceKey(e *LifecycleEvent) string {
+	return e.EC2InstanceID + "/" + e.LifecycleTransition
+}
 
-	msg := fmt.Sprintf(EventMessageLifecycleHookReceived, event.RequestID, event.EC2InstanceID)
-	kEvent := newKubernetesEvent(EventReasonLifecycleHookReceived, getMessageFields(event, msg))
-	publishKubernetesEvent(kube, kEvent)
+// enqueue adds an event to the work queue, unless an event of the same request or instance is already queued
+func (mgr *Manager) enqueue(event *LifecycleEvent) {
+	mgr.Lock()
+	defer mgr.Unlock()
+	if mgr.eventInQueue(event) {
+		return
+	}
+	mgr.workQueue[event.RequestID] = event
+	mgr.instanceIndex[instanceKey(event)] = event.RequestID
 }
 
 func (mgr *Manager) EventInQueue(e *LifecycleEvent) bool {
+	mgr.Lock()
+	defer mgr.Unlock()
+	return mgr.eventInQueue(e)
+}
+
+// eventInQueue returns true if an event of the same request is queued, the caller must hold the manager's lock
+func (mgr *Manager) eventInQueue(e *LifecycleEvent) bool {
+	if _, ok := mgr.workQueue[e.RequestID]; ok {
+		return true
+	}
+	// reconciled events carry their own request id, match them by instance as well
+	_, ok := mgr.instanceIndex[instanceKey(e)]
+	return ok
+}
+
+// startWorkers starts a fixed number of workers processing dispatched events
+func (mgr *Manager) startWorkers(count int) {
+	for i := 0; i < count; i++ {
+		go func() {
+			for event := range mgr.dispatchQueue {
+				mgr.Process(event)
+				atomic.AddInt64(&mgr.inFlightEvents, -1)
+			}
+		}()
+	}
+}
+
+// dispatchEvent hands an event to the worker pool, blocking until a worker is free, and tracks it as in-flight until processing ends
+func (mgr *Manager) dispatchEvent(event *LifecycleEvent) {
+	atomic.AddInt64(&mgr.inFlightEvents, 1)
+	mgr.dispatchQueue <- event
+}
+
+// atCapacity returns true when all workers are busy or the number of in-flight events reached the configured limit,
+// messages being received by pollers count as in-flight
+func (mgr *Manager) atCapacity() bool {
+	var (
+		ctx      = &mgr.context
+		inFlight = atomic.LoadInt64(&mgr.inFlightEvents) + atomic.LoadInt64(&mgr.intakeEvents)
+	)
+	if ctx.WorkerPoolSize > 0 && inFlight >= int64(ctx.WorkerPoolSize) {
+		return true
+	}
+	return ctx.MaxInFlightEvents > 0 && inFlight >= ctx.MaxInFlightEvents
+}
+
+// reserveIntake reserves capacity for a message about to be received by a poller, so that concurrent pollers do not
+// receive more messages than the workers can take, it returns false when at capacity
+func (mgr *Manager) reserveIntake() bool {
+	mgr.intakeMu.Lock()
+	defer mgr.intakeMu.Unlock()
+	if mgr.atCapacity() {
+		return false
+	}
+	atomic.AddInt64(&mgr.intakeEvents, 1)
+	return true
+}
+
+// releaseIntake releases the capacity reserved by a poller, once its poll returned no message or the event of its
+// message was dispatched or rejected
+func (mgr *Manager) releaseIntake() {
+	atomic.AddInt64(&mgr.intakeEvents, -1)
+}
+
+// queuedInstances returns the instances of the events in the work queue
+func (mgr *Manager) queuedInstances() map[string]bool {
+	mgr.Lock()
+	defer mgr.Unlock()
+	instances := make(map[string]bool)
 	for _, event := range mgr.workQueue {
-		if event.RequestID == e.RequestID {
-			return true
-		}
+		instances[event.EC2InstanceID] = true
 	}
-	return false
+	return instances
 }
 
 func (mgr *Manager) RemoveFromQueue(event *LifecycleEvent) {
-	for idx, ev := range mgr.workQueue {
-		if event.RequestID == ev.RequestID {
-			mgr.Lock()
-			mgr.workQueue = append(mgr.workQueue[0:idx], mgr.workQueue[idx+1:]...)
-			mgr.Unlock()
-		}
+	mgr.Lock()
+	defer mgr.Unlock()
+	queued, ok := mgr.workQueue[event.RequestID]
+	if !ok {
+		return
+	}
+	delete(mgr.workQueue, event.RequestID)
+	if mgr.instanceIndex[instanceKey(queued)] == event.RequestID {
+		delete(mgr.instanceIndex, instanceKey(queued))
 	}
 }
 
 func (mgr *Manager) CompleteEvent(event *LifecycleEvent) {
 	var (
-		queue      = mgr.authenticator.SQSClient
-		metrics    = mgr.metrics
-		kubeClient = mgr.authenticator.KubernetesClient
-		asgClient  = mgr.authenticator.ScalingGroupClient
-		url        = event.queueURL
-		t          = time.Since(event.startTime).Seconds()
+		queue     = mgr.authenticator.SQSClient
+		metrics   = mgr.metrics
+		asgClient = mgr.authenticator.ScalingGroupClient
+		url       = event.queueURL
+		t         = time.Since(event.startTime).Seconds()
 	)
 
-	if mgr.avarageLatency == 0 {
-		mgr.avarageLatency = t
-	} else {
-		mgr.avarageLatency = (mgr.avarageLatency + t) / 2
-	}
-
 	mgr.completedEvents++
 
 	log.Infof("event %v completed processing", event.RequestID)
-	event.SetEventCompleted(true)
+	mgr.setEventPhase(event, PhaseCompleting)
 
 	err := deleteMessage(queue, url, event.receiptHandle)
 	if err != nil {
 		log.Errorf("failed to delete message: %v", err)
 	}
 
-	err = completeLifecycleAction(asgClient, *event, ContinueAction)
+	err = completeLifecycleAction(asgClient, event, ContinueAction)
 	if err != nil {
 		log.Errorf("failed to complete lifecycle action: %v", err)
+	} else if mgr.context.DeleteNodeAfterComplete && event.LifecycleTransition == TerminationEventName && !mgr.isSelfNode(event) {
+		go mgr.deleteNodeAfterTermination(event)
 	}
+	mgr.setEventPhase(event, PhaseDone)
 
 	mgr.RemoveFromQueue(event)
+	mgr.releaseEvent(event)
 	msg := fmt.Sprintf(EventMessageLifecycleHookProcessed, event.RequestID, event.EC2InstanceID, t)
-	kEvent := newKubernetesEvent(EventReasonLifecycleHookProcessed, getMessageFields(event, msg))
-	publishKubernetesEvent(kubeClient, kEvent)
+	mgr.publishEvent(event, EventReasonLifecycleHookProcessed, getTimelineFields(event, msg))
 
-	metrics.AddCounter(SuccessfulEventsTotalMetric, 1)
-	metrics.DecGauge(TerminatingInstancesCountMetric)
-	metrics.SetGauge(AverageDurationSecondsMetric, mgr.avarageLatency)
+	metrics.AddCounter(SuccessfulEventsTotalMetric, eventLabels(event), 1)
+	metrics.DecGauge(TerminatingInstancesCountMetric, eventLabels(event))
+	metrics.ObserveHistogram(EventDurationSecondsMetric, eventLabels(event), t)
+	mgr.recordEvent(event, HistoryOutcomeCompleted, nil)
+	mgr.observeOutcome(event, false, nil)
 	log.Infof("event %v for instance %v completed after %vs", event.RequestID, event.EC2InstanceID, t)
 }
 
 func (mgr *Manager) FailEvent(err error, event *LifecycleEvent, abandon bool) {
 	var (
 		auth               = mgr.authenticator
-		kubeClient         = auth.KubernetesClient
 		queue              = auth.SQSClient
 		metrics            = mgr.metrics
 		scalingGroupClient = auth.ScalingGroupClient
 		url                = event.queueURL
 		t                  = time.Since(event.startTime).Seconds()
 	)
-	log.Errorf("event %v has failed processing after %vs: %v", event.RequestID, t, err)
+	log.Errorf("event %v has failed processing after %vs with a %v error: %v", event.RequestID, t, ClassifyError(err), err)
 	mgr.failedEvents++
-	metrics.AddCounter(FailedEventsTotalMetric, 1)
-	event.SetEventCompleted(true)
+	metrics.AddCounter(FailedEventsTotalMetric, eventLabels(event), 1)
+	metrics.ObserveHistogram(FailedEventDurationSecondsMetric, eventLabels(event), t)
+	mgr.setEventPhase(event, PhaseFailed)
 
 	msg := fmt.Sprintf(EventMessageLifecycleHookFailed, event.RequestID, t, err)
-	kEvent := newKubernetesEvent(EventReasonLifecycleHookFailed, getMessageFields(event, msg))
-	publishKubernetesEvent(kubeClient, kEvent)
+	mgr.publishEvent(event, EventReasonLifecycleHookFailed, getTimelineFields(event, msg))
 
+	outcome := HistoryOutcomeFailed
 	if abandon {
+		outcome = HistoryOutcomeAbandoned
+	}
+	mgr.recordEvent(event, outcome, err)
+	mgr.observeOutcome(event, true, err)
+
+	if abandon && isLifecycleActionGone(err) {
+		log.Warnf("%v> lifecycle action no longer exists, not abandoning instance", event.EC2InstanceID)
+	} else if abandon {
 		log.Warnf("abandoning instance %v", event.EC2InstanceID)
-		err := completeLifecycleAction(scalingGroupClient, *event, AbandonAction)
+		err := completeLifecycleAction(scalingGroupClient, event, AbandonAction)
 		if err != nil {
 			log.Errorf("completeLifecycleAction Failed, %s", err)
 		}
 	}
 
-	if reflect.DeepEqual(event, LifecycleEvent{}) {
-		log.Errorf("event failed: invalid message: %v", err)
+	mgr.RemoveFromQueue(event)
+	mgr.releaseEvent(event)
+
+	// invalid messages and reconciled events have no message to delete
+	if event.receiptHandle == "" {
+		if event.RequestID == "" {
+			log.Errorf("event failed: invalid message: %v", err)
+		}
 		return
 	}
 
@@ -217,6 +597,127 @@ func (mgr *Manager) FailEvent(err error, event *LifecycleEvent, abandon bool) {
 
 }
 
+// RetryEvent returns an event whose node is not found to the queue, as long as the event is younger than the node not
+// found grace period, and an event whose validation timed out, as long as its message was received less than the
+// transient retry attempts
+func (mgr *Manager) RetryEvent(err error, event *LifecycleEvent) bool {
+	var (
+		metrics = mgr.metrics
+		queue   = mgr.authenticator.SQSClient
+		grace   = time.Duration(mgr.context.NodeNotFoundGraceSeconds) * time.Second
+		delay   time.Duration
+		counter string
+	)
+
+	if event.message == nil || event.receiptHandle == "" {
+		return false
+	}
+
+	switch errors.Cause(err) {
+	case ErrNodeNotFound:
+		if _, ok := event.message.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]; !ok {
+			return false
+		}
+
+		remaining := grace - getMessageAge(event.message)
+		if remaining <= 0 {
+			return false
+		}
+
+		delay = NodeNotFoundRetryInterval
+		if remaining < delay {
+			delay = remaining
+		}
+		counter = RetriedEventsTotalMetric
+	case ErrValidationTimeout:
+		received := getMessageReceiveCount(event.message)
+		if received == 0 || received > mgr.context.TransientRetryAttempts {
+			return false
+		}
+		delay = TransientRetryInterval
+		counter = TransientRetriesTotalMetric
+	default:
+		return false
+	}
+
+	if err := changeMessageVisibility(queue, event.queueURL, event.receiptHandle, int64(delay.Round(time.Second).Seconds())); err != nil {
+		log.Errorf("%v> failed to return message to queue: %v", event.EC2InstanceID, err)
+		return false
+	}
+
+	mgr.setEventPhase(event, PhaseFailed)
+	log.Infof("%v> retrying event in %v: %v", event.EC2InstanceID, delay.Round(time.Second), err)
+	metrics.AddCounter(counter, eventLabels(event), 1)
+	return true
+}
+
+// RedriveEvent returns an event which failed with a transient error to the queue instead of abandoning its instance,
+// as long as its message was received less than the transient retry attempts
+func (mgr *Manager) RedriveEvent(err error, event *LifecycleEvent) bool {
+	var (
+		metrics = mgr.metrics
+		queue   = mgr.authenticator.SQSClient
+	)
+
+	if ClassifyError(err) != ErrorClassTransient || event.message == nil || event.receiptHandle == "" {
+		return false
+	}
+
+	received := getMessageReceiveCount(event.message)
+	if received == 0 || received > mgr.context.TransientRetryAttempts {
+		return false
+	}
+
+	delay := int64(TransientRetryInterval.Round(time.Second).Seconds())
+	if err := changeMessageVisibility(queue, event.queueURL, event.receiptHandle, delay); err != nil {
+		log.Errorf("%v> failed to return message to queue: %v", event.EC2InstanceID, err)
+		return false
+	}
+
+	log.Warnf("%v> event failed with a transient error, retrying in %v (attempt %v/%v): %v", event.EC2InstanceID, TransientRetryInterval, received, mgr.context.TransientRetryAttempts, err)
+	mgr.setEventPhase(event, PhaseFailed)
+	mgr.recordEvent(event, HistoryOutcomeRetried, err)
+	mgr.RemoveFromQueue(event)
+	mgr.releaseEvent(event)
+	metrics.AddCounter(TransientRetriesTotalMetric, eventLabels(event), 1)
+	metrics.DecGauge(TerminatingInstancesCountMetric, eventLabels(event))
+	return true
+}
+
+// SkipMessage returns the message of an event owned by another cluster sharing the queue, or by another replica of
+// the shard ring, without deleting it so that the deployment or replica owning it can consume it
+func (mgr *Manager) SkipMessage(err error, event *LifecycleEvent) bool {
+	var (
+		metrics = mgr.metrics
+		queue   = mgr.authenticator.SQSClient
+		counter string
+	)
+
+	switch errors.Cause(err) {
+	case ErrNotOwned:
+		if event.receiptHandle == "" {
+			return false
+		}
+		counter = NotOwnedMessagesTotalMetric
+	case ErrShardNotOwned:
+		counter = ShardNotOwnedMessagesTotalMetric
+	default:
+		return false
+	}
+
+	mgr.setEventPhase(event, PhaseFailed)
+
+	// reconciled events have no message, they are reconciled by the owning replica as well
+	if event.receiptHandle != "" {
+		if err := changeMessageVisibility(queue, event.queueURL, event.receiptHandle, 0); err != nil {
+			log.Errorf("%v> failed to return message to queue: %v", event.EC2InstanceID, err)
+		}
+	}
+	log.Debugf("%v> skipping message of request %v: %v", event.EC2InstanceID, event.RequestID, err)
+	metrics.AddCounter(counter, eventLabels(event), 1)
+	return true
+}
+
 func (mgr *Manager) RejectEvent(err error, event *LifecycleEvent) {
 	var (
 		metrics = mgr.metrics
@@ -226,11 +727,15 @@ func (mgr *Manager) RejectEvent(err error, event *LifecycleEvent) {
 	)
 
 	log.Debugf("event %v has been rejected for processing: %v", event.RequestID, err)
+	mgr.setEventPhase(event, PhaseFailed)
 	mgr.rejectedEvents++
-	metrics.AddCounter(RejectedEventsTotalMetric, 1)
+	metrics.AddCounter(RejectedEventsTotalMetric, eventLabels(event), 1)
 
-	if reflect.DeepEqual(event, LifecycleEvent{}) {
-		log.Errorf("event failed: invalid message: %v", err)
+	// invalid messages and reconciled events have no message to delete
+	if event.receiptHandle == "" {
+		if event.RequestID == "" {
+			log.Errorf("event failed: invalid message: %v", err)
+		}
 		return
 	}
 
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_WorkQueue(t *testing.T) {
+	t.Log("Test_WorkQueue: should index queued events by request id and instance")
+	mgr := New(Authenticator{}, _newBasicContext())
+
+	event := &LifecycleEvent{RequestID: "request-1", EC2InstanceID: "i-111111111111", LifecycleTransition: TerminationEventName}
+	mgr.enqueue(event)
+	mgr.enqueue(event)
+	if len(mgr.workQueue) != 1 {
+		t.Fatalf("expected work queue length: 1, got: %v", len(mgr.workQueue))
+	}
+
+	// a reconciled event of the same instance has its own request id
+	reconciled := &LifecycleEvent{RequestID: "reconciled-1", EC2InstanceID: "i-111111111111", LifecycleTransition: TerminationEventName}
+	if !mgr.EventInQueue(reconciled) {
+		t.Fatalf("expected event of a queued instance to be in queue")
+	}
+	mgr.enqueue(reconciled)
+	if len(mgr.workQueue) != 1 {
+		t.Fatalf("expected work queue length: 1, got: %v", len(mgr.workQueue))
+	}
+
+	launch := &LifecycleEvent{RequestID: "request-2", EC2InstanceID: "i-111111111111", LifecycleTransition: LaunchEventName}
+	if mgr.EventInQueue(launch) {
+		t.Fatalf("expected event of another transition not to be in queue")
+	}
+
+	// removing an event which is not queued leaves the queued event of the instance
+	mgr.RemoveFromQueue(reconciled)
+	if !mgr.EventInQueue(event) {
+		t.Fatalf("expected event to remain in queue")
+	}
+
+	mgr.RemoveFromQueue(event)
+	if mgr.EventInQueue(event) || mgr.EventInQueue(reconciled) {
+		t.Fatalf("expected events not to be in queue after removal")
+	}
+	if len(mgr.workQueue) != 0 || len(mgr.instanceIndex) != 0 {
+		t.Fatalf("expected empty work queue, got: %v, %v", mgr.workQueue, mgr.instanceIndex)
+	}
+}
+
+func Test_FailEventRemovesFromQueue(t *testing.T) {
+	t.Log("Test_FailEventRemovesFromQueue: should not block a new event of the same instance once an event failed")
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		SQSClient:          &stubSQS{},
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	mgr := New(auth, _newBasicContext())
+
+	event := &LifecycleEvent{RequestID: "request-1", EC2InstanceID: "i-111111111111", LifecycleTransition: LaunchEventName}
+	mgr.AddEvent(event)
+	mgr.FailEvent(errors.New("some failure"), event, false)
+
+	retried := &LifecycleEvent{RequestID: "request-2", EC2InstanceID: "i-111111111111", LifecycleTransition: LaunchEventName}
+	if mgr.EventInQueue(retried) {
+		t.Fatalf("expected event of a failed instance not to be in queue")
+	}
+	if mgr.queuedInstances()["i-111111111111"] {
+		t.Fatalf("expected failed instance not to be queued")
+	}
+}
+
+func Test_FailEventDuration(t *testing.T) {
+	t.Log("Test_FailEventDuration: should observe the duration of failed events apart from completed events")
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		SQSClient:          &stubSQS{},
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	mgr := New(auth, _newBasicContext())
+	mgr.metrics.Histograms = map[string]*prometheus.HistogramVec{
+		EventDurationSecondsMetric: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    EventDurationSecondsMetric,
+			Buckets: DurationBuckets,
+		}, ScalingGroupLabels),
+		FailedEventDurationSecondsMetric: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    FailedEventDurationSecondsMetric,
+			Buckets: DurationBuckets,
+		}, ScalingGroupLabels),
+	}
+
+	event := &LifecycleEvent{RequestID: "request-1", EC2InstanceID: "i-111111111111", AutoScalingGroupName: "my-asg", LifecycleTransition: LaunchEventName}
+	mgr.AddEvent(event)
+	mgr.FailEvent(errors.New("some failure"), event, false)
+
+	if got := testutil.CollectAndCount(mgr.metrics.Histograms[EventDurationSecondsMetric]); got != 0 {
+		t.Fatalf("expected no completed event durations, got: %v", got)
+	}
+	if got := testutil.CollectAndCount(mgr.metrics.Histograms[FailedEventDurationSecondsMetric]); got != 1 {
+		t.Fatalf("expected failed event durations: %v, got: %v", 1, got)
+	}
+}
+
+func Test_Stop(t *testing.T) {
+	t.Log("Test_Stop: should stop polling and cancel the context of in-flight events")
+	mgr := New(Authenticator{SQSClient: &stubSQS{}}, _newBasicContext())
+
+	event := &LifecycleEvent{}
+	event.SetContext(context.WithCancel(mgr.ctx))
+
+	stopped := make(chan struct{})
+	go func() {
+		mgr.newPoller(mgr.ctx, getQueueURL(&stubSQS{}, &mgr.context))
+		close(stopped)
+	}()
+	mgr.Stop()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected poller to return once stopped")
+	}
+
+	if event.Context().Err() != context.Canceled {
+		t.Fatalf("expected event context error: %v, got: %v", context.Canceled, event.Context().Err())
+	}
+}
+
+func Test_WaiterBackoff(t *testing.T) {
+	t.Log("Test_WaiterBackoff: should halve delays down to the min delay and cut them short once cancelled")
+	ctx, cancel := context.WithCancel(context.Background())
+	config := WaiterConfig{MinDelay: time.Minute, MaxDelay: 4 * time.Minute, MaxAttempts: 3}
+
+	ieb, err := config.newBackoff(ctx)
+	if err != nil {
+		t.Fatalf("failed to create backoff: %v", err)
+	}
+	cancel()
+
+	expected := []time.Duration{2 * time.Minute, time.Minute, time.Minute}
+	for _, delay := range expected {
+		if err := ieb.Next(); err != nil {
+			t.Fatalf("expected attempt, got: %v", err)
+		}
+		if ieb.delay != delay {
+			t.Fatalf("expected delay: %v, got: %v", delay, ieb.delay)
+		}
+	}
+
+	if err := ieb.Next(); err == nil {
+		t.Fatalf("expected no attempts left")
+	}
+
+	if _, err := (WaiterConfig{MinDelay: time.Minute, MaxDelay: time.Second}).newBackoff(ctx); err == nil {
+		t.Fatalf("expected error for min delay greater than max delay")
+	}
+}
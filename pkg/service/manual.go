@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// ManualRequestPrefix is the request id prefix of events of drains requested by an operator
+const ManualRequestPrefix = "manual"
+
+// DrainOptions selects the node drained by DrainInstance and what happens to its instance once drained
+type DrainOptions struct {
+	// NodeName is the name of the node to drain, InstanceID is used if it is empty
+	NodeName string
+	// InstanceID is the id of the instance whose node to drain
+	InstanceID string
+	// Terminate terminates the instance through its scaling group once the node is drained and deregistered
+	Terminate bool
+	// DecrementCapacity decrements the desired capacity of the scaling group when terminating the instance
+	DecrementCapacity bool
+}
+
+// newManualEvent returns the termination event of a node drained on request, it carries no lifecycle hook
+func (mgr *Manager) newManualEvent(opts DrainOptions) (*LifecycleEvent, error) {
+	var (
+		asgClient  = mgr.authenticator.ScalingGroupClient
+		kubeClient = mgr.authenticator.KubernetesClient
+	)
+
+	node, exists := getNodeByName(kubeClient, opts.NodeName)
+	if opts.NodeName == "" {
+		node, exists = findNodeByInstance(kubeClient, mgr.authenticator.EC2Client, opts.InstanceID)
+	}
+	if !exists {
+		if opts.NodeName == "" {
+			return nil, errors.Wrapf(ErrNodeNotFound, "instance %v is not seen in cluster nodes", opts.InstanceID)
+		}
+		return nil, errors.Wrapf(ErrNodeNotFound, "node %v is not seen in cluster nodes", opts.NodeName)
+	}
+
+	instanceID := opts.InstanceID
+	if instanceID == "" {
+		instanceID = getNodeInstanceID(node)
+	}
+	scalingGroup, ok, err := getInstanceScalingGroup(asgClient, instanceID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe scaling group of instance %v", instanceID)
+	}
+	if !ok && opts.Terminate {
+		return nil, errors.Errorf("instance %v is not part of a scaling group and cannot be terminated through one", instanceID)
+	}
+
+	event := &LifecycleEvent{
+		RequestID:            fmt.Sprintf("%v-%v-%v", ManualRequestPrefix, instanceID, time.Now().Unix()),
+		LifecycleTransition:  TerminationEventName,
+		AutoScalingGroupName: scalingGroup,
+		EC2InstanceID:        instanceID,
+	}
+	event.SetReferencedNode(node)
+	if ctx := mgr.context; ctx.MaxTimeToProcessSeconds > 0 {
+		event.SetContext(context.WithTimeout(mgr.ctx, time.Duration(ctx.MaxTimeToProcessSeconds)*time.Second))
+	} else {
+		event.SetContext(context.WithCancel(mgr.ctx))
+	}
+	return event, nil
+}
+
+// DrainInstance runs the drain and deregistration of a termination event against a node without a lifecycle hook,
+// for operators replacing specific nodes. The node is left cordoned rather than deleted, if the instance is terminated
+// its termination hook is processed by the running service, which finds the node already drained
+func (mgr *Manager) DrainInstance(opts DrainOptions) error {
+	event, err := mgr.newManualEvent(opts)
+	if err != nil {
+		return err
+	}
+	defer event.cancel()
+
+	if err := mgr.runManualDrain(event, opts); err != nil {
+		mgr.failManualDrain(event, err)
+		return err
+	}
+
+	msg := fmt.Sprintf(EventMessageManualDrainSucceeded, event.referencedNode.Name, event.EC2InstanceID, opts.Terminate)
+	mgr.publishEvent(event, EventReasonManualDrainSucceeded, getMessageFields(event, msg))
+	log.Infof("%v> node/%v drained and deregistered on request %v", event.EC2InstanceID, event.referencedNode.Name, event.RequestID)
+	return nil
+}
+
+// runManualDrain drains and deregisters the node of an event without a lifecycle hook, and terminates its instance
+// if requested, the event ends in the failed phase if any step fails
+func (mgr *Manager) runManualDrain(event *LifecycleEvent, opts DrainOptions) error {
+	mgr.setEventPhase(event, PhaseReceived)
+	mgr.setEventPhase(event, PhaseValidated)
+	log.Infof("%v> draining node/%v on request %v", event.EC2InstanceID, event.referencedNode.Name, event.RequestID)
+
+	settings := mgr.defaultEventSettings()
+	if event.AutoScalingGroupName != "" {
+		settings = mgr.resolveEventSettings(event)
+	}
+	event.SetSettings(settings)
+	log.Debugf("%v> resolved event settings: %+v", event.EC2InstanceID, settings)
+
+	// record pod IPs before eviction to follow their deregistration from ip target groups
+	if mgr.context.WithIPTargetWait {
+		podIPs, err := getNodePodIPs(event.Context(), mgr.kubeClient(event), event.referencedNode.Name)
+		if err != nil {
+			log.Errorf("%v> failed to list pods on node %v: %v", event.EC2InstanceID, event.referencedNode.Name, err)
+		}
+		event.SetReferencedPodIPs(podIPs)
+	}
+
+	mgr.setEventPhase(event, mgr.initialEventPhase())
+	if err := mgr.acquireDrainSemaphore(event); err != nil {
+		mgr.setEventPhase(event, PhaseFailed)
+		return err
+	}
+	if err := mgr.drainAndDeregister(event); err != nil {
+		mgr.setEventPhase(event, PhaseFailed)
+		return err
+	}
+
+	mgr.setEventPhase(event, PhaseCompleting)
+	if opts.Terminate {
+		if err := terminateInstance(mgr.authenticator.ScalingGroupClient, event.EC2InstanceID, opts.DecrementCapacity); err != nil {
+			mgr.setEventPhase(event, PhaseFailed)
+			return errors.Wrap(err, "failed to terminate instance")
+		}
+	}
+	mgr.setEventPhase(event, PhaseDone)
+	return nil
+}
+
+// failManualDrain reports a drain requested by an operator which failed, the node is left cordoned
+func (mgr *Manager) failManualDrain(event *LifecycleEvent, err error) {
+	msg := fmt.Sprintf(EventMessageManualDrainFailed, event.referencedNode.Name, err)
+	mgr.publishEvent(event, EventReasonManualDrainFailed, getMessageFields(event, msg))
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_DrainInstance(t *testing.T) {
+	t.Log("Test_DrainInstance: should drain a node on request and terminate its instance through its scaling group")
+	asgStubber := &stubAutoscaling{
+		autoScalingInstances: []*autoscaling.InstanceDetails{
+			{AutoScalingGroupName: aws.String("my-asg"), InstanceId: aws.String("i-123486890234")},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset()
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		KubernetesClient:   kubeClient,
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-123486890234"},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+
+	mgr := New(auth, _newBasicContext())
+	err := mgr.DrainInstance(DrainOptions{NodeName: "node-1", Terminate: true, DecrementCapacity: true})
+	if err != nil {
+		t.Fatalf("DrainInstance: expected error not to have occured, %v", err)
+	}
+
+	drained, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if !drained.Spec.Unschedulable {
+		t.Fatal("expected node to be cordoned")
+	}
+	if len(asgStubber.terminatedInstances) != 1 {
+		t.Fatalf("expected terminated instances: %v, got: %v", 1, len(asgStubber.terminatedInstances))
+	}
+	if input := asgStubber.terminatedInstances[0]; aws.StringValue(input.InstanceId) != "i-123486890234" || !aws.BoolValue(input.ShouldDecrementDesiredCapacity) {
+		t.Fatalf("expected termination of i-123486890234 decrementing capacity, got: %v", input)
+	}
+	if asgStubber.timesCalledCompleteLifecycleAction != 0 {
+		t.Fatalf("expected timesCalledCompleteLifecycleAction: %v, got: %v", 0, asgStubber.timesCalledCompleteLifecycleAction)
+	}
+}
+
+func Test_DrainInstanceValidation(t *testing.T) {
+	t.Log("Test_DrainInstanceValidation: should not drain unknown nodes or terminate instances outside of scaling groups")
+	kubeClient := fake.NewSimpleClientset()
+	asgStubber := &stubAutoscaling{}
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		KubernetesClient:   kubeClient,
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-123486890234"},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+	mgr := New(auth, _newBasicContext())
+
+	err := mgr.DrainInstance(DrainOptions{InstanceID: "i-22222222222222222"})
+	if errors.Cause(err) != ErrNodeNotFound {
+		t.Fatalf("expected error: %v, got: %v", ErrNodeNotFound, err)
+	}
+
+	err = mgr.DrainInstance(DrainOptions{InstanceID: "i-123486890234", Terminate: true})
+	if err == nil {
+		t.Fatal("DrainInstance: expected error to have occured")
+	}
+	drained, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if drained.Spec.Unschedulable {
+		t.Fatal("expected node not to be cordoned")
+	}
+}
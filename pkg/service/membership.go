@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"golang.org/x/sync/singleflight"
+)
+
+// MembershipCache holds a shared snapshot of load balancer members, concurrent lookups of the same
+// load balancer are collapsed into a single API call and results are reused until they expire
+type MembershipCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	group   singleflight.Group
+	entries map[string]*membershipEntry
+}
+
+type membershipResult struct {
+	found bool
+	port  int64
+	err   error
+}
+
+type membershipEntry struct {
+	members map[string]int64
+	expiry  time.Time
+}
+
+// NewMembershipCache returns a membership cache, a zero ttl only collapses in-flight lookups
+func NewMembershipCache(ttl time.Duration) *MembershipCache {
+	return &MembershipCache{
+		ttl:     ttl,
+		entries: make(map[string]*membershipEntry),
+	}
+}
+
+// get returns the snapshot of a load balancer's members, fetching it unless it is cached. Snapshots are shared between
+// lookups, so the fetch is not cancelled with the lookup that started it, lookups stop waiting for it once their own
+// context is done
+func (c *MembershipCache) get(ctx context.Context, key string, fetch func(ctx context.Context) (map[string]int64, error)) (map[string]int64, error) {
+	if c == nil {
+		return fetch(ctx)
+	}
+
+	c.Lock()
+	if entry, ok := c.entries[key]; ok {
+		if time.Now().Before(entry.expiry) {
+			c.Unlock()
+			return entry.members, nil
+		}
+		delete(c.entries, key)
+	}
+	c.Unlock()
+
+	shared := c.group.DoChan(key, func() (interface{}, error) {
+		members, err := fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if c.ttl > 0 {
+			c.Lock()
+			c.entries[key] = &membershipEntry{members: members, expiry: time.Now().Add(c.ttl)}
+			c.Unlock()
+		}
+		return members, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-shared:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(map[string]int64), nil
+	}
+}
+
+// findInstanceInTargetGroup looks up an instance in a cached snapshot of the target group members
+func (c *MembershipCache) findInstanceInTargetGroup(ctx context.Context, elbClient elbv2iface.ELBV2API, arn, instanceID string) (bool, int64, error) {
+	members, err := c.get(ctx, TargetTypeTargetGroup.String()+"/"+arn, func(ctx context.Context) (map[string]int64, error) {
+		return getTargetGroupMembers(ctx, elbClient, arn)
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	port, ok := members[instanceID]
+	return ok, port, nil
+}
+
+// findInstanceInClassicBalancer looks up an instance in a cached snapshot of the classic elb members
+func (c *MembershipCache) findInstanceInClassicBalancer(ctx context.Context, elbClient elbiface.ELBAPI, elbName, instanceID string) (bool, error) {
+	members, err := c.get(ctx, TargetTypeClassicELB.String()+"/"+elbName, func(ctx context.Context) (map[string]int64, error) {
+		return getClassicBalancerMembers(ctx, elbClient, elbName)
+	})
+	if err != nil {
+		return false, err
+	}
+	_, ok := members[instanceID]
+	return ok, nil
+}
+
+// forEachConcurrently calls fn for every index in [0, count) using at most workers goroutines
+func forEachConcurrently(workers, count int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > count {
+		workers = count
+	}
+
+	var wg sync.WaitGroup
+	indexes := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+func Test_MembershipCacheReuse(t *testing.T) {
+	t.Log("Test_MembershipCacheReuse: should reuse a membership snapshot until it expires")
+	var (
+		arn     = "arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/targetgroup-1/1c64a7f2cc1a3b6f"
+		cache   = NewMembershipCache(time.Minute)
+		stubber = &stubELBv2{
+			targetHealthDescriptions: []*elbv2.TargetHealthDescription{
+				{Target: &elbv2.TargetDescription{Id: aws.String("i-111111111111"), Port: aws.Int64(32334)}},
+				{Target: &elbv2.TargetDescription{Id: aws.String("i-222222222222"), Port: aws.Int64(32334)}},
+			},
+		}
+	)
+
+	for _, instanceID := range []string{"i-111111111111", "i-222222222222", "i-333333333333"} {
+		found, port, err := cache.findInstanceInTargetGroup(context.Background(), stubber, arn, instanceID)
+		if err != nil {
+			t.Fatalf("findInstanceInTargetGroup: expected error not to have occured, %v", err)
+		}
+		expectedFound := instanceID != "i-333333333333"
+		if found != expectedFound {
+			t.Fatalf("expected found for %v: %v, got: %v", instanceID, expectedFound, found)
+		}
+		if found && port != 32334 {
+			t.Fatalf("expected port: %v, got: %v", 32334, port)
+		}
+	}
+
+	expectedCalls := 1
+	if stubber.timesCalledDescribeTargetHealth != expectedCalls {
+		t.Fatalf("expected timesCalledDescribeTargetHealth: %v, got: %v", expectedCalls, stubber.timesCalledDescribeTargetHealth)
+	}
+
+	cache.entries[TargetTypeTargetGroup.String()+"/"+arn].expiry = time.Now().Add(-time.Second)
+	cache.findInstanceInTargetGroup(context.Background(), stubber, arn, "i-111111111111")
+
+	expectedCalls = 2
+	if stubber.timesCalledDescribeTargetHealth != expectedCalls {
+		t.Fatalf("expected timesCalledDescribeTargetHealth after expiry: %v, got: %v", expectedCalls, stubber.timesCalledDescribeTargetHealth)
+	}
+}
+
+func Test_MembershipCacheSingleflight(t *testing.T) {
+	t.Log("Test_MembershipCacheSingleflight: should collapse concurrent lookups into a single call")
+	var (
+		cache   = NewMembershipCache(0)
+		calls   int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+
+	fetch := func(ctx context.Context) (map[string]int64, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return map[string]int64{"i-111111111111": 0}, nil
+	}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.get(context.Background(), "classic-elb/my-elb", fetch)
+		}()
+	}
+	time.Sleep(time.Millisecond * 100)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("expected calls: %v, got: %v", 1, calls)
+	}
+
+	if len(cache.entries) != 0 {
+		t.Fatalf("expected no cached entries with zero ttl, got: %v", len(cache.entries))
+	}
+}
+
+func Test_ForEachConcurrently(t *testing.T) {
+	t.Log("Test_ForEachConcurrently: should visit every index without exceeding the worker limit")
+	var (
+		workers  = 3
+		count    = 20
+		visited  = make([]int32, count)
+		inFlight int32
+		peak     int32
+	)
+
+	forEachConcurrently(workers, count, func(i int) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			observed := atomic.LoadInt32(&peak)
+			if current <= observed || atomic.CompareAndSwapInt32(&peak, observed, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond * 5)
+		atomic.AddInt32(&visited[i], 1)
+		atomic.AddInt32(&inFlight, -1)
+	})
+
+	for i, v := range visited {
+		if v != 1 {
+			t.Fatalf("expected index %v to be visited once, got: %v", i, v)
+		}
+	}
+
+	if peak > int32(workers) {
+		t.Fatalf("expected at most %v concurrent calls, got: %v", workers, peak)
+	}
+}
@@ -1,9 +1,15 @@
 package service
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
 
 	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -15,76 +21,291 @@ var (
 	MetricsPort = ":8080"
 	// MetricsEndpoint is the endpoint to expose for metrics
 	MetricsEndpoint = "/metrics"
+	// DurationBuckets are the histogram buckets in seconds used for event and phase durations
+	DurationBuckets = []float64{5, 15, 30, 60, 120, 300, 600, 900, 1800, 3600, 7200}
 )
 
 const (
-	ActiveGoroutinesMetric            = "active_goroutines"
-	TerminatingInstancesCountMetric   = "terminating_instances_count"
-	DrainingInstancesCountMetric      = "draining_instances_count"
-	DeregisteringInstancesCountMetric = "deregistering_instances_count"
-	AverageDurationSecondsMetric      = "average_duration_seconds"
-	SuccessfulEventsTotalMetric       = "successful_events_total"
-	SuccessfulLBDeregisterTotalMetric = "successful_lb_deregister_total"
-	SuccessfulNodeDrainTotalMetric    = "successful_node_drain_total"
-	SuccessfulNodeDeleteTotalMetric   = "successful_node_delete_total"
-	FailedEventsTotalMetric           = "failed_events_total"
-	FailedLBDeregisterTotalMetric     = "failed_lb_deregister_total"
-	FailedNodeDrainTotalMetric        = "failed_node_drain_total"
-	FailedNodeDeleteTotalMetric       = "failed_node_delete_total"
-	RejectedEventsTotalMetric         = "rejected_events_total"
+	ActiveGoroutinesMetric                  = "active_goroutines"
+	TerminatingInstancesCountMetric         = "terminating_instances_count"
+	DrainingInstancesCountMetric            = "draining_instances_count"
+	DrainConcurrencyMetric                  = "drain_concurrency"
+	DrainPodsRemainingMetric                = "drain_pods_remaining"
+	DrainSemaphoreWaitingCountMetric        = "drain_semaphore_waiting_count"
+	DeregisteringInstancesCountMetric       = "deregistering_instances_count"
+	LaunchingInstancesCountMetric           = "launching_instances_count"
+	SuccessfulEventsTotalMetric             = "successful_events_total"
+	SuccessfulLBDeregisterTotalMetric       = "successful_lb_deregister_total"
+	SuccessfulNodeDrainTotalMetric          = "successful_node_drain_total"
+	SkippedNodeDrainTotalMetric             = "skipped_node_drain_total"
+	EscalatedNodeDrainTotalMetric           = "escalated_node_drain_total"
+	SuccessfulNodeDeleteTotalMetric         = "successful_node_delete_total"
+	SuccessfulNodeLaunchTotalMetric         = "successful_node_launch_total"
+	FailedEventsTotalMetric                 = "failed_events_total"
+	FailedLBDeregisterTotalMetric           = "failed_lb_deregister_total"
+	IgnoredLBDeregisterTotalMetric          = "ignored_lb_deregister_failures_total"
+	SuccessfulCloudMapDeregisterTotalMetric = "successful_cloudmap_deregister_total"
+	FailedCloudMapDeregisterTotalMetric     = "failed_cloudmap_deregister_total"
+	FailedNodeDrainTotalMetric              = "failed_node_drain_total"
+	FailedNodeDeleteTotalMetric             = "failed_node_delete_total"
+	FailedNodeLaunchTotalMetric             = "failed_node_launch_total"
+	RejectedEventsTotalMetric               = "rejected_events_total"
+	SkippedEventsTotalMetric                = "skipped_events_total"
+	WarmPoolEventsTotalMetric               = "warm_pool_events_total"
+	NotOwnedMessagesTotalMetric             = "not_owned_messages_total"
+	ShardNotOwnedMessagesTotalMetric        = "shard_not_owned_messages_total"
+	ShardMembersCountMetric                 = "shard_members_count"
+	UntrustedMessagesTotalMetric            = "untrusted_messages_total"
+	DrainSemaphoreWaitsTotalMetric          = "drain_semaphore_waits_total"
+	RetriedEventsTotalMetric                = "node_not_found_retries_total"
+	TransientRetriesTotalMetric             = "transient_retries_total"
+	FailureRateAlertsTotalMetric            = "failure_rate_alerts_total"
+	EventFailureRateMetric                  = "event_failure_rate"
+	ReconciledEventsTotalMetric             = "reconciled_events_total"
+	FailedDNSCleanupTotalMetric             = "failed_dns_cleanup_total"
+	HeartbeatStoppedTotalMetric             = "heartbeat_stopped_total"
+	HeartbeatAttemptsTotalMetric            = "heartbeat_attempts_total"
+	HeartbeatFailuresTotalMetric            = "heartbeat_failures_total"
+	HeartbeatAgeSecondsMetric               = "last_heartbeat_age_seconds"
+	DeadlineExceededEventsTotalMetric       = "deadline_exceeded_events_total"
+	QueueMessagesVisibleMetric              = "queue_messages_visible"
+	QueueMessagesInFlightMetric             = "queue_messages_in_flight"
+	QueueMessageAgeSecondsMetric            = "queue_oldest_message_age_seconds"
+	ReceivedMessagesTotalMetric             = "received_messages_total"
+	EmptyPollsTotalMetric                   = "empty_polls_total"
+	ReceiveErrorsTotalMetric                = "receive_errors_total"
+	PollerCircuitOpenMetric                 = "poller_circuit_open"
+	ConsumedQueuesCountMetric               = "consumed_queues_count"
+	InterruptionNoticesTotalMetric          = "interruption_notices_total"
+	BackpressurePollsTotalMetric            = "backpressure_polls_total"
+	EventDurationSecondsMetric              = "event_duration_seconds"
+	FailedEventDurationSecondsMetric        = "failed_event_duration_seconds"
+	DrainDurationSecondsMetric              = "drain_duration_seconds"
+	DeregisterDurationSecondsMetric         = "lb_deregister_duration_seconds"
+	EventPhaseCountMetric                   = "event_phase_count"
+	HookRemainingSecondsMetric              = "lifecycle_hook_remaining_seconds"
 )
 
+// ScalingGroupLabels are the labels of per scaling group metrics
+var ScalingGroupLabels = []string{"asg_name", "transition", "instance_refresh"}
+
 type MetricsServer struct {
-	Counters map[string]prometheus.Counter
-	Gauges   map[string]prometheus.Gauge
+	Counters   map[string]*prometheus.CounterVec
+	Gauges     map[string]*prometheus.GaugeVec
+	Histograms map[string]*prometheus.HistogramVec
+	backends   []MetricsBackend
+	// address and endpoint override MetricsPort and MetricsEndpoint
+	address  string
+	endpoint string
+	// tlsCertFile and tlsKeyFile serve metrics over TLS, tlsClientCAFile also requires client certificates signed by its CAs
+	tlsCertFile     string
+	tlsKeyFile      string
+	tlsClientCAFile string
+	// clusterName is served on the version endpoint
+	clusterName string
+	// cacheCollector exports the hits, misses and flushes of the AWS API caches
+	cacheCollector prometheus.Collector
+}
+
+// MetricsBackend receives the metrics recorded by the MetricsServer, in addition to the Prometheus metrics it serves
+type MetricsBackend interface {
+	AddCounter(idx string, labels prometheus.Labels, value float64)
+	SetGauge(idx string, labels prometheus.Labels, value float64)
+	AddGauge(idx string, labels prometheus.Labels, value float64)
+	// DeleteGauge forgets a gauge series which is no longer recorded
+	DeleteGauge(idx string, labels prometheus.Labels)
+	ObserveHistogram(idx string, labels prometheus.Labels, value float64)
+	// Start runs until the process exits, for backends which push metrics periodically
+	Start()
+}
+
+// newMetricsServer returns a metrics server publishing to the configured metrics backends
+func newMetricsServer(ctx ManagerContext, auth Authenticator) *MetricsServer {
+	m := &MetricsServer{
+		address:         MetricsPort,
+		endpoint:        MetricsEndpoint,
+		tlsCertFile:     ctx.MetricsTLSCertFile,
+		tlsKeyFile:      ctx.MetricsTLSKeyFile,
+		tlsClientCAFile: ctx.MetricsTLSClientCAFile,
+		clusterName:     ctx.ClusterName,
+	}
+	if ctx.MetricsPort > 0 {
+		m.address = fmt.Sprintf(":%v", ctx.MetricsPort)
+	}
+	if ctx.MetricsEndpoint != "" {
+		m.endpoint = ctx.MetricsEndpoint
+	}
+	if ctx.CacheConfig != nil {
+		m.cacheCollector = ctx.CacheConfig.NewCacheCollector(MetricsNamespace)
+	}
+	if publisher := newCloudWatchPublisher(ctx, auth); publisher != nil {
+		m.backends = append(m.backends, publisher)
+	}
+	if client := newStatsDClient(ctx); client != nil {
+		m.backends = append(m.backends, client)
+	}
+	return m
+}
+
+// eventLabels returns the per scaling group metric labels of an event
+func eventLabels(event *LifecycleEvent) prometheus.Labels {
+	return prometheus.Labels{
+		"asg_name":         event.AutoScalingGroupName,
+		"transition":       event.LifecycleTransition,
+		"instance_refresh": strconv.FormatBool(event.isInstanceRefresh()),
+	}
 }
 
 func (m *MetricsServer) Start() {
-	m.Gauges = make(map[string]prometheus.Gauge, 0)
-	m.Counters = make(map[string]prometheus.Counter, 0)
+	m.Gauges = make(map[string]*prometheus.GaugeVec, 0)
+	m.Counters = make(map[string]*prometheus.CounterVec, 0)
+	m.Histograms = make(map[string]*prometheus.HistogramVec, 0)
 
 	gaugeIndex := map[string]string{
 		ActiveGoroutinesMetric:            "indicates the current number of active goroutines.",
 		TerminatingInstancesCountMetric:   "indicates the current number of terminating instances.",
 		DrainingInstancesCountMetric:      "indicates the current number of draining instances.",
+		DrainConcurrencyMetric:            "indicates the current number of drains holding the drain concurrency semaphore.",
+		DrainPodsRemainingMetric:          "indicates the current number of pods remaining to be evicted from draining nodes.",
+		DrainSemaphoreWaitingCountMetric:  "indicates the current number of events waiting for the drain concurrency semaphore.",
 		DeregisteringInstancesCountMetric: "indicates the current number of deregistering instances.",
-		AverageDurationSecondsMetric:      "indicates the average duration of processing a hook in seconds.",
+		LaunchingInstancesCountMetric:     "indicates the current number of launching instances waiting for readiness.",
+		QueueMessagesVisibleMetric:        "indicates the approximate number of messages available in the queue.",
+		QueueMessagesInFlightMetric:       "indicates the approximate number of messages received but not yet deleted from the queue.",
+		QueueMessageAgeSecondsMetric:      "indicates the age in seconds of the last received message, approximating the oldest message in the queue.",
+		EventPhaseCountMetric:             "indicates the current number of events in each processing phase.",
+		ShardMembersCountMetric:           "indicates the current number of live replicas sharing events of the queue.",
+		EventFailureRateMetric:            "indicates the failure rate of the most recent events.",
+		PollerCircuitOpenMetric:           "indicates whether polling is suspended after consecutive receive errors.",
+		ConsumedQueuesCountMetric:         "indicates the number of queues polled, given by name or discovered by tag.",
+		HookRemainingSecondsMetric:        "indicates the seconds remaining until the lifecycle hook of an in-flight event expires.",
+		HeartbeatAgeSecondsMetric:         "indicates the seconds since the last successful heartbeat of an in-flight event.",
 	}
 
 	counterIndex := map[string]string{
-		SuccessfulEventsTotalMetric:       "indicates the sum of all successful events.",
-		SuccessfulLBDeregisterTotalMetric: "indicates the sum of all events that succeeded to deregister loadbalancer",
-		SuccessfulNodeDrainTotalMetric:    "indicates the sum of all events that succeeded to drain the node.",
-		SuccessfulNodeDeleteTotalMetric:   "indicates the sum of all events that succeeded to delete the node.",
-		FailedEventsTotalMetric:           "indicates the sum of all failed events.",
-		FailedLBDeregisterTotalMetric:     "indicates the sum of all events that failed to deregister loadbalancer.",
-		FailedNodeDrainTotalMetric:        "indicates the sum of all events that failed to drain the node.",
-		FailedNodeDeleteTotalMetric:       "indicates the sum of all events that failed to delete the node.",
-		RejectedEventsTotalMetric:         "indicates the sum of all rejected events.",
+		SuccessfulEventsTotalMetric:             "indicates the sum of all successful events.",
+		SuccessfulLBDeregisterTotalMetric:       "indicates the sum of all events that succeeded to deregister loadbalancer",
+		SuccessfulNodeDrainTotalMetric:          "indicates the sum of all events that succeeded to drain the node.",
+		SkippedNodeDrainTotalMetric:             "indicates the sum of all events whose node was already drained by upgrade-manager.",
+		EscalatedNodeDrainTotalMetric:           "indicates the sum of all events whose drain deleted the pods it could not evict.",
+		SuccessfulNodeDeleteTotalMetric:         "indicates the sum of all events that succeeded to delete the node.",
+		SuccessfulNodeLaunchTotalMetric:         "indicates the sum of all launch events for which the node became ready.",
+		FailedEventsTotalMetric:                 "indicates the sum of all failed events.",
+		FailedLBDeregisterTotalMetric:           "indicates the sum of all events that failed to deregister loadbalancer.",
+		IgnoredLBDeregisterTotalMetric:          "indicates the sum of all events that failed to deregister loadbalancer or cloud map services and continued termination.",
+		SuccessfulCloudMapDeregisterTotalMetric: "indicates the sum of all successful deregistrations from cloud map services.",
+		FailedCloudMapDeregisterTotalMetric:     "indicates the sum of all failed deregistrations from cloud map services.",
+		FailedNodeDrainTotalMetric:              "indicates the sum of all events that failed to drain the node.",
+		FailedNodeDeleteTotalMetric:             "indicates the sum of all events that failed to delete the node.",
+		FailedNodeLaunchTotalMetric:             "indicates the sum of all launch events for which the node did not become ready.",
+		RejectedEventsTotalMetric:               "indicates the sum of all rejected events.",
+		SkippedEventsTotalMetric:                "indicates the sum of all events whose node opted out of processing.",
+		WarmPoolEventsTotalMetric:               "indicates the sum of all warm pool events completed without draining since their instance was not a node.",
+		NotOwnedMessagesTotalMetric:             "indicates the sum of all messages returned to the queue since their scaling group belongs to another cluster.",
+		ShardNotOwnedMessagesTotalMetric:        "indicates the sum of all messages returned to the queue since their instance is owned by another replica.",
+		UntrustedMessagesTotalMetric:            "indicates the sum of all messages rejected since they were not sent by an allowed account or sender.",
+		DrainSemaphoreWaitsTotalMetric:          "indicates the sum of all events which waited for the drain concurrency semaphore.",
+		RetriedEventsTotalMetric:                "indicates the sum of all events returned to the queue since their node was not found yet.",
+		TransientRetriesTotalMetric:             "indicates the sum of all events returned to the queue since they failed with a transient error.",
+		FailureRateAlertsTotalMetric:            "indicates the sum of all alerts raised since the failure rate of recent events crossed the threshold.",
+		ReconciledEventsTotalMetric:             "indicates the sum of all orphaned events re-adopted by the reconciler.",
+		FailedDNSCleanupTotalMetric:             "indicates the sum of all events that failed to remove route53 records of the node.",
+		HeartbeatStoppedTotalMetric:             "indicates the sum of all events for which heartbeats stopped before processing completed.",
+		HeartbeatAttemptsTotalMetric:            "indicates the sum of all attempts to send a heartbeat, including retries.",
+		HeartbeatFailuresTotalMetric:            "indicates the sum of all attempts to send a heartbeat that failed.",
+		InterruptionNoticesTotalMetric:          "indicates the sum of all spot interruption, rebalance recommendation, scheduled change and state change notices received.",
+		DeadlineExceededEventsTotalMetric:       "indicates the sum of all events which exceeded the max time to process.",
+		ReceivedMessagesTotalMetric:             "indicates the sum of all messages received from the queue.",
+		EmptyPollsTotalMetric:                   "indicates the sum of all queue polls which returned no messages.",
+		ReceiveErrorsTotalMetric:                "indicates the sum of all queue polls which failed to receive messages.",
+		BackpressurePollsTotalMetric:            "indicates the sum of all queue polls skipped since all workers were busy or the in-flight event limit was reached.",
+	}
+
+	histogramIndex := map[string]string{
+		EventDurationSecondsMetric:       "indicates the duration of processing a hook which completed in seconds.",
+		FailedEventDurationSecondsMetric: "indicates the duration of processing a hook which failed in seconds.",
+		DrainDurationSecondsMetric:       "indicates the duration of draining a node in seconds.",
+		DeregisterDurationSecondsMetric:  "indicates the duration of deregistering an instance from loadbalancers in seconds.",
+	}
+
+	// gauges and counters which are not broken down per scaling group
+	globalGauges := map[string]bool{
+		ActiveGoroutinesMetric:       true,
+		DrainConcurrencyMetric:       true,
+		QueueMessagesVisibleMetric:   true,
+		QueueMessagesInFlightMetric:  true,
+		QueueMessageAgeSecondsMetric: true,
+		ShardMembersCountMetric:      true,
+		EventFailureRateMetric:       true,
+		PollerCircuitOpenMetric:      true,
+		ConsumedQueuesCountMetric:    true,
+	}
+
+	globalCounters := map[string]bool{
+		ReceivedMessagesTotalMetric:  true,
+		EmptyPollsTotalMetric:        true,
+		BackpressurePollsTotalMetric: true,
+		UntrustedMessagesTotalMetric: true,
+		FailureRateAlertsTotalMetric: true,
+		ReceiveErrorsTotalMetric:     true,
 	}
 
 	for gaugeName, desc := range gaugeIndex {
-		gauge := prometheus.NewGauge(
+		labels := ScalingGroupLabels
+		if globalGauges[gaugeName] {
+			labels = []string{}
+		}
+		if gaugeName == EventPhaseCountMetric {
+			labels = PhaseLabels
+		}
+		if gaugeName == HookRemainingSecondsMetric || gaugeName == HeartbeatAgeSecondsMetric {
+			labels = InstanceLabels
+		}
+		gauge := prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: MetricsNamespace,
 				Name:      string(gaugeName),
 				Help:      desc,
-			})
+			},
+			labels,
+		)
 		m.Gauges[string(gaugeName)] = gauge
 	}
 
 	for counterName, desc := range counterIndex {
-		counter := prometheus.NewCounter(
+		labels := ScalingGroupLabels
+		if globalCounters[counterName] {
+			labels = []string{}
+		}
+		if counterName == InterruptionNoticesTotalMetric {
+			labels = InterruptionLabels
+		}
+		counter := prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: MetricsNamespace,
 				Name:      counterName,
 				Help:      desc,
 			},
+			labels,
 		)
 		m.Counters[counterName] = counter
 	}
 
-	http.Handle(MetricsEndpoint, promhttp.Handler())
+	for histogramName, desc := range histogramIndex {
+		histogram := prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: MetricsNamespace,
+				Name:      histogramName,
+				Help:      desc,
+				Buckets:   DurationBuckets,
+			},
+			ScalingGroupLabels,
+		)
+		m.Histograms[histogramName] = histogram
+	}
+
+	http.Handle(m.endpoint, promhttp.Handler())
+	http.Handle(VersionEndpoint, versionHandler(m.clusterName))
 
 	for _, gauge := range m.Gauges {
 		prometheus.MustRegister(gauge)
@@ -94,29 +315,118 @@ func (m *MetricsServer) Start() {
 		prometheus.MustRegister(counter)
 	}
 
-	log.Fatal(http.ListenAndServe(MetricsPort, nil))
+	for _, histogram := range m.Histograms {
+		prometheus.MustRegister(histogram)
+	}
+
+	prometheus.MustRegister(awsAPICalls, awsAPIErrors, awsAPIThrottles, awsAPIDuration)
+	if m.cacheCollector != nil {
+		prometheus.MustRegister(m.cacheCollector)
+	}
+
+	prometheus.MustRegister(buildInfo)
+	setBuildInfo()
+
+	for _, backend := range m.backends {
+		go backend.Start()
+	}
+
+	server, err := m.newHTTPServer()
+	if err != nil {
+		log.Fatalf("failed to configure metrics server: %v", err)
+	}
+	if m.tlsCertFile != "" {
+		log.Fatal(server.ListenAndServeTLS(m.tlsCertFile, m.tlsKeyFile))
+	}
+	log.Fatal(server.ListenAndServe())
+}
+
+// newHTTPServer returns the server of the metrics and admin endpoints, when a client CA is configured
+// clients must present a certificate signed by it
+func (m *MetricsServer) newHTTPServer() (*http.Server, error) {
+	server := &http.Server{
+		Addr: m.address,
+	}
+	if m.tlsClientCAFile == "" {
+		return server, nil
+	}
+
+	pem, err := os.ReadFile(m.tlsClientCAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read client ca file")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client ca file %v", m.tlsClientCAFile)
+	}
+	server.TLSConfig = &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS12,
+	}
+	return server, nil
 }
 
-func (m *MetricsServer) AddCounter(idx string, value float64) {
+func (m *MetricsServer) AddCounter(idx string, labels prometheus.Labels, value float64) {
 	if val, ok := m.Counters[idx]; ok {
-		val.Add(value)
+		val.With(labels).Add(value)
+	}
+	for _, backend := range m.backends {
+		backend.AddCounter(idx, labels, value)
 	}
 }
 
-func (m *MetricsServer) SetGauge(idx string, value float64) {
+func (m *MetricsServer) SetGauge(idx string, labels prometheus.Labels, value float64) {
 	if val, ok := m.Gauges[idx]; ok {
-		val.Set(value)
+		val.With(labels).Set(value)
+	}
+	for _, backend := range m.backends {
+		backend.SetGauge(idx, labels, value)
 	}
 }
 
-func (m *MetricsServer) IncGauge(idx string) {
+func (m *MetricsServer) AddGauge(idx string, labels prometheus.Labels, value float64) {
 	if val, ok := m.Gauges[idx]; ok {
-		val.Inc()
+		val.With(labels).Add(value)
+	}
+	for _, backend := range m.backends {
+		backend.AddGauge(idx, labels, value)
 	}
 }
 
-func (m *MetricsServer) DecGauge(idx string) {
+// DeleteGauge removes a gauge series, for per event series once the event is processed
+func (m *MetricsServer) DeleteGauge(idx string, labels prometheus.Labels) {
 	if val, ok := m.Gauges[idx]; ok {
-		val.Dec()
+		val.Delete(labels)
+	}
+	for _, backend := range m.backends {
+		backend.DeleteGauge(idx, labels)
+	}
+}
+
+func (m *MetricsServer) IncGauge(idx string, labels prometheus.Labels) {
+	if val, ok := m.Gauges[idx]; ok {
+		val.With(labels).Inc()
+	}
+	for _, backend := range m.backends {
+		backend.AddGauge(idx, labels, 1)
+	}
+}
+
+func (m *MetricsServer) DecGauge(idx string, labels prometheus.Labels) {
+	if val, ok := m.Gauges[idx]; ok {
+		val.With(labels).Dec()
+	}
+	for _, backend := range m.backends {
+		backend.AddGauge(idx, labels, -1)
+	}
+}
+
+func (m *MetricsServer) ObserveHistogram(idx string, labels prometheus.Labels, value float64) {
+	if val, ok := m.Histograms[idx]; ok {
+		val.With(labels).Observe(value)
+	}
+	for _, backend := range m.backends {
+		backend.ObserveHistogram(idx, labels, value)
 	}
 }
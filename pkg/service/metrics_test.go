@@ -1,13 +1,28 @@
 package service
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/keikoproj/lifecycle-manager/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func Test_Metrics(t *testing.T) {
@@ -50,4 +65,173 @@ func Test_Metrics(t *testing.T) {
 	if resp.StatusCode != expectedStatusCode {
 		t.Fatalf("expected status code: %v, got: %v", expectedStatusCode, resp.StatusCode)
 	}
+
+	resp, err = http.Get(fmt.Sprintf("http://127.0.0.1%v%v", MetricsPort, VersionEndpoint))
+	if err != nil {
+		t.Fatalf("expected version request not to fail, %v", err)
+	}
+	defer resp.Body.Close()
+	info := VersionInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("failed to decode version: %v", err)
+	}
+	if info.Version != version.Version {
+		t.Fatalf("expected version: %v, got: %v", version.Version, info.Version)
+	}
+
+	labels := prometheus.Labels{"version": version.Version, "commit": version.GitCommit, "build_date": version.BuildDate, "go_version": version.GoVersion}
+	if got := testutil.ToFloat64(buildInfo.With(labels)); got != 1 {
+		t.Fatalf("expected build info: 1, got: %v", got)
+	}
+}
+
+func Test_ObserveHistogram(t *testing.T) {
+	t.Log("Test_ObserveHistogram: should observe durations per scaling group and transition")
+	var (
+		m         = &MetricsServer{}
+		myASG     = eventLabels(&LifecycleEvent{AutoScalingGroupName: "my-asg", LifecycleTransition: TerminationEventName})
+		otherASG  = eventLabels(&LifecycleEvent{AutoScalingGroupName: "other-asg", LifecycleTransition: TerminationEventName})
+		launching = eventLabels(&LifecycleEvent{AutoScalingGroupName: "my-asg", LifecycleTransition: LaunchEventName})
+	)
+	m.ObserveHistogram(EventDurationSecondsMetric, myASG, 10)
+
+	m.Histograms = map[string]*prometheus.HistogramVec{
+		EventDurationSecondsMetric: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    EventDurationSecondsMetric,
+			Buckets: DurationBuckets,
+		}, ScalingGroupLabels),
+	}
+	m.ObserveHistogram(EventDurationSecondsMetric, myASG, 10)
+	m.ObserveHistogram(EventDurationSecondsMetric, myASG, 4000)
+	m.ObserveHistogram(EventDurationSecondsMetric, otherASG, 20)
+	m.ObserveHistogram(EventDurationSecondsMetric, launching, 30)
+
+	expected := 3
+	got := testutil.CollectAndCount(m.Histograms[EventDurationSecondsMetric])
+	if got != expected {
+		t.Fatalf("expected series: %v, got: %v", expected, got)
+	}
+}
+
+func Test_ScalingGroupCounters(t *testing.T) {
+	t.Log("Test_ScalingGroupCounters: should break down counters per scaling group")
+	var (
+		m     = &MetricsServer{}
+		myASG = eventLabels(&LifecycleEvent{AutoScalingGroupName: "my-asg", LifecycleTransition: TerminationEventName})
+	)
+	m.AddCounter(SuccessfulEventsTotalMetric, myASG, 1)
+
+	m.Counters = map[string]*prometheus.CounterVec{
+		SuccessfulEventsTotalMetric: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: SuccessfulEventsTotalMetric,
+		}, ScalingGroupLabels),
+	}
+	m.AddCounter(SuccessfulEventsTotalMetric, myASG, 1)
+	m.AddCounter(SuccessfulEventsTotalMetric, myASG, 1)
+
+	expected := float64(2)
+	got := testutil.ToFloat64(m.Counters[SuccessfulEventsTotalMetric].With(myASG))
+	if got != expected {
+		t.Fatalf("expected successful events for my-asg: %v, got: %v", expected, got)
+	}
+}
+
+func _writeSelfSignedCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "lifecycle-manager"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func Test_MetricsServerConfig(t *testing.T) {
+	t.Log("Test_MetricsServerConfig: should override the metrics port and endpoint")
+	m := newMetricsServer(ManagerContext{}, Authenticator{})
+	if m.address != MetricsPort || m.endpoint != MetricsEndpoint {
+		t.Fatalf("expected default address and endpoint: %v%v, got: %v%v", MetricsPort, MetricsEndpoint, m.address, m.endpoint)
+	}
+
+	m = newMetricsServer(ManagerContext{MetricsPort: 9090, MetricsEndpoint: "/custom"}, Authenticator{})
+	if m.address != ":9090" || m.endpoint != "/custom" {
+		t.Fatalf("expected address and endpoint: :9090/custom, got: %v%v", m.address, m.endpoint)
+	}
+}
+
+func Test_MetricsServerClientCertificates(t *testing.T) {
+	t.Log("Test_MetricsServerClientCertificates: should only serve clients presenting a certificate signed by the client ca")
+	dir := t.TempDir()
+	certFile, keyFile := _writeSelfSignedCert(t, dir)
+
+	m := newMetricsServer(ManagerContext{
+		MetricsTLSCertFile:     certFile,
+		MetricsTLSKeyFile:      keyFile,
+		MetricsTLSClientCAFile: certFile,
+	}, Authenticator{})
+	server, err := m.newHTTPServer()
+	if err != nil {
+		t.Fatalf("newHTTPServer: expected error not to have occured, %v", err)
+	}
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go server.ServeTLS(listener, certFile, keyFile)
+	defer server.Close()
+
+	caPEM, _ := os.ReadFile(certFile)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("failed to load client certificate: %v", err)
+	}
+	url := fmt.Sprintf("https://%v%v", listener.Addr().String(), MetricsEndpoint)
+
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if resp, err := anonymous.Get(url); err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected request without client certificate to fail")
+	}
+
+	authenticated := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}}}}
+	resp, err := authenticated.Get(url)
+	if err != nil {
+		t.Fatalf("expected request with client certificate to succeed, got: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	m.tlsClientCAFile = filepath.Join(dir, "missing.crt")
+	if _, err := m.newHTTPServer(); err == nil {
+		t.Fatalf("newHTTPServer: expected error to have occured for a missing client ca file")
+	}
 }
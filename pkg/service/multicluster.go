@@ -0,0 +1,57 @@
+package service
+
+import (
+	"path"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClusterClient is the client of a cluster events are routed to when a deployment serves multiple clusters
+type ClusterClient struct {
+	// Name is the name of the cluster, the kubeconfig context it was loaded from
+	Name string
+	// ScalingGroupPattern is a shell pattern matching the names of the cluster's scaling groups
+	ScalingGroupPattern string
+	// KubernetesClient is the client of the cluster
+	KubernetesClient kubernetes.Interface
+}
+
+// clusterNode is a node of one of the clusters the manager is connected to
+type clusterNode struct {
+	name       string
+	kubeClient kubernetes.Interface
+}
+
+// routeEvent sets the client of the first cluster whose scaling group pattern matches the event's scaling group,
+// events which match no cluster are processed with the default client
+func (mgr *Manager) routeEvent(e *LifecycleEvent) {
+	for _, cluster := range mgr.authenticator.ClusterClients {
+		if ok, _ := path.Match(cluster.ScalingGroupPattern, e.AutoScalingGroupName); ok {
+			log.Debugf("%v> routing event of scaling group %v to cluster %v", e.EC2InstanceID, e.AutoScalingGroupName, cluster.Name)
+			e.kubeClient = cluster.KubernetesClient
+			return
+		}
+	}
+}
+
+// kubeClient returns the client of the cluster the event is routed to
+func (mgr *Manager) kubeClient(e *LifecycleEvent) kubernetes.Interface {
+	return eventKubeClient(e, mgr.authenticator.KubernetesClient)
+}
+
+// kubeClients returns the default client followed by the clients of all routed clusters
+func (mgr *Manager) kubeClients() []kubernetes.Interface {
+	clients := []kubernetes.Interface{mgr.authenticator.KubernetesClient}
+	for _, cluster := range mgr.authenticator.ClusterClients {
+		clients = append(clients, cluster.KubernetesClient)
+	}
+	return clients
+}
+
+func eventKubeClient(e *LifecycleEvent, fallback kubernetes.Interface) kubernetes.Interface {
+	if e.kubeClient != nil {
+		return e.kubeClient
+	}
+	return fallback
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_RouteEvent(t *testing.T) {
+	t.Log("Test_RouteEvent: should route events to the cluster whose pattern matches their scaling group")
+	var (
+		defaultClient = fake.NewSimpleClientset()
+		prodClient    = fake.NewSimpleClientset()
+		stagingClient = fake.NewSimpleClientset()
+	)
+
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		KubernetesClient:   defaultClient,
+		ClusterClients: []ClusterClient{
+			{Name: "prod", ScalingGroupPattern: "prod-*", KubernetesClient: prodClient},
+			{Name: "staging", ScalingGroupPattern: "staging-*", KubernetesClient: stagingClient},
+		},
+	}
+	mgr := New(auth, _newBasicContext())
+
+	tests := []struct {
+		scalingGroup string
+		expected     interface{}
+	}{
+		{scalingGroup: "prod-nodes", expected: prodClient},
+		{scalingGroup: "staging-nodes", expected: stagingClient},
+		{scalingGroup: "other-nodes", expected: defaultClient},
+	}
+
+	for _, tc := range tests {
+		event := &LifecycleEvent{AutoScalingGroupName: tc.scalingGroup}
+		mgr.routeEvent(event)
+		if mgr.kubeClient(event) != tc.expected {
+			t.Fatalf("%v: expected event to be routed to client %p, got: %p", tc.scalingGroup, tc.expected, mgr.kubeClient(event))
+		}
+	}
+
+	if clients := mgr.kubeClients(); len(clients) != 3 {
+		t.Fatalf("expected clients: %v, got: %v", 3, len(clients))
+	}
+}
+
+func Test_ValidateEventRouted(t *testing.T) {
+	t.Log("Test_ValidateEventRouted: should find the node of an event in the cluster it is routed to")
+	prodClient := fake.NewSimpleClientset()
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "prod-node",
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "aws:///us-west-2a/i-123486890234",
+		},
+	}
+	prodClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+
+	asgStubber := &stubAutoscaling{
+		lifecycleHooks: []*autoscaling.LifecycleHook{
+			{
+				AutoScalingGroupName: aws.String("prod-nodes"),
+				HeartbeatTimeout:     aws.Int64(60),
+			},
+		},
+	}
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		KubernetesClient:   fake.NewSimpleClientset(),
+		ClusterClients: []ClusterClient{
+			{Name: "prod", ScalingGroupPattern: "prod-*", KubernetesClient: prodClient},
+		},
+	}
+	mgr := New(auth, _newBasicContext())
+
+	event := &LifecycleEvent{
+		LifecycleHookName:    "my-hook",
+		LifecycleTransition:  TerminationEventName,
+		AutoScalingGroupName: "prod-nodes",
+		EC2InstanceID:        "i-123486890234",
+	}
+	mgr.routeEvent(event)
+
+	if err := mgr.validateEvent(event); err != nil {
+		t.Fatalf("validateEvent: expected error not to have occured, %v", err)
+	}
+
+	if event.referencedNode.Name != "prod-node" {
+		t.Fatalf("expected referenced node: %v, got: %v", "prod-node", event.referencedNode.Name)
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// NodeConditionLifecycleTerminating is the type of the node condition reporting the processing of its termination
+	NodeConditionLifecycleTerminating v1.NodeConditionType = "LifecycleTerminating"
+)
+
+// nodeConditionReasons are the reasons of the node condition for each phase of a termination event, the condition is
+// set once the node is drained so that rejected or skipped events do not report a termination
+var nodeConditionReasons = map[EventPhase]string{
+	PhaseDraining:      "Draining",
+	PhaseDeregistering: "Deregistering",
+	PhaseCompleting:    "Completing",
+	PhaseDone:          "Completed",
+	PhaseFailed:        "Failed",
+}
+
+// newNodeCondition returns the node condition of a termination event in a phase, the condition is true while the
+// event is processed and once it completed, and false once it failed as the termination may not proceed
+func newNodeCondition(event *LifecycleEvent, phase EventPhase) v1.NodeCondition {
+	status := v1.ConditionTrue
+	if phase == PhaseFailed {
+		status = v1.ConditionFalse
+	}
+	now := metav1.Now()
+	return v1.NodeCondition{
+		Type:               NodeConditionLifecycleTerminating,
+		Status:             status,
+		Reason:             nodeConditionReasons[phase],
+		Message:            fmt.Sprintf("termination of instance %v by lifecycle event %v is %v", event.EC2InstanceID, event.RequestID, phase),
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+}
+
+// setNodeCondition reports the phase of a termination event as a condition of its node, failures are logged and do
+// not affect processing
+func (mgr *Manager) setNodeCondition(event *LifecycleEvent, previous, phase EventPhase) {
+	var (
+		nodeName = event.referencedNode.Name
+	)
+
+	if !mgr.context.WithNodeCondition || nodeName == "" || event.LifecycleTransition != TerminationEventName {
+		return
+	}
+	if _, ok := nodeConditionReasons[phase]; !ok {
+		return
+	}
+	if _, ok := nodeConditionReasons[previous]; phase.isTerminal() && !ok {
+		return
+	}
+
+	condition := newNodeCondition(event, phase)
+	if err := patchNodeCondition(mgr.kubeClient(event), nodeName, condition); err != nil {
+		log.Warnf("%v> failed to set %v condition of node/%v: %v", event.EC2InstanceID, condition.Type, nodeName, err)
+	}
+}
+
+// patchNodeCondition sets a condition of a node's status, its last transition time is kept when its status is unchanged
+func patchNodeCondition(kubeClient kubernetes.Interface, nodeName string, condition v1.NodeCondition) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for _, existing := range node.Status.Conditions {
+			if existing.Type == condition.Type && existing.Status == condition.Status {
+				condition.LastTransitionTime = existing.LastTransitionTime
+			}
+		}
+
+		patch, err := json.Marshal(map[string]interface{}{
+			"status": map[string]interface{}{
+				"conditions": []v1.NodeCondition{condition},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = kubeClient.CoreV1().Nodes().PatchStatus(context.Background(), nodeName, patch)
+		return err
+	})
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_SetNodeCondition(t *testing.T) {
+	t.Log("Test_SetNodeCondition: should report the phase of a termination event as a condition of its node")
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{
+				{Type: v1.NodeReady, Status: v1.ConditionTrue},
+			},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(node)
+	ctx := _newBasicContext()
+	ctx.WithNodeCondition = true
+	mgr := New(Authenticator{KubernetesClient: kubeClient}, ctx)
+
+	event := &LifecycleEvent{
+		RequestID:           "63f5b5c2-58b3-0574-b7d5-b3162d0268f0",
+		EC2InstanceID:       "i-123486890234",
+		LifecycleTransition: TerminationEventName,
+	}
+	event.SetReferencedNode(*node)
+
+	getCondition := func() (v1.NodeCondition, int) {
+		updated, err := kubeClient.CoreV1().Nodes().Get(context.Background(), node.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Get: expected error not to have occured, %v", err)
+		}
+		for _, condition := range updated.Status.Conditions {
+			if condition.Type == NodeConditionLifecycleTerminating {
+				return condition, len(updated.Status.Conditions)
+			}
+		}
+		return v1.NodeCondition{}, len(updated.Status.Conditions)
+	}
+
+	mgr.setEventPhase(event, PhaseValidated)
+	if condition, _ := getCondition(); condition.Type != "" {
+		t.Fatalf("expected condition not to be set before draining, got: %v", condition.Reason)
+	}
+
+	mgr.setEventPhase(event, PhaseDraining)
+	condition, count := getCondition()
+	if condition.Status != v1.ConditionTrue || condition.Reason != "Draining" {
+		t.Fatalf("expected condition: %v/%v, got: %v/%v", v1.ConditionTrue, "Draining", condition.Status, condition.Reason)
+	}
+	if count != 2 {
+		t.Fatalf("expected conditions: %v, got: %v", 2, count)
+	}
+
+	mgr.setEventPhase(event, PhaseFailed)
+	condition, _ = getCondition()
+	if condition.Status != v1.ConditionFalse || condition.Reason != "Failed" {
+		t.Fatalf("expected condition: %v/%v, got: %v/%v", v1.ConditionFalse, "Failed", condition.Status, condition.Reason)
+	}
+
+	// a redelivered event reports the termination again, a duplicate rejected after validation does not fail it
+	retried := &LifecycleEvent{LifecycleTransition: TerminationEventName}
+	retried.SetReferencedNode(*node)
+	mgr.setEventPhase(retried, PhaseValidated)
+	mgr.setEventPhase(retried, PhaseDraining)
+	rejected := &LifecycleEvent{LifecycleTransition: TerminationEventName}
+	rejected.SetReferencedNode(*node)
+	mgr.setEventPhase(rejected, PhaseValidated)
+	mgr.setEventPhase(rejected, PhaseFailed)
+	if condition, _ = getCondition(); condition.Reason != "Draining" {
+		t.Fatalf("expected condition: %v, got: %v", "Draining", condition.Reason)
+	}
+
+	mgr.context.WithNodeCondition = false
+	mgr.setEventPhase(retried, PhaseDeregistering)
+	if condition, _ = getCondition(); condition.Reason != "Draining" {
+		t.Fatalf("expected condition not to be updated when disabled, got: %v", condition.Reason)
+	}
+}
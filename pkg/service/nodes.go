@@ -2,19 +2,26 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	drain "k8s.io/kubectl/pkg/drain"
 
 	"github.com/keikoproj/lifecycle-manager/pkg/log"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 )
 
 func getNodeByInstance(k kubernetes.Interface, instanceID string) (v1.Node, bool) {
@@ -26,11 +33,7 @@ func getNodeByInstance(k kubernetes.Interface, instanceID string) (v1.Node, bool
 	}
 
 	for _, node := range nodes.Items {
-		providerID := node.Spec.ProviderID
-		splitProviderID := strings.Split(providerID, "/")
-		foundID := splitProviderID[len(splitProviderID)-1]
-
-		if instanceID == foundID {
+		if instanceID == getNodeInstanceID(node) {
 			return node, true
 		}
 	}
@@ -38,6 +41,85 @@ func getNodeByInstance(k kubernetes.Interface, instanceID string) (v1.Node, bool
 	return foundNode, false
 }
 
+// getNodeInstanceID returns the instance id of a node from its provider id
+func getNodeInstanceID(node v1.Node) string {
+	splitProviderID := strings.Split(node.Spec.ProviderID, "/")
+	return splitProviderID[len(splitProviderID)-1]
+}
+
+// findNodeByInstance returns the node of an instance by its provider id, falling back to matching the addresses of the
+// instance described through EC2 when no node's provider id carries the instance id, e.g. with custom CNIs
+func findNodeByInstance(k kubernetes.Interface, ec2Client ec2iface.EC2API, instanceID string) (v1.Node, bool) {
+	node, exists := getNodeByInstance(k, instanceID)
+	if exists || ec2Client == nil {
+		return node, exists
+	}
+
+	node, exists, err := getNodeByInstanceAddress(k, ec2Client, instanceID)
+	if err != nil {
+		log.Warnf("%v> failed to match node by instance addresses: %v", instanceID, err)
+		return node, false
+	}
+	if exists {
+		log.Infof("%v> matched node/%v by instance address, its provider id is '%v'", instanceID, node.Name, node.Spec.ProviderID)
+	}
+	return node, exists
+}
+
+// getNodeByInstanceAddress returns the node of an instance whose name or addresses match the private DNS names or IPs
+// of the instance, for nodes whose provider id does not carry their instance id. Nodes whose provider id carries the id
+// of another instance are not matched, they may hold a private IP reused from a terminated instance
+func getNodeByInstanceAddress(k kubernetes.Interface, ec2Client ec2iface.EC2API, instanceID string) (v1.Node, bool, error) {
+	var foundNode v1.Node
+	out, err := ec2Client.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: aws.StringSlice([]string{instanceID}),
+	})
+	if err != nil {
+		return foundNode, false, err
+	}
+
+	addresses := make(map[string]bool)
+	for _, reservation := range out.Reservations {
+		for _, instance := range reservation.Instances {
+			addresses[aws.StringValue(instance.PrivateDnsName)] = true
+			addresses[aws.StringValue(instance.PrivateIpAddress)] = true
+			for _, eni := range instance.NetworkInterfaces {
+				for _, ip := range eni.PrivateIpAddresses {
+					addresses[aws.StringValue(ip.PrivateDnsName)] = true
+					addresses[aws.StringValue(ip.PrivateIpAddress)] = true
+				}
+			}
+		}
+	}
+	delete(addresses, "")
+	if len(addresses) == 0 {
+		return foundNode, false, nil
+	}
+
+	nodes, err := k.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return foundNode, false, err
+	}
+
+	for _, node := range nodes.Items {
+		if id := getNodeInstanceID(node); strings.HasPrefix(id, "i-") && id != instanceID {
+			continue
+		}
+		if addresses[node.Name] {
+			return node, true, nil
+		}
+		for _, address := range node.Status.Addresses {
+			if address.Type == v1.NodeExternalIP || address.Type == v1.NodeExternalDNS {
+				continue
+			}
+			if addresses[address.Address] {
+				return node, true, nil
+			}
+		}
+	}
+	return foundNode, false, nil
+}
+
 func getNodeByName(k kubernetes.Interface, nodeName string) (v1.Node, bool) {
 	var foundNode v1.Node
 	nodes, err := k.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
@@ -69,7 +151,15 @@ func isNodeStatusInCondition(node v1.Node, condition v1.ConditionStatus) bool {
 	return false
 }
 
-func drainNode(kubeClient kubernetes.Interface, node *v1.Node, timeout, retryInterval int64, retryAttempts uint) error {
+// drainObserver receives the progress of a node drain, either callback may be nil
+type drainObserver struct {
+	// podsFound is called with the number of pods to evict before every drain attempt
+	podsFound func(count int)
+	// podEvicted is called concurrently for every pod evicted or deleted from the node
+	podEvicted func(pod *v1.Pod)
+}
+
+func drainNode(ctx context.Context, kubeClient kubernetes.Interface, node *v1.Node, timeout, retryInterval int64, retryAttempts uint, policy drainPolicy, observer *drainObserver) error {
 	var err error = nil
 	if timeout == 0 {
 		log.Warn("skipping drain since timeout was set to 0")
@@ -77,9 +167,12 @@ func drainNode(kubeClient kubernetes.Interface, node *v1.Node, timeout, retryInt
 	}
 
 	for retryAttempts > 0 {
+		if ctx.Err() != nil {
+			return fmt.Errorf("drain aborted: %v", ctx.Err())
+		}
 		// create a copy of the node obj, since RunCordonOrUncordon() modifies the node obj
 		nodeCopy := node.DeepCopy()
-		err = drainNodeUtil(nodeCopy, int(timeout), kubeClient)
+		err = drainNodeUtil(ctx, nodeCopy, int(timeout), kubeClient, policy, observer)
 		if err == nil {
 			log.Infof("drain succeeded, node %v", node.Name)
 			return nil
@@ -94,8 +187,8 @@ func drainNode(kubeClient kubernetes.Interface, node *v1.Node, timeout, retryInt
 	return err
 }
 
-func deleteNode(kubeClient kubernetes.Interface, node *v1.Node) error {
-	err := deleteNodeUtil(node, kubeClient)
+func deleteNode(ctx context.Context, kubeClient kubernetes.Interface, node *v1.Node) error {
+	err := deleteNodeUtil(ctx, node, kubeClient)
 	if err != nil {
 		log.Errorf("failed to delete node %v  error: %v ", node.Name, err)
 		return err
@@ -116,30 +209,44 @@ func runCommand(call string, arg []string) (string, error) {
 	return string(out), err
 }
 
-func labelNode(kubectlPath, nodeName, labelKey, labelValue string) error {
-	label := fmt.Sprintf("%v=%v", labelKey, labelValue)
-	labelArgs := []string{"label", "--overwrite", "node", nodeName, label}
-	_, err := runCommand(kubectlPath, labelArgs)
+func labelNode(kubeClient kubernetes.Interface, nodeName, labelKey, labelValue string) error {
+	labels := map[string]string{
+		labelKey: labelValue,
+	}
+	err := patchNodeMetadata(kubeClient, nodeName, "labels", labels)
 	if err != nil {
-		log.Errorf("failed to label node %v", nodeName)
+		log.Errorf("failed to label node %v: %v", nodeName, err)
 		return err
 	}
 	return nil
 }
 
-func annotateNode(kubectlPath string, nodeName string, annotations map[string]string) error {
-	annotateArgs := []string{"annotate", "--overwrite", "node", nodeName}
-	for k, v := range annotations {
-		annotateArgs = append(annotateArgs, fmt.Sprintf("%v=%v", k, v))
-	}
-	_, err := runCommand(kubectlPath, annotateArgs)
+func annotateNode(kubeClient kubernetes.Interface, nodeName string, annotations map[string]string) error {
+	err := patchNodeMetadata(kubeClient, nodeName, "annotations", annotations)
 	if err != nil {
-		log.Errorf("failed to annotate node %v", nodeName)
+		log.Errorf("failed to annotate node %v: %v", nodeName, err)
 		return err
 	}
 	return nil
 }
 
+// patchNodeMetadata overwrites the given labels or annotations of a node with a strategic merge patch
+func patchNodeMetadata(kubeClient kubernetes.Interface, nodeName, field string, values map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			field: values,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		_, err := kubeClient.CoreV1().Nodes().Patch(context.Background(), nodeName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
+}
+
 func getNodesByAnnotationKeys(kubeClient kubernetes.Interface, keys ...string) (map[string]map[string]string, error) {
 	results := make(map[string]map[string]string, 0)
 
@@ -164,7 +271,7 @@ func getNodesByAnnotationKeys(kubeClient kubernetes.Interface, keys ...string) (
 }
 
 // drainNodeUtil cordons and drains a node.
-func drainNodeUtil(node *v1.Node, DrainTimeout int, client kubernetes.Interface) error {
+func drainNodeUtil(ctx context.Context, node *v1.Node, DrainTimeout int, client kubernetes.Interface, policy drainPolicy, observer *drainObserver) error {
 	var err error = nil
 	if client == nil {
 		return fmt.Errorf("K8sClient not set")
@@ -178,17 +285,7 @@ func drainNodeUtil(node *v1.Node, DrainTimeout int, client kubernetes.Interface)
 		return fmt.Errorf("node not found")
 	}
 
-	helper := &drain.Helper{
-		Ctx:                 context.Background(),
-		Client:              client,
-		Force:               true,
-		GracePeriodSeconds:  -1,
-		IgnoreAllDaemonSets: true,
-		Out:                 os.Stdout,
-		ErrOut:              os.Stdout,
-		DeleteEmptyDirData:  true,
-		Timeout:             time.Duration(DrainTimeout) * time.Second,
-	}
+	helper := newDrainHelper(ctx, client, time.Duration(DrainTimeout)*time.Second, policy)
 
 	if err = drain.RunCordonOrUncordon(helper, node, true); err != nil {
 		if apierrors.IsNotFound(err) {
@@ -198,7 +295,26 @@ func drainNodeUtil(node *v1.Node, DrainTimeout int, client kubernetes.Interface)
 		return err
 	}
 
-	if err = drain.RunNodeDrain(helper, node.Name); err != nil {
+	if observer != nil {
+		if observer.podsFound != nil {
+			pods, errs := helper.GetPodsForDeletion(node.Name)
+			if len(errs) == 0 {
+				observer.podsFound(len(pods.Pods()))
+			}
+		}
+		if observer.podEvicted != nil {
+			helper.OnPodDeletedOrEvicted = func(pod *v1.Pod, usingEviction bool) {
+				observer.podEvicted(pod)
+			}
+		}
+	}
+
+	if policy.order == EvictionOrderPriority {
+		err = runOrderedDrain(helper, node.Name)
+	} else {
+		err = drain.RunNodeDrain(helper, node.Name)
+	}
+	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return err
 		}
@@ -207,7 +323,51 @@ func drainNodeUtil(node *v1.Node, DrainTimeout int, client kubernetes.Interface)
 	return err
 }
 
-func deleteNodeUtil(node *v1.Node, client kubernetes.Interface) error {
+// newDrainHelper returns the drain helper evicting the pods of a node according to the drain policy
+func newDrainHelper(ctx context.Context, client kubernetes.Interface, timeout time.Duration, policy drainPolicy) *drain.Helper {
+	helper := &drain.Helper{
+		Ctx:                 ctx,
+		Client:              client,
+		Force:               true,
+		GracePeriodSeconds:  policy.gracePeriodSeconds,
+		IgnoreAllDaemonSets: true,
+		Out:                 os.Stdout,
+		ErrOut:              os.Stdout,
+		DeleteEmptyDirData:  true,
+		DisableEviction:     policy.disableEviction,
+		Timeout:             timeout,
+	}
+
+	helper.AdditionalFilters = []drain.PodFilter{policy.filterPod}
+	return helper
+}
+
+// getPodsForDeletion returns the pods a drain of the node would evict
+func getPodsForDeletion(ctx context.Context, client kubernetes.Interface, nodeName string, policy drainPolicy) ([]v1.Pod, error) {
+	list, errs := newDrainHelper(ctx, client, 0, policy).GetPodsForDeletion(nodeName)
+	if len(errs) > 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+	return list.Pods(), nil
+}
+
+// cordonNode marks a node unschedulable without evicting its pods
+func cordonNode(ctx context.Context, client kubernetes.Interface, node *v1.Node) error {
+	helper := &drain.Helper{
+		Ctx:    ctx,
+		Client: client,
+		Out:    os.Stdout,
+		ErrOut: os.Stdout,
+	}
+
+	// RunCordonOrUncordon() modifies the node obj
+	if err := drain.RunCordonOrUncordon(helper, node.DeepCopy(), true); err != nil {
+		return fmt.Errorf("error cordoning node: %v", err)
+	}
+	return nil
+}
+
+func deleteNodeUtil(ctx context.Context, node *v1.Node, client kubernetes.Interface) error {
 
 	var err error = nil
 
@@ -215,7 +375,7 @@ func deleteNodeUtil(node *v1.Node, client kubernetes.Interface) error {
 		return fmt.Errorf("node not found")
 	}
 
-	err = client.CoreV1().Nodes().Delete(context.Background(), node.Name, metav1.DeleteOptions{})
+	err = client.CoreV1().Nodes().Delete(ctx, node.Name, metav1.DeleteOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to delete node %q: %v", node.Name, err)
 	}
@@ -3,19 +3,17 @@ package service
 import (
 	"context"
 	"reflect"
+	"sync"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	v1 "k8s.io/api/core/v1"
 	apimachinery_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
-var (
-	stubKubectlPathSuccess = "echo"
-	stubKubectlPathFail    = "/bin/some-bad-file"
-)
-
 func Test_NodeStatusPredicate(t *testing.T) {
 	t.Log("Test_NodeStatusPredicate: should return true if node readiness is in given condition")
 
@@ -107,6 +105,55 @@ func Test_GetNodeByInstanceNegative(t *testing.T) {
 	}
 }
 
+func Test_FindNodeByInstanceAddress(t *testing.T) {
+	t.Log("Test_FindNodeByInstanceAddress: should match a node without the instance id in its provider id by the instance's addresses")
+	kubeClient := fake.NewSimpleClientset()
+	fakeNodes := []v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-11111111111111111"},
+			Status: v1.NodeStatus{
+				Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.2"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-2"},
+			Spec:       v1.NodeSpec{ProviderID: "custom://node-2"},
+			Status: v1.NodeStatus{
+				Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.2"}},
+			},
+		},
+	}
+
+	for _, node := range fakeNodes {
+		kubeClient.CoreV1().Nodes().Create(context.Background(), &node, apimachinery_v1.CreateOptions{})
+	}
+
+	ec2Stubber := &stubEC2{
+		instances: []*ec2.Instance{
+			{
+				InstanceId:       aws.String("i-22222222222222222"),
+				PrivateDnsName:   aws.String("ip-10-0-0-2.us-west-2.compute.internal"),
+				PrivateIpAddress: aws.String("10.0.0.2"),
+			},
+		},
+	}
+
+	if _, exists := findNodeByInstance(kubeClient, nil, "i-22222222222222222"); exists {
+		t.Fatalf("expected findNodeByInstance exists without ec2 client to be: %v, got: %v", false, exists)
+	}
+
+	// node-1 holds the same ip but belongs to another instance
+	node, exists := findNodeByInstance(kubeClient, ec2Stubber, "i-22222222222222222")
+	if !exists || node.Name != "node-2" {
+		t.Fatalf("expected node: %v, got: %v (exists: %v)", "node-2", node.Name, exists)
+	}
+
+	if _, exists := findNodeByInstance(kubeClient, ec2Stubber, "i-33333333333333333"); exists {
+		t.Fatalf("expected findNodeByInstance exists to be: %v, got: %v", false, exists)
+	}
+}
+
 func Test_GetNodesByAnnotationKey(t *testing.T) {
 	t.Log("Test_GetNodesByAnnotationKey: Get map of nodes annotation values by a key")
 	kubeClient := fake.NewSimpleClientset()
@@ -172,10 +219,90 @@ func Test_DrainNodePositive(t *testing.T) {
 		},
 	}
 	kubeClient.CoreV1().Nodes().Create(context.Background(), readyNode, apimachinery_v1.CreateOptions{})
-	err := drainNode(kubeClient, readyNode, 10, 0, 3)
+	err := drainNode(context.Background(), kubeClient, readyNode, 10, 0, 3, defaultDrainPolicy, nil)
+	if err != nil {
+		t.Fatalf("drainNode: expected error not to have occured, %v", err)
+	}
+}
+
+func Test_DrainNodeObserver(t *testing.T) {
+	t.Log("Test_DrainNodeObserver: should report the pods found and every pod evicted from the node")
+	kubeClient := fake.NewSimpleClientset()
+	// without the eviction subresource the drain falls back to deleting pods
+	kubeClient.Fake.Resources = []*apimachinery_v1.APIResourceList{{GroupVersion: "v1"}}
+	node := &v1.Node{
+		ObjectMeta: apimachinery_v1.ObjectMeta{
+			Name: "node-1",
+		},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, apimachinery_v1.CreateOptions{})
+	for _, name := range []string{"pod-1", "pod-2"} {
+		pod := &v1.Pod{
+			ObjectMeta: apimachinery_v1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: v1.PodSpec{
+				NodeName: "node-1",
+			},
+		}
+		kubeClient.CoreV1().Pods("default").Create(context.Background(), pod, apimachinery_v1.CreateOptions{})
+	}
+
+	var (
+		found   int
+		evicted = make(map[string]bool)
+		mu      sync.Mutex
+	)
+	observer := &drainObserver{
+		podsFound: func(count int) {
+			found = count
+		},
+		podEvicted: func(pod *v1.Pod) {
+			mu.Lock()
+			defer mu.Unlock()
+			evicted[pod.Name] = true
+		},
+	}
+
+	policy := drainPolicy{
+		order:              EvictionOrderPriority,
+		gracePeriodSeconds: 0,
+	}
+	err := drainNode(context.Background(), kubeClient, node, 10, 0, 1, policy, observer)
 	if err != nil {
 		t.Fatalf("drainNode: expected error not to have occured, %v", err)
 	}
+
+	if found != 2 {
+		t.Fatalf("expected pods found: %v, got: %v", 2, found)
+	}
+
+	expected := map[string]bool{"pod-1": true, "pod-2": true}
+	if !reflect.DeepEqual(evicted, expected) {
+		t.Fatalf("expected evicted pods: %v, got: %v", expected, evicted)
+	}
+}
+
+func Test_CordonNode(t *testing.T) {
+	t.Log("Test_CordonNode: should mark the node unschedulable")
+	kubeClient := fake.NewSimpleClientset()
+	node := &v1.Node{
+		ObjectMeta: apimachinery_v1.ObjectMeta{
+			Name: "node-1",
+		},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, apimachinery_v1.CreateOptions{})
+
+	err := cordonNode(context.Background(), kubeClient, node)
+	if err != nil {
+		t.Fatalf("cordonNode: expected error not to have occured, %v", err)
+	}
+
+	cordoned, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), "node-1", apimachinery_v1.GetOptions{})
+	if !cordoned.Spec.Unschedulable {
+		t.Fatalf("expected unschedulable: %v, got: %v", true, cordoned.Spec.Unschedulable)
+	}
 }
 
 func Test_DrainNodeNegative(t *testing.T) {
@@ -192,7 +319,7 @@ func Test_DrainNodeNegative(t *testing.T) {
 		},
 	}
 
-	err := drainNode(kubeClient, unjoinedNode, 10, 30, 3)
+	err := drainNode(context.Background(), kubeClient, unjoinedNode, 10, 30, 3, defaultDrainPolicy, nil)
 	if err == nil {
 		t.Fatalf("drainNode: expected error to have occured, %v", err)
 	}
@@ -209,23 +336,75 @@ func Test_RunCommand(t *testing.T) {
 func Test_LabelNodePositive(t *testing.T) {
 	t.Log("Test_LabelNode: should not return an error if succesful")
 	var (
-		nodeName = "some-node"
+		nodeName   = "some-node"
+		kubeClient = fake.NewSimpleClientset()
 	)
 
-	err := labelNode(stubKubectlPathSuccess, nodeName, ExcludeLabelKey, ExcludeLabelValue)
+	node := &v1.Node{
+		ObjectMeta: apimachinery_v1.ObjectMeta{
+			Name: nodeName,
+		},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, apimachinery_v1.CreateOptions{})
+
+	err := labelNode(kubeClient, nodeName, ExcludeLabelKey, ExcludeLabelValue)
 	if err != nil {
 		t.Fatalf("Test_LabelNode: expected error not to have occured, %v", err)
 	}
+
+	labeled, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, apimachinery_v1.GetOptions{})
+	if labeled.Labels[ExcludeLabelKey] != ExcludeLabelValue {
+		t.Fatalf("Test_LabelNode: expected label: %v, got: %v", ExcludeLabelValue, labeled.Labels[ExcludeLabelKey])
+	}
 }
 
 func Test_LabelNodeNegative(t *testing.T) {
-	t.Log("Test_LabelNode: should return an error if succesful")
+	t.Log("Test_LabelNode: should return an error if the node does not exist")
 	var (
-		nodeName = "some-node"
+		nodeName   = "some-node"
+		kubeClient = fake.NewSimpleClientset()
 	)
 
-	err := labelNode(stubKubectlPathFail, nodeName, ExcludeLabelKey, ExcludeLabelValue)
+	err := labelNode(kubeClient, nodeName, ExcludeLabelKey, ExcludeLabelValue)
 	if err == nil {
 		t.Fatalf("Test_LabelNode: expected error to have occured, %v", err)
 	}
 }
+
+func Test_AnnotateNode(t *testing.T) {
+	t.Log("Test_AnnotateNode: should overwrite the node's annotations and keep the others")
+	var (
+		nodeName   = "some-node"
+		kubeClient = fake.NewSimpleClientset()
+	)
+
+	node := &v1.Node{
+		ObjectMeta: apimachinery_v1.ObjectMeta{
+			Name: nodeName,
+			Annotations: map[string]string{
+				InProgressAnnotationKey: "some-message",
+				"some-annotation":       "some-value",
+			},
+		},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, apimachinery_v1.CreateOptions{})
+
+	annotations := map[string]string{
+		InProgressAnnotationKey: "",
+		QueueNameAnnotationKey:  "some-queue",
+	}
+	err := annotateNode(kubeClient, nodeName, annotations)
+	if err != nil {
+		t.Fatalf("Test_AnnotateNode: expected error not to have occured, %v", err)
+	}
+
+	expected := map[string]string{
+		InProgressAnnotationKey: "",
+		QueueNameAnnotationKey:  "some-queue",
+		"some-annotation":       "some-value",
+	}
+	annotated, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, apimachinery_v1.GetOptions{})
+	if !reflect.DeepEqual(annotated.Annotations, expected) {
+		t.Fatalf("Test_AnnotateNode: expected annotations: %v, got: %v", expected, annotated.Annotations)
+	}
+}
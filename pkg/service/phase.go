@@ -0,0 +1,151 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// EventPhase is the processing phase of a lifecycle event
+type EventPhase string
+
+const (
+	// PhaseReceived is the phase of events read from a message
+	PhaseReceived EventPhase = "received"
+	// PhaseValidated is the phase of events which passed validation and wait to be processed
+	PhaseValidated EventPhase = "validated"
+	// PhaseDraining is the phase of events whose node is drained
+	PhaseDraining EventPhase = "draining"
+	// PhaseDeregistering is the phase of events whose instance is deregistered from load balancers and other targets
+	PhaseDeregistering EventPhase = "deregistering"
+	// PhaseCompleting is the phase of events whose node is deleted and lifecycle hook completed
+	PhaseCompleting EventPhase = "completing"
+	// PhaseDone is the phase of events which completed processing
+	PhaseDone EventPhase = "done"
+	// PhaseFailed is the phase of events which failed processing or were rejected
+	PhaseFailed EventPhase = "failed"
+)
+
+// PhaseLabels are the labels of per phase metrics
+var PhaseLabels = append(append([]string{}, ScalingGroupLabels...), "phase")
+
+// phaseTransitions are the phases each phase may transition to, every phase but done may transition to failed.
+// Instances are deregistered before their node is drained with --deregister-before-drain
+var phaseTransitions = map[EventPhase][]EventPhase{
+	PhaseReceived:      {PhaseValidated},
+	PhaseValidated:     {PhaseDraining, PhaseDeregistering, PhaseCompleting, PhaseDone},
+	PhaseDraining:      {PhaseDeregistering, PhaseCompleting},
+	PhaseDeregistering: {PhaseDraining, PhaseCompleting},
+	PhaseCompleting:    {PhaseDone},
+}
+
+// isTerminal returns true if the phase ends the processing of an event
+func (p EventPhase) isTerminal() bool {
+	return p == PhaseDone || p == PhaseFailed
+}
+
+// canTransition returns true if an event in the phase may transition to the next phase
+func (p EventPhase) canTransition(next EventPhase) bool {
+	// events which are not read from a message, such as restored events, may start in any phase
+	if p == "" {
+		return true
+	}
+	if p.isTerminal() {
+		return false
+	}
+	if next == PhaseFailed {
+		return true
+	}
+	for _, allowed := range phaseTransitions[p] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Phase returns the processing phase of the event
+func (e *LifecycleEvent) Phase() EventPhase {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.phase
+}
+
+// setPhase transitions the event to a phase, entering a terminal phase cancels the event's context
+func (e *LifecycleEvent) setPhase(phase EventPhase) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.phase == phase {
+		return nil
+	}
+	if !e.phase.canTransition(phase) {
+		return fmt.Errorf("invalid event phase transition %v -> %v", e.phase, phase)
+	}
+	e.phase = phase
+	e.phases = append(e.phases, phase)
+	if phase.isTerminal() && e.cancel != nil {
+		e.cancel()
+	}
+	return nil
+}
+
+// isCompleted returns true once the event is done or failed
+func (e *LifecycleEvent) isCompleted() bool { return e.Phase().isTerminal() }
+
+// setPhaseFailed records that the work of a phase failed, processing may still move on to the next phase
+// depending on the failure policy
+func (e *LifecycleEvent) setPhaseFailed(phase EventPhase) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failedPhases = append(e.failedPhases, phase)
+}
+
+// phaseCompleted returns true if the event moved on from the phase to a phase other than failed, and the work
+// of the phase did not fail
+func (e *LifecycleEvent) phaseCompleted(phase EventPhase) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, p := range e.failedPhases {
+		if p == phase {
+			return false
+		}
+	}
+	for i, p := range e.phases {
+		if p == phase && i+1 < len(e.phases) {
+			return e.phases[i+1] != PhaseFailed
+		}
+	}
+	return false
+}
+
+func phaseLabels(event *LifecycleEvent, phase EventPhase) prometheus.Labels {
+	labels := eventLabels(event)
+	labels["phase"] = string(phase)
+	return labels
+}
+
+// setEventPhase transitions an event to a phase and updates the count of events per phase, terminal phases are not counted
+func (mgr *Manager) setEventPhase(event *LifecycleEvent, phase EventPhase) {
+	var (
+		metrics  = mgr.metrics
+		previous = event.Phase()
+	)
+
+	if previous == phase {
+		return
+	}
+	if err := event.setPhase(phase); err != nil {
+		log.Warnf("%v> %v", event.EC2InstanceID, err)
+		return
+	}
+	log.Debugf("%v> event %v entered phase %v", event.EC2InstanceID, event.RequestID, phase)
+
+	if previous != "" && !previous.isTerminal() {
+		metrics.DecGauge(EventPhaseCountMetric, phaseLabels(event, previous))
+	}
+	if !phase.isTerminal() {
+		metrics.IncGauge(EventPhaseCountMetric, phaseLabels(event, phase))
+	}
+	mgr.setNodeCondition(event, previous, phase)
+}
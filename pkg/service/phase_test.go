@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func Test_EventPhaseTransitions(t *testing.T) {
+	t.Log("Test_EventPhaseTransitions: should only allow transitions of the processing state machine")
+	tests := []struct {
+		from     EventPhase
+		to       EventPhase
+		expected bool
+	}{
+		{"", PhaseDraining, true},
+		{PhaseReceived, PhaseValidated, true},
+		{PhaseReceived, PhaseDraining, false},
+		{PhaseValidated, PhaseDraining, true},
+		{PhaseValidated, PhaseCompleting, true},
+		{PhaseDraining, PhaseDeregistering, true},
+		{PhaseDraining, PhaseFailed, true},
+		{PhaseDeregistering, PhaseDraining, true},
+		{PhaseDraining, PhaseCompleting, true},
+		{PhaseCompleting, PhaseDraining, false},
+		{PhaseDeregistering, PhaseCompleting, true},
+		{PhaseCompleting, PhaseDone, true},
+		{PhaseDone, PhaseFailed, false},
+		{PhaseFailed, PhaseDone, false},
+	}
+
+	for _, tc := range tests {
+		event := &LifecycleEvent{phase: tc.from}
+		err := event.setPhase(tc.to)
+		if got := err == nil; got != tc.expected {
+			t.Fatalf("expected transition %v -> %v allowed: %v, got: %v", tc.from, tc.to, tc.expected, got)
+		}
+	}
+}
+
+func Test_EventPhaseCompleted(t *testing.T) {
+	t.Log("Test_EventPhaseCompleted: should complete phases which moved on without failing")
+	event := &LifecycleEvent{}
+	for _, phase := range []EventPhase{PhaseReceived, PhaseValidated, PhaseDraining, PhaseDeregistering} {
+		event.setPhase(phase)
+	}
+	event.setPhaseFailed(PhaseDraining)
+	event.setPhase(PhaseFailed)
+
+	if !event.phaseCompleted(PhaseValidated) {
+		t.Fatalf("expected phase %v to be completed, got phases: %v", PhaseValidated, event.phases)
+	}
+	if event.phaseCompleted(PhaseDraining) {
+		t.Fatalf("expected failed phase %v not to be completed", PhaseDraining)
+	}
+	if event.phaseCompleted(PhaseDeregistering) {
+		t.Fatalf("expected phase %v followed by %v not to be completed", PhaseDeregistering, PhaseFailed)
+	}
+	if !event.isCompleted() {
+		t.Fatalf("expected event to be completed in phase %v", event.Phase())
+	}
+}
+
+func Test_SetEventPhaseMetric(t *testing.T) {
+	t.Log("Test_SetEventPhaseMetric: should count events in each non terminal phase")
+	mgr := New(Authenticator{}, _newBasicContext())
+	mgr.metrics.Gauges = map[string]*prometheus.GaugeVec{
+		EventPhaseCountMetric: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: EventPhaseCountMetric}, PhaseLabels),
+	}
+	event := &LifecycleEvent{AutoScalingGroupName: "my-asg", LifecycleTransition: TerminationEventName}
+	gauge := func(phase EventPhase) float64 {
+		return testutil.ToFloat64(mgr.metrics.Gauges[EventPhaseCountMetric].With(phaseLabels(event, phase)))
+	}
+
+	mgr.setEventPhase(event, PhaseReceived)
+	mgr.setEventPhase(event, PhaseValidated)
+	mgr.setEventPhase(event, PhaseDraining)
+	if gauge(PhaseReceived) != 0 || gauge(PhaseValidated) != 0 || gauge(PhaseDraining) != 1 {
+		t.Fatalf("expected only %v to be counted, got received/validated/draining: %v/%v/%v", PhaseDraining, gauge(PhaseReceived), gauge(PhaseValidated), gauge(PhaseDraining))
+	}
+
+	// invalid transitions are ignored
+	mgr.setEventPhase(event, PhaseDone)
+	if event.Phase() != PhaseDraining || gauge(PhaseDraining) != 1 {
+		t.Fatalf("expected phase %v to be kept, got: %v", PhaseDraining, event.Phase())
+	}
+
+	mgr.setEventPhase(event, PhaseFailed)
+	if gauge(PhaseDraining) != 0 || gauge(PhaseFailed) != 0 {
+		t.Fatalf("expected terminal phases not to be counted, got draining/failed: %v/%v", gauge(PhaseDraining), gauge(PhaseFailed))
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+)
+
+var (
+	// PodDeletionCheckInterval defines the interval at which the pods of a drained node are checked for deletion
+	PodDeletionCheckInterval = 5 * time.Second
+)
+
+// podDeletionTimeout returns the time to wait for the evicted pods of a namespace to be deleted
+func (mgr *Manager) podDeletionTimeout(namespace string) time.Duration {
+	if seconds, ok := mgr.context.PodDeletionNamespaceTimeouts[namespace]; ok {
+		return time.Duration(seconds) * time.Second
+	}
+	return time.Duration(mgr.context.PodDeletionTimeoutSeconds) * time.Second
+}
+
+// waitForPodsDeleted waits until the pods evicted from the event's node are deleted rather than only evicted, as some
+// CSI and CNI teardown only happens once a pod is deleted. Pods of a namespace are waited for up to the namespace's
+// timeout, an error naming the pods which were not deleted in time is returned
+func (mgr *Manager) waitForPodsDeleted(event *LifecycleEvent) error {
+	var (
+		kubeClient = mgr.kubeClient(event)
+		nodeName   = event.referencedNode.Name
+		start      = time.Now()
+	)
+
+	if !mgr.context.WithPodDeletionWait || event.settings.CordonOnly {
+		return nil
+	}
+
+	log.Infof("%v> waiting for evicted pods of node/%v to be deleted", event.EC2InstanceID, nodeName)
+	for {
+		pods, err := getPodsForDeletion(event.Context(), kubeClient, nodeName, mgr.drainPolicy(event))
+		if err != nil {
+			return errors.Wrap(err, "failed to list pods pending deletion")
+		}
+
+		pending, expired := []string{}, []string{}
+		for _, pod := range pods {
+			name := pod.Namespace + "/" + pod.Name
+			if time.Since(start) >= mgr.podDeletionTimeout(pod.Namespace) {
+				expired = append(expired, name)
+				continue
+			}
+			pending = append(pending, name)
+		}
+
+		if len(pending) == 0 {
+			if len(expired) > 0 {
+				sort.Strings(expired)
+				return fmt.Errorf("pods %v were not deleted within their namespace timeout", expired)
+			}
+			log.Infof("%v> evicted pods of node/%v are deleted", event.EC2InstanceID, nodeName)
+			return nil
+		}
+		log.Debugf("%v> pods %v pending deletion", event.EC2InstanceID, pending)
+
+		select {
+		case <-event.Context().Done():
+			return event.contextError("pod deletion wait")
+		case <-time.After(PodDeletionCheckInterval):
+		}
+	}
+}
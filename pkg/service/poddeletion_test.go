@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_WaitForPodsDeleted(t *testing.T) {
+	t.Log("Test_WaitForPodsDeleted: should wait for evicted pods to be deleted up to the timeout of their namespace")
+	interval := PodDeletionCheckInterval
+	PodDeletionCheckInterval = 10 * time.Millisecond
+	defer func() { PodDeletionCheckInterval = interval }()
+
+	kubeClient := fake.NewSimpleClientset()
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+	for _, namespace := range []string{"default", "kafka"} {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: namespace},
+			Spec:       v1.PodSpec{NodeName: "node-1"},
+		}
+		kubeClient.CoreV1().Pods(namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	}
+
+	ctx := _newBasicContext()
+	ctx.WithPodDeletionWait = true
+	ctx.PodDeletionTimeoutSeconds = 60
+	ctx.PodDeletionNamespaceTimeouts = map[string]int64{"kafka": 0}
+	mgr := New(Authenticator{KubernetesClient: kubeClient}, ctx)
+	event := &LifecycleEvent{EC2InstanceID: "i-123486890234"}
+	event.SetContext(context.WithCancel(context.Background()))
+	event.SetReferencedNode(*node)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		kubeClient.CoreV1().Pods("default").Delete(context.Background(), "pod-1", metav1.DeleteOptions{})
+	}()
+
+	err := mgr.waitForPodsDeleted(event)
+	if err == nil || !strings.Contains(err.Error(), "kafka/pod-1") || strings.Contains(err.Error(), "default/pod-1") {
+		t.Fatalf("expected error naming only pod kafka/pod-1, got: %v", err)
+	}
+
+	kubeClient.CoreV1().Pods("kafka").Delete(context.Background(), "pod-1", metav1.DeleteOptions{})
+	if err := mgr.waitForPodsDeleted(event); err != nil {
+		t.Fatalf("expected error not to have occured, %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// PolicyActionProcess drains and deregisters the node of an event
+	PolicyActionProcess = "process"
+	// PolicyActionSkip skips the node of an event as if it was annotated with lifecycle-manager.keikoproj.io/skip=true
+	PolicyActionSkip = "skip"
+)
+
+// Policy is an ordered list of rules deciding how termination events are processed, the decision of the first rule
+// matching an event applies on top of the scaling group tags, the hook's notification metadata is applied last
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// PolicyRule is a decision applied to the events matching all of its conditions
+type PolicyRule struct {
+	Name     string         `json:"name"`
+	Match    PolicyMatch    `json:"match"`
+	Decision PolicyDecision `json:"decision"`
+}
+
+// PolicyMatch holds the conditions of a rule, unset conditions match any event
+type PolicyMatch struct {
+	// ScalingGroups are patterns matched against the scaling group name, e.g. batch-*
+	ScalingGroups []string `json:"scalingGroups,omitempty"`
+	// ScalingGroupTags must all be set on the scaling group, an empty value matches any value
+	ScalingGroupTags map[string]string `json:"scalingGroupTags,omitempty"`
+	// InstanceTags must all be set on the EC2 instance, an empty value matches any value
+	InstanceTags map[string]string `json:"instanceTags,omitempty"`
+	// NodeSelector is a label selector matched against the node's labels
+	NodeSelector string `json:"nodeSelector,omitempty"`
+	// Namespaces matches nodes running a pod of any of these namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+	// InstanceRefresh matches only terminations caused by an instance refresh when true, and only others when false
+	InstanceRefresh *bool `json:"instanceRefresh,omitempty"`
+	// TimeWindows matches events received within any of the windows
+	TimeWindows []PolicyTimeWindow `json:"timeWindows,omitempty"`
+}
+
+// PolicyTimeWindow is a daily window of time, windows ending before they start span midnight
+type PolicyTimeWindow struct {
+	// Days are the week days the window applies to, e.g. Mon, all days when empty
+	Days []string `json:"days,omitempty"`
+	// Start and End are times of the day in the form 15:04
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Timezone is the IANA name of the window's time zone, UTC when empty
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// PolicyDecision is the processing decided for the events matching a rule, it overrides the same settings as the
+// hook's notification metadata
+type PolicyDecision struct {
+	// Action is process or skip, process when empty
+	Action string `json:"action,omitempty"`
+	HookMetadata
+}
+
+// LoadPolicy reads and validates a policy from a YAML or JSON file
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read policy")
+	}
+
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, errors.Wrap(err, "failed to parse policy")
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate returns an error for the first invalid rule of the policy
+func (p *Policy) Validate() error {
+	for i, rule := range p.Rules {
+		if err := rule.validate(); err != nil {
+			return errors.Wrapf(err, "invalid policy rule %v '%v'", i, rule.Name)
+		}
+	}
+	return nil
+}
+
+func (r PolicyRule) validate() error {
+	for _, pattern := range r.Match.ScalingGroups {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid scaling group pattern '%v'", pattern)
+		}
+	}
+	if r.Match.NodeSelector != "" {
+		if _, err := labels.Parse(r.Match.NodeSelector); err != nil {
+			return errors.Wrap(err, "invalid node selector")
+		}
+	}
+	for _, window := range r.Match.TimeWindows {
+		if _, err := window.contains(time.Now()); err != nil {
+			return err
+		}
+	}
+
+	switch r.Decision.Action {
+	case "", PolicyActionProcess, PolicyActionSkip:
+	default:
+		return errors.Errorf("action must be one of '%v' or '%v'", PolicyActionProcess, PolicyActionSkip)
+	}
+	if v := r.Decision.DrainFailurePolicy; v != nil && !IsValidFailurePolicy(*v) {
+		return errors.Errorf("invalid drainFailurePolicy '%v'", *v)
+	}
+	if v := r.Decision.DeregisterFailurePolicy; v != nil && !IsValidFailurePolicy(*v) {
+		return errors.Errorf("invalid deregisterFailurePolicy '%v'", *v)
+	}
+	if v := r.Decision.DrainTimeout; v != nil && *v < 0 {
+		return errors.Errorf("invalid drainTimeout '%v'", *v)
+	}
+	return nil
+}
+
+// contains returns true if t falls within the window
+func (w PolicyTimeWindow) contains(t time.Time) (bool, error) {
+	location := time.UTC
+	if w.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(w.Timezone); err != nil {
+			return false, errors.Wrapf(err, "invalid time window timezone '%v'", w.Timezone)
+		}
+	}
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid time window start '%v'", w.Start)
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid time window end '%v'", w.End)
+	}
+
+	t = t.In(location)
+	if len(w.Days) > 0 {
+		today := false
+		for _, day := range w.Days {
+			if strings.EqualFold(day, t.Weekday().String()[:3]) || strings.EqualFold(day, t.Weekday().String()) {
+				today = true
+			}
+		}
+		if !today {
+			return false, nil
+		}
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	if startMinute <= endMinute {
+		return minute >= startMinute && minute < endMinute, nil
+	}
+	return minute >= startMinute || minute < endMinute, nil
+}
+
+// policyInput is the event and node metadata rules are evaluated against, the scaling group and instance tags and the
+// namespaces of the node's pods are only looked up when a rule needs them
+type policyInput struct {
+	mgr        *Manager
+	event      *LifecycleEvent
+	now        time.Time
+	tags       map[string]string
+	namespaces map[string]bool
+}
+
+func (in *policyInput) scalingGroupTags() map[string]string {
+	if in.tags == nil {
+		tags, err := getScalingGroupTags(in.mgr.authenticator.ScalingGroupClient, in.event.AutoScalingGroupName)
+		if err != nil {
+			log.Warnf("%v> failed to get tags of scaling group %v for policy: %v", in.event.EC2InstanceID, in.event.AutoScalingGroupName, err)
+			tags = make(map[string]string)
+		}
+		in.tags = tags
+	}
+	return in.tags
+}
+
+func (in *policyInput) instanceTags() map[string]string {
+	if in.event.instanceTags == nil {
+		tags, err := getInstanceTags(in.mgr.authenticator.EC2Client, in.event.EC2InstanceID)
+		if err != nil {
+			log.Warnf("%v> failed to get tags of instance for policy: %v", in.event.EC2InstanceID, err)
+			return make(map[string]string)
+		}
+		in.event.instanceTags = tags
+	}
+	return in.event.instanceTags
+}
+
+func (in *policyInput) nodeNamespaces() map[string]bool {
+	if in.namespaces == nil {
+		in.namespaces = make(map[string]bool)
+		pods, err := in.mgr.kubeClient(in.event).CoreV1().Pods("").List(context.Background(), metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("spec.nodeName", in.event.referencedNode.Name).String(),
+		})
+		if err != nil {
+			log.Warnf("%v> failed to list pods of node/%v for policy: %v", in.event.EC2InstanceID, in.event.referencedNode.Name, err)
+			return in.namespaces
+		}
+		for _, pod := range pods.Items {
+			if pod.Spec.NodeName != in.event.referencedNode.Name {
+				continue
+			}
+			in.namespaces[pod.Namespace] = true
+		}
+	}
+	return in.namespaces
+}
+
+func (r PolicyRule) matches(in *policyInput) bool {
+	var (
+		match = r.Match
+		event = in.event
+	)
+
+	if len(match.ScalingGroups) > 0 {
+		found := false
+		for _, pattern := range match.ScalingGroups {
+			if ok, _ := path.Match(pattern, event.AutoScalingGroupName); ok {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if match.InstanceRefresh != nil && *match.InstanceRefresh != event.isInstanceRefresh() {
+		return false
+	}
+
+	if match.NodeSelector != "" {
+		selector, err := labels.Parse(match.NodeSelector)
+		if err != nil || !selector.Matches(labels.Set(event.referencedNode.GetLabels())) {
+			return false
+		}
+	}
+
+	if len(match.TimeWindows) > 0 {
+		found := false
+		for _, window := range match.TimeWindows {
+			if ok, _ := window.contains(in.now); ok {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(match.ScalingGroupTags) > 0 {
+		tags := in.scalingGroupTags()
+		for key, value := range match.ScalingGroupTags {
+			tag, ok := tags[key]
+			if !ok || (value != "" && tag != value) {
+				return false
+			}
+		}
+	}
+
+	if len(match.InstanceTags) > 0 && !matchesTagFilters(in.instanceTags(), match.InstanceTags) {
+		return false
+	}
+
+	if len(match.Namespaces) > 0 {
+		namespaces := in.nodeNamespaces()
+		found := false
+		for _, namespace := range match.Namespaces {
+			if namespaces[namespace] {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// evaluatePolicy returns the decision of the first policy rule matching the event, nil is returned when no rule matches
+func (mgr *Manager) evaluatePolicy(event *LifecycleEvent) *PolicyDecision {
+	policy := mgr.context.Policy
+	if policy == nil {
+		return nil
+	}
+
+	in := &policyInput{mgr: mgr, event: event, now: time.Now()}
+	for _, rule := range policy.Rules {
+		if rule.matches(in) {
+			log.Infof("%v> policy rule '%v' matched, action = %v", event.EC2InstanceID, rule.Name, rule.Decision.Action)
+			decision := rule.Decision
+			return &decision
+		}
+	}
+	return nil
+}
+
+// skip returns true if the decision skips the event
+func (d *PolicyDecision) skip() bool {
+	return d != nil && d.Action == PolicyActionSkip
+}
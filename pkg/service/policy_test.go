@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_LoadPolicy(t *testing.T) {
+	t.Log("Test_LoadPolicy: should load a valid policy and reject invalid rules")
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.yaml")
+	os.WriteFile(valid, []byte(`
+rules:
+- name: batch
+  match:
+    scalingGroups: ["batch-*"]
+    timeWindows:
+    - days: [Mon, Tue]
+      start: "22:00"
+      end: "06:00"
+      timezone: America/Los_Angeles
+  decision:
+    action: process
+    drainTimeout: 60
+    drainFailurePolicy: continue
+- name: monitoring
+  match:
+    namespaces: [monitoring]
+  decision:
+    action: skip
+`), 0600)
+
+	policy, err := LoadPolicy(valid)
+	if err != nil {
+		t.Fatalf("LoadPolicy: expected error not to have occured, %v", err)
+	}
+	if len(policy.Rules) != 2 {
+		t.Fatalf("expected rules: %v, got: %v", 2, len(policy.Rules))
+	}
+	if timeout := policy.Rules[0].Decision.DrainTimeout; timeout == nil || *timeout != 60 {
+		t.Fatalf("expected drainTimeout: %v, got: %v", 60, timeout)
+	}
+
+	invalid := map[string]string{
+		"action":   "rules: [{name: a, decision: {action: drop}}]",
+		"policy":   "rules: [{name: a, decision: {drainFailurePolicy: retry}}]",
+		"selector": "rules: [{name: a, match: {nodeSelector: 'a in b'}}]",
+		"window":   "rules: [{name: a, match: {timeWindows: [{start: '25:00', end: '06:00'}]}}]",
+		"timezone": "rules: [{name: a, match: {timeWindows: [{start: '22:00', end: '06:00', timezone: Mars/Base}]}}]",
+		"unknown":  "rules: [{name: a, match: {cluster: a}}]",
+	}
+	for name, content := range invalid {
+		file := filepath.Join(dir, name+".yaml")
+		os.WriteFile(file, []byte(content), 0600)
+		if _, err := LoadPolicy(file); err == nil {
+			t.Fatalf("LoadPolicy: expected error for invalid %v", name)
+		}
+	}
+}
+
+func Test_PolicyTimeWindow(t *testing.T) {
+	t.Log("Test_PolicyTimeWindow: should match times within a window, including windows spanning midnight")
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		window   PolicyTimeWindow
+		time     time.Time
+		expected bool
+	}{
+		{"within", PolicyTimeWindow{Start: "09:00", End: "17:00"}, monday.Add(10 * time.Hour), true},
+		{"end excluded", PolicyTimeWindow{Start: "09:00", End: "17:00"}, monday.Add(17 * time.Hour), false},
+		{"overnight late", PolicyTimeWindow{Start: "22:00", End: "06:00"}, monday.Add(23 * time.Hour), true},
+		{"overnight early", PolicyTimeWindow{Start: "22:00", End: "06:00"}, monday.Add(5 * time.Hour), true},
+		{"overnight outside", PolicyTimeWindow{Start: "22:00", End: "06:00"}, monday.Add(12 * time.Hour), false},
+		{"day", PolicyTimeWindow{Days: []string{"mon"}, Start: "00:00", End: "23:59"}, monday.Add(time.Hour), true},
+		{"other day", PolicyTimeWindow{Days: []string{"Saturday"}, Start: "00:00", End: "23:59"}, monday.Add(time.Hour), false},
+		{"timezone", PolicyTimeWindow{Start: "09:00", End: "17:00", Timezone: "Asia/Tokyo"}, monday.Add(time.Hour), true},
+	}
+
+	for _, tc := range tests {
+		got, err := tc.window.contains(tc.time)
+		if err != nil {
+			t.Fatalf("%v: expected error not to have occured, %v", tc.name, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("%v: expected contains: %v, got: %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func Test_EvaluatePolicy(t *testing.T) {
+	t.Log("Test_EvaluatePolicy: should return the decision of the first rule matching the event")
+	kube := fake.NewSimpleClientset()
+	kube.CoreV1().Pods("monitoring").Create(context.Background(), &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "prometheus-0", Namespace: "monitoring"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+	}, metav1.CreateOptions{})
+
+	auth := Authenticator{
+		KubernetesClient: kube,
+		ScalingGroupClient: &stubAutoscaling{
+			scalingGroups: []*autoscaling.Group{
+				{
+					AutoScalingGroupName: aws.String("gpu-asg"),
+					Tags: []*autoscaling.TagDescription{
+						{Key: aws.String("team"), Value: aws.String("ml")},
+					},
+				},
+			},
+		},
+	}
+	ctx := _newBasicContext()
+	ctx.Policy = &Policy{
+		Rules: []PolicyRule{
+			{
+				Name:     "ml",
+				Match:    PolicyMatch{ScalingGroups: []string{"gpu-*"}, ScalingGroupTags: map[string]string{"team": ""}},
+				Decision: PolicyDecision{HookMetadata: HookMetadata{CordonOnly: aws.Bool(true)}},
+			},
+			{
+				Name:     "monitoring",
+				Match:    PolicyMatch{Namespaces: []string{"monitoring"}},
+				Decision: PolicyDecision{Action: PolicyActionSkip},
+			},
+			{
+				Name:     "spot",
+				Match:    PolicyMatch{ScalingGroups: []string{"spot-*"}, NodeSelector: "lifecycle=spot"},
+				Decision: PolicyDecision{Action: PolicyActionProcess, HookMetadata: HookMetadata{DrainTimeout: aws.Int64(60)}},
+			},
+		},
+	}
+	mgr := New(auth, ctx)
+
+	newEvent := func(asg, node string, labels map[string]string) *LifecycleEvent {
+		event := &LifecycleEvent{AutoScalingGroupName: asg, EC2InstanceID: "i-1234567890"}
+		event.SetReferencedNode(v1.Node{ObjectMeta: metav1.ObjectMeta{Name: node, Labels: labels}})
+		return event
+	}
+
+	decision := mgr.evaluatePolicy(newEvent("gpu-asg", "node-1", nil))
+	if decision == nil || decision.CordonOnly == nil || !*decision.CordonOnly {
+		t.Fatalf("expected decision of rule: %v, got: %+v", "ml", decision)
+	}
+
+	decision = mgr.evaluatePolicy(newEvent("other-asg", "node-1", nil))
+	if !decision.skip() {
+		t.Fatalf("expected decision of rule: %v, got: %+v", "monitoring", decision)
+	}
+
+	decision = mgr.evaluatePolicy(newEvent("spot-asg", "node-2", map[string]string{"lifecycle": "spot"}))
+	if decision == nil || decision.skip() || decision.DrainTimeout == nil || *decision.DrainTimeout != 60 {
+		t.Fatalf("expected decision of rule: %v, got: %+v", "spot", decision)
+	}
+
+	decision = mgr.evaluatePolicy(newEvent("spot-asg", "node-2", nil))
+	if decision != nil {
+		t.Fatalf("expected decision: %v, got: %+v", nil, decision)
+	}
+}
+
+func Test_ResolveEventSettingsPolicy(t *testing.T) {
+	t.Log("Test_ResolveEventSettingsPolicy: should apply the policy decision before the hook's metadata")
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+	}
+	mgr := New(auth, _newBasicContext())
+	event := &LifecycleEvent{
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-1234567890",
+		NotificationMetadata: `{"drainTimeout": 120}`,
+		policyDecision: &PolicyDecision{
+			HookMetadata: HookMetadata{
+				DrainTimeout:       aws.Int64(60),
+				DrainFailurePolicy: aws.String("continue"),
+			},
+		},
+	}
+
+	settings := mgr.resolveEventSettings(event)
+	if settings.DrainTimeoutSeconds != 120 {
+		t.Fatalf("expected DrainTimeoutSeconds: %v, got: %v", 120, settings.DrainTimeoutSeconds)
+	}
+	if settings.DrainFailurePolicy != FailurePolicyContinue {
+		t.Fatalf("expected DrainFailurePolicy: %v, got: %v", FailurePolicyContinue, settings.DrainFailurePolicy)
+	}
+}
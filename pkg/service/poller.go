@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	// PollerBackoffInterval is the delay before polling again after a receive error, it doubles with each consecutive
+	// receive error up to PollerMaxBackoff
+	PollerBackoffInterval = time.Second
+	// PollerMaxBackoff is the maximum delay before polling again after a receive error
+	PollerMaxBackoff = time.Minute
+	// PollerCircuitBreakerThreshold is the number of consecutive receive errors after which polling is suspended
+	PollerCircuitBreakerThreshold = 10
+	// PollerCircuitBreakerCooldown is the delay before polling again while polling is suspended, a single poll is
+	// attempted once it passed and polling resumes if it succeeds
+	PollerCircuitBreakerCooldown = 5 * time.Minute
+)
+
+// pollerBackoff tracks the consecutive receive errors of the poller, the delay before polling again grows
+// exponentially until the circuit breaker opens and polling is suspended
+type pollerBackoff struct {
+	failures int
+	open     bool
+	jitter   float64
+}
+
+// failure records a receive error and returns the delay before polling again, opened is true when the circuit breaker
+// opened with this error
+func (b *pollerBackoff) failure() (delay time.Duration, opened bool) {
+	b.failures++
+	if b.failures >= PollerCircuitBreakerThreshold {
+		opened = !b.open
+		b.open = true
+		return PollerCircuitBreakerCooldown, opened
+	}
+
+	backoff := Backoff{Initial: PollerBackoffInterval, Max: PollerMaxBackoff, Factor: 2, Jitter: b.jitter}
+	return backoff.Delay(b.failures - 1), false
+}
+
+// success resets the consecutive receive errors, closed is true when the circuit breaker was open
+func (b *pollerBackoff) success() (closed bool) {
+	closed = b.open
+	b.failures = 0
+	b.open = false
+	return closed
+}
+
+// sleep waits for a duration, it returns false when the context is done meanwhile
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
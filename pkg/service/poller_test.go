@@ -0,0 +1,92 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func Test_PollerBackoff(t *testing.T) {
+	t.Log("Test_PollerBackoff: should back off exponentially and open the circuit breaker after consecutive errors")
+	backoff := &pollerBackoff{}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, time.Minute}
+	for _, want := range expected {
+		delay, opened := backoff.failure()
+		if delay != want || opened {
+			t.Fatalf("expected delay: %v, got: %v (opened: %v)", want, delay, opened)
+		}
+	}
+
+	for backoff.failures < PollerCircuitBreakerThreshold-1 {
+		backoff.failure()
+	}
+	delay, opened := backoff.failure()
+	if delay != PollerCircuitBreakerCooldown || !opened {
+		t.Fatalf("expected delay: %v, got: %v (opened: %v)", PollerCircuitBreakerCooldown, delay, opened)
+	}
+	if _, opened = backoff.failure(); opened {
+		t.Fatal("expected circuit breaker to open only once")
+	}
+
+	if !backoff.success() {
+		t.Fatal("expected circuit breaker to close on success")
+	}
+	if delay, _ = backoff.failure(); delay != PollerBackoffInterval {
+		t.Fatalf("expected delay: %v, got: %v", PollerBackoffInterval, delay)
+	}
+}
+
+func Test_PollerReceiveErrors(t *testing.T) {
+	t.Log("Test_PollerReceiveErrors: should keep polling after receive errors")
+	fakeMessageBody := "my-body"
+	sqsStubber := &stubSQS{
+		FakeQueueName:     "my-queue",
+		FakeQueueMessages: []*sqs.Message{{Body: aws.String(fakeMessageBody)}},
+		receiveMessageErrors: []error{
+			awserr.New("ServiceUnavailable", "service unavailable", nil),
+			awserr.New("ServiceUnavailable", "service unavailable", nil),
+		},
+	}
+
+	interval := PollerBackoffInterval
+	PollerBackoffInterval = 10 * time.Millisecond
+	defer func() { PollerBackoffInterval = interval }()
+
+	mgr := New(Authenticator{SQSClient: sqsStubber}, _newBasicContext())
+	mgr.eventStream = make(chan *receivedMessage)
+	defer mgr.Stop()
+	go mgr.newPoller(mgr.ctx, getQueueURL(sqsStubber, &mgr.context))
+
+	select {
+	case received := <-mgr.eventStream:
+		if aws.StringValue(received.message.Body) != fakeMessageBody {
+			t.Fatalf("expected message body: %v, got: %v", fakeMessageBody, aws.StringValue(received.message.Body))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected message to be received after receive errors")
+	}
+}
+
+func Test_ReserveIntake(t *testing.T) {
+	t.Log("Test_ReserveIntake: should not let concurrent pollers receive more messages than the in-flight event limit")
+	ctx := _newBasicContext()
+	ctx.MaxInFlightEvents = 2
+	mgr := New(Authenticator{SQSClient: &stubSQS{}}, ctx)
+	mgr.inFlightEvents = 1
+
+	if !mgr.reserveIntake() {
+		t.Fatal("expected intake to be reserved below the in-flight event limit")
+	}
+	if mgr.reserveIntake() {
+		t.Fatal("expected intake not to be reserved once messages being received reach the in-flight event limit")
+	}
+
+	mgr.releaseIntake()
+	if !mgr.reserveIntake() {
+		t.Fatal("expected intake to be reserved once released")
+	}
+}
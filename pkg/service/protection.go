@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// ScaleInProtectionAnnotationKey is set to "true" on nodes whose instance should be protected from scale-in, e.g.
+	// while running critical batch jobs
+	ScaleInProtectionAnnotationKey = "lifecycle-manager.keikoproj.io/scale-in-protection"
+	// ScaleInProtectedAnnotationKey marks the nodes whose instance was protected from scale-in by lifecycle-manager, so
+	// that only that protection is released, and not the protection of scaling groups protecting new instances
+	ScaleInProtectedAnnotationKey = "lifecycle-manager.keikoproj.io/scale-in-protected"
+)
+
+var (
+	// DefaultScaleInProtectionInterval is the default interval at which the scale-in protection of instances is
+	// reconciled with the annotations of their nodes
+	DefaultScaleInProtectionInterval = time.Minute
+)
+
+// setInstanceProtection sets or releases the scale-in protection of an instance of a scaling group
+func setInstanceProtection(client autoscalingiface.AutoScalingAPI, scalingGroupName, instanceID string, protected bool) error {
+	_, err := client.SetInstanceProtection(&autoscaling.SetInstanceProtectionInput{
+		AutoScalingGroupName: aws.String(scalingGroupName),
+		InstanceIds:          aws.StringSlice([]string{instanceID}),
+		ProtectedFromScaleIn: aws.Bool(protected),
+	})
+	return err
+}
+
+// removeNodeAnnotations removes the given annotations of a node with a merge patch
+func removeNodeAnnotations(kubeClient kubernetes.Interface, nodeName string, keys ...string) error {
+	annotations := make(map[string]interface{})
+	for _, key := range keys {
+		annotations[key] = nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		_, err := kubeClient.CoreV1().Nodes().Patch(context.Background(), nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
+}
+
+// startScaleInProtection periodically reconciles the scale-in protection of instances with the annotations of their
+// nodes, when --scale-in-protection is set
+func (mgr *Manager) startScaleInProtection() {
+	if !mgr.context.ScaleInProtection {
+		return
+	}
+
+	interval := DefaultScaleInProtectionInterval
+	if seconds := mgr.context.ScaleInProtectionIntervalSeconds; seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+
+	for {
+		if err := mgr.reconcileScaleInProtection(); err != nil {
+			log.Errorf("failed to reconcile scale-in protection: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// reconcileScaleInProtection protects the instances of nodes annotated with ScaleInProtectionAnnotationKey from
+// scale-in, and releases the protection of instances it protected once the annotation clears. Nodes whose instance is
+// not part of a scaling group are left alone
+func (mgr *Manager) reconcileScaleInProtection() error {
+	var (
+		kubeClient = mgr.authenticator.KubernetesClient
+		asgClient  = mgr.authenticator.ScalingGroupClient
+	)
+
+	nodes, err := kubeClient.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list nodes")
+	}
+
+	for _, node := range nodes.Items {
+		annotations := node.GetAnnotations()
+		requested := annotations[ScaleInProtectionAnnotationKey] == "true"
+		_, protected := annotations[ScaleInProtectedAnnotationKey]
+		if requested == protected {
+			continue
+		}
+
+		instanceID := getNodeInstanceID(node)
+		if instanceID == "" {
+			continue
+		}
+		scalingGroupName, ok, err := getInstanceScalingGroup(asgClient, instanceID)
+		if err != nil {
+			log.Errorf("%v> failed to get scaling group of node/%v: %v", instanceID, node.Name, err)
+			continue
+		}
+		if !ok {
+			log.Debugf("%v> node/%v is not part of a scaling group, not changing its scale-in protection", instanceID, node.Name)
+			continue
+		}
+
+		if err := setInstanceProtection(asgClient, scalingGroupName, instanceID, requested); err != nil {
+			log.Errorf("%v> failed to set scale-in protection of node/%v to %v: %v", instanceID, node.Name, requested, err)
+			continue
+		}
+
+		if requested {
+			err = annotateNode(kubeClient, node.Name, map[string]string{ScaleInProtectedAnnotationKey: scalingGroupName})
+		} else {
+			err = removeNodeAnnotations(kubeClient, node.Name, ScaleInProtectedAnnotationKey)
+		}
+		if err != nil {
+			log.Errorf("%v> failed to mark scale-in protection of node/%v: %v", instanceID, node.Name, err)
+			continue
+		}
+		log.Infof("%v> set scale-in protection of node/%v in %v to %v", instanceID, node.Name, scalingGroupName, requested)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_ReconcileScaleInProtection(t *testing.T) {
+	t.Log("Test_ReconcileScaleInProtection: should protect the instances of annotated nodes and release them once the annotation clears")
+	kubeClient := fake.NewSimpleClientset()
+	asgStubber := &stubAutoscaling{
+		autoScalingInstances: []*autoscaling.InstanceDetails{
+			{InstanceId: aws.String("i-111111111111"), AutoScalingGroupName: aws.String("my-asg")},
+			{InstanceId: aws.String("i-222222222222"), AutoScalingGroupName: aws.String("my-asg")},
+		},
+	}
+	nodes := []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "node-1",
+				Annotations: map[string]string{ScaleInProtectionAnnotationKey: "true"},
+			},
+			Spec: v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-111111111111"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-2"},
+			Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-222222222222"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "node-3",
+				Annotations: map[string]string{ScaleInProtectionAnnotationKey: "true"},
+			},
+			Spec: v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-333333333333"},
+		},
+	}
+	for _, node := range nodes {
+		kubeClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+	}
+
+	ctx := _newBasicContext()
+	ctx.ScaleInProtection = true
+	mgr := New(Authenticator{ScalingGroupClient: asgStubber, KubernetesClient: kubeClient}, ctx)
+
+	if err := mgr.reconcileScaleInProtection(); err != nil {
+		t.Fatalf("reconcileScaleInProtection: expected error not to have occured, %v", err)
+	}
+	if protected, ok := asgStubber.protectedInstances["i-111111111111"]; !ok || !protected {
+		t.Fatalf("expected instance %v protected: %v, got: %v", "i-111111111111", true, protected)
+	}
+	if _, ok := asgStubber.protectedInstances["i-222222222222"]; ok {
+		t.Fatalf("expected protection of unannotated instance %v not to be changed", "i-222222222222")
+	}
+	if _, ok := asgStubber.protectedInstances["i-333333333333"]; ok {
+		t.Fatalf("expected protection of instance %v outside of a scaling group not to be changed", "i-333333333333")
+	}
+	node, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if node.Annotations[ScaleInProtectedAnnotationKey] != "my-asg" {
+		t.Fatalf("expected node to be marked protected in: %v, got: %v", "my-asg", node.Annotations)
+	}
+
+	// nodes already protected are left alone
+	delete(asgStubber.protectedInstances, "i-111111111111")
+	if err := mgr.reconcileScaleInProtection(); err != nil {
+		t.Fatalf("reconcileScaleInProtection: expected error not to have occured, %v", err)
+	}
+	if len(asgStubber.protectedInstances) != 0 {
+		t.Fatalf("expected protection not to be set again, got: %v", asgStubber.protectedInstances)
+	}
+
+	if err := removeNodeAnnotations(kubeClient, "node-1", ScaleInProtectionAnnotationKey); err != nil {
+		t.Fatalf("removeNodeAnnotations: expected error not to have occured, %v", err)
+	}
+	if err := mgr.reconcileScaleInProtection(); err != nil {
+		t.Fatalf("reconcileScaleInProtection: expected error not to have occured, %v", err)
+	}
+	if protected, ok := asgStubber.protectedInstances["i-111111111111"]; !ok || protected {
+		t.Fatalf("expected instance %v protected: %v, got: %v", "i-111111111111", false, protected)
+	}
+	node, _ = kubeClient.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if _, ok := node.Annotations[ScaleInProtectedAnnotationKey]; ok {
+		t.Fatalf("expected protected mark to be removed, got: %v", node.Annotations)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// QueueReference identifies the queue lifecycle-manager consumes, given as the name of a queue in the local account,
+// or as the url or arn of a queue which may be owned by another account
+type QueueReference struct {
+	Name string
+	// URL is set when the queue is given by url, it is used as is instead of resolving the name
+	URL string
+	// AccountID is the account owning the queue, it is empty for queues given by name
+	AccountID string
+	// Region is the region of the queue, it is empty when it is not known from the url or arn
+	Region string
+}
+
+// ParseQueueReference parses a queue name, url e.g. https://sqs.us-west-2.amazonaws.com/000000000000/my-queue or arn
+// e.g. arn:aws:sqs:us-west-2:000000000000:my-queue
+func ParseQueueReference(queue string) (QueueReference, error) {
+	switch {
+	case arn.IsARN(queue):
+		parsed, err := arn.Parse(queue)
+		if err != nil {
+			return QueueReference{}, errors.Wrapf(err, "failed to parse queue arn %v", queue)
+		}
+		if parsed.Service != sqs.ServiceName || parsed.Resource == "" || strings.ContainsAny(parsed.Resource, ":/") {
+			return QueueReference{}, errors.Errorf("'%v' is not the arn of a queue", queue)
+		}
+		return QueueReference{Name: parsed.Resource, AccountID: parsed.AccountID, Region: parsed.Region}, nil
+
+	case strings.HasPrefix(queue, "https://") || strings.HasPrefix(queue, "http://"):
+		parsed, err := url.Parse(queue)
+		if err != nil {
+			return QueueReference{}, errors.Wrapf(err, "failed to parse queue url %v", queue)
+		}
+		path := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+		if len(path) != 2 || path[0] == "" || path[1] == "" {
+			return QueueReference{}, errors.Errorf("'%v' is not the url of a queue, expected a path of /<account-id>/<queue-name>", queue)
+		}
+		return QueueReference{Name: path[1], URL: queue, AccountID: path[0], Region: getQueueURLRegion(parsed.Hostname())}, nil
+	}
+
+	if queue == "" || strings.ContainsAny(queue, ":/") {
+		return QueueReference{}, errors.Errorf("'%v' is not a queue name, url or arn", queue)
+	}
+	return QueueReference{Name: queue}, nil
+}
+
+// getQueueURLRegion returns the region of a queue from the host of its url, sqs.<region>.amazonaws.com or the legacy
+// <region>.queue.amazonaws.com, custom endpoints such as VPC endpoints do not carry a known region
+func getQueueURLRegion(host string) string {
+	labels := strings.Split(host, ".")
+	if len(labels) < 4 || labels[len(labels)-2] != "amazonaws" {
+		return ""
+	}
+	switch {
+	case labels[0] == "sqs":
+		return labels[1]
+	case labels[1] == "queue":
+		return labels[0]
+	}
+	return ""
+}
+
+// getQueueURL returns the url of the queue lifecycle-manager consumes, queues given by arn are resolved in the
+// account owning them, which must allow sqs:GetQueueUrl in the queue's resource policy
+func getQueueURL(s sqsiface.SQSAPI, ctx *ManagerContext) string {
+	if ctx.QueueURL != "" {
+		return ctx.QueueURL
+	}
+	if ctx.QueueOwnerAccountID == "" {
+		return getQueueURLByName(s, ctx.QueueName)
+	}
+
+	out, err := s.GetQueueUrl(&sqs.GetQueueUrlInput{
+		QueueName:              aws.String(ctx.QueueName),
+		QueueOwnerAWSAccountId: aws.String(ctx.QueueOwnerAccountID),
+	})
+	if err != nil {
+		log.Fatalf("unable to find queue %v of account %v: %v", ctx.QueueName, ctx.QueueOwnerAccountID, err)
+	}
+	return aws.StringValue(out.QueueUrl)
+}
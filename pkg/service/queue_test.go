@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+)
+
+func Test_ParseQueueReference(t *testing.T) {
+	t.Log("Test_ParseQueueReference: should parse queue names, urls and arns")
+	tests := []struct {
+		queue    string
+		expected QueueReference
+		valid    bool
+	}{
+		{"my-queue", QueueReference{Name: "my-queue"}, true},
+		{"https://sqs.us-east-1.amazonaws.com/111111111111/my-queue", QueueReference{Name: "my-queue", URL: "https://sqs.us-east-1.amazonaws.com/111111111111/my-queue", AccountID: "111111111111", Region: "us-east-1"}, true},
+		{"https://eu-west-1.queue.amazonaws.com/111111111111/my-queue", QueueReference{Name: "my-queue", URL: "https://eu-west-1.queue.amazonaws.com/111111111111/my-queue", AccountID: "111111111111", Region: "eu-west-1"}, true},
+		{"http://localhost:4566/000000000000/my-queue", QueueReference{Name: "my-queue", URL: "http://localhost:4566/000000000000/my-queue", AccountID: "000000000000"}, true},
+		{"arn:aws:sqs:us-east-1:111111111111:my-queue.fifo", QueueReference{Name: "my-queue.fifo", AccountID: "111111111111", Region: "us-east-1"}, true},
+		{"arn:aws:sns:us-east-1:111111111111:my-topic", QueueReference{}, false},
+		{"https://sqs.us-east-1.amazonaws.com/my-queue", QueueReference{}, false},
+		{"my/queue", QueueReference{}, false},
+		{"", QueueReference{}, false},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseQueueReference(tc.queue)
+		if (err == nil) != tc.valid {
+			t.Fatalf("%v: expected valid: %v, got error: %v", tc.queue, tc.valid, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("%v: expected reference: %+v, got: %+v", tc.queue, tc.expected, got)
+		}
+	}
+}
+
+func Test_GetQueueURL(t *testing.T) {
+	t.Log("Test_GetQueueURL: should use queue urls as is and resolve queues of another account by their owner")
+	stubber := &stubSQS{FakeQueueName: "my-queue"}
+
+	ctx := _newBasicContext()
+	ctx.QueueName = "my-queue"
+	ctx.QueueURL = "https://sqs.us-east-1.amazonaws.com/111111111111/my-queue"
+	if url := getQueueURL(stubber, &ctx); url != ctx.QueueURL || stubber.timesCalledGetQueueUrl != 0 {
+		t.Fatalf("expected url: %v without resolving it, got: %v (%v calls)", ctx.QueueURL, url, stubber.timesCalledGetQueueUrl)
+	}
+
+	ctx.QueueURL = ""
+	ctx.QueueOwnerAccountID = "111111111111"
+	if url := getQueueURL(stubber, &ctx); url == "" || stubber.timesCalledGetQueueUrl != 1 {
+		t.Fatalf("expected url to be resolved, got: %v (%v calls)", url, stubber.timesCalledGetQueueUrl)
+	}
+}
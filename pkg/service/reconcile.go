@@ -0,0 +1,279 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+// ReconciledRequestPrefix is the request id prefix of events synthesized for waiting instances
+const ReconciledRequestPrefix = "reconciled"
+
+// getWaitingInstanceMessages synthesizes messages for instances waiting on a termination hook notifying the queue,
+// instances in skip already have an event and are ignored
+func (mgr *Manager) getWaitingInstanceMessages(queueURL string, skip map[string]bool) ([]*sqs.Message, error) {
+	waiting, err := getWaitingInstances(mgr.authenticator.ScalingGroupClient)
+	if err != nil {
+		return []*sqs.Message{}, err
+	}
+	return mgr.newWaitingInstanceMessages(queueURL, waiting, skip)
+}
+
+// newWaitingInstanceMessages synthesizes messages for the waiting instances of scaling groups whose termination hook notifies the queue
+func (mgr *Manager) newWaitingInstanceMessages(queueURL string, waiting map[string][]string, skip map[string]bool) ([]*sqs.Message, error) {
+	var (
+		auth     = mgr.authenticator
+		messages = []*sqs.Message{}
+	)
+
+	if len(waiting) == 0 {
+		return messages, nil
+	}
+
+	queueARN, err := getQueueARN(auth.SQSClient, queueURL)
+	if err != nil {
+		return messages, err
+	}
+
+	for scalingGroup, instances := range waiting {
+		hook, ok, err := getQueueTerminationHook(auth.ScalingGroupClient, scalingGroup, queueARN)
+		if err != nil {
+			log.Errorf("failed to get lifecycle hooks of %v: %v", scalingGroup, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		for _, instanceID := range instances {
+			if skip[instanceID] {
+				continue
+			}
+			message, err := newReconciledMessage(scalingGroup, hook, instanceID)
+			if err != nil {
+				return messages, err
+			}
+			log.Infof("%v> instance is waiting on %v without an event, reconciling", instanceID, aws.StringValue(hook.LifecycleHookName))
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+// newReconciledMessage returns a message equivalent to the notification of a termination hook
+func newReconciledMessage(scalingGroup string, hook *autoscaling.LifecycleHook, instanceID string) (*sqs.Message, error) {
+	now := time.Now()
+	event := &LifecycleEvent{
+		LifecycleHookName:    aws.StringValue(hook.LifecycleHookName),
+		NotificationMetadata: aws.StringValue(hook.NotificationMetadata),
+		RequestID:            fmt.Sprintf("%v-%v-%v", ReconciledRequestPrefix, instanceID, now.Unix()),
+		LifecycleTransition:  TerminationEventName,
+		AutoScalingGroupName: scalingGroup,
+		EC2InstanceID:        instanceID,
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqs.Message{
+		MessageId: aws.String(event.RequestID),
+		Body:      aws.String(string(body)),
+		Attributes: map[string]*string{
+			sqs.MessageSystemAttributeNameSentTimestamp: aws.String(strconv.FormatInt(now.UnixMilli(), 10)),
+		},
+	}, nil
+}
+
+// startReconciler periodically re-adopts orphaned events and cleans up stale in-progress annotations of the consumed queues
+func (mgr *Manager) startReconciler() {
+	var (
+		interval = time.Duration(mgr.context.ReconcileIntervalSeconds) * time.Second
+	)
+
+	if interval <= 0 {
+		return
+	}
+
+	for {
+		time.Sleep(interval)
+		for _, queueURL := range mgr.queueURLs() {
+			mgr.reconcile(queueURL)
+		}
+	}
+}
+
+func (mgr *Manager) reconcile(queueURL string) {
+	var (
+		metrics = mgr.metrics
+	)
+
+	messages, staleNodes, err := mgr.getOrphanedMessages(queueURL)
+	if err != nil {
+		log.Errorf("failed to reconcile work queue: %v", err)
+		return
+	}
+
+	for _, node := range staleNodes {
+		log.Infof("clearing stale in-progress annotation of node/%v", node.name)
+		mgr.clearResumeState(node.kubeClient, node.name)
+	}
+
+	for _, message := range messages {
+		event, err := mgr.newEvent(message, queueURL)
+		if err != nil {
+			if !mgr.SkipMessage(err, event) {
+				mgr.RejectEvent(err, event)
+			}
+			continue
+		}
+		metrics.AddCounter(ReconciledEventsTotalMetric, eventLabels(event), 1)
+		mgr.dispatchEvent(event)
+	}
+}
+
+// getResumableMessages returns the messages of the in-progress events annotated on nodes of the queue. Events of
+// instances which are no longer waiting on their termination hook, because they terminated or the hook timed out, are
+// abandoned and the annotation of their node is cleared rather than draining a node which is gone
+func (mgr *Manager) getResumableMessages(queueURL string) []*sqs.Message {
+	var (
+		auth     = mgr.authenticator
+		messages = []*sqs.Message{}
+	)
+
+	annotated := make(map[clusterNode]map[string]string)
+	for _, kubeClient := range mgr.kubeClients() {
+		nodes, err := getNodesByAnnotationKeys(kubeClient, InProgressAnnotationKey, QueueNameAnnotationKey)
+		if err != nil {
+			log.Errorf("failed to resume in progress events: %v", err)
+		}
+		for nodeName, annotations := range nodes {
+			annotated[clusterNode{name: nodeName, kubeClient: kubeClient}] = annotations
+		}
+	}
+
+	for node, annotations := range annotated {
+		if !mgr.isQueueAnnotation(annotations[QueueNameAnnotationKey], queueURL) {
+			continue
+		}
+		sqsMessage := annotations[InProgressAnnotationKey]
+		if sqsMessage == "" {
+			continue
+		}
+		log.Infof("trying to resume termination of node/%v", node.name)
+
+		message, err := mgr.decodeResumeState(node.kubeClient, sqsMessage)
+		if err != nil {
+			log.Errorf("failed to resume in progress events: %v", err)
+			continue
+		}
+
+		event, err := readMessage(message, queueURL)
+		if err != nil {
+			log.Errorf("failed to resume in progress events: %v", err)
+			continue
+		}
+
+		instanceID := event.EC2InstanceID
+		state, found, err := getInstanceLifecycleState(auth.ScalingGroupClient, instanceID)
+		if err != nil {
+			log.Warnf("%v> failed to get lifecycle state, resuming in-progress event of node/%v: %v", instanceID, node.name, err)
+		} else if !found || state != autoscaling.LifecycleStateTerminatingWait {
+			if !found {
+				state = autoscaling.LifecycleStateTerminated
+			}
+			log.Infof("%v> instance is %v, abandoning in-progress event of node/%v", instanceID, state, node.name)
+			mgr.clearResumeState(node.kubeClient, node.name)
+			continue
+		}
+
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// getOrphanedMessages cross checks the work queue against scaling group lifecycle states and node annotations, it returns
+// messages for instances waiting on a termination hook of the queue which are not being processed, and the names of nodes
+// whose in-progress annotation belongs to an instance which is no longer waiting
+func (mgr *Manager) getOrphanedMessages(queueURL string) ([]*sqs.Message, []clusterNode, error) {
+	var (
+		auth       = mgr.authenticator
+		messages   = []*sqs.Message{}
+		staleNodes = []clusterNode{}
+	)
+
+	waiting, err := getWaitingInstances(auth.ScalingGroupClient)
+	if err != nil {
+		return messages, staleNodes, err
+	}
+
+	waitingInstances := make(map[string]bool)
+	for _, instances := range waiting {
+		for _, instanceID := range instances {
+			waitingInstances[instanceID] = true
+		}
+	}
+
+	annotated := make(map[clusterNode]map[string]string)
+	for _, kubeClient := range mgr.kubeClients() {
+		nodes, err := getNodesByAnnotationKeys(kubeClient, InProgressAnnotationKey, QueueNameAnnotationKey)
+		if err != nil {
+			return messages, staleNodes, err
+		}
+		for nodeName, annotations := range nodes {
+			annotated[clusterNode{name: nodeName, kubeClient: kubeClient}] = annotations
+		}
+	}
+
+	// the work queue is read last so that events completing during the scan are not treated as orphaned
+	skip := mgr.queuedInstances()
+
+	for node, annotations := range annotated {
+		nodeName := node.name
+		if !mgr.isQueueAnnotation(annotations[QueueNameAnnotationKey], queueURL) {
+			continue
+		}
+		sqsMessage := annotations[InProgressAnnotationKey]
+		if sqsMessage == "" {
+			continue
+		}
+
+		message, err := mgr.decodeResumeState(node.kubeClient, sqsMessage)
+		if err != nil {
+			log.Errorf("failed to read in-progress annotation of node/%v: %v", nodeName, err)
+			continue
+		}
+
+		event, err := readMessage(message, queueURL)
+		if err != nil {
+			log.Errorf("failed to read in-progress annotation of node/%v: %v", nodeName, err)
+			continue
+		}
+
+		instanceID := event.EC2InstanceID
+		switch {
+		case skip[instanceID]:
+			continue
+		case waitingInstances[instanceID]:
+			log.Infof("%v> in-progress event of node/%v is not being processed, re-adopting", instanceID, nodeName)
+			messages = append(messages, message)
+			skip[instanceID] = true
+		default:
+			staleNodes = append(staleNodes, node)
+		}
+	}
+
+	synthesized, err := mgr.newWaitingInstanceMessages(queueURL, waiting, skip)
+	if err != nil {
+		return messages, staleNodes, err
+	}
+
+	return append(messages, synthesized...), staleNodes, nil
+}
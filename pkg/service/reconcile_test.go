@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_GetWaitingInstanceMessages(t *testing.T) {
+	t.Log("Test_GetWaitingInstanceMessages: should synthesize messages for waiting instances of hooks notifying the queue")
+	var (
+		queueARN = "arn:aws:sqs:us-west-2:123456789012:my-queue"
+	)
+
+	asgStubber := &stubAutoscaling{
+		scalingGroups: []*autoscaling.Group{
+			{
+				AutoScalingGroupName: aws.String("my-asg"),
+				Instances: []*autoscaling.Instance{
+					{InstanceId: aws.String("i-111111111111"), LifecycleState: aws.String(autoscaling.LifecycleStateTerminatingWait)},
+					{InstanceId: aws.String("i-222222222222"), LifecycleState: aws.String(autoscaling.LifecycleStateTerminatingWait)},
+					{InstanceId: aws.String("i-333333333333"), LifecycleState: aws.String(autoscaling.LifecycleStateInService)},
+				},
+			},
+		},
+		lifecycleHooks: []*autoscaling.LifecycleHook{
+			{
+				LifecycleHookName:     aws.String("my-launch-hook"),
+				LifecycleTransition:   aws.String(LaunchEventName),
+				NotificationTargetARN: aws.String(queueARN),
+			},
+			{
+				LifecycleHookName:     aws.String("my-hook"),
+				LifecycleTransition:   aws.String(TerminationEventName),
+				NotificationTargetARN: aws.String(queueARN),
+			},
+		},
+	}
+
+	sqsStubber := &stubSQS{
+		FakeQueueAttributes: map[string]*string{
+			sqs.QueueAttributeNameQueueArn: aws.String(queueARN),
+		},
+	}
+
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		SQSClient:          sqsStubber,
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	mgr := New(auth, _newBasicContext())
+
+	messages, err := mgr.getWaitingInstanceMessages("some-queue", map[string]bool{"i-222222222222": true})
+	if err != nil {
+		t.Fatalf("getWaitingInstanceMessages: expected error not to have occured, %v", err)
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("expected messages: %v, got: %v", 1, len(messages))
+	}
+
+	event := &LifecycleEvent{}
+	if err := json.Unmarshal([]byte(aws.StringValue(messages[0].Body)), event); err != nil {
+		t.Fatalf("json.Unmarshal: expected error not to have occured, %v", err)
+	}
+
+	if event.EC2InstanceID != "i-111111111111" || event.LifecycleHookName != "my-hook" || event.AutoScalingGroupName != "my-asg" {
+		t.Fatalf("expected reconciled event for i-111111111111 on my-hook, got: %+v", event)
+	}
+
+	asgStubber.lifecycleHooks[1].NotificationTargetARN = aws.String("arn:aws:sqs:us-west-2:123456789012:other-queue")
+	messages, err = mgr.getWaitingInstanceMessages("some-queue", map[string]bool{})
+	if err != nil {
+		t.Fatalf("getWaitingInstanceMessages: expected error not to have occured, %v", err)
+	}
+
+	if len(messages) != 0 {
+		t.Fatalf("expected messages for hooks of other queues: %v, got: %v", 0, len(messages))
+	}
+}
+
+func _newInProgressNode(t *testing.T, name, instanceID string) *v1.Node {
+	message, err := newReconciledMessage("my-asg", &autoscaling.LifecycleHook{LifecycleHookName: aws.String("my-hook")}, instanceID)
+	if err != nil {
+		t.Fatalf("newReconciledMessage: expected error not to have occured, %v", err)
+	}
+	annotation, err := serializeMessage(message)
+	if err != nil {
+		t.Fatalf("serializeMessage: expected error not to have occured, %v", err)
+	}
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				InProgressAnnotationKey: string(annotation),
+				QueueNameAnnotationKey:  "my-queue",
+			},
+		},
+	}
+}
+
+func Test_GetOrphanedMessages(t *testing.T) {
+	t.Log("Test_GetOrphanedMessages: should re-adopt waiting instances missing from the work queue and report stale annotations")
+	var (
+		queueARN = "arn:aws:sqs:us-west-2:123456789012:my-queue"
+	)
+
+	asgStubber := &stubAutoscaling{
+		scalingGroups: []*autoscaling.Group{
+			{
+				AutoScalingGroupName: aws.String("my-asg"),
+				Instances: []*autoscaling.Instance{
+					{InstanceId: aws.String("i-111111111111"), LifecycleState: aws.String(autoscaling.LifecycleStateTerminatingWait)},
+					{InstanceId: aws.String("i-333333333333"), LifecycleState: aws.String(autoscaling.LifecycleStateTerminatingWait)},
+					{InstanceId: aws.String("i-444444444444"), LifecycleState: aws.String(autoscaling.LifecycleStateTerminatingWait)},
+				},
+			},
+		},
+		lifecycleHooks: []*autoscaling.LifecycleHook{
+			{
+				LifecycleHookName:     aws.String("my-hook"),
+				LifecycleTransition:   aws.String(TerminationEventName),
+				NotificationTargetARN: aws.String(queueARN),
+			},
+		},
+	}
+
+	sqsStubber := &stubSQS{
+		FakeQueueAttributes: map[string]*string{
+			sqs.QueueAttributeNameQueueArn: aws.String(queueARN),
+		},
+	}
+
+	// node-1 is orphaned, node-2 belongs to an instance which is no longer waiting
+	kubeClient := fake.NewSimpleClientset(
+		_newInProgressNode(t, "node-1", "i-111111111111"),
+		_newInProgressNode(t, "node-2", "i-222222222222"),
+	)
+
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		SQSClient:          sqsStubber,
+		KubernetesClient:   kubeClient,
+	}
+	mgr := New(auth, _newBasicContext())
+	mgr.enqueue(&LifecycleEvent{EC2InstanceID: "i-444444444444"})
+
+	messages, staleNodes, err := mgr.getOrphanedMessages("some-queue")
+	if err != nil {
+		t.Fatalf("getOrphanedMessages: expected error not to have occured, %v", err)
+	}
+
+	if len(staleNodes) != 1 || staleNodes[0].name != "node-2" {
+		t.Fatalf("expected stale nodes: %v, got: %v", []string{"node-2"}, staleNodes)
+	}
+
+	instances := []string{}
+	for _, message := range messages {
+		event := &LifecycleEvent{}
+		if err := json.Unmarshal([]byte(aws.StringValue(message.Body)), event); err != nil {
+			t.Fatalf("json.Unmarshal: expected error not to have occured, %v", err)
+		}
+		instances = append(instances, event.EC2InstanceID)
+	}
+
+	if len(instances) != 2 || instances[0] != "i-111111111111" || instances[1] != "i-333333333333" {
+		t.Fatalf("expected orphaned instances: %v, got: %v", []string{"i-111111111111", "i-333333333333"}, instances)
+	}
+}
+
+func Test_GetResumableMessages(t *testing.T) {
+	t.Log("Test_GetResumableMessages: should resume in-progress events of waiting instances and abandon those of terminated instances")
+	asgStubber := &stubAutoscaling{
+		autoScalingInstances: []*autoscaling.InstanceDetails{
+			{AutoScalingGroupName: aws.String("my-asg"), InstanceId: aws.String("i-111111111111"), LifecycleState: aws.String(autoscaling.LifecycleStateTerminatingWait)},
+			{AutoScalingGroupName: aws.String("my-asg"), InstanceId: aws.String("i-222222222222"), LifecycleState: aws.String(autoscaling.LifecycleStateTerminatingProceed)},
+		},
+	}
+
+	// node-2's hook timed out and node-3's instance is gone
+	kubeClient := fake.NewSimpleClientset(
+		_newInProgressNode(t, "node-1", "i-111111111111"),
+		_newInProgressNode(t, "node-2", "i-222222222222"),
+		_newInProgressNode(t, "node-3", "i-333333333333"),
+	)
+
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		KubernetesClient:   kubeClient,
+	}
+	mgr := New(auth, _newBasicContext())
+
+	messages := mgr.getResumableMessages("some-queue")
+	if len(messages) != 1 {
+		t.Fatalf("expected resumed messages: %v, got: %v", 1, len(messages))
+	}
+	event := &LifecycleEvent{}
+	if err := json.Unmarshal([]byte(aws.StringValue(messages[0].Body)), event); err != nil {
+		t.Fatalf("json.Unmarshal: expected error not to have occured, %v", err)
+	}
+	if event.EC2InstanceID != "i-111111111111" {
+		t.Fatalf("expected resumed instance: %v, got: %v", "i-111111111111", event.EC2InstanceID)
+	}
+
+	for nodeName, expected := range map[string]bool{"node-1": true, "node-2": false, "node-3": false} {
+		node, err := kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get node %v: %v", nodeName, err)
+		}
+		if annotated := node.Annotations[InProgressAnnotationKey] != ""; annotated != expected {
+			t.Fatalf("expected node/%v in-progress annotation: %v, got: %v", nodeName, expected, annotated)
+		}
+	}
+}
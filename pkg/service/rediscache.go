@@ -0,0 +1,316 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// RedisCacheTimeout is the timeout of redis commands, AWS API calls go through uncached when it is exceeded
+	RedisCacheTimeout = 2 * time.Second
+	// RedisScanCount is the number of keys scanned per SCAN call while flushing caches
+	RedisScanCount = 500
+)
+
+type redisCacheContextKey int
+
+const (
+	redisCacheHitKey redisCacheContextKey = iota
+	redisCacheEntryKey
+)
+
+// RedisCacheOptions configures the redis server a RedisCache is stored in
+type RedisCacheOptions struct {
+	Address  string
+	Password string
+	DB       int
+	// KeyPrefix namespaces the keys of the cache, replicas sharing cached responses must use the same prefix
+	KeyPrefix string
+}
+
+// RedisCache is a ResponseCache stored in redis, so that replicas share the responses of describe calls. Only
+// operations with a TTL set are cached, and calls go through uncached while redis cannot be reached. Commands are
+// sent over a pool of connections, so that concurrent calls do not wait for each other
+type RedisCache struct {
+	sync.RWMutex
+	client      *redis.Client
+	keyPrefix   string
+	specificTTL map[string]time.Duration
+	mutating    map[string]bool
+	metrics     *cacheMetrics
+}
+
+// redisCacheEntry is a cached HTTP response
+type redisCacheEntry struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// NewRedisCache returns a ResponseCache stored in the redis server of opts
+func NewRedisCache(opts RedisCacheOptions) *RedisCache {
+	return &RedisCache{
+		client: redis.NewClient(&redis.Options{
+			Addr:         opts.Address,
+			Password:     opts.Password,
+			DB:           opts.DB,
+			DialTimeout:  RedisCacheTimeout,
+			ReadTimeout:  RedisCacheTimeout,
+			WriteTimeout: RedisCacheTimeout,
+			// commands are not retried, calls go through uncached instead
+			MaxRetries:            -1,
+			ContextTimeoutEnabled: true,
+		}),
+		keyPrefix:   opts.KeyPrefix,
+		specificTTL: make(map[string]time.Duration),
+		mutating:    make(map[string]bool),
+	}
+}
+
+func cacheName(serviceName, operationName string) string {
+	return serviceName + "." + operationName
+}
+
+// isCachableOperation mirrors the in-memory cache, only describe, list and get calls are cached
+func isCachableOperation(operationName string) bool {
+	return strings.HasPrefix(operationName, "Describe") ||
+		strings.HasPrefix(operationName, "List") ||
+		strings.HasPrefix(operationName, "Get")
+}
+
+func (c *RedisCache) SetCacheTTL(serviceName, operationName string, ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.specificTTL[cacheName(serviceName, operationName)] = ttl
+}
+
+func (c *RedisCache) SetCacheMutating(serviceName, operationName string, isMutating bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.mutating[cacheName(serviceName, operationName)] = isMutating
+}
+
+func (c *RedisCache) NewCacheCollector(namespace string) prometheus.Collector {
+	c.Lock()
+	defer c.Unlock()
+	c.metrics = newCacheMetrics(namespace)
+	return c.metrics
+}
+
+func (c *RedisCache) ttl(r *request.Request) time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+	return c.specificTTL[cacheName(r.ClientInfo.ServiceName, r.Operation.Name)]
+}
+
+// isMutating mirrors the in-memory cache, calls are assumed to modify the resources of their service by default
+func (c *RedisCache) isMutating(r *request.Request) bool {
+	c.RLock()
+	defer c.RUnlock()
+	if mutating, ok := c.mutating[cacheName(r.ClientInfo.ServiceName, r.Operation.Name)]; ok {
+		return mutating
+	}
+	return true
+}
+
+// key returns the key of the response to a request, the parameters are hashed to keep keys short
+func (c *RedisCache) key(r *request.Request) string {
+	params := sha256.Sum256([]byte(awsutil.Prettify(r.Params)))
+	return fmt.Sprintf("%v:%v:%v:%x", c.keyPrefix, cacheName(r.ClientInfo.ServiceName, r.Operation.Name), aws.StringValue(r.Config.Region), params)
+}
+
+func isRedisCacheHit(ctx context.Context) bool {
+	return ctx.Value(redisCacheHitKey) != nil
+}
+
+func (c *RedisCache) AddCaching(sess *session.Session) {
+	sess.Handlers.Validate.PushFront(func(r *request.Request) {
+		if !isCachableOperation(r.Operation.Name) {
+			if c.isMutating(r) {
+				c.FlushCache(r.ClientInfo.ServiceName)
+			}
+			return
+		}
+		if c.ttl(r) <= 0 {
+			return
+		}
+
+		entry, err := c.get(r.Context(), c.key(r))
+		if err != nil {
+			log.Debugf("failed to get %v response from redis cache: %v", r.Operation.Name, err)
+		}
+		if entry == nil {
+			return
+		}
+		c.metrics.inc(r.ClientInfo.ServiceName, r.Operation.Name, "hit")
+		r.HTTPResponse = &http.Response{
+			StatusCode: entry.StatusCode,
+			Header:     entry.Header,
+			Body:       io.NopCloser(bytes.NewReader(entry.Body)),
+		}
+		r.HTTPRequest = r.HTTPRequest.WithContext(context.WithValue(r.HTTPRequest.Context(), redisCacheHitKey, true))
+	})
+
+	// short circuit sending a request whose response was found in the cache
+	sess.Handlers.Send.PushFront(func(r *request.Request) {})
+	sess.Handlers.Send.AfterEachFn = func(item request.HandlerListRunItem) bool {
+		return !isRedisCacheHit(item.Request.HTTPRequest.Context())
+	}
+
+	sess.Handlers.ValidateResponse.PushFront(func(r *request.Request) {
+		if isRedisCacheHit(r.HTTPRequest.Context()) || !isCachableOperation(r.Operation.Name) || c.ttl(r) <= 0 {
+			return
+		}
+		c.metrics.inc(r.ClientInfo.ServiceName, r.Operation.Name, "miss")
+
+		body, err := io.ReadAll(r.HTTPResponse.Body)
+		if err != nil {
+			log.Debugf("failed to read %v response: %v", r.Operation.Name, err)
+			return
+		}
+		r.HTTPResponse.Body = io.NopCloser(bytes.NewReader(body))
+		entry := &redisCacheEntry{StatusCode: r.HTTPResponse.StatusCode, Header: r.HTTPResponse.Header, Body: body}
+		r.HTTPRequest = r.HTTPRequest.WithContext(context.WithValue(r.HTTPRequest.Context(), redisCacheEntryKey, entry))
+	})
+
+	sess.Handlers.Complete.PushBack(func(r *request.Request) {
+		if r.Error != nil {
+			return
+		}
+		entry, ok := r.HTTPRequest.Context().Value(redisCacheEntryKey).(*redisCacheEntry)
+		if !ok {
+			return
+		}
+		if err := c.set(r.Context(), c.key(r), entry, c.ttl(r)); err != nil {
+			log.Debugf("failed to set %v response in redis cache: %v", r.Operation.Name, err)
+		}
+	})
+}
+
+func (c *RedisCache) get(ctx context.Context, key string) (*redisCacheEntry, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry := &redisCacheEntry{}
+	if err := json.Unmarshal(value, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (c *RedisCache) set(ctx context.Context, key string, entry *redisCacheEntry, ttl time.Duration) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+// FlushCache deletes the cached responses of the caches whose name starts with prefix for every replica
+func (c *RedisCache) FlushCache(prefix string) {
+	var (
+		ctx     = context.Background()
+		pattern = fmt.Sprintf("%v:%v*", c.keyPrefix, prefix)
+		flushed = make(map[string]bool)
+		cursor  uint64
+	)
+	for {
+		keys, next, err := c.client.Scan(ctx, cursor, pattern, int64(RedisScanCount)).Result()
+		if err != nil {
+			log.Warnf("failed to flush redis cache %v: %v", prefix, err)
+			return
+		}
+
+		for _, key := range keys {
+			name := strings.TrimPrefix(key, c.keyPrefix+":")
+			flushed[strings.SplitN(name, ":", 2)[0]] = true
+		}
+		if len(keys) > 0 {
+			if err := c.client.Del(ctx, keys...).Err(); err != nil {
+				log.Warnf("failed to flush redis cache %v: %v", prefix, err)
+				return
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	for name := range flushed {
+		names := strings.SplitN(name, ".", 2)
+		if len(names) == 2 {
+			c.metrics.incFlush(names[0], names[1])
+		}
+	}
+}
+
+// cacheMetrics counts the hits, misses and flushes of a RedisCache with the metrics of the in-memory cache
+type cacheMetrics struct {
+	activity *prometheus.CounterVec
+	flushes  *prometheus.CounterVec
+}
+
+func newCacheMetrics(namespace string) *cacheMetrics {
+	return &cacheMetrics{
+		activity: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "aws_api_cache_activity",
+				Help:      "Cache activity",
+			},
+			[]string{"service", "operation", "action"},
+		),
+		flushes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "aws_api_cache_flushes",
+				Help:      "Cache flushes",
+			},
+			[]string{"service", "operation"},
+		),
+	}
+}
+
+func (m *cacheMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.activity.Describe(ch)
+	m.flushes.Describe(ch)
+}
+
+func (m *cacheMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.activity.Collect(ch)
+	m.flushes.Collect(ch)
+}
+
+func (m *cacheMetrics) inc(serviceName, operationName, action string) {
+	if m != nil {
+		m.activity.WithLabelValues(serviceName, operationName, action).Inc()
+	}
+}
+
+func (m *cacheMetrics) incFlush(serviceName, operationName string) {
+	if m != nil {
+		m.flushes.WithLabelValues(serviceName, operationName).Inc()
+	}
+}
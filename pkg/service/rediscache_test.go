@@ -0,0 +1,103 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+func newCachedELBv2Client(t *testing.T, endpoint string, responseCache ResponseCache) *elbv2.ELBV2 {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-west-2"),
+		Endpoint:    aws.String(endpoint),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	responseCache.AddCaching(sess)
+	return elbv2.New(sess)
+}
+
+func newTargetHealthAPI(calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<DescribeTargetHealthResponse><DescribeTargetHealthResult><TargetHealthDescriptions><member><Target><Id>i-123486890234</Id><Port>80</Port></Target></member></TargetHealthDescriptions></DescribeTargetHealthResult></DescribeTargetHealthResponse>`))
+	}))
+}
+
+func Test_RedisCacheSharedByReplicas(t *testing.T) {
+	t.Log("Test_RedisCacheSharedByReplicas: should serve the responses cached by one replica to another until flushed")
+	var calls int32
+	api := newTargetHealthAPI(&calls)
+	defer api.Close()
+	redis := miniredis.RunT(t)
+
+	clients := make([]*elbv2.ELBV2, 0)
+	caches := make([]*RedisCache, 0)
+	for i := 0; i < 2; i++ {
+		responseCache := NewRedisCache(RedisCacheOptions{Address: redis.Addr(), KeyPrefix: "lifecycle-manager"})
+		responseCache.SetCacheTTL("elasticloadbalancing", "DescribeTargetHealth", time.Minute)
+		caches = append(caches, responseCache)
+		clients = append(clients, newCachedELBv2Client(t, api.URL, responseCache))
+	}
+
+	describe := func(client *elbv2.ELBV2) {
+		out, err := client.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{TargetGroupArn: aws.String("arn")})
+		if err != nil {
+			t.Fatalf("failed to describe target health: %v", err)
+		}
+		if len(out.TargetHealthDescriptions) != 1 || aws.StringValue(out.TargetHealthDescriptions[0].Target.Id) != "i-123486890234" {
+			t.Fatalf("expected target: %v, got: %v", "i-123486890234", out.TargetHealthDescriptions)
+		}
+	}
+
+	describe(clients[0])
+	describe(clients[1])
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected calls: %v, got: %v", 1, got)
+	}
+
+	caches[1].FlushCache("elasticloadbalancing.DescribeTargetHealth")
+	describe(clients[0])
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected calls after flush: %v, got: %v", 2, got)
+	}
+}
+
+func Test_RedisCacheUnavailable(t *testing.T) {
+	t.Log("Test_RedisCacheUnavailable: should call the API uncached while redis cannot be reached")
+	var calls int32
+	api := newTargetHealthAPI(&calls)
+	defer api.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	responseCache := NewRedisCache(RedisCacheOptions{Address: address, KeyPrefix: "lifecycle-manager"})
+	responseCache.SetCacheTTL("elasticloadbalancing", "DescribeTargetHealth", time.Minute)
+	client := newCachedELBv2Client(t, api.URL, responseCache)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{TargetGroupArn: aws.String("arn")}); err != nil {
+			t.Fatalf("failed to describe target health: %v", err)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected calls: %v, got: %v", 2, got)
+	}
+}
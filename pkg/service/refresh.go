@@ -0,0 +1,59 @@
+package service
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+// activeInstanceRefreshStatuses are the statuses of instance refreshes which may still be replacing instances
+var activeInstanceRefreshStatuses = map[string]bool{
+	autoscaling.InstanceRefreshStatusPending:            true,
+	autoscaling.InstanceRefreshStatusInProgress:         true,
+	autoscaling.InstanceRefreshStatusCancelling:         true,
+	autoscaling.InstanceRefreshStatusRollbackInProgress: true,
+}
+
+// getActiveInstanceRefresh returns the id of the instance refresh replacing instances of the scaling group, false is
+// returned if no instance refresh is active
+func getActiveInstanceRefresh(client autoscalingiface.AutoScalingAPI, scalingGroupName string) (string, bool, error) {
+	out, err := client.DescribeInstanceRefreshes(&autoscaling.DescribeInstanceRefreshesInput{
+		AutoScalingGroupName: aws.String(scalingGroupName),
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, refresh := range out.InstanceRefreshes {
+		if activeInstanceRefreshStatuses[aws.StringValue(refresh.Status)] {
+			return aws.StringValue(refresh.InstanceRefreshId), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// isInstanceRefresh returns true if the event's termination is caused by an instance refresh of its scaling group
+func (e *LifecycleEvent) isInstanceRefresh() bool { return e.instanceRefreshID != "" }
+
+// detectInstanceRefresh records the instance refresh active in the scaling group of a terminating instance. Terminations
+// while a refresh is active are attributed to it, including scale-in which happens to run at the same time
+func (mgr *Manager) detectInstanceRefresh(event *LifecycleEvent) {
+	var (
+		asgClient = mgr.authenticator.ScalingGroupClient
+	)
+
+	if event.LifecycleTransition != TerminationEventName || event.AutoScalingGroupName == "" {
+		return
+	}
+
+	refreshID, ok, err := getActiveInstanceRefresh(asgClient, event.AutoScalingGroupName)
+	if err != nil {
+		log.Warnf("%v> failed to describe instance refreshes of %v, assuming termination is not part of one: %v", event.EC2InstanceID, event.AutoScalingGroupName, err)
+		return
+	}
+	if ok {
+		log.Infof("%v> termination is part of instance refresh %v", event.EC2InstanceID, refreshID)
+		event.instanceRefreshID = refreshID
+	}
+}
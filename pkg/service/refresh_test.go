@@ -0,0 +1,83 @@
+package service
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+)
+
+func Test_DetectInstanceRefresh(t *testing.T) {
+	t.Log("Test_DetectInstanceRefresh: should attribute terminations to the active instance refresh of their scaling group")
+	tests := []struct {
+		name       string
+		transition string
+		refreshes  []*autoscaling.InstanceRefresh
+		expected   string
+	}{
+		{
+			name:       "active refresh",
+			transition: TerminationEventName,
+			refreshes: []*autoscaling.InstanceRefresh{
+				{InstanceRefreshId: aws.String("refresh-2"), Status: aws.String(autoscaling.InstanceRefreshStatusInProgress)},
+				{InstanceRefreshId: aws.String("refresh-1"), Status: aws.String(autoscaling.InstanceRefreshStatusSuccessful)},
+			},
+			expected: "refresh-2",
+		},
+		{
+			name:       "completed refresh",
+			transition: TerminationEventName,
+			refreshes: []*autoscaling.InstanceRefresh{
+				{InstanceRefreshId: aws.String("refresh-1"), Status: aws.String(autoscaling.InstanceRefreshStatusSuccessful)},
+			},
+			expected: "",
+		},
+		{
+			name:       "launch",
+			transition: LaunchEventName,
+			refreshes: []*autoscaling.InstanceRefresh{
+				{InstanceRefreshId: aws.String("refresh-2"), Status: aws.String(autoscaling.InstanceRefreshStatusInProgress)},
+			},
+			expected: "",
+		},
+	}
+
+	for _, tc := range tests {
+		mgr := New(Authenticator{ScalingGroupClient: &stubAutoscaling{instanceRefreshes: tc.refreshes}}, _newBasicContext())
+		event := &LifecycleEvent{AutoScalingGroupName: "my-asg", EC2InstanceID: "i-1234567890", LifecycleTransition: tc.transition}
+		mgr.detectInstanceRefresh(event)
+		if event.instanceRefreshID != tc.expected {
+			t.Fatalf("%v: expected instance refresh: %v, got: %v", tc.name, tc.expected, event.instanceRefreshID)
+		}
+		if got, expected := eventLabels(event)["instance_refresh"], strconv.FormatBool(tc.expected != ""); got != expected {
+			t.Fatalf("%v: expected instance_refresh label: %v, got: %v", tc.name, expected, got)
+		}
+	}
+}
+
+func Test_ResolveEventSettingsInstanceRefresh(t *testing.T) {
+	t.Log("Test_ResolveEventSettingsInstanceRefresh: should apply the instance refresh drain concurrency to events of a refresh")
+	stubber := &stubAutoscaling{
+		scalingGroups: []*autoscaling.Group{
+			{
+				AutoScalingGroupName: aws.String("my-asg"),
+				Tags: []*autoscaling.TagDescription{
+					{Key: aws.String(MaxDrainConcurrencyTagKey), Value: aws.String("2")},
+					{Key: aws.String(InstanceRefreshMaxDrainConcurrencyTagKey), Value: aws.String("5")},
+				},
+			},
+		},
+	}
+	mgr := New(Authenticator{ScalingGroupClient: stubber}, _newBasicContext())
+
+	event := &LifecycleEvent{AutoScalingGroupName: "my-asg", EC2InstanceID: "i-1234567890"}
+	if got := mgr.resolveEventSettings(event).MaxDrainConcurrency; got != 2 {
+		t.Fatalf("expected MaxDrainConcurrency of scale-in: %v, got: %v", 2, got)
+	}
+
+	event.instanceRefreshID = "refresh-1"
+	if got := mgr.resolveEventSettings(event).MaxDrainConcurrency; got != 5 {
+		t.Fatalf("expected MaxDrainConcurrency of instance refresh: %v, got: %v", 5, got)
+	}
+}
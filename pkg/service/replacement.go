@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// ReplacementPollInterval defines the interval at which the capacity of a scaling group is checked while waiting
+	// for the replacement of a terminating instance
+	ReplacementPollInterval = 15 * time.Second
+)
+
+// waitForReplacementCapacity waits until the scaling group of the event has as many InService instances with a Ready
+// node as its desired capacity, not counting the terminating instance. Terminations which lowered the desired
+// capacity, such as scale-ins, pass right away
+func (mgr *Manager) waitForReplacementCapacity(event *LifecycleEvent) error {
+	var (
+		ctx        = &mgr.context
+		instanceID = event.EC2InstanceID
+		timeout    = time.Duration(ctx.ReplacementWaitTimeoutSeconds) * time.Second
+		deadline   = time.Now().Add(timeout)
+	)
+
+	if !ctx.WaitForReplacement {
+		return nil
+	}
+
+	for {
+		ready, desired, err := mgr.getScalingGroupReadyCapacity(event)
+		if err != nil {
+			log.Warnf("%v> failed to get capacity of scaling group %v: %v", instanceID, event.AutoScalingGroupName, err)
+		} else if ready >= desired {
+			log.Infof("%v> scaling group %v has %v/%v ready instances", instanceID, event.AutoScalingGroupName, ready, desired)
+			return nil
+		} else {
+			log.Infof("%v> waiting for replacement capacity, scaling group %v has %v/%v ready instances", instanceID, event.AutoScalingGroupName, ready, desired)
+		}
+
+		if time.Now().After(deadline) {
+			return errors.Errorf("scaling group %v did not reach its desired capacity of %v ready instances within %v", event.AutoScalingGroupName, desired, timeout)
+		}
+
+		// stop before the lifecycle hook expires rather than waiting past it
+		if remaining, ok := event.remainingTime(); ok && remaining <= ReplacementPollInterval {
+			return fmt.Errorf("lifecycle hook deadline in %v reached during replacement capacity wait", remaining.Round(time.Second))
+		}
+
+		select {
+		case <-event.Context().Done():
+			return event.contextError("replacement capacity wait")
+		case <-time.After(ReplacementPollInterval):
+		}
+	}
+}
+
+// getScalingGroupReadyCapacity returns the number of InService instances of the event's scaling group whose node is
+// Ready, other than the event's instance, and the desired capacity of the group
+func (mgr *Manager) getScalingGroupReadyCapacity(event *LifecycleEvent) (int64, int64, error) {
+	out, err := mgr.authenticator.ScalingGroupClient.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: aws.StringSlice([]string{event.AutoScalingGroupName}),
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(out.AutoScalingGroups) == 0 {
+		return 0, 0, errors.Errorf("could not find scaling group %v", event.AutoScalingGroupName)
+	}
+	group := out.AutoScalingGroups[0]
+
+	nodes, err := mgr.kubeClient(event).CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to list nodes")
+	}
+	readyInstances := make(map[string]bool)
+	for _, node := range nodes.Items {
+		if isNodeStatusInCondition(node, v1.ConditionTrue) {
+			readyInstances[getNodeInstanceID(node)] = true
+		}
+	}
+
+	var ready int64
+	for _, instance := range group.Instances {
+		id := aws.StringValue(instance.InstanceId)
+		if id == event.EC2InstanceID || aws.StringValue(instance.LifecycleState) != autoscaling.LifecycleStateInService {
+			continue
+		}
+		if readyInstances[id] {
+			ready++
+		}
+	}
+	return ready, aws.Int64Value(group.DesiredCapacity), nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func _newReadyNode(name, instanceID string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/" + instanceID},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func Test_WaitForReplacementCapacity(t *testing.T) {
+	t.Log("Test_WaitForReplacementCapacity: should wait until the scaling group has its desired capacity of ready instances besides the terminating one")
+	ReplacementPollInterval = 10 * time.Millisecond
+	defer func() { ReplacementPollInterval = 15 * time.Second }()
+
+	group := &autoscaling.Group{
+		AutoScalingGroupName: aws.String("my-asg"),
+		DesiredCapacity:      aws.Int64(2),
+		Instances: []*autoscaling.Instance{
+			{InstanceId: aws.String("i-111111111111"), LifecycleState: aws.String(autoscaling.LifecycleStateTerminatingWait)},
+			{InstanceId: aws.String("i-222222222222"), LifecycleState: aws.String(autoscaling.LifecycleStateInService)},
+			{InstanceId: aws.String("i-333333333333"), LifecycleState: aws.String(autoscaling.LifecycleStatePending)},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(
+		_newReadyNode("node-1", "i-111111111111"),
+		_newReadyNode("node-2", "i-222222222222"),
+	)
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{scalingGroups: []*autoscaling.Group{group}},
+		KubernetesClient:   kubeClient,
+	}
+	ctx := _newBasicContext()
+	ctx.WaitForReplacement = true
+	ctx.ReplacementWaitTimeoutSeconds = 1
+	mgr := New(auth, ctx)
+
+	event := &LifecycleEvent{
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-111111111111",
+		LifecycleTransition:  TerminationEventName,
+	}
+
+	if err := mgr.waitForReplacementCapacity(event); err == nil {
+		t.Fatalf("waitForReplacementCapacity: expected error while the replacement is pending")
+	}
+
+	group.Instances[2].LifecycleState = aws.String(autoscaling.LifecycleStateInService)
+	kubeClient.CoreV1().Nodes().Create(context.Background(), _newReadyNode("node-3", "i-333333333333"), metav1.CreateOptions{})
+	if err := mgr.waitForReplacementCapacity(event); err != nil {
+		t.Fatalf("waitForReplacementCapacity: expected error not to have occured, %v", err)
+	}
+
+	// a scale-in lowers the desired capacity, its terminations are not held
+	group.DesiredCapacity = aws.Int64(1)
+	group.Instances = group.Instances[:2]
+	if err := mgr.waitForReplacementCapacity(event); err != nil {
+		t.Fatalf("waitForReplacementCapacity: expected error not to have occured, %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// resumeStateGzipPrefix prefixes in-progress annotations holding the gzip compressed, base64 encoded message
+	resumeStateGzipPrefix = "gzip:"
+	// resumeStateConfigMapPrefix prefixes in-progress annotations referencing the config map holding the message
+	resumeStateConfigMapPrefix = "configmap:"
+	// resumeStateConfigMapKey is the config map key holding the gzip compressed, base64 encoded message
+	resumeStateConfigMapKey = "message"
+)
+
+var (
+	// MaxResumeAnnotationSize is the size in bytes above which the in-progress message of a node is stored in a
+	// config map instead of the node's annotation, annotations of an object are limited to 256KiB in total
+	MaxResumeAnnotationSize = 32 * 1024
+	// ResumeStateConfigMapPrefix is the name prefix of config maps holding the in-progress message of a node, it is
+	// followed by the node's uid
+	ResumeStateConfigMapPrefix = "lifecycle-manager-resume-"
+)
+
+// resumeStateNamespace returns the namespace of the config maps holding in-progress messages
+func (mgr *Manager) resumeStateNamespace() string {
+	if mgr.context.SelfPodNamespace != "" {
+		return mgr.context.SelfPodNamespace
+	}
+	return metav1.NamespaceDefault
+}
+
+// encodeResumeState returns the in-progress annotation of an event's message, the message is compressed and falls
+// back to a config map keyed by the node's uid when it is still too large for an annotation
+func (mgr *Manager) encodeResumeState(kubeClient kubernetes.Interface, node v1.Node, message *sqs.Message) (string, error) {
+	serialized, err := serializeMessage(message)
+	if err != nil {
+		return "", err
+	}
+
+	compressed, err := compressResumeState(serialized)
+	if err != nil {
+		return "", err
+	}
+	if len(resumeStateGzipPrefix)+len(compressed) <= MaxResumeAnnotationSize {
+		return resumeStateGzipPrefix + compressed, nil
+	}
+
+	if node.UID == "" {
+		return "", errors.Errorf("message of %v bytes is too large for an annotation and node/%v has no uid", len(compressed), node.Name)
+	}
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ResumeStateConfigMapPrefix + string(node.UID),
+			Namespace: mgr.resumeStateNamespace(),
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "lifecycle-manager",
+			},
+			Annotations: map[string]string{
+				"lifecycle-manager.keikoproj.io/node": node.Name,
+			},
+		},
+		Data: map[string]string{
+			resumeStateConfigMapKey: compressed,
+		},
+	}
+
+	configMaps := kubeClient.CoreV1().ConfigMaps(configMap.Namespace)
+	_, err = configMaps.Create(context.Background(), configMap, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		_, err = configMaps.Update(context.Background(), configMap, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to store message in configmap %v/%v", configMap.Namespace, configMap.Name)
+	}
+	log.Debugf("stored in-progress message of node/%v in configmap %v/%v", node.Name, configMap.Namespace, configMap.Name)
+	return resumeStateConfigMapPrefix + configMap.Name, nil
+}
+
+// decodeResumeState returns the message of an in-progress annotation, annotations written by previous versions hold
+// the message as plain JSON
+func (mgr *Manager) decodeResumeState(kubeClient kubernetes.Interface, state string) (*sqs.Message, error) {
+	if strings.HasPrefix(state, resumeStateConfigMapPrefix) {
+		name := strings.TrimPrefix(state, resumeStateConfigMapPrefix)
+		configMap, err := kubeClient.CoreV1().ConfigMaps(mgr.resumeStateNamespace()).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get configmap %v", name)
+		}
+		state = resumeStateGzipPrefix + configMap.Data[resumeStateConfigMapKey]
+	}
+	return decodeInlineResumeState(state)
+}
+
+// decodeInlineResumeState returns the message of an in-progress annotation holding the message itself
+func decodeInlineResumeState(state string) (*sqs.Message, error) {
+	if !strings.HasPrefix(state, resumeStateGzipPrefix) {
+		return deserializeMessage(state)
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(state, resumeStateGzipPrefix))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode message")
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress message")
+	}
+	defer reader.Close()
+	serialized, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress message")
+	}
+	return deserializeMessage(string(serialized))
+}
+
+func compressResumeState(serialized []byte) (string, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(serialized); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// clearResumeState removes the in-progress annotations of a node, and the config map holding its message if any
+func (mgr *Manager) clearResumeState(kubeClient kubernetes.Interface, nodeName string) {
+	node, err := kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	if err == nil {
+		state := node.GetAnnotations()[InProgressAnnotationKey]
+		if strings.HasPrefix(state, resumeStateConfigMapPrefix) {
+			name := strings.TrimPrefix(state, resumeStateConfigMapPrefix)
+			err := kubeClient.CoreV1().ConfigMaps(mgr.resumeStateNamespace()).Delete(context.Background(), name, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				log.Warnf("failed to delete configmap %v of node/%v: %v", name, nodeName, err)
+			}
+		}
+	}
+
+	annotateNode(kubeClient, nodeName, map[string]string{
+		InProgressAnnotationKey: "",
+		QueueNameAnnotationKey:  "",
+	})
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_ResumeState(t *testing.T) {
+	t.Log("Test_ResumeState: should compress in-progress messages and fall back to a config map when too large")
+	node := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+			UID:  "8d5e7f3a-uid",
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(&node)
+	ctx := _newBasicContext()
+	ctx.SelfPodNamespace = "lifecycle-manager"
+	mgr := New(Authenticator{KubernetesClient: kubeClient}, ctx)
+
+	message := &sqs.Message{
+		Body:      aws.String(`{"EC2InstanceId":"i-123486890234","LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING"}`),
+		MessageId: aws.String("63f5b5c2-58b3-0574-b7d5-b3162d0268f0"),
+	}
+
+	state, err := mgr.encodeResumeState(kubeClient, node, message)
+	if err != nil {
+		t.Fatalf("encodeResumeState: expected error not to have occured, %v", err)
+	}
+	if !strings.HasPrefix(state, resumeStateGzipPrefix) {
+		t.Fatalf("expected state with prefix: %v, got: %v", resumeStateGzipPrefix, state)
+	}
+	restored, err := mgr.decodeResumeState(kubeClient, state)
+	if err != nil || aws.StringValue(restored.Body) != aws.StringValue(message.Body) {
+		t.Fatalf("expected restored message: %v, got: %v (%v)", aws.StringValue(message.Body), restored, err)
+	}
+
+	// annotations written by previous versions hold the message as plain JSON
+	legacy, _ := serializeMessage(message)
+	restored, err = mgr.decodeResumeState(kubeClient, string(legacy))
+	if err != nil || aws.StringValue(restored.MessageId) != aws.StringValue(message.MessageId) {
+		t.Fatalf("expected restored message: %v, got: %v (%v)", aws.StringValue(message.MessageId), restored, err)
+	}
+
+	maxSize := MaxResumeAnnotationSize
+	MaxResumeAnnotationSize = 16
+	defer func() { MaxResumeAnnotationSize = maxSize }()
+
+	state, err = mgr.encodeResumeState(kubeClient, node, message)
+	if err != nil {
+		t.Fatalf("encodeResumeState: expected error not to have occured, %v", err)
+	}
+	expected := resumeStateConfigMapPrefix + ResumeStateConfigMapPrefix + string(node.UID)
+	if state != expected {
+		t.Fatalf("expected state: %v, got: %v", expected, state)
+	}
+	restored, err = mgr.decodeResumeState(kubeClient, state)
+	if err != nil || aws.StringValue(restored.Body) != aws.StringValue(message.Body) {
+		t.Fatalf("expected restored message: %v, got: %v (%v)", aws.StringValue(message.Body), restored, err)
+	}
+
+	annotateNode(kubeClient, node.Name, map[string]string{InProgressAnnotationKey: state, QueueNameAnnotationKey: "my-queue"})
+	mgr.clearResumeState(kubeClient, node.Name)
+
+	configMaps, _ := kubeClient.CoreV1().ConfigMaps(ctx.SelfPodNamespace).List(context.Background(), metav1.ListOptions{})
+	if len(configMaps.Items) != 0 {
+		t.Fatalf("expected configmaps: %v, got: %v", 0, len(configMaps.Items))
+	}
+	cleared, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), node.Name, metav1.GetOptions{})
+	if cleared.Annotations[InProgressAnnotationKey] != "" {
+		t.Fatalf("expected in-progress annotation to be cleared, got: %v", cleared.Annotations[InProgressAnnotationKey])
+	}
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ListTagsForResourcesBatchSize is the maximum number of hosted zones per ListTagsForResources call
+const ListTagsForResourcesBatchSize = 10
+
+// cleanupDNSRecords removes the A and SRV records pointing at the event's node from the configured hosted zones
+func (mgr *Manager) cleanupDNSRecords(event *LifecycleEvent) error {
+	var (
+		ctx        = &mgr.context
+		client     = mgr.authenticator.Route53Client
+		instanceID = event.EC2InstanceID
+		node       = &event.referencedNode
+		errs       error
+	)
+
+	if client == nil || (len(ctx.Route53ZoneIDs) == 0 && len(ctx.Route53ZoneTagFilters) == 0) {
+		return nil
+	}
+
+	zoneIDs, err := mgr.getCleanupHostedZones()
+	if err != nil {
+		return errors.Wrap(err, "failed to discover hosted zones")
+	}
+
+	for _, zoneID := range zoneIDs {
+		changed, err := cleanupHostedZoneRecords(event.Context(), client, zoneID, node)
+		if err != nil {
+			log.Errorf("%v> failed to remove records from hosted zone %v: %v", instanceID, zoneID, err)
+			errs = errors.Wrapf(err, "failed to remove records from hosted zone %v", zoneID)
+			continue
+		}
+		if changed == 0 {
+			log.Debugf("%v> no records of node/%v found in hosted zone %v", instanceID, node.Name, zoneID)
+			continue
+		}
+		log.Infof("%v> removed %v record sets of node/%v from hosted zone %v", instanceID, changed, node.Name, zoneID)
+		msg := fmt.Sprintf(EventMessageDNSRecordsRemoved, changed, node.Name, zoneID)
+		mgr.publishEvent(event, EventReasonDNSRecordsRemoved, getMessageFields(event, msg))
+	}
+	return errs
+}
+
+// getCleanupHostedZones returns the configured hosted zone ids together with the zones matching the zone tag filters
+func (mgr *Manager) getCleanupHostedZones() ([]string, error) {
+	var (
+		ctx     = &mgr.context
+		client  = mgr.authenticator.Route53Client
+		seen    = make(map[string]bool)
+		zoneIDs = []string{}
+	)
+
+	candidates := ctx.Route53ZoneIDs
+	if len(ctx.Route53ZoneTagFilters) > 0 {
+		tagged, err := getHostedZonesByTags(client, ctx.Route53ZoneTagFilters)
+		if err != nil {
+			return zoneIDs, err
+		}
+		candidates = append(append([]string{}, candidates...), tagged...)
+	}
+
+	for _, id := range candidates {
+		id = trimHostedZoneID(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		zoneIDs = append(zoneIDs, id)
+	}
+	return zoneIDs, nil
+}
+
+// getHostedZonesByTags returns the ids of all hosted zones carrying all filter tags
+func getHostedZonesByTags(client route53iface.Route53API, filters map[string]string) ([]string, error) {
+	var (
+		zoneIDs = []string{}
+		matched = []string{}
+	)
+
+	err := client.ListHostedZonesPages(&route53.ListHostedZonesInput{}, func(page *route53.ListHostedZonesOutput, lastPage bool) bool {
+		for _, zone := range page.HostedZones {
+			zoneIDs = append(zoneIDs, trimHostedZoneID(aws.StringValue(zone.Id)))
+		}
+		return page.NextMarker != nil
+	})
+	if err != nil {
+		return matched, err
+	}
+
+	for start := 0; start < len(zoneIDs); start += ListTagsForResourcesBatchSize {
+		end := start + ListTagsForResourcesBatchSize
+		if end > len(zoneIDs) {
+			end = len(zoneIDs)
+		}
+
+		out, err := client.ListTagsForResources(&route53.ListTagsForResourcesInput{
+			ResourceIds:  aws.StringSlice(zoneIDs[start:end]),
+			ResourceType: aws.String(route53.TagResourceTypeHostedzone),
+		})
+		if err != nil {
+			return matched, err
+		}
+
+		for _, set := range out.ResourceTagSets {
+			tags := make(map[string]string)
+			for _, tag := range set.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+			if matchesTagFilters(tags, filters) {
+				matched = append(matched, aws.StringValue(set.ResourceId))
+			}
+		}
+	}
+	return matched, nil
+}
+
+// cleanupHostedZoneRecords removes the values pointing at a node from the A and SRV records of a hosted zone,
+// record sets left without values are deleted and the number of changed record sets is returned
+func cleanupHostedZoneRecords(ctx context.Context, client route53iface.Route53API, zoneID string, node *v1.Node) (int, error) {
+	var (
+		addresses = getNodeAddresses(node)
+		changes   = []*route53.Change{}
+	)
+
+	if len(addresses) == 0 {
+		return 0, nil
+	}
+
+	input := &route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+	}
+	err := client.ListResourceRecordSetsPagesWithContext(ctx, input, func(page *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
+		for _, set := range page.ResourceRecordSets {
+			if change := newRecordCleanupChange(set, addresses); change != nil {
+				changes = append(changes, change)
+			}
+		}
+		return aws.BoolValue(page.IsTruncated)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(changes) == 0 {
+		return 0, nil
+	}
+
+	_, err = client.ChangeResourceRecordSetsWithContext(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch: &route53.ChangeBatch{
+			Comment: aws.String("lifecycle-manager: remove records of terminating node " + node.Name),
+			Changes: changes,
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(changes), nil
+}
+
+// newRecordCleanupChange returns the change removing the addresses from a record set, or nil if it does not reference them
+func newRecordCleanupChange(set *route53.ResourceRecordSet, addresses map[string]bool) *route53.Change {
+	var (
+		recordType = aws.StringValue(set.Type)
+		remaining  = []*route53.ResourceRecord{}
+	)
+
+	if set.AliasTarget != nil {
+		return nil
+	}
+
+	if recordType != route53.RRTypeA && recordType != route53.RRTypeSrv {
+		return nil
+	}
+
+	for _, record := range set.ResourceRecords {
+		if !addresses[recordTarget(recordType, aws.StringValue(record.Value))] {
+			remaining = append(remaining, record)
+		}
+	}
+
+	if len(remaining) == len(set.ResourceRecords) {
+		return nil
+	}
+
+	if len(remaining) == 0 {
+		return &route53.Change{
+			Action:            aws.String(route53.ChangeActionDelete),
+			ResourceRecordSet: set,
+		}
+	}
+
+	updated := *set
+	updated.ResourceRecords = remaining
+	return &route53.Change{
+		Action:            aws.String(route53.ChangeActionUpsert),
+		ResourceRecordSet: &updated,
+	}
+}
+
+// recordTarget returns the address a record value points at, SRV values are in the form 'priority weight port target'
+func recordTarget(recordType, value string) string {
+	if recordType == route53.RRTypeSrv {
+		fields := strings.Fields(value)
+		if len(fields) != 4 {
+			return ""
+		}
+		value = fields[3]
+	}
+	return strings.ToLower(strings.TrimSuffix(value, "."))
+}
+
+// getNodeAddresses returns the set of IP addresses and host names of a node
+func getNodeAddresses(node *v1.Node) map[string]bool {
+	addresses := make(map[string]bool)
+	for _, address := range node.Status.Addresses {
+		if address.Address == "" {
+			continue
+		}
+		addresses[strings.ToLower(strings.TrimSuffix(address.Address, "."))] = true
+	}
+	return addresses
+}
+
+func trimHostedZoneID(id string) string {
+	return strings.TrimPrefix(id, "/hostedzone/")
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type stubRoute53 struct {
+	route53iface.Route53API
+	hostedZones                         []*route53.HostedZone
+	resourceTagSets                     []*route53.ResourceTagSet
+	resourceRecordSets                  []*route53.ResourceRecordSet
+	changeBatches                       []*route53.ChangeBatch
+	timesCalledListTagsForResources     int
+	timesCalledChangeResourceRecordSets int
+}
+
+func (r *stubRoute53) ListHostedZonesPages(input *route53.ListHostedZonesInput, callback func(*route53.ListHostedZonesOutput, bool) bool) error {
+	callback(&route53.ListHostedZonesOutput{HostedZones: r.hostedZones}, true)
+	return nil
+}
+
+func (r *stubRoute53) ListTagsForResources(input *route53.ListTagsForResourcesInput) (*route53.ListTagsForResourcesOutput, error) {
+	r.timesCalledListTagsForResources++
+	sets := []*route53.ResourceTagSet{}
+	for _, set := range r.resourceTagSets {
+		for _, id := range input.ResourceIds {
+			if aws.StringValue(set.ResourceId) == aws.StringValue(id) {
+				sets = append(sets, set)
+			}
+		}
+	}
+	return &route53.ListTagsForResourcesOutput{ResourceTagSets: sets}, nil
+}
+
+func (r *stubRoute53) ListResourceRecordSetsPages(input *route53.ListResourceRecordSetsInput, callback func(*route53.ListResourceRecordSetsOutput, bool) bool) error {
+	callback(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: r.resourceRecordSets}, true)
+	return nil
+}
+
+func (r *stubRoute53) ListResourceRecordSetsPagesWithContext(ctx aws.Context, input *route53.ListResourceRecordSetsInput, callback func(*route53.ListResourceRecordSetsOutput, bool) bool, opts ...request.Option) error {
+	return r.ListResourceRecordSetsPages(input, callback)
+}
+
+func (r *stubRoute53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	r.timesCalledChangeResourceRecordSets++
+	r.changeBatches = append(r.changeBatches, input.ChangeBatch)
+	return &route53.ChangeResourceRecordSetsOutput{}, nil
+}
+
+func (r *stubRoute53) ChangeResourceRecordSetsWithContext(ctx aws.Context, input *route53.ChangeResourceRecordSetsInput, opts ...request.Option) (*route53.ChangeResourceRecordSetsOutput, error) {
+	return r.ChangeResourceRecordSets(input)
+}
+
+func newRecordSet(name, recordType string, values ...string) *route53.ResourceRecordSet {
+	records := []*route53.ResourceRecord{}
+	for _, value := range values {
+		records = append(records, &route53.ResourceRecord{Value: aws.String(value)})
+	}
+	return &route53.ResourceRecordSet{
+		Name:            aws.String(name),
+		Type:            aws.String(recordType),
+		TTL:             aws.Int64(30),
+		ResourceRecords: records,
+	}
+}
+
+func Test_CleanupHostedZoneRecords(t *testing.T) {
+	t.Log("Test_CleanupHostedZoneRecords: should remove A and SRV values pointing at the node and keep unrelated records")
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "ip-10-0-0-1.us-west-2.compute.internal"},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: v1.NodeInternalDNS, Address: "ip-10-0-0-1.us-west-2.compute.internal"},
+			},
+		},
+	}
+
+	stubber := &stubRoute53{
+		resourceRecordSets: []*route53.ResourceRecordSet{
+			newRecordSet("shared.example.com.", route53.RRTypeA, "10.0.0.1", "10.0.0.2"),
+			newRecordSet("single.example.com.", route53.RRTypeA, "10.0.0.1"),
+			newRecordSet("_svc._tcp.example.com.", route53.RRTypeSrv, "1 10 443 ip-10-0-0-1.us-west-2.compute.internal.", "1 10 443 ip-10-0-0-2.us-west-2.compute.internal."),
+			newRecordSet("other.example.com.", route53.RRTypeA, "10.0.0.3"),
+			newRecordSet("text.example.com.", route53.RRTypeTxt, "\"10.0.0.1\""),
+			{
+				Name:        aws.String("alias.example.com."),
+				Type:        aws.String(route53.RRTypeA),
+				AliasTarget: &route53.AliasTarget{DNSName: aws.String("10.0.0.1")},
+			},
+		},
+	}
+
+	changed, err := cleanupHostedZoneRecords(context.Background(), stubber, "Z123", node)
+	if err != nil {
+		t.Fatalf("cleanupHostedZoneRecords: expected error not to have occured, %v", err)
+	}
+
+	if changed != 3 {
+		t.Fatalf("expected changed record sets: %v, got: %v", 3, changed)
+	}
+
+	if stubber.timesCalledChangeResourceRecordSets != 1 {
+		t.Fatalf("expected timesCalledChangeResourceRecordSets: %v, got: %v", 1, stubber.timesCalledChangeResourceRecordSets)
+	}
+
+	expected := map[string]string{
+		"shared.example.com.":    route53.ChangeActionUpsert,
+		"single.example.com.":    route53.ChangeActionDelete,
+		"_svc._tcp.example.com.": route53.ChangeActionUpsert,
+	}
+	for _, change := range stubber.changeBatches[0].Changes {
+		name := aws.StringValue(change.ResourceRecordSet.Name)
+		if expected[name] != aws.StringValue(change.Action) {
+			t.Fatalf("expected action for %v: %v, got: %v", name, expected[name], aws.StringValue(change.Action))
+		}
+		if aws.StringValue(change.Action) == route53.ChangeActionUpsert && len(change.ResourceRecordSet.ResourceRecords) != 1 {
+			t.Fatalf("expected remaining records for %v: %v, got: %v", name, 1, len(change.ResourceRecordSet.ResourceRecords))
+		}
+	}
+}
+
+func Test_CleanupHostedZoneRecordsNoMatch(t *testing.T) {
+	t.Log("Test_CleanupHostedZoneRecordsNoMatch: should not change records when none point at the node")
+	node := &v1.Node{
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.9"}},
+		},
+	}
+	stubber := &stubRoute53{
+		resourceRecordSets: []*route53.ResourceRecordSet{
+			newRecordSet("other.example.com.", route53.RRTypeA, "10.0.0.3"),
+		},
+	}
+
+	changed, err := cleanupHostedZoneRecords(context.Background(), stubber, "Z123", node)
+	if err != nil {
+		t.Fatalf("cleanupHostedZoneRecords: expected error not to have occured, %v", err)
+	}
+
+	if changed != 0 || stubber.timesCalledChangeResourceRecordSets != 0 {
+		t.Fatalf("expected no changes, got: %v changes and %v calls", changed, stubber.timesCalledChangeResourceRecordSets)
+	}
+}
+
+func Test_GetHostedZonesByTags(t *testing.T) {
+	t.Log("Test_GetHostedZonesByTags: should return hosted zones matching the tag filters in batches")
+	var (
+		zones   = []*route53.HostedZone{}
+		tagSets = []*route53.ResourceTagSet{}
+	)
+
+	for i := 0; i < 12; i++ {
+		id := "Z" + string(rune('A'+i))
+		zones = append(zones, &route53.HostedZone{Id: aws.String("/hostedzone/" + id)})
+		tags := []*route53.Tag{}
+		if i%3 == 0 {
+			tags = append(tags, &route53.Tag{Key: aws.String("kubernetes.io/cluster/test"), Value: aws.String("owned")})
+		}
+		tagSets = append(tagSets, &route53.ResourceTagSet{ResourceId: aws.String(id), Tags: tags})
+	}
+
+	stubber := &stubRoute53{
+		hostedZones:     zones,
+		resourceTagSets: tagSets,
+	}
+
+	matched, err := getHostedZonesByTags(stubber, map[string]string{"kubernetes.io/cluster/test": "owned"})
+	if err != nil {
+		t.Fatalf("getHostedZonesByTags: expected error not to have occured, %v", err)
+	}
+
+	if len(matched) != 4 {
+		t.Fatalf("expected matched zones: %v, got: %v", 4, len(matched))
+	}
+
+	if stubber.timesCalledListTagsForResources != 2 {
+		t.Fatalf("expected timesCalledListTagsForResources: %v, got: %v", 2, stubber.timesCalledListTagsForResources)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ScaleDownDisabledActionIgnore drains nodes with scale-down disabled like any other node
+	ScaleDownDisabledActionIgnore = "ignore"
+	// ScaleDownDisabledActionWarn publishes a warning event and drains nodes with scale-down disabled
+	ScaleDownDisabledActionWarn = "warn"
+	// ScaleDownDisabledActionDelay waits for scale-down to be enabled on the node again before draining it
+	ScaleDownDisabledActionDelay = "delay"
+	// ScaleDownDisabledActionAbandon completes the lifecycle hook of nodes with scale-down disabled with ABANDON
+	// without draining them
+	ScaleDownDisabledActionAbandon = "abandon"
+)
+
+var (
+	// DefaultScaleDownDisabledAnnotationKey is the annotation of cluster-autoscaler disabling the scale-down of a node
+	DefaultScaleDownDisabledAnnotationKey = "cluster-autoscaler.kubernetes.io/scale-down-disabled"
+	// ScaleDownDisabledCheckInterval defines the interval at which a delayed event checks whether scale-down of its
+	// node was enabled again
+	ScaleDownDisabledCheckInterval = 30 * time.Second
+)
+
+// IsValidScaleDownDisabledAction returns true if the scale-down disabled action is supported
+func IsValidScaleDownDisabledAction(action string) bool {
+	switch action {
+	case ScaleDownDisabledActionIgnore, ScaleDownDisabledActionWarn, ScaleDownDisabledActionDelay, ScaleDownDisabledActionAbandon:
+		return true
+	}
+	return false
+}
+
+// scaleDownDisabledAnnotationKey returns the annotation disabling the scale-down of a node
+func (mgr *Manager) scaleDownDisabledAnnotationKey() string {
+	if key := mgr.context.ScaleDownDisabledAnnotation; key != "" {
+		return key
+	}
+	return DefaultScaleDownDisabledAnnotationKey
+}
+
+// isScaleDownDisabled returns true if the event's node is annotated to disable its scale-down
+func (mgr *Manager) isScaleDownDisabled(event *LifecycleEvent) bool {
+	return event.referencedNode.GetAnnotations()[mgr.scaleDownDisabledAnnotationKey()] == "true"
+}
+
+// handleScaleDownDisabled applies --scale-down-disabled-action to an event whose node has scale-down disabled, so that
+// such nodes are not silently drained. A terminal error is returned for abandoned events
+func (mgr *Manager) handleScaleDownDisabled(event *LifecycleEvent) error {
+	var (
+		action = mgr.context.ScaleDownDisabledAction
+		node   = event.referencedNode
+	)
+
+	if action == "" || action == ScaleDownDisabledActionIgnore || !mgr.isScaleDownDisabled(event) {
+		return nil
+	}
+
+	log.Warnf("%v> node/%v has scale-down disabled by %v, action: %v", event.EC2InstanceID, node.Name, mgr.scaleDownDisabledAnnotationKey(), action)
+	msg := fmt.Sprintf(EventMessageNodeScaleDownDisabled, node.Name, mgr.scaleDownDisabledAnnotationKey(), action)
+	mgr.publishEvent(event, EventReasonNodeScaleDownDisabled, getMessageFields(event, msg))
+
+	switch action {
+	case ScaleDownDisabledActionAbandon:
+		return NewTerminalError(errors.Errorf("node %v has scale-down disabled", node.Name))
+	case ScaleDownDisabledActionDelay:
+		return mgr.awaitScaleDownEnabled(event)
+	}
+	return nil
+}
+
+// awaitScaleDownEnabled waits until the scale-down of the event's node is enabled again, the lifecycle hook is kept
+// alive by heartbeats meanwhile and the event fails once its max time to process is exceeded
+func (mgr *Manager) awaitScaleDownEnabled(event *LifecycleEvent) error {
+	for mgr.isScaleDownDisabled(event) {
+		select {
+		case <-event.Context().Done():
+			return event.contextError("scale-down disabled wait")
+		case <-time.After(ScaleDownDisabledCheckInterval):
+		}
+
+		node, exists := getNodeByName(mgr.kubeClient(event), event.referencedNode.Name)
+		if !exists {
+			return errors.Errorf("node %v no longer exists", event.referencedNode.Name)
+		}
+		event.SetReferencedNode(node)
+	}
+	log.Infof("%v> scale-down of node/%v is enabled, draining it", event.EC2InstanceID, event.referencedNode.Name)
+	return nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_HandleScaleDownDisabled(t *testing.T) {
+	t.Log("Test_HandleScaleDownDisabled: should apply the scale-down disabled action to nodes with scale-down disabled")
+	disabled := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node-1",
+			Annotations: map[string]string{DefaultScaleDownDisabledAnnotationKey: "true"},
+		},
+	}
+	custom := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node-2",
+			Annotations: map[string]string{"example.com/protected": "true"},
+		},
+	}
+	enabled := v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-3"}}
+
+	tests := []struct {
+		action     string
+		annotation string
+		node       v1.Node
+		terminal   bool
+	}{
+		{ScaleDownDisabledActionIgnore, "", disabled, false},
+		{ScaleDownDisabledActionWarn, "", disabled, false},
+		{ScaleDownDisabledActionAbandon, "", disabled, true},
+		{ScaleDownDisabledActionAbandon, "", enabled, false},
+		{ScaleDownDisabledActionAbandon, "", custom, false},
+		{ScaleDownDisabledActionAbandon, "example.com/protected", custom, true},
+	}
+
+	for _, tc := range tests {
+		ctx := _newBasicContext()
+		ctx.ScaleDownDisabledAction = tc.action
+		ctx.ScaleDownDisabledAnnotation = tc.annotation
+		mgr := New(Authenticator{KubernetesClient: fake.NewSimpleClientset()}, ctx)
+		event := &LifecycleEvent{EC2InstanceID: "i-123486890234"}
+		event.SetReferencedNode(tc.node)
+
+		err := mgr.handleScaleDownDisabled(event)
+		if got := err != nil && ClassifyError(err) == ErrorClassTerminal; got != tc.terminal {
+			t.Fatalf("expected terminal error for action %v on node %v: %v, got: %v", tc.action, tc.node.Name, tc.terminal, err)
+		}
+	}
+}
+
+func Test_AwaitScaleDownEnabled(t *testing.T) {
+	t.Log("Test_AwaitScaleDownEnabled: should wait for scale-down of the node to be enabled again")
+	interval := ScaleDownDisabledCheckInterval
+	ScaleDownDisabledCheckInterval = 10 * time.Millisecond
+	defer func() { ScaleDownDisabledCheckInterval = interval }()
+
+	kubeClient := fake.NewSimpleClientset()
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node-1",
+			Annotations: map[string]string{DefaultScaleDownDisabledAnnotationKey: "true"},
+		},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+
+	ctx := _newBasicContext()
+	ctx.ScaleDownDisabledAction = ScaleDownDisabledActionDelay
+	mgr := New(Authenticator{KubernetesClient: kubeClient}, ctx)
+	event := &LifecycleEvent{EC2InstanceID: "i-123486890234"}
+	event.SetContext(context.WithCancel(context.Background()))
+	event.SetReferencedNode(*node)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		removeNodeAnnotations(kubeClient, "node-1", DefaultScaleDownDisabledAnnotationKey)
+	}()
+	if err := mgr.handleScaleDownDisabled(event); err != nil {
+		t.Fatalf("expected error not to have occured, %v", err)
+	}
+
+	event.SetReferencedNode(*node)
+	event.cancel()
+	if err := mgr.handleScaleDownDisabled(event); err == nil {
+		t.Fatalf("expected error for a cancelled event")
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+var (
+	// SelfTerminationCheckInterval defines the interval at which the event of lifecycle-manager's own node checks
+	// whether the other in-flight events completed
+	SelfTerminationCheckInterval = 5 * time.Second
+)
+
+// isSelfNode returns true if the event terminates the node running lifecycle-manager
+func (mgr *Manager) isSelfNode(event *LifecycleEvent) bool {
+	var (
+		name = mgr.context.SelfNodeName
+	)
+	// events routed to another cluster cannot terminate the node of lifecycle-manager
+	return name != "" && event.kubeClient == nil && event.referencedNode.Name == name
+}
+
+// isSelfTerminating returns true while the node running lifecycle-manager is terminating
+func (mgr *Manager) isSelfTerminating() bool {
+	return atomic.LoadInt32(&mgr.selfTerminating) == 1
+}
+
+// selfDrainPolicy excludes the lifecycle-manager pod from the drain of its own node, so that it keeps running until
+// the lifecycle hook is completed
+func (mgr *Manager) selfDrainPolicy(policy drainPolicy) drainPolicy {
+	var (
+		ctx = &mgr.context
+	)
+	if ctx.SelfPodName == "" || ctx.SelfPodNamespace == "" {
+		return policy
+	}
+	policy.excludedPods = map[string]bool{ctx.SelfPodNamespace + "/" + ctx.SelfPodName: true}
+	return policy
+}
+
+// awaitOtherEvents defers the termination of lifecycle-manager's own node until the other in-flight events completed,
+// polling is paused meanwhile so that no new events are received
+func (mgr *Manager) awaitOtherEvents(event *LifecycleEvent) error {
+	atomic.StoreInt32(&mgr.selfTerminating, 1)
+
+	others := atomic.LoadInt64(&mgr.inFlightEvents) - 1
+	if others <= 0 {
+		return nil
+	}
+
+	log.Infof("%v> node/%v runs lifecycle-manager, waiting for %v other in-flight events to complete", event.EC2InstanceID, event.referencedNode.Name, others)
+	msg := fmt.Sprintf(EventMessageSelfTerminationDeferred, event.referencedNode.Name, others)
+	mgr.publishEvent(event, EventReasonSelfTerminationDeferred, getMessageFields(event, msg))
+
+	for atomic.LoadInt64(&mgr.inFlightEvents) > 1 {
+		select {
+		case <-event.Context().Done():
+			return event.contextError("self termination wait")
+		case <-time.After(SelfTerminationCheckInterval):
+		}
+	}
+	log.Infof("%v> other in-flight events completed, terminating node/%v", event.EC2InstanceID, event.referencedNode.Name)
+	return nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apimachinery_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_IsSelfNode(t *testing.T) {
+	t.Log("Test_IsSelfNode: should match events of the node running lifecycle-manager in its own cluster")
+	ctx := _newBasicContext()
+	ctx.SelfNodeName = "node-1"
+	mgr := New(Authenticator{}, ctx)
+
+	self := &LifecycleEvent{}
+	self.SetReferencedNode(v1.Node{ObjectMeta: apimachinery_v1.ObjectMeta{Name: "node-1"}})
+	if !mgr.isSelfNode(self) {
+		t.Fatalf("expected event of node-1 to be a self node event")
+	}
+
+	other := &LifecycleEvent{}
+	other.SetReferencedNode(v1.Node{ObjectMeta: apimachinery_v1.ObjectMeta{Name: "node-2"}})
+	if mgr.isSelfNode(other) {
+		t.Fatalf("expected event of node-2 not to be a self node event")
+	}
+
+	routed := &LifecycleEvent{kubeClient: fake.NewSimpleClientset()}
+	routed.SetReferencedNode(v1.Node{ObjectMeta: apimachinery_v1.ObjectMeta{Name: "node-1"}})
+	if mgr.isSelfNode(routed) {
+		t.Fatalf("expected event routed to another cluster not to be a self node event")
+	}
+}
+
+func Test_AwaitOtherEvents(t *testing.T) {
+	t.Log("Test_AwaitOtherEvents: should pause polling and wait for the other in-flight events to complete")
+	interval := SelfTerminationCheckInterval
+	SelfTerminationCheckInterval = 10 * time.Millisecond
+	defer func() { SelfTerminationCheckInterval = interval }()
+
+	mgr := New(Authenticator{KubernetesClient: fake.NewSimpleClientset()}, _newBasicContext())
+	event := &LifecycleEvent{}
+	event.SetContext(context.WithCancel(context.Background()))
+
+	// the self node event and another event are in flight
+	atomic.StoreInt64(&mgr.inFlightEvents, 2)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		atomic.AddInt64(&mgr.inFlightEvents, -1)
+	}()
+
+	if err := mgr.awaitOtherEvents(event); err != nil {
+		t.Fatalf("expected error not to have occured, %v", err)
+	}
+
+	if !mgr.isSelfTerminating() {
+		t.Fatalf("expected polling to be paused")
+	}
+
+	atomic.StoreInt64(&mgr.inFlightEvents, 2)
+	event.cancel()
+	if err := mgr.awaitOtherEvents(event); err == nil {
+		t.Fatalf("expected error for a cancelled event")
+	}
+}
+
+func Test_SelfDrainPolicy(t *testing.T) {
+	t.Log("Test_SelfDrainPolicy: should not evict the lifecycle-manager pod from its own node")
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.Fake.Resources = []*apimachinery_v1.APIResourceList{{GroupVersion: "v1"}}
+	node := &v1.Node{
+		ObjectMeta: apimachinery_v1.ObjectMeta{
+			Name: "node-1",
+		},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, apimachinery_v1.CreateOptions{})
+	for _, pod := range []*v1.Pod{
+		{ObjectMeta: apimachinery_v1.ObjectMeta{Name: "pod-1", Namespace: "default"}, Spec: v1.PodSpec{NodeName: "node-1"}},
+		{ObjectMeta: apimachinery_v1.ObjectMeta{Name: "lifecycle-manager-1", Namespace: "lifecycle-manager"}, Spec: v1.PodSpec{NodeName: "node-1"}},
+	} {
+		kubeClient.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, apimachinery_v1.CreateOptions{})
+	}
+
+	ctx := _newBasicContext()
+	ctx.SelfNodeName = "node-1"
+	ctx.SelfPodName = "lifecycle-manager-1"
+	ctx.SelfPodNamespace = "lifecycle-manager"
+	mgr := New(Authenticator{KubernetesClient: kubeClient}, ctx)
+
+	policy := mgr.selfDrainPolicy(drainPolicy{order: EvictionOrderNone, gracePeriodSeconds: 0})
+	err := drainNode(context.Background(), kubeClient, node, 10, 0, 1, policy, nil)
+	if err != nil {
+		t.Fatalf("drainNode: expected error not to have occured, %v", err)
+	}
+
+	if _, err := kubeClient.CoreV1().Pods("default").Get(context.Background(), "pod-1", apimachinery_v1.GetOptions{}); err == nil {
+		t.Fatalf("expected pod-1 to be evicted")
+	}
+	if _, err := kubeClient.CoreV1().Pods("lifecycle-manager").Get(context.Background(), "lifecycle-manager-1", apimachinery_v1.GetOptions{}); err != nil {
+		t.Fatalf("expected lifecycle-manager pod not to be evicted, %v", err)
+	}
+}
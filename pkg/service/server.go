@@ -3,22 +3,26 @@ package service
 import (
 	"context"
 	"fmt"
-	"math/rand"
+	"net/http"
 	"runtime"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/keikoproj/lifecycle-manager/pkg/log"
 	"github.com/keikoproj/lifecycle-manager/pkg/version"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/semaphore"
 )
 
 var (
@@ -40,11 +44,12 @@ var (
 	InProgressAnnotationKey = "lifecycle-manager.keikoproj.io/in-progress"
 	// QueueNameAnnotationKey is the annotation key for saving the queue name for a node
 	QueueNameAnnotationKey = "lifecycle-manager.keikoproj.io/queue-name"
-	// ThreadJitterRangeSeconds configures the jitter range in seconds 0 to N per handler goroutine
-	ThreadJitterRangeSeconds = 30.0
-	// IterationJitterRangeSeconds configures the jitter range in seconds 0 to N per call iteration goroutine
+	// IterationJitterRangeSeconds configures the jitter range in seconds IterationJitterMinSeconds to N between the
+	// deregistrations of the targets of a batch
 	IterationJitterRangeSeconds = 1.5
-	// NodeAgeCacheTTL defines a node age in minutes for which all caches are flushed
+	// IterationJitterMinSeconds is the minimum delay in seconds between the deregistrations of the targets of a batch
+	IterationJitterMinSeconds = 0.5
+	// NodeAgeCacheTTL defines the default node age in minutes for which all caches are flushed
 	NodeAgeCacheTTL = 90
 	// WaiterMinDelay defines the minimum delay of the IEB waiter
 	WaiterMinDelay time.Duration = 10 * time.Second
@@ -52,78 +57,220 @@ var (
 	WaiterMaxDelay time.Duration = 90 * time.Second
 	// WaiterMaxAttempts defines the maximum attempts of the IEB waiter
 	WaiterMaxAttempts uint32 = 120
+	// WaiterDrainingMargin defines how long deregistration waiters keep waiting past the connection draining timeout of
+	// a classic-elb or the deregistration delay of a target group
+	WaiterDrainingMargin time.Duration = 60 * time.Second
+	// WaiterDelayInterval defines the interval at which pending waiters are reported
+	WaiterDelayInterval time.Duration = 180 * time.Second
+	// BackpressureInterval defines the delay before polling again while the in-flight event limit is reached
+	BackpressureInterval = 5 * time.Second
+	// QueueMetricsInterval defines the interval at which queue depth metrics are refreshed
+	QueueMetricsInterval = 30 * time.Second
+	// NodeNotFoundRetryInterval defines the delay before an event whose node is not found yet is received again
+	NodeNotFoundRetryInterval = 30 * time.Second
+	// TransientRetryInterval defines the delay before an event which failed with a transient error is received again
+	TransientRetryInterval = 30 * time.Second
+	// NodeDeletionPollInterval defines the interval at which the instance of a completed event is checked to be
+	// terminating before its node is deleted
+	NodeDeletionPollInterval = 5 * time.Second
+	// NodeDeletionTimeout defines how long to wait for the instance of a completed event to be terminating before its
+	// node is left to be removed by the cloud node lifecycle controller
+	NodeDeletionTimeout = 5 * time.Minute
+	// ErrUntrustedMessage is returned when a message is not sent by an allowed account or sender
+	ErrUntrustedMessage = errors.New("untrusted message")
+	// ErrNodeNotFound is returned when the instance of a termination event is not registered as a node
+	ErrNodeNotFound = errors.New("node not found")
 )
 
 // Start starts the lifecycle-manager service
 func (mgr *Manager) Start() {
 	var (
-		ctx      = &mgr.context
-		metrics  = mgr.metrics
-		kube     = mgr.authenticator.KubernetesClient
-		auth     = mgr.authenticator
-		queueURL = getQueueURLByName(auth.SQSClient, ctx.QueueName)
+		ctx       = &mgr.context
+		metrics   = mgr.metrics
+		auth      = mgr.authenticator
+		queueURLs = mgr.initialQueueURLs()
 	)
 
 	log.Infof("starting lifecycle-manager service v%v", version.Version)
 	log.Infof("region = %v", ctx.Region)
-	log.Infof("queue = %v", ctx.QueueName)
+	log.Infof("cluster name = %v", ctx.ClusterName)
+	for _, cluster := range auth.ClusterClients {
+		log.Infof("routing scaling groups matching %v to cluster %v", cluster.ScalingGroupPattern, cluster.Name)
+	}
+	log.Infof("queues = %v", queueURLs)
+	log.Infof("queue discovery tags = %v, interval seconds = %v", ctx.QueueDiscoveryTags, ctx.QueueDiscoveryIntervalSeconds)
 	log.Infof("polling interval seconds = %v", ctx.PollingIntervalSeconds)
 	log.Infof("max time to process seconds = %v", ctx.MaxTimeToProcessSeconds)
 	log.Infof("node drain timeout seconds = %v", ctx.DrainTimeoutSeconds)
+	log.Infof("node drain failure policy = %v", ctx.DrainFailurePolicy)
+	log.Infof("scaling group max drain concurrency = %v", ctx.ScalingGroupMaxDrainConcurrency)
+	log.Infof("instance refresh max drain concurrency = %v", ctx.InstanceRefreshMaxDrainConcurrency)
+	log.Infof("with volume detach wait = %v", ctx.WithVolumeDetachWait)
+	log.Infof("with pod deletion wait = %v", ctx.WithPodDeletionWait)
+	log.Infof("delete node after complete = %v", ctx.DeleteNodeAfterComplete)
+	log.Infof("wait for replacement = %v, timeout seconds = %v", ctx.WaitForReplacement, ctx.ReplacementWaitTimeoutSeconds)
+	log.Infof("deregister failure policy = %v", ctx.DeregisterFailurePolicy)
 	log.Infof("unknown node drain timeout seconds = %v", ctx.DrainTimeoutUnknownSeconds)
 	log.Infof("node drain retry interval seconds = %v", ctx.DrainRetryIntervalSeconds)
 	log.Infof("node drain retry attempts = %v", ctx.DrainRetryAttempts)
 	log.Infof("with alb deregister = %v", ctx.WithDeregister)
 	log.Infof("deregister target types = %v", ctx.DeregisterTargetTypes)
-
-	// start metrics server
-	log.Infof("starting metrics server on %v%v", MetricsEndpoint, MetricsPort)
+	log.Infof("deregister full scan fallback = %v", ctx.DeregisterFullScanFallback)
+	log.Infof("deregister tag filters = %v", ctx.DeregisterTagFilters)
+	log.Infof("with ip target wait = %v", ctx.WithIPTargetWait)
+	log.Infof("deregister before drain = %v", ctx.DeregisterBeforeDrain)
+	log.Infof("deregister during drain = %v", ctx.DeregisterDuringDrain)
+	log.Infof("route53 zone ids = %v", ctx.Route53ZoneIDs)
+	log.Infof("route53 zone tag filters = %v", ctx.Route53ZoneTagFilters)
+	log.Infof("cloud map namespace tag filters = %v", ctx.CloudMapNamespaceTagFilters)
+	log.Infof("cloud map service tag filters = %v", ctx.CloudMapServiceTagFilters)
+	log.Infof("global accelerator tag filters = %v", ctx.AcceleratorTagFilters)
+	log.Infof("global accelerator dial down seconds = %v", ctx.AcceleratorDialDownSeconds)
+	log.Infof("membership cache ttl = %vs", ctx.MembershipCacheTTLSeconds)
+	log.Infof("membership check concurrency = %v", ctx.MembershipCheckConcurrency)
+	log.Infof("waiter config = %+v", mgr.waiterConfig())
+	log.Infof("backoff jitter = %v", ctx.BackoffJitter)
+	log.Infof("with launch hooks = %v", ctx.WithLaunchHooks)
+	log.Infof("node not found grace seconds = %v", ctx.NodeNotFoundGraceSeconds)
+	log.Infof("validation timeout seconds = %v", ctx.ValidationTimeoutSeconds)
+	log.Infof("lb discovery timeout seconds = %v, deregister timeout seconds = %v, waiter timeout seconds = %v", ctx.LBDiscoveryTimeoutSeconds, ctx.DeregisterTimeoutSeconds, ctx.WaiterTimeoutSeconds)
+	log.Infof("reconcile on start = %v", ctx.ReconcileOnStart)
+	log.Infof("reconcile interval seconds = %v", ctx.ReconcileIntervalSeconds)
+	log.Infof("max in-flight events = %v", ctx.MaxInFlightEvents)
+	log.Infof("worker pool size = %v", ctx.WorkerPoolSize)
+	log.Infof("pollers = %v", ctx.PollerCount)
+	log.Infof("dedup store = %v", ctx.DedupStore)
+	log.Infof("event sinks = %v", ctx.EventSinks)
+	log.Infof("event namespace = %v", ctx.EventNamespace)
+	log.Infof("node scoped events = %v", ctx.NodeScopedEvents)
+	log.Infof("verify sns signature = %v", ctx.VerifySNSSignature)
+	log.Infof("allowed account ids = %v", ctx.AllowedAccountIDs)
+	log.Infof("allowed sender ids = %v", ctx.AllowedSenderIDs)
+	log.Infof("skip node selector = %v", ctx.SkipNodeSelector)
+	log.Infof("skip node action = %v", ctx.SkipNodeAction)
+	log.Infof("self node name = %v", ctx.SelfNodeName)
+	log.Infof("self pod = %v/%v", ctx.SelfPodNamespace, ctx.SelfPodName)
+	log.Infof("with sharding = %v", ctx.WithSharding)
+	log.Infof("history size = %v", ctx.HistorySize)
+	log.Infof("audit table = %v", ctx.AuditTableName)
+	log.Infof("audit retention days = %v", ctx.AuditRetentionDays)
+	log.Infof("with cloudwatch metrics = %v", ctx.WithCloudWatchMetrics)
+	log.Infof("cloudwatch namespace = %v", ctx.CloudWatchNamespace)
+	log.Infof("cloudwatch interval seconds = %v", ctx.CloudWatchIntervalSeconds)
+	log.Infof("statsd address = %v", ctx.StatsDAddress)
+	log.Infof("statsd tags = %v", ctx.StatsDTags)
+
+	// start metrics server, it also serves the event history, the events being processed and flushes caches on demand
+	log.Infof("starting metrics server on %v%v, tls = %v", metrics.endpoint, metrics.address, metrics.tlsCertFile != "")
+	http.Handle(HistoryEndpoint, mgr.history)
+	http.Handle(EventsEndpoint, mgr.eventsHandler())
+	http.Handle(CacheFlushEndpoint, mgr.cacheFlushHandler())
 	go metrics.Start()
 
-	// restore in-progress events if crashed
-	inProgressEvents, err := getNodesByAnnotationKeys(kube, InProgressAnnotationKey, QueueNameAnnotationKey)
-	if err != nil {
-		log.Errorf("failed to resume in progress events: %v", err)
+	// join the shard ring before any event is validated, so that ownership is decided over the live replicas
+	if mgr.shards != nil {
+		if err := mgr.shards.renew(mgr.ctx); err != nil {
+			log.Errorf("failed to join shard ring: %v", err)
+		}
+		go mgr.startShardRing()
 	}
 
-	// messages from in-progress are loaded to stream first
-	for node, annotations := range inProgressEvents {
-		if annotations[QueueNameAnnotationKey] != ctx.QueueName && annotations[QueueNameAnnotationKey] != "" {
-			continue
-		}
-		sqsMessage := annotations[InProgressAnnotationKey]
-		if sqsMessage == "" {
-			continue
-		}
-		log.Infof("trying to resume termination of node/%v", node)
-		message, err := deserializeMessage(sqsMessage)
-		if err != nil {
-			log.Errorf("failed to resume in progress events: %v", err)
+	// start workers before any event is dispatched
+	mgr.startWorkers(ctx.WorkerPoolSize)
+
+	// restore in-progress events if crashed, messages from in-progress are loaded to stream first
+	resumed := make(map[string]bool)
+	for _, queueURL := range queueURLs {
+		for _, message := range mgr.getResumableMessages(queueURL) {
+			event, err := mgr.newEvent(message, queueURL)
+			if err != nil {
+				if !mgr.SkipMessage(err, event) {
+					mgr.RejectEvent(err, event)
+				}
+				continue
+			}
+
+			resumed[event.EC2InstanceID] = true
+			mgr.dispatchEvent(event)
 		}
+	}
 
-		event, err := mgr.newEvent(message, queueURL)
-		if err != nil {
-			mgr.RejectEvent(err, event)
-			continue
+	// synthesize events for waiting instances whose message was lost
+	if ctx.ReconcileOnStart {
+		for _, queueURL := range queueURLs {
+			messages, err := mgr.getWaitingInstanceMessages(queueURL, resumed)
+			if err != nil {
+				log.Errorf("failed to reconcile waiting instances of queue %v: %v", queueURL, err)
+			}
+
+			for _, message := range messages {
+				event, err := mgr.newEvent(message, queueURL)
+				if err != nil {
+					if !mgr.SkipMessage(err, event) {
+						mgr.RejectEvent(err, event)
+					}
+					continue
+				}
+
+				resumed[event.EC2InstanceID] = true
+				mgr.dispatchEvent(event)
+			}
 		}
+	}
 
-		go mgr.Process(event)
+	// start SQS pollers to load messages to stream from SQS, discovered queues are started and stopped as their tags change
+	for _, queueURL := range queueURLs {
+		mgr.startQueue(queueURL)
 	}
+	if len(ctx.QueueDiscoveryTags) > 0 {
+		go mgr.startQueueDiscovery()
+	}
+	go mgr.monitorQueue()
+	go mgr.startReconciler()
+	go mgr.startScaleInProtection()
 
-	// start SQS poller to load messages to stream from SQS
-	go mgr.newPoller()
+	// process events from stream until the service is stopped
+	for {
+		var received *receivedMessage
+		select {
+		case <-mgr.ctx.Done():
+			log.Info("lifecycle-manager service stopped")
+			return
+		case received = <-mgr.eventStream:
+		}
 
-	// process events from stream
-	for message := range mgr.eventStream {
+		mgr.intakeMessage(received.message, received.queueURL)
+		// the event of the message is in-flight or was rejected, its poller's reservation is no longer needed
+		mgr.releaseIntake()
+	}
+}
 
-		event, err := mgr.newEvent(message, queueURL)
-		if err != nil {
-			mgr.RejectEvent(err, event)
-			continue
+// intakeMessage validates the message received by a poller and dispatches its event to the workers
+func (mgr *Manager) intakeMessage(message *sqs.Message, queueURL string) {
+	// interruption notices of aws-node-termination-handler's queue have no lifecycle hook and are not queued as events
+	if notice := readInterruptionNotice(message); notice != nil {
+		mgr.handleInterruptionNotice(notice, message, queueURL)
+		return
+	}
+
+	event, err := mgr.newEvent(message, queueURL)
+	if err != nil {
+		if mgr.RetryEvent(err, event) {
+			return
+		}
+		if mgr.SkipMessage(err, event) {
+			return
 		}
+		mgr.RejectEvent(err, event)
+		return
+	}
 
-		go mgr.Process(event)
+	if err := mgr.claimEvent(event); err != nil {
+		mgr.RejectEvent(err, event)
+		return
 	}
+
+	mgr.dispatchEvent(event)
 }
 
 func (mgr *Manager) newEvent(message *sqs.Message, queueURL string) (*LifecycleEvent, error) {
@@ -131,50 +278,167 @@ func (mgr *Manager) newEvent(message *sqs.Message, queueURL string) (*LifecycleE
 	if err != nil {
 		return &LifecycleEvent{}, err
 	}
+	stop := mgr.extendMessageVisibility(event)
+	defer stop()
+
+	// whether the event is part of an instance refresh is a metric label, it is detected before the event is counted
+	mgr.detectInstanceRefresh(event)
+	mgr.setEventPhase(event, PhaseReceived)
+	mgr.routeEvent(event)
+	if event.snsEnvelope != nil && mgr.context.VerifySNSSignature {
+		if err := verifySNSSignature(event.snsEnvelope); err != nil {
+			return event, errors.Wrapf(err, "failed to verify sns notification %v", event.snsEnvelope.MessageID)
+		}
+	}
+
+	if err = mgr.validateMessageSource(event); err != nil {
+		return event, err
+	}
+	if ctx := mgr.context; ctx.MaxTimeToProcessSeconds > 0 {
+		event.SetContext(context.WithTimeout(mgr.ctx, time.Duration(ctx.MaxTimeToProcessSeconds)*time.Second))
+	} else {
+		event.SetContext(context.WithCancel(mgr.ctx))
+	}
 
-	if err = mgr.validateEvent(event); err != nil {
+	if err = mgr.validateEventWithTimeout(event); err != nil {
 		return event, err
 	}
+	mgr.setEventPhase(event, PhaseValidated)
+	event.validatedTime = time.Now()
 
 	return event, nil
 }
 
+// validateMessageSource rejects messages whose account or sender is not allowed, which are either spoofed or
+// posted to a misconfigured queue
+func (mgr *Manager) validateMessageSource(e *LifecycleEvent) error {
+	var (
+		ctx     = &mgr.context
+		metrics = mgr.metrics
+		reasons = []string{}
+	)
+
+	// reconciled events are synthesized from the scaling group state rather than received from the queue
+	if e.receiptHandle == "" {
+		return nil
+	}
+
+	if len(ctx.AllowedAccountIDs) > 0 {
+		if !slices.Contains(ctx.AllowedAccountIDs, e.AccountID) {
+			reasons = append(reasons, fmt.Sprintf("account '%v' is not allowed", e.AccountID))
+		}
+		if e.snsEnvelope != nil {
+			if account := getARNAccountID(e.snsEnvelope.TopicArn); !slices.Contains(ctx.AllowedAccountIDs, account) {
+				reasons = append(reasons, fmt.Sprintf("topic account '%v' is not allowed", account))
+			}
+		}
+	}
+
+	if len(ctx.AllowedSenderIDs) > 0 {
+		senderID := getMessageSenderID(e.message)
+		if !slices.Contains(ctx.AllowedSenderIDs, senderID) {
+			reasons = append(reasons, fmt.Sprintf("sender '%v' is not allowed", senderID))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	err := errors.Wrap(ErrUntrustedMessage, strings.Join(reasons, ", "))
+	log.Warnf("%v> rejecting message of request %v: %v", e.EC2InstanceID, e.RequestID, err)
+	metrics.AddCounter(UntrustedMessagesTotalMetric, nil, 1)
+	msg := fmt.Sprintf(EventMessageUntrustedMessageRejected, e.RequestID, e.EC2InstanceID, err)
+	mgr.publishEvent(e, EventReasonUntrustedMessageRejected, getMessageFields(e, msg))
+	return err
+}
+
+// eventValidation is what the validation of an event learned about it, it is applied to the event once the
+// validation succeeded
+type eventValidation struct {
+	node              v1.Node
+	nodeFound         bool
+	instanceTags      map[string]string
+	heartbeatInterval int64
+}
+
+// apply sets the referenced node, instance tags and heartbeat interval found by validation on the event
+func (v *eventValidation) apply(e *LifecycleEvent) {
+	if v.nodeFound {
+		e.SetReferencedNode(v.node)
+	}
+	if v.instanceTags != nil {
+		e.instanceTags = v.instanceTags
+	}
+	e.SetHeartbeatInterval(v.heartbeatInterval)
+}
+
 func (mgr *Manager) validateEvent(e *LifecycleEvent) error {
+	validation, err := mgr.checkEvent(e)
+	if err != nil {
+		return err
+	}
+	validation.apply(e)
+	return nil
+}
+
+// checkEvent validates an event without modifying it, so that a validation which is no longer waited for cannot
+// change the event while it is processed or failed
+func (mgr *Manager) checkEvent(e *LifecycleEvent) (*eventValidation, error) {
 	var (
 		auth       = mgr.authenticator
-		kubeClient = auth.KubernetesClient
+		kubeClient = mgr.kubeClient(e)
+		validation = &eventValidation{}
 	)
 
-	if e.LifecycleTransition != TerminationEventName {
-		return errors.Errorf("got unsupported event type: '%+v'", e.LifecycleTransition)
+	isLaunch := e.LifecycleTransition == LaunchEventName && mgr.context.WithLaunchHooks
+	if e.LifecycleTransition != TerminationEventName && !isLaunch {
+		return nil, errors.Errorf("got unsupported event type: '%+v'", e.LifecycleTransition)
 	}
 
 	if e.EC2InstanceID == "" {
-		return errors.Errorf("instance-id not provided in event: %+v", e)
+		return nil, errors.Errorf("instance-id not provided in event: %+v", e)
 	}
 
 	if e.LifecycleHookName == "" {
-		return errors.Errorf("hook-name not provided in event: %+v", e)
+		return nil, errors.Errorf("hook-name not provided in event: %+v", e)
 	}
 
 	if mgr.EventInQueue(e) {
-		return errors.New("event already exists in queue")
+		return nil, errors.New("event already exists in queue")
+	}
+
+	if err := mgr.validateOwnership(e); err != nil {
+		return nil, err
 	}
 
-	node, exists := getNodeByInstance(kubeClient, e.EC2InstanceID)
-	if !exists {
-		return errors.Errorf("instance %v is not seen in cluster nodes", e.EC2InstanceID)
+	if err := mgr.validateShard(e); err != nil {
+		return nil, err
+	}
+
+	// launching instances are not expected to be registered as nodes yet
+	if !isLaunch {
+		node, exists := findNodeByInstance(kubeClient, auth.EC2Client, e.EC2InstanceID)
+		// instances leaving a warm pool may never have joined the cluster
+		if !exists && !e.isFromWarmPool() {
+			return nil, errors.Wrapf(ErrNodeNotFound, "instance %v is not seen in cluster nodes", e.EC2InstanceID)
+		}
+		validation.node, validation.nodeFound = node, exists
+
+		tags, err := mgr.admissionTags(e)
+		if err != nil {
+			return nil, err
+		}
+		validation.instanceTags = tags
 	}
 
 	heartbeatInterval, err := getHookHeartbeatInterval(auth.ScalingGroupClient, e.LifecycleHookName, e.AutoScalingGroupName)
 	if err != nil {
-		return errors.Wrap(err, "failed to get hook heartbeat interval")
+		return nil, errors.Wrap(err, "failed to get hook heartbeat interval")
 	}
+	validation.heartbeatInterval = heartbeatInterval
 
-	e.SetHeartbeatInterval(heartbeatInterval)
-	e.SetReferencedNode(node)
-
-	return nil
+	return validation, nil
 }
 
 // Process processes a received event
@@ -183,11 +447,46 @@ func (mgr *Manager) Process(event *LifecycleEvent) {
 	// add event to work queue
 	mgr.AddEvent(event)
 
-	log.Infof("%v> received termination event", event.EC2InstanceID)
+	// warm pool instances which are not nodes have nothing to drain or wait for
+	if event.isWarmPoolInstance() {
+		mgr.CompleteWarmPoolEvent(event)
+		return
+	}
+
+	var err error
+	if event.LifecycleTransition == LaunchEventName {
+		log.Infof("%v> received launch event", event.EC2InstanceID)
+		err = mgr.handleLaunchEvent(event)
+	} else {
+		log.Infof("%v> received termination event", event.EC2InstanceID)
+		event.policyDecision = mgr.evaluatePolicy(event)
+		if mgr.isNodeSkipped(event) || !mgr.isInstanceAdmitted(event) || event.policyDecision.skip() {
+			mgr.SkipEvent(event)
+			return
+		}
+		err = mgr.handleEvent(event)
+	}
+
+	// abandon events which have exhausted their processing budget
+	if event.Context().Err() == context.DeadlineExceeded {
+		mgr.publishDeadlineExceeded(event)
+		deadlineErr := errors.Errorf("event exceeded max time to process of %vs", mgr.context.MaxTimeToProcessSeconds)
+		if err != nil {
+			deadlineErr = errors.Wrap(err, deadlineErr.Error())
+		}
+		err = NewTerminalError(deadlineErr)
+	}
+
+	// leave events interrupted by a shutdown in progress, they are resumed once the service restarts
+	if err != nil && mgr.stopping() {
+		log.Warnf("%v> event interrupted by shutdown, it will be resumed on restart: %v", event.EC2InstanceID, err)
+		return
+	}
 
-	// handle event
-	err := mgr.handleEvent(event)
 	if err != nil {
+		if mgr.RedriveEvent(err, event) {
+			return
+		}
 		mgr.FailEvent(err, event, true)
 		return
 	}
@@ -196,215 +495,629 @@ func (mgr *Manager) Process(event *LifecycleEvent) {
 	mgr.CompleteEvent(event)
 }
 
-func (mgr *Manager) newPoller() {
+// publishDeadlineExceeded reports an event which exceeded the max time to process
+func (mgr *Manager) publishDeadlineExceeded(event *LifecycleEvent) {
+	var (
+		metrics = mgr.metrics
+	)
+
+	log.Warnf("%v> event exceeded max time to process of %vs", event.EC2InstanceID, mgr.context.MaxTimeToProcessSeconds)
+	metrics.AddCounter(DeadlineExceededEventsTotalMetric, eventLabels(event), 1)
+	msg := fmt.Sprintf(EventMessageLifecycleHookDeadlineExceeded, event.RequestID, mgr.context.MaxTimeToProcessSeconds, event.EC2InstanceID)
+	mgr.publishEvent(event, EventReasonLifecycleHookDeadlineExceeded, getMessageFields(event, msg))
+}
+
+// newPoller receives messages from the queue at url until the context is done
+func (mgr *Manager) newPoller(pollerCtx context.Context, url string) {
 	var (
 		ctx      = &mgr.context
 		metrics  = mgr.metrics
 		auth     = mgr.authenticator
 		stream   = mgr.eventStream
 		queue    = auth.SQSClient
-		url      = getQueueURLByName(queue, ctx.QueueName)
 		interval = ctx.PollingIntervalSeconds
+		backoff  = &pollerBackoff{jitter: ctx.BackoffJitter}
 	)
 
-	for {
+	for pollerCtx.Err() == nil {
 		log.Debugln("polling for messages from queue")
 		goroutines := runtime.NumGoroutine()
-		metrics.SetGauge(ActiveGoroutinesMetric, float64(goroutines))
+		metrics.SetGauge(ActiveGoroutinesMetric, nil, float64(goroutines))
 		log.Debugf("active goroutines: %v", goroutines)
 
-		output, err := queue.ReceiveMessage(&sqs.ReceiveMessageInput{
+		// leave messages in the queue while the node of lifecycle-manager terminates, they are received once it restarts on another node
+		if mgr.isSelfTerminating() {
+			log.Debugln("node of lifecycle-manager is terminating, pausing polling")
+			time.Sleep(BackpressureInterval)
+			continue
+		}
+
+		// leave messages in the queue while at capacity, their visibility timeout governs redelivery
+		if !mgr.reserveIntake() {
+			log.Debugf("all workers are busy or in-flight event limit of %v reached, pausing polling", ctx.MaxInFlightEvents)
+			metrics.AddCounter(BackpressurePollsTotalMetric, nil, 1)
+			time.Sleep(BackpressureInterval)
+			continue
+		}
+
+		output, err := queue.ReceiveMessageWithContext(pollerCtx, &sqs.ReceiveMessageInput{
 			QueueUrl: aws.String(url),
 			AttributeNames: aws.StringSlice([]string{
 				"SenderId",
+				sqs.MessageSystemAttributeNameSentTimestamp,
+				sqs.MessageSystemAttributeNameApproximateReceiveCount,
 			}),
 			MaxNumberOfMessages: aws.Int64(1),
 			WaitTimeSeconds:     aws.Int64(interval),
 		})
+		// messages received while the pollers of the queue stop become visible again once their visibility timeout passes
+		if pollerCtx.Err() != nil {
+			mgr.releaseIntake()
+			return
+		}
 		if err != nil {
-			log.Errorf("unable to receive message from queue %s, %v.", url, err)
-			time.Sleep(time.Duration(interval) * time.Second)
+			mgr.releaseIntake()
+			metrics.AddCounter(ReceiveErrorsTotalMetric, nil, 1)
+			delay, opened := backoff.failure()
+			if opened {
+				log.Errorf("unable to receive message from queue %s after %v consecutive errors, suspending polling for %v: %v", url, backoff.failures, delay, err)
+				metrics.SetGauge(PollerCircuitOpenMetric, nil, 1)
+			} else {
+				log.Errorf("unable to receive message from queue %s, polling again in %v: %v", url, delay, err)
+			}
+			if !sleep(pollerCtx, delay) {
+				return
+			}
+			continue
 		}
-		if len(output.Messages) == 0 {
+		if backoff.success() {
+			log.Infof("received from queue %s again, resuming polling", url)
+			metrics.SetGauge(PollerCircuitOpenMetric, nil, 0)
+		}
+
+		var messages []*sqs.Message
+		if output != nil {
+			messages = output.Messages
+		}
+		// at most one message is received, the reservation is handed over with it to the processing loop
+		if len(messages) == 0 {
+			mgr.releaseIntake()
 			log.Debugln("no messages received in interval")
+			metrics.AddCounter(EmptyPollsTotalMetric, nil, 1)
+		}
+		for _, message := range messages {
+			metrics.AddCounter(ReceivedMessagesTotalMetric, nil, 1)
+			metrics.SetGauge(QueueMessageAgeSecondsMetric, nil, getMessageAge(message).Seconds())
+			select {
+			case <-pollerCtx.Done():
+				mgr.releaseIntake()
+				return
+			case stream <- &receivedMessage{message: message, queueURL: url}:
+			}
+		}
+	}
+}
+
+// monitorQueue periodically exports the approximate depth of the consumed queues until the service is stopped
+func (mgr *Manager) monitorQueue() {
+	var (
+		metrics = mgr.metrics
+		queue   = mgr.authenticator.SQSClient
+	)
+
+	ticker := time.NewTicker(QueueMetricsInterval)
+	defer ticker.Stop()
+	for {
+		var totalVisible, totalInFlight int64
+		failed := false
+		for _, url := range mgr.queueURLs() {
+			visible, inFlight, err := getQueueDepth(queue, url)
+			if err != nil {
+				log.Warnf("failed to get attributes of queue %v: %v", url, err)
+				failed = true
+				continue
+			}
+			totalVisible += visible
+			totalInFlight += inFlight
+		}
+		// a partial sum would under-report the depth
+		if !failed {
+			metrics.SetGauge(QueueMessagesVisibleMetric, nil, float64(totalVisible))
+			metrics.SetGauge(QueueMessagesInFlightMetric, nil, float64(totalInFlight))
+		}
+
+		select {
+		case <-mgr.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drainLimiter bounds the number of nodes of a scaling group draining at once
+type drainLimiter struct {
+	limit int64
+	sem   *semaphore.Weighted
+	queue *DrainQueue
+}
+
+// scalingGroupDrainLimiter returns the drain limiter of a scaling group, it is replaced when the limit changes
+func (mgr *Manager) scalingGroupDrainLimiter(scalingGroup string, limit int64) *drainLimiter {
+	mgr.drainLimitersMu.Lock()
+	defer mgr.drainLimitersMu.Unlock()
+
+	limiter, ok := mgr.drainLimiters[scalingGroup]
+	if !ok || limiter.limit != limit {
+		limiter = &drainLimiter{limit: limit, sem: semaphore.NewWeighted(limit), queue: NewDrainQueue()}
+		mgr.drainLimiters[scalingGroup] = limiter
+	}
+	return limiter
+}
+
+// acquireSemaphore blocks until the semaphore is acquired, waiting events are served by deadline and counted
+func (mgr *Manager) acquireSemaphore(event *LifecycleEvent, sem *semaphore.Weighted, queue *DrainQueue, name string) error {
+	var (
+		metrics = mgr.metrics
+	)
+
+	if queue.Len() == 0 && sem.TryAcquire(1) {
+		return nil
+	}
+
+	log.Infof("%v> %v reached, waiting for a drain to finish", event.EC2InstanceID, name)
+	metrics.AddCounter(DrainSemaphoreWaitsTotalMetric, eventLabels(event), 1)
+	metrics.IncGauge(DrainSemaphoreWaitingCountMetric, eventLabels(event))
+	defer metrics.DecGauge(DrainSemaphoreWaitingCountMetric, eventLabels(event))
+	return queue.Acquire(event, sem)
+}
+
+// acquireDrainSemaphore blocks until a drain slot of the event's scaling group and a global drain slot are free
+func (mgr *Manager) acquireDrainSemaphore(event *LifecycleEvent) error {
+	var (
+		metrics = mgr.metrics
+		limit   = event.settings.MaxDrainConcurrency
+	)
+
+	// the scaling group slot is taken first so that events blocked by their scaling group do not hold global slots
+	if limit > 0 {
+		key := event.AutoScalingGroupName
+		// instance refreshes with their own limit drain alongside scale-in of the scaling group
+		if event.isInstanceRefresh() && event.settings.InstanceRefreshMaxDrainConcurrency > 0 {
+			key += "/instance-refresh"
 		}
-		for _, message := range output.Messages {
-			stream <- message
+		limiter := mgr.scalingGroupDrainLimiter(key, limit)
+		if err := mgr.acquireSemaphore(event, limiter.sem, limiter.queue, fmt.Sprintf("max drain concurrency of %v", key)); err != nil {
+			return err
 		}
+		event.drainLimiter = limiter
+	}
+
+	if err := mgr.acquireSemaphore(event, mgr.context.MaxDrainConcurrency, mgr.drainQueue, "max drain concurrency"); err != nil {
+		mgr.releaseDrainSemaphore(event, false)
+		return err
+	}
+	metrics.IncGauge(DrainConcurrencyMetric, nil)
+	return nil
+}
+
+// releaseDrainSemaphore releases the drain slots held by the event
+func (mgr *Manager) releaseDrainSemaphore(event *LifecycleEvent, global bool) {
+	if limiter := event.drainLimiter; limiter != nil {
+		limiter.sem.Release(1)
+		limiter.queue.Notify()
+		event.drainLimiter = nil
+	}
+	if global {
+		mgr.context.MaxDrainConcurrency.Release(1)
+		mgr.drainQueue.Notify()
+		mgr.metrics.DecGauge(DrainConcurrencyMetric, nil)
 	}
 }
 
 func (mgr *Manager) drainNodeTarget(event *LifecycleEvent) error {
 	var (
 		ctx                = &mgr.context
-		kubeClient         = mgr.authenticator.KubernetesClient
+		kubeClient         = mgr.kubeClient(event)
 		metrics            = mgr.metrics
-		drainTimeout       = ctx.DrainTimeoutSeconds
-		drainRetryAttempts = ctx.DrainRetryAttempts
-		retryInterval      = ctx.DrainRetryIntervalSeconds
+		settings           = event.settings
+		drainTimeout       = settings.DrainTimeoutSeconds
+		drainRetryAttempts = settings.DrainRetryAttempts
+		retryInterval      = settings.DrainRetryIntervalSeconds
 		successMsg         = fmt.Sprintf(EventMessageNodeDrainSucceeded, event.referencedNode.Name)
 	)
 
 	log.Debugf("%v> acquired drain semaphore", event.EC2InstanceID)
 	defer func() {
-		mgr.context.MaxDrainConcurrency.Release(1)
+		mgr.releaseDrainSemaphore(event, true)
 		log.Debugf("%v> released drain semaphore", event.EC2InstanceID)
 	}()
 
-	metrics.IncGauge(DrainingInstancesCountMetric)
-	defer metrics.DecGauge(DrainingInstancesCountMetric)
+	metrics.IncGauge(DrainingInstancesCountMetric, eventLabels(event))
+	defer metrics.DecGauge(DrainingInstancesCountMetric, eventLabels(event))
+
+	drainStart := time.Now()
+	event.setDrainTiming(drainStart, 0)
+	defer func() {
+		duration := time.Since(drainStart)
+		event.setDrainTiming(drainStart, duration)
+		metrics.ObserveHistogram(DrainDurationSecondsMetric, eventLabels(event), duration.Seconds())
+	}()
 
 	if isNodeStatusInCondition(event.referencedNode, v1.ConditionUnknown) {
 		log.Infof("%v> node is in unknown state, setting drain deadline to %vs", event.EC2InstanceID, ctx.DrainTimeoutUnknownSeconds)
 		drainTimeout = ctx.DrainTimeoutUnknownSeconds
 	}
 
+	if settings.CordonOnly {
+		log.Infof("%v> cordoning node/%v without draining as requested by the hook's notification metadata", event.EC2InstanceID, event.referencedNode.Name)
+		if err := cordonNode(event.Context(), kubeClient, &event.referencedNode); err != nil {
+			metrics.AddCounter(FailedNodeDrainTotalMetric, eventLabels(event), 1)
+			return err
+		}
+		return nil
+	}
+
+	if mgr.isDrainedByUpgradeManager(event) {
+		log.Infof("%v> node/%v was already drained by upgrade-manager, skipping drain", event.EC2InstanceID, event.referencedNode.Name)
+		metrics.AddCounter(SkippedNodeDrainTotalMetric, eventLabels(event), 1)
+		msg := fmt.Sprintf(EventMessageNodeDrainSkipped, event.referencedNode.Name)
+		mgr.publishEvent(event, EventReasonNodeDrainSkipped, getMessageFields(event, msg))
+		return nil
+	}
+
 	log.Infof("%v> draining node/%v", event.EC2InstanceID, event.referencedNode.Name)
-	err := drainNode(kubeClient, &event.referencedNode, drainTimeout, retryInterval, drainRetryAttempts)
+	observer, drainEnded := mgr.newDrainObserver(event)
+	err := mgr.runStagedDrain(event, kubeClient, drainTimeout, retryInterval, drainRetryAttempts, observer)
+	drainEnded()
 	if err != nil {
-		metrics.AddCounter(FailedNodeDrainTotalMetric, 1)
+		metrics.AddCounter(FailedNodeDrainTotalMetric, eventLabels(event), 1)
 		failMsg := fmt.Sprintf(EventMessageNodeDrainFailed, event.referencedNode.Name, err)
-		kEvent := newKubernetesEvent(EventMessageNodeDrainFailed, getMessageFields(event, failMsg))
-		publishKubernetesEvent(kubeClient, kEvent)
+		mgr.publishEvent(event, EventMessageNodeDrainFailed, getMessageFields(event, failMsg))
 		return err
 	}
 	log.Infof("%v> completed drain for node/%v", event.EC2InstanceID, event.referencedNode.Name)
-	event.SetDrainCompleted(true)
-	metrics.AddCounter(SuccessfulNodeDrainTotalMetric, 1)
+	metrics.AddCounter(SuccessfulNodeDrainTotalMetric, eventLabels(event), 1)
 
-	kEvent := newKubernetesEvent(EventReasonNodeDrainSucceeded, getMessageFields(event, successMsg))
-	publishKubernetesEvent(kubeClient, kEvent)
+	mgr.publishEvent(event, EventReasonNodeDrainSucceeded, getMessageFields(event, successMsg))
 	return nil
 }
 
-func (mgr *Manager) deleteNodeTarget(event *LifecycleEvent) error {
+// drainPolicy returns the policy by which the pods of the event's node are evicted
+func (mgr *Manager) drainPolicy(event *LifecycleEvent) drainPolicy {
+	policy := drainPolicy{
+		order:              mgr.context.EvictionOrder,
+		gracePeriodSeconds: int(mgr.context.DrainGracePeriodSeconds),
+		excludedNamespaces: make(map[string]bool),
+	}
+	for _, namespace := range mgr.context.DrainExcludeNamespaces {
+		policy.excludedNamespaces[namespace] = true
+	}
+	if mgr.isSelfNode(event) {
+		policy = mgr.selfDrainPolicy(policy)
+	}
+	return policy
+}
+
+// newDrainObserver reports every pod evicted from the event's node and tracks the pods remaining on it,
+// the returned func must be called once the drain ends to clear the remaining pods from the gauge
+func (mgr *Manager) newDrainObserver(event *LifecycleEvent) (*drainObserver, func()) {
+	var (
+		metrics   = mgr.metrics
+		nodeName  = event.referencedNode.Name
+		remaining int
+		mu        sync.Mutex
+	)
+
+	observer := &drainObserver{
+		podsFound: func(count int) {
+			mu.Lock()
+			defer mu.Unlock()
+			log.Infof("%v> %v pods to evict from node/%v", event.EC2InstanceID, count, nodeName)
+			metrics.AddGauge(DrainPodsRemainingMetric, eventLabels(event), float64(count-remaining))
+			remaining = count
+		},
+		podEvicted: func(pod *v1.Pod) {
+			mu.Lock()
+			if remaining > 0 {
+				remaining--
+				metrics.DecGauge(DrainPodsRemainingMetric, eventLabels(event))
+			}
+			left := remaining
+			mu.Unlock()
+
+			msg := fmt.Sprintf(EventMessagePodEvicted, pod.Namespace, pod.Name, nodeName, left)
+			log.Infof("%v> %v", event.EC2InstanceID, msg)
+			mgr.publishEvent(event, EventReasonPodEvicted, getMessageFields(event, msg))
+		},
+	}
+
+	drainEnded := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		metrics.AddGauge(DrainPodsRemainingMetric, eventLabels(event), float64(-remaining))
+		remaining = 0
+	}
+	return observer, drainEnded
+}
+
+func (mgr *Manager) deleteNodeTarget(ctx context.Context, event *LifecycleEvent) error {
 
 	var (
-		kubeClient = mgr.authenticator.KubernetesClient
+		kubeClient = mgr.kubeClient(event)
 		metrics    = mgr.metrics
 		successMsg = fmt.Sprintf(EventMessageNodeDeleteSucceeded, event.referencedNode.Name)
 	)
 
 	log.Infof("%v> deleting node/%v", event.EC2InstanceID, event.referencedNode.Name)
-	err := deleteNode(kubeClient, &event.referencedNode)
+	err := deleteNode(ctx, kubeClient, &event.referencedNode)
 	if err != nil {
-		metrics.AddCounter(FailedNodeDrainTotalMetric, 1)
+		metrics.AddCounter(FailedNodeDrainTotalMetric, eventLabels(event), 1)
 		failMsg := fmt.Sprintf(EventMessageNodeDeleteFailed, event.referencedNode.Name, err)
-		kEvent := newKubernetesEvent(EventMessageNodeDeleteFailed, getMessageFields(event, failMsg))
-		publishKubernetesEvent(kubeClient, kEvent)
+		mgr.publishEvent(event, EventMessageNodeDeleteFailed, getMessageFields(event, failMsg))
 		return err
 	}
 
 	log.Infof("%v> completed node deletion/%v", event.EC2InstanceID, event.referencedNode.Name)
 	event.SetNodeDeleted(true)
-	metrics.AddCounter(SuccessfulNodeDeleteTotalMetric, 1)
+	metrics.AddCounter(SuccessfulNodeDeleteTotalMetric, eventLabels(event), 1)
 
-	kEvent := newKubernetesEvent(EventReasonNodeDrainSucceeded, getMessageFields(event, successMsg))
-	publishKubernetesEvent(kubeClient, kEvent)
+	mgr.publishEvent(event, EventReasonNodeDrainSucceeded, getMessageFields(event, successMsg))
 
 	return nil
 }
 
-func (mgr *Manager) scanMembership(event *LifecycleEvent) (*ScanResult, error) {
+func (mgr *Manager) scanMembership(ctx context.Context, event *LifecycleEvent) (*ScanResult, error) {
 	var (
-		ctx                 = &mgr.context
 		elbv2Client         = mgr.authenticator.ELBv2Client
 		elbClient           = mgr.authenticator.ELBClient
 		instanceID          = event.EC2InstanceID
 		activeTargetGroups  = make(map[string]int64)
 		activeLoadBalancers = make([]string, 0)
 		scanResult          = &ScanResult{}
+		workers             = mgr.context.MembershipCheckConcurrency
+		targets             = make(map[string]*Target)
 	)
 
-	// get all target groups
-	targetGroups := []*elbv2.TargetGroup{}
-	if slices.Contains(ctx.DeregisterTargetTypes, TargetTypeTargetGroup.String()) {
-		err := elbv2Client.DescribeTargetGroupsPages(&elbv2.DescribeTargetGroupsInput{}, func(page *elbv2.DescribeTargetGroupsOutput, lastPage bool) bool {
-			targetGroups = append(targetGroups, page.TargetGroups...)
-			return page.NextMarker != nil
-		})
-		if err != nil {
-			return scanResult, err
-		}
+	// discover target groups and classic elbs
+	targetGroups, elbDescriptions, err := mgr.discoverLoadBalancers(ctx, event)
+	if err != nil {
+		return scanResult, err
 	}
 
-	// get all classic elbs
-	elbDescriptions := []*elb.LoadBalancerDescription{}
-	if slices.Contains(ctx.DeregisterTargetTypes, TargetTypeClassicELB.String()) {
-		err := elbClient.DescribeLoadBalancersPages(&elb.DescribeLoadBalancersInput{}, func(page *elb.DescribeLoadBalancersOutput, lastPage bool) bool {
-			elbDescriptions = append(elbDescriptions, page.LoadBalancerDescriptions...)
-			return page.NextMarker != nil
-		})
-		if err != nil {
-			return scanResult, err
-		}
+	// only keep target groups and classic elbs matching the tag filters
+	targetGroups, elbDescriptions, err = mgr.filterLoadBalancersByTags(ctx, targetGroups, elbDescriptions)
+	if err != nil {
+		return scanResult, err
 	}
 
 	log.Infof("%v> checking targetgroup/elb membership", instanceID)
+	// membership snapshots are shared between events, a lookup which is cancelled only stops waiting for the snapshot
+	// so that it does not fail the lookups of the other events waiting on the same snapshot
 	// find instance in target groups
+	tgResults := make([]membershipResult, len(targetGroups))
+	forEachConcurrently(workers, len(targetGroups), func(i int) {
+		arn := aws.StringValue(targetGroups[i].TargetGroupArn)
+		log.Debugf("%v> checking membership in %v (%v/%v)", instanceID, arn, i, len(targetGroups))
+		found, port, err := mgr.membership.findInstanceInTargetGroup(ctx, elbv2Client, arn, instanceID)
+		tgResults[i] = membershipResult{found: found, port: port, err: err}
+	})
+
 	for i, tg := range targetGroups {
 		arn := aws.StringValue(tg.TargetGroupArn)
-		// check each target group for matches
-		waitJitter(IterationJitterRangeSeconds)
-		log.Debugf("%v> checking membership in %v (%v/%v)", instanceID, arn, i, len(targetGroups))
-		found, port, err := findInstanceInTargetGroup(elbv2Client, arn, instanceID)
-		if err != nil {
-			if awsErr, ok := err.(awserr.Error); ok {
+		result := tgResults[i]
+		if result.err != nil {
+			log.Errorf("%v> failed finding instance in target group %v: %v", instanceID, arn, result.err.Error())
+			if awsErr, ok := result.err.(awserr.Error); ok {
 				if awsErr.Code() == elbv2.ErrCodeTargetGroupNotFoundException {
 					log.Warnf("%v> target group %v not found, skipping", instanceID, arn)
 					continue
 				}
 			}
-			return scanResult, err
+			return scanResult, result.err
 		}
 
-		if !found {
+		if !result.found {
 			continue
 		}
-		activeTargetGroups[arn] = port
-		mgr.AddTargetByInstance(arn, mgr.NewTarget(arn, instanceID, port, TargetTypeTargetGroup))
+		activeTargetGroups[arn] = result.port
+		targets[arn] = mgr.NewTarget(arn, instanceID, result.port, TargetTypeTargetGroup)
 	}
 	scanResult.ActiveTargetGroups = activeTargetGroups
 
 	// find instance in classic elbs
+	elbResults := make([]membershipResult, len(elbDescriptions))
+	forEachConcurrently(workers, len(elbDescriptions), func(i int) {
+		elbName := aws.StringValue(elbDescriptions[i].LoadBalancerName)
+		log.Debugf("%v> checking membership in %v (%v/%v)", instanceID, elbName, i, len(elbDescriptions))
+		found, err := mgr.membership.findInstanceInClassicBalancer(ctx, elbClient, elbName, instanceID)
+		elbResults[i] = membershipResult{found: found, err: err}
+	})
+
 	for i, desc := range elbDescriptions {
 		elbName := aws.StringValue(desc.LoadBalancerName)
-		// check each target group for matches
-		waitJitter(IterationJitterRangeSeconds)
-		log.Debugf("%v> checking membership in %v (%v/%v)", instanceID, elbName, i, len(elbDescriptions))
-		found, err := findInstanceInClassicBalancer(elbClient, elbName, instanceID)
-		if err != nil {
-			if awsErr, ok := err.(awserr.Error); ok {
+		result := elbResults[i]
+		if result.err != nil {
+			log.Errorf("%v> failed finding instance in elb %v: %v", instanceID, elbName, result.err.Error())
+			if awsErr, ok := result.err.(awserr.Error); ok {
 				if awsErr.Code() == elb.ErrCodeAccessPointNotFoundException {
 					log.Warnf("%v> classic-elb %v not found, skipping", instanceID, elbName)
 					continue
 				}
 			}
-			return scanResult, err
+			return scanResult, result.err
 		}
 
-		if !found {
+		if !result.found {
 			continue
 		}
-		mgr.AddTargetByInstance(elbName, mgr.NewTarget(elbName, instanceID, 0, TargetTypeClassicELB))
+		targets[elbName] = mgr.NewTarget(elbName, instanceID, 0, TargetTypeClassicELB)
 		activeLoadBalancers = append(activeLoadBalancers, elbName)
 	}
 	scanResult.ActiveLoadBalancers = activeLoadBalancers
 
+	// only track the targets of scans which completed, a scan failing half way leaves no targets behind
+	for key, target := range targets {
+		mgr.AddTargetByInstance(key, target)
+	}
+
 	log.Infof("%v> found %v target groups & %v classic-elb", instanceID, len(activeTargetGroups), len(elbDescriptions))
 	return scanResult, nil
 }
 
+// discoverLoadBalancers returns the target groups and classic elbs attached to the event's scaling group,
+// the entire account is scanned instead if no attachments are found and full scan fallback is enabled
+func (mgr *Manager) discoverLoadBalancers(ctx context.Context, event *LifecycleEvent) ([]*elbv2.TargetGroup, []*elb.LoadBalancerDescription, error) {
+	var (
+		asgClient       = mgr.authenticator.ScalingGroupClient
+		elbv2Client     = mgr.authenticator.ELBv2Client
+		elbClient       = mgr.authenticator.ELBClient
+		instanceID      = event.EC2InstanceID
+		withTargetGroup = slices.Contains(mgr.context.DeregisterTargetTypes, TargetTypeTargetGroup.String())
+		withClassicELB  = slices.Contains(mgr.context.DeregisterTargetTypes, TargetTypeClassicELB.String())
+		targetGroups    = []*elbv2.TargetGroup{}
+		elbDescriptions = []*elb.LoadBalancerDescription{}
+	)
+
+	// get target groups and classic elbs attached to the scaling group
+	if withTargetGroup {
+		arns, err := getScalingGroupTargetGroups(ctx, asgClient, event.AutoScalingGroupName)
+		if err != nil {
+			return targetGroups, elbDescriptions, err
+		}
+		for _, arn := range arns {
+			targetGroups = append(targetGroups, &elbv2.TargetGroup{TargetGroupArn: aws.String(arn)})
+		}
+	}
+
+	if withClassicELB {
+		names, err := getScalingGroupClassicBalancers(ctx, asgClient, event.AutoScalingGroupName)
+		if err != nil {
+			return targetGroups, elbDescriptions, err
+		}
+		for _, name := range names {
+			elbDescriptions = append(elbDescriptions, &elb.LoadBalancerDescription{LoadBalancerName: aws.String(name)})
+		}
+	}
+
+	if len(targetGroups) > 0 || len(elbDescriptions) > 0 {
+		log.Infof("%v> discovered %v target groups & %v classic-elb attached to %v", instanceID, len(targetGroups), len(elbDescriptions), event.AutoScalingGroupName)
+		return targetGroups, elbDescriptions, nil
+	}
+
+	if !mgr.context.DeregisterFullScanFallback {
+		log.Infof("%v> no target groups or classic-elb are attached to %v", instanceID, event.AutoScalingGroupName)
+		return targetGroups, elbDescriptions, nil
+	}
+	log.Infof("%v> no load balancers attached to %v, falling back to full scan", instanceID, event.AutoScalingGroupName)
+
+	// get all target groups
+	if withTargetGroup {
+		err := elbv2Client.DescribeTargetGroupsPagesWithContext(ctx, &elbv2.DescribeTargetGroupsInput{}, func(page *elbv2.DescribeTargetGroupsOutput, lastPage bool) bool {
+			targetGroups = append(targetGroups, page.TargetGroups...)
+			return page.NextMarker != nil
+		})
+		if err != nil {
+			return targetGroups, elbDescriptions, err
+		}
+	}
+
+	// get all classic elbs
+	if withClassicELB {
+		err := elbClient.DescribeLoadBalancersPagesWithContext(ctx, &elb.DescribeLoadBalancersInput{}, func(page *elb.DescribeLoadBalancersOutput, lastPage bool) bool {
+			elbDescriptions = append(elbDescriptions, page.LoadBalancerDescriptions...)
+			return page.NextMarker != nil
+		})
+		if err != nil {
+			return targetGroups, elbDescriptions, err
+		}
+	}
+
+	return targetGroups, elbDescriptions, nil
+}
+
+// filterLoadBalancersByTags returns the target groups and classic elbs carrying all of the configured tag filters
+func (mgr *Manager) filterLoadBalancersByTags(ctx context.Context, targetGroups []*elbv2.TargetGroup, elbDescriptions []*elb.LoadBalancerDescription) ([]*elbv2.TargetGroup, []*elb.LoadBalancerDescription, error) {
+	var (
+		filters             = mgr.context.DeregisterTagFilters
+		elbv2Client         = mgr.authenticator.ELBv2Client
+		elbClient           = mgr.authenticator.ELBClient
+		matchedTargetGroups = []*elbv2.TargetGroup{}
+		matchedELBs         = []*elb.LoadBalancerDescription{}
+	)
+
+	if len(filters) == 0 {
+		return targetGroups, elbDescriptions, nil
+	}
+
+	if len(targetGroups) > 0 {
+		arns := make([]string, 0, len(targetGroups))
+		for _, tg := range targetGroups {
+			arns = append(arns, aws.StringValue(tg.TargetGroupArn))
+		}
+		matched, err := getTargetGroupsByTags(ctx, elbv2Client, arns, filters)
+		if err != nil {
+			return targetGroups, elbDescriptions, err
+		}
+		for _, tg := range targetGroups {
+			if matched[aws.StringValue(tg.TargetGroupArn)] {
+				matchedTargetGroups = append(matchedTargetGroups, tg)
+			}
+		}
+	}
+
+	if len(elbDescriptions) > 0 {
+		names := make([]string, 0, len(elbDescriptions))
+		for _, desc := range elbDescriptions {
+			names = append(names, aws.StringValue(desc.LoadBalancerName))
+		}
+		matched, err := getClassicBalancersByTags(ctx, elbClient, names, filters)
+		if err != nil {
+			return targetGroups, elbDescriptions, err
+		}
+		for _, desc := range elbDescriptions {
+			if matched[aws.StringValue(desc.LoadBalancerName)] {
+				matchedELBs = append(matchedELBs, desc)
+			}
+		}
+	}
+
+	log.Debugf("tag filters matched %v/%v target groups & %v/%v classic-elb", len(matchedTargetGroups), len(targetGroups), len(matchedELBs), len(elbDescriptions))
+	return matchedTargetGroups, matchedELBs, nil
+}
+
+// checkDeregistrationDelay warns about target groups which keep deregistering targets for longer than the heartbeat
+// timeout of the lifecycle hook, the hook times out if a single heartbeat is missed while waiting for them
+func (mgr *Manager) checkDeregistrationDelay(event *LifecycleEvent, arn string, delay time.Duration) {
+	heartbeatTimeout := time.Duration(event.heartbeatInterval) * time.Second
+	if heartbeatTimeout <= 0 || delay <= heartbeatTimeout {
+		return
+	}
+	log.Warnf("%v> deregistration delay %v of target group %v exceeds the heartbeat timeout %v", event.EC2InstanceID, delay, arn, heartbeatTimeout)
+	msg := fmt.Sprintf(EventMessageTargetDeregisterDelayExceeded, arn, delay.Seconds(), heartbeatTimeout.Seconds(), event.EC2InstanceID)
+	msgFields := map[string]string{
+		"targetGroup":   arn,
+		"ec2InstanceId": event.EC2InstanceID,
+		"elbType":       "alb",
+		"details":       msg,
+	}
+	mgr.publishEvent(event, EventReasonTargetDeregisterDelayExceeded, msgFields)
+}
+
 func (mgr *Manager) executeDeregisterWaiters(event *LifecycleEvent, scanResult *ScanResult, waiter *Waiter) {
 	var (
-		kubeClient      = mgr.authenticator.KubernetesClient
 		elbv2Client     = mgr.authenticator.ELBv2Client
 		elbClient       = mgr.authenticator.ELBClient
 		instanceID      = event.EC2InstanceID
 		metrics         = mgr.metrics
 		workQueueLength = len(scanResult.ActiveTargetGroups) + len(scanResult.ActiveLoadBalancers)
+		waiterConfig    = mgr.waiterConfig()
+		statusInterval  = WaiterDelayInterval
 	)
 
+	if mgr.context.WaiterDelayIntervalSeconds > 0 {
+		statusInterval = time.Duration(mgr.context.WaiterDelayIntervalSeconds) * time.Second
+	}
+
 	waiter.Add(workQueueLength)
 	// spawn waiters for classic elb
 	for _, elbName := range scanResult.ActiveLoadBalancers {
@@ -415,7 +1128,14 @@ func (mgr *Manager) executeDeregisterWaiters(event *LifecycleEvent, scanResult *
 
 			// wait for deregister/drain
 			log.Debugf("%v> starting classic-elb waiter for %v", instance, elbName)
-			err := waitForDeregisterInstance(event, elbClient, elbName, instance)
+			config := waiterConfig
+			if timeout, err := getClassicBalancerDrainingTimeout(event.Context(), elbClient, elbName); err != nil {
+				log.Warnf("%v> failed to get connection draining timeout of classic-elb %v: %v", instance, elbName, err)
+			} else {
+				config = waiterConfig.forTimeout(timeout + WaiterDrainingMargin)
+				log.Debugf("%v> classic-elb %v drains connections for %v, waiting up to %v attempts", instance, elbName, timeout, config.MaxAttempts)
+			}
+			err := waitForDeregisterInstance(event, elbClient, elbName, instance, config)
 			if err != nil {
 				if awsErr, ok := err.(awserr.Error); ok {
 					if awsErr.Code() == elb.ErrCodeAccessPointNotFoundException {
@@ -439,8 +1159,8 @@ func (mgr *Manager) executeDeregisterWaiters(event *LifecycleEvent, scanResult *
 				"elbType":       "classic-elb",
 				"details":       msg,
 			}
-			publishKubernetesEvent(kubeClient, newKubernetesEvent(EventReasonInstanceDeregisterSucceeded, msgFields))
-			metrics.AddCounter(SuccessfulLBDeregisterTotalMetric, 1)
+			mgr.publishEvent(event, EventReasonInstanceDeregisterSucceeded, msgFields)
+			metrics.AddCounter(SuccessfulLBDeregisterTotalMetric, eventLabels(event), 1)
 		}(elbName, instanceID)
 	}
 
@@ -452,7 +1172,15 @@ func (mgr *Manager) executeDeregisterWaiters(event *LifecycleEvent, scanResult *
 			defer waiter.Done()
 			// wait for deregister/drain
 			log.Debugf("%v> starting target group waiter for %v", instance, activeARN)
-			err := waitForDeregisterTarget(event, elbv2Client, activeARN, instance, activePort)
+			config := waiterConfig
+			if delay, err := getTargetGroupDeregistrationDelay(event.Context(), elbv2Client, activeARN); err != nil {
+				log.Warnf("%v> failed to get deregistration delay of target group %v: %v", instance, activeARN, err)
+			} else {
+				config = waiterConfig.forTimeout(delay + WaiterDrainingMargin)
+				log.Debugf("%v> target group %v deregistration delay is %v, waiting up to %v attempts", instance, activeARN, delay, config.MaxAttempts)
+				mgr.checkDeregistrationDelay(event, activeARN, delay)
+			}
+			err := waitForDeregisterTarget(event, elbv2Client, activeARN, instance, activePort, config)
 			if err != nil {
 				if awsErr, ok := err.(awserr.Error); ok {
 					if awsErr.Code() == elbv2.ErrCodeTargetGroupNotFoundException {
@@ -477,8 +1205,8 @@ func (mgr *Manager) executeDeregisterWaiters(event *LifecycleEvent, scanResult *
 				"elbType":       "alb",
 				"details":       msg,
 			}
-			publishKubernetesEvent(kubeClient, newKubernetesEvent(EventReasonTargetDeregisterSucceeded, msgFields))
-			metrics.AddCounter(SuccessfulLBDeregisterTotalMetric, 1)
+			mgr.publishEvent(event, EventReasonTargetDeregisterSucceeded, msgFields)
+			metrics.AddCounter(SuccessfulLBDeregisterTotalMetric, eventLabels(event), 1)
 		}(arn, instanceID, port)
 	}
 
@@ -488,9 +1216,9 @@ func (mgr *Manager) executeDeregisterWaiters(event *LifecycleEvent, scanResult *
 			case <-waiter.finished:
 				return
 			default:
-				log.Infof("%v> there are %v pending classic-elb waiters", event.EC2InstanceID, waiter.classicWaiterCount)
-				log.Infof("%v> there are %v pending target-group waiters", event.EC2InstanceID, waiter.targetGroupWaiterCount)
-				time.Sleep(180 * time.Second)
+				log.Infof("%v> there are %v pending classic-elb waiters", event.EC2InstanceID, atomic.LoadInt64(&waiter.classicWaiterCount))
+				log.Infof("%v> there are %v pending target-group waiters", event.EC2InstanceID, atomic.LoadInt64(&waiter.targetGroupWaiterCount))
+				time.Sleep(statusInterval)
 			}
 		}
 	}()
@@ -505,7 +1233,6 @@ func (mgr *Manager) drainLoadbalancerTarget(event *LifecycleEvent) error {
 		ctx        = &mgr.context
 		metrics    = mgr.metrics
 		node       = event.referencedNode
-		kubeClient = mgr.authenticator.KubernetesClient
 		errs       error
 		isFinished bool
 	)
@@ -513,18 +1240,31 @@ func (mgr *Manager) drainLoadbalancerTarget(event *LifecycleEvent) error {
 	if !ctx.WithDeregister {
 		return nil
 	}
+
+	if event.settings.SkipDeregister {
+		log.Infof("%v> skipping load balancer deregistration as requested by the hook's notification metadata", instanceID)
+		return nil
+	}
 	log.Infof("%v> starting load balancer drain worker", instanceID)
 
-	metrics.IncGauge(DeregisteringInstancesCountMetric)
-	defer metrics.DecGauge(DeregisteringInstancesCountMetric)
+	metrics.IncGauge(DeregisteringInstancesCountMetric, eventLabels(event))
+	defer metrics.DecGauge(DeregisteringInstancesCountMetric, eventLabels(event))
+
+	deregisterStart := time.Now()
+	event.setDeregisterTiming(deregisterStart, 0)
+	defer func() {
+		duration := time.Since(deregisterStart)
+		event.setDeregisterTiming(deregisterStart, duration)
+		metrics.ObserveHistogram(DeregisterDurationSecondsMetric, eventLabels(event), duration.Seconds())
+	}()
 
 	// add exclusion label
 	log.Debugf("%v> excluding node %v from load balancers", instanceID, node.Name)
-	err := labelNode(ctx.KubectlLocalPath, node.Name, ExcludeLabelKey, ExcludeLabelValue)
+	err := labelNode(mgr.kubeClient(event), node.Name, ExcludeLabelKey, ExcludeLabelValue)
 	if err != nil {
 		return err
 	}
-	err = labelNode(ctx.KubectlLocalPath, node.Name, AlphaExcludeLabelKey, AlphaExcludeLabelValue)
+	err = labelNode(mgr.kubeClient(event), node.Name, AlphaExcludeLabelKey, AlphaExcludeLabelValue)
 	if err != nil {
 		return err
 	}
@@ -532,27 +1272,24 @@ func (mgr *Manager) drainLoadbalancerTarget(event *LifecycleEvent) error {
 	now := time.Now().UTC()
 	nodeCreationTime := node.CreationTimestamp.UTC()
 	nodeAge := int(now.Sub(nodeCreationTime).Minutes())
-	if nodeAge <= NodeAgeCacheTTL {
-		log.Warnf("%v> node younger than %vm was terminated, flushing caches", instanceID, NodeAgeCacheTTL)
+	if nodeAgeCacheFlush := mgr.nodeAgeCacheFlush(); nodeAge <= nodeAgeCacheFlush {
+		log.Warnf("%v> node younger than %vm was terminated, flushing caches", instanceID, nodeAgeCacheFlush)
 		mgr.context.CacheConfig.FlushCache("elasticloadbalancing.DescribeTargetHealth")
 		mgr.context.CacheConfig.FlushCache("elasticloadbalancing.DescribeInstanceHealth")
 	}
 
 	// scan and update targets
 	log.Infof("%v> scanner starting", instanceID)
-	scanResults, err := mgr.scanMembership(event)
+	scanResults, err := mgr.scanMembershipWithTimeout(event)
 	if err != nil {
 		return err
 	}
 
-	waitJitter(ThreadJitterRangeSeconds)
-
-	// trigger deregistrator to start scanning
-	log.Infof("%v> queuing deregistrator", instanceID)
-	deregistrator := &Deregistrator{
-		errors: make(chan DeregistrationError, 0),
-	}
-	go mgr.startDeregistrator(deregistrator)
+	// deregister the targets along with the targets of other events scanned within the batch window
+	log.Infof("%v> queuing deregistration batch", instanceID)
+	batch := mgr.joinDeregistrationBatch()
+	batchDone, eventDone := batch.done, event.Context().Done()
+	deregisterTimeout := phaseTimeout(ctx.DeregisterTimeoutSeconds)
 
 	// create waiters
 	log.Infof("%v> queuing waiters", instanceID)
@@ -561,39 +1298,36 @@ func (mgr *Manager) drainLoadbalancerTarget(event *LifecycleEvent) error {
 		errors:   make(chan WaiterError, 0),
 	}
 	go mgr.executeDeregisterWaiters(event, scanResults, waiter)
+	waiterTimeout := phaseTimeout(ctx.WaiterTimeoutSeconds)
 
 	for {
 
-		if isFinished {
+		// deregistration errors are reported once the batch including the instance completed
+		if isFinished && batchDone == nil {
 			break
 		}
 
 		select {
 		case <-waiter.finished:
-			isFinished = true
-		case err := <-deregistrator.errors:
-			var msgFields map[string]string
-			switch err.Type {
-			case TargetTypeClassicELB:
-				msg := fmt.Sprintf(EventMessageInstanceDeregisterFailed, err.Instances, err.Target, err)
-				msgFields = map[string]string{
-					"elbName":       err.Target,
-					"ec2InstanceId": strings.Join(err.Instances, ","),
-					"elbType":       TargetTypeClassicELB.String(),
-					"details":       msg,
-				}
-			case TargetTypeTargetGroup:
-				msg := fmt.Sprintf(EventMessageTargetDeregisterFailed, err.Instances, err.Target, err)
-				msgFields = map[string]string{
-					"targetGroup":   err.Target,
-					"ec2InstanceId": strings.Join(err.Instances, ","),
-					"elbType":       TargetTypeTargetGroup.String(),
-					"details":       msg,
-				}
+			isFinished, waiterTimeout = true, nil
+		case <-eventDone:
+			// cancelled events stop waiting for the batch, their waiters return on their own
+			eventDone, batchDone = nil, nil
+		case <-batchDone:
+			batchDone, deregisterTimeout = nil, nil
+			for _, err := range batch.instanceErrors(instanceID) {
+				mgr.reportDeregistrationError(event, err)
+				errs = errors.Wrap(err.Error, "deregister failed")
 			}
-			publishKubernetesEvent(kubeClient, newKubernetesEvent(EventReasonInstanceDeregisterFailed, msgFields))
-			errs = errors.Wrap(err.Error, "deregister failed")
-			metrics.AddCounter(FailedLBDeregisterTotalMetric, 1)
+		case <-deregisterTimeout:
+			// the batch still deregisters the instance, its errors are no longer reported to the event
+			batchDone, deregisterTimeout = nil, nil
+			errs = errors.Wrapf(ErrDeregisterTimeout, "deregistration exceeded %vs", ctx.DeregisterTimeoutSeconds)
+		case <-waiterTimeout:
+			// waiters which did not finish return on their own once they run out of attempts
+			isFinished, waiterTimeout = true, nil
+			go discardWaiterErrors(waiter)
+			errs = errors.Wrapf(ErrWaiterTimeout, "waiters exceeded %vs", ctx.WaiterTimeoutSeconds)
 		case err := <-waiter.errors:
 			if err.Error != nil {
 				errs = errors.Wrap(err.Error, "waiter failed")
@@ -606,58 +1340,138 @@ func (mgr *Manager) drainLoadbalancerTarget(event *LifecycleEvent) error {
 	}
 
 	log.Debugf("%v> successfully executed all drainLoadbalancerTarget goroutines", instanceID)
-	event.SetDeregisterCompleted(true)
 	return nil
 }
 
-func (mgr *Manager) handleEvent(event *LifecycleEvent) error {
+// reportDeregistrationError publishes the failed deregistration of an event's instance from a load balancer
+func (mgr *Manager) reportDeregistrationError(event *LifecycleEvent, err DeregistrationError) {
+	var msgFields map[string]string
+	switch err.Type {
+	case TargetTypeClassicELB:
+		msg := fmt.Sprintf(EventMessageInstanceDeregisterFailed, err.Instances, err.Target, err)
+		msgFields = map[string]string{
+			"elbName":       err.Target,
+			"ec2InstanceId": strings.Join(err.Instances, ","),
+			"elbType":       TargetTypeClassicELB.String(),
+			"details":       msg,
+		}
+	case TargetTypeTargetGroup:
+		msg := fmt.Sprintf(EventMessageTargetDeregisterFailed, err.Instances, err.Target, err)
+		msgFields = map[string]string{
+			"targetGroup":   err.Target,
+			"ec2InstanceId": strings.Join(err.Instances, ","),
+			"elbType":       TargetTypeTargetGroup.String(),
+			"details":       msg,
+		}
+	}
+	mgr.publishEvent(event, EventReasonInstanceDeregisterFailed, msgFields)
+	mgr.metrics.AddCounter(FailedLBDeregisterTotalMetric, eventLabels(event), 1)
+}
+
+// startHeartbeat sends heartbeats for an event and reports heartbeats which stop before the event completes
+func (mgr *Manager) startHeartbeat(event *LifecycleEvent) {
 	var (
 		asgClient = mgr.authenticator.ScalingGroupClient
-		errs      error
+		metrics   = mgr.metrics
+	)
+
+	err := sendHeartbeat(asgClient, event, mgr.context.MaxTimeToProcessSeconds, metrics)
+	if err == nil {
+		return
+	}
+
+	log.Errorf("%v> %v", event.EC2InstanceID, err)
+	metrics.AddCounter(HeartbeatStoppedTotalMetric, eventLabels(event), 1)
+	msg := fmt.Sprintf(EventMessageHeartbeatStopped, event.EC2InstanceID, err)
+	mgr.publishEvent(event, EventReasonHeartbeatStopped, getMessageFields(event, msg))
+}
+
+func (mgr *Manager) handleEvent(event *LifecycleEvent) error {
+	var (
+		errs error
 	)
 
 	// send heartbeat at intervals
-	go sendHeartbeat(asgClient, event, mgr.context.MaxTimeToProcessSeconds)
+	go mgr.startHeartbeat(event)
+	go mgr.trackHookDeadline(event)
+
+	// resolve the processing settings of the event's scaling group
+	settings := mgr.resolveEventSettings(event)
+	event.SetSettings(settings)
+	log.Debugf("%v> resolved event settings: %+v", event.EC2InstanceID, settings)
 
 	// Annotate node with InProgressAnnotationKey = EventBody for resuming in case of crash
-	storeMessage, err := serializeMessage(event.message)
+	storeMessage, err := mgr.encodeResumeState(mgr.kubeClient(event), event.referencedNode, event.message)
 	if err != nil {
-		log.Errorf("%v> failed to serialize message for storage, event cannot be restored", event.EC2InstanceID)
+		log.Errorf("%v> failed to store message, event cannot be restored: %v", event.EC2InstanceID, err)
 	} else {
 		annotations := map[string]string{
-			InProgressAnnotationKey: string(storeMessage),
-			QueueNameAnnotationKey:  mgr.context.QueueName,
+			InProgressAnnotationKey: storeMessage,
+			QueueNameAnnotationKey:  mgr.eventQueueName(event),
+		}
+		annotateNode(mgr.kubeClient(event), event.referencedNode.Name, annotations)
+	}
+
+	// the node running lifecycle-manager is terminated last, its drain would otherwise interrupt the other events
+	isSelfNode := mgr.isSelfNode(event)
+	if isSelfNode {
+		if err := mgr.awaitOtherEvents(event); err != nil {
+			return err
 		}
-		annotateNode(mgr.context.KubectlLocalPath, event.referencedNode.Name, annotations)
 	}
 
-	// acquire a semaphore to drain the node, allow up to mgr.maxDrainConcurrency drains in parallel
-	if err := mgr.context.MaxDrainConcurrency.Acquire(context.Background(), 1); err != nil {
+	// nodes with scale-down disabled by cluster-autoscaler are not drained silently
+	if err := mgr.handleScaleDownDisabled(event); err != nil {
 		return err
 	}
-	err = mgr.drainNodeTarget(event)
-	if err != nil {
-		errs = errors.Wrap(err, "failed to drain node")
+
+	// let running jobs complete before their pods are evicted, failures do not stop the termination
+	if err := mgr.awaitJobCompletion(event); err != nil {
+		log.Warnf("%v> job completion wait failed, proceeding with drain: %v", event.EC2InstanceID, err)
+		msg := fmt.Sprintf(EventMessageJobCompletionWaitFailed, event.referencedNode.Name, err)
+		mgr.publishEvent(event, EventReasonJobCompletionWaitFailed, getMessageFields(event, msg))
 	}
 
-	// alb-drain action
-	err = mgr.drainLoadbalancerTarget(event)
-	if err != nil {
-		errs = errors.Wrap(err, "failed to deregister load balancers")
+	// record pod IPs before eviction to follow their deregistration from ip target groups
+	if mgr.context.WithIPTargetWait {
+		podIPs, err := getNodePodIPs(event.Context(), mgr.kubeClient(event), event.referencedNode.Name)
+		if err != nil {
+			log.Errorf("%v> failed to list pods on node %v: %v", event.EC2InstanceID, event.referencedNode.Name, err)
+		}
+		event.SetReferencedPodIPs(podIPs)
 	}
 
+	mgr.setEventPhase(event, mgr.initialEventPhase())
+	errs = mgr.drainAndDeregister(event)
+
 	// clear the state annotation once processing is ended
-	annotations := map[string]string{
-		InProgressAnnotationKey: "",
-		QueueNameAnnotationKey:  "",
-	}
-	annotateNode(mgr.context.KubectlLocalPath, event.referencedNode.Name, annotations)
+	mgr.clearResumeState(mgr.kubeClient(event), event.referencedNode.Name)
 
 	if errs != nil {
 		return errs
 	}
 
-	err = mgr.deleteNodeTarget(event)
+	mgr.setEventPhase(event, PhaseCompleting)
+
+	// hold the termination until a replacement is ready, failures do not stop the termination
+	err = mgr.waitForReplacementCapacity(event)
+	if err != nil {
+		log.Warnf("%v> replacement capacity wait failed, proceeding with termination: %v", event.EC2InstanceID, err)
+		msg := fmt.Sprintf(EventMessageReplacementWaitFailed, event.EC2InstanceID, err)
+		mgr.publishEvent(event, EventReasonReplacementWaitFailed, getMessageFields(event, msg))
+	}
+
+	if isSelfNode {
+		// deleting the node would garbage collect the lifecycle-manager pod before the lifecycle hook is completed,
+		// the node is removed once its instance terminates instead
+		log.Infof("%v> leaving node/%v of lifecycle-manager to be removed once its instance terminates", event.EC2InstanceID, event.referencedNode.Name)
+		return nil
+	}
+	if mgr.context.DeleteNodeAfterComplete {
+		log.Infof("%v> deleting node/%v once its instance is terminating", event.EC2InstanceID, event.referencedNode.Name)
+		return nil
+	}
+	err = mgr.deleteNodeTarget(event.Context(), event)
 	if err != nil {
 		errs = errors.Wrap(err, "failed to delete the node")
 	}
@@ -665,10 +1479,197 @@ func (mgr *Manager) handleEvent(event *LifecycleEvent) error {
 	return nil
 }
 
-func waitJitter(max float64) {
-	min := 0.5
-	rand.Seed(time.Now().UnixNano())
-	r := min + rand.Float64()*(max-min)
-	log.Debugf("adding jitter of %v seconds to waiter\n", r)
-	time.Sleep(time.Duration(r) * time.Second)
+// deleteNodeAfterTermination deletes the node of a completed termination event once its instance is terminating, so
+// that a kubelet still running cannot register the node again after its deletion
+func (mgr *Manager) deleteNodeAfterTermination(event *LifecycleEvent) {
+	var (
+		scalingGroupClient = mgr.authenticator.ScalingGroupClient
+		deadline           = time.Now().Add(NodeDeletionTimeout)
+	)
+
+	for {
+		state, found, err := getInstanceLifecycleState(scalingGroupClient, event.EC2InstanceID)
+		if err != nil {
+			log.Warnf("%v> failed to get lifecycle state: %v", event.EC2InstanceID, err)
+		} else if !found || state != autoscaling.LifecycleStateTerminatingWait {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			log.Warnf("%v> instance is not terminating after %v, leaving node/%v to the cloud node lifecycle controller", event.EC2InstanceID, NodeDeletionTimeout, event.referencedNode.Name)
+			return
+		}
+		select {
+		case <-mgr.ctx.Done():
+			return
+		case <-time.After(NodeDeletionPollInterval):
+		}
+	}
+
+	if err := mgr.deleteNodeTarget(mgr.ctx, event); err != nil {
+		log.Errorf("%v> failed to delete node/%v: %v", event.EC2InstanceID, event.referencedNode.Name, err)
+	}
+}
+
+// drainAndDeregister drains the event's node and removes its instance from load balancers, dns records, cloud map
+// services and global accelerators, a drain semaphore is acquired to drain the node, allowing up to
+// mgr.maxDrainConcurrency drains in parallel. With --deregister-before-drain the instance is removed from its targets
+// before its pods are evicted, so that new connections stop arriving at pods which are about to terminate, and the
+// semaphore is only acquired once deregistration is done so that drain slots are not held while deregistering. With
+// --deregister-during-drain both run concurrently, and the event moves on to the deregistering phase once its node
+// is drained
+func (mgr *Manager) drainAndDeregister(event *LifecycleEvent) error {
+	var errs error
+
+	if mgr.context.DeregisterDuringDrain {
+		if err := mgr.acquireDrainSemaphore(event); err != nil {
+			return err
+		}
+		deregistered := make(chan error, 1)
+		go func() {
+			deregistered <- mgr.deregisterNode(event)
+		}()
+		errs = mgr.drainNodeAndWait(event)
+		mgr.setEventPhase(event, PhaseDeregistering)
+		if err := <-deregistered; err != nil {
+			errs = err
+		}
+	} else if mgr.context.DeregisterBeforeDrain {
+		mgr.setEventPhase(event, PhaseDeregistering)
+		errs = mgr.deregisterNode(event)
+		mgr.setEventPhase(event, PhaseDraining)
+		if err := mgr.acquireDrainSemaphore(event); err != nil {
+			return err
+		}
+		if err := mgr.drainNodeAndWait(event); err != nil {
+			errs = err
+		}
+	} else {
+		if err := mgr.acquireDrainSemaphore(event); err != nil {
+			return err
+		}
+		errs = mgr.drainNodeAndWait(event)
+		mgr.setEventPhase(event, PhaseDeregistering)
+		if err := mgr.deregisterNode(event); err != nil {
+			errs = err
+		}
+	}
+
+	// pod targets only drain from ip target groups once their pods are evicted
+	if err := mgr.waitForIPTargetsDrained(event); err != nil {
+		if err := mgr.handleDeregisterFailure(event, "ip target groups", err); err != nil {
+			errs = err
+		}
+	}
+
+	return errs
+}
+
+// initialEventPhase returns the first processing phase of validated events, instances are deregistered before their
+// node is drained with --deregister-before-drain
+func (mgr *Manager) initialEventPhase() EventPhase {
+	if mgr.context.DeregisterBeforeDrain {
+		return PhaseDeregistering
+	}
+	return PhaseDraining
+}
+
+// drainNodeAndWait drains the event's node, then waits for the evicted pods to be deleted and their volumes to detach
+func (mgr *Manager) drainNodeAndWait(event *LifecycleEvent) error {
+	var (
+		settings = event.settings
+		errs     error
+	)
+
+	err := mgr.drainNodeTarget(event)
+	if err != nil {
+		if settings.DrainFailurePolicy == FailurePolicyContinue {
+			log.Warnf("%v> drain failed, proceeding with termination due to %v policy: %v", event.EC2InstanceID, settings.DrainFailurePolicy, err)
+			msg := fmt.Sprintf(EventMessageNodeDrainFailureIgnored, event.referencedNode.Name, settings.DrainFailurePolicy, err)
+			mgr.publishEvent(event, EventReasonNodeDrainFailureIgnored, getMessageFields(event, msg))
+		} else {
+			event.setPhaseFailed(PhaseDraining)
+			errs = errors.Wrap(err, "failed to drain node")
+		}
+	}
+
+	// wait for evicted pods to be deleted, failures do not stop the termination
+	err = mgr.waitForPodsDeleted(event)
+	if err != nil {
+		log.Warnf("%v> pod deletion wait failed, proceeding with termination: %v", event.EC2InstanceID, err)
+		msg := fmt.Sprintf(EventMessagePodDeletionWaitFailed, event.referencedNode.Name, err)
+		mgr.publishEvent(event, EventReasonPodDeletionWaitFailed, getMessageFields(event, msg))
+	}
+
+	// wait for csi volumes of evicted pods to detach, failures do not stop the termination
+	err = mgr.waitForVolumesDetached(event)
+	if err != nil {
+		log.Warnf("%v> volume detachment wait failed, proceeding with termination: %v", event.EC2InstanceID, err)
+		msg := fmt.Sprintf(EventMessageVolumeDetachWaitFailed, event.referencedNode.Name, err)
+		mgr.publishEvent(event, EventReasonVolumeDetachWaitFailed, getMessageFields(event, msg))
+	}
+
+	return errs
+}
+
+// deregisterNode removes the event's instance from dns records, load balancers, cloud map services and global
+// accelerators
+func (mgr *Manager) deregisterNode(event *LifecycleEvent) error {
+	var (
+		errs error
+		err  error
+	)
+
+	// remove dns records of the node, failures do not stop the termination
+	err = mgr.cleanupDNSRecords(event)
+	if err != nil {
+		log.Warnf("%v> dns record cleanup failed, proceeding with termination: %v", event.EC2InstanceID, err)
+		mgr.metrics.AddCounter(FailedDNSCleanupTotalMetric, eventLabels(event), 1)
+		msg := fmt.Sprintf(EventMessageDNSRecordsCleanupFailed, event.referencedNode.Name, err)
+		mgr.publishEvent(event, EventReasonDNSRecordsCleanupFailed, getMessageFields(event, msg))
+	}
+
+	// alb-drain action
+	err = mgr.drainLoadbalancerTarget(event)
+	if err != nil {
+		if err = mgr.handleDeregisterFailure(event, "load balancers", err); err != nil {
+			errs = err
+		}
+	}
+
+	// cloud map deregistration
+	err = mgr.deregisterCloudMapTarget(event)
+	if err != nil {
+		if err = mgr.handleDeregisterFailure(event, "cloud map services", err); err != nil {
+			errs = err
+		}
+	}
+
+	// global accelerator endpoint removal
+	err = mgr.removeAcceleratorEndpoints(event)
+	if err != nil {
+		if err = mgr.handleDeregisterFailure(event, "global accelerator endpoint groups", err); err != nil {
+			errs = err
+		}
+	}
+
+	return errs
+}
+
+// handleDeregisterFailure applies the event's deregister failure policy, returning nil if termination should continue
+func (mgr *Manager) handleDeregisterFailure(event *LifecycleEvent, targets string, err error) error {
+	var (
+		settings = event.settings
+	)
+
+	if settings.DeregisterFailurePolicy != FailurePolicyContinue {
+		event.setPhaseFailed(PhaseDeregistering)
+		return errors.Wrapf(err, "failed to deregister %v", targets)
+	}
+
+	log.Warnf("%v> deregistration from %v failed, proceeding with termination due to %v policy: %v", event.EC2InstanceID, targets, settings.DeregisterFailurePolicy, err)
+	mgr.metrics.AddCounter(IgnoredLBDeregisterTotalMetric, eventLabels(event), 1)
+	msg := fmt.Sprintf(EventMessageDeregisterFailureIgnored, event.EC2InstanceID, targets, settings.DeregisterFailurePolicy, err)
+	mgr.publishEvent(event, EventReasonDeregisterFailureIgnored, getMessageFields(event, msg))
+	return nil
 }
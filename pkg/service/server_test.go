@@ -3,6 +3,9 @@ package service
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -20,26 +23,27 @@ import (
 )
 
 func init() {
-	ThreadJitterRangeSeconds = 0
 	IterationJitterRangeSeconds = 0
 	WaiterMinDelay = 1 * time.Second
 	WaiterMaxDelay = 2 * time.Second
 	WaiterMaxAttempts = 3
 	NodeAgeCacheTTL = 100
+	LaunchPollInterval = 100 * time.Millisecond
+	HeartbeatRetryDelay = 10 * time.Millisecond
 }
 
 func _completeEventAfter(event *LifecycleEvent, t time.Duration) {
 	time.Sleep(t)
-	event.SetEventCompleted(true)
+	event.setPhase(PhaseDone)
 }
 
 func _newBasicContext() ManagerContext {
 	return ManagerContext{
-		KubectlLocalPath:        stubKubectlPathSuccess,
 		QueueName:               "my-queue",
 		Region:                  "us-west-2",
 		DrainTimeoutSeconds:     1,
 		DrainRetryAttempts:      3,
+		DrainGracePeriodSeconds: -1,
 		PollingIntervalSeconds:  1,
 		MaxDrainConcurrency:     semaphore.NewWeighted(32),
 		MaxTimeToProcessSeconds: 3600,
@@ -81,6 +85,48 @@ func Test_RejectHandler(t *testing.T) {
 	}
 }
 
+func Test_RetryNodeNotFound(t *testing.T) {
+	t.Log("Test_RetryNodeNotFound: should return events to the queue while their node is not found within the grace period")
+	var (
+		sqsStubber = &stubSQS{}
+	)
+
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		SQSClient:          sqsStubber,
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	ctx := _newBasicContext()
+	ctx.NodeNotFoundGraceSeconds = 60
+
+	fakeMessage := &sqs.Message{
+		Body:          aws.String(`{"LifecycleHookName":"my-hook","AccountId":"12345689012","RequestId":"63f5b5c2-58b3-0574-b7d5-b3162d0268f0","LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING","AutoScalingGroupName":"my-asg","Service":"AWS Auto Scaling","Time":"2019-09-27T02:39:14.183Z","EC2InstanceId":"i-123486890234","LifecycleActionToken":"cc34960c-1e41-4703-a665-bdb3e5b81ad3"}`),
+		ReceiptHandle: aws.String("MbZj6wDWli+JvwwJaBV+3dcjk2YW2vA3+STFFljTM8tJJg6HRG6PYSasuWXPJB+Cw="),
+		Attributes: map[string]*string{
+			sqs.MessageSystemAttributeNameSentTimestamp: aws.String(strconv.FormatInt(time.Now().UnixMilli(), 10)),
+		},
+	}
+
+	mgr := New(auth, ctx)
+	event, err := mgr.newEvent(fakeMessage, "some-queue")
+	if errors.Cause(err) != ErrNodeNotFound {
+		t.Fatalf("expected error: %v, got: %v", ErrNodeNotFound, err)
+	}
+
+	if !mgr.RetryEvent(err, event) {
+		t.Fatal("expected event within grace period to be retried")
+	}
+
+	if sqsStubber.timesCalledChangeMessageVisibility != 1 {
+		t.Fatalf("expected timesCalledChangeMessageVisibility: %v, got: %v", 1, sqsStubber.timesCalledChangeMessageVisibility)
+	}
+
+	mgr.context.NodeNotFoundGraceSeconds = 0
+	if mgr.RetryEvent(err, event) {
+		t.Fatal("expected event outside grace period not to be retried")
+	}
+}
+
 func Test_FailHandler(t *testing.T) {
 	t.Log("Test_FailHandler: should handle failures")
 	var (
@@ -130,9 +176,8 @@ func Test_FailHandler(t *testing.T) {
 		t.Fatalf("expected deleted events: %v, got: %v", expectedDeleteMessageEvents, sqsStubber.timesCalledDeleteMessage)
 	}
 
-	expectedEventCompleted := true
-	if event.eventCompleted != expectedEventCompleted {
-		t.Fatalf("expected event completed: %v, got: %v", expectedEventCompleted, event.eventCompleted)
+	if event.Phase() != PhaseFailed {
+		t.Fatalf("expected event phase: %v, got: %v", PhaseFailed, event.Phase())
 	}
 }
 
@@ -179,8 +224,8 @@ func Test_Process(t *testing.T) {
 	g := New(auth, ctx)
 	g.Process(event)
 
-	if event.drainCompleted != true {
-		t.Fatal("handleEvent: expected drainCompleted to be true, got: false")
+	if !event.phaseCompleted(PhaseDraining) {
+		t.Fatalf("handleEvent: expected phase %v to be completed, got phases: %v", PhaseDraining, event.phases)
 	}
 
 	if asgStubber.timesCalledCompleteLifecycleAction != 1 {
@@ -192,6 +237,59 @@ func Test_Process(t *testing.T) {
 	}
 }
 
+func Test_ProcessDeadlineExceeded(t *testing.T) {
+	t.Log("Test_ProcessDeadlineExceeded: should abandon events which exceeded the max time to process")
+	asgStubber := &stubAutoscaling{}
+	sqsStubber := &stubSQS{}
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		SQSClient:          sqsStubber,
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	ctx := _newBasicContext()
+
+	node := &v1.Node{
+		Spec: v1.NodeSpec{
+			ProviderID: "aws:///us-west-2a/i-123486890234",
+		},
+	}
+	auth.KubernetesClient.CoreV1().Nodes().Create(context.Background(), node, apimachinery_v1.CreateOptions{})
+
+	event := &LifecycleEvent{
+		LifecycleHookName:    "my-hook",
+		RequestID:            "63f5b5c2-58b3-0574-b7d5-b3162d0268f0",
+		LifecycleTransition:  "autoscaling:EC2_INSTANCE_TERMINATING",
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-123486890234",
+		LifecycleActionToken: "cc34960c-1e41-4703-a665-bdb3e5b81ad3",
+		heartbeatInterval:    2,
+		referencedNode:       *node,
+	}
+	event.SetContext(context.WithDeadline(context.Background(), time.Now().Add(-time.Second)))
+
+	g := New(auth, ctx)
+	g.Process(event)
+
+	if event.phaseCompleted(PhaseDraining) {
+		t.Fatalf("Process: expected phase %v not to be completed, got phases: %v", PhaseDraining, event.phases)
+	}
+
+	if asgStubber.timesCalledCompleteLifecycleAction != 1 {
+		t.Fatalf("Process: expected timesCalledCompleteLifecycleAction to be 1, got: %v", asgStubber.timesCalledCompleteLifecycleAction)
+	}
+
+	events, _ := auth.KubernetesClient.CoreV1().Events(EventNamespace).List(context.Background(), apimachinery_v1.ListOptions{})
+	var found bool
+	for _, e := range events.Items {
+		if e.Reason == string(EventReasonLifecycleHookDeadlineExceeded) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Process: expected a %v event to be published", EventReasonLifecycleHookDeadlineExceeded)
+	}
+}
+
 func Test_HandleEvent(t *testing.T) {
 	t.Log("Test_HandleEvent: should successfully handle events")
 	asgStubber := &stubAutoscaling{}
@@ -238,8 +336,56 @@ func Test_HandleEvent(t *testing.T) {
 		t.Fatalf("handleEvent: expected error not to have occured, %v", err)
 	}
 
-	if event.drainCompleted != true {
-		t.Fatal("handleEvent: expected drainCompleted to be true, got: false")
+	if !event.phaseCompleted(PhaseDraining) {
+		t.Fatalf("handleEvent: expected phase %v to be completed, got phases: %v", PhaseDraining, event.phases)
+	}
+}
+
+func Test_DeleteNodeAfterComplete(t *testing.T) {
+	t.Log("Test_DeleteNodeAfterComplete: should leave the node after drain and delete it once its instance is terminating")
+	asgStubber := &stubAutoscaling{
+		autoScalingInstances: []*autoscaling.InstanceDetails{
+			{AutoScalingGroupName: aws.String("my-asg"), InstanceId: aws.String("i-123486890234"), LifecycleState: aws.String(autoscaling.LifecycleStateTerminatingProceed)},
+		},
+	}
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		SQSClient:          &stubSQS{},
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	ctx := _newBasicContext()
+	ctx.DeleteNodeAfterComplete = true
+
+	node := v1.Node{
+		ObjectMeta: apimachinery_v1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-123486890234"},
+	}
+	auth.KubernetesClient.CoreV1().Nodes().Create(context.Background(), &node, apimachinery_v1.CreateOptions{})
+
+	event := &LifecycleEvent{
+		LifecycleHookName:    "my-hook",
+		RequestID:            "63f5b5c2-58b3-0574-b7d5-b3162d0268f0",
+		LifecycleTransition:  TerminationEventName,
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-123486890234",
+		referencedNode:       node,
+		heartbeatInterval:    3,
+	}
+
+	mgr := New(auth, ctx)
+	if err := mgr.handleEvent(event); err != nil {
+		t.Fatalf("handleEvent: expected error not to have occured, %v", err)
+	}
+	if _, err := auth.KubernetesClient.CoreV1().Nodes().Get(context.Background(), "node-1", apimachinery_v1.GetOptions{}); err != nil {
+		t.Fatalf("expected node to exist before the hook is completed, got: %v", err)
+	}
+
+	mgr.deleteNodeAfterTermination(event)
+	if _, err := auth.KubernetesClient.CoreV1().Nodes().Get(context.Background(), "node-1", apimachinery_v1.GetOptions{}); err == nil {
+		t.Fatalf("expected node to be deleted once its instance is terminating")
+	}
+	if !event.nodeDeleted {
+		t.Fatalf("expected event node deleted: %v, got: %v", true, event.nodeDeleted)
 	}
 }
 
@@ -298,6 +444,7 @@ func Test_HandleEventWithDeregister(t *testing.T) {
 	ctx := _newBasicContext()
 	ctx.WithDeregister = true
 	ctx.DeregisterTargetTypes = []string{TargetTypeClassicELB.String(), TargetTypeTargetGroup.String()}
+	ctx.DeregisterFullScanFallback = true
 
 	fakeNodes := []v1.Node{
 		{
@@ -334,12 +481,183 @@ func Test_HandleEventWithDeregister(t *testing.T) {
 		t.Fatalf("handleEvent: expected error not to have occured, %v", err)
 	}
 
-	if event.drainCompleted != true {
-		t.Fatal("handleEvent: expected drainCompleted to be true, got: false")
+	if !event.phaseCompleted(PhaseDraining) {
+		t.Fatalf("handleEvent: expected phase %v to be completed, got phases: %v", PhaseDraining, event.phases)
+	}
+
+	if !event.phaseCompleted(PhaseDeregistering) {
+		t.Fatalf("handleEvent: expected phase %v to be completed, got phases: %v", PhaseDeregistering, event.phases)
+	}
+}
+
+func Test_HandleEventDeregisterOrder(t *testing.T) {
+	t.Log("Test_HandleEventDeregisterOrder: should deregister instances before or while draining their node")
+	tests := []struct {
+		before   bool
+		during   bool
+		expected []EventPhase
+	}{
+		{false, false, []EventPhase{PhaseDraining, PhaseDeregistering, PhaseCompleting}},
+		{true, false, []EventPhase{PhaseDeregistering, PhaseDraining, PhaseCompleting}},
+		{false, true, []EventPhase{PhaseDraining, PhaseDeregistering, PhaseCompleting}},
+	}
+
+	for _, tc := range tests {
+		var (
+			asgStubber = &stubAutoscaling{}
+			sqsStubber = &stubSQS{}
+			arn        = "arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/targetgroup-name/some-id"
+			instanceID = "i-123486890234"
+		)
+
+		elbv2Stubber := &stubELBv2{
+			targetHealthDescriptions: []*elbv2.TargetHealthDescription{
+				{
+					Target: &elbv2.TargetDescription{
+						Id:   aws.String(instanceID),
+						Port: aws.Int64(122233),
+					},
+					TargetHealth: &elbv2.TargetHealth{
+						State: aws.String(elbv2.TargetHealthStateEnumUnused),
+					},
+				},
+			},
+			targetGroups: []*elbv2.TargetGroup{
+				{
+					TargetGroupArn: aws.String(arn),
+				},
+			},
+		}
+
+		auth := Authenticator{
+			ScalingGroupClient: asgStubber,
+			SQSClient:          sqsStubber,
+			ELBv2Client:        elbv2Stubber,
+			ELBClient:          &stubELB{},
+			KubernetesClient:   fake.NewSimpleClientset(),
+		}
+
+		ctx := _newBasicContext()
+		ctx.WithDeregister = true
+		ctx.DeregisterTargetTypes = []string{TargetTypeTargetGroup.String()}
+		ctx.DeregisterFullScanFallback = true
+		ctx.DeregisterBeforeDrain = tc.before
+		ctx.DeregisterDuringDrain = tc.during
+
+		node := &v1.Node{
+			Spec: v1.NodeSpec{
+				ProviderID: fmt.Sprintf("aws:///us-west-2a/%v", instanceID),
+			},
+		}
+		auth.KubernetesClient.CoreV1().Nodes().Create(context.Background(), node, apimachinery_v1.CreateOptions{})
+
+		event := &LifecycleEvent{
+			LifecycleHookName:    "my-hook",
+			AccountID:            "12345689012",
+			RequestID:            "63f5b5c2-58b3-0574-b7d5-b3162d0268f0",
+			LifecycleTransition:  "autoscaling:EC2_INSTANCE_TERMINATING",
+			AutoScalingGroupName: "my-asg",
+			EC2InstanceID:        instanceID,
+			LifecycleActionToken: "cc34960c-1e41-4703-a665-bdb3e5b81ad3",
+			receiptHandle:        "MbZj6wDWli+JvwwJaBV+3dcjk2YW2vA3+STFFljTM8tJJg6HRG6PYSasuWXPJB+Cw=",
+			heartbeatInterval:    3,
+		}
+
+		g := New(auth, ctx)
+		err := g.handleEvent(event)
+		if err != nil {
+			t.Fatalf("handleEvent: expected error not to have occured, %v", err)
+		}
+
+		if elbv2Stubber.timesCalledDeregisterTargets != 1 {
+			t.Fatalf("handleEvent: expected DeregisterTargets to be called once, got: %v", elbv2Stubber.timesCalledDeregisterTargets)
+		}
+
+		phases := event.phases[len(event.phases)-len(tc.expected):]
+		if !reflect.DeepEqual(phases, tc.expected) {
+			t.Fatalf("handleEvent: expected phases %v, got: %v", tc.expected, event.phases)
+		}
+
+		if !event.phaseCompleted(PhaseDraining) || !event.phaseCompleted(PhaseDeregistering) {
+			t.Fatalf("handleEvent: expected phases %v and %v to be completed, got phases: %v", PhaseDeregistering, PhaseDraining, event.phases)
+		}
+	}
+}
+
+func Test_HandleEventDeregisterBeforeDrainSlots(t *testing.T) {
+	t.Log("Test_HandleEventDeregisterBeforeDrainSlots: should deregister instances before waiting for a drain slot")
+	var (
+		arn        = "arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/targetgroup-name/some-id"
+		instanceID = "i-123486890234"
+	)
+
+	elbv2Stubber := &stubELBv2{
+		targetHealthDescriptions: []*elbv2.TargetHealthDescription{
+			{
+				Target: &elbv2.TargetDescription{
+					Id:   aws.String(instanceID),
+					Port: aws.Int64(122233),
+				},
+				TargetHealth: &elbv2.TargetHealth{
+					State: aws.String(elbv2.TargetHealthStateEnumUnused),
+				},
+			},
+		},
+		targetGroups: []*elbv2.TargetGroup{
+			{
+				TargetGroupArn: aws.String(arn),
+			},
+		},
+	}
+
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		SQSClient:          &stubSQS{},
+		ELBv2Client:        elbv2Stubber,
+		ELBClient:          &stubELB{},
+		KubernetesClient:   fake.NewSimpleClientset(),
 	}
 
-	if event.deregisterCompleted != true {
-		t.Fatal("handleEvent: expected deregisterCompleted to be true, got: false")
+	ctx := _newBasicContext()
+	ctx.WithDeregister = true
+	ctx.DeregisterTargetTypes = []string{TargetTypeTargetGroup.String()}
+	ctx.DeregisterFullScanFallback = true
+	ctx.DeregisterBeforeDrain = true
+	// every drain slot is held by another event
+	ctx.MaxDrainConcurrency = semaphore.NewWeighted(1)
+	ctx.MaxDrainConcurrency.Acquire(context.Background(), 1)
+
+	node := &v1.Node{
+		Spec: v1.NodeSpec{
+			ProviderID: fmt.Sprintf("aws:///us-west-2a/%v", instanceID),
+		},
+	}
+	auth.KubernetesClient.CoreV1().Nodes().Create(context.Background(), node, apimachinery_v1.CreateOptions{})
+
+	event := &LifecycleEvent{
+		LifecycleHookName:    "my-hook",
+		RequestID:            "63f5b5c2-58b3-0574-b7d5-b3162d0268f0",
+		LifecycleTransition:  TerminationEventName,
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        instanceID,
+		LifecycleActionToken: "cc34960c-1e41-4703-a665-bdb3e5b81ad3",
+		heartbeatInterval:    3,
+	}
+	event.SetContext(context.WithCancel(context.Background()))
+	// the event is cancelled while it waits for a drain slot
+	time.AfterFunc(500*time.Millisecond, event.cancel)
+
+	g := New(auth, ctx)
+	if err := g.handleEvent(event); err == nil {
+		t.Fatal("handleEvent: expected error to have occured while every drain slot is held")
+
+	}
+
+	if elbv2Stubber.timesCalledDeregisterTargets != 1 {
+		t.Fatalf("handleEvent: expected DeregisterTargets to be called once, got: %v", elbv2Stubber.timesCalledDeregisterTargets)
+	}
+	if !event.phaseCompleted(PhaseDeregistering) {
+		t.Fatalf("handleEvent: expected phase %v to be completed, got phases: %v", PhaseDeregistering, event.phases)
 	}
 }
 
@@ -400,6 +718,7 @@ func Test_HandleEventWithDeregisterError(t *testing.T) {
 	ctx := _newBasicContext()
 	ctx.WithDeregister = true
 	ctx.DeregisterTargetTypes = []string{TargetTypeClassicELB.String(), TargetTypeTargetGroup.String()}
+	ctx.DeregisterFullScanFallback = true
 
 	fakeNodes := []v1.Node{
 		{
@@ -435,6 +754,13 @@ func Test_HandleEventWithDeregisterError(t *testing.T) {
 	if err == nil {
 		t.Fatalf("handleEvent: expected error but did not get an error")
 	}
+
+	ctx.DeregisterFailurePolicy = FailurePolicyContinue
+	g = New(auth, ctx)
+	err = g.handleEvent(event)
+	if err != nil {
+		t.Fatalf("handleEvent: expected deregister error to be ignored with %v policy, got: %v", FailurePolicyContinue, err)
+	}
 }
 
 func Test_Poller(t *testing.T) {
@@ -442,7 +768,7 @@ func Test_Poller(t *testing.T) {
 	var (
 		fakeQueueName   = "my-queue"
 		fakeMessageBody = "message-body"
-		fakeEventStream = make(chan *sqs.Message, 0)
+		fakeEventStream = make(chan *receivedMessage, 0)
 	)
 	sqsStubber := &stubSQS{
 		FakeQueueName: fakeQueueName,
@@ -461,20 +787,64 @@ func Test_Poller(t *testing.T) {
 
 	mgr := New(auth, ctx)
 	mgr.eventStream = fakeEventStream
+	queueURL := getQueueURL(sqsStubber, &mgr.context)
 
-	go mgr.newPoller()
+	go mgr.newPoller(mgr.ctx, queueURL)
 	time.Sleep(time.Duration(1) * time.Second)
 
 	if sqsStubber.timesCalledReceiveMessage == 0 {
 		t.Fatalf("expected timesCalledReceiveMessage: N>0, got: 0")
 	}
 
-	message := <-fakeEventStream
-	if aws.StringValue(message.Body) != fakeMessageBody {
-		t.Fatalf("expected message body: %v, got: %v", fakeMessageBody, message.Body)
+	received := <-fakeEventStream
+	if aws.StringValue(received.message.Body) != fakeMessageBody {
+		t.Fatalf("expected message body: %v, got: %v", fakeMessageBody, received.message.Body)
+	}
+	if received.queueURL != queueURL {
+		t.Fatalf("expected queue url: %v, got: %v", queueURL, received.queueURL)
 	}
 }
 
+func Test_PollerBackpressure(t *testing.T) {
+	t.Log("Test_PollerBackpressure: should not poll for messages while the in-flight event limit is reached")
+	var (
+		sqsStubber = &stubSQS{FakeQueueName: "my-queue"}
+	)
+
+	auth := Authenticator{
+		SQSClient: sqsStubber,
+	}
+
+	ctx := _newBasicContext()
+	ctx.MaxInFlightEvents = 1
+
+	mgr := New(auth, ctx)
+	mgr.inFlightEvents = 1
+
+	if !mgr.atCapacity() {
+		t.Fatalf("expected atCapacity: %v, got: %v", true, false)
+	}
+
+	mgr.context.MaxInFlightEvents = 0
+	mgr.context.WorkerPoolSize = 2
+	if mgr.atCapacity() {
+		t.Fatalf("expected atCapacity with an idle worker: %v, got: %v", false, true)
+	}
+
+	mgr.context.WorkerPoolSize = 1
+	if !mgr.atCapacity() {
+		t.Fatalf("expected atCapacity with all workers busy: %v, got: %v", true, false)
+	}
+
+	go mgr.newPoller(mgr.ctx, getQueueURL(sqsStubber, &mgr.context))
+	time.Sleep(time.Duration(1) * time.Second)
+
+	if sqsStubber.timesCalledReceiveMessage != 0 {
+		t.Fatalf("expected timesCalledReceiveMessage: 0, got: %v", sqsStubber.timesCalledReceiveMessage)
+	}
+
+}
+
 func Test_Worker(t *testing.T) {
 	t.Log("Test_Worker: should start processing messages")
 	var (
@@ -534,3 +904,240 @@ func Test_Worker(t *testing.T) {
 	}
 
 }
+
+func Test_WorkerPool(t *testing.T) {
+	t.Log("Test_WorkerPool: should process dispatched events with a fixed number of workers")
+	var (
+		sqsStubber = &stubSQS{}
+	)
+
+	asgStubber := &stubAutoscaling{
+		lifecycleHooks: []*autoscaling.LifecycleHook{
+			{
+				AutoScalingGroupName: aws.String("my-asg"),
+				HeartbeatTimeout:     aws.Int64(60),
+			},
+		},
+	}
+
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		SQSClient:          sqsStubber,
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+
+	ctx := _newBasicContext()
+
+	fakeNodes := []v1.Node{
+		{
+			Spec: v1.NodeSpec{
+				ProviderID: "aws:///us-west-2a/i-123486890234",
+			},
+		},
+		{
+			Spec: v1.NodeSpec{
+				ProviderID: "aws:///us-west-2c/i-22222222222222222",
+			},
+		},
+	}
+
+	for _, node := range fakeNodes {
+		auth.KubernetesClient.CoreV1().Nodes().Create(context.Background(), &node, apimachinery_v1.CreateOptions{})
+	}
+
+	fakeMessage := &sqs.Message{
+		Body:          aws.String(`{"LifecycleHookName":"my-hook","AccountId":"12345689012","RequestId":"63f5b5c2-58b3-0574-b7d5-b3162d0268f0","LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING","AutoScalingGroupName":"my-asg","Service":"AWS Auto Scaling","Time":"2019-09-27T02:39:14.183Z","EC2InstanceId":"i-123486890234","LifecycleActionToken":"cc34960c-1e41-4703-a665-bdb3e5b81ad3"}`),
+		ReceiptHandle: aws.String("MbZj6wDWli+JvwwJaBV+3dcjk2YW2vA3+STFFljTM8tJJg6HRG6PYSasuWXPJB+Cw="),
+	}
+
+	mgr := New(auth, ctx)
+	event, err := mgr.newEvent(fakeMessage, "some-queue")
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+
+	mgr.context.WorkerPoolSize = 1
+	mgr.startWorkers(mgr.context.WorkerPoolSize)
+	mgr.dispatchEvent(event)
+
+	for i := 0; i < 100 && atomic.LoadInt64(&mgr.inFlightEvents) != 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	expectedCompletedEvents := 1
+
+	if mgr.completedEvents != expectedCompletedEvents {
+		t.Fatalf("expected completed events: %v, got: %v", expectedCompletedEvents, mgr.completedEvents)
+	}
+
+}
+
+func Test_ScanMembershipAttached(t *testing.T) {
+	t.Log("Test_ScanMembershipAttached: should only scan load balancers attached to the scaling group")
+	var (
+		attachedARN       = "arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/attached/some-id"
+		otherARN          = "arn:aws:elasticloadbalancing:us-west-2:0000000000:targetgroup/other/some-id"
+		instanceID        = "i-123486890234"
+		port        int64 = 122233
+	)
+
+	asgStubber := &stubAutoscaling{
+		attachedTargetGroups: []*autoscaling.LoadBalancerTargetGroupState{
+			{
+				LoadBalancerTargetGroupARN: aws.String(attachedARN),
+			},
+		},
+	}
+
+	elbv2Stubber := &stubELBv2{
+		targetHealthDescriptions: []*elbv2.TargetHealthDescription{
+			{
+				Target: &elbv2.TargetDescription{
+					Id:   aws.String(instanceID),
+					Port: aws.Int64(port),
+				},
+			},
+		},
+		targetGroups: []*elbv2.TargetGroup{
+			{
+				TargetGroupArn: aws.String(otherARN),
+			},
+		},
+	}
+
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		ELBv2Client:        elbv2Stubber,
+		ELBClient:          &stubELB{},
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+
+	ctx := _newBasicContext()
+	ctx.WithDeregister = true
+	ctx.DeregisterTargetTypes = []string{TargetTypeClassicELB.String(), TargetTypeTargetGroup.String()}
+	ctx.DeregisterFullScanFallback = true
+
+	event := &LifecycleEvent{
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        instanceID,
+	}
+
+	mgr := New(auth, ctx)
+	result, err := mgr.scanMembership(event.Context(), event)
+	if err != nil {
+		t.Fatalf("scanMembership: expected error not to have occured, %v", err)
+	}
+
+	if elbv2Stubber.timesCalledDescribeTargetGroups != 0 {
+		t.Fatalf("expected timesCalledDescribeTargetGroups: %v, got: %v", 0, elbv2Stubber.timesCalledDescribeTargetGroups)
+	}
+
+	if result.ActiveTargetGroups[attachedARN] != port || len(result.ActiveTargetGroups) != 1 {
+		t.Fatalf("expected active target groups: %v, got: %v", map[string]int64{attachedARN: port}, result.ActiveTargetGroups)
+	}
+}
+
+func Test_AcquireDrainSemaphore(t *testing.T) {
+	t.Log("Test_AcquireDrainSemaphore: should wait for a drain slot until the event is done")
+	var (
+		ctx = _newBasicContext()
+	)
+
+	ctx.MaxDrainConcurrency = semaphore.NewWeighted(1)
+	mgr := New(Authenticator{}, ctx)
+
+	event := &LifecycleEvent{EC2InstanceID: "i-123456789012"}
+	event.SetContext(context.WithTimeout(context.Background(), 100*time.Millisecond))
+	defer event.cancel()
+
+	if err := mgr.acquireDrainSemaphore(event); err != nil {
+		t.Fatalf("acquireDrainSemaphore: expected error not to have occured, %v", err)
+	}
+
+	if err := mgr.acquireDrainSemaphore(event); err == nil {
+		t.Fatalf("acquireDrainSemaphore: expected error to have occured while the slot is held")
+	}
+
+	ctx.MaxDrainConcurrency.Release(1)
+	event.SetContext(context.WithCancel(context.Background()))
+	if err := mgr.acquireDrainSemaphore(event); err != nil {
+		t.Fatalf("acquireDrainSemaphore: expected error not to have occured, %v", err)
+	}
+}
+
+func Test_AcquireDrainSemaphoreScalingGroup(t *testing.T) {
+	t.Log("Test_AcquireDrainSemaphoreScalingGroup: should limit concurrent drains per scaling group")
+	var (
+		ctx = _newBasicContext()
+	)
+
+	mgr := New(Authenticator{}, ctx)
+
+	newEvent := func(scalingGroup string) *LifecycleEvent {
+		event := &LifecycleEvent{EC2InstanceID: "i-123456789012", AutoScalingGroupName: scalingGroup}
+		event.SetContext(context.WithTimeout(context.Background(), 100*time.Millisecond))
+		event.SetSettings(EventSettings{MaxDrainConcurrency: 1})
+		return event
+	}
+
+	first := newEvent("ingress-nodes")
+	if err := mgr.acquireDrainSemaphore(first); err != nil {
+		t.Fatalf("acquireDrainSemaphore: expected error not to have occured, %v", err)
+	}
+
+	if err := mgr.acquireDrainSemaphore(newEvent("ingress-nodes")); err == nil {
+		t.Fatalf("acquireDrainSemaphore: expected error to have occured while the scaling group slot is held")
+	}
+
+	if err := mgr.acquireDrainSemaphore(newEvent("worker-nodes")); err != nil {
+		t.Fatalf("acquireDrainSemaphore: expected error not to have occured for another scaling group, %v", err)
+	}
+
+	mgr.releaseDrainSemaphore(first, true)
+	if err := mgr.acquireDrainSemaphore(newEvent("ingress-nodes")); err != nil {
+		t.Fatalf("acquireDrainSemaphore: expected error not to have occured after release, %v", err)
+	}
+}
+
+func Test_ValidateMessageSource(t *testing.T) {
+	t.Log("Test_ValidateMessageSource: should reject messages of accounts and senders which are not allowed")
+	var (
+		ctx = _newBasicContext()
+	)
+
+	ctx.AllowedAccountIDs = []string{"123456789012"}
+	ctx.AllowedSenderIDs = []string{"AROAEXAMPLE"}
+	mgr := New(Authenticator{KubernetesClient: fake.NewSimpleClientset()}, ctx)
+
+	newEvent := func(accountID, senderID string) *LifecycleEvent {
+		event := &LifecycleEvent{AccountID: accountID, EC2InstanceID: "i-123456789012"}
+		event.SetReceiptHandle("receipt")
+		event.SetMessage(&sqs.Message{
+			Attributes: map[string]*string{
+				sqs.MessageSystemAttributeNameSenderId: aws.String(senderID),
+			},
+		})
+		return event
+	}
+
+	if err := mgr.validateMessageSource(newEvent("123456789012", "AROAEXAMPLE:AutoScaling")); err != nil {
+		t.Fatalf("validateMessageSource: expected error not to have occured, %v", err)
+	}
+
+	if err := mgr.validateMessageSource(newEvent("000000000000", "AROAEXAMPLE:AutoScaling")); errors.Cause(err) != ErrUntrustedMessage {
+		t.Fatalf("validateMessageSource: expected error: %v, got: %v", ErrUntrustedMessage, err)
+	}
+
+	if err := mgr.validateMessageSource(newEvent("123456789012", "AIDAOTHER")); errors.Cause(err) != ErrUntrustedMessage {
+		t.Fatalf("validateMessageSource: expected error: %v, got: %v", ErrUntrustedMessage, err)
+	}
+
+	event := newEvent("123456789012", "AROAEXAMPLE:AutoScaling")
+	event.snsEnvelope = &SNSEnvelope{TopicArn: "arn:aws:sns:us-west-2:000000000000:lifecycle-topic"}
+	if err := mgr.validateMessageSource(event); errors.Cause(err) != ErrUntrustedMessage {
+		t.Fatalf("validateMessageSource: expected error for a topic of another account: %v, got: %v", ErrUntrustedMessage, err)
+	}
+
+	if err := mgr.validateMessageSource(&LifecycleEvent{EC2InstanceID: "i-123456789012"}); err != nil {
+		t.Fatalf("validateMessageSource: expected reconciled events not to be validated, %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+// FailurePolicy defines how a failure to process an event is handled
+type FailurePolicy string
+
+func (p FailurePolicy) String() string {
+	return string(p)
+}
+
+const (
+	// FailurePolicyAbandon completes the lifecycle hook with ABANDON on failure
+	FailurePolicyAbandon FailurePolicy = "abandon"
+	// FailurePolicyContinue proceeds with the termination on failure
+	FailurePolicyContinue FailurePolicy = "continue"
+)
+
+var (
+	// DrainTimeoutTagKey is the scaling group tag key overriding the drain timeout in seconds
+	DrainTimeoutTagKey = "lifecycle-manager.keikoproj.io/drain-timeout"
+	// DrainRetryIntervalTagKey is the scaling group tag key overriding the drain retry interval in seconds
+	DrainRetryIntervalTagKey = "lifecycle-manager.keikoproj.io/drain-retry-interval"
+	// DrainRetryAttemptsTagKey is the scaling group tag key overriding the number of drain attempts
+	DrainRetryAttemptsTagKey = "lifecycle-manager.keikoproj.io/drain-retries"
+	// DrainFailurePolicyTagKey is the scaling group tag key overriding the drain failure policy
+	DrainFailurePolicyTagKey = "lifecycle-manager.keikoproj.io/drain-failure-policy"
+	// DeregisterFailurePolicyTagKey is the scaling group tag key overriding the load balancer deregistration failure policy
+	DeregisterFailurePolicyTagKey = "lifecycle-manager.keikoproj.io/deregister-failure-policy"
+	// MaxDrainConcurrencyTagKey is the scaling group tag key overriding the maximum number of its nodes draining at once
+	MaxDrainConcurrencyTagKey = "lifecycle-manager.keikoproj.io/max-drain-concurrency"
+	// InstanceRefreshMaxDrainConcurrencyTagKey is the scaling group tag key overriding the maximum number of its nodes
+	// draining at once during an instance refresh
+	InstanceRefreshMaxDrainConcurrencyTagKey = "lifecycle-manager.keikoproj.io/instance-refresh-max-drain-concurrency"
+	// DrainEscalationTimeoutTagKey is the scaling group tag key overriding the seconds after which a drain deletes the
+	// pods it could not evict
+	DrainEscalationTimeoutTagKey = "lifecycle-manager.keikoproj.io/drain-escalation-timeout"
+	// DrainEscalationGracePeriodTagKey is the scaling group tag key overriding the termination grace period of pods
+	// deleted by an escalated drain
+	DrainEscalationGracePeriodTagKey = "lifecycle-manager.keikoproj.io/drain-escalation-grace-period"
+)
+
+// EventSettings holds the processing settings resolved for a specific event
+type EventSettings struct {
+	DrainTimeoutSeconds       int64
+	DrainRetryIntervalSeconds int64
+	DrainRetryAttempts        uint
+	DrainFailurePolicy        FailurePolicy
+	DeregisterFailurePolicy   FailurePolicy
+	MaxDrainConcurrency       int64
+	// InstanceRefreshMaxDrainConcurrency replaces MaxDrainConcurrency for events of an instance refresh when set
+	InstanceRefreshMaxDrainConcurrency int64
+	SkipDeregister                     bool
+	CordonOnly                         bool
+	// DrainEscalationTimeoutSeconds is the time after which pods which could not be evicted are deleted, 0 disables it
+	DrainEscalationTimeoutSeconds     int64
+	DrainEscalationGracePeriodSeconds int64
+}
+
+// HookMetadata holds the overrides lifecycle hook authors can set in the hook's notification metadata as a JSON object
+type HookMetadata struct {
+	DrainTimeout            *int64  `json:"drainTimeout,omitempty"`
+	DrainFailurePolicy      *string `json:"drainFailurePolicy,omitempty"`
+	DeregisterFailurePolicy *string `json:"deregisterFailurePolicy,omitempty"`
+	SkipDeregister          *bool   `json:"skipDeregister,omitempty"`
+	CordonOnly              *bool   `json:"cordonOnly,omitempty"`
+}
+
+// IsValidFailurePolicy returns true if policy is a known failure policy
+func IsValidFailurePolicy(policy string) bool {
+	switch FailurePolicy(policy) {
+	case FailurePolicyAbandon, FailurePolicyContinue:
+		return true
+	}
+	return false
+}
+
+// defaultEventSettings returns the event settings derived from the manager context
+func (mgr *Manager) defaultEventSettings() EventSettings {
+	var (
+		ctx                     = &mgr.context
+		drainFailurePolicy      = FailurePolicyAbandon
+		deregisterFailurePolicy = FailurePolicyAbandon
+	)
+	if ctx.DrainFailurePolicy != "" {
+		drainFailurePolicy = ctx.DrainFailurePolicy
+	}
+	if ctx.DeregisterFailurePolicy != "" {
+		deregisterFailurePolicy = ctx.DeregisterFailurePolicy
+	}
+	return EventSettings{
+		DrainTimeoutSeconds:               ctx.DrainTimeoutSeconds,
+		DrainRetryIntervalSeconds:         ctx.DrainRetryIntervalSeconds,
+		DrainRetryAttempts:                ctx.DrainRetryAttempts,
+		DrainFailurePolicy:                drainFailurePolicy,
+		DeregisterFailurePolicy:           deregisterFailurePolicy,
+		DrainEscalationTimeoutSeconds:     ctx.DrainEscalationTimeoutSeconds,
+		DrainEscalationGracePeriodSeconds: ctx.DrainEscalationGracePeriodSeconds,
+	}
+}
+
+// resolveEventSettings applies the scaling group overrides on top of the default settings
+func (mgr *Manager) resolveEventSettings(event *LifecycleEvent) EventSettings {
+	var (
+		asgClient = mgr.authenticator.ScalingGroupClient
+		settings  = mgr.defaultEventSettings()
+	)
+
+	settings.MaxDrainConcurrency = mgr.context.ScalingGroupMaxDrainConcurrency[event.AutoScalingGroupName]
+	settings.InstanceRefreshMaxDrainConcurrency = mgr.context.InstanceRefreshMaxDrainConcurrency
+
+	tags, err := getScalingGroupTags(asgClient, event.AutoScalingGroupName)
+	if err != nil {
+		log.Warnf("%v> failed to get tags of scaling group %v, using default settings: %v", event.EC2InstanceID, event.AutoScalingGroupName, err)
+	} else {
+		settings.applyTags(event.EC2InstanceID, tags)
+	}
+
+	// instance groups are reconciled into their scaling group and override its tags
+	mgr.applyInstanceGroup(event, &settings)
+
+	if event.policyDecision != nil {
+		settings.applyOverrides(event.EC2InstanceID, "policy", event.policyDecision.HookMetadata)
+	}
+
+	// the hook's metadata is the most specific source and is applied last
+	settings.applyMetadata(event.EC2InstanceID, event.NotificationMetadata)
+
+	if event.isInstanceRefresh() && settings.InstanceRefreshMaxDrainConcurrency > 0 {
+		settings.MaxDrainConcurrency = settings.InstanceRefreshMaxDrainConcurrency
+	}
+	return settings
+}
+
+// applyMetadata overrides settings with the values of the hook's notification metadata, metadata which is not a JSON
+// object is not meant for lifecycle-manager and is ignored
+func (s *EventSettings) applyMetadata(instanceID, metadata string) {
+	if !strings.HasPrefix(strings.TrimSpace(metadata), "{") {
+		return
+	}
+
+	overrides := HookMetadata{}
+	if err := json.Unmarshal([]byte(metadata), &overrides); err != nil {
+		log.Warnf("%v> ignoring invalid notification metadata: %v", instanceID, err)
+		return
+	}
+	s.applyOverrides(instanceID, "notification metadata", overrides)
+}
+
+// applyOverrides overrides settings with the values set in overrides, source names them in warnings
+func (s *EventSettings) applyOverrides(instanceID, source string, overrides HookMetadata) {
+	if v := overrides.DrainTimeout; v != nil {
+		if *v >= 0 {
+			s.DrainTimeoutSeconds = *v
+		} else {
+			log.Warnf("%v> ignoring invalid value '%v' for %v drainTimeout", instanceID, *v, source)
+		}
+	}
+	if v := overrides.DrainFailurePolicy; v != nil {
+		if IsValidFailurePolicy(*v) {
+			s.DrainFailurePolicy = FailurePolicy(*v)
+		} else {
+			log.Warnf("%v> ignoring invalid value '%v' for %v drainFailurePolicy", instanceID, *v, source)
+		}
+	}
+	if v := overrides.DeregisterFailurePolicy; v != nil {
+		if IsValidFailurePolicy(*v) {
+			s.DeregisterFailurePolicy = FailurePolicy(*v)
+		} else {
+			log.Warnf("%v> ignoring invalid value '%v' for %v deregisterFailurePolicy", instanceID, *v, source)
+		}
+	}
+	if v := overrides.SkipDeregister; v != nil {
+		s.SkipDeregister = *v
+	}
+	if v := overrides.CordonOnly; v != nil {
+		s.CordonOnly = *v
+	}
+}
+
+// applyTags overrides settings with the values of known scaling group tags
+func (s *EventSettings) applyTags(instanceID string, tags map[string]string) {
+	for key, value := range tags {
+		value = strings.TrimSpace(value)
+		switch key {
+		case DrainTimeoutTagKey:
+			if v, err := strconv.ParseInt(value, 10, 64); err == nil && v >= 0 {
+				s.DrainTimeoutSeconds = v
+				continue
+			}
+		case DrainRetryIntervalTagKey:
+			if v, err := strconv.ParseInt(value, 10, 64); err == nil && v >= 0 {
+				s.DrainRetryIntervalSeconds = v
+				continue
+			}
+		case DrainRetryAttemptsTagKey:
+			if v, err := strconv.ParseUint(value, 10, 32); err == nil && v > 0 {
+				s.DrainRetryAttempts = uint(v)
+				continue
+			}
+		case DrainFailurePolicyTagKey:
+			if IsValidFailurePolicy(value) {
+				s.DrainFailurePolicy = FailurePolicy(value)
+				continue
+			}
+		case DeregisterFailurePolicyTagKey:
+			if IsValidFailurePolicy(value) {
+				s.DeregisterFailurePolicy = FailurePolicy(value)
+				continue
+			}
+		case MaxDrainConcurrencyTagKey:
+			if v, err := strconv.ParseInt(value, 10, 64); err == nil && v >= 0 {
+				s.MaxDrainConcurrency = v
+				continue
+			}
+		case InstanceRefreshMaxDrainConcurrencyTagKey:
+			if v, err := strconv.ParseInt(value, 10, 64); err == nil && v >= 0 {
+				s.InstanceRefreshMaxDrainConcurrency = v
+				continue
+			}
+		case DrainEscalationTimeoutTagKey:
+			if v, err := strconv.ParseInt(value, 10, 64); err == nil && v >= 0 {
+				s.DrainEscalationTimeoutSeconds = v
+				continue
+			}
+		case DrainEscalationGracePeriodTagKey:
+			if v, err := strconv.ParseInt(value, 10, 64); err == nil && v >= -1 {
+				s.DrainEscalationGracePeriodSeconds = v
+				continue
+			}
+		default:
+			continue
+		}
+		log.Warnf("%v> ignoring invalid value '%v' for scaling group tag %v", instanceID, value, key)
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+)
+
+func Test_ResolveEventSettingsDefaults(t *testing.T) {
+	t.Log("Test_ResolveEventSettingsDefaults: should use context settings when scaling group has no tags")
+	stubber := &stubAutoscaling{}
+	auth := Authenticator{
+		ScalingGroupClient: stubber,
+	}
+	ctx := _newBasicContext()
+	ctx.DrainRetryIntervalSeconds = 30
+
+	mgr := New(auth, ctx)
+	event := &LifecycleEvent{
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-1234567890",
+	}
+
+	settings := mgr.resolveEventSettings(event)
+	expected := EventSettings{
+		DrainTimeoutSeconds:       ctx.DrainTimeoutSeconds,
+		DrainRetryIntervalSeconds: ctx.DrainRetryIntervalSeconds,
+		DrainRetryAttempts:        ctx.DrainRetryAttempts,
+		DrainFailurePolicy:        FailurePolicyAbandon,
+		DeregisterFailurePolicy:   FailurePolicyAbandon,
+	}
+
+	if settings != expected {
+		t.Fatalf("expected settings: %+v, got: %+v", expected, settings)
+	}
+}
+
+func Test_ResolveEventSettingsTags(t *testing.T) {
+	t.Log("Test_ResolveEventSettingsTags: should override settings from scaling group tags")
+	stubber := &stubAutoscaling{
+		scalingGroups: []*autoscaling.Group{
+			{
+				AutoScalingGroupName: aws.String("my-asg"),
+				Tags: []*autoscaling.TagDescription{
+					{Key: aws.String(DrainTimeoutTagKey), Value: aws.String("900")},
+					{Key: aws.String(DrainRetryIntervalTagKey), Value: aws.String("60")},
+					{Key: aws.String(DrainRetryAttemptsTagKey), Value: aws.String("not-a-number")},
+					{Key: aws.String(DrainFailurePolicyTagKey), Value: aws.String("continue")},
+					{Key: aws.String(DeregisterFailurePolicyTagKey), Value: aws.String("continue")},
+					{Key: aws.String(MaxDrainConcurrencyTagKey), Value: aws.String("2")},
+					{Key: aws.String("Name"), Value: aws.String("my-asg")},
+				},
+			},
+		},
+	}
+	auth := Authenticator{
+		ScalingGroupClient: stubber,
+	}
+	ctx := _newBasicContext()
+	ctx.ScalingGroupMaxDrainConcurrency = map[string]int64{"my-asg": 5}
+
+	mgr := New(auth, ctx)
+	event := &LifecycleEvent{
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-1234567890",
+	}
+
+	settings := mgr.resolveEventSettings(event)
+	expected := EventSettings{
+		DrainTimeoutSeconds:       900,
+		DrainRetryIntervalSeconds: 60,
+		DrainRetryAttempts:        ctx.DrainRetryAttempts,
+		DrainFailurePolicy:        FailurePolicyContinue,
+		DeregisterFailurePolicy:   FailurePolicyContinue,
+		MaxDrainConcurrency:       2,
+	}
+
+	if settings != expected {
+		t.Fatalf("expected settings: %+v, got: %+v", expected, settings)
+	}
+
+	if stubber.timesCalledDescribeAutoScalingGroups != 1 {
+		t.Fatalf("expected timesCalledDescribeAutoScalingGroups: %v, got: %v", 1, stubber.timesCalledDescribeAutoScalingGroups)
+	}
+}
+
+func Test_ResolveEventSettingsDrainFailurePolicy(t *testing.T) {
+	t.Log("Test_ResolveEventSettingsDrainFailurePolicy: should default the drain failure policy from the context")
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+	}
+	ctx := _newBasicContext()
+	ctx.DrainFailurePolicy = FailurePolicyContinue
+
+	mgr := New(auth, ctx)
+	event := &LifecycleEvent{
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-1234567890",
+	}
+
+	settings := mgr.resolveEventSettings(event)
+	if settings.DrainFailurePolicy != FailurePolicyContinue {
+		t.Fatalf("expected drain failure policy: %v, got: %v", FailurePolicyContinue, settings.DrainFailurePolicy)
+	}
+}
+
+func Test_ResolveEventSettingsMetadata(t *testing.T) {
+	t.Log("Test_ResolveEventSettingsMetadata: should override settings from the hook's notification metadata")
+	stubber := &stubAutoscaling{
+		scalingGroups: []*autoscaling.Group{
+			{
+				AutoScalingGroupName: aws.String("my-asg"),
+				Tags: []*autoscaling.TagDescription{
+					{Key: aws.String(DrainTimeoutTagKey), Value: aws.String("900")},
+				},
+			},
+		},
+	}
+	auth := Authenticator{
+		ScalingGroupClient: stubber,
+	}
+	ctx := _newBasicContext()
+
+	mgr := New(auth, ctx)
+	event := &LifecycleEvent{
+		AutoScalingGroupName: "my-asg",
+		EC2InstanceID:        "i-1234567890",
+		NotificationMetadata: `{"drainTimeout": 120, "skipDeregister": true, "cordonOnly": true, "drainFailurePolicy": "not-a-policy"}`,
+	}
+
+	settings := mgr.resolveEventSettings(event)
+	expected := EventSettings{
+		DrainTimeoutSeconds:       120,
+		DrainRetryIntervalSeconds: ctx.DrainRetryIntervalSeconds,
+		DrainRetryAttempts:        ctx.DrainRetryAttempts,
+		DrainFailurePolicy:        FailurePolicyAbandon,
+		DeregisterFailurePolicy:   FailurePolicyAbandon,
+		SkipDeregister:            true,
+		CordonOnly:                true,
+	}
+
+	if settings != expected {
+		t.Fatalf("expected settings: %+v, got: %+v", expected, settings)
+	}
+
+	event.NotificationMetadata = "owner=team-a"
+	settings = mgr.resolveEventSettings(event)
+	if settings.DrainTimeoutSeconds != 900 || settings.SkipDeregister {
+		t.Fatalf("expected metadata which is not a JSON object to be ignored, got: %+v", settings)
+	}
+}
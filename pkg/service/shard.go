@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	// ShardLeasePrefix is the name prefix of the leases renewed by each replica to join the shard ring
+	ShardLeasePrefix = "lifecycle-manager-replica-"
+	// ShardGroupLabelKey is the label key grouping the leases of replicas sharing a queue
+	ShardGroupLabelKey = "lifecycle-manager.keikoproj.io/shard-group"
+	// ShardLeaseDuration is how long a replica remains a member of the ring without renewing its lease
+	ShardLeaseDuration = 30 * time.Second
+	// ShardRenewInterval is the interval at which replicas renew their lease and refresh the members of the ring
+	ShardRenewInterval = 10 * time.Second
+	// ErrShardNotOwned is returned when the instance of an event is owned by another replica
+	ErrShardNotOwned = errors.New("instance is owned by another replica")
+)
+
+// ShardRing is the set of live replicas sharing a queue, each instance is owned by a single replica chosen by
+// rendezvous hashing of its id over the members, so that only the members owning a departed replica's instances move
+type ShardRing struct {
+	sync.RWMutex
+	kubeClient kubernetes.Interface
+	namespace  string
+	identity   string
+	group      string
+	members    []string
+}
+
+// newShardRing returns the shard ring of the replica, or nil when sharding is disabled
+func newShardRing(ctx ManagerContext, kubeClient kubernetes.Interface) *ShardRing {
+	if !ctx.WithSharding {
+		return nil
+	}
+	return &ShardRing{
+		kubeClient: kubeClient,
+		namespace:  ctx.SelfPodNamespace,
+		identity:   ctx.SelfPodName,
+		group:      shardGroup(shardGroupName(ctx)),
+		members:    []string{ctx.SelfPodName},
+	}
+}
+
+// shardGroupName returns the name replicas consuming the same queues share, the cluster name when queues are
+// discovered by tag
+func shardGroupName(ctx ManagerContext) string {
+	if len(ctx.QueueDiscoveryTags) > 0 {
+		return ctx.ClusterName
+	}
+	return ctx.QueueName
+}
+
+// shardGroup returns the queue name as a label value, queue names which are not valid label values are hashed
+func shardGroup(queueName string) string {
+	if len(validation.IsValidLabelValue(queueName)) == 0 {
+		return queueName
+	}
+	h := fnv.New64a()
+	h.Write([]byte(queueName))
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+func (r *ShardRing) leaseName() string { return ShardLeasePrefix + r.identity }
+
+// renew renews the lease of the replica and refreshes the members of the ring from the unexpired leases of its group
+func (r *ShardRing) renew(ctx context.Context) error {
+	var (
+		leases   = r.kubeClient.CoordinationV1().Leases(r.namespace)
+		now      = metav1.NewMicroTime(time.Now())
+		duration = int32(ShardLeaseDuration.Seconds())
+	)
+
+	lease, err := leases.Get(ctx, r.leaseName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      r.leaseName(),
+				Namespace: r.namespace,
+				Labels:    map[string]string{ShardGroupLabelKey: r.group},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &r.identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+	} else if err == nil {
+		lease.Spec.RenewTime = &now
+		lease.Spec.LeaseDurationSeconds = &duration
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to renew lease %v/%v", r.namespace, r.leaseName())
+	}
+
+	list, err := leases.List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("%v=%v", ShardGroupLabelKey, r.group)})
+	if err != nil {
+		return errors.Wrap(err, "failed to list shard leases")
+	}
+
+	members := []string{r.identity}
+	for _, l := range list.Items {
+		holder := l.Spec.HolderIdentity
+		if holder == nil || *holder == r.identity || isLeaseExpired(l, now.Time) {
+			continue
+		}
+		members = append(members, *holder)
+	}
+	sort.Strings(members)
+
+	r.Lock()
+	defer r.Unlock()
+	if fmt.Sprint(members) != fmt.Sprint(r.members) {
+		log.Infof("shard ring members changed: %v", members)
+	}
+	r.members = members
+	return nil
+}
+
+func isLeaseExpired(lease coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second).Before(now)
+}
+
+// leave deletes the lease of the replica, so that the other members take over its instances without waiting for
+// the lease to expire
+func (r *ShardRing) leave() {
+	err := r.kubeClient.CoordinationV1().Leases(r.namespace).Delete(context.Background(), r.leaseName(), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Warnf("failed to delete lease %v/%v: %v", r.namespace, r.leaseName(), err)
+	}
+}
+
+// Members returns the identities of the live replicas of the ring
+func (r *ShardRing) Members() []string {
+	r.RLock()
+	defer r.RUnlock()
+	return append([]string{}, r.members...)
+}
+
+// isMember returns true if the replica holding identity is a live member of the ring
+func (r *ShardRing) isMember(identity string) bool {
+	r.RLock()
+	defer r.RUnlock()
+	for _, m := range r.members {
+		if m == identity {
+			return true
+		}
+	}
+	return false
+}
+
+// owner returns the identity of the member owning the instance, the member with the highest hash of its identity
+// and the instance id
+func (r *ShardRing) owner(instanceID string) string {
+	r.RLock()
+	defer r.RUnlock()
+
+	var (
+		owner string
+		max   uint64
+	)
+	for _, m := range r.members {
+		h := fnv.New64a()
+		h.Write([]byte(m + "/" + instanceID))
+		if sum := h.Sum64(); owner == "" || sum > max {
+			owner, max = m, sum
+		}
+	}
+	return owner
+}
+
+// startShardRing renews the lease of the replica until the service is stopped, when it leaves the ring
+func (mgr *Manager) startShardRing() {
+	var (
+		ring    = mgr.shards
+		metrics = mgr.metrics
+	)
+
+	ticker := time.NewTicker(ShardRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-mgr.ctx.Done():
+			ring.leave()
+			return
+		case <-ticker.C:
+		}
+		if err := ring.renew(mgr.ctx); err != nil {
+			log.Errorf("%v", err)
+			continue
+		}
+		metrics.SetGauge(ShardMembersCountMetric, nil, float64(len(ring.Members())))
+	}
+}
+
+// validateShard returns ErrShardNotOwned if the event's instance is owned by another replica of the ring
+func (mgr *Manager) validateShard(e *LifecycleEvent) error {
+	if mgr.shards == nil {
+		return nil
+	}
+	if owner := mgr.shards.owner(e.EC2InstanceID); owner != mgr.shards.identity {
+		return errors.Wrapf(ErrShardNotOwned, "instance %v is owned by %v", e.EC2InstanceID, owner)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func _newShardedManager(kubeClient *fake.Clientset, name string) *Manager {
+	ctx := _newBasicContext()
+	ctx.WithSharding = true
+	ctx.DedupStore = DedupStoreLease
+	ctx.SelfPodName = name
+	ctx.SelfPodNamespace = "lifecycle-manager"
+	return New(Authenticator{KubernetesClient: kubeClient}, ctx)
+}
+
+func Test_ShardOwnership(t *testing.T) {
+	t.Log("Test_ShardOwnership: should assign each instance to a single replica and only move instances of departed replicas")
+	kubeClient := fake.NewSimpleClientset()
+	replicas := []*Manager{
+		_newShardedManager(kubeClient, "replica-1"),
+		_newShardedManager(kubeClient, "replica-2"),
+		_newShardedManager(kubeClient, "replica-3"),
+	}
+	for _, replica := range replicas {
+		if err := replica.shards.renew(context.Background()); err != nil {
+			t.Fatalf("renew: expected error not to have occured, %v", err)
+		}
+	}
+	// refresh the members seen by replicas which joined first
+	for _, replica := range replicas {
+		replica.shards.renew(context.Background())
+		if members := replica.shards.Members(); len(members) != 3 {
+			t.Fatalf("expected members: 3, got: %v", members)
+		}
+	}
+
+	owners := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		instanceID := fmt.Sprintf("i-%012d", i)
+		owned := 0
+		for _, replica := range replicas {
+			err := replica.validateShard(&LifecycleEvent{EC2InstanceID: instanceID})
+			if err == nil {
+				owned++
+				owners[instanceID] = replica.shards.identity
+				continue
+			}
+			if errors.Cause(err) != ErrShardNotOwned {
+				t.Fatalf("expected error: %v, got: %v", ErrShardNotOwned, err)
+			}
+		}
+		if owned != 1 {
+			t.Fatalf("expected owners of %v: 1, got: %v", instanceID, owned)
+		}
+		counts[owners[instanceID]]++
+	}
+	for name, count := range counts {
+		if count < 50 {
+			t.Fatalf("expected instances to be spread across replicas, %v owns %v of 300", name, count)
+		}
+	}
+
+	// replica-3 leaves, only its instances move to the remaining replicas
+	replicas[2].shards.leave()
+	for _, replica := range replicas[:2] {
+		replica.shards.renew(context.Background())
+	}
+	for instanceID, owner := range owners {
+		got := replicas[0].shards.owner(instanceID)
+		if owner != "replica-3" && got != owner {
+			t.Fatalf("expected owner of %v: %v, got: %v", instanceID, owner, got)
+		}
+		if got == "replica-3" {
+			t.Fatalf("expected instance %v to move from departed replica", instanceID)
+		}
+	}
+}
+
+func Test_ShardRingExpiredLease(t *testing.T) {
+	t.Log("Test_ShardRingExpiredLease: should not count replicas whose lease expired as members")
+	expired := metav1.NewMicroTime(time.Now().Add(-time.Hour))
+	kubeClient := fake.NewSimpleClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ShardLeasePrefix + "replica-2",
+			Namespace: "lifecycle-manager",
+			Labels:    map[string]string{ShardGroupLabelKey: shardGroup(_newBasicContext().QueueName)},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       aws.String("replica-2"),
+			LeaseDurationSeconds: aws.Int32(30),
+			RenewTime:            &expired,
+		},
+	})
+
+	mgr := _newShardedManager(kubeClient, "replica-1")
+	if err := mgr.shards.renew(context.Background()); err != nil {
+		t.Fatalf("renew: expected error not to have occured, %v", err)
+	}
+	if members := mgr.shards.Members(); len(members) != 1 || members[0] != "replica-1" {
+		t.Fatalf("expected members: [replica-1], got: %v", members)
+	}
+}
+
+func Test_ShardGroup(t *testing.T) {
+	t.Log("Test_ShardGroup: should use queue names as label values or hash them")
+	if got := shardGroup("my-queue"); got != "my-queue" {
+		t.Fatalf("expected group: my-queue, got: %v", got)
+	}
+	long := fmt.Sprintf("%080d", 0)
+	if got := shardGroup(long); got == long || len(got) > 63 {
+		t.Fatalf("expected long queue name to be hashed, got: %v", got)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SimulatedRequestPrefix is the prefix of the message id of simulated events
+	SimulatedRequestPrefix = "simulated"
+
+	// SimulationActionReject is the action of events which fail validation
+	SimulationActionReject = "reject"
+	// SimulationActionWarmPool is the action of warm pool instances whose hook is completed right away
+	SimulationActionWarmPool = "complete-warm-pool"
+	// SimulationActionSkip is the action of events whose node opted out of processing
+	SimulationActionSkip = "skip"
+	// SimulationActionLaunch is the action of launch events, which wait for the instance to become a ready node
+	SimulationActionLaunch = "await-launch"
+	// SimulationActionDrain is the action of termination events, which drain and deregister the node
+	SimulationActionDrain = "drain"
+)
+
+// SimulationReport describes how lifecycle-manager processes, or would process in dry-run, a lifecycle event
+type SimulationReport struct {
+	DryRun            bool
+	RequestID         string
+	InstanceID        string
+	ScalingGroupName  string
+	Transition        string
+	InstanceRefreshID string
+	NodeName          string
+	HeartbeatInterval int64
+	Action            string
+	Settings          EventSettings
+	EvictedPods       []string
+	TargetGroups      []string
+	LoadBalancers     []string
+	Phase             EventPhase
+	Warnings          []string
+	Error             error
+}
+
+// NewSimulatedMessage returns the message of a simulated event, the body is either a lifecycle event or a serialized
+// SQS message such as the in-progress annotation of a node. The receipt handle is dropped as the message was not
+// received from the queue, which leaves the queue untouched when the event ends
+func NewSimulatedMessage(body []byte) (*sqs.Message, error) {
+	message, err := decodeInlineResumeState(strings.TrimSpace(string(body)))
+	if err != nil || message.Body == nil {
+		event := &LifecycleEvent{}
+		if err := json.Unmarshal(body, event); err != nil {
+			return nil, errors.Wrap(err, "input is neither a lifecycle event nor an SQS message")
+		}
+		message = &sqs.Message{Body: aws.String(string(body))}
+	}
+
+	message.ReceiptHandle = nil
+	if message.MessageId == nil {
+		message.MessageId = aws.String(fmt.Sprintf("%v-%v", SimulatedRequestPrefix, time.Now().Unix()))
+	}
+	return message, nil
+}
+
+// Simulate runs a message through validation and processing outside of the queue. In dry-run the event is validated
+// and its processing planned with read-only calls, otherwise it is processed as if it was received from the queue,
+// draining the node and completing the lifecycle hook
+func (mgr *Manager) Simulate(message *sqs.Message, dryRun bool) *SimulationReport {
+	report := &SimulationReport{DryRun: dryRun}
+
+	event, err := mgr.newEvent(message, "")
+	report.RequestID = event.RequestID
+	report.InstanceID = event.EC2InstanceID
+	report.ScalingGroupName = event.AutoScalingGroupName
+	report.Transition = event.LifecycleTransition
+	report.InstanceRefreshID = event.instanceRefreshID
+	report.NodeName = event.referencedNode.Name
+	report.HeartbeatInterval = event.heartbeatInterval
+	if err != nil {
+		report.Action = SimulationActionReject
+		report.Phase = PhaseFailed
+		report.Error = err
+		return report
+	}
+
+	report.Action = mgr.simulationAction(event)
+	if report.Action == SimulationActionDrain {
+		mgr.planDrain(event, report)
+	}
+
+	if dryRun {
+		report.Phase = event.Phase()
+		return report
+	}
+
+	if err := mgr.claimEvent(event); err != nil {
+		report.Phase = PhaseFailed
+		report.Error = err
+		return report
+	}
+
+	log.Infof("%v> processing simulated event %v", event.EC2InstanceID, event.RequestID)
+	mgr.Process(event)
+	report.Phase = event.Phase()
+	if report.Phase != PhaseDone {
+		report.Error = errors.Errorf("event ended in phase %v", report.Phase)
+	}
+	return report
+}
+
+// simulationAction returns how Process handles a validated event
+func (mgr *Manager) simulationAction(event *LifecycleEvent) string {
+	switch {
+	case event.isWarmPoolInstance():
+		return SimulationActionWarmPool
+	case event.LifecycleTransition == LaunchEventName:
+		return SimulationActionLaunch
+	case mgr.isNodeSkipped(event), !mgr.isInstanceAdmitted(event):
+		return SimulationActionSkip
+	}
+	event.policyDecision = mgr.evaluatePolicy(event)
+	if event.policyDecision.skip() {
+		return SimulationActionSkip
+	}
+	return SimulationActionDrain
+}
+
+// planDrain records the settings, evicted pods and load balancer memberships of a termination event in the report,
+// failures are reported as warnings as they would be retried while processing the event
+func (mgr *Manager) planDrain(event *LifecycleEvent, report *SimulationReport) {
+	var (
+		ctx = &mgr.context
+	)
+
+	report.Settings = mgr.resolveEventSettings(event)
+	event.SetSettings(report.Settings)
+
+	if !report.Settings.CordonOnly {
+		pods, err := getPodsForDeletion(event.Context(), mgr.kubeClient(event), event.referencedNode.Name, mgr.drainPolicy(event))
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("failed to list pods to evict: %v", err))
+		}
+		for _, pod := range pods {
+			report.EvictedPods = append(report.EvictedPods, pod.Namespace+"/"+pod.Name)
+		}
+	}
+
+	if !ctx.WithDeregister || report.Settings.SkipDeregister {
+		return
+	}
+	scanResult, err := mgr.scanMembership(event.Context(), event)
+	if err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("failed to scan load balancer membership: %v", err))
+		return
+	}
+	for arn := range scanResult.ActiveTargetGroups {
+		report.TargetGroups = append(report.TargetGroups, arn)
+	}
+	sort.Strings(report.TargetGroups)
+	report.LoadBalancers = scanResult.ActiveLoadBalancers
+}
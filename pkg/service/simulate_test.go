@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const simulatedEventBody = `{"LifecycleHookName":"my-hook","RequestId":"63f5b5c2-58b3-0574-b7d5-b3162d0268f0",` +
+	`"LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING","AutoScalingGroupName":"my-asg",` +
+	`"EC2InstanceId":"i-123486890234","LifecycleActionToken":"cc34960c-1e41-4703-a665-bdb3e5b81ad3"}`
+
+func Test_NewSimulatedMessage(t *testing.T) {
+	t.Log("Test_NewSimulatedMessage: should read lifecycle events and serialized messages without their receipt handle")
+	message, err := NewSimulatedMessage([]byte(simulatedEventBody))
+	if err != nil {
+		t.Fatalf("NewSimulatedMessage: expected error not to have occured, %v", err)
+	}
+	if aws.StringValue(message.Body) != simulatedEventBody || message.MessageId == nil {
+		t.Fatalf("expected message with event body and message id, got: %v", message)
+	}
+
+	stored := `{"MessageId":"message-1","ReceiptHandle":"receipt-1","Body":` + `"{\"EC2InstanceId\":\"i-123486890234\"}"}`
+	message, err = NewSimulatedMessage([]byte(stored))
+	if err != nil {
+		t.Fatalf("NewSimulatedMessage: expected error not to have occured, %v", err)
+	}
+	if aws.StringValue(message.MessageId) != "message-1" || message.ReceiptHandle != nil {
+		t.Fatalf("expected message-1 without receipt handle, got: %v", message)
+	}
+
+	if _, err = NewSimulatedMessage([]byte("not json")); err == nil {
+		t.Fatal("NewSimulatedMessage: expected error to have occured")
+	}
+}
+
+func Test_SimulateDryRun(t *testing.T) {
+	t.Log("Test_SimulateDryRun: should plan the drain of a termination without changes")
+	asgStubber := &stubAutoscaling{
+		lifecycleHooks: []*autoscaling.LifecycleHook{
+			{
+				AutoScalingGroupName: aws.String("my-asg"),
+				HeartbeatTimeout:     aws.Int64(60),
+			},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset()
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		SQSClient:          &stubSQS{},
+		KubernetesClient:   kubeClient,
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-123486890234"},
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+	kubeClient.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{})
+
+	mgr := New(auth, _newBasicContext())
+	message, _ := NewSimulatedMessage([]byte(simulatedEventBody))
+	report := mgr.Simulate(message, true)
+
+	if report.Error != nil {
+		t.Fatalf("Simulate: expected error not to have occured, %v", report.Error)
+	}
+	if report.Action != SimulationActionDrain || report.NodeName != "node-1" || report.Phase != PhaseValidated {
+		t.Fatalf("expected drain of node-1 in phase %v, got: %+v", PhaseValidated, report)
+	}
+	if len(report.EvictedPods) != 1 || report.EvictedPods[0] != "default/pod-1" {
+		t.Fatalf("expected evicted pods: [default/pod-1], got: %v", report.EvictedPods)
+	}
+	if asgStubber.timesCalledCompleteLifecycleAction != 0 {
+		t.Fatalf("expected timesCalledCompleteLifecycleAction: %v, got: %v", 0, asgStubber.timesCalledCompleteLifecycleAction)
+	}
+	if n, _ := kubeClient.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{}); n.Spec.Unschedulable {
+		t.Fatal("expected node not to be cordoned")
+	}
+}
+
+func Test_SimulateRejected(t *testing.T) {
+	t.Log("Test_SimulateRejected: should report events failing validation")
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		SQSClient:          &stubSQS{},
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	mgr := New(auth, _newBasicContext())
+	message, _ := NewSimulatedMessage([]byte(simulatedEventBody))
+	report := mgr.Simulate(message, false)
+
+	if report.Action != SimulationActionReject || errors.Cause(report.Error) != ErrNodeNotFound {
+		t.Fatalf("expected rejection with %v, got: %+v", ErrNodeNotFound, report)
+	}
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// EventSinkKubernetes publishes events as Kubernetes events
+	EventSinkKubernetes = "kubernetes"
+	// EventSinkLog writes events to the log
+	EventSinkLog = "log"
+	// EventSinkWebhook posts events as JSON to a webhook
+	EventSinkWebhook = "webhook"
+	// EventSinkSNS publishes events as JSON to an SNS topic
+	EventSinkSNS = "sns"
+)
+
+var (
+	// WebhookTimeout is the timeout of requests posting events to the webhook sink
+	WebhookTimeout = 10 * time.Second
+)
+
+// EventSink publishes the events of lifecycle-manager to an audit trail
+type EventSink interface {
+	// Publish publishes an event of the given reason about a lifecycle event
+	Publish(event *LifecycleEvent, reason EventReason, msgFields map[string]string) error
+}
+
+// SinkEvent is the payload of events published to the webhook and sns sinks
+type SinkEvent struct {
+	Reason    string            `json:"reason"`
+	Type      string            `json:"type"`
+	Fields    map[string]string `json:"fields"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+func newSinkEvent(reason EventReason, msgFields map[string]string) SinkEvent {
+	return SinkEvent{
+		Reason:    string(reason),
+		Type:      getReasonEventLevel(reason),
+		Fields:    msgFields,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// IsValidEventSink returns true if the event sink is supported
+func IsValidEventSink(sink string) bool {
+	switch sink {
+	case EventSinkKubernetes, EventSinkLog, EventSinkWebhook, EventSinkSNS:
+		return true
+	}
+	return false
+}
+
+// newEventSink returns a sink publishing to all of the configured sinks, Kubernetes events are published by default
+func newEventSink(ctx ManagerContext, auth Authenticator) EventSink {
+	kinds := ctx.EventSinks
+	if len(kinds) == 0 {
+		kinds = []string{EventSinkKubernetes}
+	}
+
+	sinks := make(MultiEventSink, 0)
+	for _, kind := range kinds {
+		switch kind {
+		case EventSinkKubernetes:
+			sinks = append(sinks, &KubernetesEventSink{
+				kubeClient: auth.KubernetesClient,
+				namespace:  ctx.EventNamespace,
+				nodeScoped: ctx.NodeScopedEvents,
+			})
+		case EventSinkLog:
+			sinks = append(sinks, &LogEventSink{})
+		case EventSinkWebhook:
+			sinks = append(sinks, &WebhookEventSink{url: ctx.EventWebhookURL, client: &http.Client{Timeout: WebhookTimeout}})
+		case EventSinkSNS:
+			sinks = append(sinks, &SNSEventSink{client: auth.SNSClient, topicARN: ctx.EventSNSTopicARN})
+		}
+	}
+	return sinks
+}
+
+// publishEvent publishes an event to the manager's sinks, failures are logged and do not affect processing
+func (mgr *Manager) publishEvent(event *LifecycleEvent, reason EventReason, msgFields map[string]string) {
+	if err := mgr.eventSink.Publish(event, reason, msgFields); err != nil {
+		log.Errorf("failed to publish event: %v", err)
+	}
+}
+
+// MultiEventSink publishes events to every sink it holds
+type MultiEventSink []EventSink
+
+func (s MultiEventSink) Publish(event *LifecycleEvent, reason EventReason, msgFields map[string]string) error {
+	var errs error
+	for _, sink := range s {
+		if err := sink.Publish(event, reason, msgFields); err != nil {
+			if errs == nil {
+				errs = err
+			} else {
+				errs = errors.Wrap(errs, err.Error())
+			}
+		}
+	}
+	return errs
+}
+
+// KubernetesEventSink publishes events as Kubernetes events in the cluster the lifecycle event is routed to
+type KubernetesEventSink struct {
+	kubeClient kubernetes.Interface
+	// namespace overrides EventNamespace as the namespace events are published in
+	namespace string
+	// nodeScoped attaches events to the lifecycle event's node, so that they are listed by kubectl describe node
+	nodeScoped bool
+}
+
+func (s *KubernetesEventSink) Publish(event *LifecycleEvent, reason EventReason, msgFields map[string]string) error {
+	kEvent := newKubernetesEvent(reason, msgFields)
+	if s.namespace != "" {
+		kEvent.Namespace = s.namespace
+		kEvent.InvolvedObject.Namespace = s.namespace
+	}
+
+	// the node's uid is only known once the event's node is resolved
+	if node := event.referencedNode; s.nodeScoped && node.UID != "" {
+		kEvent.InvolvedObject = v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       node.Name,
+			UID:        node.UID,
+		}
+		kEvent.Source = v1.EventSource{Component: "lifecycle-manager"}
+	}
+
+	publishKubernetesEvent(eventKubeClient(event, s.kubeClient), kEvent)
+	return nil
+}
+
+// LogEventSink writes events to the log, for environments where Kubernetes events are disabled
+type LogEventSink struct{}
+
+func (s *LogEventSink) Publish(event *LifecycleEvent, reason EventReason, msgFields map[string]string) error {
+	fields := log.Fields{
+		"reason": string(reason),
+		"type":   getReasonEventLevel(reason),
+	}
+	for k, v := range msgFields {
+		fields[k] = v
+	}
+	log.WithFields(fields).Info("lifecycle-manager event")
+	return nil
+}
+
+// WebhookEventSink posts events as JSON to a webhook
+type WebhookEventSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *WebhookEventSink) Publish(event *LifecycleEvent, reason EventReason, msgFields map[string]string) error {
+	body, err := json.Marshal(newSinkEvent(reason, msgFields))
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to post event to webhook")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// SNSEventSink publishes events as JSON to an SNS topic
+type SNSEventSink struct {
+	client   snsiface.SNSAPI
+	topicARN string
+}
+
+func (s *SNSEventSink) Publish(event *LifecycleEvent, reason EventReason, msgFields map[string]string) error {
+	body, err := json.Marshal(newSinkEvent(reason, msgFields))
+	if err != nil {
+		return err
+	}
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String(s.topicARN),
+		Message:  aws.String(string(body)),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			"reason": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(string(reason)),
+			},
+		},
+	}
+	if _, err := s.client.Publish(input); err != nil {
+		return errors.Wrap(err, "failed to publish event to sns")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type stubSNS struct {
+	snsiface.SNSAPI
+	published []*sns.PublishInput
+}
+
+func (s *stubSNS) Publish(input *sns.PublishInput) (*sns.PublishOutput, error) {
+	s.published = append(s.published, input)
+	return &sns.PublishOutput{}, nil
+}
+
+func Test_EventSinks(t *testing.T) {
+	t.Log("Test_EventSinks: should publish events to every configured sink")
+	var received []SinkEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event SinkEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, event)
+	}))
+	defer server.Close()
+
+	snsStubber := &stubSNS{}
+	kubeClient := fake.NewSimpleClientset()
+	auth := Authenticator{
+		KubernetesClient: kubeClient,
+		SNSClient:        snsStubber,
+	}
+	ctx := _newBasicContext()
+	ctx.EventSinks = []string{EventSinkKubernetes, EventSinkLog, EventSinkWebhook, EventSinkSNS}
+	ctx.EventWebhookURL = server.URL
+	ctx.EventSNSTopicARN = "arn:aws:sns:us-west-2:123456789012:lifecycle-events"
+	mgr := New(auth, ctx)
+
+	event := &LifecycleEvent{
+		RequestID:            "63f5b5c2-58b3-0574-b7d5-b3162d0268f0",
+		EC2InstanceID:        "i-123486890234",
+		AutoScalingGroupName: "my-asg",
+	}
+	mgr.publishEvent(event, EventReasonNodeDrainSucceeded, getMessageFields(event, "drained"))
+
+	events, _ := kubeClient.CoreV1().Events(EventNamespace).List(context.Background(), metav1.ListOptions{})
+	if len(events.Items) != 1 {
+		t.Fatalf("expected kubernetes events: %v, got: %v", 1, len(events.Items))
+	}
+
+	if len(received) != 1 || received[0].Reason != string(EventReasonNodeDrainSucceeded) || received[0].Fields["ec2InstanceId"] != event.EC2InstanceID {
+		t.Fatalf("expected webhook event with reason %v, got: %+v", EventReasonNodeDrainSucceeded, received)
+	}
+
+	if len(snsStubber.published) != 1 || aws.StringValue(snsStubber.published[0].TopicArn) != ctx.EventSNSTopicARN {
+		t.Fatalf("expected sns event to be published to %v, got: %+v", ctx.EventSNSTopicARN, snsStubber.published)
+	}
+}
+
+func Test_WebhookEventSinkError(t *testing.T) {
+	t.Log("Test_WebhookEventSinkError: should return an error when the webhook does not accept the event")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sink := MultiEventSink{
+		&LogEventSink{},
+		&WebhookEventSink{url: server.URL, client: server.Client()},
+	}
+	err := sink.Publish(&LifecycleEvent{}, EventReasonNodeDrainFailed, map[string]string{})
+	if err == nil {
+		t.Fatalf("expected error to have occured, %v", err)
+	}
+}
+
+func Test_KubernetesEventSinkNodeScoped(t *testing.T) {
+	t.Log("Test_KubernetesEventSinkNodeScoped: should publish events in the configured namespace attached to the event's node")
+	kubeClient := fake.NewSimpleClientset()
+	sink := &KubernetesEventSink{
+		kubeClient: kubeClient,
+		namespace:  "lifecycle-manager",
+		nodeScoped: true,
+	}
+
+	event := &LifecycleEvent{
+		referencedNode: v1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+			UID:  types.UID("3b8a4bd6-5d6c-4e39-9d36-58b2d4b0b2a1"),
+		}},
+	}
+	sink.Publish(event, EventReasonNodeDrainSucceeded, map[string]string{})
+	// events of unresolved nodes keep the default involved object
+	sink.Publish(&LifecycleEvent{}, EventReasonLifecycleHookReceived, map[string]string{})
+
+	events, _ := kubeClient.CoreV1().Events("lifecycle-manager").List(context.Background(), metav1.ListOptions{})
+	if len(events.Items) != 2 {
+		t.Fatalf("expected events: %v, got: %v", 2, len(events.Items))
+	}
+
+	for _, kEvent := range events.Items {
+		expectedKind := "LifecycleManager"
+		if kEvent.Reason == string(EventReasonNodeDrainSucceeded) {
+			expectedKind = "Node"
+		}
+		if kEvent.InvolvedObject.Kind != expectedKind {
+			t.Fatalf("%v: expected involved object kind: %v, got: %v", kEvent.Reason, expectedKind, kEvent.InvolvedObject.Kind)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// SkipNodeActionContinue completes the lifecycle hook of skipped nodes with CONTINUE
+	SkipNodeActionContinue = "continue"
+	// SkipNodeActionIgnore leaves the lifecycle hook of skipped nodes to other tooling or to the hook's timeout
+	SkipNodeActionIgnore = "ignore"
+)
+
+var (
+	// SkipAnnotationKey is the annotation key opting a node out of processing by lifecycle-manager
+	SkipAnnotationKey = "lifecycle-manager.keikoproj.io/skip"
+	// SkipAnnotationValue is the annotation value opting a node out of processing by lifecycle-manager
+	SkipAnnotationValue = "true"
+)
+
+// IsValidSkipNodeAction returns true if the skip node action is supported
+func IsValidSkipNodeAction(action string) bool {
+	switch action {
+	case SkipNodeActionContinue, SkipNodeActionIgnore:
+		return true
+	}
+	return false
+}
+
+// isNodeSkipped returns true if the event's node opted out of processing with the skip annotation or matches the skip node selector
+func (mgr *Manager) isNodeSkipped(event *LifecycleEvent) bool {
+	var (
+		node = event.referencedNode
+	)
+
+	if node.GetAnnotations()[SkipAnnotationKey] == SkipAnnotationValue {
+		return true
+	}
+
+	if mgr.context.SkipNodeSelector == "" {
+		return false
+	}
+
+	selector, err := labels.Parse(mgr.context.SkipNodeSelector)
+	if err != nil {
+		log.Errorf("%v> invalid skip node selector: %v", event.EC2InstanceID, err)
+		return false
+	}
+	return selector.Matches(labels.Set(node.GetLabels()))
+}
+
+// SkipEvent ends the processing of an event whose node opted out without draining it
+func (mgr *Manager) SkipEvent(event *LifecycleEvent) {
+	var (
+		auth    = mgr.authenticator
+		metrics = mgr.metrics
+		action  = mgr.context.SkipNodeAction
+	)
+
+	log.Infof("%v> node/%v is skipped, %v lifecycle hook", event.EC2InstanceID, event.referencedNode.Name, action)
+	msg := fmt.Sprintf(EventMessageNodeSkipped, event.referencedNode.Name, action)
+	mgr.publishEvent(event, EventReasonNodeSkipped, getMessageFields(event, msg))
+	metrics.AddCounter(SkippedEventsTotalMetric, eventLabels(event), 1)
+
+	if action == SkipNodeActionContinue {
+		mgr.CompleteEvent(event)
+		return
+	}
+
+	mgr.setEventPhase(event, PhaseDone)
+	if err := deleteMessage(auth.SQSClient, event.queueURL, event.receiptHandle); err != nil {
+		log.Errorf("failed to delete message: %v", err)
+	}
+	mgr.RemoveFromQueue(event)
+	mgr.releaseEvent(event)
+	metrics.DecGauge(TerminatingInstancesCountMetric, eventLabels(event))
+}
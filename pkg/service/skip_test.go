@@ -0,0 +1,108 @@
+package service
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_IsNodeSkipped(t *testing.T) {
+	t.Log("Test_IsNodeSkipped: should skip nodes carrying the skip annotation or matching the skip node selector")
+	ctx := _newBasicContext()
+	ctx.SkipNodeSelector = "tooling=other"
+	mgr := New(Authenticator{}, ctx)
+
+	tests := []struct {
+		name     string
+		node     v1.Node
+		expected bool
+	}{
+		{
+			name: "annotated",
+			node: v1.Node{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{SkipAnnotationKey: SkipAnnotationValue},
+			}},
+			expected: true,
+		},
+		{
+			name: "annotated false",
+			node: v1.Node{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{SkipAnnotationKey: "false"},
+			}},
+			expected: false,
+		},
+		{
+			name: "selected",
+			node: v1.Node{ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{"tooling": "other"},
+			}},
+			expected: true,
+		},
+		{
+			name:     "not selected",
+			node:     v1.Node{},
+			expected: false,
+		},
+	}
+
+	for _, tc := range tests {
+		event := &LifecycleEvent{referencedNode: tc.node}
+		if got := mgr.isNodeSkipped(event); got != tc.expected {
+			t.Fatalf("%v: expected skipped: %v, got: %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func Test_SkipEvent(t *testing.T) {
+	t.Log("Test_SkipEvent: should complete or leave alone the lifecycle hook of skipped nodes without draining")
+	for _, action := range []string{SkipNodeActionContinue, SkipNodeActionIgnore} {
+		asgStubber := &stubAutoscaling{}
+		sqsStubber := &stubSQS{}
+		auth := Authenticator{
+			ScalingGroupClient: asgStubber,
+			SQSClient:          sqsStubber,
+			KubernetesClient:   fake.NewSimpleClientset(),
+		}
+		ctx := _newBasicContext()
+		ctx.SkipNodeAction = action
+
+		event := &LifecycleEvent{
+			LifecycleHookName:    "my-hook",
+			RequestID:            "63f5b5c2-58b3-0574-b7d5-b3162d0268f0",
+			LifecycleTransition:  TerminationEventName,
+			AutoScalingGroupName: "my-asg",
+			EC2InstanceID:        "i-123486890234",
+			receiptHandle:        "MbZj6wDWli+JvwwJaBV+3dcjk2YW2vA3+STFFljTM8tJJg6HRG6PYSasuWXPJB+Cw=",
+			heartbeatInterval:    2,
+			referencedNode: v1.Node{ObjectMeta: metav1.ObjectMeta{
+				Name:        "node-1",
+				Annotations: map[string]string{SkipAnnotationKey: SkipAnnotationValue},
+			}},
+		}
+
+		mgr := New(auth, ctx)
+		mgr.Process(event)
+
+		if event.phaseCompleted(PhaseDraining) {
+			t.Fatalf("%v: expected phase %v not to be completed, got phases: %v", action, PhaseDraining, event.phases)
+		}
+
+		expectedCompletions := 0
+		if action == SkipNodeActionContinue {
+			expectedCompletions = 1
+		}
+		if asgStubber.timesCalledCompleteLifecycleAction != expectedCompletions {
+			t.Fatalf("%v: expected timesCalledCompleteLifecycleAction: %v, got: %v", action, expectedCompletions, asgStubber.timesCalledCompleteLifecycleAction)
+		}
+
+		if sqsStubber.timesCalledDeleteMessage != 1 {
+			t.Fatalf("%v: expected timesCalledDeleteMessage: %v, got: %v", action, 1, sqsStubber.timesCalledDeleteMessage)
+		}
+
+		if len(mgr.workQueue) != 0 {
+			t.Fatalf("%v: expected work queue length: %v, got: %v", action, 0, len(mgr.workQueue))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SNSNotificationType is the type of SNS envelopes carrying a published message
+const SNSNotificationType = "Notification"
+
+var (
+	// SNSCertificateHostPattern matches the hosts SNS signing certificates are served from
+	SNSCertificateHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+	// SNSCertificateTimeout is the timeout of downloading an SNS signing certificate
+	SNSCertificateTimeout = 10 * time.Second
+
+	snsCertificates   = make(map[string]*x509.Certificate)
+	snsCertificatesMu sync.Mutex
+)
+
+// SNSEnvelope is an SNS notification wrapping a message, delivered to SQS when raw message delivery is disabled
+type SNSEnvelope struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject,omitempty"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// unwrapSNSEnvelope returns the message wrapped in an SNS notification, nil is returned if body is not an SNS notification
+func unwrapSNSEnvelope(body string) (*SNSEnvelope, error) {
+	envelope := &SNSEnvelope{}
+	if err := json.Unmarshal([]byte(body), envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Type != SNSNotificationType || envelope.TopicArn == "" {
+		return nil, nil
+	}
+	return envelope, nil
+}
+
+// stringToSign returns the canonical form of the notification signed by SNS
+func (e *SNSEnvelope) stringToSign() string {
+	fields := []string{"Message", e.Message, "MessageId", e.MessageID}
+	if e.Subject != "" {
+		fields = append(fields, "Subject", e.Subject)
+	}
+	fields = append(fields, "Timestamp", e.Timestamp, "TopicArn", e.TopicArn, "Type", e.Type)
+	return strings.Join(fields, "\n") + "\n"
+}
+
+// verifySNSSignature verifies the notification was signed by SNS
+func verifySNSSignature(envelope *SNSEnvelope) error {
+	var (
+		hash   crypto.Hash
+		digest []byte
+		data   = []byte(envelope.stringToSign())
+	)
+
+	switch envelope.SignatureVersion {
+	case "1":
+		sum := sha1.Sum(data)
+		hash, digest = crypto.SHA1, sum[:]
+	case "2":
+		sum := sha256.Sum256(data)
+		hash, digest = crypto.SHA256, sum[:]
+	default:
+		return errors.Errorf("unsupported sns signature version '%v'", envelope.SignatureVersion)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(envelope.Signature)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode sns signature")
+	}
+
+	certificate, err := getSNSCertificate(envelope.SigningCertURL)
+	if err != nil {
+		return err
+	}
+
+	publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("sns signing certificate does not hold an rsa public key")
+	}
+
+	if err := rsa.VerifyPKCS1v15(publicKey, hash, digest, signature); err != nil {
+		return errors.Wrap(err, "invalid sns signature")
+	}
+	return nil
+}
+
+// getSNSCertificate returns the signing certificate served at certURL, certificates are only downloaded from SNS hosts
+func getSNSCertificate(certURL string) (*x509.Certificate, error) {
+	snsCertificatesMu.Lock()
+	defer snsCertificatesMu.Unlock()
+
+	if certificate, ok := snsCertificates[certURL]; ok {
+		return certificate, nil
+	}
+
+	parsed, err := url.Parse(certURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid sns signing certificate url")
+	}
+	if parsed.Scheme != "https" || !SNSCertificateHostPattern.MatchString(parsed.Host) {
+		return nil, errors.Errorf("sns signing certificate url %v is not served by sns", certURL)
+	}
+
+	client := &http.Client{Timeout: SNSCertificateTimeout}
+	resp, err := client.Get(certURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download sns signing certificate")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download sns signing certificate: %v", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download sns signing certificate")
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.New("failed to decode sns signing certificate")
+	}
+
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse sns signing certificate")
+	}
+
+	snsCertificates[certURL] = certificate
+	return certificate, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const _fakeLifecycleMessage = `{"LifecycleHookName":"my-hook","AccountId":"12345689012","RequestId":"63f5b5c2-58b3-0574-b7d5-b3162d0268f0","LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING","AutoScalingGroupName":"my-asg","Service":"AWS Auto Scaling","Time":"2019-09-27T02:39:14.183Z","EC2InstanceId":"i-123486890234","LifecycleActionToken":"cc34960c-1e41-4703-a665-bdb3e5b81ad3"}`
+
+func _newSignedEnvelope(t *testing.T, certURL string) (*SNSEnvelope, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: expected error not to have occured, %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate: expected error not to have occured, %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate: expected error not to have occured, %v", err)
+	}
+
+	envelope := &SNSEnvelope{
+		Type:             SNSNotificationType,
+		MessageID:        "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+		TopicArn:         "arn:aws:sns:us-west-2:123456789012:lifecycle-topic",
+		Message:          _fakeLifecycleMessage,
+		Timestamp:        "2019-09-27T02:39:14.200Z",
+		SignatureVersion: "2",
+		SigningCertURL:   certURL,
+	}
+	digest := sha256.Sum256([]byte(envelope.stringToSign()))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("rsa.SignPKCS1v15: expected error not to have occured, %v", err)
+	}
+	envelope.Signature = base64.StdEncoding.EncodeToString(signature)
+	return envelope, certificate
+}
+
+func Test_ReadMessageSNSEnvelope(t *testing.T) {
+	t.Log("Test_ReadMessageSNSEnvelope: should unwrap lifecycle notifications from an SNS envelope")
+	envelope, _ := _newSignedEnvelope(t, "https://sns.us-west-2.amazonaws.com/cert.pem")
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("json.Marshal: expected error not to have occured, %v", err)
+	}
+
+	event, err := readMessage(&sqs.Message{Body: aws.String(string(body))}, "some-queue")
+	if err != nil {
+		t.Fatalf("readMessage: expected error not to have occured, %v", err)
+	}
+
+	if event.EC2InstanceID != "i-123486890234" || event.LifecycleTransition != TerminationEventName {
+		t.Fatalf("expected unwrapped event for i-123486890234, got: %+v", event)
+	}
+
+	if event.snsEnvelope == nil || event.snsEnvelope.TopicArn != envelope.TopicArn {
+		t.Fatalf("expected sns envelope of topic: %v, got: %+v", envelope.TopicArn, event.snsEnvelope)
+	}
+
+	event, err = readMessage(&sqs.Message{Body: aws.String(_fakeLifecycleMessage)}, "some-queue")
+	if err != nil {
+		t.Fatalf("readMessage: expected error not to have occured, %v", err)
+	}
+
+	if event.snsEnvelope != nil {
+		t.Fatalf("expected raw message not to have an sns envelope, got: %+v", event.snsEnvelope)
+	}
+}
+
+func Test_VerifySNSSignature(t *testing.T) {
+	t.Log("Test_VerifySNSSignature: should accept notifications signed by the sns certificate only")
+	var (
+		certURL = "https://sns.us-west-2.amazonaws.com/SimpleNotificationService-test.pem"
+	)
+
+	envelope, certificate := _newSignedEnvelope(t, certURL)
+	snsCertificatesMu.Lock()
+	snsCertificates[certURL] = certificate
+	snsCertificatesMu.Unlock()
+
+	if err := verifySNSSignature(envelope); err != nil {
+		t.Fatalf("verifySNSSignature: expected error not to have occured, %v", err)
+	}
+
+	envelope.Message = `{"EC2InstanceId":"i-000000000000"}`
+	if err := verifySNSSignature(envelope); err == nil {
+		t.Fatalf("verifySNSSignature: expected error to have occured for a tampered message")
+	}
+
+	envelope.SigningCertURL = "https://example.com/cert.pem"
+	if err := verifySNSSignature(envelope); err == nil {
+		t.Fatalf("verifySNSSignature: expected error to have occured for a certificate not served by sns")
+	}
+}
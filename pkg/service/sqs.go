@@ -2,12 +2,16 @@ package service
 
 import (
 	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
 )
 
 func getQueueURLByName(s sqsiface.SQSAPI, name string) string {
@@ -46,10 +50,35 @@ func readMessage(message *sqs.Message, queueURL string) (*LifecycleEvent, error)
 		body    = aws.StringValue(message.Body)
 	)
 	log.Debugf("reading message id=%v", aws.StringValue(message.MessageId))
+
+	// notifications published through an SNS topic without raw message delivery are wrapped in an envelope
+	envelope, _ := unwrapSNSEnvelope(body)
+	if envelope != nil {
+		log.Debugf("unwrapping sns notification %v from topic %v", envelope.MessageID, envelope.TopicArn)
+		body = envelope.Message
+	}
+
+	// lifecycle actions routed by an EventBridge rule, as consumed by aws-node-termination-handler, carry the
+	// notification in their detail without its request and account ids
+	bridged, _ := unwrapEventBridgeEvent(body)
+	if bridged != nil && bridged.isLifecycleAction() {
+		log.Debugf("unwrapping eventbridge event %v of type %v", bridged.ID, bridged.DetailType)
+		body = string(bridged.Detail)
+	}
+
 	err := json.Unmarshal([]byte(body), event)
 	if err != nil {
 		return event, err
 	}
+	if bridged != nil && bridged.isLifecycleAction() {
+		if event.RequestID == "" {
+			event.RequestID = bridged.ID
+		}
+		if event.AccountID == "" {
+			event.AccountID = bridged.Account
+		}
+	}
+	event.snsEnvelope = envelope
 	event.SetReceiptHandle(receipt)
 	event.SetQueueURL(queueURL)
 	event.SetMessage(message)
@@ -57,7 +86,29 @@ func readMessage(message *sqs.Message, queueURL string) (*LifecycleEvent, error)
 	return event, nil
 }
 
+// getMessageSenderID returns the principal which sent the message, the session name of assumed roles is dropped
+func getMessageSenderID(message *sqs.Message) string {
+	if message == nil {
+		return ""
+	}
+	senderID := aws.StringValue(message.Attributes[sqs.MessageSystemAttributeNameSenderId])
+	return strings.SplitN(senderID, ":", 2)[0]
+}
+
+// getARNAccountID returns the account id of an ARN
+func getARNAccountID(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 5 {
+		return ""
+	}
+	return parts[4]
+}
+
 func deleteMessage(sqsClient sqsiface.SQSAPI, url, receiptHandle string) error {
+	// reconciled events were not received from the queue
+	if receiptHandle == "" {
+		return nil
+	}
 	log.Debugf("deleting message with receipt ID %v", receiptHandle)
 	input := &sqs.DeleteMessageInput{
 		QueueUrl:      aws.String(url),
@@ -69,3 +120,84 @@ func deleteMessage(sqsClient sqsiface.SQSAPI, url, receiptHandle string) error {
 	}
 	return nil
 }
+
+// changeMessageVisibility makes a received message visible again after the timeout in seconds
+func changeMessageVisibility(sqsClient sqsiface.SQSAPI, url, receiptHandle string, timeout int64) error {
+	log.Debugf("changing visibility of message with receipt ID %v to %vs", receiptHandle, timeout)
+	input := &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(url),
+		ReceiptHandle:     aws.String(receiptHandle),
+		VisibilityTimeout: aws.Int64(timeout),
+	}
+	_, err := sqsClient.ChangeMessageVisibility(input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// getQueueARN returns the arn of a queue
+func getQueueARN(sqsClient sqsiface.SQSAPI, url string) (string, error) {
+	input := &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(url),
+		AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameQueueArn}),
+	}
+	out, err := sqsClient.GetQueueAttributes(input)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.Attributes[sqs.QueueAttributeNameQueueArn]), nil
+}
+
+// getQueueVisibilityTimeout returns the default visibility timeout of a queue
+func getQueueVisibilityTimeout(sqsClient sqsiface.SQSAPI, url string) (time.Duration, error) {
+	out, err := sqsClient.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(url),
+		AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameVisibilityTimeout}),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	seconds, err := strconv.ParseInt(aws.StringValue(out.Attributes[sqs.QueueAttributeNameVisibilityTimeout]), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to parse visibility timeout")
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// getQueueDepth returns the approximate number of visible and in-flight messages in a queue
+func getQueueDepth(sqsClient sqsiface.SQSAPI, url string) (int64, int64, error) {
+	out, err := sqsClient.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl: aws.String(url),
+		AttributeNames: aws.StringSlice([]string{
+			sqs.QueueAttributeNameApproximateNumberOfMessages,
+			sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+		}),
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	visible, _ := strconv.ParseInt(aws.StringValue(out.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessages]), 10, 64)
+	inFlight, _ := strconv.ParseInt(aws.StringValue(out.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible]), 10, 64)
+	return visible, inFlight, nil
+}
+
+// getMessageReceiveCount returns the number of times a message was received, 0 when the attribute was not requested
+func getMessageReceiveCount(message *sqs.Message) int64 {
+	count, err := strconv.ParseInt(aws.StringValue(message.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// getMessageAge returns the time since a message was sent, or zero if the message has no SentTimestamp attribute
+func getMessageAge(message *sqs.Message) time.Duration {
+	sent, err := strconv.ParseInt(aws.StringValue(message.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Since(time.UnixMilli(sent))
+}
@@ -7,27 +7,65 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 )
 
 type stubSQS struct {
 	sqsiface.SQSAPI
-	FakeQueueMessages         []*sqs.Message
-	FakeQueueName             string
-	timesCalledReceiveMessage int
-	timesCalledDeleteMessage  int
-	timesCalledGetQueueUrl    int
+	FakeQueueMessages                  []*sqs.Message
+	FakeQueueName                      string
+	timesCalledReceiveMessage          int
+	timesCalledDeleteMessage           int
+	timesCalledGetQueueUrl             int
+	FakeQueueAttributes                map[string]*string
+	timesCalledChangeMessageVisibility int
+	receiveMessageErrors               []error
+	FakeQueueTags                      map[string]map[string]*string
+	lastVisibilityTimeout              int64
+}
+
+func (s *stubSQS) ListQueuesPages(input *sqs.ListQueuesInput, fn func(*sqs.ListQueuesOutput, bool) bool) error {
+	urls := []string{}
+	for url := range s.FakeQueueTags {
+		urls = append(urls, url)
+	}
+	fn(&sqs.ListQueuesOutput{QueueUrls: aws.StringSlice(urls)}, true)
+	return nil
+}
+
+func (s *stubSQS) ListQueueTags(input *sqs.ListQueueTagsInput) (*sqs.ListQueueTagsOutput, error) {
+	return &sqs.ListQueueTagsOutput{Tags: s.FakeQueueTags[aws.StringValue(input.QueueUrl)]}, nil
+}
+
+func (s *stubSQS) ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	s.timesCalledChangeMessageVisibility++
+	s.lastVisibilityTimeout = aws.Int64Value(input.VisibilityTimeout)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (s *stubSQS) GetQueueAttributes(input *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{Attributes: s.FakeQueueAttributes}, nil
 }
 
 func (s *stubSQS) ReceiveMessage(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
 	s.timesCalledReceiveMessage++
+	if len(s.receiveMessageErrors) > 0 {
+		err := s.receiveMessageErrors[0]
+		s.receiveMessageErrors = s.receiveMessageErrors[1:]
+		return nil, err
+	}
 	if len(s.FakeQueueMessages) != 0 {
 		return &sqs.ReceiveMessageOutput{Messages: s.FakeQueueMessages}, nil
 	}
 	return &sqs.ReceiveMessageOutput{}, nil
 }
 
+func (s *stubSQS) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	return s.ReceiveMessage(input)
+}
+
 func (s *stubSQS) DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
 	s.timesCalledDeleteMessage++
 	return &sqs.DeleteMessageOutput{}, nil
@@ -143,3 +181,65 @@ func Test_ReadMessage(t *testing.T) {
 		t.Fatalf("readMessage: expected event: %+v got: %+v", expectedLifecycleEvent, event)
 	}
 }
+
+func Test_GetQueueDepth(t *testing.T) {
+	t.Log("Test_GetQueueDepth: should return the approximate visible and in-flight messages")
+	stubber := &stubSQS{
+		FakeQueueAttributes: map[string]*string{
+			sqs.QueueAttributeNameApproximateNumberOfMessages:           aws.String("12"),
+			sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible: aws.String("3"),
+		},
+	}
+
+	visible, inFlight, err := getQueueDepth(stubber, "https://queue.amazonaws.com/80398EXAMPLE/MyQueue")
+	if err != nil {
+		t.Fatalf("getQueueDepth: expected error not to have occured, %v", err)
+	}
+
+	if visible != 12 || inFlight != 3 {
+		t.Fatalf("expected visible/in-flight: 12/3, got: %v/%v", visible, inFlight)
+	}
+}
+
+func Test_MonitorQueueStops(t *testing.T) {
+	t.Log("Test_MonitorQueueStops: should stop exporting the queue depth once the service is stopped")
+	interval := QueueMetricsInterval
+	QueueMetricsInterval = 10 * time.Millisecond
+	defer func() { QueueMetricsInterval = interval }()
+
+	mgr := New(Authenticator{SQSClient: &stubSQS{}}, _newBasicContext())
+	mgr.queues["https://queue.amazonaws.com/80398EXAMPLE/MyQueue"] = &consumedQueue{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mgr.monitorQueue()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	mgr.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected queue monitoring to stop with the service")
+	}
+}
+
+func Test_GetMessageAge(t *testing.T) {
+	t.Log("Test_GetMessageAge: should return the time since a message was sent")
+	sent := time.Now().Add(-time.Minute).UnixMilli()
+	message := &sqs.Message{
+		Attributes: map[string]*string{
+			sqs.MessageSystemAttributeNameSentTimestamp: aws.String(fmt.Sprint(sent)),
+		},
+	}
+
+	age := getMessageAge(message)
+	if age < time.Minute || age > time.Minute+time.Second*5 {
+		t.Fatalf("expected age: ~%v, got: %v", time.Minute, age)
+	}
+
+	if age := getMessageAge(&sqs.Message{}); age != 0 {
+		t.Fatalf("expected age without timestamp: 0, got: %v", age)
+	}
+}
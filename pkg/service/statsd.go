@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatsDClient sends metrics in the DogStatsD format over UDP, labels are sent as tags along with the configured tags
+type StatsDClient struct {
+	sync.Mutex
+	conn   net.Conn
+	prefix string
+	tags   []string
+	// gauges holds the current value of gauges, which DogStatsD only accepts as absolute values
+	gauges map[string]float64
+}
+
+// newStatsDClient returns a client sending to the configured address, or nil when StatsD metrics are disabled
+func newStatsDClient(ctx ManagerContext) *StatsDClient {
+	if ctx.StatsDAddress == "" {
+		return nil
+	}
+	conn, err := net.Dial("udp", ctx.StatsDAddress)
+	if err != nil {
+		log.Errorf("failed to connect to statsd at %v: %v", ctx.StatsDAddress, err)
+		return nil
+	}
+	return &StatsDClient{
+		conn:   conn,
+		prefix: MetricsNamespace + ".",
+		tags:   ctx.StatsDTags,
+		gauges: make(map[string]float64),
+	}
+}
+
+// formatStatsDLine formats a metric in the DogStatsD format, e.g. lifecycle_manager.failed_events_total:1|c|#asg_name:my-asg
+func formatStatsDLine(name string, value float64, metricType string, tags []string) string {
+	line := fmt.Sprintf("%v:%v|%v", name, strconv.FormatFloat(value, 'f', -1, 64), metricType)
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
+
+func (c *StatsDClient) metricTags(labels prometheus.Labels) []string {
+	tags := make([]string, 0, len(labels)+len(c.tags))
+	for k, v := range labels {
+		tags = append(tags, fmt.Sprintf("%v:%v", k, v))
+	}
+	sort.Strings(tags)
+	return append(tags, c.tags...)
+}
+
+func (c *StatsDClient) send(idx string, labels prometheus.Labels, value float64, metricType string) {
+	line := formatStatsDLine(c.prefix+idx, value, metricType, c.metricTags(labels))
+	if _, err := c.conn.Write([]byte(line)); err != nil {
+		log.Debugf("failed to send metric to statsd: %v", err)
+	}
+}
+
+func (c *StatsDClient) AddCounter(idx string, labels prometheus.Labels, value float64) {
+	c.send(idx, labels, value, "c")
+}
+
+func (c *StatsDClient) SetGauge(idx string, labels prometheus.Labels, value float64) {
+	c.Lock()
+	c.gauges[gaugeKey(idx, labels)] = value
+	c.Unlock()
+	c.send(idx, labels, value, "g")
+}
+
+func (c *StatsDClient) AddGauge(idx string, labels prometheus.Labels, value float64) {
+	key := gaugeKey(idx, labels)
+	c.Lock()
+	c.gauges[key] += value
+	value = c.gauges[key]
+	c.Unlock()
+	c.send(idx, labels, value, "g")
+}
+
+func (c *StatsDClient) DeleteGauge(idx string, labels prometheus.Labels) {
+	c.Lock()
+	delete(c.gauges, gaugeKey(idx, labels))
+	c.Unlock()
+}
+
+func (c *StatsDClient) ObserveHistogram(idx string, labels prometheus.Labels, value float64) {
+	c.send(idx, labels, value, "h")
+}
+
+// Start is a no-op since metrics are sent as they are recorded
+func (c *StatsDClient) Start() {}
+
+func gaugeKey(idx string, labels prometheus.Labels) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	key := idx
+	for _, k := range keys {
+		key += "," + k + "=" + labels[k]
+	}
+	return key
+}
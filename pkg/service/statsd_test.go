@@ -0,0 +1,68 @@
+package service
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_FormatStatsDLine(t *testing.T) {
+	t.Log("Test_FormatStatsDLine: should format metrics in the DogStatsD format")
+	tests := []struct {
+		value    float64
+		kind     string
+		tags     []string
+		expected string
+	}{
+		{1, "c", nil, "lifecycle_manager.failed_events_total:1|c"},
+		{2.5, "h", []string{"asg_name:my-asg", "env:prod"}, "lifecycle_manager.failed_events_total:2.5|h|#asg_name:my-asg,env:prod"},
+	}
+
+	for _, tc := range tests {
+		got := formatStatsDLine("lifecycle_manager.failed_events_total", tc.value, tc.kind, tc.tags)
+		if got != tc.expected {
+			t.Fatalf("expected line: %v, got: %v", tc.expected, got)
+		}
+	}
+}
+
+func Test_StatsDClient(t *testing.T) {
+	t.Log("Test_StatsDClient: should send counters, absolute gauges and histograms with labels and configured tags")
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := _newBasicContext()
+	ctx.StatsDAddress = conn.LocalAddr().String()
+	ctx.StatsDTags = []string{"env:prod"}
+	m := newMetricsServer(ctx, Authenticator{})
+	if len(m.backends) != 1 {
+		t.Fatalf("expected backends: 1, got: %v", len(m.backends))
+	}
+
+	labels := eventLabels(&LifecycleEvent{AutoScalingGroupName: "my-asg", LifecycleTransition: TerminationEventName})
+	m.AddCounter(FailedEventsTotalMetric, labels, 1)
+	m.IncGauge(TerminatingInstancesCountMetric, labels)
+	m.IncGauge(TerminatingInstancesCountMetric, labels)
+	m.ObserveHistogram(DrainDurationSecondsMetric, labels, 30)
+
+	expected := []string{
+		"lifecycle_manager.failed_events_total:1|c|#asg_name:my-asg,instance_refresh:false,transition:autoscaling:EC2_INSTANCE_TERMINATING,env:prod",
+		"lifecycle_manager.terminating_instances_count:1|g|#asg_name:my-asg,instance_refresh:false,transition:autoscaling:EC2_INSTANCE_TERMINATING,env:prod",
+		"lifecycle_manager.terminating_instances_count:2|g|#asg_name:my-asg,instance_refresh:false,transition:autoscaling:EC2_INSTANCE_TERMINATING,env:prod",
+		"lifecycle_manager.drain_duration_seconds:30|h|#asg_name:my-asg,instance_refresh:false,transition:autoscaling:EC2_INSTANCE_TERMINATING,env:prod",
+	}
+	buf := make([]byte, 1024)
+	for _, line := range expected {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("failed to read metric: %v", err)
+		}
+		if got := string(buf[:n]); got != line {
+			t.Fatalf("expected line: %v, got: %v", line, got)
+		}
+	}
+}
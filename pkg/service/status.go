@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+var (
+	// EventsEndpoint is the endpoint of the metrics server serving the events being processed
+	EventsEndpoint = "/events"
+)
+
+// InFlightEvent is the state of an event being processed served on the events endpoint
+type InFlightEvent struct {
+	RequestID         string     `json:"requestId"`
+	InstanceID        string     `json:"instanceId"`
+	ScalingGroupName  string     `json:"scalingGroupName"`
+	Transition        string     `json:"transition"`
+	InstanceRefreshID string     `json:"instanceRefreshId,omitempty"`
+	NodeName          string     `json:"nodeName,omitempty"`
+	Phase             EventPhase `json:"phase"`
+	StartTime         time.Time  `json:"startTime"`
+	AgeSeconds        float64    `json:"ageSeconds"`
+	// BlockingPods are the pods remaining to be evicted from the node of a draining event
+	BlockingPods []string `json:"blockingPods,omitempty"`
+}
+
+// queuedEvents returns the events in the work queue, oldest first
+func (mgr *Manager) queuedEvents() []*LifecycleEvent {
+	mgr.Lock()
+	events := make([]*LifecycleEvent, 0, len(mgr.workQueue))
+	for _, event := range mgr.workQueue {
+		events = append(events, event)
+	}
+	mgr.Unlock()
+
+	sort.Slice(events, func(i, j int) bool { return events[i].startTime.Before(events[j].startTime) })
+	return events
+}
+
+// InFlightEvents returns the state of the events being processed, oldest first. Events which are done or failed and
+// not yet removed from the work queue are left out. The pods remaining on the nodes of draining events are listed
+// from the cluster
+func (mgr *Manager) InFlightEvents() []InFlightEvent {
+	now := time.Now()
+	results := make([]InFlightEvent, 0)
+	for _, event := range mgr.queuedEvents() {
+		if event.isCompleted() {
+			continue
+		}
+		result := InFlightEvent{
+			RequestID:         event.RequestID,
+			InstanceID:        event.EC2InstanceID,
+			ScalingGroupName:  event.AutoScalingGroupName,
+			Transition:        event.LifecycleTransition,
+			InstanceRefreshID: event.instanceRefreshID,
+			NodeName:          event.referencedNode.Name,
+			Phase:             event.Phase(),
+			StartTime:         event.startTime.UTC(),
+			AgeSeconds:        now.Sub(event.startTime).Seconds(),
+		}
+
+		if result.Phase == PhaseDraining && result.NodeName != "" {
+			pods, err := getPodsForDeletion(mgr.ctx, mgr.kubeClient(event), result.NodeName, mgr.drainPolicy(event))
+			if err != nil {
+				log.Warnf("%v> failed to list pods remaining on node %v: %v", event.EC2InstanceID, result.NodeName, err)
+			}
+			for _, pod := range pods {
+				result.BlockingPods = append(result.BlockingPods, pod.Namespace+"/"+pod.Name)
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// eventsHandler serves the events being processed as JSON, oldest first
+func (mgr *Manager) eventsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mgr.InFlightEvents()); err != nil {
+			log.Errorf("failed to serve in-flight events: %v", err)
+		}
+	})
+}
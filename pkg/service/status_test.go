@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_InFlightEvents(t *testing.T) {
+	t.Log("Test_InFlightEvents: should serve the events being processed with the pods blocking their drain, oldest first")
+	kubeClient := fake.NewSimpleClientset()
+	node := v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+	}
+	kubeClient.CoreV1().Nodes().Create(context.Background(), &node, metav1.CreateOptions{})
+	kubeClient.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{})
+
+	mgr := New(Authenticator{KubernetesClient: kubeClient}, _newBasicContext())
+	draining := &LifecycleEvent{
+		RequestID:           "request-1",
+		EC2InstanceID:       "i-123486890234",
+		LifecycleTransition: TerminationEventName,
+		referencedNode:      node,
+		phase:               PhaseDraining,
+		startTime:           time.Now().Add(-time.Minute),
+	}
+	validated := &LifecycleEvent{
+		RequestID:           "request-2",
+		EC2InstanceID:       "i-22222222222222222",
+		LifecycleTransition: TerminationEventName,
+		phase:               PhaseValidated,
+		startTime:           time.Now(),
+	}
+	mgr.enqueue(validated)
+	mgr.enqueue(draining)
+
+	server := httptest.NewServer(mgr.eventsHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + EventsEndpoint)
+	if err != nil {
+		t.Fatalf("failed to get events: %v", err)
+	}
+	defer resp.Body.Close()
+
+	events := make([]InFlightEvent, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		t.Fatalf("failed to decode events: %v", err)
+	}
+
+	if len(events) != 2 || events[0].RequestID != "request-1" || events[1].RequestID != "request-2" {
+		t.Fatalf("expected events: [request-1 request-2], got: %+v", events)
+	}
+	if events[0].Phase != PhaseDraining || events[0].AgeSeconds < 60 {
+		t.Fatalf("expected draining event older than 60s, got: %+v", events[0])
+	}
+	if len(events[0].BlockingPods) != 1 || events[0].BlockingPods[0] != "default/pod-1" {
+		t.Fatalf("expected blocking pods: [default/pod-1], got: %v", events[0].BlockingPods)
+	}
+	if len(events[1].BlockingPods) != 0 {
+		t.Fatalf("expected no blocking pods of validated event, got: %v", events[1].BlockingPods)
+	}
+}
+
+func Test_InFlightEventsFailed(t *testing.T) {
+	t.Log("Test_InFlightEventsFailed: should not serve events which failed processing")
+	auth := Authenticator{
+		ScalingGroupClient: &stubAutoscaling{},
+		SQSClient:          &stubSQS{},
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	mgr := New(auth, _newBasicContext())
+
+	failed := &LifecycleEvent{RequestID: "request-1", EC2InstanceID: "i-123486890234", LifecycleTransition: TerminationEventName}
+	mgr.AddEvent(failed)
+	mgr.setEventPhase(failed, PhaseValidated)
+	mgr.FailEvent(errors.New("some failure"), failed, false)
+
+	// events which ended and are not yet removed from the work queue are not in-flight either
+	done := &LifecycleEvent{RequestID: "request-2", EC2InstanceID: "i-22222222222222222", LifecycleTransition: TerminationEventName, phase: PhaseDone}
+	mgr.enqueue(done)
+
+	if events := mgr.InFlightEvents(); len(events) != 0 {
+		t.Fatalf("expected no in-flight events, got: %+v", events)
+	}
+}
@@ -13,6 +13,23 @@ const (
 	TargetTypeTargetGroup TargetType = "target-group"
 )
 
+// DescribeTagsBatchSize is the maximum number of resources per DescribeTags call
+const DescribeTagsBatchSize = 20
+
+// matchesTagFilters returns true if tags contain all filters, a filter with an empty value only requires the key to exist
+func matchesTagFilters(tags map[string]string, filters map[string]string) bool {
+	for key, value := range filters {
+		v, ok := tags[key]
+		if !ok {
+			return false
+		}
+		if value != "" && v != value {
+			return false
+		}
+	}
+	return true
+}
+
 // Target defines a deregistration target
 type Target struct {
 	Type       TargetType
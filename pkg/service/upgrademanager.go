@@ -0,0 +1,67 @@
+package service
+
+import (
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// DefaultUpgradeManagerTagFilters are the tags upgrade-manager sets on the instances of a RollingUpgrade which it
+	// is replacing
+	DefaultUpgradeManagerTagFilters = map[string]string{"upgrademgr.keikoproj.io/state": "in-progress"}
+)
+
+// upgradeManagerTagFilters returns the instance tags identifying terminations by an upgrade-manager RollingUpgrade
+func (mgr *Manager) upgradeManagerTagFilters() map[string]string {
+	if filters := mgr.context.UpgradeManagerTagFilters; len(filters) > 0 {
+		return filters
+	}
+	return DefaultUpgradeManagerTagFilters
+}
+
+// isUpgradeManagerTermination returns true if the event's instance is replaced by an upgrade-manager RollingUpgrade,
+// the tags of the instance are fetched unless the event was already enriched with them
+func (mgr *Manager) isUpgradeManagerTermination(event *LifecycleEvent) bool {
+	if event.instanceTags == nil {
+		tags, err := getInstanceTags(mgr.authenticator.EC2Client, event.EC2InstanceID)
+		if err != nil {
+			log.Warnf("%v> failed to get instance tags, assuming it is not replaced by upgrade-manager: %v", event.EC2InstanceID, err)
+			return false
+		}
+		event.instanceTags = tags
+	}
+	return matchesTagFilters(event.instanceTags, mgr.upgradeManagerTagFilters())
+}
+
+// isDrainedByUpgradeManager returns true if the event's node was already cordoned and drained by upgrade-manager, so
+// that it is not drained a second time. The node is looked up again as it may have been drained since the event was
+// received
+func (mgr *Manager) isDrainedByUpgradeManager(event *LifecycleEvent) bool {
+	var (
+		kubeClient = mgr.kubeClient(event)
+	)
+
+	if !mgr.context.WithUpgradeManager || !mgr.isUpgradeManagerTermination(event) {
+		return false
+	}
+
+	node, err := kubeClient.CoreV1().Nodes().Get(event.Context(), event.referencedNode.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Warnf("%v> failed to get node/%v, draining it: %v", event.EC2InstanceID, event.referencedNode.Name, err)
+		return false
+	}
+	if !node.Spec.Unschedulable {
+		return false
+	}
+
+	pods, err := getPodsForDeletion(event.Context(), kubeClient, node.Name, mgr.drainPolicy(event))
+	if err != nil {
+		log.Warnf("%v> failed to list pods of node/%v, draining it: %v", event.EC2InstanceID, node.Name, err)
+		return false
+	}
+	if len(pods) > 0 {
+		log.Infof("%v> node/%v is cordoned by upgrade-manager with %v pods left to evict, draining it", event.EC2InstanceID, node.Name, len(pods))
+		return false
+	}
+	return true
+}
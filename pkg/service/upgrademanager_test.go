@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_IsDrainedByUpgradeManager(t *testing.T) {
+	t.Log("Test_IsDrainedByUpgradeManager: should only skip the drain of nodes already drained by upgrade-manager")
+	ec2Stubber := &stubEC2{
+		instances: []*ec2.Instance{
+			{
+				InstanceId: aws.String("i-111111111111"),
+				Tags:       []*ec2.Tag{{Key: aws.String("upgrademgr.keikoproj.io/state"), Value: aws.String("in-progress")}},
+			},
+			{
+				InstanceId: aws.String("i-222222222222"),
+			},
+		},
+	}
+
+	tests := []struct {
+		instanceID    string
+		unschedulable bool
+		pods          int
+		enabled       bool
+		expected      bool
+	}{
+		{"i-111111111111", true, 0, true, true},
+		{"i-111111111111", true, 0, false, false},
+		{"i-111111111111", false, 0, true, false},
+		{"i-111111111111", true, 1, true, false},
+		{"i-222222222222", true, 0, true, false},
+	}
+
+	for _, tc := range tests {
+		kubeClient := fake.NewSimpleClientset()
+		node := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Spec:       v1.NodeSpec{Unschedulable: tc.unschedulable},
+		}
+		kubeClient.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+		for i := 0; i < tc.pods; i++ {
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"},
+				Spec:       v1.PodSpec{NodeName: "node-1"},
+			}
+			kubeClient.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{})
+		}
+
+		ctx := _newBasicContext()
+		ctx.WithUpgradeManager = tc.enabled
+		mgr := New(Authenticator{KubernetesClient: kubeClient, EC2Client: ec2Stubber}, ctx)
+		event := &LifecycleEvent{EC2InstanceID: tc.instanceID}
+		event.SetContext(context.WithCancel(context.Background()))
+		event.SetReferencedNode(*node)
+
+		if got := mgr.isDrainedByUpgradeManager(event); got != tc.expected {
+			t.Fatalf("expected instance %v with node unschedulable: %v, pods: %v and enabled: %v drained: %v, got: %v", tc.instanceID, tc.unschedulable, tc.pods, tc.enabled, tc.expected, got)
+		}
+	}
+}
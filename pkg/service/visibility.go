@@ -0,0 +1,81 @@
+package service
+
+import (
+	"time"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrValidationTimeout is returned when the validation of an event exceeds the validation timeout
+	ErrValidationTimeout = errors.New("event validation timed out")
+)
+
+// extendMessageVisibility keeps the message of an event invisible in the queue while it is validated, throttled
+// validation calls could otherwise exceed the queue's visibility timeout and have the message redelivered. The
+// visibility is extended by the queue's visibility timeout each time half of it has passed, so that it is never
+// shortened, the returned function stops the extensions once an ongoing extension is done
+func (mgr *Manager) extendMessageVisibility(event *LifecycleEvent) func() {
+	var (
+		queue   = mgr.authenticator.SQSClient
+		timeout = mgr.queueVisibility(event.queueURL)
+	)
+
+	if event.receiptHandle == "" || timeout < 2*time.Second {
+		return func() {}
+	}
+
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				log.Debugf("%v> validation is slow, extending message visibility by %v", event.EC2InstanceID, timeout)
+				if err := changeMessageVisibility(queue, event.queueURL, event.receiptHandle, int64(timeout.Seconds())); err != nil {
+					log.Warnf("%v> failed to extend message visibility: %v", event.EC2InstanceID, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// validateEventWithTimeout validates an event within the validation timeout, events whose validation times out are
+// failed with a transient ErrValidationTimeout. Their validation is left to finish in the background, what it learns
+// about the event is only applied when it finishes in time
+func (mgr *Manager) validateEventWithTimeout(e *LifecycleEvent) error {
+	timeout := time.Duration(mgr.context.ValidationTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		return mgr.validateEvent(e)
+	}
+
+	type check struct {
+		validation *eventValidation
+		err        error
+	}
+	result := make(chan check, 1)
+	go func() {
+		validation, err := mgr.checkEvent(e)
+		result <- check{validation: validation, err: err}
+	}()
+
+	select {
+	case c := <-result:
+		if c.err != nil {
+			return c.err
+		}
+		c.validation.apply(e)
+		return nil
+	case <-time.After(timeout):
+		return NewTransientError(errors.Wrapf(ErrValidationTimeout, "validation exceeded %v", timeout))
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type countingSQS struct {
+	stubSQS
+	timesCalledChangeMessageVisibility int32
+}
+
+func (s *countingSQS) ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	atomic.AddInt32(&s.timesCalledChangeMessageVisibility, 1)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+type slowAutoscaling struct {
+	*stubAutoscaling
+	release chan struct{}
+}
+
+func (a *slowAutoscaling) DescribeLifecycleHooks(input *autoscaling.DescribeLifecycleHooksInput) (*autoscaling.DescribeLifecycleHooksOutput, error) {
+	<-a.release
+	return a.stubAutoscaling.DescribeLifecycleHooks(input)
+}
+
+func Test_ExtendMessageVisibility(t *testing.T) {
+	t.Log("Test_ExtendMessageVisibility: should extend the visibility of a message until stopped")
+	sqsStubber := &countingSQS{}
+	mgr := New(Authenticator{SQSClient: sqsStubber}, _newBasicContext())
+	mgr.queues["my-queue-url"] = &consumedQueue{visibility: 2 * time.Second}
+
+	event := &LifecycleEvent{
+		EC2InstanceID: "i-123486890234",
+		queueURL:      "my-queue-url",
+		receiptHandle: "MbZj6wDWli+JvwwJaBV+3dcjk2YW2vA3+STFFljTM8tJJg6HRG6PYSasuWXPJB+Cw=",
+	}
+	stop := mgr.extendMessageVisibility(event)
+	time.Sleep(2500 * time.Millisecond)
+	stop()
+
+	extended := atomic.LoadInt32(&sqsStubber.timesCalledChangeMessageVisibility)
+	if extended == 0 {
+		t.Fatal("expected visibility of message to be extended")
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if got := atomic.LoadInt32(&sqsStubber.timesCalledChangeMessageVisibility); got != extended {
+		t.Fatalf("expected timesCalledChangeMessageVisibility: %v, got: %v", extended, got)
+	}
+}
+
+func Test_ValidationTimeout(t *testing.T) {
+	t.Log("Test_ValidationTimeout: should return the message of an event whose validation timed out to the queue")
+	var (
+		sqsStubber = &stubSQS{}
+		asgStubber = &slowAutoscaling{
+			stubAutoscaling: &stubAutoscaling{
+				lifecycleHooks: []*autoscaling.LifecycleHook{
+					{
+						AutoScalingGroupName: aws.String("my-asg"),
+						HeartbeatTimeout:     aws.Int64(60),
+					},
+				},
+			},
+			release: make(chan struct{}),
+		}
+	)
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-123486890234"},
+	}
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		SQSClient:          sqsStubber,
+		KubernetesClient:   fake.NewSimpleClientset(node),
+	}
+	ctx := _newBasicContext()
+	ctx.ValidationTimeoutSeconds = 1
+	ctx.TransientRetryAttempts = 2
+	mgr := New(auth, ctx)
+
+	event := &LifecycleEvent{
+		LifecycleHookName:    "my-hook",
+		AutoScalingGroupName: "my-asg",
+		LifecycleTransition:  TerminationEventName,
+		EC2InstanceID:        "i-123486890234",
+		receiptHandle:        "MbZj6wDWli+JvwwJaBV+3dcjk2YW2vA3+STFFljTM8tJJg6HRG6PYSasuWXPJB+Cw=",
+		message: &sqs.Message{
+			Attributes: map[string]*string{
+				sqs.MessageSystemAttributeNameApproximateReceiveCount: aws.String("1"),
+			},
+		},
+	}
+
+	err := mgr.validateEventWithTimeout(event)
+	if ClassifyError(err) != ErrorClassTransient {
+		t.Fatalf("expected error class: %v, got: %v (%v)", ErrorClassTransient, ClassifyError(err), err)
+	}
+
+	if !mgr.RetryEvent(err, event) {
+		t.Fatal("expected event whose validation timed out to be retried")
+	}
+	if sqsStubber.timesCalledChangeMessageVisibility != 1 {
+		t.Fatalf("expected timesCalledChangeMessageVisibility: %v, got: %v", 1, sqsStubber.timesCalledChangeMessageVisibility)
+	}
+
+	event.message.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount] = aws.String("3")
+	if mgr.RetryEvent(err, event) {
+		t.Fatal("expected event which exhausted its retries not to be retried")
+	}
+
+	// the validation left in the background does not change the retried event once it finishes
+	close(asgStubber.release)
+	time.Sleep(100 * time.Millisecond)
+	if event.heartbeatInterval != 0 || event.referencedNode.Name != "" {
+		t.Fatalf("expected event not to be modified by timed out validation, got heartbeat interval: %v, node: %v", event.heartbeatInterval, event.referencedNode.Name)
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// EBSCSIVolumePrefix is the prefix of attached volume names managed by the EBS CSI driver
+const EBSCSIVolumePrefix = "kubernetes.io/csi/ebs.csi.aws.com^"
+
+// getNodeCSIVolumeIDs returns the ids of EBS volumes the CSI driver reports as attached to a node
+func getNodeCSIVolumeIDs(node v1.Node) []string {
+	ids := []string{}
+	for _, volume := range node.Status.VolumesAttached {
+		name := string(volume.Name)
+		if strings.HasPrefix(name, EBSCSIVolumePrefix) {
+			ids = append(ids, strings.TrimPrefix(name, EBSCSIVolumePrefix))
+		}
+	}
+	return ids
+}
+
+// waitForVolumesDetached waits until the CSI volumes of the event's node are no longer attached to the instance
+func (mgr *Manager) waitForVolumesDetached(event *LifecycleEvent) error {
+	var (
+		ctx        = &mgr.context
+		ec2Client  = mgr.authenticator.EC2Client
+		instanceID = event.EC2InstanceID
+		volumeIDs  = getNodeCSIVolumeIDs(event.referencedNode)
+	)
+
+	// the volumes of the lifecycle-manager pod remain attached while it completes the termination of its own node
+	if !ctx.WithVolumeDetachWait || ec2Client == nil || len(volumeIDs) == 0 || mgr.isSelfNode(event) {
+		return nil
+	}
+
+	log.Infof("%v> waiting for %v csi volumes to detach", instanceID, len(volumeIDs))
+	err := waitForVolumeDetachment(event, ec2Client, volumeIDs, mgr.waiterConfig())
+	if err != nil {
+		return err
+	}
+	log.Infof("%v> csi volumes detached", instanceID)
+	return nil
+}
+
+func waitForVolumeDetachment(event *LifecycleEvent, ec2Client ec2iface.EC2API, volumeIDs []string, config WaiterConfig) error {
+	var (
+		instanceID = event.EC2InstanceID
+	)
+
+	input := &ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("attachment.instance-id"),
+				Values: aws.StringSlice([]string{instanceID}),
+			},
+			{
+				Name:   aws.String("volume-id"),
+				Values: aws.StringSlice(volumeIDs),
+			},
+		},
+	}
+
+	for ieb, err := config.newBackoff(event.Context()); err == nil; err = ieb.Next() {
+
+		if event.isCompleted() {
+			return errors.New("event finished execution during volume detachment wait")
+		}
+
+		if err := event.contextError("volume detachment wait"); err != nil {
+			return err
+		}
+
+		// stop before the lifecycle hook expires rather than waiting past it
+		if remaining, ok := event.remainingTime(); ok && remaining <= config.maxDelay() {
+			return fmt.Errorf("lifecycle hook deadline in %v reached during volume detachment wait", remaining.Round(time.Second))
+		}
+
+		attached := []string{}
+		err := ec2Client.DescribeVolumesPagesWithContext(event.Context(), input, func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+			for _, volume := range page.Volumes {
+				attached = append(attached, aws.StringValue(volume.VolumeId))
+			}
+			return page.NextToken != nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(attached) == 0 {
+			return nil
+		}
+		log.Debugf("%v> volumes %v pending detachment", instanceID, attached)
+	}
+
+	return errors.New("wait for volume detachment timed out")
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	v1 "k8s.io/api/core/v1"
+)
+
+type stubEC2 struct {
+	ec2iface.EC2API
+	attachedVolumes            [][]string
+	timesCalledDescribeVolumes int
+	instances                  []*ec2.Instance
+}
+
+func (e *stubEC2) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	instances := []*ec2.Instance{}
+	for _, instance := range e.instances {
+		for _, id := range input.InstanceIds {
+			if aws.StringValue(instance.InstanceId) == aws.StringValue(id) {
+				instances = append(instances, instance)
+			}
+		}
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: instances}}}, nil
+}
+
+func (e *stubEC2) DescribeVolumesPages(input *ec2.DescribeVolumesInput, callback func(*ec2.DescribeVolumesOutput, bool) bool) error {
+	volumes := []*ec2.Volume{}
+	if e.timesCalledDescribeVolumes < len(e.attachedVolumes) {
+		for _, id := range e.attachedVolumes[e.timesCalledDescribeVolumes] {
+			volumes = append(volumes, &ec2.Volume{VolumeId: aws.String(id)})
+		}
+	}
+	e.timesCalledDescribeVolumes++
+	callback(&ec2.DescribeVolumesOutput{Volumes: volumes}, true)
+	return nil
+}
+
+func (e *stubEC2) DescribeVolumesPagesWithContext(ctx aws.Context, input *ec2.DescribeVolumesInput, callback func(*ec2.DescribeVolumesOutput, bool) bool, opts ...request.Option) error {
+	return e.DescribeVolumesPages(input, callback)
+}
+
+func Test_GetNodeCSIVolumeIDs(t *testing.T) {
+	t.Log("Test_GetNodeCSIVolumeIDs: should return the ids of volumes attached by the EBS CSI driver")
+	node := v1.Node{
+		Status: v1.NodeStatus{
+			VolumesAttached: []v1.AttachedVolume{
+				{Name: "kubernetes.io/csi/ebs.csi.aws.com^vol-0123456789abcdef0"},
+				{Name: "kubernetes.io/csi/efs.csi.aws.com^fs-01234567"},
+			},
+		},
+	}
+
+	ids := getNodeCSIVolumeIDs(node)
+	if len(ids) != 1 || ids[0] != "vol-0123456789abcdef0" {
+		t.Fatalf("expected volume ids: %v, got: %v", []string{"vol-0123456789abcdef0"}, ids)
+	}
+}
+
+func Test_WaitForVolumeDetachment(t *testing.T) {
+	t.Log("Test_WaitForVolumeDetachment: should wait until csi volumes are no longer attached to the instance")
+	ec2Stubber := &stubEC2{
+		attachedVolumes: [][]string{{"vol-0123456789abcdef0"}},
+	}
+
+	event := &LifecycleEvent{EC2InstanceID: "i-123456789012", startTime: time.Now()}
+	config := WaiterConfig{MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	err := waitForVolumeDetachment(event, ec2Stubber, []string{"vol-0123456789abcdef0"}, config)
+	if err != nil {
+		t.Fatalf("waitForVolumeDetachment: expected error not to have occured, %v", err)
+	}
+
+	if ec2Stubber.timesCalledDescribeVolumes != 2 {
+		t.Fatalf("expected timesCalledDescribeVolumes: %v, got: %v", 2, ec2Stubber.timesCalledDescribeVolumes)
+	}
+}
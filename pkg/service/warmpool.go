@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/keikoproj/lifecycle-manager/pkg/log"
+)
+
+const (
+	// TransitionLocationWarmPool is the origin or destination of lifecycle transitions from or to a warm pool
+	TransitionLocationWarmPool = "WarmPool"
+	// WarmPoolStatePrefix is the prefix of the lifecycle states of instances in a warm pool, e.g. Warmed:Terminating
+	WarmPoolStatePrefix = "Warmed:"
+)
+
+// isWarmPoolLocation returns true if the origin or destination of a transition is a warm pool, either as a location
+// or as a warm pool lifecycle state
+func isWarmPoolLocation(location string) bool {
+	return location == TransitionLocationWarmPool || strings.HasPrefix(location, WarmPoolStatePrefix)
+}
+
+// isFromWarmPool returns true if the event's instance transitions out of a warm pool
+func (e *LifecycleEvent) isFromWarmPool() bool { return isWarmPoolLocation(e.Origin) }
+
+// isToWarmPool returns true if the event's instance transitions into a warm pool
+func (e *LifecycleEvent) isToWarmPool() bool { return isWarmPoolLocation(e.Destination) }
+
+// isWarmPoolInstance returns true if the event's instance is in a warm pool and was never registered as a node,
+// such as warmed instances being terminated or instances launching into a warm pool
+func (e *LifecycleEvent) isWarmPoolInstance() bool {
+	if e.LifecycleTransition == LaunchEventName {
+		return e.isToWarmPool()
+	}
+	return e.isFromWarmPool() && e.referencedNode.Name == ""
+}
+
+// CompleteWarmPoolEvent completes the lifecycle hook of a warm pool instance without draining it, so that the
+// warm pool keeps cycling
+func (mgr *Manager) CompleteWarmPoolEvent(event *LifecycleEvent) {
+	var (
+		metrics = mgr.metrics
+	)
+
+	log.Infof("%v> instance is in a warm pool and not a node, completing lifecycle hook (%v -> %v)", event.EC2InstanceID, event.Origin, event.Destination)
+	msg := fmt.Sprintf(EventMessageWarmPoolInstanceCompleted, event.EC2InstanceID, event.Origin, event.Destination)
+	mgr.publishEvent(event, EventReasonWarmPoolInstanceCompleted, getMessageFields(event, msg))
+	metrics.AddCounter(WarmPoolEventsTotalMetric, eventLabels(event), 1)
+	mgr.CompleteEvent(event)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_IsWarmPoolInstance(t *testing.T) {
+	t.Log("Test_IsWarmPoolInstance: should detect warm pool instances which are not nodes")
+	node := v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	tests := []struct {
+		name     string
+		event    *LifecycleEvent
+		expected bool
+	}{
+		{"terminating from warm pool", &LifecycleEvent{LifecycleTransition: TerminationEventName, Origin: "WarmPool", Destination: "EC2"}, true},
+		{"terminating from warmed state", &LifecycleEvent{LifecycleTransition: TerminationEventName, Origin: "Warmed:Terminating"}, true},
+		{"terminating warmed node", &LifecycleEvent{LifecycleTransition: TerminationEventName, Origin: "WarmPool", referencedNode: node}, false},
+		{"terminating from group", &LifecycleEvent{LifecycleTransition: TerminationEventName, Origin: "AutoScalingGroup", Destination: "EC2"}, false},
+		{"launching into warm pool", &LifecycleEvent{LifecycleTransition: LaunchEventName, Origin: "EC2", Destination: "WarmPool"}, true},
+		{"launching from warm pool", &LifecycleEvent{LifecycleTransition: LaunchEventName, Origin: "WarmPool", Destination: "AutoScalingGroup"}, false},
+	}
+
+	for _, tc := range tests {
+		if got := tc.event.isWarmPoolInstance(); got != tc.expected {
+			t.Fatalf("%v: expected warm pool instance: %v, got: %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func Test_ProcessWarmPoolTermination(t *testing.T) {
+	t.Log("Test_ProcessWarmPoolTermination: should complete the hook of a warmed instance which is not a node without draining")
+	asgStubber := &stubAutoscaling{
+		lifecycleHooks: []*autoscaling.LifecycleHook{
+			{
+				AutoScalingGroupName: aws.String("my-asg"),
+				HeartbeatTimeout:     aws.Int64(60),
+			},
+		},
+	}
+	sqsStubber := &stubSQS{}
+	auth := Authenticator{
+		ScalingGroupClient: asgStubber,
+		SQSClient:          sqsStubber,
+		KubernetesClient:   fake.NewSimpleClientset(),
+	}
+	mgr := New(auth, _newBasicContext())
+
+	message := &sqs.Message{
+		ReceiptHandle: aws.String("MbZj6wDWli+JvwwJaBV+3dcjk2YW2vA3+STFFljTM8tJJg6HRG6PYSasuWXPJB+Cw="),
+		Body: aws.String(`{"LifecycleHookName":"my-hook","RequestId":"63f5b5c2-58b3-0574-b7d5-b3162d0268f0",` +
+			`"LifecycleTransition":"autoscaling:EC2_INSTANCE_TERMINATING","AutoScalingGroupName":"my-asg",` +
+			`"EC2InstanceId":"i-123486890234","LifecycleActionToken":"token-1","Origin":"WarmPool","Destination":"EC2"}`),
+	}
+
+	event, err := mgr.newEvent(message, "https://queue-url")
+	if err != nil {
+		t.Fatalf("newEvent: expected error not to have occured, %v", err)
+	}
+	if event.Origin != "WarmPool" || event.Destination != "EC2" {
+		t.Fatalf("expected origin/destination: WarmPool/EC2, got: %v/%v", event.Origin, event.Destination)
+	}
+
+	mgr.Process(event)
+
+	if event.Phase() != PhaseDone {
+		t.Fatalf("expected phase: %v, got: %v", PhaseDone, event.Phase())
+	}
+	if event.phaseCompleted(PhaseDraining) {
+		t.Fatalf("expected phase %v not to be completed, got phases: %v", PhaseDraining, event.phases)
+	}
+	if asgStubber.timesCalledCompleteLifecycleAction != 1 {
+		t.Fatalf("expected timesCalledCompleteLifecycleAction: %v, got: %v", 1, asgStubber.timesCalledCompleteLifecycleAction)
+	}
+	if sqsStubber.timesCalledDeleteMessage != 1 {
+		t.Fatalf("expected timesCalledDeleteMessage: %v, got: %v", 1, sqsStubber.timesCalledDeleteMessage)
+	}
+}